@@ -0,0 +1,163 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	daveDN = "CN=dave,OU=People,DC=ad,DC=uoregon,DC=edu"
+	erinDN = "CN=erin,OU=People,DC=ad,DC=uoregon,DC=edu"
+)
+
+// addExpiredUser adds erin, whose account expired on 2012-12-14.
+func (e *testEnv) addExpiredUser(t *testing.T) {
+	t.Helper()
+	req := ldap.NewAddRequest(erinDN, nil)
+	req.Attribute("objectClass", []string{"user"})
+	req.Attribute("objectCategory", []string{"person"})
+	req.Attribute("cn", []string{"erin"})
+	req.Attribute("sAMAccountName", []string{"erin"})
+	req.Attribute("userAccountControl", []string{"512"})
+	req.Attribute("accountExpires", []string{"130000000000000000"})
+	if err := e.dir.Add(req); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestAddInactiveAccount checks that adding a disabled or expired account
+// is refused with its status unless --allow-disabled or
+// allow_disabled_members says otherwise.
+func TestAddInactiveAccount(t *testing.T) {
+	alphaAdminsDN := "CN=is.racs.pirg.alpha.admins,OU=alpha,OU=PIRGS,OU=RACS,DC=ad,DC=uoregon,DC=edu"
+	cases := []struct {
+		name     string
+		extra    string
+		before   []string
+		args     []string
+		groupDN  string
+		memberDN string
+		wantCode int
+		want     string
+	}{
+		{
+			name:     "enabled",
+			args:     []string{"pirg", "alpha", "add-member", "carol"},
+			groupDN:  alphaDN,
+			memberDN: carolDN,
+		},
+		{
+			name:     "disabled",
+			args:     []string{"pirg", "alpha", "add-member", "dave"},
+			groupDN:  alphaDN,
+			memberDN: daveDN,
+			wantCode: 1,
+			want:     "account dave is disabled, use --allow-disabled",
+		},
+		{
+			name:     "expired",
+			args:     []string{"pirg", "alpha", "add-member", "erin"},
+			groupDN:  alphaDN,
+			memberDN: erinDN,
+			wantCode: 1,
+			want:     "account erin is expired since 2012-12-14",
+		},
+		{
+			name:     "missing",
+			args:     []string{"pirg", "alpha", "add-member", "nosuch"},
+			wantCode: 1,
+			want:     `user "nosuch" not found`,
+		},
+		{
+			name:     "allow disabled",
+			args:     []string{"pirg", "alpha", "add-member", "--allow-disabled", "dave"},
+			groupDN:  alphaDN,
+			memberDN: daveDN,
+		},
+		{
+			name:     "allow expired",
+			args:     []string{"pirg", "alpha", "add-member", "--allow-disabled", "erin"},
+			groupDN:  alphaDN,
+			memberDN: erinDN,
+		},
+		{
+			name:     "allow_disabled_members",
+			extra:    "allow_disabled_members: true\n",
+			args:     []string{"pirg", "alpha", "add-member", "dave"},
+			groupDN:  alphaDN,
+			memberDN: daveDN,
+		},
+		{
+			name:     "add-admin",
+			before:   []string{"pirg", "alpha", "add-member", "--allow-disabled", "dave"},
+			args:     []string{"pirg", "alpha", "add-admin", "dave"},
+			groupDN:  alphaAdminsDN,
+			memberDN: daveDN,
+			wantCode: 1,
+			want:     "Skipped 1 inactive admin account(s): account dave is disabled",
+		},
+		{
+			name:     "set-pi",
+			args:     []string{"pirg", "alpha", "set-pi", "--pi", "erin"},
+			groupDN:  alphaDN,
+			memberDN: erinDN,
+			wantCode: 1,
+			want:     "account erin is expired",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := newTestEnv(t, tc.extra)
+			env.addExpiredUser(t)
+			if tc.before != nil {
+				if code, stdout, _ := env.run(tc.before...); code != 0 {
+					t.Fatalf("%s exited %d:\n%s", strings.Join(tc.before, " "), code, stdout)
+				}
+			}
+			code, stdout, stderr := env.run(tc.args...)
+			if code != tc.wantCode {
+				t.Fatalf("exit %d, want %d, stdout:\n%s\nstderr:\n%s", code, tc.wantCode, stdout, stderr)
+			}
+			if !strings.Contains(stdout, tc.want) {
+				t.Errorf("stdout doesn't contain %q:\n%s", tc.want, stdout)
+			}
+			if tc.groupDN == "" {
+				return
+			}
+			if got := env.hasMember(tc.groupDN, tc.memberDN); got != (tc.wantCode == 0) {
+				t.Errorf("%s is a member = %v after exit %d", tc.memberDN, got, code)
+			}
+		})
+	}
+}
+
+// TestAddInactiveAccountsBatch checks that inactive accounts among several
+// usernames are reported one by one while the active ones are still added.
+func TestAddInactiveAccountsBatch(t *testing.T) {
+	env := newTestEnv(t, "")
+	env.addExpiredUser(t)
+	code, stdout, stderr := env.run("pirg", "alpha", "add-member", "carol", "dave", "erin")
+	if code != 1 {
+		t.Fatalf("exit %d, want 1, stdout:\n%s\nstderr:\n%s", code, stdout, stderr)
+	}
+	for _, want := range []string{
+		"(+1 added, 2 failed)",
+		"Skipped 2 inactive member account(s)",
+		"account dave is disabled",
+		"account erin is expired since 2012-12-14",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("stdout doesn't contain %q:\n%s", want, stdout)
+		}
+	}
+	if !env.hasMember(alphaDN, carolDN) {
+		t.Error("carol not added alongside the inactive accounts")
+	}
+	for _, dn := range []string{daveDN, erinDN} {
+		if env.hasMember(alphaDN, dn) {
+			t.Errorf("%s added despite being inactive", dn)
+		}
+	}
+}
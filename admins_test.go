@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+// TestAdminsGroupDNFromConfig checks that the top-level admins group comes
+// from the config rather than being fixed.
+func TestAdminsGroupDNFromConfig(t *testing.T) {
+	env := newTestEnv(t, "")
+	if code, stdout, _ := env.run("admins", "list", "--namespace", "pirg"); code != 0 || stdout != "alice\ncarol\n" {
+		t.Errorf("default pirg admins: exit %d, stdout:\n%s", code, stdout)
+	}
+
+	env = newTestEnv(t, "pirg_admins_group_dn: "+talapasUsersDN+"\n")
+	if code, stdout, _ := env.run("admins", "list", "--namespace", "pirg"); code != 0 || stdout != "alice\nbob\ncarol\n" {
+		t.Errorf("configured pirg admins: exit %d, stdout:\n%s", code, stdout)
+	}
+	if code, stdout, stderr := env.run("pirg", "alpha", "add-admin", "bob"); code != 0 {
+		t.Fatalf("exit %d, stdout:\n%s\nstderr:\n%s", code, stdout, stderr)
+	}
+	if env.hasMember("CN=IS.RACS.Talapas.PirgAdmins,OU=RACS,OU=Groups,OU=IS,OU=Units,DC=ad,DC=uoregon,DC=edu", bobDN) {
+		t.Error("admin added to the default admins group instead of the configured one")
+	}
+}
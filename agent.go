@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/uoracs/directory-manager/internal/agent"
+	"github.com/uoracs/directory-manager/internal/config"
+)
+
+// agentRequest returns the agent request for command, and false for
+// commands the agent doesn't answer, including ones whose flags change
+// the output.
+func agentRequest(command string) (agent.Request, bool) {
+	req := agent.Request{Op: command}
+	switch command {
+	case "pirg list":
+		if CLI.Pirg.List.ChangedSince != "" || CLI.Pirg.List.Long || CLI.Pirg.List.PI != "" || CLI.Pirg.List.BaseDN != "" {
+			return req, false
+		}
+	case "pirg <name> get-pi":
+		req.Args = []string{CLI.Pirg.Name.Name}
+	case "pirg <name> list-members":
+		opts := CLI.Pirg.Name.ListMembers
+		if len(opts.Fields) > 0 || opts.ExcludeAdmins || opts.Count || opts.DNOnly || opts.Summary || opts.Limit != 0 || opts.Offset != 0 || opts.Sort != "" || jsonOutput() {
+			return req, false
+		}
+		req.Args = []string{CLI.Pirg.Name.Name}
+	case "pirg <name> list-admins":
+		if len(CLI.Pirg.Name.ListAdmins.Fields) > 0 || CLI.Pirg.Name.ListAdmins.WithRole {
+			return req, false
+		}
+		req.Args = []string{CLI.Pirg.Name.Name}
+	case "cephfs <name> get-owner":
+		req.Args = []string{CLI.Cephfs.Name.Name}
+	case "cephs3 <name> get-owner":
+		req.Args = []string{CLI.Cephs3.Name.Name}
+	default:
+		return req, false
+	}
+	return req, true
+}
+
+// runThroughAgent answers command through a running agent, if there is one
+// and it can. It reports false when the command should run directly.
+func runThroughAgent(cfg *config.Config, command string) bool {
+	// The agent's connection may be to another DC than the one asked for
+	if CLI.PreferDC != "" {
+		return false
+	}
+	req, ok := agentRequest(command)
+	if !ok {
+		return false
+	}
+	path := agent.SocketPath(cfg)
+	if _, err := os.Stat(path); err != nil {
+		return false
+	}
+	req.Config = cfg.Fingerprint()
+	resp, err := agent.Call(path, req)
+	if err != nil {
+		slog.Debug("Agent unavailable, connecting directly", "error", err)
+		return false
+	}
+	if resp.NotFound != "" {
+		notFound("%s", resp.NotFound)
+		return true
+	}
+	for _, line := range resp.Lines {
+		fmt.Fprintln(stdout, line)
+	}
+	return true
+}
+
+// stopAgent asks the running agent to exit.
+func stopAgent(cfg *config.Config) {
+	if _, err := agent.Call(agent.SocketPath(cfg), agent.Request{Op: agent.OpStop}); err != nil {
+		fail("Error stopping agent", err)
+	}
+	fmt.Fprintln(stdout, "Agent stopped.")
+}
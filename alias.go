@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/kong"
+)
+
+// aliasArg matches a positional placeholder such as {1} in an alias.
+var aliasArg = regexp.MustCompile(`\{(\d+)\}`)
+
+// commandWordIndex returns the index in args of the first word that isn't
+// a global flag or a flag's value, or -1 if there is none.
+func commandWordIndex(app *kong.Application, args []string) int {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return -1
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			return i
+		}
+		if strings.Contains(arg, "=") {
+			continue
+		}
+		for _, flag := range app.Flags {
+			long := arg == "--"+flag.Name || slices.ContainsFunc(flag.Aliases, func(a string) bool { return arg == "--"+a })
+			short := flag.Short != 0 && arg == "-"+string(flag.Short)
+			if (long || short) && !flag.IsBool() && !flag.IsCounter() {
+				// The next argument is the flag's value
+				i++
+				break
+			}
+		}
+	}
+	return -1
+}
+
+// isCommand reports whether word names a top-level command or one of its
+// aliases, which a site alias of the same name must never hide.
+func isCommand(app *kong.Application, word string) bool {
+	for _, node := range app.Children {
+		if node.Name == word || slices.Contains(node.Aliases, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitAlias splits an alias expansion into words like a shell would:
+// whitespace separates words, single quotes keep everything literally,
+// and inside double quotes or outside quotes a backslash escapes the next
+// character.
+func splitAlias(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\\':
+			escaped, inWord = true, true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if escaped {
+		return nil, fmt.Errorf("trailing backslash")
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// substituteAlias expands the alias name, defined as expansion, with the
+// arguments that followed it. Each {N} is replaced by the Nth argument
+// after the expansion is split into words, so an argument with spaces or
+// quotes stays one word. Arguments no placeholder uses are appended.
+func substituteAlias(name string, expansion string, rest []string) ([]string, error) {
+	words, err := splitAlias(expansion)
+	if err != nil {
+		return nil, fmt.Errorf("alias %s: %w", name, err)
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("alias %s is empty", name)
+	}
+	used := make([]bool, len(rest))
+	for i, word := range words {
+		var missing int
+		words[i] = aliasArg.ReplaceAllStringFunc(word, func(m string) string {
+			n, _ := strconv.Atoi(m[1 : len(m)-1])
+			if n < 1 || n > len(rest) {
+				missing = max(missing, n)
+				return m
+			}
+			used[n-1] = true
+			return rest[n-1]
+		})
+		if missing > 0 {
+			return nil, fmt.Errorf("alias %s needs at least %d arguments, got %d", name, missing, len(rest))
+		}
+	}
+	for i, arg := range rest {
+		if !used[i] {
+			words = append(words, arg)
+		}
+	}
+	return words, nil
+}
+
+// expandAliases rewrites args when their command word is one of the
+// aliases from configuration, keeping any global flags before it. An
+// expansion may start with another alias, which is expanded in turn; one
+// that leads back to itself is an error. A built-in command always wins
+// over an alias of the same name, which is only warned about.
+func expandAliases(app *kong.Application, args []string, aliases map[string]string) ([]string, error) {
+	var chain []string
+	for {
+		i := commandWordIndex(app, args)
+		if i < 0 {
+			return args, nil
+		}
+		name := args[i]
+		expansion, ok := aliases[name]
+		if !ok {
+			return args, nil
+		}
+		if isCommand(app, name) {
+			if len(chain) == 0 {
+				slog.Warn("Ignoring alias with the name of a built-in command", "alias", name)
+			}
+			return args, nil
+		}
+		if slices.Contains(chain, name) {
+			return nil, fmt.Errorf("alias %s expands to itself: %s", name, strings.Join(append(chain, name), " -> "))
+		}
+		chain = append(chain, name)
+		expanded, err := substituteAlias(name, expansion, args[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		args = slices.Concat(args[:i], expanded)
+		slog.Debug("Expanded alias", "alias", name, "args", args)
+	}
+}
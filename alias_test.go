@@ -0,0 +1,191 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/kong"
+)
+
+func TestSplitAlias(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    []string
+		wantErr string
+	}{
+		{in: "pirg {1} add-member {2}", want: []string{"pirg", "{1}", "add-member", "{2}"}},
+		{in: "  pirg\t list \n", want: []string{"pirg", "list"}},
+		{in: `--reason "onboarding {2}"`, want: []string{"--reason", "onboarding {2}"}},
+		{in: `--reason 'it''s "quoted"'`, want: []string{"--reason", `its "quoted"`}},
+		{in: `a\ b c`, want: []string{"a b", "c"}},
+		{in: `"say \"hi\""`, want: []string{`say "hi"`}},
+		{in: `'no \escape'`, want: []string{`no \escape`}},
+		{in: `x "" y`, want: []string{"x", "", "y"}},
+		{in: "", want: nil},
+		{in: `pirg \`, wantErr: "trailing backslash"},
+		{in: `pirg "bio`, wantErr: `unterminated " quote`},
+		{in: `pirg 'bio`, wantErr: "unterminated ' quote"},
+	}
+	for _, tc := range cases {
+		got, err := splitAlias(tc.in)
+		if tc.wantErr != "" {
+			if err == nil || err.Error() != tc.wantErr {
+				t.Errorf("splitAlias(%q) error = %v, want %q", tc.in, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("splitAlias(%q): %v", tc.in, err)
+			continue
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("splitAlias(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestSubstituteAlias(t *testing.T) {
+	cases := []struct {
+		name      string
+		expansion string
+		rest      []string
+		want      []string
+		wantErr   string
+	}{
+		{
+			name:      "placeholders",
+			expansion: `pirg {1} add-member {2} --reason "onboarding {2}"`,
+			rest:      []string{"bio", "alice"},
+			want:      []string{"pirg", "bio", "add-member", "alice", "--reason", "onboarding alice"},
+		},
+		{
+			name:      "unused arguments are appended",
+			expansion: "pirg {1} list-members",
+			rest:      []string{"bio", "-o", "json"},
+			want:      []string{"pirg", "bio", "list-members", "-o", "json"},
+		},
+		{
+			name:      "no placeholders",
+			expansion: "pirg list",
+			rest:      []string{"--long"},
+			want:      []string{"pirg", "list", "--long"},
+		},
+		{
+			name:      "an argument with spaces and quotes stays one word",
+			expansion: "pirg bio add-member alice --reason {1}",
+			rest:      []string{`RT#1 "urgent"`},
+			want:      []string{"pirg", "bio", "add-member", "alice", "--reason", `RT#1 "urgent"`},
+		},
+		{
+			name:      "an argument used twice",
+			expansion: "{1} {1}",
+			rest:      []string{"x"},
+			want:      []string{"x", "x"},
+		},
+		{
+			name:      "missing argument",
+			expansion: "pirg {1} add-member {3}",
+			rest:      []string{"bio"},
+			wantErr:   "alias onboard needs at least 3 arguments, got 1",
+		},
+		{
+			name:      "empty",
+			expansion: "  ",
+			wantErr:   "alias onboard is empty",
+		},
+		{
+			name:      "bad quoting",
+			expansion: `pirg "bio`,
+			wantErr:   `alias onboard: unterminated " quote`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := substituteAlias("onboard", tc.expansion, tc.rest)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Errorf("error = %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("substituteAlias() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExpandAliases(t *testing.T) {
+	cli := CLI
+	app := kong.Must(&cli, kong.Name("directory-manager")).Model
+	aliases := map[string]string{
+		"onboard": "pirg {1} add-member {2}",
+		"welcome": "onboard {1} {2} --reason welcome",
+		"ping":    "pong",
+		"pong":    "ping",
+		"pirg":    "cephfs",
+	}
+	cases := []struct {
+		name    string
+		args    []string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "not an alias",
+			args: []string{"pirg", "bio", "list-members"},
+			want: []string{"pirg", "bio", "list-members"},
+		},
+		{
+			name: "global flags before the alias are kept",
+			args: []string{"-o", "json", "--reason", "x", "onboard", "bio", "alice"},
+			want: []string{"-o", "json", "--reason", "x", "pirg", "bio", "add-member", "alice"},
+		},
+		{
+			name: "an alias of an alias",
+			args: []string{"welcome", "bio", "alice"},
+			want: []string{"pirg", "bio", "add-member", "alice", "--reason", "welcome"},
+		},
+		{
+			name:    "a loop",
+			args:    []string{"ping"},
+			wantErr: "alias ping expands to itself: ping -> pong -> ping",
+		},
+		{
+			name: "a built-in command shadows an alias",
+			args: []string{"pirg", "list"},
+			want: []string{"pirg", "list"},
+		},
+		{
+			name: "a built-in command reached through an alias isn't expanded again",
+			args: []string{"onboard", "bio", "alice"},
+			want: []string{"pirg", "bio", "add-member", "alice"},
+		},
+		{
+			name: "no command word",
+			args: []string{"--version"},
+			want: []string{"--version"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := expandAliases(app, tc.args, aliases)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("error = %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("expandAliases(%q) = %q, want %q", tc.args, got, tc.want)
+			}
+		})
+	}
+}
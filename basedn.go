@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/uoracs/directory-manager/internal/config"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+)
+
+// listBaseDN returns the namespace and --base-dn of a list command, or
+// empty strings for any other command.
+func listBaseDN(command string) (string, string) {
+	switch command {
+	case "pirg list":
+		return "pirg", CLI.Pirg.List.BaseDN
+	case "cephfs list":
+		return "cephfs", CLI.Cephfs.List.BaseDN
+	case "cephs3 list":
+		return "cephs3", CLI.Cephs3.List.BaseDN
+	case "software list":
+		return "software", CLI.Software.List.BaseDN
+	}
+	return "", ""
+}
+
+// overrideBaseDN points the namespace of a list command at its --base-dn
+// for this run, after checking that the DN is an OU. The config file is
+// left alone.
+func overrideBaseDN(ctx context.Context, cfg *config.Config, command string) {
+	namespace, dn := listBaseDN(command)
+	if dn == "" {
+		return
+	}
+	if err := ld.CheckOU(ctx, dn); err != nil {
+		fail(fmt.Sprintf("Error checking --base-dn %s", dn), err)
+	}
+	slog.Debug("Overriding base DN", "namespace", namespace, "field", config.BaseDNField(namespace), "dn", dn)
+	cfg.SetBaseDN(namespace, dn)
+}
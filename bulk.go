@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/uoracs/directory-manager/internal/bulk"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+)
+
+// bulkResult is one member's outcome as printed in json mode.
+type bulkResult struct {
+	Member string `json:"member"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// bulkVerbs holds the forms of each verb reportParallel and the member
+// counts print.
+var bulkVerbs = map[string][3]string{
+	"add":    {"adding", "Added", "added"},
+	"remove": {"removing", "Removed", "removed"},
+}
+
+// parallelReport is what reportParallel prints in json mode.
+type parallelReport struct {
+	Results []bulkResult `json:"results"`
+	*memberCountSummary
+}
+
+// parallel reports whether membership changes should be spread over
+// several connections.
+func parallel() bool {
+	return CLI.Parallel > 1
+}
+
+// runParallel makes a membership change for each of usernames or dns,
+// whichever was given, over --parallel connections.
+func runParallel(ctx context.Context, usernames []string, dns []string, byUsername func(context.Context, string) error, byDN func(context.Context, string) error) []bulk.Result {
+	members, change := usernames, byUsername
+	if len(dns) > 0 {
+		members, change = dns, byDN
+	}
+	results, err := bulk.Run(ctx, members, CLI.Parallel, change)
+	if err != nil {
+		fail("Error opening LDAP connections", err)
+	}
+	return results
+}
+
+// reportParallel prints what happened to each member, in the order they
+// were given, a summary, and the member counts if they were taken, then
+// exits 1 unless every change was made or wasn't needed.
+// verb is "add" or "remove".
+func reportParallel(verb string, results []bulk.Result) {
+	rows := make([]bulkResult, 0, len(results))
+	changed, unchanged, inactive, failed := 0, 0, 0, 0
+	for _, r := range results {
+		row := bulkResult{Member: r.Member, Status: "ok"}
+		switch {
+		case r.Err == nil:
+			changed++
+		case errors.Is(r.Err, ld.ErrNotMember):
+			unchanged++
+			row.Status = "not_member"
+		case errors.Is(r.Err, ld.ErrAccountInactive):
+			inactive++
+			row.Status, row.Error = "inactive", r.Err.Error()
+		default:
+			failed++
+			row.Status, row.Error = "failed", r.Err.Error()
+		}
+		rows = append(rows, row)
+	}
+	counts := counted.summary(failed + inactive)
+	if jsonOutput() {
+		printJSON(parallelReport{Results: rows, memberCountSummary: counts})
+	} else {
+		for _, row := range rows {
+			switch row.Status {
+			case "not_member":
+				fmt.Fprintf(stdout, "%s was not a member (no change)\n", row.Member)
+			case "inactive":
+				fmt.Fprintf(stdout, "Skipped %s: %s\n", row.Member, row.Error)
+			case "failed":
+				fmt.Fprintf(stdout, "Error %s member %s: %s\n", bulkVerbs[verb][0], row.Member, row.Error)
+			}
+		}
+		fmt.Fprintf(stdout, "%s %d of %d members, %d failed, %d inactive skipped", bulkVerbs[verb][1], changed, len(results), failed, inactive)
+		if unchanged > 0 {
+			fmt.Fprintf(stdout, ", %d not members", unchanged)
+		}
+		fmt.Fprintln(stdout, ".")
+		if counts != nil {
+			fmt.Fprintln(stdout, counts)
+		}
+	}
+	if changed+unchanged < len(results) {
+		exit(1)
+	}
+}
+
+// notMember reports a member that remove-member found already out of
+// group, and returns whether err was that. Removing a non-member succeeds
+// without changing anything, so the caller carries on.
+func notMember(member string, group string, err error) bool {
+	if !errors.Is(err, ld.ErrNotMember) {
+		return false
+	}
+	if !jsonOutput() {
+		fmt.Fprintf(stdout, "%s was not a member of %s (no change)\n", member, group)
+	}
+	return true
+}
+
+// usernameArgs returns the usernames an add-member or add-admin was given,
+// or nil for other commands. Removals aren't checked up front: a user
+// deleted from AD is still removed by CN, which the batched lookup can't
+// know about.
+func usernameArgs(command string) *[]string {
+	switch command {
+	case "pirg <name> add-member", "pirg <name> add-member <username>":
+		return &CLI.Pirg.Name.AddMember.Usernames
+	case "pirg <name> add-admin <username>":
+		return &CLI.Pirg.Name.AddAdmin.Usernames
+	case "pirg <name> subgroup <name> add-member <username>":
+		return &CLI.Pirg.Name.Subgroup.Name.AddMember.Usernames
+	case "cephfs <name> add-member", "cephfs <name> add-member <username>":
+		return &CLI.Cephfs.Name.AddMember.Usernames
+	case "cephfs <name> add-admin <username>":
+		return &CLI.Cephfs.Name.AddAdmin.Usernames
+	case "cephs3 <name> add-member", "cephs3 <name> add-member <username>":
+		return &CLI.Cephs3.Name.AddMember.Usernames
+	case "cephs3 <name> add-admin <username>":
+		return &CLI.Cephs3.Name.AddAdmin.Usernames
+	case "software <name> add-member", "software <name> add-member <username>":
+		return &CLI.Software.Name.AddMember.Usernames
+	case "software <name> add-admin <username>":
+		return &CLI.Software.Name.AddAdmin.Usernames
+	}
+	return nil
+}
+
+// knownUsernames looks up several usernames in one batched search before
+// anything is changed. Unless --skip-unknown is set, it exits naming every
+// username that wasn't found, so a typo changes nothing instead of stopping
+// partway through. A single username is left to the command's own lookup.
+func knownUsernames(ctx context.Context, usernames []string) []string {
+	if len(usernames) < 2 {
+		return usernames
+	}
+	dns, err := ld.ResolveUsernames(ctx, usernames)
+	if err != nil {
+		fail("Error looking up usernames", err)
+	}
+	var known, unknown []string
+	for _, username := range usernames {
+		if _, ok := dns[strings.ToLower(username)]; ok {
+			known = append(known, username)
+		} else {
+			unknown = append(unknown, username)
+		}
+	}
+	if len(unknown) == 0 {
+		return usernames
+	}
+	if !CLI.SkipUnknown {
+		fail("Nothing was changed", fmt.Errorf("usernames %s %w; pass --skip-unknown to change the others", strings.Join(unknown, ", "), ld.ErrNotFound))
+	}
+	fmt.Fprintf(stderr, "Warning: skipping unknown usernames %s\n", strings.Join(unknown, ", "))
+	return known
+}
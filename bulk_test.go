@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+func TestKnownUsernames(t *testing.T) {
+	t.Run("unknown fails", func(t *testing.T) {
+		env := newTestEnv(t, "")
+		code, stdout, _ := env.run("pirg", "alpha", "add-member", "carol", "nosuch")
+		if code == 0 {
+			t.Fatalf("exit 0 with an unknown username:\n%s", stdout)
+		}
+		if !strings.Contains(stdout, "nosuch") || !strings.Contains(stdout, "--skip-unknown") {
+			t.Errorf("error doesn't name the unknown username and --skip-unknown:\n%s", stdout)
+		}
+		if env.hasMember(alphaDN, carolDN) {
+			t.Error("carol added although the command changed nothing")
+		}
+	})
+
+	t.Run("skip unknown", func(t *testing.T) {
+		env := newTestEnv(t, "")
+		code, stdout, stderr := env.run("--skip-unknown", "pirg", "alpha", "add-member", "carol", "nosuch")
+		if code != 0 {
+			t.Fatalf("exit %d, stdout:\n%s\nstderr:\n%s", code, stdout, stderr)
+		}
+		if !strings.Contains(stderr, "skipping unknown usernames nosuch") {
+			t.Errorf("no warning about nosuch:\n%s", stderr)
+		}
+		if !env.hasMember(alphaDN, carolDN) {
+			t.Error("carol not added")
+		}
+	})
+
+	t.Run("single name", func(t *testing.T) {
+		env := newTestEnv(t, "")
+		code, stdout, _ := env.run("pirg", "alpha", "add-member", "nosuch")
+		if code == 0 {
+			t.Fatalf("exit 0 with an unknown username:\n%s", stdout)
+		}
+		if strings.Contains(stdout, "--skip-unknown") {
+			t.Errorf("a single username went through the batched check:\n%s", stdout)
+		}
+	})
+}
+
+// TestRemoveDeletedAccounts checks that removing several members still
+// removes one whose account was deleted from AD, by CN.
+func TestRemoveDeletedAccounts(t *testing.T) {
+	env := newTestEnv(t, "")
+	const ghostDN = "CN=ghost,OU=People,DC=ad,DC=uoregon,DC=edu"
+	req := ldap.NewModifyRequest(alphaDN, nil)
+	req.Add("member", []string{ghostDN})
+	if err := env.dir.Modify(req); err != nil {
+		t.Fatal(err)
+	}
+	if code, stdout, stderr := env.run("pirg", "alpha", "remove-member", "ghost", "bob"); code != 0 {
+		t.Fatalf("exit %d, stdout:\n%s\nstderr:\n%s", code, stdout, stderr)
+	}
+	if env.hasMember(alphaDN, ghostDN) {
+		t.Error("member with a deleted account not removed")
+	}
+	if env.hasMember(alphaDN, bobDN) {
+		t.Error("bob not removed")
+	}
+}
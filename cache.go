@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/uoracs/directory-manager/internal/config"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/opcache"
+)
+
+// opCache is this run's cache of recent membership changes. A nil *opCache
+// is a disabled cache, so callers don't need to check --no-cache.
+type opCache struct {
+	store *opcache.Store
+	ttl   time.Duration
+	// usersGroupDN is the top-level users group an add also puts the member in
+	usersGroupDN string
+}
+
+// openOpCache opens the operation cache under data_path. An unreadable cache
+// is ignored rather than failing the command.
+func openOpCache(cfg *config.Config) *opCache {
+	if CLI.NoCache {
+		return nil
+	}
+	store, err := opcache.Open(opcache.Path(cfg.DataPath))
+	if err != nil {
+		slog.Warn("Ignoring unreadable operation cache", "error", err)
+		return nil
+	}
+	return &opCache{
+		store:        store,
+		ttl:          time.Duration(cfg.OpCacheTTLMinutes) * time.Minute,
+		usersGroupDN: cfg.UsersGroupDN,
+	}
+}
+
+// uncached returns the usernames whose change to group was not made within
+// the TTL, or no longer holds, and prints the others as already done.
+func (c *opCache) uncached(ctx context.Context, group string, direction opcache.Direction, usernames []string) []string {
+	if c == nil {
+		return usernames
+	}
+	var rest []string
+	for _, username := range usernames {
+		op := opcache.Op{Role: "member", Group: group, Member: username, Direction: direction}
+		if !c.holds(ctx, op) {
+			rest = append(rest, username)
+			continue
+		}
+		if direction == opcache.Add {
+			fmt.Fprintf(stdout, "(cached) %s is already a member\n", username)
+		} else {
+			fmt.Fprintf(stdout, "(cached) %s is not a member\n", username)
+		}
+	}
+	return rest
+}
+
+// holds reports whether op was recorded within the TTL and its effect is
+// still in place, checked with a membership lookup. Adding a member also
+// puts them in the top-level users group, so an add only holds while they
+// are in both.
+func (c *opCache) holds(ctx context.Context, op opcache.Op) bool {
+	e, ok := c.store.Lookup(op, c.ttl, time.Now())
+	if !ok {
+		return false
+	}
+	groupDNs := []string{e.GroupDN}
+	if op.Direction == opcache.Add {
+		groupDNs = append(groupDNs, c.usersGroupDN)
+	}
+	for _, groupDN := range groupDNs {
+		in, err := ld.UserInGroup(ctx, groupDN, e.UserDN)
+		if err != nil {
+			slog.Debug("Failed to verify cached operation", "group", op.Group, "member", op.Member, "error", err)
+			return false
+		}
+		if in != (op.Direction == opcache.Add) {
+			slog.Debug("Cached operation no longer holds", "group", op.Group, "member", op.Member, "direction", op.Direction, "groupDN", groupDN)
+			c.store.Forget(op)
+			return false
+		}
+	}
+	return true
+}
+
+// record notes that the change of username in group succeeded and saves
+// the cache straight away, since a later failure exits without returning.
+func (c *opCache) record(ctx context.Context, group string, groupDN string, direction opcache.Direction, username string) {
+	if c == nil {
+		return
+	}
+	userDN, err := ld.GetUserDN(ctx, username)
+	if err != nil {
+		slog.Debug("Not caching operation", "group", group, "member", username, "error", err)
+		return
+	}
+	op := opcache.Op{Role: "member", Group: group, Member: username, Direction: direction}
+	c.store.Record(op, groupDN, userDN, time.Now())
+	c.save()
+}
+
+// invalidate drops everything recorded for group.
+func (c *opCache) invalidate(group string) {
+	if c == nil {
+		return
+	}
+	c.store.InvalidateGroup(group)
+	c.save()
+}
+
+func (c *opCache) save() {
+	if err := c.store.Save(c.ttl, time.Now()); err != nil {
+		slog.Warn("Failed to save operation cache", "error", err)
+	}
+}
+
+// invalidatedPirg returns the PIRG whose cached membership changes command
+// could undo, or "" if none. add-member and remove-member given usernames
+// keep the cache up to date themselves.
+func invalidatedPirg(command string) string {
+	switch command {
+	case "pirg <name> delete", "pirg <name> set-pi", "pirg <name> fix-pi", "pirg <name> add-missing",
+		"pirg <name> add-admin <username>", "pirg <name> remove-admin <username>":
+		return CLI.Pirg.Name.Name
+	case "pirg <name> check":
+		if CLI.Pirg.Name.Check.Fix {
+			return CLI.Pirg.Name.Name
+		}
+	case "pirg <name> add-member", "pirg <name> add-member <username>":
+		if len(CLI.Pirg.Name.AddMember.DNs) > 0 {
+			return CLI.Pirg.Name.Name
+		}
+	case "pirg <name> remove-member", "pirg <name> remove-member <username>":
+		opts := CLI.Pirg.Name.RemoveMember
+		if opts.FromSubgroup != "" || opts.MembersOf != "" || opts.AllRegular || len(opts.DNs) > 0 {
+			return CLI.Pirg.Name.Name
+		}
+	case "transfer-ownership":
+		return CLI.TransferOwnership.Pirg
+	}
+	return ""
+}
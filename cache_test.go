@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestCachedAddRechecksTopLevelUsers checks that a repeated add-member is
+// only answered from the cache while the member is still in the top-level
+// users group as well as the PIRG.
+func TestCachedAddRechecksTopLevelUsers(t *testing.T) {
+	env := newTestEnv(t, "")
+	add := []string{"pirg", "alpha", "add-member", "carol"}
+	if code, stdout, stderr := env.run(add...); code != 0 {
+		t.Fatalf("exit %d, stdout:\n%s\nstderr:\n%s", code, stdout, stderr)
+	}
+	if _, stdout, _ := env.run(add...); !strings.Contains(stdout, "(cached)") {
+		t.Fatalf("repeated add not answered from the cache:\n%s", stdout)
+	}
+
+	env.removeMemberDirectly(t, talapasUsersDN, carolDN)
+	code, stdout, stderr := env.run(add...)
+	if code != 0 {
+		t.Fatalf("exit %d, stdout:\n%s\nstderr:\n%s", code, stdout, stderr)
+	}
+	if strings.Contains(stdout, "(cached)") {
+		t.Errorf("add answered from the cache after the member left the top-level users group:\n%s", stdout)
+	}
+	if !env.hasMember(talapasUsersDN, carolDN) {
+		t.Error("member not put back in the top-level users group")
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	labDN       = "CN=is.racs.cephfs.lab,OU=lab,OU=CEPHFS,OU=RACS,DC=ad,DC=uoregon,DC=edu"
+	labOwnerDN  = "CN=is.racs.cephfs.lab.owner,OU=lab,OU=CEPHFS,OU=RACS,DC=ad,DC=uoregon,DC=edu"
+	labAdminsDN = "CN=is.racs.cephfs.lab.admins,OU=lab,OU=CEPHFS,OU=RACS,DC=ad,DC=uoregon,DC=edu"
+	bobDN       = "CN=bob,OU=People,DC=ad,DC=uoregon,DC=edu"
+)
+
+// newCephfsEnv creates cephfs group lab owned by carol.
+func newCephfsEnv(t *testing.T) *testEnv {
+	t.Helper()
+	env := newTestEnv(t, "")
+	if code, stdout, stderr := env.run("cephfs", "lab", "create", "--owner", "carol"); code != 0 {
+		t.Fatalf("create failed:\n%s%s", stdout, stderr)
+	}
+	return env
+}
+
+func TestCephfsTransferOwner(t *testing.T) {
+	env := newCephfsEnv(t)
+	code, stdout, stderr := env.run("cephfs", "lab", "transfer-owner", "bob", "--demote-old")
+	if code != 0 {
+		t.Fatalf("exit %d:\n%s%s", code, stdout, stderr)
+	}
+	if !strings.Contains(stdout, "Transferred cephfs group lab from carol to bob.") {
+		t.Errorf("unexpected output:\n%s", stdout)
+	}
+	if !env.hasMember(labOwnerDN, bobDN) || env.hasMember(labOwnerDN, carolDN) {
+		t.Errorf("owner group = %v, want only bob", env.dir.Values(labOwnerDN, "member"))
+	}
+	if env.hasMember(labAdminsDN, carolDN) || !env.hasMember(labDN, carolDN) {
+		t.Error("carol wasn't demoted to an ordinary member")
+	}
+}
+
+func TestCephfsTransferOwnerWithoutOwner(t *testing.T) {
+	env := newCephfsEnv(t)
+	// The owner group was emptied by hand
+	env.removeMemberDirectly(t, labOwnerDN, carolDN)
+
+	code, stdout, stderr := env.run("--output", "json", "cephfs", "lab", "transfer-owner", "bob", "--demote-old")
+	if code != 0 {
+		t.Fatalf("exit %d:\n%s%s", code, stdout, stderr)
+	}
+	var result struct {
+		PreviousOwner string `json:"previous_owner"`
+		Owner         string `json:"owner"`
+		Demoted       bool   `json:"demoted"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("output isn't JSON: %v\n%s", err, stdout)
+	}
+	if result.PreviousOwner != "" || result.Owner != "bob" || result.Demoted {
+		t.Errorf("result = %+v", result)
+	}
+	for _, dn := range []string{labDN, labOwnerDN, labAdminsDN} {
+		if !env.hasMember(dn, bobDN) {
+			t.Errorf("bob not added to %s", dn)
+		}
+	}
+	// carol stays an admin: only a previous owner is demoted
+	if !env.hasMember(labAdminsDN, carolDN) {
+		t.Error("carol was removed from the admins group")
+	}
+
+	code, stdout, _ = env.run("cephfs", "lab", "get-owner")
+	if code != 0 || strings.TrimSpace(stdout) != "bob" {
+		t.Errorf("get-owner exit %d, printed %q", code, stdout)
+	}
+}
+
+func TestCephfsTransferOwnerWithoutOwnerText(t *testing.T) {
+	env := newCephfsEnv(t)
+	env.removeMemberDirectly(t, labOwnerDN, carolDN)
+	code, stdout, stderr := env.run("cephfs", "lab", "transfer-owner", "bob")
+	if code != 0 || stdout != "cephfs group lab had no Owner; bob is now its Owner.\n" {
+		t.Errorf("exit %d, printed %q\n%s", code, stdout, stderr)
+	}
+}
+
+// TestCephfsRename checks that renaming a cephfs group renames its OU, its
+// main, admins, and Owner groups, and the subgroups whose CNs embed its
+// name, keeping their members.
+func TestCephfsRename(t *testing.T) {
+	for _, family := range []string{"cephfs", "cephs3"} {
+		t.Run(family, func(t *testing.T) {
+			env := newTestEnv(t, "")
+			base := "OU=" + strings.ToUpper(family) + ",OU=RACS,DC=ad,DC=uoregon,DC=edu"
+			prefix := "is.racs." + family + "."
+			env.mustRun(t, family, "lab", "create", "--owner", "carol")
+			env.mustRun(t, family, "lab", "add-member", "bob")
+			// There is no command for cephfs subgroups, so add one by hand.
+			oldSubDN := "CN=" + prefix + "lab.data,OU=Groups,OU=lab," + base
+			sub := ldap.NewAddRequest(oldSubDN, nil)
+			sub.Attribute("objectClass", []string{"group"})
+			sub.Attribute("cn", []string{prefix + "lab.data"})
+			sub.Attribute("sAMAccountName", []string{prefix + "lab.data"})
+			sub.Attribute("member", []string{bobDN})
+			if err := env.dir.Add(sub); err != nil {
+				t.Fatal(err)
+			}
+
+			env.mustRun(t, family, "lab", "rename", "bench")
+
+			if env.dir.Exists("OU=lab," + base) {
+				t.Error("old OU left behind")
+			}
+			ouDN := "OU=bench," + base
+			for _, tc := range []struct{ name, memberDN string }{
+				{prefix + "bench", bobDN},
+				{prefix + "bench.admins", carolDN},
+				{prefix + "bench.owner", carolDN},
+			} {
+				dn := "CN=" + tc.name + "," + ouDN
+				if got := env.dir.Values(dn, "sAMAccountName"); len(got) != 1 || got[0] != tc.name {
+					t.Errorf("%s sAMAccountName = %v", dn, got)
+				}
+				if !env.hasMember(dn, tc.memberDN) {
+					t.Errorf("%s lost member %s", dn, tc.memberDN)
+				}
+			}
+			subDN := "CN=" + prefix + "bench.data,OU=Groups," + ouDN
+			if got := env.dir.Values(subDN, "sAMAccountName"); len(got) != 1 || got[0] != prefix+"bench.data" {
+				t.Errorf("subgroup sAMAccountName = %v", got)
+			}
+			if !env.hasMember(subDN, bobDN) {
+				t.Error("subgroup lost its member")
+			}
+			if got := env.mustRun(t, family, "list"); got != "bench\n" {
+				t.Errorf("list = %q, want bench", got)
+			}
+			if got := env.mustRun(t, family, "bench", "get-owner"); strings.TrimSpace(got) != "carol" {
+				t.Errorf("get-owner = %q, want carol", got)
+			}
+		})
+	}
+}
+
+// TestCephfsRenameRefused checks that rename leaves the group alone when
+// the new name is taken or malformed.
+func TestCephfsRenameRefused(t *testing.T) {
+	cases := []struct {
+		name    string
+		newName string
+		want    string
+	}{
+		{name: "taken", newName: "other", want: "cephfs group other already exists."},
+		{name: "malformed", newName: "bad!", want: "invalid CEPHFS name: bad!"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := newCephfsEnv(t)
+			env.mustRun(t, "cephfs", "other", "create", "--owner", "bob")
+			code, stdout, _ := env.run("cephfs", "lab", "rename", tc.newName)
+			if code == 0 || !strings.Contains(stdout, tc.want) {
+				t.Errorf("exit %d, want output containing %q:\n%s", code, tc.want, stdout)
+			}
+			if !env.dir.Exists(labDN) || !env.hasMember(labOwnerDN, carolDN) {
+				t.Error("refused rename changed the group")
+			}
+		})
+	}
+}
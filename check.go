@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uoracs/directory-manager/internal/consistency"
+)
+
+type checkResult struct {
+	Problem string `json:"problem"`
+	Fixable bool   `json:"fixable"`
+	Fixed   bool   `json:"fixed"`
+	Error   string `json:"error,omitempty"`
+}
+
+// reportProblems prints the problems found by a check, repairing the fixable
+// ones first when fix is set. It exits 1 if any problem remains, so checks
+// can be run from cron or monitoring.
+func reportProblems(ctx context.Context, problems []consistency.Problem, fix bool) {
+	results := make([]checkResult, 0, len(problems))
+	remaining := 0
+	for _, p := range problems {
+		r := checkResult{Problem: p.Description, Fixable: p.Fixable()}
+		if fix && p.Fixable() {
+			if err := p.Fix(ctx); err != nil {
+				r.Error = err.Error()
+			} else {
+				r.Fixed = true
+			}
+		}
+		if !r.Fixed {
+			remaining++
+		}
+		results = append(results, r)
+	}
+
+	if jsonOutput() {
+		printJSON(results)
+	} else if tableOutput() && len(results) > 0 {
+		rows := make([][]string, 0, len(results))
+		for _, r := range results {
+			status := "open"
+			switch {
+			case r.Fixed:
+				status = "fixed"
+			case r.Error != "":
+				status = "fix failed: " + r.Error
+			case r.Fixable:
+				status = "fixable"
+			}
+			rows = append(rows, []string{status, r.Problem})
+		}
+		printTable([]string{"status", "problem"}, rows)
+	} else if len(results) == 0 {
+		fmt.Fprintln(stdout, "No problems found.")
+	} else {
+		for _, r := range results {
+			switch {
+			case r.Fixed:
+				fmt.Fprintf(stdout, "Fixed: %s\n", r.Problem)
+			case r.Error != "":
+				fmt.Fprintf(stdout, "Failed to fix: %s: %s\n", r.Problem, r.Error)
+			case r.Fixable:
+				fmt.Fprintf(stdout, "%s (fixable with --fix)\n", r.Problem)
+			default:
+				fmt.Fprintln(stdout, r.Problem)
+			}
+		}
+	}
+	if remaining > 0 {
+		exit(1)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/uoracs/directory-manager/internal/managedgroup"
+)
+
+const (
+	aliceDN        = "CN=alice,OU=People,DC=ad,DC=uoregon,DC=edu"
+	carolDN        = "CN=carol,OU=People,DC=ad,DC=uoregon,DC=edu"
+	talapasUsersDN = "CN=IS.RACS.Talapas.Users,OU=RACS,OU=Groups,OU=IS,OU=Units,DC=ad,DC=uoregon,DC=edu"
+)
+
+// removeMemberDirectly takes memberDN out of the group at groupDN behind
+// the tool's back, leaving an inconsistency for check to find.
+func (e *testEnv) removeMemberDirectly(t *testing.T, groupDN string, memberDN string) {
+	t.Helper()
+	req := ldap.NewModifyRequest(groupDN, nil)
+	req.Delete("member", []string{memberDN})
+	if err := e.dir.Modify(req); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (e *testEnv) hasMember(groupDN string, memberDN string) bool {
+	return slices.ContainsFunc(e.dir.Values(groupDN, "member"), func(m string) bool { return strings.EqualFold(m, memberDN) })
+}
+
+// TestCheckFixFrozen checks that check --fix leaves a frozen group alone
+// until it is unfrozen, for each family with a freeze.
+func TestCheckFixFrozen(t *testing.T) {
+	cases := []struct {
+		family   string
+		name     string
+		create   []string
+		mainDN   string
+		holderDN string
+	}{
+		{
+			family:   "pirg",
+			name:     "alpha",
+			mainDN:   "CN=is.racs.pirg.alpha,OU=alpha,OU=PIRGS,OU=RACS,DC=ad,DC=uoregon,DC=edu",
+			holderDN: aliceDN,
+		},
+		{
+			family:   "cephfs",
+			name:     "scratch",
+			create:   []string{"cephfs", "scratch", "create", "--owner", "carol"},
+			mainDN:   "CN=" + managedgroup.Cephfs.Prefix + "scratch,OU=scratch,OU=CEPHFS,OU=RACS,DC=ad,DC=uoregon,DC=edu",
+			holderDN: carolDN,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.family, func(t *testing.T) {
+			env := newTestEnv(t, "")
+			if tc.create != nil {
+				if code, _, stderr := env.run(tc.create...); code != 0 {
+					t.Fatalf("create exited %d: %s", code, stderr)
+				}
+			}
+			env.removeMemberDirectly(t, tc.mainDN, tc.holderDN)
+			if code, _, stderr := env.run("--reason", "audit", tc.family, tc.name, "freeze"); code != 0 {
+				t.Fatalf("freeze exited %d: %s", code, stderr)
+			}
+
+			code, stdout, _ := env.run(tc.family, tc.name, "check", "--fix")
+			if code == 0 {
+				t.Errorf("check --fix of a frozen group exited 0:\n%s", stdout)
+			}
+			if !strings.Contains(stdout, "Failed to fix") || !strings.Contains(stdout, "frozen") {
+				t.Errorf("check --fix didn't report the freeze:\n%s", stdout)
+			}
+			if env.hasMember(tc.mainDN, tc.holderDN) {
+				t.Error("check --fix changed a frozen group")
+			}
+
+			if code, _, stderr := env.run(tc.family, tc.name, "unfreeze"); code != 0 {
+				t.Fatalf("unfreeze exited %d: %s", code, stderr)
+			}
+			if code, stdout, _ := env.run(tc.family, tc.name, "check", "--fix"); code != 0 {
+				t.Errorf("check --fix after unfreezing exited %d:\n%s", code, stdout)
+			}
+			if !env.hasMember(tc.mainDN, tc.holderDN) {
+				t.Error("check --fix didn't fix the group once it was unfrozen")
+			}
+		})
+	}
+}
+
+// TestFreeze checks that a frozen group refuses changes with the freeze
+// reason, allows them with --override-freeze and --reason, shows the freeze
+// in list --long and info, and takes changes again once unfrozen, for each
+// family with a freeze.
+func TestFreeze(t *testing.T) {
+	cases := []struct {
+		family string
+		name   string
+		create []string
+		mainDN string
+		adds   string
+		addDN  string
+	}{
+		{
+			family: "pirg",
+			name:   "alpha",
+			mainDN: alphaDN,
+			adds:   "carol",
+			addDN:  carolDN,
+		},
+		{
+			family: "cephfs",
+			name:   "scratch",
+			create: []string{"cephfs", "scratch", "create", "--owner", "carol"},
+			mainDN: "CN=" + managedgroup.Cephfs.Prefix + "scratch,OU=scratch,OU=CEPHFS,OU=RACS,DC=ad,DC=uoregon,DC=edu",
+			adds:   "alice",
+			addDN:  aliceDN,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.family, func(t *testing.T) {
+			env := newTestEnv(t, "")
+			if tc.create != nil {
+				env.mustRun(t, tc.create...)
+			}
+
+			code, stdout, _ := env.run(tc.family, tc.name, "freeze")
+			if code == 0 || !strings.Contains(stdout, "Freezing needs a reason") {
+				t.Errorf("freeze without --reason exited %d:\n%s", code, stdout)
+			}
+			env.mustRun(t, "--reason", "billing dispute", tc.family, tc.name, "freeze")
+
+			code, stdout, _ = env.run(tc.family, tc.name, "add-member", tc.adds)
+			if code == 0 || !strings.Contains(stdout, "billing dispute: frozen") {
+				t.Errorf("add-member to a frozen group exited %d:\n%s", code, stdout)
+			}
+			if env.hasMember(tc.mainDN, tc.addDN) {
+				t.Fatal("add-member changed a frozen group")
+			}
+
+			code, stdout, _ = env.run("--override-freeze", tc.family, tc.name, "add-member", tc.adds)
+			if code == 0 || !strings.Contains(stdout, "--override-freeze requires --reason") {
+				t.Errorf("--override-freeze without --reason exited %d:\n%s", code, stdout)
+			}
+			if env.hasMember(tc.mainDN, tc.addDN) {
+				t.Fatal("--override-freeze without --reason changed a frozen group")
+			}
+			code, stdout, stderr := env.run("--override-freeze", "--reason", "RT#9", tc.family, tc.name, "add-member", tc.adds)
+			if code != 0 {
+				t.Fatalf("add-member with --override-freeze exited %d:\n%s", code, stdout)
+			}
+			if !strings.Contains(stderr, `msg="Changing frozen group" reason=RT#9`) {
+				t.Errorf("override not logged with its reason:\n%s", stderr)
+			}
+			if !env.hasMember(tc.mainDN, tc.addDN) {
+				t.Error("add-member with --override-freeze didn't change the group")
+			}
+
+			list := env.mustRun(t, tc.family, "list", "--long")
+			if !strings.Contains(list, tc.name+" (frozen by ") || !strings.Contains(list, ": billing dispute)") {
+				t.Errorf("list --long doesn't show the freeze:\n%s", list)
+			}
+			info := env.mustRun(t, tc.family, tc.name, "info")
+			if !strings.Contains(info, "Frozen: yes, frozen by ") || !strings.Contains(info, "billing dispute") {
+				t.Errorf("info doesn't show the freeze:\n%s", info)
+			}
+
+			env.mustRun(t, tc.family, tc.name, "unfreeze")
+			list = env.mustRun(t, tc.family, "list", "--long")
+			if strings.Contains(list, "frozen") || !strings.Contains(list, tc.name+"\n") {
+				t.Errorf("list --long still shows the freeze after unfreezing:\n%s", list)
+			}
+			if info := env.mustRun(t, tc.family, tc.name, "info"); !strings.Contains(info, "Frozen: no\n") {
+				t.Errorf("info still shows the freeze after unfreezing:\n%s", info)
+			}
+			env.mustRun(t, tc.family, tc.name, "remove-member", tc.adds)
+			if env.hasMember(tc.mainDN, tc.addDN) {
+				t.Error("remove-member didn't change the unfrozen group")
+			}
+		})
+	}
+}
+
+// TestFreezeCoversPirgSubgroupsAndPI checks that a PIRG's freeze also
+// refuses changes to its subgroups, its PI, and deleting it.
+func TestFreezeCoversPirgSubgroupsAndPI(t *testing.T) {
+	env := newTestEnv(t, "")
+	env.mustRun(t, "--reason", "security incident", "pirg", "alpha", "freeze")
+	for _, args := range [][]string{
+		{"pirg", "alpha", "subgroup", "lab", "remove-member", "bob"},
+		{"pirg", "alpha", "set-pi", "--pi", "bob"},
+		{"pirg", "alpha", "delete"},
+	} {
+		code, stdout, _ := env.run(args...)
+		if code == 0 || !strings.Contains(stdout, "security incident: frozen") {
+			t.Errorf("%s on a frozen PIRG exited %d:\n%s", strings.Join(args, " "), code, stdout)
+		}
+	}
+	if !env.hasMember(alphaLabDN, bobDN) {
+		t.Error("subgroup remove-member changed a frozen PIRG")
+	}
+	if !env.dir.Exists(alphaDN) {
+		t.Error("delete removed a frozen PIRG")
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/uoracs/directory-manager/internal/cephfs"
+	"github.com/uoracs/directory-manager/internal/cephs3"
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/pirg"
+	"github.com/uoracs/directory-manager/internal/software"
+)
+
+// nameCollision is a short name used by groups in more than one namespace.
+type nameCollision struct {
+	Name       string   `json:"name"`
+	Namespaces []string `json:"namespaces"`
+}
+
+// familyLists are the functions that list each family's short names.
+var familyLists = []struct {
+	name string
+	list func(context.Context) ([]string, error)
+}{
+	{"pirg", pirg.PirgList},
+	{"cephfs", cephfs.CephfsList},
+	{"cephs3", cephs3.Cephs3List},
+	{"software", software.SoftwareList},
+}
+
+// findNameCollisions lists every enabled family and returns the short names
+// found in more than one, sorted by name.
+func findNameCollisions(ctx context.Context, cfg *config.Config) ([]nameCollision, error) {
+	namespaces := make(map[string][]string)
+	for _, f := range familyLists {
+		if cfg.DisabledSubsystem(f.name) != "" {
+			continue
+		}
+		names, err := f.list(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s groups: %w", f.name, err)
+		}
+		for _, name := range names {
+			namespaces[name] = append(namespaces[name], f.name)
+		}
+	}
+	var collisions []nameCollision
+	for name, in := range namespaces {
+		if len(in) > 1 {
+			collisions = append(collisions, nameCollision{Name: name, Namespaces: in})
+		}
+	}
+	sort.Slice(collisions, func(i, j int) bool { return collisions[i].Name < collisions[j].Name })
+	return collisions, nil
+}
+
+// printNameCollisions prints the short names used in more than one
+// namespace, and exits 1 if there are any so the check can be run from cron.
+func printNameCollisions(ctx context.Context, cfg *config.Config) {
+	collisions, err := findNameCollisions(ctx, cfg)
+	if err != nil {
+		fail("Error checking for name collisions", err)
+	}
+	if jsonOutput() {
+		if collisions == nil {
+			collisions = []nameCollision{}
+		}
+		printJSON(collisions)
+	} else if tableOutput() && len(collisions) > 0 {
+		rows := make([][]string, 0, len(collisions))
+		for _, c := range collisions {
+			rows = append(rows, []string{c.Name, strings.Join(c.Namespaces, ",")})
+		}
+		printTable([]string{"name", "namespaces"}, rows)
+	} else if len(collisions) == 0 {
+		fmt.Fprintln(stdout, "No name collisions found.")
+	} else {
+		for _, c := range collisions {
+			fmt.Fprintf(stdout, "%s: %s\n", c.Name, strings.Join(c.Namespaces, ", "))
+		}
+	}
+	if len(collisions) > 0 {
+		exit(1)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uoracs/directory-manager/internal/config"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/managedgroup"
+)
+
+// baseDNCheck is the result of checking one configured base DN.
+type baseDNCheck struct {
+	Field string `json:"field"`
+	DN    string `json:"dn"`
+	Error string `json:"error,omitempty"`
+}
+
+// validateConfig checks that the base DN of every enabled family is an OU,
+// since each group's OU is created under it, and exits 1 if any isn't.
+// The rest of the config was already validated when it was loaded, except
+// the server and credentials, which are reported first if missing.
+func validateConfig(ctx context.Context, cfg *config.Config) {
+	if err := cfg.CheckLDAP(); err != nil {
+		fail("Can't check base DNs without connecting to LDAP", err)
+	}
+	checks := make([]baseDNCheck, 0, len(managedgroup.Families))
+	failed := 0
+	for _, f := range managedgroup.Families {
+		if cfg.DisabledSubsystem(f.Name) != "" {
+			continue
+		}
+		c := baseDNCheck{Field: config.BaseDNField(f.Name), DN: cfg.BaseDN(f.Name)}
+		if err := ld.CheckOU(ctx, c.DN); err != nil {
+			c.Error = err.Error()
+			failed++
+		}
+		checks = append(checks, c)
+	}
+	if jsonOutput() {
+		printJSON(checks)
+	} else if tableOutput() {
+		rows := make([][]string, 0, len(checks))
+		for _, c := range checks {
+			status := "ok"
+			if c.Error != "" {
+				status = c.Error
+			}
+			rows = append(rows, []string{c.Field, c.DN, status})
+		}
+		printTable([]string{"field", "dn", "status"}, rows)
+	} else {
+		for _, c := range checks {
+			if c.Error != "" {
+				fmt.Fprintf(stdout, "%s is misconfigured: %s\n", c.Field, c.Error)
+				continue
+			}
+			fmt.Fprintf(stdout, "%s: ok\n", c.Field)
+		}
+	}
+	if failed > 0 {
+		exit(1)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/pirg"
+)
+
+// printContacts prints the PI and admins of the given PIRGs, or of every
+// PIRG, one row per PIRG and role, with a warning on stderr counting the
+// contacts who have no mail address.
+func printContacts(ctx context.Context, names []string) {
+	contacts, err := pirg.PirgContacts(ctx, names)
+	if errors.Is(err, ld.ErrNotFound) {
+		notFound("%s", err)
+		return
+	}
+	if err != nil {
+		fail("Error listing PIRG contacts", err)
+	}
+	missing := 0
+	for _, c := range contacts {
+		if c.Mail == "" {
+			missing++
+		}
+	}
+	if missing > 0 {
+		fmt.Fprintf(stderr, "Warning: %d of %d contacts have no mail address\n", missing, len(contacts))
+	}
+
+	if jsonOutput() {
+		printJSON(contacts)
+		return
+	}
+	headers := []string{"pirg", "role", "username", "display_name", "mail"}
+	rows := make([][]string, 0, len(contacts))
+	for _, c := range contacts {
+		rows = append(rows, []string{c.Pirg, c.Role, c.Username, c.DisplayName, c.Mail})
+	}
+	if csvOutput() {
+		w := csv.NewWriter(stdout)
+		w.Write(headers)
+		w.WriteAll(rows)
+		if err := w.Error(); err != nil {
+			fail("Error writing CSV", err)
+		}
+		return
+	}
+	if tableOutput() {
+		printTable(headers, rows)
+		return
+	}
+	if len(contacts) == 0 {
+		fmt.Fprintln(stdout, "No PIRG contacts found.")
+		return
+	}
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+}
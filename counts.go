@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/uoracs/directory-manager/internal/cephfs"
+	"github.com/uoracs/directory-manager/internal/cephs3"
+	"github.com/uoracs/directory-manager/internal/managedgroup"
+	"github.com/uoracs/directory-manager/internal/pirg"
+	"github.com/uoracs/directory-manager/internal/software"
+)
+
+// memberCount is the member count of the group an add-member or
+// remove-member changes, taken before the change so it can be confirmed by
+// counting again afterwards.
+type memberCount struct {
+	ctx    context.Context
+	group  string
+	verb   string
+	before int
+	count  func(context.Context) (int, error)
+}
+
+// memberCountSummary is a confirmed memberCount as printed in json mode.
+type memberCountSummary struct {
+	Group  string `json:"group"`
+	Before int    `json:"before"`
+	After  int    `json:"after"`
+	Failed int    `json:"failed"`
+	verb   string
+}
+
+// counted is this run's memberCount, or nil if it doesn't count members.
+// It is package level because reportParallel and reportInactive exit
+// without returning to main.
+var counted *memberCount
+
+// startMemberCount counts the members of the group command changes, if it
+// adds or removes several members, or one with --verbose. A group that
+// can't be counted is left to the command to report.
+func startMemberCount(ctx context.Context, command string) *memberCount {
+	var family, name, subgroup, verb string
+	var several bool
+	switch command {
+	case "pirg <name> add-member", "pirg <name> add-member <username>":
+		opts := CLI.Pirg.Name.AddMember
+		family, name, verb = "pirg", CLI.Pirg.Name.Name, "add"
+		several = len(opts.Usernames)+len(opts.DNs) > 1
+	case "pirg <name> remove-member", "pirg <name> remove-member <username>":
+		opts := CLI.Pirg.Name.RemoveMember
+		family, name, verb = "pirg", CLI.Pirg.Name.Name, "remove"
+		// The subgroup is what is emptied, even when its members also
+		// leave the PIRG
+		subgroup = opts.FromSubgroup
+		several = len(opts.Usernames)+len(opts.DNs) > 1 || opts.MembersOf != "" || opts.FromSubgroup != "" || opts.AllRegular
+	case "cephfs <name> add-member", "cephfs <name> add-member <username>":
+		opts := CLI.Cephfs.Name.AddMember
+		family, name, verb = "cephfs", CLI.Cephfs.Name.Name, "add"
+		several = len(opts.Usernames)+len(opts.DNs) > 1
+	case "cephfs <name> remove-member", "cephfs <name> remove-member <username>":
+		opts := CLI.Cephfs.Name.RemoveMember
+		family, name, verb = "cephfs", CLI.Cephfs.Name.Name, "remove"
+		several = len(opts.Usernames)+len(opts.DNs) > 1
+	case "cephs3 <name> add-member", "cephs3 <name> add-member <username>":
+		opts := CLI.Cephs3.Name.AddMember
+		family, name, verb = "cephs3", CLI.Cephs3.Name.Name, "add"
+		several = len(opts.Usernames)+len(opts.DNs) > 1
+	case "cephs3 <name> remove-member", "cephs3 <name> remove-member <username>":
+		opts := CLI.Cephs3.Name.RemoveMember
+		family, name, verb = "cephs3", CLI.Cephs3.Name.Name, "remove"
+		several = len(opts.Usernames)+len(opts.DNs) > 1
+	case "software <name> add-member", "software <name> add-member <username>":
+		opts := CLI.Software.Name.AddMember
+		family, name, verb = "software", CLI.Software.Name.Name, "add"
+		several = len(opts.Usernames)+len(opts.DNs) > 1
+	case "software <name> remove-member", "software <name> remove-member <username>":
+		opts := CLI.Software.Name.RemoveMember
+		family, name, verb = "software", CLI.Software.Name.Name, "remove"
+		several = len(opts.Usernames)+len(opts.DNs) > 1
+	default:
+		return nil
+	}
+	if !several && !CLI.Verbose {
+		return nil
+	}
+
+	var count func(context.Context, string) (int, error)
+	switch family {
+	case "pirg":
+		count = pirg.PirgCountMembers
+	case "cephfs":
+		count = cephfs.CephfsCountMembers
+	case "cephs3":
+		count = cephs3.Cephs3CountMembers
+	case "software":
+		count = software.SoftwareCountMembers
+	}
+	c := &memberCount{
+		ctx:   ctx,
+		verb:  verb,
+		count: func(ctx context.Context) (int, error) { return count(ctx, name) },
+	}
+	if f, ok := managedgroup.ByName(family); ok {
+		c.group = f.Prefix + name
+	}
+	if subgroup != "" {
+		c.group += "." + subgroup
+		c.count = func(ctx context.Context) (int, error) { return pirg.PirgSubgroupCountMembers(ctx, name, subgroup) }
+	}
+	before, err := c.count(ctx)
+	if err != nil {
+		slog.Debug("Not counting members", "group", c.group, "error", err)
+		return nil
+	}
+	c.before = before
+	return c
+}
+
+// summary counts the members again, once, and returns both counts. failed
+// is how many of the members given weren't changed. It returns nil if there
+// is nothing to confirm.
+func (c *memberCount) summary(failed int) *memberCountSummary {
+	if c == nil {
+		return nil
+	}
+	// Confirm only once, whichever exit path gets here first
+	counted = nil
+	after, err := c.count(c.ctx)
+	if err != nil {
+		slog.Warn("Failed to count members after the change", "group", c.group, "error", err)
+		return nil
+	}
+	return &memberCountSummary{Group: c.group, Before: c.before, After: after, Failed: failed, verb: c.verb}
+}
+
+// String formats the summary like "is.racs.pirg.bio: 45 → 57 members (+12
+// added, 0 failed)".
+func (s *memberCountSummary) String() string {
+	return fmt.Sprintf("%s: %d → %d members (%+d %s, %d failed)", s.Group, s.Before, s.After, s.After-s.Before, bulkVerbs[s.verb][2], s.Failed)
+}
+
+// confirmMemberCount prints this run's member counts, if it took any. The
+// summary is the only JSON printed for a change made one member at a time.
+func confirmMemberCount(failed int) {
+	s := counted.summary(failed)
+	if s == nil {
+		return
+	}
+	if jsonOutput() {
+		printJSON(s)
+		return
+	}
+	fmt.Fprintln(stdout, s)
+}
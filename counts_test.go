@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestVerboseMemberCount checks that --verbose counts a single-member
+// change, and that several members, including those --members-of and
+// --from-subgroup find, are counted without it.
+func TestVerboseMemberCount(t *testing.T) {
+	cases := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"single", []string{"pirg", "alpha", "add-member", "carol"}, ""},
+		{"single verbose", []string{"--verbose", "pirg", "alpha", "add-member", "carol"}, "is.racs.pirg.alpha: 2 → 3 members (+1 added, 0 failed)"},
+		{"several", []string{"pirg", "alpha", "remove-member", "bob", "carol"}, "is.racs.pirg.alpha: 2 → 1 members (-1 removed, 0 failed)"},
+		{"members of", []string{"pirg", "alpha", "remove-member", "--members-of", "gamma"}, "is.racs.pirg.alpha: 2 → 1 members (-1 removed, 0 failed)"},
+		{"from subgroup", []string{"pirg", "alpha", "remove-member", "--from-subgroup", "lab"}, "is.racs.pirg.alpha.lab: 2 → 0 members (-2 removed, 0 failed)"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := newTestEnv(t, "")
+			code, stdout, stderr := env.run(append([]string{"--no-cache"}, tc.args...)...)
+			if code != 0 {
+				t.Fatalf("exit %d, stdout:\n%s\nstderr:\n%s", code, stdout, stderr)
+			}
+			if tc.want == "" {
+				if strings.Contains(stdout, "members (") {
+					t.Errorf("member count printed without --verbose:\n%s", stdout)
+				}
+				return
+			}
+			if !strings.Contains(stdout, tc.want) {
+				t.Errorf("stdout missing %q:\n%s", tc.want, stdout)
+			}
+		})
+	}
+}
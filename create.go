@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/uoracs/directory-manager/internal/pirg"
+)
+
+// populateNewPirg adds the --member and --admin users of pirg create to the
+// PIRG just created, the same way add-missing does. If that fails and
+// --rollback-on-error is set, the members added so far are removed and the
+// PIRG is deleted again, so a failed create leaves nothing behind. With
+// --rollback-on-error, skipping an inactive account counts as failing.
+func populateNewPirg(ctx context.Context, name string) {
+	opts := CLI.Pirg.Name.Create
+	if len(opts.Members) == 0 && len(opts.Admins) == 0 {
+		return
+	}
+	diff, err := pirg.PirgMembershipDiff(ctx, name, opts.Members, opts.Admins)
+	if err != nil {
+		fail("Error comparing PIRG membership", err)
+	}
+	if opts.NoMember {
+		// PirgMembershipDiff always wants the PI as a member
+		diff.AddMembers = slices.DeleteFunc(diff.AddMembers, func(u string) bool { return strings.EqualFold(u, opts.PI) })
+	}
+	inactive, err := pirg.PirgAddMissing(ctx, name, diff)
+	if err == nil && len(inactive) > 0 && opts.RollbackOnError {
+		err = fmt.Errorf("skipped %d inactive member or admin account(s): %w", len(inactive), errors.Join(inactive...))
+	}
+	if err != nil {
+		if !opts.RollbackOnError {
+			fail(fmt.Sprintf("Error populating PIRG %s, which was created", name), err)
+		}
+		if undoErr := pirg.PirgUndoCreate(ctx, name, diff.AddMembers); undoErr != nil {
+			fail(fmt.Sprintf("Error populating PIRG %s, and rolling back failed", name), errors.Join(err, undoErr))
+		}
+		fail(fmt.Sprintf("Error populating PIRG %s, which was deleted again", name), err)
+	}
+	reportInactive("member or admin", inactive)
+}
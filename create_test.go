@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const betaDN = "CN=is.racs.pirg.beta,OU=beta,OU=PIRGS,OU=RACS,DC=ad,DC=uoregon,DC=edu"
+
+// TestCreateWithInactiveMember checks that an inactive --member fails pirg
+// create, and that --rollback-on-error then deletes the PIRG again.
+func TestCreateWithInactiveMember(t *testing.T) {
+	cases := []struct {
+		name     string
+		rollback bool
+	}{
+		{name: "kept"},
+		{name: "rolled back", rollback: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := newTestEnv(t, "")
+			// dave is disabled
+			args := []string{"pirg", "beta", "create", "--pi", "bob", "--member", "carol", "--member", "dave"}
+			if tc.rollback {
+				args = append(args, "--rollback-on-error")
+			}
+			code, stdout, stderr := env.run(args...)
+			if code == 0 {
+				t.Fatalf("exit 0 with an inactive member, stdout:\n%s", stdout)
+			}
+			if !strings.Contains(stdout+stderr, "dave") {
+				t.Errorf("dave not reported as skipped:\n%s%s", stdout, stderr)
+			}
+			_, list, _ := env.run("pirg", "list")
+			exists := strings.Contains(list, "beta")
+			if exists == tc.rollback {
+				t.Errorf("beta exists = %v with rollback %v, pirg list:\n%s", exists, tc.rollback, list)
+			}
+			if !tc.rollback && !env.hasMember(betaDN, carolDN) {
+				t.Error("carol not added to the PIRG that was kept")
+			}
+		})
+	}
+}
+
+// TestCreateWithMail checks that pirg create mail-enables the PIRG group
+// from pirg_mail_template unless --no-mail is given, and refuses an
+// address another PIRG already uses.
+func TestCreateWithMail(t *testing.T) {
+	env := newTestEnv(t, "pirg_mail_template: \"{{.Name}}-pirg@talapas.uoregon.edu\"\n")
+	env.mustRun(t, "pirg", "beta", "create", "--pi", "bob")
+	if got := env.dir.Values(betaDN, "mail"); len(got) != 1 || got[0] != "beta-pirg@talapas.uoregon.edu" {
+		t.Errorf("mail = %q", got)
+	}
+	if got := env.dir.Values(betaDN, "proxyAddresses"); len(got) != 1 || got[0] != "SMTP:beta-pirg@talapas.uoregon.edu" {
+		t.Errorf("proxyAddresses = %q", got)
+	}
+	if got := env.mustRun(t, "pirg", "beta", "get-mail"); got != "beta-pirg@talapas.uoregon.edu\n" {
+		t.Errorf("get-mail = %q", got)
+	}
+
+	env.mustRun(t, "pirg", "delta", "create", "--pi", "bob", "--no-mail")
+	if got := env.mustRun(t, "pirg", "delta", "get-mail"); got != "No mail address set.\n" {
+		t.Errorf("get-mail after --no-mail = %q", got)
+	}
+	code, stdout, _ := env.run("pirg", "delta", "set-mail", "beta-pirg@talapas.uoregon.edu")
+	if code == 0 || !strings.Contains(stdout, "already exists") {
+		t.Errorf("set-mail to beta's address exited %d:\n%s", code, stdout)
+	}
+	env.mustRun(t, "pirg", "delta", "set-mail", "delta@talapas.uoregon.edu")
+	env.mustRun(t, "pirg", "delta", "clear-mail")
+	if got := env.mustRun(t, "pirg", "delta", "get-mail"); got != "No mail address set.\n" {
+		t.Errorf("get-mail after clear-mail = %q", got)
+	}
+}
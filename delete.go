@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/uoracs/directory-manager/internal/pirg"
+)
+
+// printDeleteBlockers prints what stands in the way of deleting a PIRG, as a
+// checklist to clear before the real delete.
+func printDeleteBlockers(ctx context.Context, name string) {
+	b, err := pirg.PirgDeleteBlockers(ctx, name)
+	if err != nil {
+		fail("Error checking PIRG", err)
+	}
+	if jsonOutput() {
+		printJSON(struct {
+			CanDelete bool `json:"can_delete"`
+			pirg.DeleteBlockers
+		}{!b.Blocked(), b})
+		return
+	}
+	if b.PI != "" {
+		fmt.Fprintf(stdout, "PI: %s (removed by the delete)\n", b.PI)
+	}
+	if !b.Blocked() {
+		fmt.Fprintf(stdout, "PIRG %s can be deleted.\n", name)
+		return
+	}
+	fmt.Fprintf(stdout, "PIRG %s can't be deleted until these are removed:\n", name)
+	if len(b.Members) > 0 {
+		fmt.Fprintf(stdout, "Members (%d): %s\n", len(b.Members), strings.Join(b.Members, ", "))
+	}
+	for _, sub := range b.Subgroups {
+		fmt.Fprintf(stdout, "Subgroup %s (%d): %s\n", sub.Name, len(sub.Members), strings.Join(sub.Members, ", "))
+	}
+}
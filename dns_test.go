@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// TestPirgDNsLegacyLocation checks that dns reports where a group actually
+// is rather than where the OU layout says it should be.
+func TestPirgDNsLegacyLocation(t *testing.T) {
+	env := newTestEnv(t, "")
+	req := ldap.NewModifyDNRequest(
+		"CN=is.racs.pirg.alpha.pi,OU=alpha,OU=PIRGS,OU=RACS,DC=ad,DC=uoregon,DC=edu",
+		"CN=is.racs.pirg.alpha.pi", true,
+		"OU=Groups,OU=alpha,OU=PIRGS,OU=RACS,DC=ad,DC=uoregon,DC=edu",
+	)
+	if err := env.dir.ModifyDN(req); err != nil {
+		t.Fatal(err)
+	}
+	got := env.mustRun(t, "pirg", "alpha", "dns")
+	want := "pi: CN=is.racs.pirg.alpha.pi,OU=Groups,OU=alpha,OU=PIRGS,OU=RACS,DC=ad,DC=uoregon,DC=edu\n"
+	if !strings.Contains(got, want) {
+		t.Errorf("dns doesn't contain %q:\n%s", want, got)
+	}
+	if strings.Contains(got, "subgroup pi") {
+		t.Errorf("moved PI group listed as a subgroup:\n%s", got)
+	}
+}
+
+// TestDNsMissingComponent checks that a missing admins or role group is
+// shown as missing and makes dns exit 1.
+func TestDNsMissingComponent(t *testing.T) {
+	cases := []struct {
+		name   string
+		create []string
+		args   []string
+		dn     string
+		label  string
+	}{
+		{
+			name:  "pirg admins",
+			args:  []string{"pirg", "alpha", "dns"},
+			dn:    "CN=is.racs.pirg.alpha.admins,OU=alpha,OU=PIRGS,OU=RACS,DC=ad,DC=uoregon,DC=edu",
+			label: "admins",
+		},
+		{
+			name:   "cephfs owner",
+			create: []string{"cephfs", "lab", "create", "--owner", "carol"},
+			args:   []string{"cephfs", "lab", "dns"},
+			dn:     labOwnerDN,
+			label:  "owner",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := newTestEnv(t, "")
+			if tc.create != nil {
+				env.mustRun(t, tc.create...)
+			}
+			if err := env.dir.Del(ldap.NewDelRequest(tc.dn, nil)); err != nil {
+				t.Fatal(err)
+			}
+			code, stdout, stderr := env.run(tc.args...)
+			if code != 1 {
+				t.Fatalf("exit %d, want 1, stdout:\n%s\nstderr:\n%s", code, stdout, stderr)
+			}
+			if want := tc.label + ": (missing)\n"; !strings.Contains(stdout, want) {
+				t.Errorf("stdout doesn't contain %q:\n%s", want, stdout)
+			}
+			if !strings.Contains(stdout, "main: CN=") {
+				t.Errorf("main group not listed alongside the missing one:\n%s", stdout)
+			}
+			cn := strings.TrimPrefix(strings.SplitN(tc.dn, ",", 2)[0], "CN=")
+			if want := "Missing groups: " + cn; !strings.Contains(stderr, want) {
+				t.Errorf("stderr doesn't contain %q:\n%s", want, stderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/uoracs/directory-manager/internal/config"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/managedgroup"
+)
+
+// globalSecurityGroup is the groupType of the groups the tool creates.
+const globalSecurityGroup = "-2147483646"
+
+// doctorCheck is the outcome of checking one assumption the tool makes about
+// the directory.
+type doctorCheck struct {
+	Check  string `json:"check"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// doctor collects checks and counts the failed ones.
+type doctor struct {
+	checks []doctorCheck
+	failed int
+}
+
+func (d *doctor) pass(check string, format string, args ...any) {
+	d.checks = append(d.checks, doctorCheck{Check: check, Status: "pass", Detail: fmt.Sprintf(format, args...)})
+}
+
+func (d *doctor) warn(check string, format string, args ...any) {
+	d.checks = append(d.checks, doctorCheck{Check: check, Status: "warn", Detail: fmt.Sprintf(format, args...)})
+}
+
+func (d *doctor) fail(check string, format string, args ...any) {
+	d.checks = append(d.checks, doctorCheck{Check: check, Status: "fail", Detail: fmt.Sprintf(format, args...)})
+	d.failed++
+}
+
+// runDoctor checks the directory against what the tool expects of it: base
+// DNs that are OUs, the top-level groups, groups with posixGroup, gidNumber
+// and the global security groupType, users with sAMAccountName and
+// uidNumber, and GID ranges with room left. It only reads, and exits 1 if
+// any check fails.
+func runDoctor(ctx context.Context, cfg *config.Config) {
+	d := &doctor{}
+
+	for _, f := range managedgroup.Families {
+		if cfg.DisabledSubsystem(f.Name) != "" {
+			continue
+		}
+		field := config.BaseDNField(f.Name)
+		if err := ld.CheckOU(ctx, cfg.BaseDN(f.Name)); err != nil {
+			d.fail(field, "%s", err)
+			continue
+		}
+		d.pass(field, "%s is an OU", cfg.BaseDN(f.Name))
+	}
+
+	if _, _, err := ld.SampleEntry(ctx, cfg.LDAPUsersBaseDN, "(objectClass=*)", []string{"distinguishedName"}); err != nil {
+		d.fail("ldap_users_base_dn", "%s", err)
+	} else {
+		d.pass("ldap_users_base_dn", "%s exists", cfg.LDAPUsersBaseDN)
+	}
+
+	topLevel := []string{cfg.UsersGroupDN}
+	for _, f := range managedgroup.Families {
+		if dn := cfg.AdminsGroupDN(f.Name); dn != "" && cfg.DisabledSubsystem(f.Name) == "" {
+			topLevel = append(topLevel, dn)
+		}
+	}
+	for _, dn := range topLevel {
+		cn, _ := ld.ConvertDNToObjectName(dn)
+		if err := ld.CheckGroup(ctx, dn); err != nil {
+			d.fail("top-level group "+cn, "%s", err)
+			continue
+		}
+		d.pass("top-level group "+cn, "%s is a group", dn)
+	}
+
+	for _, f := range managedgroup.Families {
+		if cfg.DisabledSubsystem(f.Name) != "" {
+			continue
+		}
+		d.checkSampleGroup(ctx, f, cfg.BaseDN(f.Name))
+	}
+	d.checkSampleUser(ctx, cfg)
+
+	byRange := make(map[[2]int]ld.GidUsage)
+	for _, f := range managedgroup.Families {
+		if cfg.DisabledSubsystem(f.Name) != "" {
+			continue
+		}
+		d.checkGidUsage(ctx, cfg, f, byRange)
+	}
+
+	printDoctor(d.checks)
+	if d.failed > 0 {
+		exit(1)
+	}
+}
+
+// checkSampleGroup checks one of the family's groups for the posixGroup
+// class, a numeric gidNumber, and the global security groupType.
+func (d *doctor) checkSampleGroup(ctx context.Context, f managedgroup.Family, baseDN string) {
+	check := f.Name + " group"
+	filter := fmt.Sprintf("(&(objectClass=group)(cn=%s*))", ldap.EscapeFilter(f.Prefix))
+	s, found, err := ld.SampleEntry(ctx, baseDN, filter, []string{"cn", "objectClass", "gidNumber", "groupType"})
+	if err != nil {
+		d.fail(check, "failed to sample a group: %s", err)
+		return
+	}
+	if !found {
+		d.warn(check, "no %s* group under %s to sample", f.Prefix, baseDN)
+		return
+	}
+	cn := firstValue(s.Attributes["cn"])
+	if slices.ContainsFunc(s.Attributes["objectclass"], func(c string) bool { return strings.EqualFold(c, "posixGroup") }) {
+		d.pass(check+" objectClass", "%s has posixGroup", cn)
+	} else {
+		d.warn(check+" objectClass", "%s lacks posixGroup", cn)
+	}
+	if gid := firstValue(s.Attributes["gidnumber"]); gid == "" {
+		d.fail(check+" gidNumber", "%s has no gidNumber, so SSSD ignores it", cn)
+	} else if _, err := strconv.Atoi(gid); err != nil {
+		d.fail(check+" gidNumber", "%s has gidNumber %q, which isn't a number", cn, gid)
+	} else {
+		d.pass(check+" gidNumber", "%s has gidNumber %s", cn, gid)
+	}
+	if groupType := firstValue(s.Attributes["grouptype"]); groupType == globalSecurityGroup {
+		d.pass(check+" groupType", "%s is a global security group (%s)", cn, groupType)
+	} else {
+		d.warn(check+" groupType", "%s has groupType %s, the tool creates %s (global security)", cn, groupType, globalSecurityGroup)
+	}
+}
+
+// checkGidUsage checks how much of the family's GID range is allocated,
+// warning from gid_usage_warn_percent on and failing once no GID is left.
+// Families sharing a range share one entry of byRange, so it is only
+// searched once.
+func (d *doctor) checkGidUsage(ctx context.Context, cfg *config.Config, f managedgroup.Family, byRange map[[2]int]ld.GidUsage) {
+	check := f.Name + " GID range"
+	minGid, maxGid := cfg.GidRange(f.Name)
+	u, ok := byRange[[2]int{minGid, maxGid}]
+	if !ok {
+		var err error
+		u, err = ld.GetGidUsage(ctx, minGid, maxGid)
+		if err != nil {
+			d.fail(check, "failed to compute GID usage: %s", err)
+			return
+		}
+		byRange[[2]int{minGid, maxGid}] = u
+	}
+	detail := fmt.Sprintf("%d-%d has %d of %d GIDs allocated (%.1f%%)", u.Min, u.Max, u.Allocated, u.Size, u.Percent)
+	switch {
+	case u.Free == 0:
+		d.fail(check, "%s, so no new group can be created", detail)
+	case u.Percent >= float64(cfg.GidUsageWarnPercent):
+		d.warn(check, "%s, at or over gid_usage_warn_percent (%d%%)", detail, cfg.GidUsageWarnPercent)
+	default:
+		d.pass(check, "%s", detail)
+	}
+}
+
+// checkSampleUser checks one member of the top-level users group for the
+// person category, sAMAccountName, and a numeric uidNumber.
+func (d *doctor) checkSampleUser(ctx context.Context, cfg *config.Config) {
+	filter := fmt.Sprintf("(&(objectCategory=person)(objectClass=user)(memberOf=%s))", ldap.EscapeFilter(cfg.UsersGroupDN))
+	s, found, err := ld.SampleEntry(ctx, cfg.LDAPUsersBaseDN, filter, []string{"sAMAccountName", "uidNumber"})
+	if err != nil {
+		d.fail("user", "failed to sample a user: %s", err)
+		return
+	}
+	if !found {
+		d.warn("user", "no person under %s is in the top-level users group to sample", cfg.LDAPUsersBaseDN)
+		return
+	}
+	username := firstValue(s.Attributes["samaccountname"])
+	if username == "" {
+		d.fail("user sAMAccountName", "%s has no sAMAccountName, so it can't be looked up by username", s.DN)
+		return
+	}
+	d.pass("user sAMAccountName", "%s is a person with sAMAccountName %s", s.DN, username)
+	if uid := firstValue(s.Attributes["uidnumber"]); uid == "" {
+		d.warn("user uidNumber", "%s has no uidNumber; get-uid falls back to the SID", username)
+	} else if _, err := strconv.Atoi(uid); err != nil {
+		d.fail("user uidNumber", "%s has uidNumber %q, which isn't a number", username, uid)
+	} else {
+		d.pass("user uidNumber", "%s has uidNumber %s", username, uid)
+	}
+}
+
+func firstValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// printDoctor prints each check as "[status] check: detail", or as a table
+// or JSON array.
+func printDoctor(checks []doctorCheck) {
+	if jsonOutput() {
+		printJSON(checks)
+		return
+	}
+	if tableOutput() {
+		rows := make([][]string, 0, len(checks))
+		for _, c := range checks {
+			rows = append(rows, []string{c.Status, c.Check, c.Detail})
+		}
+		printTable([]string{"status", "check", "detail"}, rows)
+		return
+	}
+	for _, c := range checks {
+		fmt.Fprintf(stdout, "[%s] %s: %s\n", c.Status, c.Check, c.Detail)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDoctorGidUsage(t *testing.T) {
+	// Every family but pirg gets an empty range of its own
+	const otherRanges = "cephfs_min_gid: 101000\ncephfs_max_gid: 101999\n" +
+		"cephs3_min_gid: 102000\ncephs3_max_gid: 102999\n" +
+		"software_min_gid: 103000\nsoftware_max_gid: 103999\n"
+	cases := []struct {
+		name       string
+		extra      string
+		wantStatus string
+		wantDetail string
+	}{
+		{
+			name:       "plenty left",
+			wantStatus: "pass",
+			wantDetail: "100000-100999 has 7 of 1000 GIDs allocated (0.7%)",
+		},
+		{
+			// alpha's four groups hold 100000-100003
+			name:       "nearly full",
+			extra:      "pirg_min_gid: 100000\npirg_max_gid: 100004\ngid_usage_warn_percent: 80\n" + otherRanges,
+			wantStatus: "warn",
+			wantDetail: "100000-100004 has 4 of 5 GIDs allocated (80.0%), at or over gid_usage_warn_percent (80%)",
+		},
+		{
+			name:       "full",
+			extra:      "pirg_min_gid: 100000\npirg_max_gid: 100003\n" + otherRanges,
+			wantStatus: "fail",
+			wantDetail: "100000-100003 has 4 of 4 GIDs allocated (100.0%), so no new group can be created",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := newTestEnv(t, tc.extra)
+			_, stdout, stderr := env.run("-o", "json", "doctor")
+			var checks []doctorCheck
+			if err := json.Unmarshal([]byte(stdout), &checks); err != nil {
+				t.Fatalf("%v, stdout:\n%s\nstderr:\n%s", err, stdout, stderr)
+			}
+			found := map[string]doctorCheck{}
+			for _, c := range checks {
+				found[c.Check] = c
+			}
+			got, ok := found["pirg GID range"]
+			if !ok {
+				t.Fatalf("no pirg GID range check in %+v", checks)
+			}
+			if got.Status != tc.wantStatus || got.Detail != tc.wantDetail {
+				t.Errorf("pirg GID range = %s %q, want %s %q", got.Status, got.Detail, tc.wantStatus, tc.wantDetail)
+			}
+			if c := found["cephfs GID range"]; c.Status != "pass" {
+				t.Errorf("cephfs GID range = %s %q, want pass", c.Status, c.Detail)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestDisabledFamilies checks that a family turned off in configuration is
+// refused before the directory is connected to, while the families left on
+// keep working.
+func TestDisabledFamilies(t *testing.T) {
+	cases := []struct {
+		name  string
+		extra string
+		args  []string
+		want  string
+	}{
+		{name: "pirg", extra: "enable_pirg: false\n", args: []string{"pirg", "alpha", "add-member", "carol"}, want: "the pirg subsystem is disabled in configuration"},
+		{name: "ceph covers cephfs", extra: "enable_ceph: false\n", args: []string{"cephfs", "lab", "create", "--owner", "carol"}, want: "the ceph subsystem is disabled in configuration"},
+		{name: "ceph covers cephs3", extra: "enable_ceph: false\n", args: []string{"cephs3", "list"}, want: "the ceph subsystem is disabled in configuration"},
+		{name: "cephfs", extra: "enable_cephfs: false\n", args: []string{"cephfs", "list"}, want: "the cephfs subsystem is disabled in configuration"},
+		{name: "software", extra: "enable_software: false\n", args: []string{"software", "matlab", "create"}, want: "the software subsystem is disabled in configuration"},
+		{name: "admins namespace", extra: "enable_cephs3: false\n", args: []string{"admins", "list", "--namespace", "cephs3"}, want: "the cephs3 subsystem is disabled in configuration"},
+		{name: "json", extra: "enable_ceph: false\n", args: []string{"--output", "json", "cephs3", "list"}, want: `"code":"disabled"`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := newTestEnv(t, tc.extra)
+			connect := connectDirectory
+			connectDirectory = func(ctx context.Context) context.Context {
+				t.Error("connected to the directory for a disabled family")
+				return connect(ctx)
+			}
+			code, stdout, stderr := env.run(tc.args...)
+			if code != 1 {
+				t.Errorf("exit %d, want 1, stderr:\n%s", code, stderr)
+			}
+			if !strings.Contains(stdout, tc.want) {
+				t.Errorf("stdout doesn't contain %q:\n%s", tc.want, stdout)
+			}
+		})
+	}
+}
+
+func TestEnabledFamiliesStillWork(t *testing.T) {
+	env := newTestEnv(t, "enable_cephfs: false\nenable_software: false\n")
+	env.mustRun(t, "cephs3", "bucket", "create", "--owner", "carol")
+	if got := env.mustRun(t, "cephs3", "list"); got != "bucket\n" {
+		t.Errorf("cephs3 list = %q", got)
+	}
+	if got := env.mustRun(t, "pirg", "list"); got != "alpha\ngamma\n" {
+		t.Errorf("pirg list = %q", got)
+	}
+}
+
+func TestDisabledFamiliesHiddenFromHelp(t *testing.T) {
+	env := newTestEnv(t, "enable_ceph: false\nenable_software: false\n")
+	code, stdout, _ := env.run("--help")
+	if code != 0 {
+		t.Fatalf("--help exited %d", code)
+	}
+	for _, hidden := range []string{"cephfs list", "cephs3 list", "software list"} {
+		if strings.Contains(stdout, hidden) {
+			t.Errorf("help lists disabled %q", hidden)
+		}
+	}
+	if !strings.Contains(stdout, "pirg list") {
+		t.Error("help doesn't list the enabled pirg family")
+	}
+
+	env = newTestEnv(t, "")
+	_, stdout, _ = env.run("--help")
+	for _, shown := range []string{"pirg list", "cephfs list", "cephs3 list", "software list"} {
+		if !strings.Contains(stdout, shown) {
+			t.Errorf("help doesn't list %q with every family enabled", shown)
+		}
+	}
+}
+
+func TestDoctorSkipsDisabledFamilies(t *testing.T) {
+	env := newTestEnv(t, "enable_ceph: false\n")
+	stdout := env.mustRun(t, "doctor")
+	for _, skipped := range []string{"cephfs", "cephs3", "CephfsAdmins", "CephS3Admins"} {
+		if strings.Contains(stdout, skipped) {
+			t.Errorf("doctor checked disabled %s:\n%s", skipped, stdout)
+		}
+	}
+	for _, checked := range []string{"ldap_pirg_dn", "ldap_software_dn", "pirg GID range", "software GID range"} {
+		if !strings.Contains(stdout, checked) {
+			t.Errorf("doctor skipped enabled %s:\n%s", checked, stdout)
+		}
+	}
+}
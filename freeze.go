@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os/user"
+	"strings"
+	"time"
+
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/quota"
+)
+
+// newFreeze returns a freeze for --reason, made now by the user running
+// the command.
+func newFreeze() ld.Freeze {
+	if CLI.Reason == "" {
+		failUsage("Freezing needs a reason: pass --reason, e.g. --reason \"RT#12345 billing dispute\".")
+	}
+	f := ld.Freeze{Reason: CLI.Reason, At: time.Now().UTC()}
+	if u, err := user.Current(); err == nil {
+		f.By = u.Username
+	}
+	return f
+}
+
+// freezeText describes a freeze for text output.
+func freezeText(f ld.Freeze) string {
+	return fmt.Sprintf("frozen by %s on %s: %s", f.By, f.At.Format(time.DateOnly), f.Reason)
+}
+
+// listedGroup is one group in list --long output.
+type listedGroup struct {
+	Name   string           `json:"name"`
+	Frozen bool             `json:"frozen"`
+	Freeze *ld.Freeze       `json:"freeze,omitempty"`
+	Quotas map[string]int64 `json:"quotas,omitempty"`
+}
+
+// printLongList prints group names with whether each is frozen, and why,
+// and their storage quotas, if quotas has any configured.
+func printLongList(names []string, frozen map[string]ld.Freeze, quotas listQuotas, none string) {
+	groups := make([]listedGroup, 0, len(names))
+	for _, name := range names {
+		g := listedGroup{Name: name, Quotas: quotas.byGroup[name]}
+		if f, ok := frozen[name]; ok {
+			g.Frozen, g.Freeze = true, &f
+		}
+		groups = append(groups, g)
+	}
+	if jsonOutput() {
+		printJSON(groups)
+		return
+	}
+	if len(groups) == 0 {
+		fmt.Fprintln(stdout, none)
+		return
+	}
+	if tableOutput() {
+		rows := make([][]string, 0, len(groups))
+		for _, g := range groups {
+			status := ""
+			if g.Frozen {
+				status = freezeText(*g.Freeze)
+			}
+			row := []string{g.Name, status}
+			for _, quotaName := range quotas.names {
+				value := ""
+				if bytes, ok := g.Quotas[quotaName]; ok {
+					value = quota.Format(bytes)
+				}
+				row = append(row, value)
+			}
+			rows = append(rows, row)
+		}
+		printTable(append([]string{"name", "status"}, quotas.names...), rows)
+		return
+	}
+	for _, g := range groups {
+		var notes []string
+		if g.Frozen {
+			notes = append(notes, freezeText(*g.Freeze))
+		}
+		if text := quotaText(quotas.names, g.Quotas); text != "" {
+			notes = append(notes, text)
+		}
+		if len(notes) > 0 {
+			fmt.Fprintf(stdout, "%s (%s)\n", g.Name, strings.Join(notes, "; "))
+			continue
+		}
+		fmt.Fprintln(stdout, g.Name)
+	}
+}
+
+// groupInfo is what info prints about a PIRG or cephfs group.
+type groupInfo struct {
+	Name    string     `json:"name"`
+	DN      string     `json:"dn"`
+	PI      string     `json:"pi,omitempty"`
+	Owner   string     `json:"owner,omitempty"`
+	Members int        `json:"members"`
+	Frozen  bool       `json:"frozen"`
+	Freeze  *ld.Freeze `json:"freeze,omitempty"`
+}
+
+// printGroupInfo prints info as "Field: value" lines, or JSON.
+func printGroupInfo(info groupInfo) {
+	if jsonOutput() {
+		printJSON(info)
+		return
+	}
+	fmt.Fprintf(stdout, "Name: %s\n", info.Name)
+	fmt.Fprintf(stdout, "DN: %s\n", info.DN)
+	if info.PI != "" {
+		fmt.Fprintf(stdout, "PI: %s\n", info.PI)
+	}
+	if info.Owner != "" {
+		fmt.Fprintf(stdout, "Owner: %s\n", info.Owner)
+	}
+	fmt.Fprintf(stdout, "Members: %d\n", info.Members)
+	if info.Frozen {
+		fmt.Fprintf(stdout, "Frozen: yes, %s\n", freezeText(*info.Freeze))
+	} else {
+		fmt.Fprintln(stdout, "Frozen: no")
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/uoracs/directory-manager/internal/config"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/managedgroup"
+)
+
+type familyGidUsage struct {
+	Family string `json:"family"`
+	ld.GidUsage
+}
+
+// printGidUsage prints how much of each family's GID range is allocated.
+// Families without a range of their own share ldap_min_gid/ldap_max_gid,
+// which is scanned once and reported for each of them.
+func printGidUsage(ctx context.Context, cfg *config.Config) {
+	byRange := make(map[[2]int]ld.GidUsage)
+	usages := make([]familyGidUsage, 0, len(managedgroup.Families))
+	for _, f := range managedgroup.Families {
+		minGid, maxGid := cfg.GidRange(f.Name)
+		u, ok := byRange[[2]int{minGid, maxGid}]
+		if !ok {
+			var err error
+			u, err = ld.GetGidUsage(ctx, minGid, maxGid)
+			if err != nil {
+				fail(fmt.Sprintf("Error computing GID usage of %s", f.Name), err)
+			}
+			byRange[[2]int{minGid, maxGid}] = u
+		}
+		usages = append(usages, familyGidUsage{Family: f.Name, GidUsage: u})
+	}
+
+	if jsonOutput() {
+		printJSON(usages)
+		return
+	}
+	rows := make([][]string, 0, len(usages))
+	for _, u := range usages {
+		rows = append(rows, []string{
+			u.Family,
+			fmt.Sprintf("%d-%d", u.Min, u.Max),
+			strconv.Itoa(u.Size),
+			strconv.Itoa(u.Allocated),
+			strconv.Itoa(u.Free),
+			strconv.Itoa(u.LargestFreeBlock),
+			fmt.Sprintf("%.1f%%", u.Percent),
+		})
+	}
+	printTable([]string{"family", "range", "size", "allocated", "free", "largest free block", "used"}, rows)
+}
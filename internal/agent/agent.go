@@ -0,0 +1,85 @@
+// Package agent runs a long-lived process that holds one bound LDAP
+// connection and answers read-only lookups over a unix socket, so scripts
+// that call the CLI many times don't pay for config loading, the TLS dial
+// and the bind on every call.
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"path/filepath"
+	"time"
+
+	"github.com/uoracs/directory-manager/internal/config"
+)
+
+// ProtocolVersion is bumped on any incompatible change to Request or
+// Response. The agent refuses requests of any other version, and the CLI
+// falls back to connecting directly.
+const ProtocolVersion = 2
+
+const (
+	// OpPing checks that an agent is listening.
+	OpPing = "ping"
+	// OpStop asks the agent to exit.
+	OpStop = "stop"
+
+	dialTimeout    = time.Second
+	requestTimeout = 30 * time.Second
+)
+
+// Request is one operation sent to the agent. Op is the CLI command as kong
+// names it, e.g. "pirg <name> get-pi", and Args its positional arguments.
+// Config is the CLI's config.Config fingerprint; the agent only answers
+// operations when it matches its own, since a CLI given another --config
+// or environment may be asking about another directory.
+type Request struct {
+	Version int      `json:"version"`
+	Op      string   `json:"op"`
+	Args    []string `json:"args,omitempty"`
+	Config  string   `json:"config,omitempty"`
+}
+
+// Response is the agent's answer. Lines are printed as-is; NotFound is the
+// message for a missing object. Any other failure is reported in Error, and
+// the CLI retries the command directly to report it properly.
+type Response struct {
+	Version  int      `json:"version"`
+	Lines    []string `json:"lines,omitempty"`
+	NotFound string   `json:"not_found,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// SocketPath returns where the agent listens.
+func SocketPath(cfg *config.Config) string {
+	return filepath.Join(cfg.DataPath, "agent.sock")
+}
+
+// Call sends req to the agent listening at path and returns its response.
+func Call(path string, req Request) (Response, error) {
+	conn, err := net.DialTimeout("unix", path, dialTimeout)
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to connect to agent: %w", err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(requestTimeout)); err != nil {
+		return Response{}, fmt.Errorf("failed to set agent deadline: %w", err)
+	}
+
+	req.Version = ProtocolVersion
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return Response{}, fmt.Errorf("failed to send agent request: %w", err)
+	}
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return Response{}, fmt.Errorf("failed to read agent response: %w", err)
+	}
+	if resp.Version != ProtocolVersion {
+		return Response{}, fmt.Errorf("agent speaks protocol version %d, expected %d", resp.Version, ProtocolVersion)
+	}
+	if resp.Error != "" {
+		return Response{}, fmt.Errorf("agent error: %s", resp.Error)
+	}
+	return resp, nil
+}
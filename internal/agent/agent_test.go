@@ -0,0 +1,56 @@
+package agent
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const fingerprint = "agent-fingerprint"
+
+// serveOnce answers the first request sent to a socket in a temporary
+// directory, as an agent with the given config fingerprint would, and
+// returns the socket's path.
+func serveOnce(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "agent.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		handle(context.Background(), conn, fingerprint)
+	}()
+	return path
+}
+
+func TestCallPing(t *testing.T) {
+	// Pinging needs no configuration, so an agent can always be found
+	if _, err := Call(serveOnce(t), Request{Op: OpPing}); err != nil {
+		t.Errorf("ping failed: %v", err)
+	}
+}
+
+func TestCallConfigMismatch(t *testing.T) {
+	for _, config := range []string{"", "another-fingerprint"} {
+		_, err := Call(serveOnce(t), Request{Op: "pirg list", Config: config})
+		if err == nil || !strings.Contains(err.Error(), "different configuration") {
+			t.Errorf("Call with config %q: error = %v, want a configuration mismatch", config, err)
+		}
+	}
+}
+
+func TestCallUnsupportedOperation(t *testing.T) {
+	// A matching fingerprint gets past the check to the operation itself
+	_, err := Call(serveOnce(t), Request{Op: "pirg <name> delete", Config: fingerprint})
+	if err == nil || !strings.Contains(err.Error(), "unsupported operation") {
+		t.Errorf("error = %v, want an unsupported operation", err)
+	}
+}
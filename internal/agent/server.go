@@ -0,0 +1,220 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"time"
+
+	"github.com/uoracs/directory-manager/internal/cephfs"
+	"github.com/uoracs/directory-manager/internal/cephs3"
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/pirg"
+)
+
+// errNotFound carries the message printed for a missing object.
+type errNotFound string
+
+func (e errNotFound) Error() string { return string(e) }
+
+// operation answers one read-only command, returning the lines the CLI
+// would have printed.
+type operation func(ctx context.Context, args []string) ([]string, error)
+
+// operations are the commands the agent answers. Their output must match
+// what main prints for the same command run directly.
+var operations = map[string]operation{
+	"pirg list": func(ctx context.Context, args []string) ([]string, error) {
+		pirgs, err := pirg.PirgList(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(pirgs) == 0 {
+			return []string{"No PIRGs found."}, nil
+		}
+		return pirgs, nil
+	},
+	"pirg <name> get-pi": func(ctx context.Context, args []string) ([]string, error) {
+		if err := pirgExists(ctx, args); err != nil {
+			return nil, err
+		}
+		pi, err := pirg.PirgGetPIUsername(ctx, args[0])
+		if err != nil {
+			return nil, err
+		}
+		return []string{pi}, nil
+	},
+	"pirg <name> list-members": func(ctx context.Context, args []string) ([]string, error) {
+		if err := pirgExists(ctx, args); err != nil {
+			return nil, err
+		}
+		return pirg.PirgListMemberUsernames(ctx, args[0])
+	},
+	"pirg <name> list-admins": func(ctx context.Context, args []string) ([]string, error) {
+		if err := pirgExists(ctx, args); err != nil {
+			return nil, err
+		}
+		return pirg.PirgListAdminUsernames(ctx, args[0])
+	},
+	"cephfs <name> get-owner": func(ctx context.Context, args []string) ([]string, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+		}
+		owner, err := cephfs.CephfsGetOwnerUsername(ctx, args[0])
+		if err != nil {
+			return nil, err
+		}
+		if owner == "" {
+			return []string{"No PI assigned to this cephfs group"}, nil
+		}
+		return []string{owner}, nil
+	},
+	"cephs3 <name> get-owner": func(ctx context.Context, args []string) ([]string, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+		}
+		owner, err := cephs3.Cephs3GetOwnerUsername(ctx, args[0])
+		if err != nil {
+			return nil, err
+		}
+		if owner == "" {
+			return []string{"No PI assigned to this cephs3 group"}, nil
+		}
+		return []string{owner}, nil
+	},
+}
+
+func pirgExists(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected 1 argument, got %d", len(args))
+	}
+	found, err := pirg.PirgExists(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errNotFound(fmt.Sprintf("PIRG %s not found.", args[0]))
+	}
+	return nil
+}
+
+// Serve listens on the agent socket and answers requests one at a time with
+// the connection in ctx, reconnecting if the server drops it. It returns
+// once no request has arrived for idle, when asked to stop, or once ctx is
+// done.
+func Serve(ctx context.Context, idle time.Duration) error {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return fmt.Errorf("config not found in context")
+	}
+	path := SocketPath(cfg)
+	if err := os.MkdirAll(cfg.DataPath, 0o700); err != nil {
+		return fmt.Errorf("failed to create data path: %w", err)
+	}
+	// A socket left behind by an agent that died is replaced; a live one isn't
+	if _, err := Call(path, Request{Op: OpPing}); err == nil {
+		return fmt.Errorf("an agent is already listening on %s", path)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove stale agent socket: %w", err)
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	defer ln.Close()
+	// Only the account running the agent may use its bound connection
+	if err := os.Chmod(path, 0o600); err != nil {
+		return fmt.Errorf("failed to restrict agent socket: %w", err)
+	}
+	slog.Info("Agent listening", "socket", path, "idle", idle)
+
+	fingerprint := cfg.Fingerprint()
+	timer := time.AfterFunc(idle, func() {
+		slog.Info("Agent idle, shutting down", "idle", idle)
+		ln.Close()
+	})
+	defer timer.Stop()
+	// Ctrl-C, SIGTERM, or --timeout stop the agent the same way
+	stopWatching := context.AfterFunc(ctx, func() {
+		slog.Info("Agent interrupted, shutting down")
+		ln.Close()
+	})
+	defer stopWatching()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("failed to accept agent connection: %w", err)
+		}
+		timer.Stop()
+		if !ld.Connected(ctx) {
+			slog.Info("LDAP connection closed, reconnecting")
+			if err := ld.Reconnect(ctx); err != nil {
+				slog.Error("Failed to reconnect to LDAP", "error", err)
+			}
+		}
+		if stop := handle(ctx, conn, fingerprint); stop {
+			slog.Info("Agent stopped")
+			return nil
+		}
+		timer.Reset(idle)
+	}
+}
+
+// handle answers the single request on conn and reports whether it asked
+// the agent to stop. Operations are only answered for a CLI whose config
+// fingerprint matches the agent's.
+func handle(ctx context.Context, conn net.Conn, fingerprint string) bool {
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(requestTimeout)); err != nil {
+		slog.Error("Failed to set agent connection deadline", "error", err)
+		return false
+	}
+	var req Request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		slog.Error("Failed to read agent request", "error", err)
+		return false
+	}
+
+	resp := Response{Version: ProtocolVersion}
+	stop := false
+	if req.Version != ProtocolVersion {
+		resp.Error = fmt.Sprintf("unsupported protocol version %d, the agent speaks %d", req.Version, ProtocolVersion)
+	} else if req.Op == OpStop {
+		stop = true
+	} else if req.Op != OpPing && req.Config != fingerprint {
+		resp.Error = "the agent was started with a different configuration"
+	} else if req.Op != OpPing {
+		op, ok := operations[req.Op]
+		if !ok {
+			resp.Error = fmt.Sprintf("unsupported operation %q", req.Op)
+		} else {
+			lines, err := op(ctx, req.Args)
+			var notFound errNotFound
+			switch {
+			case errors.As(err, &notFound):
+				resp.NotFound = notFound.Error()
+			case err != nil:
+				resp.Error = err.Error()
+			default:
+				resp.Lines = lines
+			}
+		}
+	}
+	slog.Debug("Answered agent request", "op", req.Op, "args", req.Args, "error", resp.Error)
+
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		slog.Error("Failed to write agent response", "error", err)
+	}
+	return stop
+}
@@ -0,0 +1,78 @@
+// Package bulk makes one membership change for many members, optionally
+// over several LDAP connections at once.
+package bulk
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+)
+
+// MaxParallel is the most connections Run opens, so a large --parallel
+// can't flood the domain controller.
+const MaxParallel = 8
+
+// Result is the outcome of the change for one member.
+type Result struct {
+	Member string
+	Err    error
+}
+
+// Run calls change for each member and returns one result per member, in
+// the order the members were given. With parallel above 1, up to parallel
+// workers each bind their own connection from the config in ctx and take
+// members from a shared queue. Otherwise the changes are made one after
+// another over ctx's connection. An error opening a connection fails the
+// run before any change is made.
+func Run(ctx context.Context, members []string, parallel int, change func(ctx context.Context, member string) error) ([]Result, error) {
+	results := make([]Result, len(members))
+	for i, member := range members {
+		results[i].Member = member
+	}
+	parallel = min(parallel, MaxParallel, len(members))
+	if parallel <= 1 {
+		for i := range results {
+			results[i].Err = change(ctx, results[i].Member)
+		}
+		return results, nil
+	}
+
+	workers := make([]context.Context, 0, parallel)
+	defer func() {
+		for _, wctx := range workers {
+			ld.Close(wctx)
+		}
+	}()
+	for range parallel {
+		wctx, err := ld.LoadLDAPConnection(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open connection %d of %d: %w", len(workers)+1, parallel, err)
+		}
+		workers = append(workers, wctx)
+	}
+	slog.Debug("Opened worker connections", "count", parallel, "members", len(members))
+
+	// Each worker writes only the results of the members it takes, so
+	// results needs no lock
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for w, wctx := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				results[i].Err = change(wctx, results[i].Member)
+				slog.Debug("Changed member", "worker", w, "member", results[i].Member, "error", results[i].Err)
+			}
+		}()
+	}
+	for i := range members {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+	return results, nil
+}
@@ -9,16 +9,16 @@ import (
 	"strings"
 
 	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/consistency"
 	"github.com/uoracs/directory-manager/internal/keys"
 	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/managedgroup"
 )
 
 var (
-	err                   error
-	found                 bool
-	groupPrefix           = "is.racs.cephfs."
-	topLevelUsersGroupDN  = "CN=IS.RACS.Talapas.Users,OU=RACS,OU=Groups,OU=IS,OU=Units,DC=ad,DC=uoregon,DC=edu"
-	topLevelAdminsGroupDN = "CN=IS.RACS.Talapas.CephfsAdmins,OU=RACS,OU=Groups,OU=IS,OU=Units,DC=ad,DC=uoregon,DC=edu"
+	err         error
+	found       bool
+	groupPrefix = managedgroup.Cephfs.Prefix
 )
 
 func ConvertCEPHGroupNametoShortName(cephfsName string) (string, error) {
@@ -63,7 +63,7 @@ func getCEPHFSAdminsGroupFullName(ctx context.Context, cephfsName string) (strin
 	if cfg == nil {
 		return "", fmt.Errorf("config not found in context")
 	}
-	n := fmt.Sprintf("%s%s.admins", groupPrefix, cephfsName)
+	n := groupPrefix + cephfsName + managedgroup.Cephfs.AdminsSuffix
 	slog.Debug("CEPHFS admins group full name", "name", n)
 	return n, nil
 }
@@ -74,7 +74,7 @@ func getCEPHFSOWNERGroupFullName(ctx context.Context, cephfsName string) (string
 	if cfg == nil {
 		return "", fmt.Errorf("config not found in context")
 	}
-	n := fmt.Sprintf("%s%s.owner", groupPrefix, cephfsName)
+	n := groupPrefix + cephfsName + managedgroup.Cephfs.RoleSuffix
 	slog.Debug("CEPHFS OWNER group full name", "name", n)
 	return n, nil
 }
@@ -86,7 +86,7 @@ func getUserDN(ctx context.Context, name string) (string, error) {
 		return "", fmt.Errorf("failed to get user DN: %w", err)
 	}
 	if dn == "" {
-		return "", fmt.Errorf("user %s not found", name)
+		return "", fmt.Errorf("user %s %w", name, ld.ErrNotFound)
 	}
 	slog.Debug("User DN", "dn", dn)
 	return dn, nil
@@ -103,7 +103,7 @@ func getCEPHFSSubgroupOUDN(ctx context.Context, cephfsName string) (string, erro
 	if err != nil {
 		return "", fmt.Errorf("failed to get CEPHFS DN: %w", err)
 	}
-	n := fmt.Sprintf("OU=Groups,%s", cephfsDN)
+	n := fmt.Sprintf("OU=%s,%s", managedgroup.Cephfs.SubgroupOU, cephfsDN)
 	slog.Debug("CEPHFS subgroup OU DN", "dn", n)
 	return n, nil
 }
@@ -148,11 +148,15 @@ func getCEPHFSDN(ctx context.Context, name string) (string, error) {
 // if not found, it returns an empty string, false, and nil
 func findCEPHFSDN(ctx context.Context, name string) (string, bool, error) {
 	slog.Debug("Finding CEPHFS DN", "name", name)
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return "", false, fmt.Errorf("config not found in context")
+	}
 	groupName, err := getCEPHFSFullName(ctx, name)
 	if err != nil {
 		return "", false, fmt.Errorf("failed to get CEPHFS full name: %w", err)
 	}
-	dn, found, err := ld.GetGroupDN(ctx, groupName)
+	dn, found, err := ld.GetGroupDN(ctx, cfg.LDAPCephfsDN, groupName)
 	if !found && err == nil {
 		slog.Debug("CEPHFS not found", "name", name)
 		return "", false, nil
@@ -280,7 +284,7 @@ func getAllCEPHFSDNs(ctx context.Context) ([]string, error) {
 	for _, groupName := range allGroupNamesInCEPHFSsOU {
 		slog.Debug("Checking group name", "groupName", groupName)
 		if matched, _ := regexp.MatchString(cephfsGroupNameRegex, groupName); matched {
-			cephfsDN, found, err := ld.GetGroupDN(ctx, groupName)
+			cephfsDN, found, err := ld.GetGroupDN(ctx, cfg.LDAPCephfsDN, groupName)
 			if err != nil {
 				return nil, fmt.Errorf("failed to get group DN: %w", err)
 			}
@@ -296,23 +300,29 @@ func getAllCEPHFSDNs(ctx context.Context) ([]string, error) {
 // addUserToTopLevelUsersGroup adds a user to the top level users group.
 func addUserToTopLevelUsersGroup(ctx context.Context, member string) error {
 	slog.Debug("Adding user to top level users group", "member", member)
-	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
-	if cfg == nil {
-		return fmt.Errorf("config not found in context")
-	}
 	userDN, err := getUserDN(ctx, member)
 	if err != nil {
 		return fmt.Errorf("failed to get user DN: %w", err)
 	}
-	inGroup, err := ld.UserInGroup(ctx, topLevelUsersGroupDN, userDN)
+	return addUserDNToTopLevelUsersGroup(ctx, member, userDN)
+}
+
+// addUserDNToTopLevelUsersGroup is addUserToTopLevelUsersGroup for a user
+// whose DN is already known.
+func addUserDNToTopLevelUsersGroup(ctx context.Context, member string, userDN string) error {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return fmt.Errorf("config not found in context")
+	}
+	inGroup, err := ld.UserInGroup(ctx, cfg.UsersGroupDN, userDN)
 	if err != nil {
 		return fmt.Errorf("failed to check if user is in group: %w", err)
 	}
 	if inGroup {
-		slog.Debug("User already in top level users group", "userDN", userDN, "topLevelUsersGroupDN", topLevelUsersGroupDN)
+		slog.Debug("User already in top level users group", "userDN", userDN, "topLevelUsersGroupDN", cfg.UsersGroupDN)
 		return nil
 	}
-	err = ld.AddUserToGroup(ctx, topLevelUsersGroupDN, userDN)
+	err = ld.AddUserToGroup(ctx, cfg.UsersGroupDN, userDN)
 	if err != nil {
 		return fmt.Errorf("failed to add user %s to users group: %w", member, err)
 	}
@@ -331,15 +341,15 @@ func addUsertoTopLevelAdminsGroup(ctx context.Context, member string) error {
 	if err != nil {
 		return fmt.Errorf("failed to get user DN: %w", err)
 	}
-	inGroup, err := ld.UserInGroup(ctx, topLevelAdminsGroupDN, userDN)
+	inGroup, err := ld.UserInGroup(ctx, cfg.CephfsAdminsGroupDN, userDN)
 	if err != nil {
 		return fmt.Errorf("failed to check if user is in group: %w", err)
 	}
 	if inGroup {
-		slog.Debug("User already in top level admins group", "userDN", userDN, "topLevelAdminsGroupDN", topLevelAdminsGroupDN)
+		slog.Debug("User already in top level admins group", "userDN", userDN, "topLevelAdminsGroupDN", cfg.CephfsAdminsGroupDN)
 		return nil
 	}
-	err = ld.AddUserToGroup(ctx, topLevelAdminsGroupDN, userDN)
+	err = ld.AddUserToGroup(ctx, cfg.CephfsAdminsGroupDN, userDN)
 	if err != nil {
 		return fmt.Errorf("failed to add user %s to admins group: %w", member, err)
 	}
@@ -358,15 +368,15 @@ func removeUserFromTopLevelUsersGroup(ctx context.Context, member string) error
 	if err != nil {
 		return fmt.Errorf("failed to get user DN: %w", err)
 	}
-	inGroup, err := ld.UserInGroup(ctx, topLevelUsersGroupDN, userDN)
+	inGroup, err := ld.UserInGroup(ctx, cfg.UsersGroupDN, userDN)
 	if err != nil {
 		return fmt.Errorf("failed to check if user is in group: %w", err)
 	}
 	if !inGroup {
-		slog.Debug("User not in top level users group", "userDN", userDN, "topLevelUsersGroupDN", topLevelUsersGroupDN)
+		slog.Debug("User not in top level users group", "userDN", userDN, "topLevelUsersGroupDN", cfg.UsersGroupDN)
 		return nil
 	}
-	err = ld.RemoveUserFromGroup(ctx, topLevelUsersGroupDN, userDN)
+	err = ld.RemoveUserFromGroup(ctx, cfg.UsersGroupDN, userDN)
 	if err != nil {
 		return fmt.Errorf("failed to remove user %s from users group: %w", member, err)
 	}
@@ -385,15 +395,15 @@ func removeUserFromTopLevelAdminsGroup(ctx context.Context, member string) error
 	if err != nil {
 		return fmt.Errorf("failed to get user DN: %w", err)
 	}
-	inGroup, err := ld.UserInGroup(ctx, topLevelAdminsGroupDN, userDN)
+	inGroup, err := ld.UserInGroup(ctx, cfg.CephfsAdminsGroupDN, userDN)
 	if err != nil {
 		return fmt.Errorf("failed to check if user is in group: %w", err)
 	}
 	if !inGroup {
-		slog.Debug("User not in top level admins group", "userDN", userDN, "topLevelAdminsGroupDN", topLevelAdminsGroupDN)
+		slog.Debug("User not in top level admins group", "userDN", userDN, "topLevelAdminsGroupDN", cfg.CephfsAdminsGroupDN)
 		return nil
 	}
-	err = ld.RemoveUserFromGroup(ctx, topLevelAdminsGroupDN, userDN)
+	err = ld.RemoveUserFromGroup(ctx, cfg.CephfsAdminsGroupDN, userDN)
 	if err != nil {
 		return fmt.Errorf("failed to remove user %s from admins group: %w", member, err)
 	}
@@ -401,33 +411,51 @@ func removeUserFromTopLevelAdminsGroup(ctx context.Context, member string) error
 	return nil
 }
 
-// userInAnyCEPHFS checks if the user is in any CEPHFS.
-func userInAnyCEPHFS(ctx context.Context, username string) (bool, error) {
-	slog.Debug("Checking if user is in any CEPHFS", "username", username)
+// ListUserCephfs returns the short names of the CEPHFS groups the user is a member of,
+// derived from their memberOf entries. Admins, owner, and subgroups are not counted.
+func ListUserCephfs(ctx context.Context, username string) ([]string, error) {
+	slog.Debug("Listing CEPHFS groups for user", "username", username)
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
 	if cfg == nil {
-		return false, fmt.Errorf("config not found in context")
+		return nil, fmt.Errorf("config not found in context")
 	}
 	userDN, err := getUserDN(ctx, username)
 	if err != nil {
-		return false, fmt.Errorf("failed to get user DN: %w", err)
+		return nil, fmt.Errorf("failed to get user DN: %w", err)
 	}
 	userGroups, err := ld.GetGroupsForUser(ctx, userDN)
 	if err != nil {
-		return false, fmt.Errorf("failed to get user groups: %w", err)
+		return nil, fmt.Errorf("failed to get user groups: %w", err)
 	}
+	var names []string
 	for _, groupDN := range userGroups {
 		groupName, err := ld.ConvertDNToObjectName(groupDN)
 		if err != nil {
-			return false, fmt.Errorf("failed to convert DN to object name: %w", err)
+			return nil, fmt.Errorf("failed to convert DN to object name: %w", err)
 		}
-		if strings.HasPrefix(groupName, groupPrefix) {
-			slog.Debug("User found in some CEPHFS", "userDN", userDN, "groupDN", groupDN)
-			return true, nil
+		namespace, ok := ld.ClassifyGroupName(groupName)
+		if !ok || namespace != "cephfs" {
+			continue
+		}
+		name := strings.TrimPrefix(strings.ToLower(groupName), groupPrefix)
+		if strings.Contains(name, ".") {
+			// this is admins, owner, or a subgroup
+			continue
 		}
+		names = append(names, name)
 	}
-	slog.Debug("User not found in any CEPHFS group")
-	return false, nil
+	slices.Sort(names)
+	slog.Debug("User CEPHFS groups", "username", username, "cephfs", names)
+	return names, nil
+}
+
+// userInAnyCEPHFS checks if the user is in any CEPHFS.
+func userInAnyCEPHFS(ctx context.Context, username string) (bool, error) {
+	names, err := ListUserCephfs(ctx, username)
+	if err != nil {
+		return false, err
+	}
+	return len(names) > 0, nil
 }
 
 // userIsAdminInAnyCEPHFS checks if the user is an admin in any CEPHFS.
@@ -512,10 +540,24 @@ func CephfsCreate(ctx context.Context, cephfsName string, ownerUsername string)
 		return fmt.Errorf("failed to find CEPHFS DN: %w", err)
 	}
 
-	// Get the starting gidNumber, we'll increment locally
-	// for each group we create
-	// TODO: use the prod version: ld.GetNextGidNumber
-	gidNumber, err := ld.GetNextGidNumber(ctx)
+	// Check the owner before creating anything
+	err = checkOwnerEligibility(ctx, ownerUsername)
+	if err != nil {
+		return err
+	}
+
+	// Reuse an empty OU left by an earlier failed create
+	ouDN, err := getCEPHFSOUDN(ctx, cephfsName)
+	if err != nil {
+		return fmt.Errorf("failed to get CEPHFS OU DN: %w", err)
+	}
+	err = ld.AdoptOrphanOU(ctx, ouDN, "cephfs orphans clean")
+	if err != nil {
+		return err
+	}
+
+	// Reserve a gidNumber for each of the three groups we create
+	gidNumber, err := ld.AllocateGids(ctx, "cephfs", 3)
 	if err != nil {
 		return fmt.Errorf("failed to get next GID number: %w", err)
 	}
@@ -580,7 +622,7 @@ func CephfsCreate(ctx context.Context, cephfsName string, ownerUsername string)
 	slog.Debug("Created CEPHFS OWNER group object", "cephfsOwnerGroupName", cephfsOwnerGroupFullName)
 
 	// Add the Owner to the CEPHFS Owner group
-	err = CEPHFSSetOWNER(ctx, cephfsName, ownerUsername)
+	err = setOwner(ctx, cephfsName, ownerUsername)
 	if err != nil {
 		return fmt.Errorf("failed to add Owner user %s to CEPHFS Owner group %s: %w", ownerUsername, cephfsName, err)
 	}
@@ -610,6 +652,9 @@ func CephfsDelete(ctx context.Context, cephfsName string) error {
 	if cfg == nil {
 		return fmt.Errorf("config not found in context")
 	}
+	if err := checkNotFrozen(ctx, cephfsName); err != nil {
+		return err
+	}
 	cephOUDN, err := getCEPHFSOUDN(ctx, cephfsName)
 	if err != nil {
 		return fmt.Errorf("failed to get CEPHFS DN: %w", err)
@@ -637,6 +682,101 @@ func CephfsDelete(ctx context.Context, cephfsName string) error {
 	return nil
 }
 
+// CephfsRename renames the CEPHFS with the given name.
+//
+// It renames the CEPHFS OU, then the main, admins, and Owner groups and every
+// subgroup, since all of their CNs embed the CEPHFS name.
+func CephfsRename(ctx context.Context, cephfsName string, newName string) error {
+	slog.Debug("Renaming CEPHFS", "name", cephfsName, "newName", newName)
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return fmt.Errorf("config not found in context")
+	}
+	if err := checkNotFrozen(ctx, cephfsName); err != nil {
+		return err
+	}
+
+	// Validate the new name the same way we match existing CEPHFS groups
+	cephfsGroupNameRegex, err := cephfsGroupNameRegex(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get CEPHFS group name regex: %w", err)
+	}
+	newFullName, err := getCEPHFSFullName(ctx, newName)
+	if err != nil {
+		return fmt.Errorf("failed to get CEPHFS full name: %w", err)
+	}
+	if matched, _ := regexp.MatchString(cephfsGroupNameRegex, newFullName); !matched {
+		return fmt.Errorf("invalid CEPHFS name: %s", newName)
+	}
+
+	// Make sure the target doesn't exist yet
+	_, found, err := findCEPHFSDN(ctx, newName)
+	if err != nil {
+		return fmt.Errorf("failed to find CEPHFS DN: %w", err)
+	}
+	if found {
+		return fmt.Errorf("CEPHFS %s %w", newName, ld.ErrAlreadyExists)
+	}
+	newOUDN, err := getCEPHFSOUDN(ctx, newName)
+	if err != nil {
+		return fmt.Errorf("failed to get CEPHFS DN: %w", err)
+	}
+	exists, err := ld.DNExists(ctx, newOUDN)
+	if err != nil {
+		return fmt.Errorf("failed to check if OU exists: %w", err)
+	}
+	if exists {
+		return fmt.Errorf("CEPHFS OU %s %w", newOUDN, ld.ErrAlreadyExists)
+	}
+
+	// Rename the OU, this moves every group inside it along
+	oldOUDN, err := getCEPHFSOUDN(ctx, cephfsName)
+	if err != nil {
+		return fmt.Errorf("failed to get CEPHFS DN: %w", err)
+	}
+	err = ld.RenameOU(ctx, oldOUDN, newName)
+	if err != nil {
+		return fmt.Errorf("failed to rename CEPHFS OU: %w", err)
+	}
+	slog.Debug("Renamed CEPHFS OU", "oldOUDN", oldOUDN, "newOUDN", newOUDN)
+
+	// Rename the main, admins, and Owner groups
+	for _, suffix := range []string{"", managedgroup.Cephfs.AdminsSuffix, managedgroup.Cephfs.RoleSuffix} {
+		oldGroupDN := fmt.Sprintf("CN=%s%s%s,%s", groupPrefix, cephfsName, suffix, newOUDN)
+		newGroupName := fmt.Sprintf("%s%s%s", groupPrefix, newName, suffix)
+		err = ld.RenameGroup(ctx, oldGroupDN, newGroupName)
+		if err != nil {
+			return fmt.Errorf("failed to rename CEPHFS group: %w", err)
+		}
+		slog.Debug("Renamed CEPHFS group", "oldGroupDN", oldGroupDN, "newGroupName", newGroupName)
+	}
+
+	// Rename the subgroups
+	subgroupOUDN, err := getCEPHFSSubgroupOUDN(ctx, newName)
+	if err != nil {
+		return fmt.Errorf("failed to get CEPHFS subgroup OU DN: %w", err)
+	}
+	subgroups, err := ld.GetGroupNamesInOU(ctx, subgroupOUDN, false)
+	if err != nil {
+		return fmt.Errorf("failed to get CEPHFS subgroups: %w", err)
+	}
+	for _, subgroup := range subgroups {
+		shortName := getCEPHFSSubgroupShortName(cephfsName, subgroup)
+		newSubgroupName, err := getCEPHFSSubgroupName(ctx, newName, shortName)
+		if err != nil {
+			return fmt.Errorf("failed to get CEPHFS subgroup full name: %w", err)
+		}
+		oldSubgroupDN := fmt.Sprintf("CN=%s,%s", subgroup, subgroupOUDN)
+		err = ld.RenameGroup(ctx, oldSubgroupDN, newSubgroupName)
+		if err != nil {
+			return fmt.Errorf("failed to rename CEPHFS subgroup: %w", err)
+		}
+		slog.Debug("Renamed CEPHFS subgroup", "oldSubgroupDN", oldSubgroupDN, "newSubgroupName", newSubgroupName)
+	}
+
+	return nil
+}
+
 // CephfsGetOwner returns the Owner username for the CEPHFS with the given name.
 func CephfsGetOwnerUsername(ctx context.Context, cephfsName string) (string, error) {
 	// Get the Owner username for the CEPHFS with the given name
@@ -644,13 +784,9 @@ func CephfsGetOwnerUsername(ctx context.Context, cephfsName string) (string, err
 	if cfg == nil {
 		return "", fmt.Errorf("config not found in context")
 	}
-	cephfsOwnerGroupDN, err := getCEPHFSOWNERGroupDN(ctx, cephfsName)
+	members, err := cephfsOwnerUsernames(ctx, cephfsName)
 	if err != nil {
-		return "", fmt.Errorf("failed to get CEPHFS Owner group DN: %w", err)
-	}
-	members, err := ld.GetGroupMemberUsernames(ctx, cephfsOwnerGroupDN)
-	if err != nil {
-		return "", fmt.Errorf("failed to get group members: %w", err)
+		return "", err
 	}
 	if len(members) == 0 {
 		return "", fmt.Errorf("no Owner found for CEPHFS %s", cephfsName)
@@ -661,7 +797,46 @@ func CephfsGetOwnerUsername(ctx context.Context, cephfsName string) (string, err
 	return members[0], nil
 }
 
+// cephfsOwnerUsernames returns the members of the CEPHFS Owner group, of
+// which there should be exactly one.
+func cephfsOwnerUsernames(ctx context.Context, cephfsName string) ([]string, error) {
+	cephfsOwnerGroupDN, err := getCEPHFSOWNERGroupDN(ctx, cephfsName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CEPHFS Owner group DN: %w", err)
+	}
+	members, err := ld.GetGroupMemberUsernames(ctx, cephfsOwnerGroupDN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group members: %w", err)
+	}
+	return members, nil
+}
+
+// checkOwnerEligibility checks the user against the owner_eligibility policy.
+func checkOwnerEligibility(ctx context.Context, ownerUsername string) error {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return fmt.Errorf("config not found in context")
+	}
+	ownerDN, err := getUserDN(ctx, ownerUsername)
+	if err != nil {
+		return fmt.Errorf("failed to get owner DN: %w", err)
+	}
+	return ld.CheckEligibility(ctx, ownerDN, cfg.OwnerEligibility, "owner_eligibility")
+}
+
+// CEPHFSSetOWNER makes the user the owner of the CEPHFS group, if owner_eligibility allows it.
 func CEPHFSSetOWNER(ctx context.Context, cephfsName string, ownerUsername string) error {
+	if err := checkNotFrozen(ctx, cephfsName); err != nil {
+		return err
+	}
+	err := checkOwnerEligibility(ctx, ownerUsername)
+	if err != nil {
+		return err
+	}
+	return setOwner(ctx, cephfsName, ownerUsername)
+}
+
+func setOwner(ctx context.Context, cephfsName string, ownerUsername string) error {
 	slog.Debug("Setting Owner for CEPHFS", "cephfsName", cephfsName, "ownerUsername", ownerUsername)
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
 	if cfg == nil {
@@ -721,6 +896,40 @@ func CEPHFSSetOWNER(ctx context.Context, cephfsName string, ownerUsername string
 	return nil
 }
 
+// CephfsTransferOwner hands the CEPHFS group to newOwner with CEPHFSSetOWNER,
+// which also takes the previous owner out of the owner group. With
+// demoteOld the previous owner is removed from the admins group too, but
+// stays a member. It returns the previous owner, or "" for a group that
+// had none, which newOwner is simply made the owner of.
+func CephfsTransferOwner(ctx context.Context, cephfsName string, newOwner string, demoteOld bool) (string, error) {
+	owners, err := cephfsOwnerUsernames(ctx, cephfsName)
+	if err != nil {
+		return "", fmt.Errorf("failed to get current Owner: %w", err)
+	}
+	if len(owners) > 1 {
+		return "", fmt.Errorf("failed to get current Owner: multiple Owners found for CEPHFS %s", cephfsName)
+	}
+	var previous string
+	if len(owners) == 1 {
+		previous = owners[0]
+	}
+	if previous != "" && strings.EqualFold(previous, newOwner) {
+		slog.Debug("User already Owner of CEPHFS", "cephfsName", cephfsName, "ownerUsername", newOwner)
+		return previous, nil
+	}
+	err = CEPHFSSetOWNER(ctx, cephfsName, newOwner)
+	if err != nil {
+		return previous, fmt.Errorf("failed to set Owner: %w", err)
+	}
+	if demoteOld && previous != "" {
+		err = CephfsRemoveAdmin(ctx, cephfsName, previous)
+		if err != nil {
+			return previous, fmt.Errorf("failed to remove previous Owner %s from admins: %w", previous, err)
+		}
+	}
+	return previous, nil
+}
+
 func CephfsList(ctx context.Context) ([]string, error) {
 	// List all cephfs
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
@@ -759,12 +968,30 @@ func CephfsList(ctx context.Context) ([]string, error) {
 	return cephfsShortNames, nil
 }
 
+// CephfsListAll classifies every group under the CEPHFS base DN, including
+// companion admins and owner groups, subgroups, and groups whose names don't
+// follow the conventions, which CephfsList leaves out.
+func CephfsListAll(ctx context.Context) ([]managedgroup.Classification, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	groups, err := ld.ClassifyGroupsInOU(ctx, managedgroup.Cephfs, cfg.LDAPCephfsDN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to classify cephfs groups: %w", err)
+	}
+	return groups, nil
+}
+
 // CephfsAddMember adds a member to the CEPHFS with the given name.
 func CephfsAddMember(ctx context.Context, cephfsName string, member string) error {
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
 	if cfg == nil {
 		return fmt.Errorf("config not found in context")
 	}
+	if err := checkNotFrozen(ctx, cephfsName); err != nil {
+		return err
+	}
 	cephfsDN, err := getCEPHFSDN(ctx, cephfsName)
 	if err != nil {
 		return fmt.Errorf("failed to get CEPHFS DN: %w", err)
@@ -805,16 +1032,20 @@ func CephfsAddMember(ctx context.Context, cephfsName string, member string) erro
 //
 // It will remove them from the CEPHFS group, all subgroups, the admin group, and the Owner group.
 // If the user is not a member of any other CEPHFSs, they will also be removed from the top level users and admins groups.
+// If the user was not a member, it changes nothing and returns ld.ErrNotMember, wrapped.
 func CephfsRemoveMember(ctx context.Context, name string, member string) error {
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
 	if cfg == nil {
 		return fmt.Errorf("config not found in context")
 	}
+	if err := checkNotFrozen(ctx, name); err != nil {
+		return err
+	}
 	cephfsDN, err := getCEPHFSDN(ctx, name)
 	if err != nil {
 		return fmt.Errorf("failed to get CEPHFS DN: %w", err)
 	}
-	userDN, err := getUserDN(ctx, member)
+	userDN, _, err := ld.ResolveMemberDN(ctx, cephfsDN, member)
 	if err != nil {
 		return fmt.Errorf("failed to get user DN: %w", err)
 	}
@@ -826,7 +1057,7 @@ func CephfsRemoveMember(ctx context.Context, name string, member string) error {
 	}
 	if !inGroup {
 		slog.Debug("User not in CEPHFS", "userDN", userDN, "cephfsDN", cephfsDN)
-		return nil
+		return fmt.Errorf("%s %w of CEPHFS %s", member, ld.ErrNotMember, name)
 	}
 
 	// Check if the user is the Owner of the CEPHFS
@@ -944,6 +1175,105 @@ func CephfsRemoveMember(ctx context.Context, name string, member string) error {
 	return nil
 }
 
+// CephfsAddMemberDN adds the entry at memberDN to the CEPHFS with the given name,
+// without looking it up by username. Only user accounts are added to
+// the top level users group. Other objects, such as service accounts, are
+// only added to the CEPHFS group.
+func CephfsAddMemberDN(ctx context.Context, cephfsName string, memberDN string) error {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return fmt.Errorf("config not found in context")
+	}
+	if err := checkNotFrozen(ctx, cephfsName); err != nil {
+		return err
+	}
+	cephfsDN, err := getCEPHFSDN(ctx, cephfsName)
+	if err != nil {
+		return fmt.Errorf("failed to get CEPHFS DN: %w", err)
+	}
+	entry, err := ld.GetMemberEntry(ctx, memberDN)
+	if err != nil {
+		return fmt.Errorf("failed to look up member: %w", err)
+	}
+
+	inGroup, err := ld.UserInGroup(ctx, cephfsDN, entry.DN)
+	if err != nil {
+		return fmt.Errorf("failed to check if user is in group: %w", err)
+	}
+	if inGroup {
+		slog.Debug("Member already in CEPHFS", "memberDN", entry.DN, "cephfsDN", cephfsDN)
+		return nil
+	}
+
+	slog.Debug("Adding member to CEPHFS", "memberDN", entry.DN, "cephfsDN", cephfsDN)
+	err = ld.AddUserToGroup(ctx, cephfsDN, entry.DN)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to CEPHFS %s: %w", entry.DN, cephfsName, err)
+	}
+	if !entry.IsUser {
+		slog.Debug("Not a user account, skipping top level users group", "memberDN", entry.DN)
+		return nil
+	}
+	err = addUserDNToTopLevelUsersGroup(ctx, entry.Username, entry.DN)
+	if err != nil {
+		return fmt.Errorf("failed to add user %s to top level users group: %w", entry.Username, err)
+	}
+	return nil
+}
+
+// CephfsRemoveMemberDN removes the entry at memberDN from the CEPHFS with the
+// given name. User accounts are removed by username through
+// CephfsRemoveMember, which also handles the subgroups, admins, owner check, and top level groups. Other objects are
+// only removed from the CEPHFS group, unless they are in the Owner group. A DN that no longer resolves is removed as given.
+func CephfsRemoveMemberDN(ctx context.Context, name string, memberDN string) error {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return fmt.Errorf("config not found in context")
+	}
+	if err := checkNotFrozen(ctx, name); err != nil {
+		return err
+	}
+	entry, err := ld.GetRemovableMemberEntry(ctx, memberDN)
+	if err != nil {
+		return fmt.Errorf("failed to look up member: %w", err)
+	}
+	if entry.IsUser {
+		return CephfsRemoveMember(ctx, name, entry.Username)
+	}
+	cephfsDN, err := getCEPHFSDN(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get CEPHFS DN: %w", err)
+	}
+
+	inGroup, err := ld.UserInGroup(ctx, cephfsDN, entry.DN)
+	if err != nil {
+		return fmt.Errorf("failed to check if user is in group: %w", err)
+	}
+	if !inGroup {
+		slog.Debug("Member not in CEPHFS", "memberDN", entry.DN, "cephfsDN", cephfsDN)
+		return fmt.Errorf("%s %w of CEPHFS %s", entry.DN, ld.ErrNotMember, name)
+	}
+
+	// Like the username path, refuse to remove the Owner
+	roleGroupDN, err := getCEPHFSOWNERGroupDN(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get CEPHFS Owner group DN: %w", err)
+	}
+	isRole, err := ld.UserInGroup(ctx, roleGroupDN, entry.DN)
+	if err != nil {
+		return fmt.Errorf("failed to check if user is in group: %w", err)
+	}
+	if isRole {
+		return fmt.Errorf("%s is the Owner of CEPHFS %s, cannot remove without setting a new Owner: %w", entry.DN, name, ld.ErrPolicy)
+	}
+	err = ld.RemoveUserFromGroup(ctx, cephfsDN, entry.DN)
+	if err != nil {
+		return fmt.Errorf("failed to remove %s from CEPHFS %s: %w", entry.DN, name, err)
+	}
+	slog.Debug("Removed member from CEPHFS", "memberDN", entry.DN, "cephfsDN", cephfsDN)
+	return nil
+}
+
 func CephfsListMemberUsernames(ctx context.Context, name string) ([]string, error) {
 	// List all members of the CEPHFS with the given name
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
@@ -981,6 +1311,24 @@ func CephfsListMemberDNs(ctx context.Context, name string) ([]string, error) {
 	return members, nil
 }
 
+// CephfsCountMembers returns how many members the CEPHFS group has, without
+// looking up their usernames.
+func CephfsCountMembers(ctx context.Context, name string) (int, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return 0, fmt.Errorf("config not found in context")
+	}
+	cephfsDN, err := getCEPHFSDN(ctx, name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get CEPHFS DN: %w", err)
+	}
+	count, err := ld.CountGroupMembers(ctx, cephfsDN)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count group members: %w", err)
+	}
+	return count, nil
+}
+
 // CephfsListAdminUsernames lists all admin usernames of the CEPHFS with the given name.
 func CephfsListAdminUsernames(ctx context.Context, name string) ([]string, error) {
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
@@ -999,12 +1347,47 @@ func CephfsListAdminUsernames(ctx context.Context, name string) ([]string, error
 	return admins, nil
 }
 
+// CephfsListAdminDNs lists all admin DNs of the CEPHFS with the given name.
+func CephfsListAdminDNs(ctx context.Context, name string) ([]string, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	cephfsDN, err := getCEPHFSAdminsGroupDN(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CEPHFS DN: %w", err)
+	}
+	admins, err := ld.GetGroupMemberDNs(ctx, cephfsDN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group members: %w", err)
+	}
+	slices.Sort(admins)
+	return admins, nil
+}
+
+// CephfsListTopLevelAdminUsernames lists all members of the top level CEPHFS admins group.
+func CephfsListTopLevelAdminUsernames(ctx context.Context) ([]string, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	admins, err := ld.GetGroupMemberUsernames(ctx, cfg.CephfsAdminsGroupDN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group members: %w", err)
+	}
+	slices.Sort(admins)
+	return admins, nil
+}
+
 // CephfsAddAdmin adds an admin to the CEPHFS with the given name.
 func CephfsAddAdmin(ctx context.Context, cephfsName string, adminUsername string) error {
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
 	if cfg == nil {
 		return fmt.Errorf("config not found in context")
 	}
+	if err := checkNotFrozen(ctx, cephfsName); err != nil {
+		return err
+	}
 	adminGroupDN, err := getCEPHFSAdminsGroupDN(ctx, cephfsName)
 	if err != nil {
 		return fmt.Errorf("failed to get CEPHFS admin group DN: %w", err)
@@ -1021,7 +1404,7 @@ func CephfsAddAdmin(ctx context.Context, cephfsName string, adminUsername string
 	}
 	if !found {
 		slog.Debug("CEPHFS not found", "name", cephfsName)
-		return fmt.Errorf("CEPHFS %s not found", cephfsName)
+		return fmt.Errorf("CEPHFS %s %w", cephfsName, ld.ErrNotFound)
 	}
 
 	// Check if the user is a member of the CEPHFS group
@@ -1067,11 +1450,14 @@ func CephfsRemoveAdmin(ctx context.Context, cephfsName string, adminUsername str
 	if cfg == nil {
 		return fmt.Errorf("config not found in context")
 	}
+	if err := checkNotFrozen(ctx, cephfsName); err != nil {
+		return err
+	}
 	adminGroupDN, err := getCEPHFSAdminsGroupDN(ctx, cephfsName)
 	if err != nil { 
 		return fmt.Errorf("failed to get CEPHFS admin group DN: %w", err)
 	}
-	userDN, err := getUserDN(ctx, adminUsername)
+	userDN, fallback, err := ld.ResolveMemberDN(ctx, adminGroupDN, adminUsername)
 	if err != nil {
 		return fmt.Errorf("failed to get user DN: %w", err)
 	}
@@ -1093,6 +1479,11 @@ func CephfsRemoveAdmin(ctx context.Context, cephfsName string, adminUsername str
 	}
 	slog.Debug("Removed admin from CEPHFS", "userDN", userDN, "cephfsDN", adminGroupDN)
 
+	// A user missing from AD can't be looked up to clean up the top level groups
+	if fallback {
+		return nil
+	}
+
 	// Remove the user from the top level admins if they are not an admin of any other CEPHFS
 	isAdminInAnotherCEPHFS, err := userIsAdminInAnyCEPHFS(ctx, adminUsername)
 	if err != nil {
@@ -1151,11 +1542,18 @@ func CephfsSubgroupList(ctx context.Context, cephfsName string) ([]string, error
 }
 
 // CephfsSubgroupCreate creates a new subgroup under the CEPHFS with the given name.
-func CephfsSubgroupCreate(ctx context.Context, cephfsName string, subgroupName string) error {
+// description is stored on the group and may be empty unless require_subgroup_description is set.
+func CephfsSubgroupCreate(ctx context.Context, cephfsName string, subgroupName string, description string) error {
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
 	if cfg == nil {
 		return fmt.Errorf("config not found in context")
 	}
+	if err := checkNotFrozen(ctx, cephfsName); err != nil {
+		return err
+	}
+	if err := ld.ValidateSubgroupDescription(ctx, description); err != nil {
+		return err
+	}
 	subgroupDN, err := getCEPHFSSubgroupDN(ctx, cephfsName, subgroupName)
 	if err != nil {
 		return fmt.Errorf("failed to get CEPHFS subgroup DN: %w", err)
@@ -1171,11 +1569,11 @@ func CephfsSubgroupCreate(ctx context.Context, cephfsName string, subgroupName s
 	}
 
 	// Create the subgroup object inside the CEPHFS OU/Groups
-	gidNumber, err := ld.GetNextGidNumber(ctx)
+	gidNumber, err := ld.AllocateGids(ctx, "cephfs", 1)
 	if err != nil {
 		return fmt.Errorf("failed to get next GID number: %w", err)
 	}
-	err = ld.CreateGroup(ctx, subgroupOUDN, subgroupFullName, gidNumber)
+	err = ld.CreateGroup(ctx, subgroupOUDN, subgroupFullName, gidNumber, ld.WithDescription(description))
 	if err != nil {
 		return fmt.Errorf("failed to create CEPHFS subgroup object: %w", err)
 	}
@@ -1185,35 +1583,56 @@ func CephfsSubgroupCreate(ctx context.Context, cephfsName string, subgroupName s
 }
 
 // CephfsSubgroupDelete deletes the subgroup with the given name under the CEPHFS groups OU.
-// If the subgroup is found, it returns true and nil.
-func CephfsSubgroupDelete(ctx context.Context, cephfsName string, subgroupName string) error {
+// It will error if the subgroup still has members, unless force is set,
+// in which case the members are removed first and their usernames returned.
+func CephfsSubgroupDelete(ctx context.Context, cephfsName string, subgroupName string, force bool) ([]string, error) {
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
 	if cfg == nil {
-		return fmt.Errorf("config not found in context")
+		return nil, fmt.Errorf("config not found in context")
+	}
+	if err := checkNotFrozen(ctx, cephfsName); err != nil {
+		return nil, err
 	}
 	subgroupDN, err := getCEPHFSSubgroupDN(ctx, cephfsName, subgroupName)
 	if err != nil {
-		return fmt.Errorf("failed to get CEPHFS subgroup DN: %w", err)
+		return nil, fmt.Errorf("failed to get CEPHFS subgroup DN: %w", err)
 	}
 
 	// Check if the subgroup exists
 	exists, err := ld.DNExists(ctx, subgroupDN)
 	if err != nil {
-		return fmt.Errorf("failed to check if group exists: %w", err)
+		return nil, fmt.Errorf("failed to check if group exists: %w", err)
 	}
 	if !exists {
 		slog.Debug("CEPHFS subgroup does not exist", "subgroupDN", subgroupDN)
-		return nil
+		return nil, nil
+	}
+
+	// Check if the subgroup has members
+	members, err := CephfsSubgroupListMemberUsernames(ctx, cephfsName, subgroupName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group members: %w", err)
+	}
+	if len(members) > 0 && !force {
+		return nil, fmt.Errorf("CEPHFS subgroup %s has %d members, cannot delete without force: %w", subgroupName, len(members), ld.ErrNotEmpty)
+	}
+	var removed []string
+	for _, member := range members {
+		err = CephfsSubgroupRemoveMember(ctx, cephfsName, subgroupName, member)
+		if err != nil {
+			return removed, fmt.Errorf("failed to remove member %s from CEPHFS subgroup %s: %w", member, subgroupName, err)
+		}
+		removed = append(removed, member)
 	}
 
 	// Delete the subgroup object
 	err = ld.DeleteGroup(ctx, subgroupDN)
 	if err != nil {
-		return fmt.Errorf("failed to delete CEPHFS subgroup object: %w", err)
+		return removed, fmt.Errorf("failed to delete CEPHFS subgroup object: %w", err)
 	}
 	slog.Debug("Deleted CEPHFS subgroup object", "subgroupDN", subgroupDN)
 
-	return nil
+	return removed, nil
 }
 
 // CephfsSubgroupListMemberUsernames lists all members of the subgroup with the given name under the CEPHFS.
@@ -1286,6 +1705,9 @@ func CephfsSubgroupAddMember(ctx context.Context, cephfsName string, subgroupNam
 	if cfg == nil {
 		return fmt.Errorf("config not found in context")
 	}
+	if err := checkNotFrozen(ctx, cephfsName); err != nil {
+		return err
+	}
 	subgroupDN, err := getCEPHFSSubgroupDN(ctx, cephfsName, subgroupName)
 	if err != nil {
 		return fmt.Errorf("failed to get CEPHFS subgroup DN: %w", err)
@@ -1321,11 +1743,14 @@ func CephfsSubgroupRemoveMember(ctx context.Context, cephfsName string, subgroup
 	if cfg == nil {
 		return fmt.Errorf("config not found in context")
 	}
+	if err := checkNotFrozen(ctx, cephfsName); err != nil {
+		return err
+	}
 	subgroupDN, err := getCEPHFSSubgroupDN(ctx, cephfsName, subgroupName)
 	if err != nil {
 		return fmt.Errorf("failed to get CEPHFS subgroup DN: %w", err)
 	}
-	userDN, err := getUserDN(ctx, memberUsername)
+	userDN, _, err := ld.ResolveMemberDN(ctx, subgroupDN, memberUsername)
 	if err != nil {
 		return fmt.Errorf("failed to get user DN: %w", err)
 	}
@@ -1388,3 +1813,58 @@ func CephfsSubgroupListDNs(ctx context.Context, cephfsName string) ([]string, er
 	slices.Sort(subgroups)
 	return subgroups, nil
 }
+
+// CephfsCheck checks the CEPHFS with the given name for inconsistencies, such as
+// an owner missing from the admins group or subgroup members who aren't
+// members of the CEPHFS.
+func CephfsCheck(ctx context.Context, name string) ([]consistency.Problem, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	mainDN, err := getCEPHFSDN(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CEPHFS DN: %w", err)
+	}
+	adminsDN, err := getCEPHFSAdminsGroupDN(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CEPHFS admins group DN: %w", err)
+	}
+	ownerDN, err := getCEPHFSOWNERGroupDN(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CEPHFS owner group DN: %w", err)
+	}
+	subgroupDNs, err := CephfsSubgroupListDNs(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CEPHFS subgroups: %w", err)
+	}
+	fullName, err := getCEPHFSFullName(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CEPHFS full name: %w", err)
+	}
+	return consistency.Check(ctx, consistency.Group{
+		Name:          fullName,
+		MainDN:        mainDN,
+		AdminsDN:      adminsDN,
+		RoleDN:        ownerDN,
+		RoleName:      "owner",
+		SubgroupDNs:   subgroupDNs,
+		// Admins here have always been repaired by adding them as members
+		StrayAdminFix: consistency.StrayAdminAddToMain,
+	})
+}
+
+// CephfsDNs returns the DNs of the main, admins and owner groups and every
+// subgroup of the CEPHFS with the given name, as found in the directory.
+// Missing groups have an empty DN.
+func CephfsDNs(ctx context.Context, name string) ([]managedgroup.Component, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	components, err := ld.ResolveGroupComponents(ctx, managedgroup.Cephfs, cfg.LDAPCephfsDN, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve CEPHFS group DNs: %w", err)
+	}
+	return components, nil
+}
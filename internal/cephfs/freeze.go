@@ -0,0 +1,79 @@
+package cephfs
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+)
+
+// checkNotFrozen refuses changes to a frozen CEPHFS, unless overridden.
+func checkNotFrozen(ctx context.Context, name string) error {
+	groupDN, err := getCEPHFSDN(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get CEPHFS DN: %w", err)
+	}
+	return ld.CheckNotFrozen(ctx, groupDN)
+}
+
+// CephfsFreeze freezes the CEPHFS with the given name, so changes to it are
+// refused until CephfsUnfreeze.
+func CephfsFreeze(ctx context.Context, name string, f ld.Freeze) error {
+	groupDN, err := getCEPHFSDN(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get CEPHFS DN: %w", err)
+	}
+	return ld.FreezeGroup(ctx, groupDN, f)
+}
+
+// CephfsUnfreeze lets changes to the CEPHFS with the given name through again.
+func CephfsUnfreeze(ctx context.Context, name string) error {
+	groupDN, err := getCEPHFSDN(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get CEPHFS DN: %w", err)
+	}
+	return ld.UnfreezeGroup(ctx, groupDN)
+}
+
+// CephfsGetFreeze returns the freeze on the CEPHFS with the given name, if it
+// is frozen.
+func CephfsGetFreeze(ctx context.Context, name string) (ld.Freeze, bool, error) {
+	groupDN, err := getCEPHFSDN(ctx, name)
+	if err != nil {
+		return ld.Freeze{}, false, fmt.Errorf("failed to get CEPHFS DN: %w", err)
+	}
+	return ld.GetFreeze(ctx, groupDN)
+}
+
+// CephfsListFrozen returns the freeze of every frozen CEPHFS, keyed by short
+// name.
+func CephfsListFrozen(ctx context.Context) (map[string]ld.Freeze, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	groups, err := ld.GetFrozenGroupsInOU(ctx, cfg.LDAPCephfsDN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get frozen CEPHFS groups: %w", err)
+	}
+	nameRegex, err := cephfsGroupNameRegex(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CEPHFS group name regex: %w", err)
+	}
+	re := regexp.MustCompile(nameRegex)
+	frozen := make(map[string]ld.Freeze, len(groups))
+	for groupName, f := range groups {
+		if !re.MatchString(groupName) {
+			continue
+		}
+		shortName, err := ConvertCEPHGroupNametoShortName(groupName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert CEPHFS group name to short name: %w", err)
+		}
+		frozen[shortName] = f
+	}
+	return frozen, nil
+}
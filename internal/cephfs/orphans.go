@@ -0,0 +1,43 @@
+package cephfs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/managedgroup"
+)
+
+// CephfsListOrphans returns the OUs under the CEPHFS base DN with no CEPHFS
+// group in them, with any groups left inside.
+func CephfsListOrphans(ctx context.Context) ([]ld.OrphanOU, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	orphans, err := ld.FindOrphanOUs(ctx, managedgroup.Cephfs, cfg.LDAPCephfsDN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find orphan CEPHFS OUs: %w", err)
+	}
+	return orphans, nil
+}
+
+// CephfsDeleteOrphan deletes the OU of the CEPHFS with the given name, which
+// must have no CEPHFS group, refusing with ld.ErrNotEmpty if anything but
+// OUs is left inside.
+func CephfsDeleteOrphan(ctx context.Context, name string) error {
+	dn, found, err := findCEPHFSDN(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to find CEPHFS DN: %w", err)
+	}
+	if found {
+		return fmt.Errorf("CEPHFS %s exists at %s, its OU is not an orphan", name, dn)
+	}
+	ouDN, err := getCEPHFSOUDN(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get CEPHFS OU DN: %w", err)
+	}
+	return ld.DeleteEmptyOU(ctx, ouDN)
+}
@@ -0,0 +1,117 @@
+package cephfs
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/ldap/ldaptest"
+)
+
+// cephfsTestContext returns a context using the directory and config the
+// root package's golden tests use, with cephfs group lab owned by carol and
+// bob as a member.
+func cephfsTestContext(t *testing.T) context.Context {
+	t.Helper()
+	dir, err := ldaptest.Load(filepath.Join("..", "..", "testdata", "directory.ldif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.GetConfig(filepath.Join("..", "..", "testdata", "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := ld.WithClient(context.WithValue(context.Background(), keys.ConfigKey, cfg), dir)
+	if err := CephfsCreate(ctx, "lab", "carol"); err != nil {
+		t.Fatal(err)
+	}
+	if err := CephfsAddMember(ctx, "lab", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	return ctx
+}
+
+func TestCephfsSubgroupDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		members     []string
+		force       bool
+		wantErr     error
+		wantRemoved []string
+	}{
+		{name: "empty"},
+		{name: "populated", members: []string{"bob", "carol"}, wantErr: ld.ErrNotEmpty},
+		{name: "populated forced", members: []string{"bob", "carol"}, force: true, wantRemoved: []string{"bob", "carol"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := cephfsTestContext(t)
+			if err := CephfsSubgroupCreate(ctx, "lab", "scratch", ""); err != nil {
+				t.Fatal(err)
+			}
+			for _, member := range tc.members {
+				if err := CephfsSubgroupAddMember(ctx, "lab", "scratch", member); err != nil {
+					t.Fatal(err)
+				}
+			}
+			removed, err := CephfsSubgroupDelete(ctx, "lab", "scratch", tc.force)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("CephfsSubgroupDelete() = %v, want %v", err, tc.wantErr)
+			}
+			if !slices.Equal(removed, tc.wantRemoved) {
+				t.Errorf("removed = %q, want %q", removed, tc.wantRemoved)
+			}
+			exists, err := CephfsSubgroupExists(ctx, "lab", "scratch")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if exists != (tc.wantErr != nil) {
+				t.Errorf("subgroup exists = %v after CephfsSubgroupDelete() = %v", exists, err)
+			}
+			if tc.wantErr == nil {
+				return
+			}
+			got, err := CephfsSubgroupListMemberUsernames(ctx, "lab", "scratch")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tc.members) {
+				t.Errorf("members after a refused delete = %q, want %q", got, tc.members)
+			}
+		})
+	}
+}
+
+// TestCephfsSubgroupDeleteNotGroup checks that a subgroup delete refuses to
+// delete a user that sits where the subgroup would be.
+func TestCephfsSubgroupDeleteNotGroup(t *testing.T) {
+	ctx := cephfsTestContext(t)
+	dn, err := getCEPHFSSubgroupDN(ctx, "lab", "scratch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := ld.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := ldap.NewAddRequest(dn, nil)
+	req.Attribute("objectClass", []string{"top", "person", "organizationalPerson", "user"})
+	req.Attribute("objectCategory", []string{"person"})
+	if err := l.Add(req); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = CephfsSubgroupDelete(ctx, "lab", "scratch", true)
+	if !errors.Is(err, ld.ErrNotGroup) {
+		t.Fatalf("CephfsSubgroupDelete() = %v, want %v", err, ld.ErrNotGroup)
+	}
+	if exists, err := ld.DNExists(ctx, dn); err != nil || !exists {
+		t.Errorf("user at the subgroup DN deleted: exists = %v, %v", exists, err)
+	}
+}
@@ -9,16 +9,16 @@ import (
 	"strings"
 
 	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/consistency"
 	"github.com/uoracs/directory-manager/internal/keys"
 	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/managedgroup"
 )
 
 var (
-	err                   error
-	found                 bool
-	groupPrefix           = "is.racs.cephs3."
-	topLevelUsersGroupDN  = "CN=IS.RACS.Talapas.Users,OU=RACS,OU=Groups,OU=IS,OU=Units,DC=ad,DC=uoregon,DC=edu"
-	topLevelAdminsGroupDN = "CN=IS.RACS.Talapas.CephS3Admins,OU=RACS,OU=Groups,OU=IS,OU=Units,DC=ad,DC=uoregon,DC=edu"
+	err         error
+	found       bool
+	groupPrefix = managedgroup.Cephs3.Prefix
 )
 
 func ConvertCEPHGroupNametoShortName(cephs3Name string) (string, error) {
@@ -63,7 +63,7 @@ func getcephs3AdminsGroupFullName(ctx context.Context, cephs3Name string) (strin
 	if cfg == nil {
 		return "", fmt.Errorf("config not found in context")
 	}
-	n := fmt.Sprintf("%s%s.admins", groupPrefix, cephs3Name)
+	n := groupPrefix + cephs3Name + managedgroup.Cephs3.AdminsSuffix
 	slog.Debug("cephs3 admins group full name", "name", n)
 	return n, nil
 }
@@ -74,7 +74,7 @@ func getcephs3OWNERGroupFullName(ctx context.Context, cephs3Name string) (string
 	if cfg == nil {
 		return "", fmt.Errorf("config not found in context")
 	}
-	n := fmt.Sprintf("%s%s.owner", groupPrefix, cephs3Name)
+	n := groupPrefix + cephs3Name + managedgroup.Cephs3.RoleSuffix
 	slog.Debug("cephs3 OWNER group full name", "name", n)
 	return n, nil
 }
@@ -86,7 +86,7 @@ func getUserDN(ctx context.Context, name string) (string, error) {
 		return "", fmt.Errorf("failed to get user DN: %w", err)
 	}
 	if dn == "" {
-		return "", fmt.Errorf("user %s not found", name)
+		return "", fmt.Errorf("user %s %w", name, ld.ErrNotFound)
 	}
 	slog.Debug("User DN", "dn", dn)
 	return dn, nil
@@ -103,7 +103,7 @@ func getcephs3SubgroupOUDN(ctx context.Context, cephs3Name string) (string, erro
 	if err != nil {
 		return "", fmt.Errorf("failed to get cephs3 DN: %w", err)
 	}
-	n := fmt.Sprintf("OU=Groups,%s", cephs3DN)
+	n := fmt.Sprintf("OU=%s,%s", managedgroup.Cephs3.SubgroupOU, cephs3DN)
 	slog.Debug("cephs3 subgroup OU DN", "dn", n)
 	return n, nil
 }
@@ -148,11 +148,15 @@ func getcephs3DN(ctx context.Context, name string) (string, error) {
 // if not found, it returns an empty string, false, and nil
 func findcephs3DN(ctx context.Context, name string) (string, bool, error) {
 	slog.Debug("Finding cephs3 DN", "name", name)
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return "", false, fmt.Errorf("config not found in context")
+	}
 	groupName, err := getcephs3FullName(ctx, name)
 	if err != nil {
 		return "", false, fmt.Errorf("failed to get cephs3 full name: %w", err)
 	}
-	dn, found, err := ld.GetGroupDN(ctx, groupName)
+	dn, found, err := ld.GetGroupDN(ctx, cfg.LDAPCephs3DN, groupName)
 	if !found && err == nil {
 		slog.Debug("cephs3 not found", "name", name)
 		return "", false, nil
@@ -281,7 +285,7 @@ func getAllcephs3DNs(ctx context.Context) ([]string, error) {
 	for _, groupName := range allGroupNamesIncephs3sOU {
 		slog.Debug("Checking group name", "groupName", groupName)
 		if matched, _ := regexp.MatchString(cephs3GroupNameRegex, groupName); matched {
-			cephs3DN, found, err := ld.GetGroupDN(ctx, groupName)
+			cephs3DN, found, err := ld.GetGroupDN(ctx, cfg.LDAPCephs3DN, groupName)
 			if err != nil {
 				return nil, fmt.Errorf("failed to get group DN: %w", err)
 			}
@@ -297,23 +301,29 @@ func getAllcephs3DNs(ctx context.Context) ([]string, error) {
 // addUserToTopLevelUsersGroup adds a user to the top level users group.
 func addUserToTopLevelUsersGroup(ctx context.Context, member string) error {
 	slog.Debug("Adding user to top level users group", "member", member)
-	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
-	if cfg == nil {
-		return fmt.Errorf("config not found in context")
-	}
 	userDN, err := getUserDN(ctx, member)
 	if err != nil {
 		return fmt.Errorf("failed to get user DN: %w", err)
 	}
-	inGroup, err := ld.UserInGroup(ctx, topLevelUsersGroupDN, userDN)
+	return addUserDNToTopLevelUsersGroup(ctx, member, userDN)
+}
+
+// addUserDNToTopLevelUsersGroup is addUserToTopLevelUsersGroup for a user
+// whose DN is already known.
+func addUserDNToTopLevelUsersGroup(ctx context.Context, member string, userDN string) error {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return fmt.Errorf("config not found in context")
+	}
+	inGroup, err := ld.UserInGroup(ctx, cfg.UsersGroupDN, userDN)
 	if err != nil {
 		return fmt.Errorf("failed to check if user is in group: %w", err)
 	}
 	if inGroup {
-		slog.Debug("User already in top level users group", "userDN", userDN, "topLevelUsersGroupDN", topLevelUsersGroupDN)
+		slog.Debug("User already in top level users group", "userDN", userDN, "topLevelUsersGroupDN", cfg.UsersGroupDN)
 		return nil
 	}
-	err = ld.AddUserToGroup(ctx, topLevelUsersGroupDN, userDN)
+	err = ld.AddUserToGroup(ctx, cfg.UsersGroupDN, userDN)
 	if err != nil {
 		return fmt.Errorf("failed to add user %s to users group: %w", member, err)
 	}
@@ -332,15 +342,15 @@ func addUsertoTopLevelAdminsGroup(ctx context.Context, member string) error {
 	if err != nil {
 		return fmt.Errorf("failed to get user DN: %w", err)
 	}
-	inGroup, err := ld.UserInGroup(ctx, topLevelAdminsGroupDN, userDN)
+	inGroup, err := ld.UserInGroup(ctx, cfg.Cephs3AdminsGroupDN, userDN)
 	if err != nil {
 		return fmt.Errorf("failed to check if user is in group: %w", err)
 	}
 	if inGroup {
-		slog.Debug("User already in top level admins group", "userDN", userDN, "topLevelAdminsGroupDN", topLevelAdminsGroupDN)
+		slog.Debug("User already in top level admins group", "userDN", userDN, "topLevelAdminsGroupDN", cfg.Cephs3AdminsGroupDN)
 		return nil
 	}
-	err = ld.AddUserToGroup(ctx, topLevelAdminsGroupDN, userDN)
+	err = ld.AddUserToGroup(ctx, cfg.Cephs3AdminsGroupDN, userDN)
 	if err != nil {
 		return fmt.Errorf("failed to add user %s to admins group: %w", member, err)
 	}
@@ -359,15 +369,15 @@ func removeUserFromTopLevelUsersGroup(ctx context.Context, member string) error
 	if err != nil {
 		return fmt.Errorf("failed to get user DN: %w", err)
 	}
-	inGroup, err := ld.UserInGroup(ctx, topLevelUsersGroupDN, userDN)
+	inGroup, err := ld.UserInGroup(ctx, cfg.UsersGroupDN, userDN)
 	if err != nil {
 		return fmt.Errorf("failed to check if user is in group: %w", err)
 	}
 	if !inGroup {
-		slog.Debug("User not in top level users group", "userDN", userDN, "topLevelUsersGroupDN", topLevelUsersGroupDN)
+		slog.Debug("User not in top level users group", "userDN", userDN, "topLevelUsersGroupDN", cfg.UsersGroupDN)
 		return nil
 	}
-	err = ld.RemoveUserFromGroup(ctx, topLevelUsersGroupDN, userDN)
+	err = ld.RemoveUserFromGroup(ctx, cfg.UsersGroupDN, userDN)
 	if err != nil {
 		return fmt.Errorf("failed to remove user %s from users group: %w", member, err)
 	}
@@ -386,15 +396,15 @@ func removeUserFromTopLevelAdminsGroup(ctx context.Context, member string) error
 	if err != nil {
 		return fmt.Errorf("failed to get user DN: %w", err)
 	}
-	inGroup, err := ld.UserInGroup(ctx, topLevelAdminsGroupDN, userDN)
+	inGroup, err := ld.UserInGroup(ctx, cfg.Cephs3AdminsGroupDN, userDN)
 	if err != nil {
 		return fmt.Errorf("failed to check if user is in group: %w", err)
 	}
 	if !inGroup {
-		slog.Debug("User not in top level admins group", "userDN", userDN, "topLevelAdminsGroupDN", topLevelAdminsGroupDN)
+		slog.Debug("User not in top level admins group", "userDN", userDN, "topLevelAdminsGroupDN", cfg.Cephs3AdminsGroupDN)
 		return nil
 	}
-	err = ld.RemoveUserFromGroup(ctx, topLevelAdminsGroupDN, userDN)
+	err = ld.RemoveUserFromGroup(ctx, cfg.Cephs3AdminsGroupDN, userDN)
 	if err != nil {
 		return fmt.Errorf("failed to remove user %s from admins group: %w", member, err)
 	}
@@ -402,33 +412,51 @@ func removeUserFromTopLevelAdminsGroup(ctx context.Context, member string) error
 	return nil
 }
 
-// userInAnycephs3 checks if the user is in any cephs3.
-func userInAnycephs3(ctx context.Context, username string) (bool, error) {
-	slog.Debug("Checking if user is in any cephs3", "username", username)
+// ListUserCephs3 returns the short names of the cephs3 groups the user is a member of,
+// derived from their memberOf entries. Admins, owner, and subgroups are not counted.
+func ListUserCephs3(ctx context.Context, username string) ([]string, error) {
+	slog.Debug("Listing cephs3 groups for user", "username", username)
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
 	if cfg == nil {
-		return false, fmt.Errorf("config not found in context")
+		return nil, fmt.Errorf("config not found in context")
 	}
 	userDN, err := getUserDN(ctx, username)
 	if err != nil {
-		return false, fmt.Errorf("failed to get user DN: %w", err)
+		return nil, fmt.Errorf("failed to get user DN: %w", err)
 	}
 	userGroups, err := ld.GetGroupsForUser(ctx, userDN)
 	if err != nil {
-		return false, fmt.Errorf("failed to get user groups: %w", err)
+		return nil, fmt.Errorf("failed to get user groups: %w", err)
 	}
+	var names []string
 	for _, groupDN := range userGroups {
 		groupName, err := ld.ConvertDNToObjectName(groupDN)
 		if err != nil {
-			return false, fmt.Errorf("failed to convert DN to object name: %w", err)
+			return nil, fmt.Errorf("failed to convert DN to object name: %w", err)
 		}
-		if strings.HasPrefix(groupName, groupPrefix) {
-			slog.Debug("User found in some cephs3", "userDN", userDN, "groupDN", groupDN)
-			return true, nil
+		namespace, ok := ld.ClassifyGroupName(groupName)
+		if !ok || namespace != "cephs3" {
+			continue
+		}
+		name := strings.TrimPrefix(strings.ToLower(groupName), groupPrefix)
+		if strings.Contains(name, ".") {
+			// this is admins, owner, or a subgroup
+			continue
 		}
+		names = append(names, name)
 	}
-	slog.Debug("User not found in any cephs3 group")
-	return false, nil
+	slices.Sort(names)
+	slog.Debug("User cephs3 groups", "username", username, "cephs3", names)
+	return names, nil
+}
+
+// userInAnycephs3 checks if the user is in any cephs3.
+func userInAnycephs3(ctx context.Context, username string) (bool, error) {
+	names, err := ListUserCephs3(ctx, username)
+	if err != nil {
+		return false, err
+	}
+	return len(names) > 0, nil
 }
 
 // userIsAdminInAnycephs3 checks if the user is an admin in any cephs3.
@@ -513,7 +541,23 @@ func Cephs3Create(ctx context.Context, cephs3Name string, ownerUsername string)
 		return fmt.Errorf("failed to find cephs3 DN: %w", err)
 	}
 
-	gidNumber, err := ld.GetNextGidNumber(ctx)
+	// Check the owner before creating anything
+	err = checkOwnerEligibility(ctx, ownerUsername)
+	if err != nil {
+		return err
+	}
+
+	// Reuse an empty OU left by an earlier failed create
+	ouDN, err := getcephs3OUDN(ctx, cephs3Name)
+	if err != nil {
+		return fmt.Errorf("failed to get cephs3 OU DN: %w", err)
+	}
+	err = ld.AdoptOrphanOU(ctx, ouDN, "cephs3 orphans clean")
+	if err != nil {
+		return err
+	}
+
+	gidNumber, err := ld.AllocateGids(ctx, "cephs3", 3)
 	if err != nil {
 		return fmt.Errorf("failed to get next GID number: %w", err)
 	}
@@ -578,7 +622,7 @@ func Cephs3Create(ctx context.Context, cephs3Name string, ownerUsername string)
 	slog.Debug("Created cephs3 OWNER group object", "cephs3OwnerGroupName", cephs3OwnerGroupFullName)
 
 	// Add the Owner to the cephs3 Owner group
-	err = Cephs3SetOWNER(ctx, cephs3Name, ownerUsername)
+	err = setOwner(ctx, cephs3Name, ownerUsername)
 	if err != nil {
 		return fmt.Errorf("failed to add Owner user %s to cephs3 Owner group %s: %w", ownerUsername, cephs3Name, err)
 	}
@@ -635,6 +679,98 @@ func Cephs3Delete(ctx context.Context, cephs3Name string) error {
 	return nil
 }
 
+// Cephs3Rename renames the cephs3 with the given name.
+//
+// It renames the cephs3 OU, then the main, admins, and Owner groups and every
+// subgroup, since all of their CNs embed the cephs3 name.
+func Cephs3Rename(ctx context.Context, cephs3Name string, newName string) error {
+	slog.Debug("Renaming cephs3", "name", cephs3Name, "newName", newName)
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return fmt.Errorf("config not found in context")
+	}
+
+	// Validate the new name the same way we match existing cephs3 groups
+	cephs3GroupNameRegex, err := cephs3GroupNameRegex(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get cephs3 group name regex: %w", err)
+	}
+	newFullName, err := getcephs3FullName(ctx, newName)
+	if err != nil {
+		return fmt.Errorf("failed to get cephs3 full name: %w", err)
+	}
+	if matched, _ := regexp.MatchString(cephs3GroupNameRegex, newFullName); !matched {
+		return fmt.Errorf("invalid cephs3 name: %s", newName)
+	}
+
+	// Make sure the target doesn't exist yet
+	_, found, err := findcephs3DN(ctx, newName)
+	if err != nil {
+		return fmt.Errorf("failed to find cephs3 DN: %w", err)
+	}
+	if found {
+		return fmt.Errorf("cephs3 %s %w", newName, ld.ErrAlreadyExists)
+	}
+	newOUDN, err := getcephs3OUDN(ctx, newName)
+	if err != nil {
+		return fmt.Errorf("failed to get cephs3 DN: %w", err)
+	}
+	exists, err := ld.DNExists(ctx, newOUDN)
+	if err != nil {
+		return fmt.Errorf("failed to check if OU exists: %w", err)
+	}
+	if exists {
+		return fmt.Errorf("cephs3 OU %s %w", newOUDN, ld.ErrAlreadyExists)
+	}
+
+	// Rename the OU, this moves every group inside it along
+	oldOUDN, err := getcephs3OUDN(ctx, cephs3Name)
+	if err != nil {
+		return fmt.Errorf("failed to get cephs3 DN: %w", err)
+	}
+	err = ld.RenameOU(ctx, oldOUDN, newName)
+	if err != nil {
+		return fmt.Errorf("failed to rename cephs3 OU: %w", err)
+	}
+	slog.Debug("Renamed cephs3 OU", "oldOUDN", oldOUDN, "newOUDN", newOUDN)
+
+	// Rename the main, admins, and Owner groups
+	for _, suffix := range []string{"", managedgroup.Cephs3.AdminsSuffix, managedgroup.Cephs3.RoleSuffix} {
+		oldGroupDN := fmt.Sprintf("CN=%s%s%s,%s", groupPrefix, cephs3Name, suffix, newOUDN)
+		newGroupName := fmt.Sprintf("%s%s%s", groupPrefix, newName, suffix)
+		err = ld.RenameGroup(ctx, oldGroupDN, newGroupName)
+		if err != nil {
+			return fmt.Errorf("failed to rename cephs3 group: %w", err)
+		}
+		slog.Debug("Renamed cephs3 group", "oldGroupDN", oldGroupDN, "newGroupName", newGroupName)
+	}
+
+	// Rename the subgroups
+	subgroupOUDN, err := getcephs3SubgroupOUDN(ctx, newName)
+	if err != nil {
+		return fmt.Errorf("failed to get cephs3 subgroup OU DN: %w", err)
+	}
+	subgroups, err := ld.GetGroupNamesInOU(ctx, subgroupOUDN, false)
+	if err != nil {
+		return fmt.Errorf("failed to get cephs3 subgroups: %w", err)
+	}
+	for _, subgroup := range subgroups {
+		shortName := getcephs3SubgroupShortName(cephs3Name, subgroup)
+		newSubgroupName, err := getcephs3SubgroupName(ctx, newName, shortName)
+		if err != nil {
+			return fmt.Errorf("failed to get cephs3 subgroup full name: %w", err)
+		}
+		oldSubgroupDN := fmt.Sprintf("CN=%s,%s", subgroup, subgroupOUDN)
+		err = ld.RenameGroup(ctx, oldSubgroupDN, newSubgroupName)
+		if err != nil {
+			return fmt.Errorf("failed to rename cephs3 subgroup: %w", err)
+		}
+		slog.Debug("Renamed cephs3 subgroup", "oldSubgroupDN", oldSubgroupDN, "newSubgroupName", newSubgroupName)
+	}
+
+	return nil
+}
+
 // cephs3GetOwner returns the Owner username for the cephs3 with the given name.
 func Cephs3GetOwnerUsername(ctx context.Context, cephs3Name string) (string, error) {
 	// Get the Owner username for the cephs3 with the given name
@@ -659,7 +795,29 @@ func Cephs3GetOwnerUsername(ctx context.Context, cephs3Name string) (string, err
 	return members[0], nil
 }
 
+// checkOwnerEligibility checks the user against the owner_eligibility policy.
+func checkOwnerEligibility(ctx context.Context, ownerUsername string) error {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return fmt.Errorf("config not found in context")
+	}
+	ownerDN, err := getUserDN(ctx, ownerUsername)
+	if err != nil {
+		return fmt.Errorf("failed to get owner DN: %w", err)
+	}
+	return ld.CheckEligibility(ctx, ownerDN, cfg.OwnerEligibility, "owner_eligibility")
+}
+
+// Cephs3SetOWNER makes the user the owner of the cephs3 group, if owner_eligibility allows it.
 func Cephs3SetOWNER(ctx context.Context, cephs3Name string, ownerUsername string) error {
+	err := checkOwnerEligibility(ctx, ownerUsername)
+	if err != nil {
+		return err
+	}
+	return setOwner(ctx, cephs3Name, ownerUsername)
+}
+
+func setOwner(ctx context.Context, cephs3Name string, ownerUsername string) error {
 	slog.Debug("Setting Owner for cephs3", "cephs3Name", cephs3Name, "ownerUsername", ownerUsername)
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
 	if cfg == nil {
@@ -757,6 +915,21 @@ func Cephs3List(ctx context.Context) ([]string, error) {
 	return cephs3ShortNames, nil
 }
 
+// Cephs3ListAll classifies every group under the cephs3 base DN, including
+// companion admins and owner groups, subgroups, and groups whose names don't
+// follow the conventions, which Cephs3List leaves out.
+func Cephs3ListAll(ctx context.Context) ([]managedgroup.Classification, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	groups, err := ld.ClassifyGroupsInOU(ctx, managedgroup.Cephs3, cfg.LDAPCephs3DN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to classify cephs3 groups: %w", err)
+	}
+	return groups, nil
+}
+
 // cephs3AddMember adds a member to the cephs3 with the given name.
 func Cephs3AddMember(ctx context.Context, cephs3Name string, member string) error {
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
@@ -803,6 +976,7 @@ func Cephs3AddMember(ctx context.Context, cephs3Name string, member string) erro
 //
 // It will remove them from the cephs3 group, all subgroups, the admin group, and the Owner group.
 // If the user is not a member of any other cephs3s, they will also be removed from the top level users and admins groups.
+// If the user was not a member, it changes nothing and returns ld.ErrNotMember, wrapped.
 func Cephs3RemoveMember(ctx context.Context, name string, member string) error {
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
 	if cfg == nil {
@@ -812,7 +986,7 @@ func Cephs3RemoveMember(ctx context.Context, name string, member string) error {
 	if err != nil {
 		return fmt.Errorf("failed to get cephs3 DN: %w", err)
 	}
-	userDN, err := getUserDN(ctx, member)
+	userDN, _, err := ld.ResolveMemberDN(ctx, cephs3DN, member)
 	if err != nil {
 		return fmt.Errorf("failed to get user DN: %w", err)
 	}
@@ -824,7 +998,7 @@ func Cephs3RemoveMember(ctx context.Context, name string, member string) error {
 	}
 	if !inGroup {
 		slog.Debug("User not in cephs3", "userDN", userDN, "cephs3DN", cephs3DN)
-		return nil
+		return fmt.Errorf("%s %w of cephs3 %s", member, ld.ErrNotMember, name)
 	}
 
 	// Check if the user is the Owner of the cephs3
@@ -942,6 +1116,99 @@ func Cephs3RemoveMember(ctx context.Context, name string, member string) error {
 	return nil
 }
 
+// Cephs3AddMemberDN adds the entry at memberDN to the cephs3 with the given name,
+// without looking it up by username. Only user accounts are added to
+// the top level users group. Other objects, such as service accounts, are
+// only added to the cephs3 group.
+func Cephs3AddMemberDN(ctx context.Context, cephs3Name string, memberDN string) error {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return fmt.Errorf("config not found in context")
+	}
+	cephs3DN, err := getcephs3DN(ctx, cephs3Name)
+	if err != nil {
+		return fmt.Errorf("failed to get cephs3 DN: %w", err)
+	}
+	entry, err := ld.GetMemberEntry(ctx, memberDN)
+	if err != nil {
+		return fmt.Errorf("failed to look up member: %w", err)
+	}
+
+	inGroup, err := ld.UserInGroup(ctx, cephs3DN, entry.DN)
+	if err != nil {
+		return fmt.Errorf("failed to check if user is in group: %w", err)
+	}
+	if inGroup {
+		slog.Debug("Member already in cephs3", "memberDN", entry.DN, "cephs3DN", cephs3DN)
+		return nil
+	}
+
+	slog.Debug("Adding member to cephs3", "memberDN", entry.DN, "cephs3DN", cephs3DN)
+	err = ld.AddUserToGroup(ctx, cephs3DN, entry.DN)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to cephs3 %s: %w", entry.DN, cephs3Name, err)
+	}
+	if !entry.IsUser {
+		slog.Debug("Not a user account, skipping top level users group", "memberDN", entry.DN)
+		return nil
+	}
+	err = addUserDNToTopLevelUsersGroup(ctx, entry.Username, entry.DN)
+	if err != nil {
+		return fmt.Errorf("failed to add user %s to top level users group: %w", entry.Username, err)
+	}
+	return nil
+}
+
+// Cephs3RemoveMemberDN removes the entry at memberDN from the cephs3 with the
+// given name. User accounts are removed by username through
+// Cephs3RemoveMember, which also handles the subgroups, admins, owner check, and top level groups. Other objects are
+// only removed from the cephs3 group, unless they are in the Owner group. A DN that no longer resolves is removed as given.
+func Cephs3RemoveMemberDN(ctx context.Context, name string, memberDN string) error {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return fmt.Errorf("config not found in context")
+	}
+	entry, err := ld.GetRemovableMemberEntry(ctx, memberDN)
+	if err != nil {
+		return fmt.Errorf("failed to look up member: %w", err)
+	}
+	if entry.IsUser {
+		return Cephs3RemoveMember(ctx, name, entry.Username)
+	}
+	cephs3DN, err := getcephs3DN(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get cephs3 DN: %w", err)
+	}
+
+	inGroup, err := ld.UserInGroup(ctx, cephs3DN, entry.DN)
+	if err != nil {
+		return fmt.Errorf("failed to check if user is in group: %w", err)
+	}
+	if !inGroup {
+		slog.Debug("Member not in cephs3", "memberDN", entry.DN, "cephs3DN", cephs3DN)
+		return fmt.Errorf("%s %w of cephs3 %s", entry.DN, ld.ErrNotMember, name)
+	}
+
+	// Like the username path, refuse to remove the Owner
+	roleGroupDN, err := getCephs3OWNERGroupDN(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get cephs3 Owner group DN: %w", err)
+	}
+	isRole, err := ld.UserInGroup(ctx, roleGroupDN, entry.DN)
+	if err != nil {
+		return fmt.Errorf("failed to check if user is in group: %w", err)
+	}
+	if isRole {
+		return fmt.Errorf("%s is the Owner of cephs3 %s, cannot remove without setting a new Owner: %w", entry.DN, name, ld.ErrPolicy)
+	}
+	err = ld.RemoveUserFromGroup(ctx, cephs3DN, entry.DN)
+	if err != nil {
+		return fmt.Errorf("failed to remove %s from cephs3 %s: %w", entry.DN, name, err)
+	}
+	slog.Debug("Removed member from cephs3", "memberDN", entry.DN, "cephs3DN", cephs3DN)
+	return nil
+}
+
 func Cephs3ListMemberUsernames(ctx context.Context, name string) ([]string, error) {
 	// List all members of the cephs3 with the given name
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
@@ -979,6 +1246,24 @@ func Cephs3ListMemberDNs(ctx context.Context, name string) ([]string, error) {
 	return members, nil
 }
 
+// Cephs3CountMembers returns how many members the cephs3 group has, without
+// looking up their usernames.
+func Cephs3CountMembers(ctx context.Context, name string) (int, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return 0, fmt.Errorf("config not found in context")
+	}
+	cephs3DN, err := getcephs3DN(ctx, name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get cephs3 DN: %w", err)
+	}
+	count, err := ld.CountGroupMembers(ctx, cephs3DN)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count group members: %w", err)
+	}
+	return count, nil
+}
+
 // cephs3ListAdminUsernames lists all admin usernames of the cephs3 with the given name.
 func Cephs3ListAdminUsernames(ctx context.Context, name string) ([]string, error) {
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
@@ -997,6 +1282,38 @@ func Cephs3ListAdminUsernames(ctx context.Context, name string) ([]string, error
 	return admins, nil
 }
 
+// Cephs3ListAdminDNs lists all admin DNs of the cephs3 with the given name.
+func Cephs3ListAdminDNs(ctx context.Context, name string) ([]string, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	cephs3DN, err := getcephs3AdminsGroupDN(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cephs3 DN: %w", err)
+	}
+	admins, err := ld.GetGroupMemberDNs(ctx, cephs3DN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group members: %w", err)
+	}
+	slices.Sort(admins)
+	return admins, nil
+}
+
+// Cephs3ListTopLevelAdminUsernames lists all members of the top level cephs3 admins group.
+func Cephs3ListTopLevelAdminUsernames(ctx context.Context) ([]string, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	admins, err := ld.GetGroupMemberUsernames(ctx, cfg.Cephs3AdminsGroupDN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group members: %w", err)
+	}
+	slices.Sort(admins)
+	return admins, nil
+}
+
 // cephs3AddAdmin adds an admin to the cephs3 with the given name.
 func Cephs3AddAdmin(ctx context.Context, cephs3Name string, adminUsername string) error {
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
@@ -1019,7 +1336,7 @@ func Cephs3AddAdmin(ctx context.Context, cephs3Name string, adminUsername string
 	}
 	if !found {
 		slog.Debug("cephs3 not found", "name", cephs3Name)
-		return fmt.Errorf("cephs3 %s not found", cephs3Name)
+		return fmt.Errorf("cephs3 %s %w", cephs3Name, ld.ErrNotFound)
 	}
 
 	// Check if the user is a member of the cephs3 group
@@ -1069,7 +1386,7 @@ func Cephs3RemoveAdmin(ctx context.Context, cephs3Name string, adminUsername str
 	if err != nil { 
 		return fmt.Errorf("failed to get cephs3 admin group DN: %w", err)
 	}
-	userDN, err := getUserDN(ctx, adminUsername)
+	userDN, fallback, err := ld.ResolveMemberDN(ctx, adminGroupDN, adminUsername)
 	if err != nil {
 		return fmt.Errorf("failed to get user DN: %w", err)
 	}
@@ -1091,6 +1408,11 @@ func Cephs3RemoveAdmin(ctx context.Context, cephs3Name string, adminUsername str
 	}
 	slog.Debug("Removed admin from cephs3", "userDN", userDN, "cephs3DN", adminGroupDN)
 
+	// A user missing from AD can't be looked up to clean up the top level groups
+	if fallback {
+		return nil
+	}
+
 	// Remove the user from the top level admins if they are not an admin of any other cephs3
 	isAdminInAnothercephs3, err := userIsAdminInAnycephs3(ctx, adminUsername)
 	if err != nil {
@@ -1149,11 +1471,15 @@ func Cephs3SubgroupList(ctx context.Context, cephs3Name string) ([]string, error
 }
 
 // cephs3SubgroupCreate creates a new subgroup under the cephs3 with the given name.
-func Cephs3SubgroupCreate(ctx context.Context, cephs3Name string, subgroupName string) error {
+// description is stored on the group and may be empty unless require_subgroup_description is set.
+func Cephs3SubgroupCreate(ctx context.Context, cephs3Name string, subgroupName string, description string) error {
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
 	if cfg == nil {
 		return fmt.Errorf("config not found in context")
 	}
+	if err := ld.ValidateSubgroupDescription(ctx, description); err != nil {
+		return err
+	}
 	subgroupDN, err := getcephs3SubgroupDN(ctx, cephs3Name, subgroupName)
 	if err != nil {
 		return fmt.Errorf("failed to get cephs3 subgroup DN: %w", err)
@@ -1169,11 +1495,11 @@ func Cephs3SubgroupCreate(ctx context.Context, cephs3Name string, subgroupName s
 	}
 
 	// Create the subgroup object inside the cephs3 OU/Groups
-	gidNumber, err := ld.GetNextGidNumber(ctx)
+	gidNumber, err := ld.AllocateGids(ctx, "cephs3", 1)
 	if err != nil {
 		return fmt.Errorf("failed to get next GID number: %w", err)
 	}
-	err = ld.CreateGroup(ctx, subgroupOUDN, subgroupFullName, gidNumber)
+	err = ld.CreateGroup(ctx, subgroupOUDN, subgroupFullName, gidNumber, ld.WithDescription(description))
 	if err != nil {
 		return fmt.Errorf("failed to create cephs3 subgroup object: %w", err)
 	}
@@ -1183,35 +1509,53 @@ func Cephs3SubgroupCreate(ctx context.Context, cephs3Name string, subgroupName s
 }
 
 // cephs3SubgroupDelete deletes the subgroup with the given name under the cephs3 groups OU.
-// If the subgroup is found, it returns true and nil.
-func Cephs3SubgroupDelete(ctx context.Context, cephs3Name string, subgroupName string) error {
+// It will error if the subgroup still has members, unless force is set,
+// in which case the members are removed first and their usernames returned.
+func Cephs3SubgroupDelete(ctx context.Context, cephs3Name string, subgroupName string, force bool) ([]string, error) {
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
 	if cfg == nil {
-		return fmt.Errorf("config not found in context")
+		return nil, fmt.Errorf("config not found in context")
 	}
 	subgroupDN, err := getcephs3SubgroupDN(ctx, cephs3Name, subgroupName)
 	if err != nil {
-		return fmt.Errorf("failed to get cephs3 subgroup DN: %w", err)
+		return nil, fmt.Errorf("failed to get cephs3 subgroup DN: %w", err)
 	}
 
 	// Check if the subgroup exists
 	exists, err := ld.DNExists(ctx, subgroupDN)
 	if err != nil {
-		return fmt.Errorf("failed to check if group exists: %w", err)
+		return nil, fmt.Errorf("failed to check if group exists: %w", err)
 	}
 	if !exists {
 		slog.Debug("cephs3 subgroup does not exist", "subgroupDN", subgroupDN)
-		return nil
+		return nil, nil
+	}
+
+	// Check if the subgroup has members
+	members, err := Cephs3SubgroupListMemberUsernames(ctx, cephs3Name, subgroupName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group members: %w", err)
+	}
+	if len(members) > 0 && !force {
+		return nil, fmt.Errorf("cephs3 subgroup %s has %d members, cannot delete without force: %w", subgroupName, len(members), ld.ErrNotEmpty)
+	}
+	var removed []string
+	for _, member := range members {
+		err = Cephs3SubgroupRemoveMember(ctx, cephs3Name, subgroupName, member)
+		if err != nil {
+			return removed, fmt.Errorf("failed to remove member %s from cephs3 subgroup %s: %w", member, subgroupName, err)
+		}
+		removed = append(removed, member)
 	}
 
 	// Delete the subgroup object
 	err = ld.DeleteGroup(ctx, subgroupDN)
 	if err != nil {
-		return fmt.Errorf("failed to delete cephs3 subgroup object: %w", err)
+		return removed, fmt.Errorf("failed to delete cephs3 subgroup object: %w", err)
 	}
 	slog.Debug("Deleted cephs3 subgroup object", "subgroupDN", subgroupDN)
 
-	return nil
+	return removed, nil
 }
 
 // cephs3SubgroupListMemberUsernames lists all members of the subgroup with the given name under the cephs3.
@@ -1323,7 +1667,7 @@ func Cephs3SubgroupRemoveMember(ctx context.Context, cephs3Name string, subgroup
 	if err != nil {
 		return fmt.Errorf("failed to get cephs3 subgroup DN: %w", err)
 	}
-	userDN, err := getUserDN(ctx, memberUsername)
+	userDN, _, err := ld.ResolveMemberDN(ctx, subgroupDN, memberUsername)
 	if err != nil {
 		return fmt.Errorf("failed to get user DN: %w", err)
 	}
@@ -1386,3 +1730,58 @@ func Cephs3SubgroupListDNs(ctx context.Context, cephs3Name string) ([]string, er
 	slices.Sort(subgroups)
 	return subgroups, nil
 }
+
+// Cephs3Check checks the cephs3 with the given name for inconsistencies, such as
+// an owner missing from the admins group or subgroup members who aren't
+// members of the cephs3.
+func Cephs3Check(ctx context.Context, name string) ([]consistency.Problem, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	mainDN, err := getcephs3DN(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cephs3 DN: %w", err)
+	}
+	adminsDN, err := getcephs3AdminsGroupDN(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cephs3 admins group DN: %w", err)
+	}
+	ownerDN, err := getCephs3OWNERGroupDN(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cephs3 owner group DN: %w", err)
+	}
+	subgroupDNs, err := Cephs3SubgroupListDNs(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cephs3 subgroups: %w", err)
+	}
+	fullName, err := getcephs3FullName(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cephs3 full name: %w", err)
+	}
+	return consistency.Check(ctx, consistency.Group{
+		Name:          fullName,
+		MainDN:        mainDN,
+		AdminsDN:      adminsDN,
+		RoleDN:        ownerDN,
+		RoleName:      "owner",
+		SubgroupDNs:   subgroupDNs,
+		// Admins here have always been repaired by adding them as members
+		StrayAdminFix: consistency.StrayAdminAddToMain,
+	})
+}
+
+// Cephs3DNs returns the DNs of the main, admins and owner groups and every
+// subgroup of the cephs3 with the given name, as found in the directory.
+// Missing groups have an empty DN.
+func Cephs3DNs(ctx context.Context, name string) ([]managedgroup.Component, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	components, err := ld.ResolveGroupComponents(ctx, managedgroup.Cephs3, cfg.LDAPCephs3DN, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve cephs3 group DNs: %w", err)
+	}
+	return components, nil
+}
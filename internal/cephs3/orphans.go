@@ -0,0 +1,43 @@
+package cephs3
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/managedgroup"
+)
+
+// Cephs3ListOrphans returns the OUs under the cephs3 base DN with no cephs3
+// group in them, with any groups left inside.
+func Cephs3ListOrphans(ctx context.Context) ([]ld.OrphanOU, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	orphans, err := ld.FindOrphanOUs(ctx, managedgroup.Cephs3, cfg.LDAPCephs3DN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find orphan cephs3 OUs: %w", err)
+	}
+	return orphans, nil
+}
+
+// Cephs3DeleteOrphan deletes the OU of the cephs3 with the given name, which
+// must have no cephs3 group, refusing with ld.ErrNotEmpty if anything but
+// OUs is left inside.
+func Cephs3DeleteOrphan(ctx context.Context, name string) error {
+	dn, found, err := findcephs3DN(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to find cephs3 DN: %w", err)
+	}
+	if found {
+		return fmt.Errorf("cephs3 %s exists at %s, its OU is not an orphan", name, dn)
+	}
+	ouDN, err := getcephs3OUDN(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get cephs3 OU DN: %w", err)
+	}
+	return ld.DeleteEmptyOU(ctx, ouDN)
+}
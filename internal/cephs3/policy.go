@@ -0,0 +1,141 @@
+package cephs3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+)
+
+// DefaultPolicyTemplate renders an RGW bucket policy that gives the admins
+// group's members read/write access and the main group's members read-only
+// access. It is used when cephs3_policy_template is unset.
+const DefaultPolicyTemplate = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Sid": "ReadWriteGid{{.Admins.Gid}}",
+      "Effect": "Allow",
+      "Principal": {"AWS": {{json (userARNs .Admins.Members)}}},
+      "Action": ["s3:*"],
+      "Resource": ["arn:aws:s3:::{{.Name}}", "arn:aws:s3:::{{.Name}}/*"]
+    },
+    {
+      "Sid": "ReadOnlyGid{{.Main.Gid}}",
+      "Effect": "Allow",
+      "Principal": {"AWS": {{json (userARNs .Main.Members)}}},
+      "Action": ["s3:GetObject", "s3:ListBucket"],
+      "Resource": ["arn:aws:s3:::{{.Name}}", "arn:aws:s3:::{{.Name}}/*"]
+    }
+  ]
+}
+`
+
+// PolicyGroup is one of a cephs3's groups as a policy template sees it.
+type PolicyGroup struct {
+	CN      string
+	DN      string
+	Gid     string
+	Members []string
+}
+
+// Policy is what a policy template is rendered with.
+type Policy struct {
+	Name   string
+	Main   PolicyGroup
+	Admins PolicyGroup
+	Owner  PolicyGroup
+}
+
+// policyFuncs are the functions templates can call besides the builtins.
+var policyFuncs = template.FuncMap{
+	// json encodes a value, e.g. a member list, as JSON.
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	// userARNs turns usernames into RGW user principals.
+	"userARNs": func(usernames []string) []string {
+		arns := make([]string, 0, len(usernames))
+		for _, username := range usernames {
+			arns = append(arns, "arn:aws:iam:::user/"+username)
+		}
+		return arns
+	},
+}
+
+// Cephs3Policy looks up the gidNumber and member usernames of the main,
+// admins, and owner groups of the cephs3 with the given name. It fails
+// naming every one of them that is missing.
+func Cephs3Policy(ctx context.Context, name string) (Policy, error) {
+	components, err := Cephs3DNs(ctx, name)
+	if err != nil {
+		return Policy{}, err
+	}
+	p := Policy{Name: name}
+	groups := map[string]*PolicyGroup{"main": &p.Main, "admins": &p.Admins, "owner": &p.Owner}
+	var missing []string
+	for _, c := range components {
+		g, ok := groups[c.Label]
+		if !ok {
+			continue
+		}
+		if c.DN == "" {
+			missing = append(missing, c.CN)
+			continue
+		}
+		g.CN, g.DN = c.CN, c.DN
+		g.Gid, err = ld.GetGidOfExistingGroup(ctx, c.CN)
+		if err != nil {
+			return Policy{}, fmt.Errorf("failed to get GID of %s: %w", c.CN, err)
+		}
+		g.Members, err = ld.GetGroupMemberUsernames(ctx, c.DN)
+		if err != nil {
+			return Policy{}, fmt.Errorf("failed to get members of %s: %w", c.CN, err)
+		}
+		if g.Members == nil {
+			g.Members = []string{}
+		}
+		slices.Sort(g.Members)
+	}
+	if len(missing) > 0 {
+		return Policy{}, fmt.Errorf("groups %s %w", strings.Join(missing, ", "), ld.ErrNotFound)
+	}
+	return p, nil
+}
+
+// RenderPolicy renders p with cephs3_policy_template, or with
+// DefaultPolicyTemplate if that is unset, and checks the result is JSON.
+func RenderPolicy(ctx context.Context, p Policy) (string, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return "", fmt.Errorf("config not found in context")
+	}
+	text := cfg.Cephs3PolicyTemplate
+	if text == "" {
+		text = DefaultPolicyTemplate
+	}
+	return renderPolicy(text, p)
+}
+
+func renderPolicy(text string, p Policy) (string, error) {
+	tmpl, err := template.New("policy").Funcs(policyFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse policy template: %w", err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, p); err != nil {
+		return "", fmt.Errorf("failed to render policy template: %w", err)
+	}
+	if !json.Valid(out.Bytes()) {
+		return "", fmt.Errorf("policy template did not render valid JSON")
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,91 @@
+package cephs3
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+)
+
+func TestRenderPolicy(t *testing.T) {
+	p := Policy{
+		Name:   "bucket",
+		Main:   PolicyGroup{CN: "is.racs.cephs3.bucket", Gid: "100013", Members: []string{"alice", "carol"}},
+		Admins: PolicyGroup{CN: "is.racs.cephs3.bucket.admins", Gid: "100014", Members: []string{}},
+		Owner:  PolicyGroup{CN: "is.racs.cephs3.bucket.owner", Gid: "100015", Members: []string{"carol"}},
+	}
+	cases := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  string
+	}{
+		{
+			name:     "default with an empty group",
+			template: DefaultPolicyTemplate,
+			want:     `"Principal": {"AWS": []}`,
+		},
+		{
+			name:     "default",
+			template: DefaultPolicyTemplate,
+			want:     `"Principal": {"AWS": ["arn:aws:iam:::user/alice","arn:aws:iam:::user/carol"]}`,
+		},
+		{
+			name:     "custom",
+			template: `{"bucket": "{{.Name}}", "owner": {{json .Owner.Members}}, "gids": [{{.Main.Gid}}, {{.Admins.Gid}}]}`,
+			want:     `{"bucket": "bucket", "owner": ["carol"], "gids": [100013, 100014]}`,
+		},
+		{
+			name:     "not JSON",
+			template: `bucket {{.Name}}`,
+			wantErr:  "policy template did not render valid JSON",
+		},
+		{
+			name:     "unknown field",
+			template: `{"bucket": "{{.Bucket}}"}`,
+			wantErr:  "failed to render policy template",
+		},
+		{
+			name:     "malformed",
+			template: `{"bucket": "{{.Name}"}`,
+			wantErr:  "failed to parse policy template",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := renderPolicy(tc.template, p)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("renderPolicy() = %v, want an error containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(got, tc.want) {
+				t.Errorf("renderPolicy() doesn't contain %s:\n%s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestCephs3PolicyMissingGroups(t *testing.T) {
+	ctx := cephs3TestContext(t)
+	for _, dn := range []string{
+		"CN=is.racs.cephs3.lab.admins,OU=lab,OU=CEPHS3,OU=RACS,DC=ad,DC=uoregon,DC=edu",
+		"CN=is.racs.cephs3.lab.owner,OU=lab,OU=CEPHS3,OU=RACS,DC=ad,DC=uoregon,DC=edu",
+	} {
+		if err := ld.DeleteGroup(ctx, dn); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := Cephs3Policy(ctx, "lab")
+	if !errors.Is(err, ld.ErrNotFound) {
+		t.Fatalf("Cephs3Policy() = %v, want %v", err, ld.ErrNotFound)
+	}
+	if want := "groups is.racs.cephs3.lab.admins, is.racs.cephs3.lab.owner not found"; err.Error() != want {
+		t.Errorf("Cephs3Policy() = %q, want %q", err, want)
+	}
+}
@@ -0,0 +1,88 @@
+package cephs3
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/ldap/ldaptest"
+)
+
+// cephs3TestContext returns a context using the directory and config the
+// root package's golden tests use, with cephs3 group lab owned by carol and
+// bob as a member.
+func cephs3TestContext(t *testing.T) context.Context {
+	t.Helper()
+	dir, err := ldaptest.Load(filepath.Join("..", "..", "testdata", "directory.ldif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.GetConfig(filepath.Join("..", "..", "testdata", "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := ld.WithClient(context.WithValue(context.Background(), keys.ConfigKey, cfg), dir)
+	if err := Cephs3Create(ctx, "lab", "carol"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Cephs3AddMember(ctx, "lab", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	return ctx
+}
+
+func TestCephs3SubgroupDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		members     []string
+		force       bool
+		wantErr     error
+		wantRemoved []string
+	}{
+		{name: "empty"},
+		{name: "populated", members: []string{"bob", "carol"}, wantErr: ld.ErrNotEmpty},
+		{name: "populated forced", members: []string{"bob", "carol"}, force: true, wantRemoved: []string{"bob", "carol"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := cephs3TestContext(t)
+			if err := Cephs3SubgroupCreate(ctx, "lab", "scratch", ""); err != nil {
+				t.Fatal(err)
+			}
+			for _, member := range tc.members {
+				if err := Cephs3SubgroupAddMember(ctx, "lab", "scratch", member); err != nil {
+					t.Fatal(err)
+				}
+			}
+			removed, err := Cephs3SubgroupDelete(ctx, "lab", "scratch", tc.force)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("Cephs3SubgroupDelete() = %v, want %v", err, tc.wantErr)
+			}
+			if !slices.Equal(removed, tc.wantRemoved) {
+				t.Errorf("removed = %q, want %q", removed, tc.wantRemoved)
+			}
+			exists, err := Cephs3SubgroupExists(ctx, "lab", "scratch")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if exists != (tc.wantErr != nil) {
+				t.Errorf("subgroup exists = %v after Cephs3SubgroupDelete() = %v", exists, err)
+			}
+			if tc.wantErr == nil {
+				return
+			}
+			got, err := Cephs3SubgroupListMemberUsernames(ctx, "lab", "scratch")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tc.members) {
+				t.Errorf("members after a refused delete = %q, want %q", got, tc.members)
+			}
+		})
+	}
+}
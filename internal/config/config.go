@@ -1,28 +1,216 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/goccy/go-yaml"
+	"github.com/uoracs/directory-manager/internal/quota"
 )
 
+const (
+	// DefaultLDAPPageSize is the page size of large searches when
+	// ldap_page_size is unset.
+	DefaultLDAPPageSize = 500
+	// DefaultLDAPModifyRetries is how many times a change the domain
+	// controller was too busy for is retried when ldap_modify_retries is
+	// unset.
+	DefaultLDAPModifyRetries = 2
+	// DefaultLDAPSlowOpMs is how long, in milliseconds, an LDAP search or
+	// change may take before it is logged as slow when ldap_slow_op_ms is
+	// unset.
+	DefaultLDAPSlowOpMs = 1000
+	// maxLDAPPageSize is AD's default MaxPageSize.
+	maxLDAPPageSize = 1000
+)
+
+// ClampPageSize brings a search page size within the 1-1000 AD allows,
+// warning when it had to change it.
+func ClampPageSize(size int) int {
+	clamped := min(max(size, 1), maxLDAPPageSize)
+	if clamped != size {
+		slog.Warn("LDAP page size out of range, clamping", "requested", size, "using", clamped)
+	}
+	return clamped
+}
+
+// QuotaMap maps the name of a storage quota to the attribute of the main
+// PIRG group it is stored in.
+type QuotaMap map[string]string
+
+// Eligibility restricts who may hold a role such as PI or owner. A user is
+// eligible if their DN is under one of AllowedUserOUs or they are a member of
+// RequiredGroupDN. An empty policy allows everyone.
+type Eligibility struct {
+	AllowedUserOUs  []string `yaml:"allowed_user_ous"`
+	RequiredGroupDN string   `yaml:"required_group_dn"`
+}
+
+// IsEmpty reports whether the policy places no restriction.
+func (e Eligibility) IsEmpty() bool {
+	return len(e.AllowedUserOUs) == 0 && e.RequiredGroupDN == ""
+}
+
 type Config struct {
-	LDAPServer       string `yaml:"ldap_server"`
-	LDAPPort         int    `yaml:"ldap_port"`
-	LDAPUsername     string `yaml:"ldap_username"`
-	LDAPPassword     string `yaml:"ldap_password"`
-	LDAPUsersBaseDN  string `yaml:"ldap_users_base_dn"`
-	LDAPGroupsBaseDN string `yaml:"ldap_groups_base_dn"`
-	LDAPPirgDN       string `yaml:"ldap_pirg_dn"`
-	LDAPCephfsDN       string `yaml:"ldap_cephfs_dn"`
-	LDAPCephs3DN       string `yaml:"ldap_cephs3_dn"`
-	LDAPSoftwareDN   string `yaml:"ldap_software_dn"`
-	LDAPMinGid       int    `yaml:"ldap_min_gid"`
-	LDAPMaxGid       int    `yaml:"ldap_max_gid"`
-	DataPath         string `yaml:"data_path"`
+	LDAPServer                 string      `yaml:"ldap_server"`
+	LDAPServers                []string    `yaml:"ldap_servers"`
+	LDAPPort                   int         `yaml:"ldap_port"`
+	LDAPUsername               string      `yaml:"ldap_username"`
+	LDAPPassword               string      `yaml:"ldap_password"`
+	LDAPUsersBaseDN            string      `yaml:"ldap_users_base_dn"`
+	LDAPGroupsBaseDN           string      `yaml:"ldap_groups_base_dn"`
+	GidScanBaseDNs             []string    `yaml:"gid_scan_base_dns"`
+	LDAPPirgDN                 string      `yaml:"ldap_pirg_dn"`
+	PirgMailTemplate           string      `yaml:"pirg_mail_template"`
+	PIEligibility              Eligibility `yaml:"pi_eligibility"`
+	OwnerEligibility           Eligibility `yaml:"owner_eligibility"`
+	LDAPCephfsDN               string      `yaml:"ldap_cephfs_dn"`
+	LDAPCephs3DN               string      `yaml:"ldap_cephs3_dn"`
+	LDAPSoftwareDN             string      `yaml:"ldap_software_dn"`
+	UsersGroupDN               string      `yaml:"users_group_dn"`
+	PirgAdminsGroupDN          string      `yaml:"pirg_admins_group_dn"`
+	CephfsAdminsGroupDN        string      `yaml:"cephfs_admins_group_dn"`
+	Cephs3AdminsGroupDN        string      `yaml:"cephs3_admins_group_dn"`
+	SoftwareLayout             string      `yaml:"software_layout"`
+	SoftwareAdminsGroupDN      string      `yaml:"software_admins_group_dn"`
+	EnablePirg                 *bool       `yaml:"enable_pirg"`
+	EnableCeph                 *bool       `yaml:"enable_ceph"`
+	EnableCephfs               *bool       `yaml:"enable_cephfs"`
+	EnableCephs3               *bool       `yaml:"enable_cephs3"`
+	EnableSoftware             *bool       `yaml:"enable_software"`
+	LDAPMinGid                 int         `yaml:"ldap_min_gid"`
+	LDAPMaxGid                 int         `yaml:"ldap_max_gid"`
+	LDAPPageSize               int         `yaml:"ldap_page_size"`
+	LDAPModifyRetries          *int        `yaml:"ldap_modify_retries"`
+	LDAPSlowOpMs               *int        `yaml:"ldap_slow_op_ms"`
+	PirgMinGid                 int         `yaml:"pirg_min_gid"`
+	PirgMaxGid                 int         `yaml:"pirg_max_gid"`
+	CephfsMinGid               int         `yaml:"cephfs_min_gid"`
+	CephfsMaxGid               int         `yaml:"cephfs_max_gid"`
+	Cephs3MinGid               int         `yaml:"cephs3_min_gid"`
+	Cephs3MaxGid               int         `yaml:"cephs3_max_gid"`
+	SoftwareMinGid             int         `yaml:"software_min_gid"`
+	SoftwareMaxGid             int         `yaml:"software_max_gid"`
+	EnforceGidUniqueness       *bool       `yaml:"enforce_gid_uniqueness"`
+	GidHighWaterDN             string      `yaml:"gid_highwater_dn"`
+	GidHighWaterAttribute      string      `yaml:"gid_highwater_attribute"`
+	MaintenanceDN              string      `yaml:"maintenance_dn"`
+	MaintenanceAttribute       string      `yaml:"maintenance_attribute"`
+	GidUsageWarnPercent        int         `yaml:"gid_usage_warn_percent"`
+	RequireSubgroupDescription *bool       `yaml:"require_subgroup_description"`
+	MemberRangeSize            int         `yaml:"member_range_size"`
+	AllowDisabledMembers       *bool       `yaml:"allow_disabled_members"`
+	DataPath                   string      `yaml:"data_path"`
+	AgentIdleMinutes           int         `yaml:"agent_idle_minutes"`
+	OpCacheTTLMinutes          int         `yaml:"op_cache_ttl_minutes"`
+	RequireReason              *bool       `yaml:"require_reason"`
+	KeepTopLevelUsers          *bool       `yaml:"keep_top_level_users_on_last_removal"`
+	ChangeNoteLimit            int         `yaml:"change_note_limit"`
+	Cephs3PolicyTemplate       string      `yaml:"cephs3_policy_template"`
+	WebhookURL                 string      `yaml:"webhook_url"`
+	QuotaAttributeMap          QuotaMap    `yaml:"quota_attribute_map"`
+	EnforceNameUniqueness      *bool       `yaml:"enforce_name_uniqueness"`
+	// Aliases maps a site-defined command word to the command line it
+	// expands to, with {1}, {2}, ... replaced by the arguments after it.
+	Aliases map[string]string `yaml:"aliases"`
+	// GidHighWaterAttributes maps each namespace with its own GID range to
+	// the attribute of gid_highwater_dn holding that range's mark.
+	GidHighWaterAttributes map[string]string `yaml:"gid_highwater_attributes"`
+	LogFormat              string            `yaml:"log_format"`
+	LogLevel               string            `yaml:"log_level"`
+	LogFile                string            `yaml:"log_file"`
+	LogFileMaxSizeMB       int               `yaml:"log_file_max_size_mb"`
+	DisableUpdateCheck     *bool             `yaml:"disable_update_check"`
+}
+
+// LogValue implements slog.LogValuer so the bind password never ends up in logs.
+func (c Config) LogValue() slog.Value {
+	password := ""
+	if c.LDAPPassword != "" {
+		password = "REDACTED"
+	}
+	return slog.GroupValue(
+		slog.String("ldap_server", c.LDAPServer),
+		slog.Any("ldap_servers", c.LDAPServers),
+		slog.Int("ldap_port", c.LDAPPort),
+		slog.String("ldap_username", c.LDAPUsername),
+		slog.String("ldap_password", password),
+		slog.String("ldap_users_base_dn", c.LDAPUsersBaseDN),
+		slog.String("ldap_groups_base_dn", c.LDAPGroupsBaseDN),
+		slog.Any("gid_scan_base_dns", c.GidScanBaseDNs),
+		slog.String("ldap_pirg_dn", c.LDAPPirgDN),
+		slog.String("pirg_mail_template", c.PirgMailTemplate),
+		slog.Any("pi_eligibility_allowed_user_ous", c.PIEligibility.AllowedUserOUs),
+		slog.String("pi_eligibility_required_group_dn", c.PIEligibility.RequiredGroupDN),
+		slog.Any("owner_eligibility_allowed_user_ous", c.OwnerEligibility.AllowedUserOUs),
+		slog.String("owner_eligibility_required_group_dn", c.OwnerEligibility.RequiredGroupDN),
+		slog.String("ldap_cephfs_dn", c.LDAPCephfsDN),
+		slog.String("ldap_cephs3_dn", c.LDAPCephs3DN),
+		slog.String("ldap_software_dn", c.LDAPSoftwareDN),
+		slog.String("users_group_dn", c.UsersGroupDN),
+		slog.String("pirg_admins_group_dn", c.PirgAdminsGroupDN),
+		slog.String("cephfs_admins_group_dn", c.CephfsAdminsGroupDN),
+		slog.String("cephs3_admins_group_dn", c.Cephs3AdminsGroupDN),
+		slog.String("software_layout", c.SoftwareLayout),
+		slog.String("software_admins_group_dn", c.SoftwareAdminsGroupDN),
+		slog.Bool("enable_pirg", enabled(c.EnablePirg)),
+		slog.Bool("enable_ceph", enabled(c.EnableCeph)),
+		slog.Bool("enable_cephfs", enabled(c.EnableCephfs)),
+		slog.Bool("enable_cephs3", enabled(c.EnableCephs3)),
+		slog.Bool("enable_software", enabled(c.EnableSoftware)),
+		slog.Int("ldap_min_gid", c.LDAPMinGid),
+		slog.Int("ldap_max_gid", c.LDAPMaxGid),
+		slog.Int("ldap_page_size", c.LDAPPageSize),
+		slog.Int("ldap_modify_retries", c.ModifyRetries()),
+		slog.Int64("ldap_slow_op_ms", c.SlowOpThreshold().Milliseconds()),
+		slog.Int("pirg_min_gid", c.PirgMinGid),
+		slog.Int("pirg_max_gid", c.PirgMaxGid),
+		slog.Int("cephfs_min_gid", c.CephfsMinGid),
+		slog.Int("cephfs_max_gid", c.CephfsMaxGid),
+		slog.Int("cephs3_min_gid", c.Cephs3MinGid),
+		slog.Int("cephs3_max_gid", c.Cephs3MaxGid),
+		slog.Int("software_min_gid", c.SoftwareMinGid),
+		slog.Int("software_max_gid", c.SoftwareMaxGid),
+		slog.Bool("enforce_gid_uniqueness", c.EnforceGidUniqueness == nil || *c.EnforceGidUniqueness),
+		slog.String("gid_highwater_dn", c.GidHighWaterDN),
+		slog.String("gid_highwater_attribute", c.GidHighWaterAttribute),
+		slog.Any("gid_highwater_attributes", c.GidHighWaterAttributes),
+		slog.String("maintenance_dn", c.MaintenanceDN),
+		slog.String("maintenance_attribute", c.MaintenanceAttribute),
+		slog.Int("gid_usage_warn_percent", c.GidUsageWarnPercent),
+		slog.Bool("require_subgroup_description", c.SubgroupDescriptionRequired()),
+		slog.Int("member_range_size", c.MemberRangeSize),
+		slog.Bool("allow_disabled_members", c.DisabledMembersAllowed()),
+		slog.String("data_path", c.DataPath),
+		slog.Int("agent_idle_minutes", c.AgentIdleMinutes),
+		slog.Int("op_cache_ttl_minutes", c.OpCacheTTLMinutes),
+		slog.Bool("require_reason", c.ReasonRequired()),
+		slog.Bool("keep_top_level_users_on_last_removal", c.KeepsTopLevelUsers()),
+		slog.Int("change_note_limit", c.ChangeNoteLimit),
+		slog.Bool("cephs3_policy_template", c.Cephs3PolicyTemplate != ""),
+		// The URL may carry a token
+		slog.Bool("webhook_url", c.WebhookURL != ""),
+		slog.Any("quota_attribute_map", c.QuotaAttributeMap),
+		slog.Bool("enforce_name_uniqueness", c.NameUniquenessEnforced()),
+		slog.Any("aliases", c.Aliases),
+		slog.String("log_format", c.LogFormat),
+		slog.String("log_level", c.LogLevel),
+		slog.String("log_file", c.LogFile),
+		slog.Int("log_file_max_size_mb", c.LogFileMaxSizeMB),
+		slog.Bool("disable_update_check", c.UpdateCheckDisabled()),
+	)
 }
 
 func loadEnvironment() (*Config, error) {
@@ -35,6 +223,15 @@ func loadEnvironment() (*Config, error) {
 	if found {
 		slog.Debug("Found LDAP server in environment variables")
 	}
+	servers, found := os.LookupEnv("DIRECTORY_MANAGER_LDAP_SERVERS")
+	if found {
+		slog.Debug("Found LDAP servers in environment variables")
+		for _, server := range strings.Split(servers, ",") {
+			if server = strings.TrimSpace(server); server != "" {
+				c.LDAPServers = append(c.LDAPServers, server)
+			}
+		}
+	}
 	port, found := os.LookupEnv("DIRECTORY_MANAGER_LDAP_PORT")
 	if found {
 		slog.Debug("Found LDAP port in environment variables")
@@ -59,10 +256,38 @@ func loadEnvironment() (*Config, error) {
 	if found {
 		slog.Debug("Found LDAP groups base DN in environment variables")
 	}
+	gidScanBaseDNs, found := os.LookupEnv("DIRECTORY_MANAGER_GID_SCAN_BASE_DNS")
+	if found {
+		slog.Debug("Found GID scan base DNs in environment variables")
+		c.GidScanBaseDNs = splitDNList(gidScanBaseDNs)
+	}
 	c.LDAPPirgDN, found = os.LookupEnv("DIRECTORY_MANAGER_LDAP_PIRG_DN")
 	if found {
 		slog.Debug("Found LDAP PIRG DN in environment variables")
 	}
+	c.PirgMailTemplate, found = os.LookupEnv("DIRECTORY_MANAGER_PIRG_MAIL_TEMPLATE")
+	if found {
+		slog.Debug("Found PIRG mail template in environment variables")
+	}
+	// DNs contain commas, so lists of them are separated with semicolons
+	piAllowedOUs, found := os.LookupEnv("DIRECTORY_MANAGER_PI_ELIGIBILITY_ALLOWED_USER_OUS")
+	if found {
+		slog.Debug("Found PI eligibility allowed user OUs in environment variables")
+		c.PIEligibility.AllowedUserOUs = splitDNList(piAllowedOUs)
+	}
+	c.PIEligibility.RequiredGroupDN, found = os.LookupEnv("DIRECTORY_MANAGER_PI_ELIGIBILITY_REQUIRED_GROUP_DN")
+	if found {
+		slog.Debug("Found PI eligibility required group DN in environment variables")
+	}
+	ownerAllowedOUs, found := os.LookupEnv("DIRECTORY_MANAGER_OWNER_ELIGIBILITY_ALLOWED_USER_OUS")
+	if found {
+		slog.Debug("Found owner eligibility allowed user OUs in environment variables")
+		c.OwnerEligibility.AllowedUserOUs = splitDNList(ownerAllowedOUs)
+	}
+	c.OwnerEligibility.RequiredGroupDN, found = os.LookupEnv("DIRECTORY_MANAGER_OWNER_ELIGIBILITY_REQUIRED_GROUP_DN")
+	if found {
+		slog.Debug("Found owner eligibility required group DN in environment variables")
+	}
 	c.LDAPCephfsDN, found = os.LookupEnv("DIRECTORY_MANAGER_LDAP_CEPHFS_DN")
 	if found {
 		slog.Debug("Found LDAP Cephfs DN in environment variables")
@@ -78,6 +303,30 @@ func loadEnvironment() (*Config, error) {
 		slog.Debug("Found LDAP Software DN in environment variables")
 		// fmt.Println("LDAPSoftwareDN was found successfully")
 	}
+	c.UsersGroupDN, found = os.LookupEnv("DIRECTORY_MANAGER_USERS_GROUP_DN")
+	if found {
+		slog.Debug("Found users group DN in environment variables")
+	}
+	c.PirgAdminsGroupDN, found = os.LookupEnv("DIRECTORY_MANAGER_PIRG_ADMINS_GROUP_DN")
+	if found {
+		slog.Debug("Found PIRG admins group DN in environment variables")
+	}
+	c.CephfsAdminsGroupDN, found = os.LookupEnv("DIRECTORY_MANAGER_CEPHFS_ADMINS_GROUP_DN")
+	if found {
+		slog.Debug("Found cephfs admins group DN in environment variables")
+	}
+	c.Cephs3AdminsGroupDN, found = os.LookupEnv("DIRECTORY_MANAGER_CEPHS3_ADMINS_GROUP_DN")
+	if found {
+		slog.Debug("Found cephs3 admins group DN in environment variables")
+	}
+	c.SoftwareLayout, found = os.LookupEnv("DIRECTORY_MANAGER_SOFTWARE_LAYOUT")
+	if found {
+		slog.Debug("Found software layout in environment variables")
+	}
+	c.SoftwareAdminsGroupDN, found = os.LookupEnv("DIRECTORY_MANAGER_SOFTWARE_ADMINS_GROUP_DN")
+	if found {
+		slog.Debug("Found software admins group DN in environment variables")
+	}
 	mingid, found := os.LookupEnv("DIRECTORY_MANAGER_LDAP_MIN_GID")
 	if found {
 		slog.Debug("Found LDAP min gid in environment variables")
@@ -94,14 +343,697 @@ func loadEnvironment() (*Config, error) {
 			return nil, fmt.Errorf("failed to convert LDAP max gid to int: %w", err)
 		}
 	}
+	pageSize, found := os.LookupEnv("DIRECTORY_MANAGER_LDAP_PAGE_SIZE")
+	if found {
+		slog.Debug("Found LDAP page size in environment variables")
+		c.LDAPPageSize, err = strconv.Atoi(pageSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert LDAP page size to int: %w", err)
+		}
+	}
+	pirgMinGid, found := os.LookupEnv("DIRECTORY_MANAGER_PIRG_MIN_GID")
+	if found {
+		slog.Debug("Found pirg min gid in environment variables")
+		c.PirgMinGid, err = strconv.Atoi(pirgMinGid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert pirg min gid to int: %w", err)
+		}
+	}
+	pirgMaxGid, found := os.LookupEnv("DIRECTORY_MANAGER_PIRG_MAX_GID")
+	if found {
+		slog.Debug("Found pirg max gid in environment variables")
+		c.PirgMaxGid, err = strconv.Atoi(pirgMaxGid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert pirg max gid to int: %w", err)
+		}
+	}
+	cephfsMinGid, found := os.LookupEnv("DIRECTORY_MANAGER_CEPHFS_MIN_GID")
+	if found {
+		slog.Debug("Found cephfs min gid in environment variables")
+		c.CephfsMinGid, err = strconv.Atoi(cephfsMinGid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert cephfs min gid to int: %w", err)
+		}
+	}
+	cephfsMaxGid, found := os.LookupEnv("DIRECTORY_MANAGER_CEPHFS_MAX_GID")
+	if found {
+		slog.Debug("Found cephfs max gid in environment variables")
+		c.CephfsMaxGid, err = strconv.Atoi(cephfsMaxGid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert cephfs max gid to int: %w", err)
+		}
+	}
+	cephs3MinGid, found := os.LookupEnv("DIRECTORY_MANAGER_CEPHS3_MIN_GID")
+	if found {
+		slog.Debug("Found cephs3 min gid in environment variables")
+		c.Cephs3MinGid, err = strconv.Atoi(cephs3MinGid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert cephs3 min gid to int: %w", err)
+		}
+	}
+	cephs3MaxGid, found := os.LookupEnv("DIRECTORY_MANAGER_CEPHS3_MAX_GID")
+	if found {
+		slog.Debug("Found cephs3 max gid in environment variables")
+		c.Cephs3MaxGid, err = strconv.Atoi(cephs3MaxGid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert cephs3 max gid to int: %w", err)
+		}
+	}
+	softwareMinGid, found := os.LookupEnv("DIRECTORY_MANAGER_SOFTWARE_MIN_GID")
+	if found {
+		slog.Debug("Found software min gid in environment variables")
+		c.SoftwareMinGid, err = strconv.Atoi(softwareMinGid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert software min gid to int: %w", err)
+		}
+	}
+	softwareMaxGid, found := os.LookupEnv("DIRECTORY_MANAGER_SOFTWARE_MAX_GID")
+	if found {
+		slog.Debug("Found software max gid in environment variables")
+		c.SoftwareMaxGid, err = strconv.Atoi(softwareMaxGid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert software max gid to int: %w", err)
+		}
+	}
+	modifyRetries, found := os.LookupEnv("DIRECTORY_MANAGER_LDAP_MODIFY_RETRIES")
+	if found {
+		slog.Debug("Found ldap modify retries in environment variables")
+		retries, err := strconv.Atoi(modifyRetries)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert ldap modify retries to int: %w", err)
+		}
+		c.LDAPModifyRetries = &retries
+	}
+	slowOpMs, found := os.LookupEnv("DIRECTORY_MANAGER_LDAP_SLOW_OP_MS")
+	if found {
+		slog.Debug("Found ldap slow op ms in environment variables")
+		ms, err := strconv.Atoi(slowOpMs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert ldap slow op ms to int: %w", err)
+		}
+		c.LDAPSlowOpMs = &ms
+	}
+	enforceGidUniqueness, found := os.LookupEnv("DIRECTORY_MANAGER_ENFORCE_GID_UNIQUENESS")
+	if found {
+		slog.Debug("Found enforce gid uniqueness in environment variables")
+		enforce, err := strconv.ParseBool(enforceGidUniqueness)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert enforce gid uniqueness to bool: %w", err)
+		}
+		c.EnforceGidUniqueness = &enforce
+	}
+	enablePirg, found := os.LookupEnv("DIRECTORY_MANAGER_ENABLE_PIRG")
+	if found {
+		slog.Debug("Found enable pirg in environment variables")
+		enable, err := strconv.ParseBool(enablePirg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert enable pirg to bool: %w", err)
+		}
+		c.EnablePirg = &enable
+	}
+	enableCeph, found := os.LookupEnv("DIRECTORY_MANAGER_ENABLE_CEPH")
+	if found {
+		slog.Debug("Found enable ceph in environment variables")
+		enable, err := strconv.ParseBool(enableCeph)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert enable ceph to bool: %w", err)
+		}
+		c.EnableCeph = &enable
+	}
+	enableCephfs, found := os.LookupEnv("DIRECTORY_MANAGER_ENABLE_CEPHFS")
+	if found {
+		slog.Debug("Found enable cephfs in environment variables")
+		enable, err := strconv.ParseBool(enableCephfs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert enable cephfs to bool: %w", err)
+		}
+		c.EnableCephfs = &enable
+	}
+	enableCephs3, found := os.LookupEnv("DIRECTORY_MANAGER_ENABLE_CEPHS3")
+	if found {
+		slog.Debug("Found enable cephs3 in environment variables")
+		enable, err := strconv.ParseBool(enableCephs3)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert enable cephs3 to bool: %w", err)
+		}
+		c.EnableCephs3 = &enable
+	}
+	enableSoftware, found := os.LookupEnv("DIRECTORY_MANAGER_ENABLE_SOFTWARE")
+	if found {
+		slog.Debug("Found enable software in environment variables")
+		enable, err := strconv.ParseBool(enableSoftware)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert enable software to bool: %w", err)
+		}
+		c.EnableSoftware = &enable
+	}
+	c.GidHighWaterDN, found = os.LookupEnv("DIRECTORY_MANAGER_GID_HIGHWATER_DN")
+	if found {
+		slog.Debug("Found gid high-water DN in environment variables")
+	}
+	c.GidHighWaterAttribute, found = os.LookupEnv("DIRECTORY_MANAGER_GID_HIGHWATER_ATTRIBUTE")
+	if found {
+		slog.Debug("Found gid high-water attribute in environment variables")
+	}
+	c.MaintenanceDN, found = os.LookupEnv("DIRECTORY_MANAGER_MAINTENANCE_DN")
+	if found {
+		slog.Debug("Found maintenance DN in environment variables")
+	}
+	c.MaintenanceAttribute, found = os.LookupEnv("DIRECTORY_MANAGER_MAINTENANCE_ATTRIBUTE")
+	if found {
+		slog.Debug("Found maintenance attribute in environment variables")
+	}
+	gidUsageWarnPercent, found := os.LookupEnv("DIRECTORY_MANAGER_GID_USAGE_WARN_PERCENT")
+	if found {
+		slog.Debug("Found gid usage warn percent in environment variables")
+		c.GidUsageWarnPercent, err = strconv.Atoi(gidUsageWarnPercent)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert gid usage warn percent to int: %w", err)
+		}
+	}
+	requireSubgroupDescription, found := os.LookupEnv("DIRECTORY_MANAGER_REQUIRE_SUBGROUP_DESCRIPTION")
+	if found {
+		slog.Debug("Found require subgroup description in environment variables")
+		require, err := strconv.ParseBool(requireSubgroupDescription)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert require subgroup description to bool: %w", err)
+		}
+		c.RequireSubgroupDescription = &require
+	}
+	memberRangeSize, found := os.LookupEnv("DIRECTORY_MANAGER_MEMBER_RANGE_SIZE")
+	if found {
+		slog.Debug("Found member range size in environment variables")
+		c.MemberRangeSize, err = strconv.Atoi(memberRangeSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert member range size to int: %w", err)
+		}
+	}
+	allowDisabledMembers, found := os.LookupEnv("DIRECTORY_MANAGER_ALLOW_DISABLED_MEMBERS")
+	if found {
+		slog.Debug("Found allow disabled members in environment variables")
+		allow, err := strconv.ParseBool(allowDisabledMembers)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert allow disabled members to bool: %w", err)
+		}
+		c.AllowDisabledMembers = &allow
+	}
+	requireReason, found := os.LookupEnv("DIRECTORY_MANAGER_REQUIRE_REASON")
+	if found {
+		slog.Debug("Found require reason in environment variables")
+		require, err := strconv.ParseBool(requireReason)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert require reason to bool: %w", err)
+		}
+		c.RequireReason = &require
+	}
+	keepTopLevelUsers, found := os.LookupEnv("DIRECTORY_MANAGER_KEEP_TOP_LEVEL_USERS_ON_LAST_REMOVAL")
+	if found {
+		slog.Debug("Found keep top level users on last removal in environment variables")
+		keep, err := strconv.ParseBool(keepTopLevelUsers)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert keep top level users on last removal to bool: %w", err)
+		}
+		c.KeepTopLevelUsers = &keep
+	}
+	changeNoteLimit, found := os.LookupEnv("DIRECTORY_MANAGER_CHANGE_NOTE_LIMIT")
+	if found {
+		slog.Debug("Found change note limit in environment variables")
+		c.ChangeNoteLimit, err = strconv.Atoi(changeNoteLimit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert change note limit to int: %w", err)
+		}
+	}
+	cephs3PolicyTemplate, found := os.LookupEnv("DIRECTORY_MANAGER_CEPHS3_POLICY_TEMPLATE")
+	if found {
+		slog.Debug("Found cephs3 policy template in environment variables")
+		c.Cephs3PolicyTemplate = cephs3PolicyTemplate
+	}
+	webhookURL, found := os.LookupEnv("DIRECTORY_MANAGER_WEBHOOK_URL")
+	if found {
+		slog.Debug("Found webhook URL in environment variables")
+		c.WebhookURL = webhookURL
+	}
+	gidHighWaterAttributes, found := os.LookupEnv("DIRECTORY_MANAGER_GID_HIGHWATER_ATTRIBUTES")
+	if found {
+		slog.Debug("Found GID high-water attributes in environment variables")
+		c.GidHighWaterAttributes, err = parseQuotaMap(gidHighWaterAttributes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse GID high-water attributes: %w", err)
+		}
+	}
+	quotaAttributeMap, found := os.LookupEnv("DIRECTORY_MANAGER_QUOTA_ATTRIBUTE_MAP")
+	if found {
+		slog.Debug("Found quota attribute map in environment variables")
+		c.QuotaAttributeMap, err = parseQuotaMap(quotaAttributeMap)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse quota attribute map: %w", err)
+		}
+	}
+	enforceNameUniqueness, found := os.LookupEnv("DIRECTORY_MANAGER_ENFORCE_NAME_UNIQUENESS")
+	if found {
+		slog.Debug("Found enforce name uniqueness in environment variables")
+		enforce, err := strconv.ParseBool(enforceNameUniqueness)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert enforce name uniqueness to bool: %w", err)
+		}
+		c.EnforceNameUniqueness = &enforce
+	}
 	dataPath, found := os.LookupEnv("DIRECTORY_MANAGER_DATA_PATH")
 	if found {
 		slog.Debug("Found data path in environment variables")
 		c.DataPath = dataPath
 	}
+	agentIdleMinutes, found := os.LookupEnv("DIRECTORY_MANAGER_AGENT_IDLE_MINUTES")
+	if found {
+		slog.Debug("Found agent idle minutes in environment variables")
+		c.AgentIdleMinutes, err = strconv.Atoi(agentIdleMinutes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert agent idle minutes to int: %w", err)
+		}
+	}
+	opCacheTTLMinutes, found := os.LookupEnv("DIRECTORY_MANAGER_OP_CACHE_TTL_MINUTES")
+	if found {
+		slog.Debug("Found op cache TTL minutes in environment variables")
+		c.OpCacheTTLMinutes, err = strconv.Atoi(opCacheTTLMinutes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert op cache TTL minutes to int: %w", err)
+		}
+	}
+	c.LogFormat, found = os.LookupEnv("DIRECTORY_MANAGER_LOG_FORMAT")
+	if found {
+		slog.Debug("Found log format in environment variables")
+	}
+	c.LogLevel, found = os.LookupEnv("DIRECTORY_MANAGER_LOG_LEVEL")
+	if found {
+		slog.Debug("Found log level in environment variables")
+	}
+	c.LogFile, found = os.LookupEnv("DIRECTORY_MANAGER_LOG_FILE")
+	if found {
+		slog.Debug("Found log file in environment variables")
+	}
+	logFileMaxSize, found := os.LookupEnv("DIRECTORY_MANAGER_LOG_FILE_MAX_SIZE_MB")
+	if found {
+		slog.Debug("Found log file max size in environment variables")
+		c.LogFileMaxSizeMB, err = strconv.Atoi(logFileMaxSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert log file max size to int: %w", err)
+		}
+	}
+	disableUpdateCheck, found := os.LookupEnv("DIRECTORY_MANAGER_DISABLE_UPDATE_CHECK")
+	if found {
+		slog.Debug("Found disable update check in environment variables")
+		disable, err := strconv.ParseBool(disableUpdateCheck)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert disable update check to bool: %w", err)
+		}
+		c.DisableUpdateCheck = &disable
+	}
 	return &c, nil
 }
 
+func enabled(flag *bool) bool {
+	return flag == nil || *flag
+}
+
+func isTrue(flag *bool) bool {
+	return flag != nil && *flag
+}
+
+// DisabledSubsystem returns the subsystem turned off in configuration that
+// disables the given command family (pirg, cephfs, cephs3 or software), or
+// "" if the family is enabled. enable_ceph covers both cephfs and cephs3.
+func (c *Config) DisabledSubsystem(family string) string {
+	switch family {
+	case "pirg":
+		if !enabled(c.EnablePirg) {
+			return "pirg"
+		}
+	case "cephfs", "cephs3":
+		if !enabled(c.EnableCeph) {
+			return "ceph"
+		}
+		if family == "cephfs" && !enabled(c.EnableCephfs) {
+			return "cephfs"
+		}
+		if family == "cephs3" && !enabled(c.EnableCephs3) {
+			return "cephs3"
+		}
+	case "software":
+		if !enabled(c.EnableSoftware) {
+			return "software"
+		}
+	}
+	return ""
+}
+
+// SubgroupDescriptionRequired reports whether subgroups can only be created
+// with a description.
+func (c *Config) SubgroupDescriptionRequired() bool {
+	return isTrue(c.RequireSubgroupDescription)
+}
+
+// DisabledMembersAllowed reports whether disabled accounts may be added as
+// members without --allow-disabled.
+func (c *Config) DisabledMembersAllowed() bool {
+	return isTrue(c.AllowDisabledMembers)
+}
+
+// UpdateCheckDisabled reports whether version skips asking for the latest
+// release.
+func (c *Config) UpdateCheckDisabled() bool {
+	return isTrue(c.DisableUpdateCheck)
+}
+
+// ReasonRequired reports whether changing commands need --reason.
+func (c *Config) ReasonRequired() bool {
+	return isTrue(c.RequireReason)
+}
+
+// KeepsTopLevelUsers reports whether users stay in the top-level users
+// group when they leave their last managed group.
+func (c *Config) KeepsTopLevelUsers() bool {
+	return isTrue(c.KeepTopLevelUsers)
+}
+
+// NameUniquenessEnforced reports whether a new group's short name must not
+// be taken in any other family.
+func (c *Config) NameUniquenessEnforced() bool {
+	return isTrue(c.EnforceNameUniqueness)
+}
+
+// ModifyRetries returns how many times a change refused as busy or
+// unavailable is retried.
+func (c *Config) ModifyRetries() int {
+	if c.LDAPModifyRetries == nil {
+		return DefaultLDAPModifyRetries
+	}
+	return *c.LDAPModifyRetries
+}
+
+// SlowOpThreshold returns how long an LDAP search or change may take before
+// it is logged as slow, or 0 if slow operations aren't logged.
+func (c *Config) SlowOpThreshold() time.Duration {
+	if c.LDAPSlowOpMs == nil {
+		return DefaultLDAPSlowOpMs * time.Millisecond
+	}
+	return time.Duration(*c.LDAPSlowOpMs) * time.Millisecond
+}
+
+// BaseDN returns the DN the namespace's groups are created under, or "" for
+// an unknown namespace.
+func (c *Config) BaseDN(namespace string) string {
+	switch namespace {
+	case "pirg":
+		return c.LDAPPirgDN
+	case "cephfs":
+		return c.LDAPCephfsDN
+	case "cephs3":
+		return c.LDAPCephs3DN
+	case "software":
+		return c.LDAPSoftwareDN
+	}
+	return ""
+}
+
+// AdminsGroupDN returns the top-level group every admin in the namespace is
+// added to, or "" for a namespace without one. Software only has one when
+// software_admins_group_dn is set.
+func (c *Config) AdminsGroupDN(namespace string) string {
+	switch namespace {
+	case "pirg":
+		return c.PirgAdminsGroupDN
+	case "cephfs":
+		return c.CephfsAdminsGroupDN
+	case "cephs3":
+		return c.Cephs3AdminsGroupDN
+	case "software":
+		return c.SoftwareAdminsGroupDN
+	}
+	return ""
+}
+
+// SetBaseDN points the namespace at dn for the rest of the run, as
+// --base-dn does. Unknown namespaces are ignored.
+func (c *Config) SetBaseDN(namespace string, dn string) {
+	switch namespace {
+	case "pirg":
+		c.LDAPPirgDN = dn
+	case "cephfs":
+		c.LDAPCephfsDN = dn
+	case "cephs3":
+		c.LDAPCephs3DN = dn
+	case "software":
+		c.LDAPSoftwareDN = dn
+	}
+}
+
+// BaseDNField returns the setting that holds the namespace's base DN, for
+// error messages.
+func BaseDNField(namespace string) string {
+	return "ldap_" + namespace + "_dn"
+}
+
+// gidRangeNamespaces are the namespaces that may have their own GID range.
+var gidRangeNamespaces = []string{"pirg", "cephfs", "cephs3", "software"}
+
+// namespaceGidRange returns the namespace's own GID range, or zeros if unset.
+func (c *Config) namespaceGidRange(namespace string) (int, int) {
+	switch namespace {
+	case "pirg":
+		return c.PirgMinGid, c.PirgMaxGid
+	case "cephfs":
+		return c.CephfsMinGid, c.CephfsMaxGid
+	case "cephs3":
+		return c.Cephs3MinGid, c.Cephs3MaxGid
+	case "software":
+		return c.SoftwareMinGid, c.SoftwareMaxGid
+	}
+	return 0, 0
+}
+
+// GidRange returns the GID range new groups in the namespace are allocated
+// from, falling back to ldap_min_gid/ldap_max_gid when none is configured.
+func (c *Config) GidRange(namespace string) (int, int) {
+	minGid, maxGid := c.namespaceGidRange(namespace)
+	if minGid == 0 && maxGid == 0 {
+		return c.LDAPMinGid, c.LDAPMaxGid
+	}
+	return minGid, maxGid
+}
+
+// validateGidRanges checks that every configured namespace range is complete
+// and well-formed, and that no two namespaces can be given the same GID.
+// A namespace without its own range uses ldap_min_gid/ldap_max_gid, so that
+// range must not overlap any namespace's own range either.
+func (c *Config) validateGidRanges() error {
+	for _, a := range gidRangeNamespaces {
+		aMin, aMax := c.namespaceGidRange(a)
+		if aMin == 0 && aMax == 0 {
+			continue
+		}
+		if aMin == 0 || aMax == 0 {
+			return fmt.Errorf("%s_min_gid and %s_max_gid must be set together", a, a)
+		}
+		if aMin >= aMax {
+			return fmt.Errorf("%s_min_gid must be less than %s_max_gid", a, a)
+		}
+	}
+	for i, a := range gidRangeNamespaces {
+		aMin, aMax := c.GidRange(a)
+		aOwn := c.hasOwnGidRange(a)
+		for _, b := range gidRangeNamespaces[i+1:] {
+			bMin, bMax := c.GidRange(b)
+			bOwn := c.hasOwnGidRange(b)
+			// Namespaces that both fall back share the global range on purpose
+			if !aOwn && !bOwn {
+				continue
+			}
+			if aMin > bMax || bMin > aMax {
+				continue
+			}
+			switch {
+			case !aOwn:
+				return fmt.Errorf("%s GID range overlaps ldap_min_gid/ldap_max_gid, which %s falls back to", b, a)
+			case !bOwn:
+				return fmt.Errorf("%s GID range overlaps ldap_min_gid/ldap_max_gid, which %s falls back to", a, b)
+			default:
+				return fmt.Errorf("%s and %s GID ranges overlap", a, b)
+			}
+		}
+	}
+	return nil
+}
+
+// hasOwnGidRange reports whether the namespace has a GID range of its own
+// rather than falling back to ldap_min_gid/ldap_max_gid.
+func (c *Config) hasOwnGidRange(namespace string) bool {
+	minGid, maxGid := c.namespaceGidRange(namespace)
+	return minGid != 0 || maxGid != 0
+}
+
+// HighWaterAttribute returns the attribute of gid_highwater_dn holding the
+// high-water mark of the namespace's GID range: the namespace's entry in
+// gid_highwater_attributes, or gid_highwater_attribute for the global range.
+func (c *Config) HighWaterAttribute(namespace string) string {
+	if attribute := c.GidHighWaterAttributes[namespace]; attribute != "" {
+		return attribute
+	}
+	return c.GidHighWaterAttribute
+}
+
+// validateHighWaterAttributes checks that gid_highwater_attributes only
+// names namespaces with their own GID range, and, when gid_highwater_dn is
+// set, that each of them has a mark of its own. A mark shared by two ranges
+// would be ignored by whichever range it isn't in.
+func (c *Config) validateHighWaterAttributes() error {
+	used := map[string]string{strings.ToLower(c.GidHighWaterAttribute): "the global range"}
+	for _, namespace := range gidRangeNamespaces {
+		attribute, ok := c.GidHighWaterAttributes[namespace]
+		minGid, maxGid := c.namespaceGidRange(namespace)
+		ownRange := minGid != 0 || maxGid != 0
+		if !ok {
+			if ownRange && c.GidHighWaterDN != "" {
+				return fmt.Errorf("gid_highwater_attributes needs an attribute for %s, which has its own GID range", namespace)
+			}
+			continue
+		}
+		if !ownRange {
+			return fmt.Errorf("gid_highwater_attributes: %s has no GID range of its own", namespace)
+		}
+		if !attributeNameRegex.MatchString(attribute) {
+			return fmt.Errorf("gid_highwater_attributes: %q is not an attribute name", attribute)
+		}
+		if other, ok := used[strings.ToLower(attribute)]; ok {
+			return fmt.Errorf("gid_highwater_attributes: %s and %s both keep their mark in %s", other, namespace, attribute)
+		}
+		used[strings.ToLower(attribute)] = namespace
+	}
+	for namespace := range c.GidHighWaterAttributes {
+		if !slices.Contains(gidRangeNamespaces, namespace) {
+			return fmt.Errorf("gid_highwater_attributes: unknown namespace %q, want one of %s", namespace, strings.Join(gidRangeNamespaces, ", "))
+		}
+	}
+	return nil
+}
+
+// CheckLDAP reports what is missing to connect to the directory. GetConfig
+// doesn't require these, so commands that never connect, such as schema,
+// work without them.
+func (c *Config) CheckLDAP() error {
+	if c.LDAPServer == "" && len(c.LDAPServers) == 0 {
+		return fmt.Errorf("ldap_server or ldap_servers is required")
+	}
+	if c.LDAPUsername == "" {
+		return fmt.Errorf("ldap_username is required")
+	}
+	if c.LDAPPassword == "" {
+		return fmt.Errorf("ldap_password is required")
+	}
+	return nil
+}
+
+// Servers returns the domain controllers to connect to, in the order they
+// are tried: ldap_servers if set, otherwise ldap_server.
+func (c *Config) Servers() []string {
+	if len(c.LDAPServers) > 0 {
+		return c.LDAPServers
+	}
+	return []string{c.LDAPServer}
+}
+
+// PreferServer moves server to the front of Servers, so it is tried first
+// and the others are only used if it can't be reached.
+func (c *Config) PreferServer(server string) error {
+	servers := c.Servers()
+	i := slices.IndexFunc(servers, func(s string) bool { return strings.EqualFold(s, server) })
+	if i < 0 {
+		return fmt.Errorf("%s is not one of the configured LDAP servers (%s)", server, strings.Join(servers, ", "))
+	}
+	c.LDAPServers = append([]string{servers[i]}, slices.Delete(slices.Clone(servers), i, i+1)...)
+	return nil
+}
+
+// GidScanDNs returns the DNs searched for gidNumbers in use when allocating
+// a GID: gid_scan_base_dns if set, otherwise the base DN of every family.
+func (c *Config) GidScanDNs() []string {
+	if len(c.GidScanBaseDNs) > 0 {
+		return c.GidScanBaseDNs
+	}
+	var dns []string
+	for _, dn := range []string{c.LDAPPirgDN, c.LDAPCephfsDN, c.LDAPCephs3DN, c.LDAPSoftwareDN} {
+		if dn != "" && !slices.ContainsFunc(dns, func(d string) bool { return strings.EqualFold(d, dn) }) {
+			dns = append(dns, dn)
+		}
+	}
+	return dns
+}
+
+// Fingerprint returns a hash of the configuration, so processes can tell
+// whether they were started with the same settings. The bind password and
+// webhook_url, which may carry a token, are left out: the hash is sent to
+// the agent, and neither changes what a query answers.
+func (c *Config) Fingerprint() string {
+	settings := *c
+	settings.LDAPPassword, settings.WebhookURL = "", ""
+	// Config is plain data, and map keys are sorted, so this can't fail and
+	// is stable
+	data, _ := json.Marshal(&settings)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// parseQuotaMap reads a comma-separated list of name=attribute pairs, such
+// as "projects=extensionAttribute10,scratch=extensionAttribute11".
+func parseQuotaMap(s string) (QuotaMap, error) {
+	m := make(QuotaMap)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, attribute, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q is not name=attribute", pair)
+		}
+		m[strings.TrimSpace(name)] = strings.TrimSpace(attribute)
+	}
+	return m, nil
+}
+
+// attributeNameRegex matches LDAP attribute names, such as extensionAttribute10.
+var attributeNameRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]*$`)
+
+// validateQuotaAttributeMap checks that quota_attribute_map only names known
+// quotas, each stored in an attribute of its own.
+func (c *Config) validateQuotaAttributeMap() error {
+	used := make(map[string]string)
+	for name, attribute := range c.QuotaAttributeMap {
+		if !slices.Contains(quota.Names, name) {
+			return fmt.Errorf("quota_attribute_map: unknown quota %q, want one of %s", name, strings.Join(quota.Names, ", "))
+		}
+		if !attributeNameRegex.MatchString(attribute) {
+			return fmt.Errorf("quota_attribute_map: %q is not an attribute name", attribute)
+		}
+		if other, ok := used[strings.ToLower(attribute)]; ok {
+			return fmt.Errorf("quota_attribute_map: %s and %s are both stored in %s", other, name, attribute)
+		}
+		used[strings.ToLower(attribute)] = name
+	}
+	return nil
+}
+
+// splitDNList splits a semicolon-separated list of DNs, dropping empty entries.
+func splitDNList(s string) []string {
+	var dns []string
+	for _, dn := range strings.Split(s, ";") {
+		dn = strings.TrimSpace(dn)
+		if dn != "" {
+			dns = append(dns, dn)
+		}
+	}
+	return dns
+}
+
 func readConfigFile(path string) (*Config, error) {
 	// Open the YAML file
 	yml, err := os.ReadFile(path)
@@ -118,6 +1050,26 @@ func readConfigFile(path string) (*Config, error) {
 	return &c, nil
 }
 
+// readConfigDir reads every *.yaml file in dir in lexical order, each one
+// merged on top of the ones before it. A missing directory is no config.
+func readConfigDir(dir string) (*Config, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+	sort.Strings(paths)
+	var cfg *Config
+	for _, path := range paths {
+		slog.Debug("Reading config drop-in", "path", path)
+		dropIn, err := readConfigFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		cfg = mergeConfigsLeft(cfg, dropIn)
+	}
+	return cfg, nil
+}
+
 func mergeConfigsLeft(cfg1, cfg2 *Config) *Config {
 	if cfg1 == nil {
 		return cfg2
@@ -129,6 +1081,9 @@ func mergeConfigsLeft(cfg1, cfg2 *Config) *Config {
 	if cfg2.LDAPServer != "" {
 		cfg1.LDAPServer = cfg2.LDAPServer
 	}
+	if len(cfg2.LDAPServers) > 0 {
+		cfg1.LDAPServers = cfg2.LDAPServers
+	}
 	if cfg2.LDAPPort != 0 {
 		cfg1.LDAPPort = cfg2.LDAPPort
 	}
@@ -144,9 +1099,27 @@ func mergeConfigsLeft(cfg1, cfg2 *Config) *Config {
 	if cfg2.LDAPGroupsBaseDN != "" {
 		cfg1.LDAPGroupsBaseDN = cfg2.LDAPGroupsBaseDN
 	}
+	if len(cfg2.GidScanBaseDNs) > 0 {
+		cfg1.GidScanBaseDNs = cfg2.GidScanBaseDNs
+	}
 	if cfg2.LDAPPirgDN != "" {
 		cfg1.LDAPPirgDN = cfg2.LDAPPirgDN
 	}
+	if cfg2.PirgMailTemplate != "" {
+		cfg1.PirgMailTemplate = cfg2.PirgMailTemplate
+	}
+	if len(cfg2.PIEligibility.AllowedUserOUs) > 0 {
+		cfg1.PIEligibility.AllowedUserOUs = cfg2.PIEligibility.AllowedUserOUs
+	}
+	if cfg2.PIEligibility.RequiredGroupDN != "" {
+		cfg1.PIEligibility.RequiredGroupDN = cfg2.PIEligibility.RequiredGroupDN
+	}
+	if len(cfg2.OwnerEligibility.AllowedUserOUs) > 0 {
+		cfg1.OwnerEligibility.AllowedUserOUs = cfg2.OwnerEligibility.AllowedUserOUs
+	}
+	if cfg2.OwnerEligibility.RequiredGroupDN != "" {
+		cfg1.OwnerEligibility.RequiredGroupDN = cfg2.OwnerEligibility.RequiredGroupDN
+	}
 	if cfg2.LDAPCephfsDN != "" {
 		cfg1.LDAPCephfsDN = cfg2.LDAPCephfsDN
 	}
@@ -156,15 +1129,160 @@ func mergeConfigsLeft(cfg1, cfg2 *Config) *Config {
 	if cfg2.LDAPSoftwareDN != "" {
 		cfg1.LDAPSoftwareDN = cfg2.LDAPSoftwareDN
 	}
+	if cfg2.UsersGroupDN != "" {
+		cfg1.UsersGroupDN = cfg2.UsersGroupDN
+	}
+	if cfg2.PirgAdminsGroupDN != "" {
+		cfg1.PirgAdminsGroupDN = cfg2.PirgAdminsGroupDN
+	}
+	if cfg2.CephfsAdminsGroupDN != "" {
+		cfg1.CephfsAdminsGroupDN = cfg2.CephfsAdminsGroupDN
+	}
+	if cfg2.Cephs3AdminsGroupDN != "" {
+		cfg1.Cephs3AdminsGroupDN = cfg2.Cephs3AdminsGroupDN
+	}
+	if cfg2.SoftwareLayout != "" {
+		cfg1.SoftwareLayout = cfg2.SoftwareLayout
+	}
+	if cfg2.SoftwareAdminsGroupDN != "" {
+		cfg1.SoftwareAdminsGroupDN = cfg2.SoftwareAdminsGroupDN
+	}
 	if cfg2.LDAPMinGid != 0 {
 		cfg1.LDAPMinGid = cfg2.LDAPMinGid
 	}
 	if cfg2.LDAPMaxGid != 0 {
 		cfg1.LDAPMaxGid = cfg2.LDAPMaxGid
 	}
+	if cfg2.LDAPPageSize != 0 {
+		cfg1.LDAPPageSize = cfg2.LDAPPageSize
+	}
+	if cfg2.PirgMinGid != 0 {
+		cfg1.PirgMinGid = cfg2.PirgMinGid
+	}
+	if cfg2.PirgMaxGid != 0 {
+		cfg1.PirgMaxGid = cfg2.PirgMaxGid
+	}
+	if cfg2.CephfsMinGid != 0 {
+		cfg1.CephfsMinGid = cfg2.CephfsMinGid
+	}
+	if cfg2.CephfsMaxGid != 0 {
+		cfg1.CephfsMaxGid = cfg2.CephfsMaxGid
+	}
+	if cfg2.Cephs3MinGid != 0 {
+		cfg1.Cephs3MinGid = cfg2.Cephs3MinGid
+	}
+	if cfg2.Cephs3MaxGid != 0 {
+		cfg1.Cephs3MaxGid = cfg2.Cephs3MaxGid
+	}
+	if cfg2.SoftwareMinGid != 0 {
+		cfg1.SoftwareMinGid = cfg2.SoftwareMinGid
+	}
+	if cfg2.SoftwareMaxGid != 0 {
+		cfg1.SoftwareMaxGid = cfg2.SoftwareMaxGid
+	}
+	if cfg2.LDAPModifyRetries != nil {
+		cfg1.LDAPModifyRetries = cfg2.LDAPModifyRetries
+	}
+	if cfg2.LDAPSlowOpMs != nil {
+		cfg1.LDAPSlowOpMs = cfg2.LDAPSlowOpMs
+	}
+	if cfg2.EnforceGidUniqueness != nil {
+		cfg1.EnforceGidUniqueness = cfg2.EnforceGidUniqueness
+	}
+	if cfg2.EnablePirg != nil {
+		cfg1.EnablePirg = cfg2.EnablePirg
+	}
+	if cfg2.EnableCeph != nil {
+		cfg1.EnableCeph = cfg2.EnableCeph
+	}
+	if cfg2.EnableCephfs != nil {
+		cfg1.EnableCephfs = cfg2.EnableCephfs
+	}
+	if cfg2.EnableCephs3 != nil {
+		cfg1.EnableCephs3 = cfg2.EnableCephs3
+	}
+	if cfg2.EnableSoftware != nil {
+		cfg1.EnableSoftware = cfg2.EnableSoftware
+	}
+	if cfg2.GidHighWaterDN != "" {
+		cfg1.GidHighWaterDN = cfg2.GidHighWaterDN
+	}
+	if cfg2.GidHighWaterAttribute != "" {
+		cfg1.GidHighWaterAttribute = cfg2.GidHighWaterAttribute
+	}
+	if len(cfg2.GidHighWaterAttributes) > 0 {
+		cfg1.GidHighWaterAttributes = cfg2.GidHighWaterAttributes
+	}
+	if cfg2.MaintenanceDN != "" {
+		cfg1.MaintenanceDN = cfg2.MaintenanceDN
+	}
+	if cfg2.MaintenanceAttribute != "" {
+		cfg1.MaintenanceAttribute = cfg2.MaintenanceAttribute
+	}
+	if cfg2.GidUsageWarnPercent != 0 {
+		cfg1.GidUsageWarnPercent = cfg2.GidUsageWarnPercent
+	}
+	if cfg2.RequireSubgroupDescription != nil {
+		cfg1.RequireSubgroupDescription = cfg2.RequireSubgroupDescription
+	}
+	if cfg2.MemberRangeSize != 0 {
+		cfg1.MemberRangeSize = cfg2.MemberRangeSize
+	}
+	if cfg2.AllowDisabledMembers != nil {
+		cfg1.AllowDisabledMembers = cfg2.AllowDisabledMembers
+	}
 	if cfg2.DataPath != "" {
 		cfg1.DataPath = cfg2.DataPath
 	}
+	if cfg2.AgentIdleMinutes != 0 {
+		cfg1.AgentIdleMinutes = cfg2.AgentIdleMinutes
+	}
+	if cfg2.OpCacheTTLMinutes != 0 {
+		cfg1.OpCacheTTLMinutes = cfg2.OpCacheTTLMinutes
+	}
+	if cfg2.RequireReason != nil {
+		cfg1.RequireReason = cfg2.RequireReason
+	}
+	if cfg2.KeepTopLevelUsers != nil {
+		cfg1.KeepTopLevelUsers = cfg2.KeepTopLevelUsers
+	}
+	if cfg2.ChangeNoteLimit != 0 {
+		cfg1.ChangeNoteLimit = cfg2.ChangeNoteLimit
+	}
+	if cfg2.Cephs3PolicyTemplate != "" {
+		cfg1.Cephs3PolicyTemplate = cfg2.Cephs3PolicyTemplate
+	}
+	if cfg2.WebhookURL != "" {
+		cfg1.WebhookURL = cfg2.WebhookURL
+	}
+	if len(cfg2.QuotaAttributeMap) > 0 {
+		cfg1.QuotaAttributeMap = cfg2.QuotaAttributeMap
+	}
+	if cfg2.EnforceNameUniqueness != nil {
+		cfg1.EnforceNameUniqueness = cfg2.EnforceNameUniqueness
+	}
+	// Aliases from later files add to earlier ones rather than replacing them
+	for name, expansion := range cfg2.Aliases {
+		if cfg1.Aliases == nil {
+			cfg1.Aliases = make(map[string]string)
+		}
+		cfg1.Aliases[name] = expansion
+	}
+	if cfg2.LogFormat != "" {
+		cfg1.LogFormat = cfg2.LogFormat
+	}
+	if cfg2.LogLevel != "" {
+		cfg1.LogLevel = cfg2.LogLevel
+	}
+	if cfg2.LogFile != "" {
+		cfg1.LogFile = cfg2.LogFile
+	}
+	if cfg2.LogFileMaxSizeMB != 0 {
+		cfg1.LogFileMaxSizeMB = cfg2.LogFileMaxSizeMB
+	}
+	if cfg2.DisableUpdateCheck != nil {
+		cfg1.DisableUpdateCheck = cfg2.DisableUpdateCheck
+	}
 
 	return cfg1
 }
@@ -185,27 +1303,24 @@ func GetConfig(path string) (*Config, error) {
 	} else if !os.IsNotExist(err) {
 		return nil, fmt.Errorf("failed to stat config file: %w", err)
 	}
+	// Drop-ins in config.d next to the config file override it
+	dropInCfg, err := readConfigDir(filepath.Join(filepath.Dir(configPath), "config.d"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config drop-ins: %w", err)
+	}
 	envCfg, err := loadEnvironment()
 	if err != nil {
 		return nil, fmt.Errorf("failed to load environment variables: %w", err)
 	}
-	cfg := mergeConfigsLeft(fileCfg, envCfg)
+	cfg := mergeConfigsLeft(mergeConfigsLeft(fileCfg, dropInCfg), envCfg)
 
 	// Set unconfigurable values
 
-	// Validate the config values and set defaults
-	if cfg.LDAPServer == "" {
-		return nil, fmt.Errorf("ldap_server is required")
-	}
+	// Validate the config values and set defaults. The server and
+	// credentials are only required to connect; see CheckLDAP.
 	if cfg.LDAPPort == 0 {
 		cfg.LDAPPort = 636
 	}
-	if cfg.LDAPUsername == "" {
-		return nil, fmt.Errorf("ldap_username is required")
-	}
-	if cfg.LDAPPassword == "" {
-		return nil, fmt.Errorf("ldap_password is required")
-	}
 	if cfg.LDAPUsersBaseDN == "" {
 		cfg.LDAPUsersBaseDN = "dc=ad,dc=uoregon,dc=edu"
 	}
@@ -224,6 +1339,24 @@ func GetConfig(path string) (*Config, error) {
 	if cfg.LDAPSoftwareDN == "" {
 		cfg.LDAPSoftwareDN = "ou=Software,ou=RACS,ou=Groups,ou=IS,ou=units,dc=ad,dc=uoregon,dc=edu"
 	}
+	if cfg.UsersGroupDN == "" {
+		cfg.UsersGroupDN = "CN=IS.RACS.Talapas.Users,OU=RACS,OU=Groups,OU=IS,OU=Units,DC=ad,DC=uoregon,DC=edu"
+	}
+	if cfg.PirgAdminsGroupDN == "" {
+		cfg.PirgAdminsGroupDN = "CN=IS.RACS.Talapas.PirgAdmins,OU=RACS,OU=Groups,OU=IS,OU=Units,DC=ad,DC=uoregon,DC=edu"
+	}
+	if cfg.CephfsAdminsGroupDN == "" {
+		cfg.CephfsAdminsGroupDN = "CN=IS.RACS.Talapas.CephfsAdmins,OU=RACS,OU=Groups,OU=IS,OU=Units,DC=ad,DC=uoregon,DC=edu"
+	}
+	if cfg.Cephs3AdminsGroupDN == "" {
+		cfg.Cephs3AdminsGroupDN = "CN=IS.RACS.Talapas.CephS3Admins,OU=RACS,OU=Groups,OU=IS,OU=Units,DC=ad,DC=uoregon,DC=edu"
+	}
+	if cfg.SoftwareLayout == "" {
+		cfg.SoftwareLayout = "flat"
+	}
+	if cfg.SoftwareLayout != "flat" && cfg.SoftwareLayout != "ou" {
+		return nil, fmt.Errorf("software_layout must be flat or ou")
+	}
 	if cfg.LDAPMinGid == 0 {
 		cfg.LDAPMinGid = 50000
 	}
@@ -233,9 +1366,87 @@ func GetConfig(path string) (*Config, error) {
 	if cfg.LDAPMinGid >= cfg.LDAPMaxGid {
 		return nil, fmt.Errorf("ldap_min_gid must be less than ldap_max_gid")
 	}
+	if err := cfg.validateGidRanges(); err != nil {
+		return nil, err
+	}
+	if cfg.LDAPPageSize == 0 {
+		cfg.LDAPPageSize = DefaultLDAPPageSize
+	}
+	cfg.LDAPPageSize = ClampPageSize(cfg.LDAPPageSize)
+	if cfg.LDAPModifyRetries == nil {
+		retries := DefaultLDAPModifyRetries
+		cfg.LDAPModifyRetries = &retries
+	}
+	if *cfg.LDAPModifyRetries < 0 {
+		return nil, fmt.Errorf("ldap_modify_retries must not be negative")
+	}
+	if cfg.LDAPSlowOpMs != nil && *cfg.LDAPSlowOpMs < 0 {
+		return nil, fmt.Errorf("ldap_slow_op_ms must not be negative")
+	}
+	if cfg.EnforceGidUniqueness == nil {
+		enforce := true
+		cfg.EnforceGidUniqueness = &enforce
+	}
+	if cfg.GidHighWaterAttribute == "" {
+		cfg.GidHighWaterAttribute = "uidNumber"
+	}
+	if err := cfg.validateHighWaterAttributes(); err != nil {
+		return nil, err
+	}
+	if cfg.MaintenanceAttribute == "" {
+		cfg.MaintenanceAttribute = "info"
+	}
+	if cfg.GidUsageWarnPercent == 0 {
+		cfg.GidUsageWarnPercent = 90
+	}
+	if cfg.GidUsageWarnPercent < 0 || cfg.GidUsageWarnPercent > 100 {
+		return nil, fmt.Errorf("gid_usage_warn_percent must be between 1 and 100")
+	}
+	if cfg.MemberRangeSize < 0 {
+		return nil, fmt.Errorf("member_range_size must not be negative")
+	}
 	if cfg.DataPath == "" {
 		cfg.DataPath = "/var/lib/directory-manager"
 	}
+	if cfg.AgentIdleMinutes == 0 {
+		cfg.AgentIdleMinutes = 15
+	}
+	if cfg.AgentIdleMinutes < 0 {
+		return nil, fmt.Errorf("agent_idle_minutes must not be negative")
+	}
+	if cfg.OpCacheTTLMinutes == 0 {
+		cfg.OpCacheTTLMinutes = 10
+	}
+	if cfg.OpCacheTTLMinutes < 0 {
+		return nil, fmt.Errorf("op_cache_ttl_minutes must not be negative")
+	}
+	if cfg.ChangeNoteLimit == 0 {
+		cfg.ChangeNoteLimit = 5
+	}
+	if cfg.ChangeNoteLimit < 0 {
+		return nil, fmt.Errorf("change_note_limit must not be negative")
+	}
+	if cfg.WebhookURL != "" {
+		u, err := url.Parse(cfg.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("webhook_url must be an http or https URL")
+		}
+	}
+	if err := cfg.validateQuotaAttributeMap(); err != nil {
+		return nil, err
+	}
+	if cfg.LogFormat == "" {
+		cfg.LogFormat = "text"
+	}
+	if cfg.LogFormat != "text" && cfg.LogFormat != "json" {
+		return nil, fmt.Errorf("log_format must be text or json")
+	}
+	if cfg.LogLevel == "" {
+		cfg.LogLevel = "info"
+	}
+	if cfg.LogFileMaxSizeMB == 0 {
+		cfg.LogFileMaxSizeMB = 100
+	}
 
 	return cfg, nil
 }
@@ -0,0 +1,250 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeConfig writes base to config.yaml and dropIn, if not empty, to a
+// drop-in in config.d next to it, returning the config file's path.
+func writeConfig(t *testing.T, base string, dropIn string) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(base), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if dropIn != "" {
+		if err := os.Mkdir(filepath.Join(dir, "config.d"), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "config.d", "override.yaml"), []byte(dropIn), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+// boolSettings are the settings that are off unless turned on, with how
+// to read each one.
+var boolSettings = []struct {
+	key string
+	env string
+	get func(*Config) bool
+}{
+	{"require_subgroup_description", "DIRECTORY_MANAGER_REQUIRE_SUBGROUP_DESCRIPTION", (*Config).SubgroupDescriptionRequired},
+	{"allow_disabled_members", "DIRECTORY_MANAGER_ALLOW_DISABLED_MEMBERS", (*Config).DisabledMembersAllowed},
+	{"disable_update_check", "DIRECTORY_MANAGER_DISABLE_UPDATE_CHECK", (*Config).UpdateCheckDisabled},
+	{"require_reason", "DIRECTORY_MANAGER_REQUIRE_REASON", (*Config).ReasonRequired},
+	{"keep_top_level_users_on_last_removal", "DIRECTORY_MANAGER_KEEP_TOP_LEVEL_USERS_ON_LAST_REMOVAL", (*Config).KeepsTopLevelUsers},
+	{"enforce_name_uniqueness", "DIRECTORY_MANAGER_ENFORCE_NAME_UNIQUENESS", (*Config).NameUniquenessEnforced},
+}
+
+func TestBoolSettingsOverride(t *testing.T) {
+	for _, s := range boolSettings {
+		t.Run(s.key, func(t *testing.T) {
+			cfg, err := GetConfig(writeConfig(t, "", ""))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.get(cfg) {
+				t.Errorf("%s is on by default", s.key)
+			}
+
+			cfg, err = GetConfig(writeConfig(t, s.key+": true\n", s.key+": false\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.get(cfg) {
+				t.Errorf("a drop-in setting %s to false didn't override true", s.key)
+			}
+
+			t.Setenv(s.env, "false")
+			cfg, err = GetConfig(writeConfig(t, s.key+": true\n", ""))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.get(cfg) {
+				t.Errorf("%s=false didn't override true", s.env)
+			}
+		})
+	}
+}
+
+func TestAdminsGroupDN(t *testing.T) {
+	cfg, err := GetConfig(writeConfig(t, "", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.UsersGroupDN != "CN=IS.RACS.Talapas.Users,OU=RACS,OU=Groups,OU=IS,OU=Units,DC=ad,DC=uoregon,DC=edu" {
+		t.Errorf("default users_group_dn = %q", cfg.UsersGroupDN)
+	}
+	if got := cfg.AdminsGroupDN("cephfs"); got != "CN=IS.RACS.Talapas.CephfsAdmins,OU=RACS,OU=Groups,OU=IS,OU=Units,DC=ad,DC=uoregon,DC=edu" {
+		t.Errorf("default cephfs admins group = %q", got)
+	}
+	if got := cfg.AdminsGroupDN("software"); got != "" {
+		t.Errorf("software admins group = %q without software_admins_group_dn", got)
+	}
+
+	t.Setenv("DIRECTORY_MANAGER_CEPHS3_ADMINS_GROUP_DN", "CN=S3Admins,DC=env")
+	cfg, err = GetConfig(writeConfig(t,
+		"pirg_admins_group_dn: CN=PirgAdmins,DC=base\ncephs3_admins_group_dn: CN=S3Admins,DC=base\n",
+		"pirg_admins_group_dn: CN=PirgAdmins,DC=dropin\nsoftware_admins_group_dn: CN=SoftwareAdmins,DC=dropin\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"pirg":     "CN=PirgAdmins,DC=dropin",
+		"cephs3":   "CN=S3Admins,DC=env",
+		"software": "CN=SoftwareAdmins,DC=dropin",
+		"nosuch":   "",
+	}
+	for namespace, dn := range want {
+		if got := cfg.AdminsGroupDN(namespace); got != dn {
+			t.Errorf("AdminsGroupDN(%q) = %q, want %q", namespace, got, dn)
+		}
+	}
+}
+
+func TestDisabledSubsystem(t *testing.T) {
+	families := []string{"pirg", "cephfs", "cephs3", "software", "aduser"}
+	cases := []struct {
+		yaml string
+		want []string
+	}{
+		{yaml: "", want: []string{"", "", "", "", ""}},
+		{yaml: "enable_pirg: false\n", want: []string{"pirg", "", "", "", ""}},
+		{yaml: "enable_ceph: false\n", want: []string{"", "ceph", "ceph", "", ""}},
+		{yaml: "enable_cephfs: false\n", want: []string{"", "cephfs", "", "", ""}},
+		{yaml: "enable_cephs3: false\n", want: []string{"", "", "cephs3", "", ""}},
+		{yaml: "enable_ceph: false\nenable_cephfs: true\n", want: []string{"", "ceph", "ceph", "", ""}},
+		{yaml: "enable_software: false\n", want: []string{"", "", "", "software", ""}},
+	}
+	for _, tc := range cases {
+		cfg, err := GetConfig(writeConfig(t, tc.yaml, ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, family := range families {
+			if got := cfg.DisabledSubsystem(family); got != tc.want[i] {
+				t.Errorf("with %q, DisabledSubsystem(%q) = %q, want %q", tc.yaml, family, got, tc.want[i])
+			}
+		}
+	}
+
+	t.Setenv("DIRECTORY_MANAGER_ENABLE_SOFTWARE", "false")
+	cfg, err := GetConfig(writeConfig(t, "enable_software: true\n", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.DisabledSubsystem("software"); got != "software" {
+		t.Errorf("DIRECTORY_MANAGER_ENABLE_SOFTWARE=false didn't disable software, got %q", got)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	base := Config{LDAPServer: "dc1.example.edu", LDAPPirgDN: "OU=PIRGS,DC=example,DC=edu"}
+	same := base
+	if base.Fingerprint() != same.Fingerprint() {
+		t.Error("equal configs have different fingerprints")
+	}
+	other := base
+	other.LDAPPirgDN = "OU=PIRGS,DC=other,DC=edu"
+	if base.Fingerprint() == other.Fingerprint() {
+		t.Error("configs with different base DNs have the same fingerprint")
+	}
+	off := false
+	other = base
+	other.EnforceNameUniqueness = &off
+	if base.Fingerprint() == other.Fingerprint() {
+		t.Error("setting a boolean didn't change the fingerprint")
+	}
+	other = base
+	other.LDAPPassword = "hunter2"
+	other.WebhookURL = "https://hooks.example.edu/?token=secret"
+	if base.Fingerprint() != other.Fingerprint() {
+		t.Error("the password or webhook URL changed the fingerprint")
+	}
+	if other.LDAPPassword != "hunter2" {
+		t.Error("Fingerprint cleared the password")
+	}
+}
+
+func TestValidateGidRanges(t *testing.T) {
+	cases := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{
+			name: "global range only",
+			cfg:  Config{LDAPMinGid: 100000, LDAPMaxGid: 199999},
+		},
+		{
+			name: "every namespace has its own range",
+			cfg: Config{
+				LDAPMinGid: 100000, LDAPMaxGid: 199999,
+				PirgMinGid: 100000, PirgMaxGid: 109999,
+				CephfsMinGid: 110000, CephfsMaxGid: 119999,
+				Cephs3MinGid: 120000, Cephs3MaxGid: 129999,
+				SoftwareMinGid: 130000, SoftwareMaxGid: 139999,
+			},
+		},
+		{
+			name: "own range beside the fallback",
+			cfg: Config{
+				LDAPMinGid: 100000, LDAPMaxGid: 199999,
+				SoftwareMinGid: 200000, SoftwareMaxGid: 209999,
+			},
+		},
+		{
+			name: "own range inside the fallback",
+			cfg: Config{
+				LDAPMinGid: 100000, LDAPMaxGid: 199999,
+				SoftwareMinGid: 150000, SoftwareMaxGid: 159999,
+			},
+			wantErr: "software GID range overlaps ldap_min_gid/ldap_max_gid, which pirg falls back to",
+		},
+		{
+			name: "own range touching the fallback",
+			cfg: Config{
+				LDAPMinGid: 100000, LDAPMaxGid: 199999,
+				PirgMinGid: 199999, PirgMaxGid: 209999,
+			},
+			wantErr: "pirg GID range overlaps ldap_min_gid/ldap_max_gid, which cephfs falls back to",
+		},
+		{
+			name: "two own ranges overlap",
+			cfg: Config{
+				PirgMinGid: 100000, PirgMaxGid: 109999,
+				CephfsMinGid: 105000, CephfsMaxGid: 119999,
+			},
+			wantErr: "pirg and cephfs GID ranges overlap",
+		},
+		{
+			name:    "half a range",
+			cfg:     Config{CephfsMinGid: 100000},
+			wantErr: "cephfs_min_gid and cephfs_max_gid must be set together",
+		},
+		{
+			name:    "backwards range",
+			cfg:     Config{Cephs3MinGid: 120000, Cephs3MaxGid: 110000},
+			wantErr: "cephs3_min_gid must be less than cephs3_max_gid",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.cfg.validateGidRanges()
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateGidRanges() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.wantErr {
+				t.Errorf("validateGidRanges() = %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package consistency
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+)
+
+// StrayAdminFix is how Check repairs an admin who isn't in the main group.
+type StrayAdminFix string
+
+const (
+	// StrayAdminReport leaves such admins for a person to decide on.
+	StrayAdminReport StrayAdminFix = ""
+	// StrayAdminAddToMain adds them to the main group.
+	StrayAdminAddToMain StrayAdminFix = "add-to-main"
+	// StrayAdminRemoveFromAdmins removes them from the admins group.
+	StrayAdminRemoveFromAdmins StrayAdminFix = "remove-from-admins"
+)
+
+// Group describes the AD groups that make up one managed group, such as a
+// cephfs share: its main group, its admins group, the role group holding its
+// single owner (or PI), and its subgroups.
+type Group struct {
+	Name        string
+	MainDN      string
+	AdminsDN    string
+	RoleDN      string
+	RoleName    string // what the role group holds, e.g. "owner" or "PI"
+	SubgroupDNs []string
+	// StrayAdminFix chooses the repair for admins missing from the main group.
+	StrayAdminFix StrayAdminFix
+	// RoleHolderNotMember is set when the role holder is deliberately not
+	// in the main group, such as a PI created with --no-member.
+	RoleHolderNotMember bool
+}
+
+// Problem is a single inconsistency found by Check. Fix is nil when the
+// problem needs a person to resolve it.
+type Problem struct {
+	Description string
+	Fix         func(ctx context.Context) error
+}
+
+// Fixable reports whether Check knows how to repair the problem.
+func (p Problem) Fixable() bool {
+	return p.Fix != nil
+}
+
+// Check reports inconsistencies in g:
+//   - the role group must have exactly one member
+//   - the role holder must be in the admins group, and the main group
+//     unless g.RoleHolderNotMember is set
+//   - admins must be members of the main group; g.StrayAdminFix says how to fix this
+//   - subgroup members must be members of the main group
+//   - no group may list a member DN that no longer exists
+//
+// While the main group is frozen, the fixes are refused.
+func Check(ctx context.Context, g Group) ([]Problem, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	slog.Debug("Checking group consistency", "name", g.Name)
+
+	groupDNs := append([]string{g.MainDN, g.AdminsDN, g.RoleDN}, g.SubgroupDNs...)
+	membersByGroup := make(map[string][]string, len(groupDNs))
+	var allMembers []string
+	seen := make(map[string]bool)
+	for _, groupDN := range groupDNs {
+		members, err := ld.GetGroupMemberDNs(ctx, groupDN)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get members of %s: %w", groupDN, err)
+		}
+		membersByGroup[groupDN] = members
+		for _, m := range members {
+			if !seen[strings.ToLower(m)] {
+				seen[strings.ToLower(m)] = true
+				allMembers = append(allMembers, m)
+			}
+		}
+	}
+
+	dangling, err := danglingDNs(ctx, allMembers)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []Problem
+
+	// Dangling DNs are reported once per group that lists them, and are
+	// otherwise ignored so they don't also show up as missing memberships.
+	for _, groupDN := range groupDNs {
+		for _, m := range membersByGroup[groupDN] {
+			if !dangling[strings.ToLower(m)] {
+				continue
+			}
+			problems = append(problems, Problem{
+				Description: fmt.Sprintf("%s lists member %s, which no longer exists", groupName(groupDN), m),
+				Fix:         removeMember(groupDN, m),
+			})
+		}
+	}
+
+	mainMembers := dnSet(membersByGroup[g.MainDN])
+	adminMembers := dnSet(membersByGroup[g.AdminsDN])
+
+	var role []string
+	for _, m := range membersByGroup[g.RoleDN] {
+		if !dangling[strings.ToLower(m)] {
+			role = append(role, m)
+		}
+	}
+	switch len(role) {
+	case 0:
+		problems = append(problems, Problem{
+			Description: fmt.Sprintf("%s has no %s", g.Name, g.RoleName),
+		})
+	case 1:
+		holder := role[0]
+		if !adminMembers[strings.ToLower(holder)] {
+			problems = append(problems, Problem{
+				Description: fmt.Sprintf("%s %s is not in the admins group", g.RoleName, holder),
+				Fix:         addMember(g.AdminsDN, holder),
+			})
+		}
+		if !mainMembers[strings.ToLower(holder)] {
+			if !g.RoleHolderNotMember {
+				problems = append(problems, Problem{
+					Description: fmt.Sprintf("%s %s is not a member", g.RoleName, holder),
+					Fix:         addMember(g.MainDN, holder),
+				})
+			}
+			mainMembers[strings.ToLower(holder)] = true
+		}
+	default:
+		problems = append(problems, Problem{
+			Description: fmt.Sprintf("%s has %d %ss: %s", g.Name, len(role), g.RoleName, strings.Join(role, "; ")),
+		})
+	}
+
+	for _, m := range membersByGroup[g.AdminsDN] {
+		if dangling[strings.ToLower(m)] || mainMembers[strings.ToLower(m)] {
+			continue
+		}
+		p := Problem{Description: fmt.Sprintf("admin %s is not a member", m)}
+		switch g.StrayAdminFix {
+		case StrayAdminReport:
+			p.Description += "; it can be added as a member or removed from the admins"
+		case StrayAdminAddToMain:
+			p.Fix = addMember(g.MainDN, m)
+			mainMembers[strings.ToLower(m)] = true
+		case StrayAdminRemoveFromAdmins:
+			p.Fix = removeMember(g.AdminsDN, m)
+		}
+		problems = append(problems, p)
+	}
+
+	// Subgroup members outside the main group are removed rather than
+	// added, since subgroup membership shouldn't grant access to the parent.
+	for _, subgroupDN := range g.SubgroupDNs {
+		for _, m := range membersByGroup[subgroupDN] {
+			if dangling[strings.ToLower(m)] || mainMembers[strings.ToLower(m)] {
+				continue
+			}
+			problems = append(problems, Problem{
+				Description: fmt.Sprintf("subgroup %s member %s is not a member of %s", groupName(subgroupDN), m, g.Name),
+				Fix:         removeMember(subgroupDN, m),
+			})
+		}
+	}
+
+	// A frozen group is only checked; its fixes are refused like any other
+	// change to it
+	for i, p := range problems {
+		if p.Fix != nil {
+			problems[i].Fix = unlessFrozen(g.MainDN, p.Fix)
+		}
+	}
+
+	slog.Debug("Checked group consistency", "name", g.Name, "problems", len(problems))
+	return problems, nil
+}
+
+// unlessFrozen returns fix, refused with ErrFrozen while the group at
+// mainDN is frozen.
+func unlessFrozen(mainDN string, fix func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if err := ld.CheckNotFrozen(ctx, mainDN); err != nil {
+			return err
+		}
+		return fix(ctx)
+	}
+}
+
+// danglingDNs returns the lowercased DNs in memberDNs that no longer exist.
+// Members are looked up in bulk first; only those not found under the users
+// base DN are checked individually.
+func danglingDNs(ctx context.Context, memberDNs []string) (map[string]bool, error) {
+	found, err := ld.GetUserAttributes(ctx, memberDNs, []string{"distinguishedName"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up members: %w", err)
+	}
+	dangling := make(map[string]bool)
+	for _, m := range memberDNs {
+		if _, ok := found[strings.ToLower(m)]; ok {
+			continue
+		}
+		exists, err := ld.DNExists(ctx, m)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check if %s exists: %w", m, err)
+		}
+		if !exists {
+			dangling[strings.ToLower(m)] = true
+		}
+	}
+	return dangling, nil
+}
+
+func dnSet(dns []string) map[string]bool {
+	set := make(map[string]bool, len(dns))
+	for _, dn := range dns {
+		set[strings.ToLower(dn)] = true
+	}
+	return set
+}
+
+// groupName returns the CN of groupDN for use in messages.
+func groupName(groupDN string) string {
+	name, err := ld.ConvertDNToObjectName(groupDN)
+	if err != nil {
+		return groupDN
+	}
+	return name
+}
+
+func addMember(groupDN string, memberDN string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		slog.Debug("Adding member to fix consistency", "group", groupDN, "member", memberDN)
+		return ld.AddUserToGroup(ctx, groupDN, memberDN)
+	}
+}
+
+func removeMember(groupDN string, memberDN string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		slog.Debug("Removing member to fix consistency", "group", groupDN, "member", memberDN)
+		return ld.RemoveUserFromGroup(ctx, groupDN, memberDN)
+	}
+}
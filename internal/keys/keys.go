@@ -6,4 +6,10 @@ const (
 	ConfigKey   Key = "config"
 	LDAPConnKey Key = "ldap_conn"
 	GidCacheKey Key = "gid_cache"
+	// OverridePolicyKey is set to true to let eligibility policy checks pass.
+	OverridePolicyKey Key = "override_policy"
+	// AllowDisabledKey is set to true to let disabled and expired accounts be added.
+	AllowDisabledKey Key = "allow_disabled"
+	// OverrideFreezeKey is set to true to let changes to frozen groups through.
+	OverrideFreezeKey Key = "override_freeze"
 )
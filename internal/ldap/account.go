@@ -0,0 +1,130 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+)
+
+// ErrAccountInactive is returned, wrapped, when a disabled or expired
+// account would be added to a group.
+var ErrAccountInactive = errors.New("account is not active")
+
+const (
+	// uacAccountDisable is the ACCOUNTDISABLE bit of userAccountControl.
+	uacAccountDisable = 0x2
+	// accountNeverExpires is the accountExpires value AD uses, besides 0,
+	// for accounts without an expiry date.
+	accountNeverExpires = 0x7FFFFFFFFFFFFFFF
+	// fileTimeUnixOffset is the number of seconds from 1601-01-01, the epoch
+	// of the 100ns intervals accountExpires counts, to the Unix epoch.
+	fileTimeUnixOffset = 11644473600
+)
+
+// AccountStatus is whether an account can log in, decoded from its
+// userAccountControl and accountExpires attributes.
+type AccountStatus struct {
+	Disabled bool
+	// Expires is zero for accounts that never expire.
+	Expires time.Time
+}
+
+// Active reports whether the account is enabled and unexpired at now.
+func (s AccountStatus) Active(now time.Time) bool {
+	return !s.Disabled && (s.Expires.IsZero() || now.Before(s.Expires))
+}
+
+// Describe says why the account isn't active at now, or "active".
+func (s AccountStatus) Describe(now time.Time) string {
+	switch {
+	case s.Disabled:
+		return "disabled"
+	case !s.Expires.IsZero() && !now.Before(s.Expires):
+		return "expired since " + s.Expires.Format(time.DateOnly)
+	}
+	return "active"
+}
+
+// DecodeAccountStatus decodes the userAccountControl and accountExpires
+// attribute values of an account. Empty values are treated as unset.
+func DecodeAccountStatus(userAccountControl string, accountExpires string) (AccountStatus, error) {
+	var s AccountStatus
+	if userAccountControl != "" {
+		uac, err := strconv.ParseInt(userAccountControl, 10, 64)
+		if err != nil {
+			return s, fmt.Errorf("invalid userAccountControl %q: %w", userAccountControl, err)
+		}
+		s.Disabled = uac&uacAccountDisable != 0
+	}
+	if accountExpires != "" {
+		ticks, err := strconv.ParseInt(accountExpires, 10, 64)
+		if err != nil {
+			return s, fmt.Errorf("invalid accountExpires %q: %w", accountExpires, err)
+		}
+		if ticks != 0 && ticks != accountNeverExpires {
+			s.Expires = time.Unix(ticks/1e7-fileTimeUnixOffset, ticks%1e7*100).UTC()
+		}
+	}
+	return s, nil
+}
+
+// GetAccountStatus looks up the account status of the user at userDN.
+func GetAccountStatus(ctx context.Context, userDN string) (AccountStatus, error) {
+	l, err := Conn(ctx)
+	if err != nil {
+		return AccountStatus{}, err
+	}
+
+	searchRequest := ldap.NewSearchRequest(
+		userDN,
+		ldap.ScopeBaseObject,
+		ldap.NeverDerefAliases,
+		0, 0, false,
+		"(objectClass=*)",
+		[]string{"userAccountControl", "accountExpires"},
+		nil,
+	)
+	sr, err := l.Search(searchRequest)
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return AccountStatus{}, fmt.Errorf("user %s %w", userDN, ErrNotFound)
+		}
+		return AccountStatus{}, fmt.Errorf("failed to search LDAP: %w", err)
+	}
+	if len(sr.Entries) == 0 {
+		return AccountStatus{}, fmt.Errorf("user %s %w", userDN, ErrNotFound)
+	}
+	entry := sr.Entries[0]
+	return DecodeAccountStatus(entry.GetAttributeValue("userAccountControl"), entry.GetAttributeValue("accountExpires"))
+}
+
+// CheckAccountActive refuses disabled and expired accounts unless
+// allow_disabled_members is set or the context carries
+// keys.AllowDisabledKey, in which case it logs a warning and allows them.
+func CheckAccountActive(ctx context.Context, username string, userDN string) error {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return fmt.Errorf("config not found in context")
+	}
+	status, err := GetAccountStatus(ctx, userDN)
+	if err != nil {
+		return fmt.Errorf("failed to get account status of %s: %w", username, err)
+	}
+	now := time.Now()
+	if status.Active(now) {
+		return nil
+	}
+	allow, _ := ctx.Value(keys.AllowDisabledKey).(bool)
+	if allow || cfg.DisabledMembersAllowed() {
+		slog.Warn("Allowing inactive account", "username", username, "status", status.Describe(now))
+		return nil
+	}
+	return fmt.Errorf("account %s is %s, use --allow-disabled to add it anyway: %w", username, status.Describe(now), ErrAccountInactive)
+}
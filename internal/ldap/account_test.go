@@ -0,0 +1,175 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+	"github.com/uoracs/directory-manager/internal/ldap/ldaptest"
+)
+
+func TestDecodeAccountStatus(t *testing.T) {
+	newYear := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name         string
+		uac, expires string
+		want         AccountStatus
+		wantErr      bool
+		activeAt     time.Time
+		wantActive   bool
+		wantDescribe string
+	}{
+		{
+			name:         "unset",
+			want:         AccountStatus{},
+			activeAt:     newYear,
+			wantActive:   true,
+			wantDescribe: "active",
+		},
+		{
+			name:         "normal account",
+			uac:          "512",
+			expires:      "0",
+			activeAt:     newYear,
+			wantActive:   true,
+			wantDescribe: "active",
+		},
+		{
+			name:         "disabled",
+			uac:          "514",
+			want:         AccountStatus{Disabled: true},
+			activeAt:     newYear,
+			wantDescribe: "disabled",
+		},
+		{
+			name:         "never expires",
+			uac:          "66048",
+			expires:      "9223372036854775807",
+			activeAt:     newYear,
+			wantActive:   true,
+			wantDescribe: "active",
+		},
+		{
+			name:         "expires in the future",
+			expires:      "134116992000000000",
+			want:         AccountStatus{Expires: newYear},
+			activeAt:     newYear.Add(-time.Second),
+			wantActive:   true,
+			wantDescribe: "active",
+		},
+		{
+			name:         "expired",
+			expires:      "134116992000000000",
+			want:         AccountStatus{Expires: newYear},
+			activeAt:     newYear,
+			wantDescribe: "expired since 2026-01-01",
+		},
+		{
+			name:         "sub-second expiry",
+			expires:      "134116992000000015",
+			want:         AccountStatus{Expires: newYear.Add(1500 * time.Nanosecond)},
+			activeAt:     newYear.Add(time.Microsecond),
+			wantActive:   true,
+			wantDescribe: "active",
+		},
+		{
+			name:         "disabled wins over expired",
+			uac:          "2",
+			expires:      "134116992000000000",
+			want:         AccountStatus{Disabled: true, Expires: newYear},
+			activeAt:     newYear.AddDate(1, 0, 0),
+			wantDescribe: "disabled",
+		},
+		{name: "invalid userAccountControl", uac: "enabled", wantErr: true},
+		{name: "invalid accountExpires", expires: "never", wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := DecodeAccountStatus(tc.uac, tc.expires)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("DecodeAccountStatus() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Disabled != tc.want.Disabled || !got.Expires.Equal(tc.want.Expires) {
+				t.Errorf("DecodeAccountStatus() = %+v, want %+v", got, tc.want)
+			}
+			if active := got.Active(tc.activeAt); active != tc.wantActive {
+				t.Errorf("Active(%s) = %v, want %v", tc.activeAt, active, tc.wantActive)
+			}
+			if d := got.Describe(tc.activeAt); d != tc.wantDescribe {
+				t.Errorf("Describe(%s) = %q, want %q", tc.activeAt, d, tc.wantDescribe)
+			}
+		})
+	}
+}
+
+const accountTestLDIF = `dn: DC=test
+objectClass: domain
+
+dn: CN=active,DC=test
+objectClass: user
+userAccountControl: 512
+
+dn: CN=disabled,DC=test
+objectClass: user
+userAccountControl: 514
+
+dn: CN=expired,DC=test
+objectClass: user
+userAccountControl: 512
+accountExpires: 130000000000000000
+`
+
+func TestCheckAccountActive(t *testing.T) {
+	cases := []struct {
+		name      string
+		dn        string
+		allow     bool
+		allowAll  bool
+		wantErr   error
+		wantInErr string
+	}{
+		{name: "active", dn: "CN=active,DC=test"},
+		{name: "disabled", dn: "CN=disabled,DC=test", wantErr: ErrAccountInactive, wantInErr: "is disabled"},
+		{name: "expired", dn: "CN=expired,DC=test", wantErr: ErrAccountInactive, wantInErr: "is expired since 2012-12-14"},
+		{name: "missing", dn: "CN=missing,DC=test", wantErr: ErrNotFound},
+		{name: "disabled with --allow-disabled", dn: "CN=disabled,DC=test", allow: true},
+		{name: "expired with allow_disabled_members", dn: "CN=expired,DC=test", allowAll: true},
+		{name: "missing with --allow-disabled", dn: "CN=missing,DC=test", allow: true, wantErr: ErrNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := ldaptest.New()
+			if err := dir.ReadLDIF(strings.NewReader(accountTestLDIF)); err != nil {
+				t.Fatal(err)
+			}
+			cfg := &config.Config{AllowDisabledMembers: &tc.allowAll}
+			ctx := WithClient(context.WithValue(context.Background(), keys.ConfigKey, cfg), dir)
+			if tc.allow {
+				ctx = context.WithValue(ctx, keys.AllowDisabledKey, true)
+			}
+			err := CheckAccountActive(ctx, "someone", tc.dn)
+			if tc.wantErr == nil {
+				if err != nil {
+					t.Fatalf("CheckAccountActive() = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("CheckAccountActive() = %v, want %v", err, tc.wantErr)
+			}
+			if !strings.Contains(err.Error(), tc.wantInErr) {
+				t.Errorf("CheckAccountActive() = %q, want it to contain %q", err, tc.wantInErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// firstValues returns the first value of each of entry's attributes, keyed
+// by lowercased name.
+func firstValues(entry *ldap.Entry) map[string]string {
+	values := make(map[string]string, len(entry.Attributes))
+	for _, attr := range entry.Attributes {
+		if len(attr.Values) > 0 {
+			values[strings.ToLower(attr.Name)] = attr.Values[0]
+		}
+	}
+	return values
+}
+
+// GetGroupAttributes returns the given attributes of the group at groupDN,
+// keyed by lowercased name. Attributes the group doesn't have are left out.
+func GetGroupAttributes(ctx context.Context, groupDN string, attributes []string) (map[string]string, error) {
+	l, err := Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	searchRequest := ldap.NewSearchRequest(
+		groupDN,
+		ldap.ScopeBaseObject,
+		ldap.NeverDerefAliases,
+		0, 0, false,
+		"(objectClass=group)",
+		attributes,
+		nil,
+	)
+	sr, err := l.Search(searchRequest)
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, fmt.Errorf("group %q %w", groupDN, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to search LDAP: %w", err)
+	}
+	if len(sr.Entries) == 0 {
+		return nil, fmt.Errorf("group %q %w", groupDN, ErrNotFound)
+	}
+	return firstValues(sr.Entries[0]), nil
+}
+
+// SetGroupAttributes replaces the given attributes of the group at groupDN
+// in one modify. An empty value removes the attribute.
+func SetGroupAttributes(ctx context.Context, groupDN string, values map[string]string) error {
+	l, err := Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	modifyRequest := ldap.NewModifyRequest(groupDN, nil)
+	for attribute, value := range values {
+		// Replace with no values removes the attribute.
+		var replacement []string
+		if value != "" {
+			replacement = []string{value}
+		}
+		modifyRequest.Replace(attribute, replacement)
+	}
+	slog.Debug("Setting group attributes", "groupDN", groupDN, "values", values)
+	if err := retryTransient(ctx, groupDN, func() error { return l.Modify(modifyRequest) }); err != nil {
+		return fmt.Errorf("failed to set attributes on group %s: %w", groupDN, accessError(ctx, groupDN, err))
+	}
+	return nil
+}
+
+// GetGroupAttributesInOU returns the given attributes of every group in ouDN
+// and its children that has at least one of them, keyed by cn and then by
+// lowercased attribute name, from one paged search.
+func GetGroupAttributesInOU(ctx context.Context, ouDN string, attributes []string) (map[string]map[string]string, error) {
+	l, err := Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var filter strings.Builder
+	filter.WriteString("(&(objectClass=group)(|")
+	for _, attribute := range attributes {
+		fmt.Fprintf(&filter, "(%s=*)", attribute)
+	}
+	filter.WriteString("))")
+
+	searchRequest := ldap.NewSearchRequest(
+		ouDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0, 0, false,
+		filter.String(),
+		append([]string{"cn"}, attributes...),
+		nil,
+	)
+	sr, err := l.SearchWithPaging(searchRequest, pageSize(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search LDAP: %w", err)
+	}
+
+	groups := make(map[string]map[string]string, len(sr.Entries))
+	for _, entry := range sr.Entries {
+		values := firstValues(entry)
+		delete(values, "cn")
+		groups[entry.GetAttributeValue("cn")] = values
+	}
+	return groups, nil
+}
@@ -0,0 +1,66 @@
+package ldap
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// Change is one add, modify, rename, or delete sent to the directory, and
+// how it ended.
+type Change struct {
+	Op string // "add", "modify", "rename", or "delete"
+	DN string
+	// Members are the member values a modify added or removed.
+	Members []string
+	Err     error
+}
+
+// changeLog is every change made through one connector and those opened
+// from it, across reconnects.
+type changeLog struct {
+	mu      sync.Mutex
+	changes []Change
+}
+
+func (l *changeLog) record(change Change) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.changes = append(l.changes, change)
+}
+
+// memberValues returns the member values req adds, removes, or replaces.
+func memberValues(req *ldap.ModifyRequest) []string {
+	var members []string
+	for _, change := range req.Changes {
+		if strings.EqualFold(change.Modification.Type, "member") {
+			members = append(members, change.Modification.Vals...)
+		}
+	}
+	return members
+}
+
+// Changes returns every change sent to the directory through the
+// connection in ctx so far, and through any connection opened from it with
+// LoadLDAPConnection, in order, including those that failed.
+func Changes(ctx context.Context) []Change {
+	c, err := connectorFrom(ctx)
+	if err != nil {
+		return nil
+	}
+	c.changes.mu.Lock()
+	defer c.changes.mu.Unlock()
+	return slices.Clone(c.changes.changes)
+}
+
+// Changed reports whether any change sent through the connection in ctx
+// succeeded.
+func Changed(ctx context.Context) bool {
+	return slices.ContainsFunc(Changes(ctx), func(change Change) bool { return change.Err == nil })
+}
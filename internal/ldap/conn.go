@@ -0,0 +1,136 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+)
+
+// connector dials and binds on first use and hands out the same connection
+// afterwards, so commands that never touch the directory never connect. A
+// failed connection is remembered rather than retried, so a loop over many
+// users doesn't bind with bad credentials once per user. The connection
+// lasts as long as ctx, the context the connector was made with.
+type connector struct {
+	mu   sync.Mutex
+	ctx  context.Context
+	dial func(context.Context) (Client, error)
+	conn *TimedConn
+	err  error
+	// changes is shared with the connectors opened from this one, such as
+	// bulk's workers, so Changes sees what they did too
+	changes *changeLog
+}
+
+// WithConnector returns ctx with a connector that connects the first time
+// Conn is called. Cancelling ctx, or its deadline passing, closes the
+// connection and fails any request still waiting on it. If ctx already has
+// a connector, the new one connects the same way and records its changes
+// in the same log.
+func WithConnector(ctx context.Context) context.Context {
+	c := &connector{ctx: ctx, dial: dial, changes: &changeLog{}}
+	if parent, _ := ctx.Value(keys.LDAPConnKey).(*connector); parent != nil {
+		c.dial, c.changes = parent.dial, parent.changes
+	}
+	return context.WithValue(ctx, keys.LDAPConnKey, c)
+}
+
+// WithClient returns ctx with a connector already connected through
+// client, such as the in-memory directory tests use. Connectors opened from
+// it share client.
+func WithClient(ctx context.Context, client Client) context.Context {
+	c := &connector{
+		ctx:     ctx,
+		dial:    func(context.Context) (Client, error) { return client, nil },
+		changes: &changeLog{},
+	}
+	c.conn = &TimedConn{Client: client, ctx: ctx, slow: slowOpThreshold(ctx), changes: c.changes}
+	return context.WithValue(ctx, keys.LDAPConnKey, c)
+}
+
+func connectorFrom(ctx context.Context) (*connector, error) {
+	c, _ := ctx.Value(keys.LDAPConnKey).(*connector)
+	if c == nil {
+		return nil, fmt.Errorf("LDAP connection not found in context")
+	}
+	return c, nil
+}
+
+// Conn returns the LDAP connection in ctx, connecting first if nothing has
+// used it yet.
+func Conn(ctx context.Context) (*TimedConn, error) {
+	c, err := connectorFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil && c.err == nil {
+		var l Client
+		l, c.err = c.dial(c.ctx)
+		if c.err == nil {
+			c.conn = &TimedConn{Client: l, ctx: c.ctx, slow: slowOpThreshold(ctx), changes: c.changes}
+		}
+	}
+	if c.err != nil {
+		return nil, fmt.Errorf("failed to connect to LDAP: %w", c.err)
+	}
+	return c.conn, nil
+}
+
+// slowOpThreshold returns ldap_slow_op_ms as a duration.
+func slowOpThreshold(ctx context.Context) time.Duration {
+	if cfg, ok := ctx.Value(keys.ConfigKey).(*config.Config); ok && cfg != nil {
+		return cfg.SlowOpThreshold()
+	}
+	return config.DefaultLDAPSlowOpMs * time.Millisecond
+}
+
+// Connected reports whether the connection in ctx is open.
+func Connected(ctx context.Context) bool {
+	c, err := connectorFrom(ctx)
+	if err != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn != nil && !c.conn.IsClosing()
+}
+
+// Reconnect closes the connection in ctx, if any, and connects again,
+// forgetting an earlier failure. The agent uses it after a domain
+// controller drops its connection.
+func Reconnect(ctx context.Context) error {
+	c, err := connectorFrom(ctx)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	c.conn, c.err = nil, nil
+	c.mu.Unlock()
+	_, err = Conn(ctx)
+	return err
+}
+
+// Close closes the connection in ctx if one was made.
+func Close(ctx context.Context) error {
+	c, err := connectorFrom(ctx)
+	if err != nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err = c.conn.Close()
+	c.conn = nil
+	return err
+}
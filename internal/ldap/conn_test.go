@@ -0,0 +1,222 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+	"github.com/uoracs/directory-manager/internal/ldap/ldaptest"
+)
+
+func interruptTestContext(t *testing.T, ctx context.Context) (context.Context, *ldaptest.Directory) {
+	t.Helper()
+	dir := ldaptest.New()
+	if err := dir.ReadLDIF(strings.NewReader("dn: DC=test\nobjectClass: domain\n\ndn: CN=g,DC=test\nobjectClass: group\ncn: g\n")); err != nil {
+		t.Fatal(err)
+	}
+	ctx = context.WithValue(ctx, keys.ConfigKey, &config.Config{})
+	return WithClient(ctx, dir), dir
+}
+
+func TestConnInterrupted(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	cases := []struct {
+		name string
+		ctx  context.Context
+		want error
+		msg  string
+	}{
+		{"cancelled", cancelled, context.Canceled, "command cancelled"},
+		{"timed out", expired, context.DeadlineExceeded, "command timed out"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, dir := interruptTestContext(t, tc.ctx)
+			l, err := Conn(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = l.Search(ldap.NewSearchRequest("DC=test", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+			if !errors.Is(err, tc.want) || !strings.Contains(err.Error(), tc.msg) {
+				t.Errorf("Search error = %v, want %q wrapping %v", err, tc.msg, tc.want)
+			}
+			modify := ldap.NewModifyRequest("CN=g,DC=test", nil)
+			modify.Add("member", []string{"CN=u,DC=test"})
+			if err := l.Modify(modify); !errors.Is(err, tc.want) {
+				t.Errorf("Modify error = %v, want %v", err, tc.want)
+			}
+			if got := dir.Values("CN=g,DC=test", "member"); len(got) != 0 {
+				t.Errorf("modify was sent after the command was interrupted: member = %v", got)
+			}
+			if changes := Changes(ctx); len(changes) != 0 {
+				t.Errorf("unsent change recorded: %+v", changes)
+			}
+		})
+	}
+}
+
+// interruptingClient cancels the command during each request, then
+// answers with err.
+type interruptingClient struct {
+	*ldaptest.Directory
+	cancel context.CancelFunc
+	err    error
+}
+
+func (c interruptingClient) Modify(req *ldap.ModifyRequest) error {
+	c.cancel()
+	if c.err != nil {
+		return c.err
+	}
+	return c.Directory.Modify(req)
+}
+
+func TestConnInterruptedDuringRequest(t *testing.T) {
+	modify := func(client func(*ldaptest.Directory, context.CancelFunc) Client) error {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ctx, dir := interruptTestContext(t, ctx)
+		ctx = WithClient(ctx, client(dir, cancel))
+		l, err := Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := ldap.NewModifyRequest("CN=g,DC=test", nil)
+		req.Add("member", []string{"CN=u,DC=test"})
+		return l.Modify(req)
+	}
+
+	// The request failed because the connection was closed under it
+	err := modify(func(dir *ldaptest.Directory, cancel context.CancelFunc) Client {
+		return interruptingClient{Directory: dir, cancel: cancel, err: ldap.NewError(ldap.ErrorNetwork, errors.New("ldap: connection closed"))}
+	})
+	if !errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), "command cancelled") {
+		t.Errorf("error = %v, want the cancellation", err)
+	}
+
+	// The request finished before the connection was closed
+	err = modify(func(dir *ldaptest.Directory, cancel context.CancelFunc) Client {
+		return interruptingClient{Directory: dir, cancel: cancel}
+	})
+	if err != nil {
+		t.Errorf("request that succeeded reported %v", err)
+	}
+}
+
+// TestCloseOnDone checks that a request waiting on a server that never
+// answers fails once the context is done.
+func TestCloseOnDone(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	// Read and ignore requests, like a server that has hung
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			if _, err := server.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+	l := ldap.NewConn(client, false)
+	l.Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	conn := closeOnDone(ctx, l)
+	defer conn.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := conn.Search(ldap.NewSearchRequest("DC=test", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("search of a hung server succeeded")
+		}
+		if !conn.IsClosing() {
+			t.Error("connection still open after the deadline")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("search still waiting long after the deadline")
+	}
+}
+
+// TestLoadLDAPConnectionSharesChanges checks that a connection opened from
+// another, as bulk's workers are, records its changes where Changes on the
+// first one sees them.
+func TestLoadLDAPConnectionSharesChanges(t *testing.T) {
+	ctx, dir := interruptTestContext(t, context.Background())
+	worker, err := LoadLDAPConnection(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := Conn(worker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modify := ldap.NewModifyRequest("CN=g,DC=test", nil)
+	modify.Add("member", []string{"CN=u,DC=test"})
+	if err := l.Modify(modify); err != nil {
+		t.Fatal(err)
+	}
+	if got := dir.Values("CN=g,DC=test", "member"); len(got) != 1 {
+		t.Errorf("worker's modify didn't reach the directory: member = %v", got)
+	}
+	if !Changed(ctx) {
+		t.Errorf("worker's change missing from Changes: %+v", Changes(ctx))
+	}
+}
+
+// TestConnDialsOnce checks that the connector doesn't dial until Conn is
+// first called, and then neither dials again after success nor retries a
+// failure.
+func TestConnDialsOnce(t *testing.T) {
+	dir := ldaptest.New()
+	cases := []struct {
+		name    string
+		dialErr error
+	}{
+		{name: "success"},
+		{name: "failure", dialErr: errors.New("invalid credentials")},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dials := 0
+			ctx := context.WithValue(context.Background(), keys.ConfigKey, &config.Config{})
+			c := &connector{ctx: ctx, changes: &changeLog{}, dial: func(context.Context) (Client, error) {
+				dials++
+				if tc.dialErr != nil {
+					return nil, tc.dialErr
+				}
+				return dir, nil
+			}}
+			ctx = context.WithValue(ctx, keys.LDAPConnKey, c)
+			if dials != 0 || Connected(ctx) {
+				t.Fatal("connected before Conn was called")
+			}
+			for range 3 {
+				_, err := Conn(ctx)
+				if !errors.Is(err, tc.dialErr) {
+					t.Errorf("Conn() = %v, want %v", err, tc.dialErr)
+				}
+			}
+			if dials != 1 {
+				t.Errorf("dialled %d times, want 1", dials)
+			}
+			if got := Connected(ctx); got != (tc.dialErr == nil) {
+				t.Errorf("Connected() = %v", got)
+			}
+		})
+	}
+}
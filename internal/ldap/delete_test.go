@@ -0,0 +1,64 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestDeleteChecksObjectClass checks that DeleteGroup and
+// DeleteOURecursively refuse to delete any other kind of object, and leave
+// it in place.
+func TestDeleteChecksObjectClass(t *testing.T) {
+	cases := []struct {
+		name    string
+		delete  func(ctx context.Context, dn string) error
+		dn      string
+		wantErr error
+		wantMsg string
+	}{
+		{name: "group delete of an OU", delete: DeleteGroup, dn: "OU=lab,DC=test", wantErr: ErrNotGroup, wantMsg: "is a organizationalUnit"},
+		{name: "group delete of a user", delete: DeleteGroup, dn: "CN=u,OU=lab,DC=test", wantErr: ErrNotGroup, wantMsg: "is a user"},
+		{name: "OU delete of a group", delete: DeleteOURecursively, dn: "CN=g,OU=lab,DC=test", wantErr: ErrNotOU, wantMsg: "is a group"},
+		{name: "group delete of nothing", delete: DeleteGroup, dn: "CN=nosuch,OU=lab,DC=test", wantErr: ErrNotFound},
+		{name: "OU delete of nothing", delete: DeleteOURecursively, dn: "OU=nosuch,DC=test", wantErr: ErrNotFound},
+		{name: "invalid DN", delete: DeleteGroup, dn: "not a DN", wantMsg: "invalid DN"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, dir := retryTestContext(t, 0, 0)
+			err := tc.delete(ctx, tc.dn)
+			if err == nil {
+				t.Fatal("delete succeeded")
+			}
+			if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Errorf("error = %v, want %v", err, tc.wantErr)
+			}
+			if !strings.Contains(err.Error(), tc.wantMsg) {
+				t.Errorf("error = %v, want it to say %q", err, tc.wantMsg)
+			}
+			for _, dn := range []string{"OU=lab,DC=test", "CN=g,OU=lab,DC=test", "CN=u,OU=lab,DC=test"} {
+				if !dir.Exists(dn) {
+					t.Errorf("%s deleted", dn)
+				}
+			}
+			if len(Changes(ctx)) != 0 {
+				t.Errorf("a delete was sent: %+v", Changes(ctx))
+			}
+		})
+	}
+}
+
+func TestDeleteMatchingClass(t *testing.T) {
+	ctx, dir := retryTestContext(t, 0, 0)
+	if err := DeleteGroup(ctx, "CN=g,OU=lab,DC=test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteOURecursively(ctx, "OU=lab,DC=test"); err != nil {
+		t.Fatal(err)
+	}
+	if dir.Exists("OU=lab,DC=test") || dir.Exists("CN=u,OU=lab,DC=test") {
+		t.Error("OU or its contents left behind")
+	}
+}
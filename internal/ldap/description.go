@@ -0,0 +1,103 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+)
+
+// maxDescriptionLength is AD's rangeUpper for the description attribute.
+const maxDescriptionLength = 1024
+
+// GroupDetails is a group's name together with the attributes shown in long listings.
+type GroupDetails struct {
+	Name        string `json:"name"`
+	GidNumber   int    `json:"gid_number"`
+	Description string `json:"description"`
+}
+
+// WithDescription sets the description of a new group. An empty description is left unset.
+func WithDescription(description string) GroupOption {
+	return func(r *ldap.AddRequest) {
+		if description != "" {
+			r.Attribute("description", []string{description})
+		}
+	}
+}
+
+// ValidateSubgroupDescription checks a subgroup description against AD's
+// length limit and, when require_subgroup_description is set, that it isn't empty.
+func ValidateSubgroupDescription(ctx context.Context, description string) error {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return fmt.Errorf("config not found in context")
+	}
+	if description == "" && cfg.SubgroupDescriptionRequired() {
+		return fmt.Errorf("a description is required by require_subgroup_description: %w", ErrPolicy)
+	}
+	if n := utf8.RuneCountInString(description); n > maxDescriptionLength {
+		return fmt.Errorf("description is %d characters, the limit is %d", n, maxDescriptionLength)
+	}
+	return nil
+}
+
+// SetGroupDescription replaces the description of a group. An empty description clears it.
+func SetGroupDescription(ctx context.Context, groupDN string, description string) error {
+	l, err := Conn(ctx)
+	if err != nil {
+		return err
+	}
+	var values []string
+	if description != "" {
+		values = []string{description}
+	}
+	modifyRequest := ldap.NewModifyRequest(groupDN, nil)
+	modifyRequest.Replace("description", values)
+	if err := retryTransient(ctx, groupDN, func() error { return l.Modify(modifyRequest) }); err != nil {
+		return fmt.Errorf("failed to set description on %s: %w", groupDN, accessError(ctx, groupDN, err))
+	}
+	slog.Debug("Set group description", "groupDN", groupDN)
+	return nil
+}
+
+// GetGroupDetailsInOU returns the groups directly under ouDN with their
+// gidNumber and description, in a single search.
+func GetGroupDetailsInOU(ctx context.Context, ouDN string) ([]GroupDetails, error) {
+	l, err := Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	searchRequest := ldap.NewSearchRequest(
+		ouDN,
+		ldap.ScopeSingleLevel,
+		ldap.NeverDerefAliases,
+		0, 0, false,
+		"(objectClass=group)",
+		[]string{"cn", "gidNumber", "description"},
+		nil,
+	)
+
+	sr, err := l.Search(searchRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search LDAP: %w", err)
+	}
+
+	groups := make([]GroupDetails, 0, len(sr.Entries))
+	for _, entry := range sr.Entries {
+		// A group without a gidNumber is listed with 0 rather than hidden
+		gidNumber, _ := strconv.Atoi(entry.GetAttributeValue("gidNumber"))
+		groups = append(groups, GroupDetails{
+			Name:        entry.GetAttributeValue("cn"),
+			GidNumber:   gidNumber,
+			Description: entry.GetAttributeValue("description"),
+		})
+	}
+	return groups, nil
+}
@@ -0,0 +1,44 @@
+package ldap
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// ParentDN returns the DN of the entry containing dn. The DN is parsed, so
+// an escaped comma inside an RDN value isn't taken for a separator, and
+// the parent is rebuilt from its RDNs with their values escaped again.
+func ParentDN(dn string) (string, error) {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse DN %q: %w", dn, err)
+	}
+	if len(parsed.RDNs) < 2 {
+		return "", fmt.Errorf("DN %q has no parent", dn)
+	}
+	rdns := make([]string, 0, len(parsed.RDNs)-1)
+	for _, rdn := range parsed.RDNs[1:] {
+		attrs := make([]string, 0, len(rdn.Attributes))
+		for _, attr := range rdn.Attributes {
+			attrs = append(attrs, attr.Type+"="+ldap.EscapeDN(attr.Value))
+		}
+		rdns = append(rdns, strings.Join(attrs, "+"))
+	}
+	return strings.Join(rdns, ","), nil
+}
+
+// SameDN reports whether a and b name the same entry, comparing them RDN by
+// RDN and ignoring case and spacing around separators.
+func SameDN(a string, b string) (bool, error) {
+	parsedA, err := ldap.ParseDN(a)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse DN %q: %w", a, err)
+	}
+	parsedB, err := ldap.ParseDN(b)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse DN %q: %w", b, err)
+	}
+	return parsedA.EqualFold(parsedB), nil
+}
@@ -0,0 +1,93 @@
+package ldap
+
+import "testing"
+
+func TestParentDN(t *testing.T) {
+	cases := []struct {
+		dn      string
+		want    string
+		wantErr bool
+	}{
+		{dn: "CN=app,OU=SOFTWARE,DC=test", want: "OU=SOFTWARE,DC=test"},
+		{dn: "CN=app, OU=SOFTWARE, DC=test", want: "OU=SOFTWARE,DC=test"},
+		{dn: `CN=Smith\, Jo,OU=People,DC=test`, want: "OU=People,DC=test"},
+		{dn: `CN=app,OU=Software\, Licensed,DC=test`, want: `OU=Software\, Licensed,DC=test`},
+		{dn: `CN=app,OU=a\+b,DC=test`, want: `OU=a\+b,DC=test`},
+		{dn: "CN=app,OU=x+L=y,DC=test", want: "OU=x+L=y,DC=test"},
+		{dn: "DC=test", wantErr: true},
+		{dn: "not a DN", wantErr: true},
+	}
+	for _, tc := range cases {
+		got, err := ParentDN(tc.dn)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("ParentDN(%q) = %q, want an error", tc.dn, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParentDN(%q): %v", tc.dn, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("ParentDN(%q) = %q, want %q", tc.dn, got, tc.want)
+		}
+	}
+}
+
+func TestSameDN(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want bool
+	}{
+		{a: "OU=app,OU=SOFTWARE,DC=test", b: "ou=APP, ou=software, dc=test", want: true},
+		{a: `OU=Software\, Licensed,DC=test`, b: `OU=software\2c licensed,DC=test`, want: true},
+		{a: `OU=Software\, Licensed,DC=test`, b: "OU=Software,OU=Licensed,DC=test", want: false},
+		{a: "OU=app,DC=test", b: "OU=app,DC=other", want: false},
+	}
+	for _, tc := range cases {
+		got, err := SameDN(tc.a, tc.b)
+		if err != nil {
+			t.Errorf("SameDN(%q, %q): %v", tc.a, tc.b, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("SameDN(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestConvertDNToObjectName(t *testing.T) {
+	cases := []struct {
+		dn      string
+		want    string
+		wantErr bool
+	}{
+		{dn: "CN=is.racs.pirg.alpha,OU=alpha,OU=PIRGS,DC=test", want: "is.racs.pirg.alpha"},
+		{dn: "cn=alice, ou=People, dc=test", want: "alice"},
+		{dn: `CN=Lab\, Inc,OU=Groups,DC=test`, want: "Lab, Inc"},
+		{dn: `CN=a\2Cb,DC=test`, want: "a,b"},
+		{dn: `CN=\#hash\+plus,DC=test`, want: "#hash+plus"},
+		{dn: "OU=PIRGS,DC=test", want: "PIRGS"},
+		{dn: "DC=test", want: "test"},
+		{dn: "CN=,DC=test", wantErr: true},
+		{dn: "", wantErr: true},
+		{dn: "not a DN", wantErr: true},
+	}
+	for _, tc := range cases {
+		got, err := ConvertDNToObjectName(tc.dn)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("ConvertDNToObjectName(%q) = %q, want an error", tc.dn, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ConvertDNToObjectName(%q): %v", tc.dn, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("ConvertDNToObjectName(%q) = %q, want %q", tc.dn, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+)
+
+// DNUnder reports whether dn is ouDN or lies beneath it. Both are parsed and
+// compared RDN by RDN, ignoring case and spacing around separators.
+func DNUnder(dn string, ouDN string) (bool, error) {
+	child, err := ldap.ParseDN(dn)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse DN %q: %w", dn, err)
+	}
+	parent, err := ldap.ParseDN(ouDN)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse DN %q: %w", ouDN, err)
+	}
+	return parent.EqualFold(child) || parent.AncestorOfFold(child), nil
+}
+
+// CheckEligibility checks a user against an eligibility policy. knob names
+// the config setting the policy came from, for the error message. If the
+// context carries keys.OverridePolicyKey, a failing check is logged as a
+// warning and allowed.
+func CheckEligibility(ctx context.Context, userDN string, policy config.Eligibility, knob string) error {
+	if policy.IsEmpty() {
+		return nil
+	}
+	for _, ouDN := range policy.AllowedUserOUs {
+		under, err := DNUnder(userDN, ouDN)
+		if err != nil {
+			return fmt.Errorf("failed to check %s: %w", knob, err)
+		}
+		if under {
+			slog.Debug("User eligible by OU", "userDN", userDN, "ouDN", ouDN)
+			return nil
+		}
+	}
+	if policy.RequiredGroupDN != "" {
+		inGroup, err := UserInGroup(ctx, policy.RequiredGroupDN, userDN)
+		if err != nil {
+			return fmt.Errorf("failed to check %s: %w", knob, err)
+		}
+		if inGroup {
+			slog.Debug("User eligible by group", "userDN", userDN, "groupDN", policy.RequiredGroupDN)
+			return nil
+		}
+	}
+	if override, _ := ctx.Value(keys.OverridePolicyKey).(bool); override {
+		slog.Warn("Overriding eligibility policy", "policy", knob, "userDN", userDN)
+		return nil
+	}
+	return fmt.Errorf("user %s is not permitted by %s: %w", userDN, knob, ErrPolicy)
+}
@@ -0,0 +1,44 @@
+package ldap
+
+import "testing"
+
+func TestDNUnder(t *testing.T) {
+	const people = "OU=People,DC=ad,DC=uoregon,DC=edu"
+	cases := []struct {
+		name string
+		dn   string
+		ou   string
+		want bool
+	}{
+		{"directly below", "CN=alice,OU=People,DC=ad,DC=uoregon,DC=edu", people, true},
+		{"deeper below", "CN=alice,OU=Staff,OU=People,DC=ad,DC=uoregon,DC=edu", people, true},
+		{"the OU itself", people, people, true},
+		{"case and spacing", "cn=alice, ou=PEOPLE, dc=AD, dc=uoregon, dc=edu", people, true},
+		{"sibling OU", "CN=alice,OU=Guests,DC=ad,DC=uoregon,DC=edu", people, false},
+		{"parent of the OU", "DC=ad,DC=uoregon,DC=edu", people, false},
+		{"OU name as a suffix", "CN=alice,OU=OldPeople,DC=ad,DC=uoregon,DC=edu", people, false},
+		{"OU name in an RDN value", "CN=OU=People,OU=Guests,DC=ad,DC=uoregon,DC=edu", people, false},
+		{"escaped comma", `CN=Smith\, Alice,OU=People,DC=ad,DC=uoregon,DC=edu`, people, true},
+		{"escaped comma in the OU", `CN=alice,OU=People\, Temp,DC=ad,DC=uoregon,DC=edu`, people, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := DNUnder(tc.dn, tc.ou)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("DNUnder(%q, %q) = %v, want %v", tc.dn, tc.ou, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDNUnderInvalid(t *testing.T) {
+	if _, err := DNUnder("alice", "OU=People,DC=edu"); err == nil {
+		t.Error("no error for an unparseable DN")
+	}
+	if _, err := DNUnder("CN=alice,OU=People,DC=edu", "People"); err == nil {
+		t.Error("no error for an unparseable OU")
+	}
+}
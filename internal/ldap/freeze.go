@@ -0,0 +1,162 @@
+package ldap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/uoracs/directory-manager/internal/keys"
+)
+
+// freezePrefix marks the line of info that holds a group's freeze, followed
+// by the Freeze as JSON. It differs from changeNotePrefix, so trimming
+// change notes never drops it.
+const freezePrefix = "[directory-manager:frozen] "
+
+// Freeze is why and by whom a group was frozen against changes.
+type Freeze struct {
+	Reason string    `json:"reason"`
+	By     string    `json:"by,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// parseFreeze returns the freeze recorded in info, if there is one. A
+// marker that can't be decoded still freezes the group, with its raw text
+// as the reason, so a damaged marker never silently unfreezes it.
+func parseFreeze(info string) (Freeze, bool) {
+	for _, line := range strings.Split(strings.ReplaceAll(info, "\r\n", "\n"), "\n") {
+		data, ok := strings.CutPrefix(line, freezePrefix)
+		if !ok {
+			continue
+		}
+		var f Freeze
+		if err := json.Unmarshal([]byte(data), &f); err != nil || f.Reason == "" {
+			f = Freeze{Reason: data}
+		}
+		return f, true
+	}
+	return Freeze{}, false
+}
+
+// setFreeze returns info with its freeze line replaced by f, or removed if
+// f is nil. Other lines are left alone.
+func setFreeze(info string, f *Freeze) (string, error) {
+	var lines []string
+	if info != "" {
+		for _, line := range strings.Split(strings.ReplaceAll(info, "\r\n", "\n"), "\n") {
+			if !strings.HasPrefix(line, freezePrefix) {
+				lines = append(lines, line)
+			}
+		}
+	}
+	if f != nil {
+		data, err := json.Marshal(f)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode freeze: %w", err)
+		}
+		lines = append(lines, freezePrefix+string(data))
+	}
+	updated := strings.Join(lines, "\r\n")
+	if n := utf8.RuneCountInString(updated); n > maxInfoLength {
+		return "", fmt.Errorf("info would be %d characters, the limit is %d", n, maxInfoLength)
+	}
+	return updated, nil
+}
+
+// GetFreeze returns the freeze on the group at groupDN, if it is frozen.
+func GetFreeze(ctx context.Context, groupDN string) (Freeze, bool, error) {
+	l, err := Conn(ctx)
+	if err != nil {
+		return Freeze{}, false, err
+	}
+	info, err := getInfo(l, groupDN)
+	if err != nil {
+		return Freeze{}, false, fmt.Errorf("failed to read info of %s: %w", groupDN, err)
+	}
+	f, frozen := parseFreeze(info)
+	return f, frozen, nil
+}
+
+// FreezeGroup records f on the group at groupDN, replacing any freeze it
+// already has.
+func FreezeGroup(ctx context.Context, groupDN string, f Freeze) error {
+	err := modifyInfo(ctx, groupDN, func(info string) (string, error) {
+		return setFreeze(info, &f)
+	})
+	if err != nil {
+		return err
+	}
+	slog.Info("Froze group", "groupDN", groupDN, "reason", f.Reason, "by", f.By)
+	return nil
+}
+
+// UnfreezeGroup removes the freeze from the group at groupDN, if it has one.
+func UnfreezeGroup(ctx context.Context, groupDN string) error {
+	err := modifyInfo(ctx, groupDN, func(info string) (string, error) {
+		return setFreeze(info, nil)
+	})
+	if err != nil {
+		return err
+	}
+	slog.Info("Unfroze group", "groupDN", groupDN)
+	return nil
+}
+
+// CheckNotFrozen returns ErrFrozen, with the reason, if the group at groupDN
+// is frozen. With OverrideFreezeKey set in ctx the change is allowed, and
+// logged as a warning so the override can be audited. A group that doesn't
+// exist isn't frozen; the caller reports it missing as usual.
+func CheckNotFrozen(ctx context.Context, groupDN string) error {
+	f, frozen, err := GetFreeze(ctx, groupDN)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to check freeze: %w", err)
+	}
+	if !frozen {
+		return nil
+	}
+	if override, ok := ctx.Value(keys.OverrideFreezeKey).(bool); ok && override {
+		slog.Warn("Changing frozen group", "groupDN", groupDN, "freezeReason", f.Reason, "frozenBy", f.By)
+		return nil
+	}
+	return fmt.Errorf("%s was frozen by %s on %s: %s: %w", groupDN, f.By, f.At.Format(time.DateOnly), f.Reason, ErrFrozen)
+}
+
+// GetFrozenGroupsInOU returns the freeze of every frozen group in ouDN and
+// its children, keyed by cn, from one paged search.
+func GetFrozenGroupsInOU(ctx context.Context, ouDN string) (map[string]Freeze, error) {
+	l, err := Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	searchRequest := ldap.NewSearchRequest(
+		ouDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0, 0, false,
+		fmt.Sprintf("(&(objectClass=group)(info=*%s*))", ldap.EscapeFilter(strings.TrimSpace(freezePrefix))),
+		[]string{"cn", "info"},
+		nil,
+	)
+	sr, err := l.SearchWithPaging(searchRequest, pageSize(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search LDAP: %w", err)
+	}
+
+	frozen := make(map[string]Freeze)
+	for _, entry := range sr.Entries {
+		if f, ok := parseFreeze(entry.GetAttributeValue("info")); ok {
+			frozen[entry.GetAttributeValue("cn")] = f
+		}
+	}
+	return frozen, nil
+}
@@ -2,14 +2,17 @@ package ldap
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/go-ldap/ldap/v3"
 	"github.com/uoracs/directory-manager/internal/config"
 	"github.com/uoracs/directory-manager/internal/keys"
+	"github.com/uoracs/directory-manager/internal/managedgroup"
 )
 
 func GetDummyGidNumber(ctx context.Context) (int, error) {
@@ -25,20 +28,20 @@ func GetGidOfExistingGroup(ctx context.Context, groupName string) (string, error
 		return "", fmt.Errorf("config not found in context")
 	}
 
-	l := ctx.Value(keys.LDAPConnKey).(*ldap.Conn)
-	if l == nil {
-		return "", fmt.Errorf("LDAP connection not found in context")
+	l, err := Conn(ctx)
+	if err != nil {
+		return "", err
 	}
 
 	// fullCN := "is.racs.cephfs." + groupName // e.g., "is.racs.ceph.flopezlab"
 	var baseDN string
-	if strings.HasPrefix(groupName, "is.racs.cephfs.") {
+	if strings.HasPrefix(groupName, managedgroup.Cephfs.Prefix) {
 	    baseDN = cfg.LDAPCephfsDN
-	} else if strings.HasPrefix(groupName, "is.racs.cephs3.") {
+	} else if strings.HasPrefix(groupName, managedgroup.Cephs3.Prefix) {
 	    baseDN = cfg.LDAPCephs3DN
-	} else if strings.HasPrefix(groupName, "is.racs.pirg.") {
+	} else if strings.HasPrefix(groupName, managedgroup.Pirg.Prefix) {
 	    baseDN = cfg.LDAPPirgDN
-	} else if strings.HasPrefix(groupName, "is.racs.software.") {
+	} else if strings.HasPrefix(groupName, managedgroup.Software.Prefix) {
 	    baseDN = cfg.LDAPSoftwareDN
 	} else {
 	    return "", fmt.Errorf("unknown group type for %s", groupName)
@@ -59,7 +62,7 @@ func GetGidOfExistingGroup(ctx context.Context, groupName string) (string, error
 	}
 
 	if len(sr.Entries) == 0 {
-		return "", fmt.Errorf("group %s not found", groupName)
+		return "", fmt.Errorf("group %s %w", groupName, ErrNotFound)
 	}
 
 	gidStr := sr.Entries[0].GetAttributeValue("gidNumber")
@@ -67,80 +70,364 @@ func GetGidOfExistingGroup(ctx context.Context, groupName string) (string, error
 	return gidStr, nil
 }
 
+// GetNextGidNumber reserves and returns the next GID in the global range,
+// ldap_min_gid to ldap_max_gid.
 func GetNextGidNumber(ctx context.Context) (int, error) {
+	return AllocateGids(ctx, "", 1)
+}
+
+// highWaterRetries is how many times an allocation starts over because
+// another one moved the high-water mark first.
+const highWaterRetries = 3
+
+// errHighWaterMoved is returned by SetHighWaterGid when the mark is no
+// longer the value it was read as.
+var errHighWaterMoved = errors.New("GID high-water mark changed concurrently")
+
+// AllocateGids reserves count consecutive GIDs in the namespace's range, or
+// the global range for "", and returns the first. That is one more than
+// the highest gidNumber in use in the range or the range's high-water mark,
+// or the bottom of the range. GIDs outside the range are ignored, so each
+// namespace allocates from its own range. The mark is raised to the last
+// GID reserved; if another allocation moved it in the meantime, this one
+// starts over rather than hand out the same GIDs.
+func AllocateGids(ctx context.Context, namespace string, count int) (int, error) {
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
 	if cfg == nil {
 		return 0, fmt.Errorf("config not found in context")
 	}
-	l := ctx.Value(keys.LDAPConnKey).(*ldap.Conn)
-	if l == nil {
-		return 0, fmt.Errorf("LDAP connection not found in context")
+	minGid, maxGid := cfg.GidRange(namespace)
+	for attempt := 0; ; attempt++ {
+		existing, err := GetExistingGroupsWithGidNumbersInRange(ctx, minGid, maxGid)
+		if err != nil {
+			return 0, err
+		}
+		nextGid := minGid
+		gids := make([]int, 0, len(existing)+count)
+		for _, gid := range existing {
+			gids = append(gids, gid)
+			if gid >= nextGid {
+				nextGid = gid + 1
+			}
+		}
+		highWater, err := GetHighWaterGid(ctx, namespace)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get GID high-water mark: %w", err)
+		}
+		// A mark outside the range, such as one kept before the range got
+		// a mark of its own, says nothing about it
+		if highWater >= minGid && highWater <= maxGid && highWater >= nextGid {
+			nextGid = highWater + 1
+		}
+		nextGid, err = skipGidsInUse(ctx, cfg, nextGid, count, maxGid)
+		if err != nil {
+			return 0, err
+		}
+		last := nextGid + count - 1
+		if last > maxGid {
+			return 0, fmt.Errorf("no %d available GID numbers between %d and %d", count, minGid, maxGid)
+		}
+		err = SetHighWaterGid(ctx, namespace, highWater, last)
+		if errors.Is(err, errHighWaterMoved) && attempt < highWaterRetries {
+			slog.Debug("GID high-water mark moved, allocating again", "namespace", namespace, "attempt", attempt+1)
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to update GID high-water mark: %w", err)
+		}
+		for gid := nextGid; gid <= last; gid++ {
+			gids = append(gids, gid)
+		}
+		slog.Debug("Allocated GID numbers", "namespace", namespace, "min", minGid, "max", maxGid, "first", nextGid, "count", count)
+		warnGidUsage(cfg, ComputeGidUsage(minGid, maxGid, gids))
+		return nextGid, nil
 	}
-	highestGid := 0
-	searchRequest := ldap.NewSearchRequest(
-		cfg.LDAPGroupsBaseDN,
-		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
-		"(&(objectClass=group)(gidNumber=*))",
-		[]string{"gidNumber"},
-		nil,
-	)
-	sr, err := l.Search(searchRequest)
-	if err != nil {
-		return 0, fmt.Errorf("failed to search LDAP: %w", err)
+}
 
+// checkGidUnique returns an error wrapping ErrAlreadyExists if a group
+// under ldap_groups_base_dn already has gidNumber, unless
+// enforce_gid_uniqueness is off.
+func checkGidUnique(ctx context.Context, cfg *config.Config, gidNumber int) error {
+	if cfg.EnforceGidUniqueness != nil && !*cfg.EnforceGidUniqueness {
+		return nil
+	}
+	holder, inUse, err := GidNumberInUse(ctx, gidNumber)
+	if err != nil {
+		return fmt.Errorf("failed to check gidNumber uniqueness: %w", err)
+	}
+	if inUse {
+		return fmt.Errorf("gidNumber %d is already used by group %s: %w", gidNumber, holder, ErrAlreadyExists)
+	}
+	return nil
+}
 
+// skipGidsInUse returns the first GID at or after nextGid that starts count
+// consecutive GIDs no group under ldap_groups_base_dn holds. The allocator
+// only scans the GID scan DNs, but CreateGroup refuses a GID held anywhere
+// under the groups base DN, so a group outside the scanned DNs would
+// otherwise make every allocation fail on its GID. Nothing is checked if
+// enforce_gid_uniqueness is off.
+func skipGidsInUse(ctx context.Context, cfg *config.Config, nextGid int, count int, maxGid int) (int, error) {
+	if cfg.EnforceGidUniqueness != nil && !*cfg.EnforceGidUniqueness {
+		return nextGid, nil
 	}
-	for _, entry := range sr.Entries {
-		gid, err := strconv.Atoi(entry.GetAttributeValue("gidNumber"))
+	for gid := nextGid; gid < nextGid+count && gid <= maxGid; gid++ {
+		holder, inUse, err := GidNumberInUse(ctx, gid)
 		if err != nil {
-			continue
+			return 0, fmt.Errorf("failed to check gidNumber uniqueness: %w", err)
 		}
-		if gid > highestGid {
-			highestGid = gid
+		if inUse {
+			slog.Warn("GID is held by a group outside the GID scan DNs, skipping it", "gidNumber", gid, "group", holder)
+			nextGid = gid + 1
 		}
 	}
-	if highestGid >= cfg.LDAPMaxGid {
-		return 0, fmt.Errorf("no available GID numbers")
+	return nextGid, nil
+}
+
+// GidUsage is how much of a GID range is taken.
+type GidUsage struct {
+	Min       int `json:"min"`
+	Max       int `json:"max"`
+	Size      int `json:"size"`
+	Allocated int `json:"allocated"`
+	Free      int `json:"free"`
+	// LargestFreeBlock is the longest run of consecutive free GIDs. New
+	// GIDs are allocated above the highest in use, so free GIDs in gaps
+	// below it are only reachable by hand.
+	LargestFreeBlock int     `json:"largest_free_block"`
+	Percent          float64 `json:"percent"`
+}
+
+// ComputeGidUsage summarizes how much of [minGid, maxGid] the given GIDs
+// take. Duplicates and GIDs outside the range are ignored.
+func ComputeGidUsage(minGid int, maxGid int, gids []int) GidUsage {
+	u := GidUsage{Min: minGid, Max: maxGid}
+	if maxGid < minGid {
+		return u
 	}
-	nextGid := highestGid + 1
-	if nextGid < cfg.LDAPMinGid {
-		return 0, fmt.Errorf("next GID number is less than minimum GID number")
+	u.Size = maxGid - minGid + 1
+	inRange := make([]int, 0, len(gids))
+	for _, gid := range gids {
+		if gid >= minGid && gid <= maxGid {
+			inRange = append(inRange, gid)
+		}
 	}
-	return nextGid, nil
+	slices.Sort(inRange)
+	inRange = slices.Compact(inRange)
+
+	u.Allocated = len(inRange)
+	u.Free = u.Size - u.Allocated
+	u.Percent = float64(u.Allocated) * 100 / float64(u.Size)
+	// Walk the gaps between consecutive allocated GIDs, with sentinels
+	// just outside both ends of the range
+	prev := minGid - 1
+	for _, gid := range append(inRange, maxGid+1) {
+		u.LargestFreeBlock = max(u.LargestFreeBlock, gid-prev-1)
+		prev = gid
+	}
+	return u
 }
 
-func GetExistingGroupsWithGidNumbers(ctx context.Context) (map[string]int, error) {
+// GetGidUsage returns how much of [minGid, maxGid] is taken by groups.
+func GetGidUsage(ctx context.Context, minGid int, maxGid int) (GidUsage, error) {
+	existing, err := GetExistingGroupsWithGidNumbersInRange(ctx, minGid, maxGid)
+	if err != nil {
+		return GidUsage{}, err
+	}
+	gids := make([]int, 0, len(existing))
+	for _, gid := range existing {
+		gids = append(gids, gid)
+	}
+	return ComputeGidUsage(minGid, maxGid, gids), nil
+}
+
+// warnGidUsage logs a warning once usage crosses gid_usage_warn_percent, so
+// a range running out is noticed before allocation starts failing.
+func warnGidUsage(cfg *config.Config, u GidUsage) {
+	if u.Size == 0 || u.Percent < float64(cfg.GidUsageWarnPercent) {
+		return
+	}
+	slog.Warn("GID range nearly exhausted",
+		"min", u.Min, "max", u.Max,
+		"allocated", u.Allocated, "free", u.Free,
+		"largest_free_block", u.LargestFreeBlock,
+		"percent", fmt.Sprintf("%.1f", u.Percent),
+		"threshold", cfg.GidUsageWarnPercent,
+	)
+}
+
+// GetHighWaterGid returns the highest GID ever allocated in the
+// namespace's range, as recorded on the gid_highwater_dn object, so
+// deleting the group with the highest GID can't cause that GID to be handed
+// out again. It returns 0 if gid_highwater_dn is unset or the attribute is
+// empty.
+func GetHighWaterGid(ctx context.Context, namespace string) (int, error) {
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
 	if cfg == nil {
-		return nil, fmt.Errorf("config not found in context")
+		return 0, fmt.Errorf("config not found in context")
 	}
-	l := ctx.Value(keys.LDAPConnKey).(*ldap.Conn)
-	if l == nil {
-		return nil, fmt.Errorf("LDAP connection not found in context")
+	if cfg.GidHighWaterDN == "" {
+		return 0, nil
 	}
+	l, err := Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	attribute := cfg.HighWaterAttribute(namespace)
 	searchRequest := ldap.NewSearchRequest(
-		cfg.LDAPGroupsBaseDN,
-		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
-		"(&(objectClass=group)(gidNumber=*))",
-		[]string{"cn", "gidNumber"},
+		cfg.GidHighWaterDN,
+		ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)",
+		[]string{attribute},
 		nil,
 	)
-	slog.Debug("Searching LDAP for existing groups with gid numbers", "baseDN", cfg.LDAPGroupsBaseDN)
-
 	sr, err := l.Search(searchRequest)
 	if err != nil {
-		return nil, fmt.Errorf("failed to search LDAP: %w", err)
+		return 0, fmt.Errorf("failed to read %s: %w", cfg.GidHighWaterDN, err)
 	}
+	if len(sr.Entries) == 0 {
+		return 0, fmt.Errorf("gid_highwater_dn %s %w", cfg.GidHighWaterDN, ErrNotFound)
+	}
+	value := sr.Entries[0].GetEqualFoldAttributeValue(attribute)
+	if value == "" {
+		return 0, nil
+	}
+	gid, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q on %s: %w", attribute, value, cfg.GidHighWaterDN, err)
+	}
+	return gid, nil
+}
+
+// SetHighWaterGid changes the high-water mark of the namespace's range from
+// old, as last read, to gid. The old value is removed and the new one added
+// in the same modify, so if another allocation changed the mark first the
+// modify fails and errHighWaterMoved is returned. It does nothing if
+// gid_highwater_dn is unset.
+func SetHighWaterGid(ctx context.Context, namespace string, old int, gid int) error {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return fmt.Errorf("config not found in context")
+	}
+	if cfg.GidHighWaterDN == "" || old == gid {
+		return nil
+	}
+	l, err := Conn(ctx)
+	if err != nil {
+		return err
+	}
+	attribute := cfg.HighWaterAttribute(namespace)
+	modifyRequest := ldap.NewModifyRequest(cfg.GidHighWaterDN, nil)
+	if old != 0 {
+		modifyRequest.Delete(attribute, []string{strconv.Itoa(old)})
+	}
+	modifyRequest.Add(attribute, []string{strconv.Itoa(gid)})
+	err = retryTransient(ctx, cfg.GidHighWaterDN, func() error { return l.Modify(modifyRequest) })
+	if ldap.IsErrorAnyOf(err, ldap.LDAPResultNoSuchAttribute, ldap.LDAPResultAttributeOrValueExists, ldap.LDAPResultConstraintViolation) {
+		return fmt.Errorf("%s on %s: %w", attribute, cfg.GidHighWaterDN, errHighWaterMoved)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set %s on %s: %w", attribute, cfg.GidHighWaterDN, accessError(ctx, cfg.GidHighWaterDN, err))
+	}
+	slog.Debug("Set GID high-water mark", "namespace", namespace, "attribute", attribute, "gidNumber", gid)
+	return nil
+}
+
+// GetExistingGroupsWithGidNumbers returns the gidNumber of every group with
+// one in [ldap_min_gid, ldap_max_gid], keyed by cn. GIDs outside the range
+// say nothing about what can be allocated, so they are never fetched.
+func GetExistingGroupsWithGidNumbers(ctx context.Context) (map[string]int, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	return GetExistingGroupsWithGidNumbersInRange(ctx, cfg.LDAPMinGid, cfg.LDAPMaxGid)
+}
+
+// GetExistingGroupsWithGidNumbersInRange is GetExistingGroupsWithGidNumbers
+// restricted to groups with a gidNumber in [minGid, maxGid].
+func GetExistingGroupsWithGidNumbersInRange(ctx context.Context, minGid int, maxGid int) (map[string]int, error) {
+	existing, err := getGroupsWithGidNumbers(ctx, fmt.Sprintf("(&(objectClass=group)(gidNumber>=%d)(gidNumber<=%d))", minGid, maxGid))
+	if err != nil {
+		return nil, err
+	}
+	// In case the server compares gidNumber as a string
+	for cn, gid := range existing {
+		if gid < minGid || gid > maxGid {
+			delete(existing, cn)
+		}
+	}
+	return existing, nil
+}
 
+func getGroupsWithGidNumbers(ctx context.Context, filter string) (map[string]int, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	l, err := Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Only the OUs GIDs are allocated for are searched, one at a time, so
+	// unrelated groups elsewhere under the groups base DN are never read.
 	existing := make(map[string]int)
-	for _, entry := range sr.Entries {
-		gid, err := strconv.Atoi(entry.GetAttributeValue("gidNumber"))
-		if err != nil {
+	for _, baseDN := range cfg.GidScanDNs() {
+		searchRequest := ldap.NewSearchRequest(
+			baseDN,
+			ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+			filter,
+			[]string{"cn", "gidNumber"},
+			nil,
+		)
+		slog.Debug("Searching LDAP for existing groups with gid numbers", "baseDN", baseDN, "filter", filter)
+
+		sr, err := l.SearchWithPaging(searchRequest, pageSize(ctx))
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			slog.Warn("GID scan base DN not found, skipping", "baseDN", baseDN)
 			continue
 		}
-		existing[entry.GetAttributeValue("cn")] = gid
+		if err != nil {
+			return nil, fmt.Errorf("failed to search LDAP: %w", err)
+		}
+
+		for _, entry := range sr.Entries {
+			gid, err := strconv.Atoi(entry.GetAttributeValue("gidNumber"))
+			if err != nil {
+				continue
+			}
+			existing[entry.GetAttributeValue("cn")] = gid
+		}
 	}
 
 	return existing, nil
 }
 
+
+// GidNumberInUse reports whether any group already has the given gidNumber,
+// returning the cn of the first one found.
+func GidNumberInUse(ctx context.Context, gidNumber int) (string, bool, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return "", false, fmt.Errorf("config not found in context")
+	}
+	l, err := Conn(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	searchRequest := ldap.NewSearchRequest(
+		cfg.LDAPGroupsBaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		fmt.Sprintf("(&(objectClass=group)(gidNumber=%d))", gidNumber),
+		[]string{"cn"},
+		nil,
+	)
+	sr, err := l.Search(searchRequest)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to search LDAP: %w", err)
+	}
+	if len(sr.Entries) == 0 {
+		return "", false, nil
+	}
+	return sr.Entries[0].GetAttributeValue("cn"), true, nil
+}
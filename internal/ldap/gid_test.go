@@ -0,0 +1,333 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+	"github.com/uoracs/directory-manager/internal/ldap/ldaptest"
+)
+
+const gidTestLDIF = `dn: DC=test
+objectClass: domain
+
+dn: OU=PIRGS,DC=test
+objectClass: organizationalUnit
+
+dn: CN=is.racs.pirg.alpha,OU=PIRGS,DC=test
+objectClass: group
+cn: is.racs.pirg.alpha
+gidNumber: 100000
+
+dn: OU=CEPHFS,DC=test
+objectClass: organizationalUnit
+
+dn: CN=HighWater,DC=test
+objectClass: contact
+
+dn: OU=Other,DC=test
+objectClass: organizationalUnit
+`
+
+const highWaterDN = "CN=HighWater,DC=test"
+
+// gidTestContext returns a context using a directory seeded with
+// gidTestLDIF, a global range of 100000-100999, and a cephfs range of
+// 200000-200009 with a high-water mark of its own.
+func gidTestContext(t *testing.T, client func(*ldaptest.Directory) Client) (context.Context, *ldaptest.Directory) {
+	t.Helper()
+	dir := ldaptest.New()
+	if err := dir.ReadLDIF(strings.NewReader(gidTestLDIF)); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		LDAPGroupsBaseDN:       "DC=test",
+		LDAPPirgDN:             "OU=PIRGS,DC=test",
+		LDAPCephfsDN:           "OU=CEPHFS,DC=test",
+		LDAPMinGid:             100000,
+		LDAPMaxGid:             100999,
+		CephfsMinGid:           200000,
+		CephfsMaxGid:           200009,
+		GidHighWaterDN:         highWaterDN,
+		GidHighWaterAttribute:  "uidNumber",
+		GidHighWaterAttributes: map[string]string{"cephfs": "gidNumber"},
+	}
+	ctx := context.WithValue(context.Background(), keys.ConfigKey, cfg)
+	var c Client = dir
+	if client != nil {
+		c = client(dir)
+	}
+	return WithClient(ctx, c), dir
+}
+
+func TestAllocateGidsRecordsLastGid(t *testing.T) {
+	ctx, dir := gidTestContext(t, nil)
+	first, err := AllocateGids(ctx, "", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != 100001 {
+		t.Errorf("first GID = %d, want 100001", first)
+	}
+	if got := dir.Values(highWaterDN, "uidNumber"); len(got) != 1 || got[0] != "100003" {
+		t.Errorf("high-water mark = %v, want [100003]", got)
+	}
+	next, err := AllocateGids(ctx, "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != 100004 {
+		t.Errorf("next GID = %d, want 100004 above the PI group's GID", next)
+	}
+}
+
+func TestAllocateGidsKeepsOneMarkPerRange(t *testing.T) {
+	ctx, dir := gidTestContext(t, nil)
+	if _, err := AllocateGids(ctx, "pirg", 1); err != nil {
+		t.Fatal(err)
+	}
+	first, err := AllocateGids(ctx, "cephfs", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != 200000 {
+		t.Errorf("first cephfs GID = %d, want 200000", first)
+	}
+	if got := dir.Values(highWaterDN, "gidNumber"); len(got) != 1 || got[0] != "200002" {
+		t.Errorf("cephfs mark = %v, want [200002]", got)
+	}
+	if got := dir.Values(highWaterDN, "uidNumber"); len(got) != 1 || got[0] != "100001" {
+		t.Errorf("global mark = %v, want [100001], untouched by cephfs", got)
+	}
+	// The global range, which pirg shares, is still allocated from its own mark
+	next, err := AllocateGids(ctx, "pirg", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != 100002 {
+		t.Errorf("next pirg GID = %d, want 100002", next)
+	}
+}
+
+func TestAllocateGidsFullRange(t *testing.T) {
+	ctx, _ := gidTestContext(t, nil)
+	if _, err := AllocateGids(ctx, "cephfs", 9); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AllocateGids(ctx, "cephfs", 2); err == nil {
+		t.Error("allocating past the end of the range succeeded")
+	}
+	if _, err := AllocateGids(ctx, "cephfs", 1); err != nil {
+		t.Errorf("allocating the last GID of the range: %v", err)
+	}
+}
+
+// setMark sets the global high-water mark as if an earlier allocation had.
+func setMark(t *testing.T, dir *ldaptest.Directory, gid int) {
+	t.Helper()
+	req := ldap.NewModifyRequest(highWaterDN, nil)
+	req.Replace("uidNumber", []string{strconv.Itoa(gid)})
+	if err := dir.Modify(req); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// racingClient moves the high-water mark before the first few changes to
+// it, like another host allocating at the same moment.
+type racingClient struct {
+	*ldaptest.Directory
+	races int
+	mark  int
+}
+
+func (c *racingClient) Modify(req *ldap.ModifyRequest) error {
+	if req.DN == highWaterDN && c.races > 0 {
+		c.races--
+		c.mark++
+		race := ldap.NewModifyRequest(highWaterDN, nil)
+		race.Replace("uidNumber", []string{strconv.Itoa(c.mark)})
+		if err := c.Directory.Modify(race); err != nil {
+			return err
+		}
+	}
+	return c.Directory.Modify(req)
+}
+
+func TestAllocateGidsRetriesWhenMarkMoves(t *testing.T) {
+	ctx, dir := gidTestContext(t, func(dir *ldaptest.Directory) Client {
+		return &racingClient{Directory: dir, races: 2, mark: 100010}
+	})
+	setMark(t, dir, 100005)
+	first, err := AllocateGids(ctx, "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != 100013 {
+		t.Errorf("first GID = %d, want 100013 above the mark the other host left", first)
+	}
+	if got := dir.Values(highWaterDN, "uidNumber"); len(got) != 1 || got[0] != "100013" {
+		t.Errorf("high-water mark = %v, want [100013]", got)
+	}
+}
+
+func TestAllocateGidsGivesUpAfterRetries(t *testing.T) {
+	ctx, dir := gidTestContext(t, func(dir *ldaptest.Directory) Client {
+		return &racingClient{Directory: dir, races: highWaterRetries + 1, mark: 100010}
+	})
+	setMark(t, dir, 100005)
+	if _, err := AllocateGids(ctx, "", 1); err == nil {
+		t.Error("allocation succeeded although the mark moved every time")
+	}
+}
+
+// addOtherGroup adds a group with the given gidNumber outside the GID scan
+// DNs but under the groups base DN.
+func addOtherGroup(t *testing.T, dir *ldaptest.Directory, name string, gid int) {
+	t.Helper()
+	req := ldap.NewAddRequest("CN="+name+",OU=Other,DC=test", nil)
+	req.Attribute("objectClass", []string{"group"})
+	req.Attribute("cn", []string{name})
+	req.Attribute("gidNumber", []string{strconv.Itoa(gid)})
+	if err := dir.Add(req); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAllocateGidsSkipsGidsHeldOutsideScanDNs(t *testing.T) {
+	ctx, dir := gidTestContext(t, nil)
+	addOtherGroup(t, dir, "other1", 100001)
+	addOtherGroup(t, dir, "other2", 100003)
+	first, err := AllocateGids(ctx, "", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != 100004 {
+		t.Errorf("first GID = %d, want 100004 past the GIDs held outside the scan DNs", first)
+	}
+	if _, inUse, err := GidNumberInUse(ctx, first); err != nil || inUse {
+		t.Errorf("GidNumberInUse(%d) = %v, %v; want the allocated GID free", first, inUse, err)
+	}
+}
+
+func TestAllocateGidsWithoutUniquenessCheck(t *testing.T) {
+	ctx, dir := gidTestContext(t, nil)
+	enforce := false
+	ctx.Value(keys.ConfigKey).(*config.Config).EnforceGidUniqueness = &enforce
+	addOtherGroup(t, dir, "other1", 100001)
+	first, err := AllocateGids(ctx, "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != 100001 {
+		t.Errorf("first GID = %d, want 100001 with enforce_gid_uniqueness off", first)
+	}
+}
+
+func TestCreateGroupGidUniqueness(t *testing.T) {
+	on, off := true, false
+	cases := []struct {
+		name    string
+		enforce *bool
+		wantErr bool
+	}{
+		{name: "default", wantErr: true},
+		{name: "enforced", enforce: &on, wantErr: true},
+		{name: "not enforced", enforce: &off},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, dir := gidTestContext(t, nil)
+			ctx.Value(keys.ConfigKey).(*config.Config).EnforceGidUniqueness = tc.enforce
+			if err := CreateGroup(ctx, "OU=Other,DC=test", "first", 100500); err != nil {
+				t.Fatal(err)
+			}
+			err := CreateGroup(ctx, "OU=Other,DC=test", "second", 100500)
+			exists := dir.Exists("CN=second,OU=Other,DC=test")
+			if !tc.wantErr {
+				if err != nil || !exists {
+					t.Errorf("CreateGroup() = %v, created %v; want the second group created", err, exists)
+				}
+				return
+			}
+			if !errors.Is(err, ErrAlreadyExists) || !strings.Contains(err.Error(), "used by group first") {
+				t.Errorf("CreateGroup() = %v, want ErrAlreadyExists naming the first group", err)
+			}
+			if exists {
+				t.Error("second group created with a duplicate gidNumber")
+			}
+			// A GID held by a group outside the family OUs counts too
+			if err := CreateGroup(ctx, "OU=Other,DC=test", "third", 100000); !errors.Is(err, ErrAlreadyExists) {
+				t.Errorf("CreateGroup() with the PIRG's GID = %v, want ErrAlreadyExists", err)
+			}
+		})
+	}
+}
+
+func TestComputeGidUsage(t *testing.T) {
+	cases := []struct {
+		name     string
+		min, max int
+		gids     []int
+		want     GidUsage
+	}{
+		{
+			name: "empty",
+			min:  100, max: 199,
+			want: GidUsage{Min: 100, Max: 199, Size: 100, Free: 100, LargestFreeBlock: 100},
+		},
+		{
+			name: "full",
+			min:  100, max: 103,
+			gids: []int{103, 101, 100, 102},
+			want: GidUsage{Min: 100, Max: 103, Size: 4, Allocated: 4, LargestFreeBlock: 0, Percent: 100},
+		},
+		{
+			name: "gaps",
+			min:  100, max: 109,
+			gids: []int{100, 101, 105, 109},
+			want: GidUsage{Min: 100, Max: 109, Size: 10, Allocated: 4, Free: 6, LargestFreeBlock: 3, Percent: 40},
+		},
+		{
+			name: "largest block at the top",
+			min:  100, max: 109,
+			gids: []int{100},
+			want: GidUsage{Min: 100, Max: 109, Size: 10, Allocated: 1, Free: 9, LargestFreeBlock: 9, Percent: 10},
+		},
+		{
+			name: "duplicates and GIDs out of range are ignored",
+			min:  100, max: 103,
+			gids: []int{99, 100, 100, 104, 5000, -1},
+			want: GidUsage{Min: 100, Max: 103, Size: 4, Allocated: 1, Free: 3, LargestFreeBlock: 3, Percent: 25},
+		},
+		{
+			name: "only GIDs out of range",
+			min:  100, max: 101,
+			gids: []int{50, 102},
+			want: GidUsage{Min: 100, Max: 101, Size: 2, Free: 2, LargestFreeBlock: 2},
+		},
+		{
+			name: "single GID range",
+			min:  100, max: 100,
+			gids: []int{100},
+			want: GidUsage{Min: 100, Max: 100, Size: 1, Allocated: 1, Percent: 100},
+		},
+		{
+			name: "backwards range",
+			min:  200, max: 100,
+			gids: []int{150},
+			want: GidUsage{Min: 200, Max: 100},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ComputeGidUsage(tc.min, tc.max, tc.gids); got != tc.want {
+				t.Errorf("ComputeGidUsage() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// maxInfoLength is AD's rangeUpper for the info attribute, shown as Notes
+// in ADUC.
+const maxInfoLength = 1024
+
+// changeNotePrefix marks the lines of info written by AppendChangeNote, so
+// notes people typed by hand are never trimmed.
+const changeNotePrefix = "[directory-manager] "
+
+// changeNoteRetries is how many times modifyInfo starts over when info
+// changed between reading and writing it.
+const changeNoteRetries = 3
+
+// appendChangeNote returns info with note added as the last line. Only the
+// newest keep change notes are kept, and older ones are dropped until the
+// result fits in maxInfoLength. Other lines are left alone.
+func appendChangeNote(info string, note string, keep int) (string, error) {
+	var lines []string
+	if info != "" {
+		lines = strings.Split(strings.ReplaceAll(info, "\r\n", "\n"), "\n")
+	}
+	lines = append(lines, changeNotePrefix+note)
+
+	isNote := func(line string) bool { return strings.HasPrefix(line, changeNotePrefix) }
+	notes := 0
+	for _, line := range lines {
+		if isNote(line) {
+			notes++
+		}
+	}
+	// Drop the oldest notes, but never the new one
+	for notes > 1 && (notes > keep || utf8.RuneCountInString(strings.Join(lines, "\r\n")) > maxInfoLength) {
+		for i, line := range lines {
+			if isNote(line) {
+				lines = append(lines[:i], lines[i+1:]...)
+				notes--
+				break
+			}
+		}
+	}
+	updated := strings.Join(lines, "\r\n")
+	if n := utf8.RuneCountInString(updated); n > maxInfoLength {
+		return "", fmt.Errorf("info would be %d characters, the limit is %d", n, maxInfoLength)
+	}
+	return updated, nil
+}
+
+// getInfo returns the info attribute of the entry at dn.
+func getInfo(l *TimedConn, dn string) (string, error) {
+	searchRequest := ldap.NewSearchRequest(
+		dn,
+		ldap.ScopeBaseObject,
+		ldap.NeverDerefAliases,
+		0, 0, false,
+		"(objectClass=*)",
+		[]string{"info"},
+		nil,
+	)
+	sr, err := l.Search(searchRequest)
+	if err != nil {
+		if ldapErr, ok := err.(*ldap.Error); ok && ldapErr.ResultCode == ldap.LDAPResultNoSuchObject {
+			return "", fmt.Errorf("%s %w", dn, ErrNotFound)
+		}
+		return "", fmt.Errorf("failed to search LDAP: %w", err)
+	}
+	if len(sr.Entries) == 0 {
+		return "", fmt.Errorf("%s %w", dn, ErrNotFound)
+	}
+	return sr.Entries[0].GetAttributeValue("info"), nil
+}
+
+// AppendChangeNote adds note to the info attribute of the group at groupDN,
+// keeping the newest keep notes.
+func AppendChangeNote(ctx context.Context, groupDN string, note string, keep int) error {
+	err := modifyInfo(ctx, groupDN, func(info string) (string, error) {
+		return appendChangeNote(info, note, keep)
+	})
+	if err != nil {
+		return err
+	}
+	slog.Debug("Added change note", "groupDN", groupDN, "note", note)
+	return nil
+}
+
+// modifyInfo replaces the info attribute of groupDN with what update makes
+// of it. The old value is removed and the new one added in the same modify,
+// so a concurrent change makes the modify fail instead of being
+// overwritten. In that case it reads info again and retries.
+func modifyInfo(ctx context.Context, groupDN string, update func(info string) (string, error)) error {
+	l, err := Conn(ctx)
+	if err != nil {
+		return err
+	}
+	for attempt := 0; ; attempt++ {
+		info, err := getInfo(l, groupDN)
+		if err != nil {
+			return fmt.Errorf("failed to read info of %s: %w", groupDN, err)
+		}
+		updated, err := update(info)
+		if err != nil {
+			return fmt.Errorf("failed to update info of %s: %w", groupDN, err)
+		}
+		if updated == info {
+			return nil
+		}
+		modifyRequest := ldap.NewModifyRequest(groupDN, nil)
+		if info != "" {
+			modifyRequest.Delete("info", []string{info})
+		}
+		if updated != "" {
+			modifyRequest.Add("info", []string{updated})
+		}
+		err = retryTransient(ctx, groupDN, func() error { return l.Modify(modifyRequest) })
+		if err == nil {
+			return nil
+		}
+		var ldapErr *ldap.Error
+		conflict := errors.As(err, &ldapErr) &&
+			(ldapErr.ResultCode == ldap.LDAPResultNoSuchAttribute || ldapErr.ResultCode == ldap.LDAPResultAttributeOrValueExists)
+		if !conflict || attempt == changeNoteRetries {
+			return fmt.Errorf("failed to set info on %s: %w", groupDN, accessError(ctx, groupDN, err))
+		}
+		slog.Debug("Info changed while updating it, retrying", "groupDN", groupDN, "attempt", attempt+1)
+	}
+}
@@ -0,0 +1,84 @@
+package ldap
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestAppendChangeNote(t *testing.T) {
+	cases := []struct {
+		name string
+		info string
+		note string
+		keep int
+		want string
+	}{
+		{
+			name: "empty",
+			note: "2026-01-02 add-member: RT#1",
+			keep: 5,
+			want: "[directory-manager] 2026-01-02 add-member: RT#1",
+		},
+		{
+			name: "after hand-written notes",
+			info: "Owned by the lab\nBilled to 1234",
+			note: "n",
+			keep: 5,
+			want: "Owned by the lab\r\nBilled to 1234\r\n[directory-manager] n",
+		},
+		{
+			name: "keeps the newest",
+			info: "[directory-manager] a\r\nby hand\r\n[directory-manager] b",
+			note: "c",
+			keep: 2,
+			want: "by hand\r\n[directory-manager] b\r\n[directory-manager] c",
+		},
+		{
+			name: "keep of zero still records the new note",
+			info: "[directory-manager] a",
+			note: "b",
+			keep: 0,
+			want: "[directory-manager] b",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := appendChangeNote(tc.info, tc.note, tc.keep)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("appendChangeNote() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAppendChangeNoteTruncatesToLimit(t *testing.T) {
+	var lines []string
+	for i := 0; i < 40; i++ {
+		lines = append(lines, changeNotePrefix+strings.Repeat("x", 40))
+	}
+	info := "by hand\r\n" + strings.Join(lines, "\r\n")
+	got, err := appendChangeNote(info, "newest", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := utf8.RuneCountInString(got); n > maxInfoLength {
+		t.Errorf("info is %d characters, over the limit of %d", n, maxInfoLength)
+	}
+	if !strings.HasPrefix(got, "by hand\r\n") {
+		t.Errorf("hand-written line dropped: %q", got)
+	}
+	if !strings.HasSuffix(got, changeNotePrefix+"newest") {
+		t.Errorf("new note missing from the end: %q", got)
+	}
+}
+
+func TestAppendChangeNoteTooLong(t *testing.T) {
+	info := strings.Repeat("y", maxInfoLength-5)
+	if _, err := appendChangeNote(info, "note", 5); err == nil {
+		t.Error("no error for a hand-written info leaving no room for the note")
+	}
+}
@@ -2,52 +2,163 @@ package ldap
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-ldap/ldap/v3"
 	"github.com/uoracs/directory-manager/internal/config"
 	"github.com/uoracs/directory-manager/internal/keys"
+	"github.com/uoracs/directory-manager/internal/managedgroup"
 )
 
+var (
+	// ErrNotFound is returned, wrapped, when a user or group does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrAlreadyExists is returned, wrapped, when an object to be created already exists.
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrPolicy is returned, wrapped, when a configured policy forbids an action.
+	ErrPolicy = errors.New("policy violation")
+	// ErrPermissionDenied is returned, wrapped, when the bind account may not make a change.
+	ErrPermissionDenied = errors.New("permission denied")
+	// ErrNotOU is returned, wrapped, when a DN expected to be an OU is some other object.
+	ErrNotOU = errors.New("not an organizational unit")
+	// ErrNotGroup is returned, wrapped, when a DN expected to be a group is some other object.
+	ErrNotGroup = errors.New("not a group")
+	// ErrNotEmpty is returned, wrapped, when a group still has members, or an OU still holds objects, and can't be deleted.
+	ErrNotEmpty = errors.New("not empty")
+	// ErrFrozen is returned, wrapped, when a change is refused because the group is frozen.
+	ErrFrozen = errors.New("frozen")
+	// ErrNotMember is returned, wrapped, when a member to be removed wasn't in the group, so nothing changed.
+	ErrNotMember = errors.New("was not a member")
+)
+
+// accessError explains an Insufficient Access Rights failure on dn in terms
+// operators can act on, since AD's own message doesn't say who was denied or
+// where. Any other error is returned unchanged.
+func accessError(ctx context.Context, dn string, err error) error {
+	var ldapErr *ldap.Error
+	if !errors.As(err, &ldapErr) || ldapErr.ResultCode != ldap.LDAPResultInsufficientAccessRights {
+		return err
+	}
+	slog.Debug("Insufficient access rights", "dn", dn, "error", err)
+	account := "the bind account"
+	if cfg, ok := ctx.Value(keys.ConfigKey).(*config.Config); ok && cfg != nil {
+		account = fmt.Sprintf("the bind account %s", cfg.LDAPUsername)
+	}
+	return fmt.Errorf("%s lacks permission to modify %s; check delegation on the target OU: %w", account, dn, ErrPermissionDenied)
+}
+
+// ConvertDNToObjectName returns the value of the first RDN of dn, e.g. the
+// cn of a user or group, with any escaping undone, so "CN=Lab\, Inc,OU=..."
+// yields "Lab, Inc".
 func ConvertDNToObjectName(dn string) (string, error) {
-	parts := strings.Split(dn, ",")
-	if len(parts) == 0 {
-		return "", fmt.Errorf("invalid DN format")
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil {
+		return "", fmt.Errorf("invalid DN %q: %w", dn, err)
+	}
+	if len(parsed.RDNs) == 0 || len(parsed.RDNs[0].Attributes) == 0 {
+		return "", fmt.Errorf("invalid DN %q: no RDN", dn)
 	}
-	head := parts[0]
-	hparts := strings.Split(head, "=")
-	if len(hparts) != 2 {
-		return "", fmt.Errorf("invalid DN format")
+	value := parsed.RDNs[0].Attributes[0].Value
+	if value == "" {
+		return "", fmt.Errorf("invalid DN %q: empty RDN value", dn)
 	}
-	return hparts[1], nil
+	return value, nil
 }
 
+// LoadLDAPConnection returns ctx with a connector that is already connected,
+// for work that certainly needs the directory, such as a bulk worker.
 func LoadLDAPConnection(ctx context.Context) (context.Context, error) {
+	ctx = WithConnector(ctx)
+	if _, err := Conn(ctx); err != nil {
+		return nil, err
+	}
+	return ctx, nil
+}
+
+// dial connects and binds to the first of the configured servers that
+// accepts the bind.
+func dial(ctx context.Context) (Client, error) {
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
 	if cfg == nil {
 		return nil, fmt.Errorf("config not found in context")
 	}
-	connStr := fmt.Sprintf("ldaps://%s:%d", cfg.LDAPServer, cfg.LDAPPort)
-	l, err := ldap.DialURL(connStr)
+	if err := cfg.CheckLDAP(); err != nil {
+		return nil, err
+	}
+	// Try each server in turn until one binds. Bad credentials would fail
+	// everywhere, and only bring the account closer to lockout.
+	var errs []error
+	for _, server := range cfg.Servers() {
+		l, err := dialServer(ctx, cfg, server)
+		if err == nil {
+			slog.Debug("Connected to LDAP server", "server", server)
+			return l, nil
+		}
+		// The other servers would reject bad credentials too, and there's
+		// no time left to try them after a timeout or Ctrl-C
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) || ctx.Err() != nil {
+			return nil, err
+		}
+		slog.Warn("Failed to connect to LDAP server, trying the next one", "server", server, "error", err)
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// dialServer connects and binds to one server. The connection is closed
+// once ctx is done, bind included, so --timeout bounds the whole command
+// rather than each request, and Ctrl-C stops waiting on the server.
+func dialServer(ctx context.Context, cfg *config.Config, server string) (Client, error) {
+	connStr := fmt.Sprintf("ldaps://%s:%d", server, cfg.LDAPPort)
+	dialer := &net.Dialer{}
+	if deadline, ok := ctx.Deadline(); ok {
+		dialer.Deadline = deadline
+	}
+	l, err := ldap.DialURL(connStr, ldap.DialWithDialer(dialer))
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to LDAP server: %w", err)
+		return nil, fmt.Errorf("failed to connect to LDAP server %s: %w", server, err)
 	}
+	conn := closeOnDone(ctx, l)
 
 	err = l.Bind(cfg.LDAPUsername, cfg.LDAPPassword)
 	if err != nil {
-		return nil, fmt.Errorf("failed to bind to LDAP server: %w", err)
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("failed to bind to LDAP server %s: %w", server, ctx.Err())
+		}
+		return nil, fmt.Errorf("failed to bind to LDAP server %s: %w", server, err)
 	}
+	return conn, nil
+}
+
+// ctxConn is a connection that is closed once its context is done, the
+// only way to abandon the requests waiting on it.
+type ctxConn struct {
+	*ldap.Conn
+	stop func() bool
+}
+
+func closeOnDone(ctx context.Context, l *ldap.Conn) *ctxConn {
+	return &ctxConn{Conn: l, stop: context.AfterFunc(ctx, func() { l.Close() })}
+}
 
-	return context.WithValue(ctx, keys.LDAPConnKey, l), nil
+// Close closes the connection and stops watching its context.
+func (c *ctxConn) Close() error {
+	c.stop()
+	return c.Conn.Close()
 }
 
 func CreateOU(ctx context.Context, baseDN string, name string) error {
-	l := ctx.Value(keys.LDAPConnKey).(*ldap.Conn)
-	if l == nil {
-		return fmt.Errorf("LDAP connection not found in context")
+	l, err := Conn(ctx)
+	if err != nil {
+		return err
 	}
 
 	// Construct the DN for the new group.
@@ -61,6 +172,11 @@ func CreateOU(ctx context.Context, baseDN string, name string) error {
 	if exists {
 		return nil
 	}
+	// An OU created inside a group or user makes a tree nothing else finds
+	err = CheckOU(ctx, baseDN)
+	if err != nil {
+		return fmt.Errorf("refusing to create OU %s: %w", name, err)
+	}
 
 	// Create a new add request.
 	addRequest := ldap.NewAddRequest(ouDN, nil)
@@ -68,17 +184,24 @@ func CreateOU(ctx context.Context, baseDN string, name string) error {
 	addRequest.Attribute("ou", []string{name})
 
 	// Execute the add request.
-	if err := l.Add(addRequest); err != nil {
-		return fmt.Errorf("failed to add group %s: %w", name, err)
+	if err := retryTransient(ctx, ouDN, func() error { return l.Add(addRequest) }); err != nil {
+		return fmt.Errorf("failed to add group %s: %w", name, accessError(ctx, baseDN, err))
 	}
 
 	return nil
 }
 
-func CreateGroup(ctx context.Context, baseDN string, name string, gidNumber int) error {
-	l := ctx.Value(keys.LDAPConnKey).(*ldap.Conn)
-	if l == nil {
-		return fmt.Errorf("LDAP connection not found in context")
+// GroupOption sets additional attributes on a group created by CreateGroup.
+type GroupOption func(*ldap.AddRequest)
+
+func CreateGroup(ctx context.Context, baseDN string, name string, gidNumber int, opts ...GroupOption) error {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return fmt.Errorf("config not found in context")
+	}
+	l, err := Conn(ctx)
+	if err != nil {
+		return err
 	}
 
 	// Construct the DN for the new group.
@@ -94,6 +217,11 @@ func CreateGroup(ctx context.Context, baseDN string, name string, gidNumber int)
 		return nil
 	}
 
+	// Refuse to hand out a gidNumber another group already has.
+	if err := checkGidUnique(ctx, cfg, gidNumber); err != nil {
+		return err
+	}
+
 	// Create a new add request.
 	// Note: In AD with Unix extensions, a group may include both the "group" and "posixGroup" object classes.
 	addRequest := ldap.NewAddRequest(groupDN, nil)
@@ -106,19 +234,22 @@ func CreateGroup(ctx context.Context, baseDN string, name string, gidNumber int)
 	addRequest.Attribute("groupType", []string{"-2147483646"})
 	// Set the gidNumber attribute as a string.
 	addRequest.Attribute("gidNumber", []string{strconv.Itoa(gidNumber)})
+	for _, opt := range opts {
+		opt(addRequest)
+	}
 
 	// Execute the add request.
-	if err := l.Add(addRequest); err != nil {
-		return fmt.Errorf("failed to add group %s: %w", name, err)
+	if err := retryTransient(ctx, groupDN, func() error { return l.Add(addRequest) }); err != nil {
+		return fmt.Errorf("failed to add group %s: %w", name, accessError(ctx, baseDN, err))
 	}
 
 	return nil
 }
 
 func AddUserToGroup(ctx context.Context, groupDN string, userDN string) error {
-	l := ctx.Value(keys.LDAPConnKey).(*ldap.Conn)
-	if l == nil {
-		return fmt.Errorf("LDAP connection not found in context")
+	l, err := Conn(ctx)
+	if err != nil {
+		return err
 	}
 
 	// Create a new modify request to add the user to the group.
@@ -126,22 +257,23 @@ func AddUserToGroup(ctx context.Context, groupDN string, userDN string) error {
 	modifyRequest.Add("member", []string{userDN})
 
 	// Execute the modify request.
-	if err := l.Modify(modifyRequest); err != nil {
-		// Handle the case where the user is already a member of the group.
-		if ldapErr, ok := err.(*ldap.Error); ok && ldapErr.ResultCode == ldap.LDAPResultEntryAlreadyExists {
-			slog.Debug("User already in group", "userDN", userDN, "groupDN", groupDN)
+	if err := retryTransient(ctx, groupDN, func() error { return l.Modify(modifyRequest) }); err != nil {
+		// Handle the case where the user is already a member of the group,
+		// including when another writer added them first.
+		if classifyResult(err, ldap.LDAPResultEntryAlreadyExists, ldap.LDAPResultAttributeOrValueExists) == changeSatisfied {
+			slog.Debug("User already in group", "userDN", userDN, "groupDN", groupDN, "error", err)
 			return nil
 		}
-		return fmt.Errorf("failed to add user %s to group %s: %w", userDN, groupDN, err)
+		return fmt.Errorf("failed to add user %s to group %s: %w", userDN, groupDN, accessError(ctx, groupDN, err))
 	}
 
 	return nil
 }
 
 func RemoveUserFromGroup(ctx context.Context, groupDN string, userDN string) error {
-	l := ctx.Value(keys.LDAPConnKey).(*ldap.Conn)
-	if l == nil {
-		return fmt.Errorf("LDAP connection not found in context")
+	l, err := Conn(ctx)
+	if err != nil {
+		return err
 	}
 
 	// Create a new modify request to remove the user from the group.
@@ -149,17 +281,58 @@ func RemoveUserFromGroup(ctx context.Context, groupDN string, userDN string) err
 	modifyRequest.Delete("member", []string{userDN})
 
 	// Execute the modify request.
-	if err := l.Modify(modifyRequest); err != nil {
-		return fmt.Errorf("failed to remove user %s from group %s: %w", userDN, groupDN, err)
+	if err := retryTransient(ctx, groupDN, func() error { return l.Modify(modifyRequest) }); err != nil {
+		// Another writer may have removed the user first.
+		if classifyResult(err, ldap.LDAPResultNoSuchAttribute) == changeSatisfied {
+			slog.Debug("User already not in group", "userDN", userDN, "groupDN", groupDN, "error", err)
+			return nil
+		}
+		return fmt.Errorf("failed to remove user %s from group %s: %w", userDN, groupDN, accessError(ctx, groupDN, err))
 	}
 
 	return nil
 }
 
+// memberModifyBatchSize is how many members RemoveUsersFromGroup removes in
+// one modify, well under AD's limit of 5000 values per change.
+const memberModifyBatchSize = 500
+
+// RemoveUsersFromGroup removes many members from groupDN in batched modifies
+// rather than one modify per member. AD applies a modify whole or not at
+// all, so a batch that fails because one of its members is already gone is
+// redone one member at a time, and the rest are still removed.
+func RemoveUsersFromGroup(ctx context.Context, groupDN string, userDNs []string) error {
+	l, err := Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(userDNs); start += memberModifyBatchSize {
+		batch := userDNs[start:min(start+memberModifyBatchSize, len(userDNs))]
+		modifyRequest := ldap.NewModifyRequest(groupDN, nil)
+		modifyRequest.Delete("member", batch)
+		slog.Debug("Removing members from group", "groupDN", groupDN, "count", len(batch))
+		err := retryTransient(ctx, groupDN, func() error { return l.Modify(modifyRequest) })
+		if err == nil {
+			continue
+		}
+		if classifyResult(err, ldap.LDAPResultNoSuchAttribute) != changeSatisfied {
+			return fmt.Errorf("failed to remove %d members from group %s: %w", len(batch), groupDN, accessError(ctx, groupDN, err))
+		}
+		slog.Debug("A member was already not in group, removing one at a time", "groupDN", groupDN, "error", err)
+		for _, userDN := range batch {
+			if err := RemoveUserFromGroup(ctx, groupDN, userDN); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func UserInGroup(ctx context.Context, groupDN string, userDN string) (bool, error) {
-	l := ctx.Value(keys.LDAPConnKey).(*ldap.Conn)
-	if l == nil {
-		return false, fmt.Errorf("LDAP connection not found in context")
+	l, err := Conn(ctx)
+	if err != nil {
+		return false, err
 	}
 
 	// Create a new search request to check if the user is a member of the group.
@@ -182,39 +355,18 @@ func UserInGroup(ctx context.Context, groupDN string, userDN string) (bool, erro
 }
 
 func GetGroupMemberDNs(ctx context.Context, groupDN string) ([]string, error) {
-	l := ctx.Value(keys.LDAPConnKey).(*ldap.Conn)
-	if l == nil {
-		return nil, fmt.Errorf("LDAP connection not found in context")
-	}
-
-	// Create a new search request to get the members of the group.
-	searchRequest := ldap.NewSearchRequest(
-		groupDN,
-		ldap.ScopeBaseObject,
-		ldap.NeverDerefAliases,
-		0, 0, false,
-		"(objectClass=*)",
-		[]string{"member"},
-		nil,
-	)
-
-	sr, err := l.Search(searchRequest)
+	l, err := Conn(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to search LDAP: %w", err)
-	}
-
-	if len(sr.Entries) == 0 {
-		return nil, fmt.Errorf("group %q not found", groupDN)
+		return nil, err
 	}
 
-	members := sr.Entries[0].GetAttributeValues("member")
-	return members, nil
+	return getMemberValues(ctx, l, groupDN)
 }
 
 func GetGroupsForUser(ctx context.Context, userDN string) ([]string, error) {
-	l := ctx.Value(keys.LDAPConnKey).(*ldap.Conn)
-	if l == nil {
-		return nil, fmt.Errorf("LDAP connection not found in context")
+	l, err := Conn(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create a new search request to get the groups of the user.
@@ -230,45 +382,93 @@ func GetGroupsForUser(ctx context.Context, userDN string) ([]string, error) {
 
 	sr, err := l.Search(searchRequest)
 	if err != nil {
+		if ldapErr, ok := err.(*ldap.Error); ok && ldapErr.ResultCode == ldap.LDAPResultNoSuchObject {
+			return nil, fmt.Errorf("user %q %w", userDN, ErrNotFound)
+		}
 		return nil, fmt.Errorf("failed to search LDAP: %w", err)
 	}
 
 	if len(sr.Entries) == 0 {
-		return nil, fmt.Errorf("user %q not found", userDN)
+		return nil, fmt.Errorf("user %q %w", userDN, ErrNotFound)
 	}
 
 	groups := sr.Entries[0].GetAttributeValues("memberOf")
 	return groups, nil
 }
 
-// GetGroupMemberUsernames retrieves the usernames of all members of a group.
-func GetGroupMemberUsernames(ctx context.Context, groupDN string) ([]string, error) {
-	l := ctx.Value(keys.LDAPConnKey).(*ldap.Conn)
-	if l == nil {
-		return nil, fmt.Errorf("LDAP connection not found in context")
-	}
+// DNGroup is a group an object is a direct member of.
+type DNGroup struct {
+	// Family is the managed group family whose prefix the group has, or
+	// empty for groups the tool doesn't manage.
+	Family string `json:"family,omitempty"`
+	// Name is the CN without the family prefix, or the whole CN.
+	Name string            `json:"name"`
+	Kind managedgroup.Kind `json:"kind,omitempty"`
+	CN   string            `json:"cn"`
+	DN   string            `json:"dn"`
+}
 
-	// Create a new search request to get the members of the group.
-	searchRequest := ldap.NewSearchRequest(
-		groupDN,
-		ldap.ScopeBaseObject,
-		ldap.NeverDerefAliases,
-		0, 0, false,
-		"(objectClass=*)",
-		[]string{"member"},
-		nil,
-	)
-	// fmt.Printf("norm search request: %+v\n", searchRequest)
-	sr, err := l.Search(searchRequest)
+// GetGroupsForDN returns the groups the object at dn is a direct member of,
+// classified by family. It reads memberOf with GetGroupsForUser, which works
+// for any object with that attribute, such as computers and service
+// accounts, not just users. memberOf leaves out the primary group and
+// groups in other domains.
+func GetGroupsForDN(ctx context.Context, dn string) ([]DNGroup, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	if _, err := ldap.ParseDN(dn); err != nil {
+		return nil, fmt.Errorf("invalid DN %s: %w", dn, err)
+	}
+	groupDNs, err := GetGroupsForUser(ctx, dn)
 	if err != nil {
-		return nil, fmt.Errorf("failed to search LDAP: %w", err)
+		return nil, err
 	}
+	groups := make([]DNGroup, 0, len(groupDNs))
+	for _, groupDN := range groupDNs {
+		cn, err := ConvertDNToObjectName(groupDN)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert DN to object name: %w", err)
+		}
+		g := DNGroup{Name: cn, CN: cn, DN: groupDN}
+		for _, f := range managedgroup.Families {
+			if short, ok := strings.CutPrefix(strings.ToLower(cn), f.Prefix); ok {
+				g.Family, g.Name = f.Name, short
+				g.Kind = f.Classify(cfg.BaseDN(f.Name), groupDN).Kind
+				break
+			}
+		}
+		groups = append(groups, g)
+	}
+	// Managed groups first, by family and name
+	slices.SortFunc(groups, func(a, b DNGroup) int {
+		if (a.Family == "") != (b.Family == "") {
+			if a.Family == "" {
+				return 1
+			}
+			return -1
+		}
+		if a.Family != b.Family {
+			return strings.Compare(a.Family, b.Family)
+		}
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+	slog.Debug("Groups for DN", "dn", dn, "count", len(groups))
+	return groups, nil
+}
 
-	if len(sr.Entries) == 0 {
-		return nil, fmt.Errorf("group %q not found", groupDN)
+// GetGroupMemberUsernames retrieves the usernames of all members of a group.
+func GetGroupMemberUsernames(ctx context.Context, groupDN string) ([]string, error) {
+	l, err := Conn(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	members := sr.Entries[0].GetAttributeValues("member")
+	members, err := getMemberValues(ctx, l, groupDN)
+	if err != nil {
+		return nil, err
+	}
 	usernames := make([]string, len(members))
 	for i, member := range members {
 		u, err := ConvertDNToObjectName(member)
@@ -285,9 +485,9 @@ func GetUserDN(ctx context.Context, username string) (string, error) {
 	if cfg == nil {
 		return "", fmt.Errorf("config not found in context")
 	}
-	l := ctx.Value(keys.LDAPConnKey).(*ldap.Conn)
-	if l == nil {
-		return "", fmt.Errorf("LDAP connection not found in context")
+	l, err := Conn(ctx)
+	if err != nil {
+		return "", err
 	}
 	baseDN := cfg.LDAPUsersBaseDN
 	// Build a search filter.
@@ -310,28 +510,24 @@ func GetUserDN(ctx context.Context, username string) (string, error) {
 	// Execute the search.
 	sr, err := l.Search(searchRequest)
 	if err != nil {
-		return "", fmt.Errorf("LDAP search failed: %v", err)
+		return "", fmt.Errorf("LDAP search failed: %w", err)
 	}
 
 	// Check if we got any results.
 	if len(sr.Entries) == 0 {
-		return "", fmt.Errorf("user %q not found", username)
+		return "", fmt.Errorf("user %q %w", username, ErrNotFound)
 	}
 
 	// Return the distinguished name of the first matching entry.
 	return sr.Entries[0].DN, nil
 }
 
-func GetGroupDN(ctx context.Context, groupname string) (string, bool, error) {
-	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
-	if cfg == nil {
-		return "", false, fmt.Errorf("config not found in context")
-	}
-	l := ctx.Value(keys.LDAPConnKey).(*ldap.Conn)
-	if l == nil {
-		return "", false, fmt.Errorf("LDAP connection not found in context")
+// GetGroupDNs returns the DNs of all groups under baseDN with the given cn.
+func GetGroupDNs(ctx context.Context, baseDN string, groupname string) ([]string, error) {
+	l, err := Conn(ctx)
+	if err != nil {
+		return nil, err
 	}
-	baseDN := cfg.LDAPGroupsBaseDN
 	// Build a search filter.
 	// The filter targets groups with a matching cn.
 	filter := fmt.Sprintf("(&(objectClass=group)(cn=%s))", ldap.EscapeFilter(groupname))
@@ -352,28 +548,85 @@ func GetGroupDN(ctx context.Context, groupname string) (string, bool, error) {
 	// Execute the search.
 	sr, err := l.Search(searchRequest)
 	if err != nil {
-		// Handle the case where the group does not exist, this is not an error
+		// Handle the case where the base does not exist, this is not an error
 		if ldapErr, ok := err.(*ldap.Error); ok && ldapErr.ResultCode == ldap.LDAPResultNoSuchObject {
 			slog.Debug("Group not found", "groupname", groupname)
-			return "", false, nil
+			return nil, nil
 		}
 		slog.Error("LDAP search failed", "error", err)
-		return "", false, fmt.Errorf("LDAP search failed: %v", err)
+		return nil, fmt.Errorf("LDAP search failed: %w", err)
 	}
 
-	if len(sr.Entries) == 0 {
+	dns := make([]string, len(sr.Entries))
+	for i, entry := range sr.Entries {
+		dns[i] = entry.DN
+	}
+	return dns, nil
+}
+
+// GetGroupDN returns the DN of the group under baseDN with the given cn.
+// Rather than pick one arbitrarily, it errors if more than one group matches.
+func GetGroupDN(ctx context.Context, baseDN string, groupname string) (string, bool, error) {
+	dns, err := GetGroupDNs(ctx, baseDN, groupname)
+	if err != nil {
+		return "", false, err
+	}
+	switch len(dns) {
+	case 0:
 		slog.Debug("Group not found", "groupname", groupname)
 		return "", false, nil
+	case 1:
+		return dns[0], true, nil
+	default:
+		return "", false, fmt.Errorf("multiple groups named %s found: %s", groupname, strings.Join(dns, "; "))
+	}
+}
+
+// GetGroupDNsByCN returns the DNs of the groups under baseDN with any of
+// the given cns, keyed by lowercased cn, from one search. Several groups can
+// be resolved in a single round trip this way. A missing baseDN finds
+// nothing rather than failing.
+func GetGroupDNsByCN(ctx context.Context, baseDN string, cns []string) (map[string][]string, error) {
+	l, err := Conn(ctx)
+	if err != nil {
+		return nil, err
 	}
+	var filter strings.Builder
+	filter.WriteString("(&(objectClass=group)(|")
+	for _, cn := range cns {
+		filter.WriteString(fmt.Sprintf("(cn=%s)", ldap.EscapeFilter(cn)))
+	}
+	filter.WriteString("))")
 
-	return sr.Entries[0].DN, true, nil
+	searchRequest := ldap.NewSearchRequest(
+		baseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0, 0, false,
+		filter.String(),
+		[]string{"cn"},
+		nil,
+	)
+	sr, err := l.Search(searchRequest)
+	if err != nil {
+		if ldapErr, ok := err.(*ldap.Error); ok && ldapErr.ResultCode == ldap.LDAPResultNoSuchObject {
+			return map[string][]string{}, nil
+		}
+		return nil, fmt.Errorf("failed to search LDAP: %w", err)
+	}
+	dns := make(map[string][]string, len(cns))
+	for _, entry := range sr.Entries {
+		cn := strings.ToLower(entry.GetAttributeValue("cn"))
+		dns[cn] = append(dns[cn], entry.DN)
+	}
+	return dns, nil
 }
 
 func DNExists(ctx context.Context, dn string) (bool, error) {
 	slog.Debug("Checking if DN exists", "dn", dn)
-	l := ctx.Value(keys.LDAPConnKey).(*ldap.Conn)
-	if l == nil {
-		return false, fmt.Errorf("LDAP connection not found in context")
+	l, err := Conn(ctx)
+	if err != nil {
+		return false, err
 	}
 
 	searchRequest := ldap.NewSearchRequest(
@@ -398,13 +651,146 @@ func DNExists(ctx context.Context, dn string) (bool, error) {
 	return len(sr.Entries) > 0, nil
 }
 
+// MemberEntry is the directory entry behind a member given by DN.
+type MemberEntry struct {
+	DN string
+	// Username is the sAMAccountName, if the entry has one.
+	Username string
+	// IsUser is whether the entry is a user account rather than a computer,
+	// service account, or other object.
+	IsUser bool
+	// Stale is whether dn no longer resolves to an entry, e.g. because the
+	// object was renamed or deleted but its old DN is still in a member list.
+	Stale bool
+}
+
+// GetMemberEntry looks up the entry at dn, for callers that add or remove
+// members by DN instead of by username. It returns ErrNotFound if there is
+// no such entry. Computers and managed service accounts also have the user
+// object class, so they are told apart by the computer class.
+func GetMemberEntry(ctx context.Context, dn string) (MemberEntry, error) {
+	if _, err := ldap.ParseDN(dn); err != nil {
+		return MemberEntry{}, fmt.Errorf("invalid DN %s: %w", dn, err)
+	}
+	exists, err := DNExists(ctx, dn)
+	if err != nil {
+		return MemberEntry{}, fmt.Errorf("failed to check if DN exists: %w", err)
+	}
+	if !exists {
+		return MemberEntry{}, fmt.Errorf("%s %w", dn, ErrNotFound)
+	}
+	l, err := Conn(ctx)
+	if err != nil {
+		return MemberEntry{}, err
+	}
+
+	searchRequest := ldap.NewSearchRequest(
+		dn,
+		ldap.ScopeBaseObject,
+		ldap.NeverDerefAliases,
+		0, 0, false,
+		"(objectClass=*)",
+		[]string{"objectClass", "sAMAccountName"},
+		nil,
+	)
+	sr, err := l.Search(searchRequest)
+	if err != nil {
+		return MemberEntry{}, fmt.Errorf("failed to search LDAP: %w", err)
+	}
+	if len(sr.Entries) == 0 {
+		return MemberEntry{}, fmt.Errorf("%s %w", dn, ErrNotFound)
+	}
+	entry := sr.Entries[0]
+	hasClass := func(class string) bool {
+		return slices.ContainsFunc(entry.GetAttributeValues("objectClass"), func(c string) bool { return strings.EqualFold(c, class) })
+	}
+	m := MemberEntry{
+		DN:       entry.DN,
+		Username: entry.GetAttributeValue("sAMAccountName"),
+		IsUser:   hasClass("user") && !hasClass("computer"),
+	}
+	slog.Debug("Member entry", "dn", m.DN, "username", m.Username, "isUser", m.IsUser)
+	return m, nil
+}
+
+// GetRemovableMemberEntry is GetMemberEntry for removals. A dn that no longer
+// resolves is returned as given, marked Stale, so the literal value can still
+// be removed from a group's member list.
+func GetRemovableMemberEntry(ctx context.Context, dn string) (MemberEntry, error) {
+	m, err := GetMemberEntry(ctx, dn)
+	if errors.Is(err, ErrNotFound) {
+		slog.Debug("Member DN does not resolve, removing it as given", "dn", dn)
+		return MemberEntry{DN: dn, Stale: true}, nil
+	}
+	return m, err
+}
+
+// CheckOU returns nil if dn is an organizational unit. Otherwise it returns
+// ErrNotFound, or ErrNotOU naming the object's most specific class.
+func CheckOU(ctx context.Context, dn string) error {
+	l, err := Conn(ctx)
+	if err != nil {
+		return err
+	}
+	return checkObjectClass(l, dn, "organizationalUnit", ErrNotOU)
+}
+
+// CheckGroup returns nil if dn is a group. Otherwise it returns ErrNotFound,
+// or ErrNotGroup naming the object's most specific class.
+func CheckGroup(ctx context.Context, dn string) error {
+	l, err := Conn(ctx)
+	if err != nil {
+		return err
+	}
+	return checkObjectClass(l, dn, "group", ErrNotGroup)
+}
+
+// checkObjectClass returns nil if the object at dn has the given objectClass.
+// Otherwise it returns ErrNotFound, or notErr naming the object's most
+// specific class. It is one base-scope search.
+func checkObjectClass(l *TimedConn, dn string, want string, notErr error) error {
+	if _, err := ldap.ParseDN(dn); err != nil {
+		return fmt.Errorf("invalid DN %s: %w", dn, err)
+	}
+
+	searchRequest := ldap.NewSearchRequest(
+		dn,
+		ldap.ScopeBaseObject,
+		ldap.NeverDerefAliases,
+		0, 0, false,
+		"(objectClass=*)",
+		[]string{"objectClass"},
+		nil,
+	)
+	sr, err := l.Search(searchRequest)
+	if err != nil {
+		if ldapErr, ok := err.(*ldap.Error); ok && ldapErr.ResultCode == ldap.LDAPResultNoSuchObject {
+			return fmt.Errorf("%s %w", dn, ErrNotFound)
+		}
+		return fmt.Errorf("failed to search LDAP: %w", err)
+	}
+	if len(sr.Entries) == 0 {
+		return fmt.Errorf("%s %w", dn, ErrNotFound)
+	}
+	classes := sr.Entries[0].GetAttributeValues("objectClass")
+	if slices.ContainsFunc(classes, func(c string) bool { return strings.EqualFold(c, want) }) {
+		return nil
+	}
+	// AD lists objectClass from top down to the most specific class
+	class := "object"
+	if len(classes) > 0 {
+		class = classes[len(classes)-1]
+	}
+	return fmt.Errorf("%s is a %s: %w", dn, class, notErr)
+}
+
 // GetGroupNamesInOU retrieves the names of all groups in a given organizational unit (OU).
 func GetGroupNamesInOU(ctx context.Context, ouDN string, recursive bool) ([]string, error) {
 	var scope int
 
-	l := ctx.Value(keys.LDAPConnKey).(*ldap.Conn)
-	if l == nil {
-		return nil, fmt.Errorf("LDAP connection not found in context")
+	l, err := Conn(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	if recursive {
@@ -423,7 +809,7 @@ func GetGroupNamesInOU(ctx context.Context, ouDN string, recursive bool) ([]stri
 		nil,
 	)
 
-	sr, err := l.Search(searchRequest)
+	sr, err := l.SearchWithPaging(searchRequest, pageSize(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("failed to search LDAP: %w", err)
 	}
@@ -436,11 +822,117 @@ func GetGroupNamesInOU(ctx context.Context, ouDN string, recursive bool) ([]stri
 	return groupNames, nil
 }
 
+// GetGroupsWhenChangedInOU retrieves the whenChanged time of every group in
+// an organizational unit (OU) and its children, keyed by cn.
+func GetGroupsWhenChangedInOU(ctx context.Context, ouDN string) (map[string]time.Time, error) {
+	l, err := Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	searchRequest := ldap.NewSearchRequest(
+		ouDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0, 0, false,
+		"(objectClass=group)",
+		[]string{"cn", "whenChanged"},
+		nil,
+	)
+
+	sr, err := l.SearchWithPaging(searchRequest, pageSize(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search LDAP: %w", err)
+	}
+
+	changed := make(map[string]time.Time, len(sr.Entries))
+	for _, entry := range sr.Entries {
+		t, err := ParseGeneralizedTime(entry.GetAttributeValue("whenChanged"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse whenChanged of %s: %w", entry.DN, err)
+		}
+		changed[entry.GetAttributeValue("cn")] = t
+	}
+	return changed, nil
+}
+
+// GroupSummary is a group's member count and when it last changed.
+type GroupSummary struct {
+	DN          string
+	CN          string
+	Members     int
+	WhenChanged time.Time
+}
+
+// SummarizeGroupsInOU returns every group under ouDN with its member count
+// and whenChanged, from one paged subtree search. whenChanged is an
+// operational attribute, so it is only returned because it is asked for by
+// name. Groups too large for AD to return their members in one response
+// are counted with further ranged requests.
+func SummarizeGroupsInOU(ctx context.Context, ouDN string) ([]GroupSummary, error) {
+	l, err := Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	searchRequest := ldap.NewSearchRequest(
+		ouDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0, 0, false,
+		"(objectClass=group)",
+		[]string{"cn", "member", "whenChanged"},
+		nil,
+	)
+	sr, err := l.SearchWithPaging(searchRequest, pageSize(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search LDAP: %w", err)
+	}
+
+	summaries := make([]GroupSummary, 0, len(sr.Entries))
+	for _, entry := range sr.Entries {
+		s := GroupSummary{DN: entry.DN, CN: entry.GetAttributeValue("cn")}
+		if v := entry.GetAttributeValue("whenChanged"); v != "" {
+			s.WhenChanged, err = ParseGeneralizedTime(v)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse whenChanged of %s: %w", entry.DN, err)
+			}
+		}
+		members, err := entryMemberValues(ctx, l, entry)
+		if err != nil {
+			return nil, err
+		}
+		s.Members = len(members)
+		summaries = append(summaries, s)
+	}
+	slog.Debug("Summarized groups", "ouDN", ouDN, "count", len(summaries))
+	return summaries, nil
+}
+
+// ParseGeneralizedTime parses an LDAP GeneralizedTime value such as AD's
+// "20250101123000.0Z". AD stores these in UTC; the result is always UTC.
+func ParseGeneralizedTime(value string) (time.Time, error) {
+	v := value
+	// Drop the fractional seconds, which AD always writes as ".0"
+	if i := strings.IndexAny(v, ".,"); i >= 0 {
+		j := i + 1
+		for j < len(v) && v[j] >= '0' && v[j] <= '9' {
+			j++
+		}
+		v = v[:i] + v[j:]
+	}
+	t, err := time.Parse("20060102150405Z0700", v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid generalized time %q", value)
+	}
+	return t.UTC(), nil
+}
+
 // GetGroupDNsInOU retrieves the distinguished names (DNs) of all groups in a given organizational unit (OU).
 func GetGroupDNsInOU(ctx context.Context, ouDN string) ([]string, error) {
-	l := ctx.Value(keys.LDAPConnKey).(*ldap.Conn)
-	if l == nil {
-		return nil, fmt.Errorf("LDAP connection not found in context")
+	l, err := Conn(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	searchRequest := ldap.NewSearchRequest(
@@ -453,7 +945,7 @@ func GetGroupDNsInOU(ctx context.Context, ouDN string) ([]string, error) {
 		nil,
 	)
 
-	sr, err := l.Search(searchRequest)
+	sr, err := l.SearchWithPaging(searchRequest, pageSize(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("failed to search LDAP: %w", err)
 	}
@@ -466,11 +958,103 @@ func GetGroupDNsInOU(ctx context.Context, ouDN string) ([]string, error) {
 	return groupDNs, nil
 }
 
+// ClassifyGroupsInOU classifies every group in baseDN and its children
+// against family's naming conventions, including groups the family's own
+// list functions skip. Results are sorted by DN.
+func ClassifyGroupsInOU(ctx context.Context, family managedgroup.Family, baseDN string) ([]managedgroup.Classification, error) {
+	l, err := Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	searchRequest := ldap.NewSearchRequest(
+		baseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0, 0, false,
+		"(objectClass=group)",
+		[]string{"cn"},
+		nil,
+	)
+
+	sr, err := l.SearchWithPaging(searchRequest, pageSize(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search LDAP: %w", err)
+	}
+
+	groups := make([]managedgroup.Classification, 0, len(sr.Entries))
+	for _, entry := range sr.Entries {
+		c := family.Classify(baseDN, entry.DN)
+		if c.Kind == managedgroup.KindNonconforming {
+			slog.Debug("Nonconforming group", "dn", entry.DN, "reason", c.Reason)
+		}
+		groups = append(groups, c)
+	}
+	slices.SortFunc(groups, func(a, b managedgroup.Classification) int {
+		return strings.Compare(strings.ToLower(a.DN), strings.ToLower(b.DN))
+	})
+	return groups, nil
+}
+
+// ResolveGroupComponents finds the main, admins and role groups and the
+// subgroups of the managed group name by searching baseDN for their CNs, so
+// groups outside the usual OU layout are still found. Expected groups that
+// don't exist are returned with an empty DN.
+func ResolveGroupComponents(ctx context.Context, family managedgroup.Family, baseDN string, name string) ([]managedgroup.Component, error) {
+	l, err := Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fullName := strings.ToLower(family.Prefix + name)
+
+	searchRequest := ldap.NewSearchRequest(
+		baseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0, 0, false,
+		fmt.Sprintf("(&(objectClass=group)(|(cn=%s)(cn=%s.*)))", ldap.EscapeFilter(fullName), ldap.EscapeFilter(fullName)),
+		[]string{"cn"},
+		nil,
+	)
+	sr, err := l.SearchWithPaging(searchRequest, pageSize(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search LDAP: %w", err)
+	}
+
+	expected := []managedgroup.Component{{Label: "main", CN: fullName}}
+	if family.AdminsSuffix != "" {
+		expected = append(expected, managedgroup.Component{Label: "admins", CN: fullName + family.AdminsSuffix})
+	}
+	if family.RoleSuffix != "" {
+		expected = append(expected, managedgroup.Component{Label: family.RoleLabel(), CN: fullName + family.RoleSuffix})
+	}
+	var subgroups []managedgroup.Component
+	found := make(map[string]string)
+	for _, entry := range sr.Entries {
+		cn := strings.ToLower(entry.GetAttributeValue("cn"))
+		if other, ok := found[cn]; ok {
+			return nil, fmt.Errorf("multiple groups named %s found: %s; %s", cn, other, entry.DN)
+		}
+		found[cn] = entry.DN
+		if !slices.ContainsFunc(expected, func(c managedgroup.Component) bool { return c.CN == cn }) {
+			subgroup := strings.TrimPrefix(cn, fullName+".")
+			subgroups = append(subgroups, managedgroup.Component{Label: "subgroup " + subgroup, CN: cn, DN: entry.DN})
+		}
+	}
+	for i := range expected {
+		expected[i].DN = found[expected[i].CN]
+	}
+	slices.SortFunc(subgroups, func(a, b managedgroup.Component) int {
+		return strings.Compare(a.CN, b.CN)
+	})
+	return append(expected, subgroups...), nil
+}
+
 // GetOUDNsInOU retrieves the distinguished names (DNs) of all organizational units (OUs) in a given organizational unit (OU).
-func getOUDNsInOU(ctx context.Context, ouDN string) ([]string, error) {
-	l := ctx.Value(keys.LDAPConnKey).(*ldap.Conn)
-	if l == nil {
-		return nil, fmt.Errorf("LDAP connection not found in context")
+func GetOUDNsInOU(ctx context.Context, ouDN string) ([]string, error) {
+	l, err := Conn(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	searchRequest := ldap.NewSearchRequest(
@@ -483,7 +1067,7 @@ func getOUDNsInOU(ctx context.Context, ouDN string) ([]string, error) {
 		nil,
 	)
 
-	sr, err := l.Search(searchRequest)
+	sr, err := l.SearchWithPaging(searchRequest, pageSize(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("failed to search LDAP: %w", err)
 	}
@@ -497,32 +1081,266 @@ func getOUDNsInOU(ctx context.Context, ouDN string) ([]string, error) {
 }
 
 // DeleteOURecursively deletes an organizational unit (OU) and all its contents.
+// It refuses, with ErrNotOU, to delete anything that isn't an OU, so a
+// mis-built DN can't take a group or user with it.
 func DeleteOURecursively(ctx context.Context, dn string) error {
-	l := ctx.Value(keys.LDAPConnKey).(*ldap.Conn)
-	if l == nil {
-		return fmt.Errorf("LDAP connection not found in context")
+	l, err := Conn(ctx)
+	if err != nil {
+		return err
+	}
+	if err := checkObjectClass(l, dn, "organizationalUnit", ErrNotOU); err != nil {
+		return fmt.Errorf("refusing to delete %s: %w", dn, err)
 	}
 
 	ctrl := ldap.NewControlSubtreeDelete()
 	delRequest := ldap.NewDelRequest(dn, []ldap.Control{ctrl})
-	if err := l.Del(delRequest); err != nil {
-		return fmt.Errorf("failed to delete OU %s: %w", dn, err)
+	// A subtree delete of a large OU can run for minutes and the library
+	// can't abandon it. Cancellation closes the connection to stop waiting,
+	// but the server may still be partway through the tree.
+	if err := retryTransient(ctx, dn, func() error { return l.Del(delRequest) }); err != nil {
+		if ctx.Err() != nil {
+			reason := "cancelled"
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				reason = "timed out"
+			}
+			slog.Warn("Subtree delete interrupted; the OU may be partially removed", "dn", dn, "reason", reason)
+			return fmt.Errorf("delete of OU %s %s, it may be partially removed: %w", dn, reason, ctx.Err())
+		}
+		return fmt.Errorf("failed to delete OU %s: %w", dn, accessError(ctx, dn, err))
 	}
 
 	return nil
 }
 
-// DeleteGroup deletes a group from LDAP.
+// DeleteGroup deletes a group from LDAP. It refuses, with ErrNotGroup, to
+// delete anything that isn't a group, so a mis-built DN can't remove an OU
+// or a user.
 func DeleteGroup(ctx context.Context, groupDN string) error {
-	l := ctx.Value(keys.LDAPConnKey).(*ldap.Conn)
-	if l == nil {
-		return fmt.Errorf("LDAP connection not found in context")
+	l, err := Conn(ctx)
+	if err != nil {
+		return err
+	}
+	if err := checkObjectClass(l, groupDN, "group", ErrNotGroup); err != nil {
+		return fmt.Errorf("refusing to delete %s: %w", groupDN, err)
 	}
 
 	delRequest := ldap.NewDelRequest(groupDN, nil)
-	if err := l.Del(delRequest); err != nil {
-		return fmt.Errorf("failed to delete group %s: %w", groupDN, err)
+	if err := retryTransient(ctx, groupDN, func() error { return l.Del(delRequest) }); err != nil {
+		return fmt.Errorf("failed to delete group %s: %w", groupDN, accessError(ctx, groupDN, err))
+	}
+
+	return nil
+}
+
+// RenameOU renames an organizational unit (OU) in place.
+// Everything inside the OU moves with it.
+func RenameOU(ctx context.Context, ouDN string, newName string) error {
+	l, err := Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	modifyDNRequest := ldap.NewModifyDNRequest(ouDN, fmt.Sprintf("OU=%s", ldap.EscapeDN(newName)), true, "")
+	if err := retryTransient(ctx, ouDN, func() error { return l.ModifyDN(modifyDNRequest) }); err != nil {
+		return fmt.Errorf("failed to rename OU %s to %s: %w", ouDN, newName, accessError(ctx, ouDN, err))
+	}
+
+	return nil
+}
+
+// RenameGroup renames a group in place, updating both its cn and sAMAccountName.
+// Group memberships follow the rename, since AD stores them by reference.
+func RenameGroup(ctx context.Context, groupDN string, newName string) error {
+	l, err := Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	modifyDNRequest := ldap.NewModifyDNRequest(groupDN, fmt.Sprintf("CN=%s", ldap.EscapeDN(newName)), true, "")
+	if err := retryTransient(ctx, groupDN, func() error { return l.ModifyDN(modifyDNRequest) }); err != nil {
+		return fmt.Errorf("failed to rename group %s to %s: %w", groupDN, newName, accessError(ctx, groupDN, err))
+	}
+
+	// The DN now points at the new CN, under the same parent.
+	parentDN, err := ParentDN(groupDN)
+	if err != nil {
+		return err
+	}
+	newDN := fmt.Sprintf("CN=%s,%s", ldap.EscapeDN(newName), parentDN)
+
+	// sAMAccountName is not derived from the cn, so it has to be updated separately.
+	modifyRequest := ldap.NewModifyRequest(newDN, nil)
+	modifyRequest.Replace("sAMAccountName", []string{newName})
+	if err := retryTransient(ctx, newDN, func() error { return l.Modify(modifyRequest) }); err != nil {
+		return fmt.Errorf("failed to update sAMAccountName of group %s: %w", newDN, accessError(ctx, newDN, err))
+	}
+
+	return nil
+}
+
+// memberOfBatchSize is how many user DNs are OR-ed together in a single
+// memberOf search, to keep filters a reasonable size.
+const memberOfBatchSize = 50
+
+// pageSize is the page size for searches that may return many entries:
+// ldap_page_size, or 500 if there's no config to read it from.
+func pageSize(ctx context.Context) uint32 {
+	if cfg, ok := ctx.Value(keys.ConfigKey).(*config.Config); ok && cfg != nil && cfg.LDAPPageSize > 0 {
+		return uint32(cfg.LDAPPageSize)
+	}
+	return config.DefaultLDAPPageSize
+}
+
+// GetGroupsForUsers retrieves the memberOf group DNs for many users at once,
+// keyed by sAMAccountName. Users are looked up in batches rather than one by one.
+func GetGroupsForUsers(ctx context.Context, userDNs []string) (map[string][]string, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	l, err := Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string][]string)
+	for start := 0; start < len(userDNs); start += memberOfBatchSize {
+		end := min(start+memberOfBatchSize, len(userDNs))
+		var filter strings.Builder
+		filter.WriteString("(|")
+		for _, userDN := range userDNs[start:end] {
+			filter.WriteString(fmt.Sprintf("(distinguishedName=%s)", ldap.EscapeFilter(userDN)))
+		}
+		filter.WriteString(")")
+
+		searchRequest := ldap.NewSearchRequest(
+			cfg.LDAPUsersBaseDN,
+			ldap.ScopeWholeSubtree,
+			ldap.NeverDerefAliases,
+			0, 0, false,
+			filter.String(),
+			[]string{"sAMAccountName", "memberOf"},
+			nil,
+		)
+		slog.Debug("Searching LDAP for memberOf of users", "count", end-start)
+
+		sr, err := l.SearchWithPaging(searchRequest, pageSize(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("failed to search LDAP: %w", err)
+		}
+		for _, entry := range sr.Entries {
+			groups[entry.GetAttributeValue("sAMAccountName")] = entry.GetAttributeValues("memberOf")
+		}
+	}
+
+	return groups, nil
+}
+
+// GetUserAttributes fetches the given attributes for many users at once,
+// keyed by lowercased DN. Users are looked up in batches to keep the search
+// filter well under AD's limits. Attributes a user doesn't have are left out
+// of their map; users that aren't found are left out entirely.
+func GetUserAttributes(ctx context.Context, userDNs []string, attributes []string) (map[string]map[string]string, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	l, err := Conn(ctx)
+	if err != nil {
+		return nil, err
 	}
 
+	users := make(map[string]map[string]string)
+	for start := 0; start < len(userDNs); start += memberOfBatchSize {
+		end := min(start+memberOfBatchSize, len(userDNs))
+		var filter strings.Builder
+		filter.WriteString("(|")
+		for _, userDN := range userDNs[start:end] {
+			filter.WriteString(fmt.Sprintf("(distinguishedName=%s)", ldap.EscapeFilter(userDN)))
+		}
+		filter.WriteString(")")
+
+		searchRequest := ldap.NewSearchRequest(
+			cfg.LDAPUsersBaseDN,
+			ldap.ScopeWholeSubtree,
+			ldap.NeverDerefAliases,
+			0, 0, false,
+			filter.String(),
+			attributes,
+			nil,
+		)
+		slog.Debug("Searching LDAP for user attributes", "count", end-start, "attributes", attributes)
+
+		sr, err := l.SearchWithPaging(searchRequest, pageSize(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("failed to search LDAP: %w", err)
+		}
+		for _, entry := range sr.Entries {
+			values := make(map[string]string)
+			for _, attr := range entry.Attributes {
+				if len(attr.Values) > 0 {
+					values[strings.ToLower(attr.Name)] = attr.Values[0]
+				}
+			}
+			users[strings.ToLower(entry.DN)] = values
+		}
+	}
+
+	return users, nil
+}
+
+// GetManagedBy returns the managedBy DN of an object, or an empty string if it is unset.
+func GetManagedBy(ctx context.Context, dn string) (string, error) {
+	l, err := Conn(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	searchRequest := ldap.NewSearchRequest(
+		dn,
+		ldap.ScopeBaseObject,
+		ldap.NeverDerefAliases,
+		0, 0, false,
+		"(objectClass=*)",
+		[]string{"managedBy"},
+		nil,
+	)
+	sr, err := l.Search(searchRequest)
+	if err != nil {
+		return "", fmt.Errorf("failed to search LDAP: %w", err)
+	}
+	if len(sr.Entries) == 0 {
+		return "", fmt.Errorf("object %q %w", dn, ErrNotFound)
+	}
+	return sr.Entries[0].GetAttributeValue("managedBy"), nil
+}
+
+// SetManagedBy sets the managedBy attribute of an object. An empty managerDN clears it.
+func SetManagedBy(ctx context.Context, dn string, managerDN string) error {
+	l, err := Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	var values []string
+	if managerDN != "" {
+		values = []string{managerDN}
+	}
+	modifyRequest := ldap.NewModifyRequest(dn, nil)
+	modifyRequest.Replace("managedBy", values)
+	if err := retryTransient(ctx, dn, func() error { return l.Modify(modifyRequest) }); err != nil {
+		return fmt.Errorf("failed to set managedBy on %s: %w", dn, accessError(ctx, dn, err))
+	}
 	return nil
 }
+
+// ClassifyGroupName returns the namespace (pirg, cephfs, cephs3, or software)
+// of a group by its name, and false if the group is not one we manage.
+func ClassifyGroupName(groupName string) (string, bool) {
+	for _, family := range managedgroup.Families {
+		if strings.HasPrefix(strings.ToLower(groupName), family.Prefix) {
+			return family.Name, true
+		}
+	}
+	return "", false
+}
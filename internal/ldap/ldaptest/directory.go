@@ -0,0 +1,735 @@
+// Package ldaptest is an in-memory directory for tests. It answers the
+// searches and changes commands make the way AD does, closely enough to
+// run commands against a seeded dataset without a domain controller.
+package ldaptest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// Matching rules AD supports in extensible filters.
+const (
+	ruleBitAnd  = "1.2.840.113556.1.4.803"
+	ruleBitOr   = "1.2.840.113556.1.4.804"
+	ruleInChain = "1.2.840.113556.1.4.1941"
+)
+
+// linkedAttributes hold DNs that AD keeps pointing at their objects: they
+// follow renames and lose values for deleted objects.
+var linkedAttributes = []string{"member", "managedBy"}
+
+type entry struct {
+	dn    *ldap.DN
+	dnStr string
+	attrs []*ldap.EntryAttribute
+}
+
+func (e *entry) attr(name string) *ldap.EntryAttribute {
+	for _, a := range e.attrs {
+		if strings.EqualFold(a.Name, name) {
+			return a
+		}
+	}
+	return nil
+}
+
+func (e *entry) values(name string) []string {
+	if a := e.attr(name); a != nil {
+		return a.Values
+	}
+	return nil
+}
+
+func (e *entry) clone() *entry {
+	c := &entry{dn: e.dn, dnStr: e.dnStr}
+	for _, a := range e.attrs {
+		c.attrs = append(c.attrs, ldap.NewEntryAttribute(a.Name, slices.Clone(a.Values)))
+	}
+	return c
+}
+
+// Directory is an in-memory directory. It implements ldap.Client from the
+// internal ldap package, and is safe to use from several goroutines.
+type Directory struct {
+	mu      sync.Mutex
+	entries []*entry
+	// MaxValRange is how many values of member one search returns before
+	// the rest has to be read range by range, like AD's MaxValRange. Zero
+	// returns them all at once.
+	MaxValRange int
+}
+
+// New returns an empty directory.
+func New() *Directory {
+	return &Directory{}
+}
+
+// Load returns a directory holding the entries of the LDIF file at path.
+func Load(path string) (*Directory, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	d := New()
+	if err := d.ReadLDIF(f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return d, nil
+}
+
+// ReadLDIF adds the entries in r, written as LDIF "attribute: value" lines
+// starting with a dn line and separated by blank lines. Lines starting
+// with # are comments. Parents must come before their children.
+func (d *Directory) ReadLDIF(r io.Reader) error {
+	var req *ldap.AddRequest
+	add := func() error {
+		if req == nil {
+			return nil
+		}
+		err := d.Add(req)
+		req = nil
+		return err
+	}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(text, "#") {
+			continue
+		}
+		if text == "" {
+			if err := add(); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			continue
+		}
+		name, value, ok := strings.Cut(text, ":")
+		if !ok {
+			return fmt.Errorf("line %d: expected \"attribute: value\"", line)
+		}
+		value = strings.TrimSpace(value)
+		if req == nil {
+			if !strings.EqualFold(name, "dn") {
+				return fmt.Errorf("line %d: entry doesn't start with a dn", line)
+			}
+			req = ldap.NewAddRequest(value, nil)
+			continue
+		}
+		i := slices.IndexFunc(req.Attributes, func(a ldap.Attribute) bool { return strings.EqualFold(a.Type, name) })
+		if i < 0 {
+			req.Attribute(name, []string{value})
+		} else {
+			req.Attributes[i].Vals = append(req.Attributes[i].Vals, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if err := add(); err != nil {
+		return fmt.Errorf("line %d: %w", line, err)
+	}
+	return nil
+}
+
+// WriteLDIF writes every entry to w in the order they were added, in the
+// format ReadLDIF reads.
+func (d *Directory) WriteLDIF(w io.Writer) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, e := range d.entries {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "dn: %s\n", e.dnStr); err != nil {
+			return err
+		}
+		for _, a := range e.attrs {
+			for _, v := range a.Values {
+				if _, err := fmt.Fprintf(w, "%s: %s\n", a.Name, v); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Values returns the values of the named attribute of the entry at dn, or
+// nil if there is no such entry or attribute.
+func (d *Directory) Values(dn string, attribute string) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil {
+		return nil
+	}
+	e := d.find(parsed)
+	if e == nil {
+		return nil
+	}
+	return slices.Clone(d.attributeValues(e, attribute))
+}
+
+// Exists reports whether there is an entry at dn.
+func (d *Directory) Exists(dn string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	parsed, err := ldap.ParseDN(dn)
+	return err == nil && d.find(parsed) != nil
+}
+
+func (d *Directory) find(dn *ldap.DN) *entry {
+	for _, e := range d.entries {
+		if e.dn.EqualFold(dn) {
+			return e
+		}
+	}
+	return nil
+}
+
+func parseDN(dn string) (*ldap.DN, error) {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil {
+		return nil, ldap.NewError(ldap.LDAPResultInvalidDNSyntax, err)
+	}
+	return parsed, nil
+}
+
+func parent(dn *ldap.DN) *ldap.DN {
+	if len(dn.RDNs) == 0 {
+		return dn
+	}
+	return &ldap.DN{RDNs: dn.RDNs[1:]}
+}
+
+func noSuchObject(dn string) error {
+	return ldap.NewError(ldap.LDAPResultNoSuchObject, fmt.Errorf("no such object: %s", dn))
+}
+
+// sameDN reports whether a and b name the same object, ignoring case.
+func sameDN(a string, b string) bool {
+	pa, err := ldap.ParseDN(a)
+	if err != nil {
+		return false
+	}
+	pb, err := ldap.ParseDN(b)
+	return err == nil && pa.EqualFold(pb)
+}
+
+// attributeValues returns the values of name on e, including the
+// attributes AD computes: distinguishedName and memberOf.
+func (d *Directory) attributeValues(e *entry, name string) []string {
+	switch strings.ToLower(name) {
+	case "distinguishedname":
+		return []string{e.dnStr}
+	case "memberof":
+		var groups []string
+		for _, g := range d.entries {
+			if slices.ContainsFunc(g.values("member"), func(m string) bool { return sameDN(m, e.dnStr) }) {
+				groups = append(groups, g.dnStr)
+			}
+		}
+		return groups
+	}
+	return e.values(name)
+}
+
+// Search returns the entries under the request's base matching its filter.
+// A size limit truncates the result and returns it with a sizeLimitExceeded
+// error, like a server does.
+func (d *Directory) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	base, err := parseDN(req.BaseDN)
+	if err != nil {
+		return nil, err
+	}
+	if d.find(base) == nil {
+		return nil, noSuchObject(req.BaseDN)
+	}
+	filter, err := ldap.CompileFilter(req.Filter)
+	if err != nil {
+		return nil, err
+	}
+	result := &ldap.SearchResult{}
+	for _, e := range d.entries {
+		var inScope bool
+		switch req.Scope {
+		case ldap.ScopeBaseObject:
+			inScope = e.dn.EqualFold(base)
+		case ldap.ScopeSingleLevel:
+			inScope = len(e.dn.RDNs) > 0 && parent(e.dn).EqualFold(base)
+		default:
+			inScope = e.dn.EqualFold(base) || base.AncestorOfFold(e.dn)
+		}
+		if !inScope {
+			continue
+		}
+		ok, err := d.matches(e, filter)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if req.SizeLimit > 0 && len(result.Entries) == req.SizeLimit {
+			return result, ldap.NewError(ldap.LDAPResultSizeLimitExceeded, errors.New("size limit exceeded"))
+		}
+		result.Entries = append(result.Entries, d.selectAttributes(e, req.Attributes))
+	}
+	return result, nil
+}
+
+// SearchWithPaging is Search: the whole result is one page.
+func (d *Directory) SearchWithPaging(req *ldap.SearchRequest, pagingSize uint32) (*ldap.SearchResult, error) {
+	return d.Search(req)
+}
+
+// selectAttributes copies the requested attributes of e into a result
+// entry. No attributes, or "*", selects every stored one. A member
+// attribute with more than MaxValRange values, or one requested as
+// member;range=low-high, is returned as a range like AD does.
+func (d *Directory) selectAttributes(e *entry, names []string) *ldap.Entry {
+	result := &ldap.Entry{DN: e.dnStr}
+	if len(names) == 0 || slices.Contains(names, "*") {
+		for _, a := range e.attrs {
+			result.Attributes = append(result.Attributes, d.valueRange(a.Name, a.Values, 0, -1))
+		}
+		if groups := d.attributeValues(e, "memberOf"); len(groups) > 0 {
+			result.Attributes = append(result.Attributes, ldap.NewEntryAttribute("memberOf", groups))
+		}
+		names = slices.DeleteFunc(slices.Clone(names), func(n string) bool { return n == "*" })
+	}
+	for _, name := range names {
+		selected := slices.ContainsFunc(result.Attributes, func(a *ldap.EntryAttribute) bool { return strings.EqualFold(a.Name, name) })
+		if name == "1.1" || selected {
+			continue
+		}
+		base, bounds, ranged := strings.Cut(name, ";range=")
+		values := d.attributeValues(e, base)
+		if len(values) == 0 {
+			continue
+		}
+		if !ranged {
+			result.Attributes = append(result.Attributes, d.valueRange(name, values, 0, -1))
+			continue
+		}
+		lowText, highText, _ := strings.Cut(bounds, "-")
+		low, _ := strconv.Atoi(lowText)
+		high := -1
+		if highText != "*" {
+			high, _ = strconv.Atoi(highText)
+		}
+		result.Attributes = append(result.Attributes, d.valueRange(base, values, low, high))
+	}
+	return result
+}
+
+// valueRange returns values low through high, or through the end for a
+// negative high, as the attribute name. It's named name;range=low-high
+// when values remain past high or past MaxValRange, and name;range=low-*
+// when a range was asked for and it reaches the end.
+func (d *Directory) valueRange(name string, values []string, low int, high int) *ldap.EntryAttribute {
+	asked := low > 0 || high >= 0
+	if !asked && (d.MaxValRange <= 0 || len(values) <= d.MaxValRange) {
+		return ldap.NewEntryAttribute(name, slices.Clone(values))
+	}
+	last := len(values) - 1
+	if high >= 0 && high < last {
+		last = high
+	}
+	if d.MaxValRange > 0 && last-low+1 > d.MaxValRange {
+		last = low + d.MaxValRange - 1
+	}
+	low = min(low, len(values))
+	if last < len(values)-1 {
+		return ldap.NewEntryAttribute(fmt.Sprintf("%s;range=%d-%d", name, low, last), slices.Clone(values[low:last+1]))
+	}
+	return ldap.NewEntryAttribute(fmt.Sprintf("%s;range=%d-*", name, low), slices.Clone(values[low:]))
+}
+
+// matches reports whether e matches the compiled filter.
+func (d *Directory) matches(e *entry, f *ber.Packet) (bool, error) {
+	switch f.Tag {
+	case ldap.FilterAnd:
+		for _, child := range f.Children {
+			ok, err := d.matches(e, child)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	case ldap.FilterOr:
+		for _, child := range f.Children {
+			ok, err := d.matches(e, child)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	case ldap.FilterNot:
+		ok, err := d.matches(e, f.Children[0])
+		return !ok, err
+	case ldap.FilterPresent:
+		return len(d.attributeValues(e, decode(f))) > 0, nil
+	case ldap.FilterEqualityMatch, ldap.FilterApproxMatch:
+		want := decode(f.Children[1])
+		return slices.ContainsFunc(d.attributeValues(e, decode(f.Children[0])), func(v string) bool { return valuesEqual(v, want) }), nil
+	case ldap.FilterGreaterOrEqual, ldap.FilterLessOrEqual:
+		want := decode(f.Children[1])
+		return slices.ContainsFunc(d.attributeValues(e, decode(f.Children[0])), func(v string) bool {
+			c := compareValues(v, want)
+			if f.Tag == ldap.FilterGreaterOrEqual {
+				return c >= 0
+			}
+			return c <= 0
+		}), nil
+	case ldap.FilterSubstrings:
+		return slices.ContainsFunc(d.attributeValues(e, decode(f.Children[0])), func(v string) bool {
+			return matchesSubstrings(strings.ToLower(v), f.Children[1].Children)
+		}), nil
+	case ldap.FilterExtensibleMatch:
+		return d.matchesExtensible(e, f)
+	}
+	return false, fmt.Errorf("unsupported filter type %d", f.Tag)
+}
+
+func (d *Directory) matchesExtensible(e *entry, f *ber.Packet) (bool, error) {
+	var rule, name, want string
+	for _, child := range f.Children {
+		switch child.Tag {
+		case ldap.MatchingRuleAssertionMatchingRule:
+			rule = decode(child)
+		case ldap.MatchingRuleAssertionType:
+			name = decode(child)
+		case ldap.MatchingRuleAssertionMatchValue:
+			want = decode(child)
+		}
+	}
+	switch rule {
+	case ruleBitAnd, ruleBitOr:
+		mask, err := strconv.ParseInt(want, 10, 64)
+		if err != nil {
+			return false, ldap.NewError(ldap.LDAPResultInappropriateMatching, err)
+		}
+		return slices.ContainsFunc(d.attributeValues(e, name), func(v string) bool {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return false
+			}
+			if rule == ruleBitAnd {
+				return n&mask == mask
+			}
+			return n&mask != 0
+		}), nil
+	case ruleInChain:
+		return d.inChain(e, name, want, map[string]bool{}), nil
+	}
+	return false, ldap.NewError(ldap.LDAPResultInappropriateMatching, fmt.Errorf("unsupported matching rule %q", rule))
+}
+
+// inChain reports whether following the DN-valued attribute name from e
+// reaches target, directly or through other entries.
+func (d *Directory) inChain(e *entry, name string, target string, seen map[string]bool) bool {
+	key := strings.ToLower(e.dnStr)
+	if seen[key] {
+		return false
+	}
+	seen[key] = true
+	for _, v := range d.attributeValues(e, name) {
+		if sameDN(v, target) {
+			return true
+		}
+		parsed, err := ldap.ParseDN(v)
+		if err != nil {
+			continue
+		}
+		if next := d.find(parsed); next != nil && d.inChain(next, name, target, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+func decode(p *ber.Packet) string {
+	return ber.DecodeString(p.Data.Bytes())
+}
+
+// valuesEqual compares attribute values the way AD's default matching
+// does: ignoring case, and as DNs when both are DNs.
+func valuesEqual(a string, b string) bool {
+	return strings.EqualFold(a, b) || (strings.Contains(a, "=") && sameDN(a, b))
+}
+
+// compareValues orders two values as integers if both are, or else as
+// case-insensitive strings.
+func compareValues(a string, b string) int {
+	na, errA := strconv.ParseInt(a, 10, 64)
+	nb, errB := strconv.ParseInt(b, 10, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case na < nb:
+			return -1
+		case na > nb:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+func matchesSubstrings(v string, parts []*ber.Packet) bool {
+	for _, part := range parts {
+		s := strings.ToLower(decode(part))
+		switch part.Tag {
+		case ldap.FilterSubstringsInitial:
+			if !strings.HasPrefix(v, s) {
+				return false
+			}
+			v = v[len(s):]
+		case ldap.FilterSubstringsAny:
+			i := strings.Index(v, s)
+			if i < 0 {
+				return false
+			}
+			v = v[i+len(s):]
+		case ldap.FilterSubstringsFinal:
+			if !strings.HasSuffix(v, s) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Add adds an entry. Its parent must exist and it must not.
+func (d *Directory) Add(req *ldap.AddRequest) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dn, err := parseDN(req.DN)
+	if err != nil {
+		return err
+	}
+	if d.find(dn) != nil {
+		return ldap.NewError(ldap.LDAPResultEntryAlreadyExists, fmt.Errorf("entry already exists: %s", req.DN))
+	}
+	if len(d.entries) > 0 && d.find(parent(dn)) == nil {
+		return noSuchObject(parent(dn).String())
+	}
+	e := &entry{dn: dn, dnStr: req.DN}
+	for _, a := range req.Attributes {
+		e.attrs = append(e.attrs, ldap.NewEntryAttribute(a.Type, slices.Clone(a.Vals)))
+	}
+	d.entries = append(d.entries, e)
+	return nil
+}
+
+// Modify applies every change of the request, or none if one fails.
+func (d *Directory) Modify(req *ldap.ModifyRequest) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dn, err := parseDN(req.DN)
+	if err != nil {
+		return err
+	}
+	original := d.find(dn)
+	if original == nil {
+		return noSuchObject(req.DN)
+	}
+	e := original.clone()
+	for _, change := range req.Changes {
+		name := change.Modification.Type
+		values := change.Modification.Vals
+		a := e.attr(name)
+		switch change.Operation {
+		case ldap.AddAttribute:
+			if a == nil {
+				a = ldap.NewEntryAttribute(name, nil)
+				e.attrs = append(e.attrs, a)
+			}
+			for _, v := range values {
+				if slices.ContainsFunc(a.Values, func(have string) bool { return valuesEqual(have, v) }) {
+					return ldap.NewError(ldap.LDAPResultAttributeOrValueExists, fmt.Errorf("%s already has value %s", name, v))
+				}
+				a.Values = append(a.Values, v)
+			}
+		case ldap.DeleteAttribute:
+			if a == nil {
+				return ldap.NewError(ldap.LDAPResultNoSuchAttribute, fmt.Errorf("no attribute %s", name))
+			}
+			if len(values) == 0 {
+				a.Values = nil
+			}
+			for _, v := range values {
+				i := slices.IndexFunc(a.Values, func(have string) bool { return valuesEqual(have, v) })
+				if i < 0 {
+					return ldap.NewError(ldap.LDAPResultNoSuchAttribute, fmt.Errorf("%s has no value %s", name, v))
+				}
+				a.Values = slices.Delete(a.Values, i, i+1)
+			}
+		case ldap.ReplaceAttribute:
+			if a == nil {
+				a = ldap.NewEntryAttribute(name, nil)
+				e.attrs = append(e.attrs, a)
+			}
+			a.Values = slices.Clone(values)
+		default:
+			return ldap.NewError(ldap.LDAPResultProtocolError, fmt.Errorf("unknown modify operation %d", change.Operation))
+		}
+		e.attrs = slices.DeleteFunc(e.attrs, func(a *ldap.EntryAttribute) bool { return len(a.Values) == 0 })
+	}
+	original.attrs = e.attrs
+	return nil
+}
+
+// ModifyDN renames or moves an entry and everything under it, updating
+// the linked attributes that point at them. Like AD, it sets the entry's
+// naming attribute, such as cn, to the new RDN value.
+func (d *Directory) ModifyDN(req *ldap.ModifyDNRequest) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dn, err := parseDN(req.DN)
+	if err != nil {
+		return err
+	}
+	e := d.find(dn)
+	if e == nil {
+		return noSuchObject(req.DN)
+	}
+	superior := parent(dn).String()
+	if req.NewSuperior != "" {
+		superior = req.NewSuperior
+	}
+	newDNStr := req.NewRDN
+	if superior != "" {
+		newDNStr += "," + superior
+	}
+	newDN, err := parseDN(newDNStr)
+	if err != nil {
+		return err
+	}
+	if d.find(newDN) != nil {
+		return ldap.NewError(ldap.LDAPResultEntryAlreadyExists, fmt.Errorf("entry already exists: %s", newDNStr))
+	}
+	if d.find(parent(newDN)) == nil {
+		return noSuchObject(superior)
+	}
+	oldRDN, newRDN := dn.RDNs[0].Attributes[0], newDN.RDNs[0].Attributes[0]
+	if req.DeleteOldRDN {
+		if a := e.attr(oldRDN.Type); a != nil {
+			a.Values = slices.DeleteFunc(a.Values, func(v string) bool { return strings.EqualFold(v, oldRDN.Value) })
+		}
+	}
+	if a := e.attr(newRDN.Type); a == nil {
+		e.attrs = append(e.attrs, ldap.NewEntryAttribute(newRDN.Type, []string{newRDN.Value}))
+	} else if !slices.Contains(a.Values, newRDN.Value) {
+		a.Values = append(a.Values, newRDN.Value)
+	}
+	e.attrs = slices.DeleteFunc(e.attrs, func(a *ldap.EntryAttribute) bool { return len(a.Values) == 0 })
+	renamed := map[string]string{}
+	for _, moved := range d.entries {
+		if !moved.dn.EqualFold(dn) && !dn.AncestorOfFold(moved.dn) {
+			continue
+		}
+		var rdns []string
+		for _, rdn := range moved.dn.RDNs[:len(moved.dn.RDNs)-len(dn.RDNs)] {
+			rdns = append(rdns, rdn.String())
+		}
+		rdns = append(rdns, newDNStr)
+		old := moved.dnStr
+		moved.dnStr = strings.Join(rdns, ",")
+		moved.dn, _ = ldap.ParseDN(moved.dnStr)
+		renamed[old] = moved.dnStr
+	}
+	d.relink(func(v string) (string, bool) {
+		for old, renamedTo := range renamed {
+			if sameDN(v, old) {
+				return renamedTo, true
+			}
+		}
+		return v, true
+	})
+	return nil
+}
+
+// Del deletes an entry, which must have no children unless the request
+// carries the subtree delete control, and removes it from linked
+// attributes.
+func (d *Directory) Del(req *ldap.DelRequest) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dn, err := parseDN(req.DN)
+	if err != nil {
+		return err
+	}
+	if d.find(dn) == nil {
+		return noSuchObject(req.DN)
+	}
+	subtree := ldap.FindControl(req.Controls, ldap.ControlTypeSubtreeDelete) != nil
+	var deleted []string
+	var kept []*entry
+	for _, e := range d.entries {
+		switch {
+		case e.dn.EqualFold(dn):
+			deleted = append(deleted, e.dnStr)
+		case dn.AncestorOfFold(e.dn):
+			if !subtree {
+				return ldap.NewError(ldap.LDAPResultNotAllowedOnNonLeaf, fmt.Errorf("%s has children", req.DN))
+			}
+			deleted = append(deleted, e.dnStr)
+		default:
+			kept = append(kept, e)
+		}
+	}
+	d.entries = kept
+	d.relink(func(v string) (string, bool) {
+		return v, !slices.ContainsFunc(deleted, func(dn string) bool { return sameDN(v, dn) })
+	})
+	return nil
+}
+
+// relink rewrites the values of every linked attribute with update, which
+// returns the new value and whether to keep it.
+func (d *Directory) relink(update func(v string) (string, bool)) {
+	for _, e := range d.entries {
+		for _, name := range linkedAttributes {
+			a := e.attr(name)
+			if a == nil {
+				continue
+			}
+			var values []string
+			for _, v := range a.Values {
+				if v, keep := update(v); keep {
+					values = append(values, v)
+				}
+			}
+			a.Values = values
+		}
+		e.attrs = slices.DeleteFunc(e.attrs, func(a *ldap.EntryAttribute) bool { return len(a.Values) == 0 })
+	}
+}
+
+// Close does nothing, so one directory can serve several commands in turn.
+func (d *Directory) Close() error {
+	return nil
+}
+
+// IsClosing is always false; see Close.
+func (d *Directory) IsClosing() bool {
+	return false
+}
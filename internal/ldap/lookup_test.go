@@ -0,0 +1,83 @@
+package ldap
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/uoracs/directory-manager/internal/ldap/ldaptest"
+)
+
+const lookupTestLDIF = `dn: DC=test
+objectClass: domain
+
+dn: OU=PIRGS,DC=test
+objectClass: organizationalUnit
+
+dn: CN=chemlab,OU=PIRGS,DC=test
+objectClass: group
+cn: chemlab
+
+dn: CN=twin,OU=PIRGS,DC=test
+objectClass: group
+cn: twin
+
+dn: OU=Old,OU=PIRGS,DC=test
+objectClass: organizationalUnit
+
+dn: CN=twin,OU=Old,OU=PIRGS,DC=test
+objectClass: group
+cn: twin
+
+dn: OU=Archive,DC=test
+objectClass: organizationalUnit
+
+dn: CN=chemlab,OU=Archive,DC=test
+objectClass: group
+cn: chemlab
+
+dn: CN=person,OU=PIRGS,DC=test
+objectClass: user
+cn: person
+`
+
+func TestGetGroupDN(t *testing.T) {
+	dir := ldaptest.New()
+	if err := dir.ReadLDIF(strings.NewReader(lookupTestLDIF)); err != nil {
+		t.Fatal(err)
+	}
+	ctx := WithClient(context.Background(), dir)
+	cases := []struct {
+		name      string
+		baseDN    string
+		cn        string
+		wantDN    string
+		wantFound bool
+		wantErr   string
+	}{
+		{name: "scoped past an archived copy", baseDN: "OU=PIRGS,DC=test", cn: "chemlab", wantDN: "CN=chemlab,OU=PIRGS,DC=test", wantFound: true},
+		{name: "archived copy on its own", baseDN: "OU=Archive,DC=test", cn: "chemlab", wantDN: "CN=chemlab,OU=Archive,DC=test", wantFound: true},
+		{name: "copies in scope", baseDN: "DC=test", cn: "chemlab", wantErr: "multiple groups named chemlab found"},
+		{name: "duplicate in a sub-OU", baseDN: "OU=PIRGS,DC=test", cn: "twin", wantErr: "multiple groups named twin found"},
+		{name: "missing", baseDN: "OU=PIRGS,DC=test", cn: "nosuch"},
+		{name: "not a group", baseDN: "OU=PIRGS,DC=test", cn: "person"},
+		{name: "missing base DN", baseDN: "OU=Gone,DC=test", cn: "chemlab"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dn, found, err := GetGroupDN(ctx, tc.baseDN, tc.cn)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("GetGroupDN() = %q, %v, %v; want an error containing %q", dn, found, err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dn != tc.wantDN || found != tc.wantFound {
+				t.Errorf("GetGroupDN() = %q, %v; want %q, %v", dn, found, tc.wantDN, tc.wantFound)
+			}
+		})
+	}
+}
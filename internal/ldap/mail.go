@@ -0,0 +1,179 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+)
+
+// primaryProxyPrefix marks the primary SMTP address in proxyAddresses.
+// Secondary addresses use the lowercase "smtp:" prefix.
+const primaryProxyPrefix = "SMTP:"
+
+// WithMail mail-enables a new group with the given primary address.
+func WithMail(address string) GroupOption {
+	return func(r *ldap.AddRequest) {
+		r.Attribute("mail", []string{address})
+		r.Attribute("proxyAddresses", []string{primaryProxyPrefix + address})
+	}
+}
+
+// ValidateMailAddress checks that address is a bare email address,
+// without a display name or angle brackets.
+func ValidateMailAddress(address string) error {
+	parsed, err := mail.ParseAddress(address)
+	if err != nil || parsed.Address != address {
+		return fmt.Errorf("invalid mail address %q", address)
+	}
+	return nil
+}
+
+// MailAddressInUse reports whether any user or group other than excludeDN
+// already uses address as its mail attribute or in its proxyAddresses.
+func MailAddressInUse(ctx context.Context, address string, excludeDN string) (bool, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return false, fmt.Errorf("config not found in context")
+	}
+	l, err := Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// proxyAddresses matching is case-insensitive in AD, so this finds
+	// both primary and secondary entries.
+	escaped := ldap.EscapeFilter(address)
+	filter := fmt.Sprintf("(|(mail=%s)(proxyAddresses=smtp:%s))", escaped, escaped)
+	for _, baseDN := range []string{cfg.LDAPUsersBaseDN, cfg.LDAPGroupsBaseDN} {
+		searchRequest := ldap.NewSearchRequest(
+			baseDN,
+			ldap.ScopeWholeSubtree,
+			ldap.NeverDerefAliases,
+			0, 0, false,
+			filter,
+			[]string{"dn"},
+			nil,
+		)
+
+		sr, err := l.Search(searchRequest)
+		if err != nil {
+			return false, fmt.Errorf("failed to search LDAP: %w", err)
+		}
+		for _, entry := range sr.Entries {
+			if !strings.EqualFold(entry.DN, excludeDN) {
+				slog.Debug("Mail address in use", "address", address, "dn", entry.DN)
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// GetGroupMail returns the mail attribute of a group, or an empty string
+// if the group is not mail-enabled.
+func GetGroupMail(ctx context.Context, groupDN string) (string, error) {
+	l, err := Conn(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	searchRequest := ldap.NewSearchRequest(
+		groupDN,
+		ldap.ScopeBaseObject,
+		ldap.NeverDerefAliases,
+		0, 0, false,
+		"(objectClass=group)",
+		[]string{"mail"},
+		nil,
+	)
+
+	sr, err := l.Search(searchRequest)
+	if err != nil {
+		return "", fmt.Errorf("failed to search LDAP: %w", err)
+	}
+	if len(sr.Entries) == 0 {
+		return "", fmt.Errorf("group %q %w", groupDN, ErrNotFound)
+	}
+	return sr.Entries[0].GetAttributeValue("mail"), nil
+}
+
+// SetGroupMail sets the primary mail address of a group, keeping the mail
+// attribute and the primary SMTP: proxyAddresses entry in step. Secondary
+// smtp: entries are preserved. An empty address clears the primary address.
+func SetGroupMail(ctx context.Context, groupDN string, address string) error {
+	l, err := Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	if address != "" {
+		if err := ValidateMailAddress(address); err != nil {
+			return err
+		}
+		inUse, err := MailAddressInUse(ctx, address, groupDN)
+		if err != nil {
+			return fmt.Errorf("failed to check mail address: %w", err)
+		}
+		if inUse {
+			return fmt.Errorf("mail address %s %w", address, ErrAlreadyExists)
+		}
+	}
+
+	searchRequest := ldap.NewSearchRequest(
+		groupDN,
+		ldap.ScopeBaseObject,
+		ldap.NeverDerefAliases,
+		0, 0, false,
+		"(objectClass=group)",
+		[]string{"proxyAddresses"},
+		nil,
+	)
+	sr, err := l.Search(searchRequest)
+	if err != nil {
+		return fmt.Errorf("failed to search LDAP: %w", err)
+	}
+	if len(sr.Entries) == 0 {
+		return fmt.Errorf("group %q %w", groupDN, ErrNotFound)
+	}
+	proxyAddresses := mailProxyAddresses(sr.Entries[0].GetAttributeValues("proxyAddresses"), address)
+	slog.Debug("Setting group mail", "groupDN", groupDN, "mail", address, "proxyAddresses", proxyAddresses)
+
+	// Replace with no values removes the attribute.
+	var mailValues []string
+	if address != "" {
+		mailValues = []string{address}
+	}
+	modifyRequest := ldap.NewModifyRequest(groupDN, nil)
+	modifyRequest.Replace("mail", mailValues)
+	modifyRequest.Replace("proxyAddresses", proxyAddresses)
+	if err := retryTransient(ctx, groupDN, func() error { return l.Modify(modifyRequest) }); err != nil {
+		return fmt.Errorf("failed to set mail on group %s: %w", groupDN, accessError(ctx, groupDN, err))
+	}
+	return nil
+}
+
+// mailProxyAddresses returns existing with its primary SMTP: entry replaced
+// by address. A secondary entry for the same address is dropped so it is
+// not listed twice.
+func mailProxyAddresses(existing []string, address string) []string {
+	var out []string
+	if address != "" {
+		out = append(out, primaryProxyPrefix+address)
+	}
+	for _, entry := range existing {
+		if strings.HasPrefix(entry, primaryProxyPrefix) {
+			continue
+		}
+		if address != "" && strings.EqualFold(entry, "smtp:"+address) {
+			continue
+		}
+		out = append(out, entry)
+	}
+	return out
+}
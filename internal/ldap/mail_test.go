@@ -0,0 +1,193 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+	"github.com/uoracs/directory-manager/internal/ldap/ldaptest"
+)
+
+const (
+	mailGroupDN  = "CN=lab,OU=Groups,DC=test"
+	otherGroupDN = "CN=other,OU=Groups,DC=test"
+)
+
+const mailTestLDIF = `dn: DC=test
+objectClass: domain
+
+dn: OU=People,DC=test
+objectClass: organizationalUnit
+
+dn: CN=alice,OU=People,DC=test
+objectClass: user
+mail: alice@example.edu
+proxyAddresses: SMTP:alice@example.edu
+proxyAddresses: smtp:asmith@example.edu
+
+dn: OU=Groups,DC=test
+objectClass: organizationalUnit
+
+dn: CN=lab,OU=Groups,DC=test
+objectClass: group
+mail: lab@example.edu
+proxyAddresses: SMTP:lab@example.edu
+proxyAddresses: smtp:lab-old@example.edu
+proxyAddresses: X500:/o=Example/cn=lab
+
+dn: CN=other,OU=Groups,DC=test
+objectClass: group
+mail: other@example.edu
+proxyAddresses: SMTP:other@example.edu
+proxyAddresses: smtp:other-alias@example.edu
+`
+
+// mailTestContext returns a context using a directory with a mail-enabled
+// user and two mail-enabled groups.
+func mailTestContext(t *testing.T) (context.Context, *ldaptest.Directory) {
+	t.Helper()
+	dir := ldaptest.New()
+	if err := dir.ReadLDIF(strings.NewReader(mailTestLDIF)); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{LDAPUsersBaseDN: "OU=People,DC=test", LDAPGroupsBaseDN: "OU=Groups,DC=test"}
+	ctx := context.WithValue(context.Background(), keys.ConfigKey, cfg)
+	return WithClient(ctx, dir), dir
+}
+
+func TestValidateMailAddress(t *testing.T) {
+	for _, address := range []string{"lab@example.edu", "lab-pirg@talapas.uoregon.edu"} {
+		if err := ValidateMailAddress(address); err != nil {
+			t.Errorf("ValidateMailAddress(%q) = %v, want nil", address, err)
+		}
+	}
+	for _, address := range []string{"", "lab", "lab@", "Lab <lab@example.edu>", "<lab@example.edu>", "lab@example.edu, other@example.edu"} {
+		if err := ValidateMailAddress(address); err == nil {
+			t.Errorf("ValidateMailAddress(%q) = nil, want an error", address)
+		}
+	}
+}
+
+func TestMailProxyAddresses(t *testing.T) {
+	cases := []struct {
+		name     string
+		existing []string
+		address  string
+		want     []string
+	}{
+		{
+			name:    "none yet",
+			address: "lab@example.edu",
+			want:    []string{"SMTP:lab@example.edu"},
+		},
+		{
+			name:     "replaces the primary and keeps the rest",
+			existing: []string{"SMTP:old@example.edu", "smtp:alias@example.edu", "X500:/o=Example/cn=lab"},
+			address:  "lab@example.edu",
+			want:     []string{"SMTP:lab@example.edu", "smtp:alias@example.edu", "X500:/o=Example/cn=lab"},
+		},
+		{
+			name:     "promotes a secondary",
+			existing: []string{"SMTP:old@example.edu", "smtp:Alias@example.edu"},
+			address:  "alias@example.edu",
+			want:     []string{"SMTP:alias@example.edu"},
+		},
+		{
+			name:     "clears the primary",
+			existing: []string{"SMTP:old@example.edu", "smtp:alias@example.edu"},
+			want:     []string{"smtp:alias@example.edu"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := mailProxyAddresses(tc.existing, tc.address); !slices.Equal(got, tc.want) {
+				t.Errorf("mailProxyAddresses() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSetGroupMail(t *testing.T) {
+	cases := []struct {
+		name      string
+		address   string
+		wantMail  []string
+		wantProxy []string
+	}{
+		{
+			name:      "new address",
+			address:   "bench@example.edu",
+			wantMail:  []string{"bench@example.edu"},
+			wantProxy: []string{"SMTP:bench@example.edu", "smtp:lab-old@example.edu", "X500:/o=Example/cn=lab"},
+		},
+		{
+			name:      "own secondary",
+			address:   "lab-old@example.edu",
+			wantMail:  []string{"lab-old@example.edu"},
+			wantProxy: []string{"SMTP:lab-old@example.edu", "X500:/o=Example/cn=lab"},
+		},
+		{
+			name:      "own primary",
+			address:   "lab@example.edu",
+			wantMail:  []string{"lab@example.edu"},
+			wantProxy: []string{"SMTP:lab@example.edu", "smtp:lab-old@example.edu", "X500:/o=Example/cn=lab"},
+		},
+		{
+			name:      "clear",
+			wantProxy: []string{"smtp:lab-old@example.edu", "X500:/o=Example/cn=lab"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, dir := mailTestContext(t)
+			if err := SetGroupMail(ctx, mailGroupDN, tc.address); err != nil {
+				t.Fatal(err)
+			}
+			if got := dir.Values(mailGroupDN, "mail"); !slices.Equal(got, tc.wantMail) {
+				t.Errorf("mail = %q, want %q", got, tc.wantMail)
+			}
+			if got := dir.Values(mailGroupDN, "proxyAddresses"); !slices.Equal(got, tc.wantProxy) {
+				t.Errorf("proxyAddresses = %q, want %q", got, tc.wantProxy)
+			}
+			if got, err := GetGroupMail(ctx, mailGroupDN); err != nil || got != tc.address {
+				t.Errorf("GetGroupMail() = %q, %v, want %q", got, err, tc.address)
+			}
+		})
+	}
+}
+
+func TestSetGroupMailRefused(t *testing.T) {
+	cases := []struct {
+		name    string
+		address string
+		wantErr error
+	}{
+		{name: "malformed", address: "Lab <lab@example.edu>"},
+		{name: "user's mail", address: "alice@example.edu", wantErr: ErrAlreadyExists},
+		{name: "user's secondary", address: "ASmith@example.edu", wantErr: ErrAlreadyExists},
+		{name: "other group's mail", address: "other@example.edu", wantErr: ErrAlreadyExists},
+		{name: "other group's secondary", address: "other-alias@example.edu", wantErr: ErrAlreadyExists},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, dir := mailTestContext(t)
+			err := SetGroupMail(ctx, mailGroupDN, tc.address)
+			if err == nil {
+				t.Fatal("SetGroupMail() = nil, want an error")
+			}
+			if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Errorf("SetGroupMail() = %v, want %v", err, tc.wantErr)
+			}
+			if got := dir.Values(mailGroupDN, "mail"); !slices.Equal(got, []string{"lab@example.edu"}) {
+				t.Errorf("refused SetGroupMail changed mail to %q", got)
+			}
+			if got := dir.Values(otherGroupDN, "proxyAddresses"); len(got) != 2 {
+				t.Errorf("refused SetGroupMail changed the other group's proxyAddresses to %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+)
+
+// GetMaintenanceMarker returns the maintenance lock recorded on the
+// maintenance_dn object, as written by SetMaintenanceMarker. It returns ""
+// if maintenance_dn is unset or the attribute is empty.
+func GetMaintenanceMarker(ctx context.Context) (string, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return "", fmt.Errorf("config not found in context")
+	}
+	if cfg.MaintenanceDN == "" {
+		return "", nil
+	}
+	l, err := Conn(ctx)
+	if err != nil {
+		return "", err
+	}
+	searchRequest := ldap.NewSearchRequest(
+		cfg.MaintenanceDN,
+		ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)",
+		[]string{cfg.MaintenanceAttribute},
+		nil,
+	)
+	sr, err := l.Search(searchRequest)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", cfg.MaintenanceDN, err)
+	}
+	if len(sr.Entries) == 0 {
+		return "", fmt.Errorf("maintenance_dn %s %w", cfg.MaintenanceDN, ErrNotFound)
+	}
+	return sr.Entries[0].GetAttributeValue(cfg.MaintenanceAttribute), nil
+}
+
+// SetMaintenanceMarker records value on the maintenance_dn object, or
+// clears the attribute if value is "". It does nothing if maintenance_dn
+// is unset.
+func SetMaintenanceMarker(ctx context.Context, value string) error {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return fmt.Errorf("config not found in context")
+	}
+	if cfg.MaintenanceDN == "" {
+		return nil
+	}
+	l, err := Conn(ctx)
+	if err != nil {
+		return err
+	}
+	values := []string{}
+	if value != "" {
+		values = []string{value}
+	}
+	modifyRequest := ldap.NewModifyRequest(cfg.MaintenanceDN, nil)
+	modifyRequest.Replace(cfg.MaintenanceAttribute, values)
+	if err := retryTransient(ctx, cfg.MaintenanceDN, func() error { return l.Modify(modifyRequest) }); err != nil {
+		return fmt.Errorf("failed to set %s on %s: %w", cfg.MaintenanceAttribute, cfg.MaintenanceDN, accessError(ctx, cfg.MaintenanceDN, err))
+	}
+	slog.Debug("Set maintenance marker", "dn", cfg.MaintenanceDN, "cleared", value == "")
+	return nil
+}
@@ -0,0 +1,234 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+)
+
+// getMemberValues reads every value of groupDN's member attribute.
+func getMemberValues(ctx context.Context, l *TimedConn, groupDN string) ([]string, error) {
+	var members []string
+	err := walkMemberValues(ctx, l, groupDN, func(values []string) {
+		members = append(members, values...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// walkMemberValues passes the values of groupDN's member attribute to visit,
+// one response at a time. AD returns at most MaxValRange values (1500 by
+// default) in one response; for larger groups it sends an empty member
+// attribute plus member;range=0-1499, and the rest has to be fetched range
+// by range until one ending in "*" arrives. member_range_size, if set, caps
+// how many values are requested at a time.
+func walkMemberValues(ctx context.Context, l *TimedConn, groupDN string, visit func(values []string)) error {
+	rangeSize := 0
+	if cfg, ok := ctx.Value(keys.ConfigKey).(*config.Config); ok && cfg != nil {
+		rangeSize = cfg.MemberRangeSize
+	}
+
+	attribute := "member"
+	if rangeSize > 0 {
+		attribute = fmt.Sprintf("member;range=0-%d", rangeSize-1)
+	}
+	for {
+		searchRequest := ldap.NewSearchRequest(
+			groupDN,
+			ldap.ScopeBaseObject,
+			ldap.NeverDerefAliases,
+			0, 0, false,
+			"(objectClass=*)",
+			[]string{attribute},
+			nil,
+		)
+		sr, err := l.Search(searchRequest)
+		if err != nil {
+			return fmt.Errorf("failed to search LDAP: %w", err)
+		}
+		if len(sr.Entries) == 0 {
+			return fmt.Errorf("group %q %w", groupDN, ErrNotFound)
+		}
+
+		next := -1
+		for _, attr := range sr.Entries[0].Attributes {
+			name := strings.ToLower(attr.Name)
+			if name == "member" {
+				visit(attr.Values)
+				continue
+			}
+			bounds, ok := strings.CutPrefix(name, "member;range=")
+			if !ok {
+				continue
+			}
+			visit(attr.Values)
+			_, high, found := strings.Cut(bounds, "-")
+			if !found {
+				return fmt.Errorf("unexpected range attribute %s on %s", attr.Name, groupDN)
+			}
+			if high == "*" {
+				break
+			}
+			last, err := strconv.Atoi(high)
+			if err != nil {
+				return fmt.Errorf("unexpected range attribute %s on %s", attr.Name, groupDN)
+			}
+			next = last + 1
+		}
+		// No range attribute, or a final one ending in "*": that's everything
+		if next < 0 {
+			return nil
+		}
+		slog.Debug("Fetching next range of group members", "groupDN", groupDN, "start", next)
+		if rangeSize > 0 {
+			attribute = fmt.Sprintf("member;range=%d-%d", next, next+rangeSize-1)
+		} else {
+			attribute = fmt.Sprintf("member;range=%d-*", next)
+		}
+	}
+}
+
+// entryMemberValues returns every value of the member attribute of entry,
+// a group read by a search that asked for member. A group too large for AD
+// to return its members in one response comes back with only the first
+// range, so the rest is fetched with walkMemberValues.
+func entryMemberValues(ctx context.Context, l *TimedConn, entry *ldap.Entry) ([]string, error) {
+	var values []string
+	for _, attr := range entry.Attributes {
+		name := strings.ToLower(attr.Name)
+		if name == "member" {
+			values = attr.Values
+			continue
+		}
+		if !strings.HasPrefix(name, "member;range=") {
+			continue
+		}
+		// A range ending in "*" holds every member; any other means more to fetch
+		if strings.HasSuffix(name, "-*") {
+			return attr.Values, nil
+		}
+		values, err := getMemberValues(ctx, l, entry.DN)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get members of %s: %w", entry.DN, err)
+		}
+		return values, nil
+	}
+	return values, nil
+}
+
+// CountGroupMembers returns how many values groupDN's member attribute has,
+// fetching ranges like GetGroupMemberDNs but without keeping the DNs.
+func CountGroupMembers(ctx context.Context, groupDN string) (int, error) {
+	l, err := Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	err = walkMemberValues(ctx, l, groupDN, func(values []string) {
+		count += len(values)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// GetMembersOfGroupsInOU returns the member DNs of every group under ouDN
+// that filter matches, keyed by group DN, from one paged subtree search.
+// Groups too large for AD to return their members in one response are read
+// with further ranged requests.
+func GetMembersOfGroupsInOU(ctx context.Context, ouDN string, filter string) (map[string][]string, error) {
+	l, err := Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	searchRequest := ldap.NewSearchRequest(
+		ouDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0, 0, false,
+		filter,
+		[]string{"member"},
+		nil,
+	)
+	sr, err := l.SearchWithPaging(searchRequest, pageSize(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search LDAP: %w", err)
+	}
+
+	members := make(map[string][]string, len(sr.Entries))
+	for _, entry := range sr.Entries {
+		members[entry.DN], err = entryMemberValues(ctx, l, entry)
+		if err != nil {
+			return nil, err
+		}
+	}
+	slog.Debug("Read members of groups", "ouDN", ouDN, "groups", len(members))
+	return members, nil
+}
+
+// FindMemberDNByCN returns the member of groupDN whose leading CN equals name,
+// ignoring case. It errors, listing the matches, if more than one member has
+// that CN.
+func FindMemberDNByCN(ctx context.Context, groupDN string, name string) (string, bool, error) {
+	members, err := GetGroupMemberDNs(ctx, groupDN)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get group members: %w", err)
+	}
+	var matches []string
+	for _, memberDN := range members {
+		parsed, err := ldap.ParseDN(memberDN)
+		if err != nil || len(parsed.RDNs) == 0 {
+			slog.Debug("Skipping unparseable member DN", "memberDN", memberDN, "error", err)
+			continue
+		}
+		for _, attr := range parsed.RDNs[0].Attributes {
+			if strings.EqualFold(attr.Type, "CN") && strings.EqualFold(attr.Value, name) {
+				matches = append(matches, memberDN)
+				break
+			}
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", false, nil
+	case 1:
+		return matches[0], true, nil
+	default:
+		return "", false, fmt.Errorf("multiple members of %s have CN %s: %s", groupDN, name, strings.Join(matches, "; "))
+	}
+}
+
+// ResolveMemberDN returns the DN to remove from groupDN for username. If the
+// user no longer exists in AD, it falls back to the group's member whose CN
+// matches username, so stale entries can still be removed. fallback reports
+// whether that happened; callers should skip anything that needs the user
+// to exist. If neither lookup finds the user, the not-found error is returned.
+func ResolveMemberDN(ctx context.Context, groupDN string, username string) (string, bool, error) {
+	userDN, err := GetUserDN(ctx, username)
+	if err == nil {
+		return userDN, false, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return "", false, err
+	}
+	memberDN, found, ferr := FindMemberDNByCN(ctx, groupDN, username)
+	if ferr != nil {
+		return "", false, ferr
+	}
+	if !found {
+		return "", false, err
+	}
+	slog.Warn("User not found in AD, removing group member by CN", "username", username, "memberDN", memberDN, "groupDN", groupDN)
+	return memberDN, true, nil
+}
@@ -0,0 +1,245 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+	"github.com/uoracs/directory-manager/internal/ldap/ldaptest"
+)
+
+const (
+	bigGroupDN   = "CN=big,OU=Groups,DC=test"
+	smallGroupDN = "CN=small,OU=Groups,DC=test"
+)
+
+// memberTestContext returns a context using a directory whose group big has
+// count members and group small has two, and which returns at most
+// maxValRange member values per search, as AD's MaxValRange does.
+func memberTestContext(t *testing.T, count int, maxValRange int, rangeSize int) (context.Context, []string) {
+	t.Helper()
+	var b strings.Builder
+	b.WriteString("dn: DC=test\nobjectClass: domain\n\n")
+	b.WriteString("dn: OU=People,DC=test\nobjectClass: organizationalUnit\n\n")
+	b.WriteString("dn: OU=Groups,DC=test\nobjectClass: organizationalUnit\n\n")
+	var members []string
+	for i := range count {
+		dn := fmt.Sprintf("CN=user%03d,OU=People,DC=test", i)
+		members = append(members, dn)
+		fmt.Fprintf(&b, "dn: %s\nobjectClass: user\n\n", dn)
+	}
+	fmt.Fprintf(&b, "dn: %s\nobjectClass: group\ncn: big\ngidNumber: 100001\n", bigGroupDN)
+	for _, dn := range members {
+		fmt.Fprintf(&b, "member: %s\n", dn)
+	}
+	fmt.Fprintf(&b, "\ndn: %s\nobjectClass: group\ncn: small\ngidNumber: 100002\nmember: %s\nmember: %s\n", smallGroupDN, members[0], members[1])
+
+	dir := ldaptest.New()
+	if err := dir.ReadLDIF(strings.NewReader(b.String())); err != nil {
+		t.Fatal(err)
+	}
+	dir.MaxValRange = maxValRange
+	cfg := &config.Config{MemberRangeSize: rangeSize}
+	ctx := context.WithValue(context.Background(), keys.ConfigKey, cfg)
+	return WithClient(ctx, dir), members
+}
+
+var memberRangeCases = []struct {
+	name        string
+	count       int
+	maxValRange int
+	rangeSize   int
+}{
+	{name: "in one response", count: 7, maxValRange: 0},
+	{name: "exactly the limit", count: 6, maxValRange: 6},
+	{name: "ranged", count: 7, maxValRange: 3},
+	{name: "ranged with a last range of one", count: 7, maxValRange: 2},
+	{name: "ranged with member_range_size", count: 7, maxValRange: 5, rangeSize: 2},
+}
+
+func TestGetGroupMemberDNsRanged(t *testing.T) {
+	for _, tc := range memberRangeCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, members := memberTestContext(t, tc.count, tc.maxValRange, tc.rangeSize)
+			got, err := GetGroupMemberDNs(ctx, bigGroupDN)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, members) {
+				t.Errorf("members = %v, want %v", got, members)
+			}
+			count, err := CountGroupMembers(ctx, bigGroupDN)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != tc.count {
+				t.Errorf("count = %d, want %d", count, tc.count)
+			}
+		})
+	}
+}
+
+// TestSubtreeSearchesRanged checks that each search reading the members of
+// every group under an OU fetches the rest of a group AD returned only the
+// first range of.
+func TestSubtreeSearchesRanged(t *testing.T) {
+	for _, tc := range memberRangeCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, members := memberTestContext(t, tc.count, tc.maxValRange, tc.rangeSize)
+
+			byGroup, err := GetMembersOfGroupsInOU(ctx, "OU=Groups,DC=test", "(objectClass=group)")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := byGroup[bigGroupDN]; !slices.Equal(got, members) {
+				t.Errorf("GetMembersOfGroupsInOU: big = %v, want %v", got, members)
+			}
+			if got := byGroup[smallGroupDN]; len(got) != 2 {
+				t.Errorf("GetMembersOfGroupsInOU: small = %v, want 2 members", got)
+			}
+
+			groups, err := GetPosixGroupsInOU(ctx, "OU=Groups,DC=test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, g := range groups {
+				if g.DN == bigGroupDN && !slices.Equal(g.Members, members) {
+					t.Errorf("GetPosixGroupsInOU: big = %v, want %v", g.Members, members)
+				}
+			}
+
+			summaries, err := SummarizeGroupsInOU(ctx, "OU=Groups,DC=test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range summaries {
+				if s.DN == bigGroupDN && s.Members != tc.count {
+					t.Errorf("SummarizeGroupsInOU: big has %d members, want %d", s.Members, tc.count)
+				}
+			}
+			if len(groups) != 2 || len(summaries) != 2 {
+				t.Errorf("read %d POSIX groups and %d summaries, want 2 of each", len(groups), len(summaries))
+			}
+		})
+	}
+}
+
+func TestCountGroupMembers(t *testing.T) {
+	const emptyGroupDN = "CN=empty,OU=Groups,DC=test"
+	// More than the 1500 values AD returns per range by default
+	ctx, _ := memberTestContext(t, 1600, 1500, 0)
+	l, err := Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	add := ldap.NewAddRequest(emptyGroupDN, nil)
+	add.Attribute("objectClass", []string{"group"})
+	add.Attribute("cn", []string{"empty"})
+	if err := l.Add(add); err != nil {
+		t.Fatal(err)
+	}
+
+	for dn, want := range map[string]int{emptyGroupDN: 0, smallGroupDN: 2, bigGroupDN: 1600} {
+		got, err := CountGroupMembers(ctx, dn)
+		if err != nil {
+			t.Fatalf("CountGroupMembers(%s): %v", dn, err)
+		}
+		if got != want {
+			t.Errorf("CountGroupMembers(%s) = %d, want %d", dn, got, want)
+		}
+	}
+}
+
+// resolveTestContext returns a context using a directory where alice exists
+// and group g also has members ghost, whose account was deleted, and two
+// deleted accounts named twin from different OUs.
+func resolveTestContext(t *testing.T) context.Context {
+	t.Helper()
+	dir := ldaptest.New()
+	err := dir.ReadLDIF(strings.NewReader(`dn: DC=test
+objectClass: domain
+
+dn: OU=People,DC=test
+objectClass: organizationalUnit
+
+dn: CN=alice,OU=People,DC=test
+objectClass: user
+objectCategory: person
+cn: alice
+sAMAccountName: alice
+
+dn: CN=g,DC=test
+objectClass: group
+cn: g
+member: CN=alice,OU=People,DC=test
+member: CN=Ghost,OU=People,DC=test
+member: CN=twin,OU=People,DC=test
+member: CN=twin,OU=Old,DC=test
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{LDAPUsersBaseDN: "OU=People,DC=test"}
+	return WithClient(context.WithValue(context.Background(), keys.ConfigKey, cfg), dir)
+}
+
+func TestResolveMemberDN(t *testing.T) {
+	ctx := resolveTestContext(t)
+	cases := []struct {
+		name         string
+		username     string
+		wantDN       string
+		wantFallback bool
+		wantErr      error
+		wantMsg      string
+	}{
+		{name: "by username", username: "alice", wantDN: "CN=alice,OU=People,DC=test"},
+		{name: "deleted, present by CN", username: "ghost", wantDN: "CN=Ghost,OU=People,DC=test", wantFallback: true},
+		{name: "deleted and absent", username: "nobody", wantErr: ErrNotFound},
+		{name: "deleted, ambiguous CN", username: "twin", wantMsg: "multiple members"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dn, fallback, err := ResolveMemberDN(ctx, "CN=g,DC=test", tc.username)
+			if tc.wantErr != nil || tc.wantMsg != "" {
+				if err == nil {
+					t.Fatalf("ResolveMemberDN() = %q, want an error", dn)
+				}
+				if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+					t.Errorf("error = %v, want %v", err, tc.wantErr)
+				}
+				if !strings.Contains(err.Error(), tc.wantMsg) {
+					t.Errorf("error = %v, want it to mention %q", err, tc.wantMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dn != tc.wantDN || fallback != tc.wantFallback {
+				t.Errorf("ResolveMemberDN() = %q, %v, want %q, %v", dn, fallback, tc.wantDN, tc.wantFallback)
+			}
+		})
+	}
+}
+
+func TestFindMemberDNByCN(t *testing.T) {
+	ctx := resolveTestContext(t)
+	dn, found, err := FindMemberDNByCN(ctx, "CN=g,DC=test", "GHOST")
+	if err != nil || !found || dn != "CN=Ghost,OU=People,DC=test" {
+		t.Errorf("FindMemberDNByCN(GHOST) = %q, %v, %v", dn, found, err)
+	}
+	// Only the leading RDN counts
+	if dn, found, err := FindMemberDNByCN(ctx, "CN=g,DC=test", "People"); err != nil || found {
+		t.Errorf("FindMemberDNByCN(People) = %q, %v, %v, want not found", dn, found, err)
+	}
+	if _, _, err := FindMemberDNByCN(ctx, "CN=nosuch,DC=test", "ghost"); err == nil {
+		t.Error("no error for a group that doesn't exist")
+	}
+}
@@ -0,0 +1,73 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+)
+
+// notMemberMarker is the line of info on a main group whose role holder,
+// such as the PI of a PIRG created with --no-member, is deliberately not a
+// member of it. It differs from changeNotePrefix, so trimming change notes
+// never drops it.
+const notMemberMarker = "[directory-manager:role-holder-not-member]"
+
+// hasNotMemberMarker reports whether info has the not-member marker.
+func hasNotMemberMarker(info string) bool {
+	for _, line := range strings.Split(strings.ReplaceAll(info, "\r\n", "\n"), "\n") {
+		if line == notMemberMarker {
+			return true
+		}
+	}
+	return false
+}
+
+// setNotMemberMarker returns info with the not-member marker added, or
+// removed if notMember is false. Other lines are left alone.
+func setNotMemberMarker(info string, notMember bool) (string, error) {
+	var lines []string
+	if info != "" {
+		for _, line := range strings.Split(strings.ReplaceAll(info, "\r\n", "\n"), "\n") {
+			if line != notMemberMarker {
+				lines = append(lines, line)
+			}
+		}
+	}
+	if notMember {
+		lines = append(lines, notMemberMarker)
+	}
+	updated := strings.Join(lines, "\r\n")
+	if n := utf8.RuneCountInString(updated); n > maxInfoLength {
+		return "", fmt.Errorf("info would be %d characters, the limit is %d", n, maxInfoLength)
+	}
+	return updated, nil
+}
+
+// RoleHolderNotMember reports whether the group at groupDN is marked as
+// having a role holder who is deliberately not a member of it.
+func RoleHolderNotMember(ctx context.Context, groupDN string) (bool, error) {
+	l, err := Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	info, err := getInfo(l, groupDN)
+	if err != nil {
+		return false, fmt.Errorf("failed to read info of %s: %w", groupDN, err)
+	}
+	return hasNotMemberMarker(info), nil
+}
+
+// SetRoleHolderNotMember marks the group at groupDN as having a role holder
+// who is deliberately not a member of it, or removes the mark.
+func SetRoleHolderNotMember(ctx context.Context, groupDN string, notMember bool) error {
+	err := modifyInfo(ctx, groupDN, func(info string) (string, error) {
+		return setNotMemberMarker(info, notMember)
+	})
+	if err != nil {
+		return err
+	}
+	slog.Debug("Set role holder not-member mark", "groupDN", groupDN, "notMember", notMember)
+	return nil
+}
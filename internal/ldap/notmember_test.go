@@ -0,0 +1,37 @@
+package ldap
+
+import "testing"
+
+func TestSetNotMemberMarker(t *testing.T) {
+	info := "Owned by the lab\r\n[directory-manager] 2026-01-02 alice: create"
+	marked, err := setNotMemberMarker(info, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := info + "\r\n" + notMemberMarker; marked != want {
+		t.Errorf("marked info = %q, want %q", marked, want)
+	}
+	if !hasNotMemberMarker(marked) {
+		t.Error("marker not found after setting it")
+	}
+	again, err := setNotMemberMarker(marked, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != marked {
+		t.Errorf("setting the marker twice changed info to %q", again)
+	}
+	cleared, err := setNotMemberMarker(marked, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cleared != info {
+		t.Errorf("cleared info = %q, want %q", cleared, info)
+	}
+	if hasNotMemberMarker(cleared) {
+		t.Error("marker found after clearing it")
+	}
+	if empty, err := setNotMemberMarker("", false); err != nil || empty != "" {
+		t.Errorf("clearing empty info = %q, %v; want empty", empty, err)
+	}
+}
@@ -0,0 +1,147 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/uoracs/directory-manager/internal/managedgroup"
+)
+
+// OrphanOU is an OU directly under a family's base DN with no main group
+// named for it, as a failed create or a hand deletion leaves behind. The
+// family's list and exists functions look for groups, so they never see it.
+type OrphanOU struct {
+	Name string `json:"name"`
+	DN   string `json:"dn"`
+	// Groups are the DNs of groups still somewhere in the OU, such as an
+	// admins group a failed create got as far as.
+	Groups []string `json:"groups"`
+}
+
+// FindOrphanOUs returns the OUs directly under baseDN that don't hold the
+// main group of family for their name, sorted by name.
+func FindOrphanOUs(ctx context.Context, family managedgroup.Family, baseDN string) ([]OrphanOU, error) {
+	l, err := Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ouDNs, err := GetOUDNsInOU(ctx, baseDN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list OUs in %s: %w", baseDN, err)
+	}
+
+	// One search for every group in the family, rather than one per OU
+	searchRequest := ldap.NewSearchRequest(
+		baseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0, 0, false,
+		"(objectClass=group)",
+		[]string{"cn"},
+		nil,
+	)
+	sr, err := l.SearchWithPaging(searchRequest, pageSize(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search LDAP: %w", err)
+	}
+
+	var orphans []OrphanOU
+	for _, ouDN := range ouDNs {
+		name, err := ConvertDNToObjectName(ouDN)
+		if err != nil {
+			return nil, err
+		}
+		suffix := "," + strings.ToLower(ouDN)
+		mainCN := family.Prefix + name
+		hasMain := false
+		var groups []string
+		for _, entry := range sr.Entries {
+			if !strings.HasSuffix(strings.ToLower(entry.DN), suffix) {
+				continue
+			}
+			if strings.EqualFold(entry.GetAttributeValue("cn"), mainCN) {
+				hasMain = true
+				break
+			}
+			groups = append(groups, entry.DN)
+		}
+		if hasMain {
+			continue
+		}
+		slog.Debug("Orphan OU", "dn", ouDN, "groups", len(groups))
+		orphans = append(orphans, OrphanOU{Name: name, DN: ouDN, Groups: groups})
+	}
+	slices.SortFunc(orphans, func(a, b OrphanOU) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+	return orphans, nil
+}
+
+// objectsInOU returns the DNs of everything in ouDN and below that isn't
+// itself an OU.
+func objectsInOU(ctx context.Context, ouDN string) ([]string, error) {
+	l, err := Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	searchRequest := ldap.NewSearchRequest(
+		ouDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0, 0, false,
+		"(!(objectClass=organizationalUnit))",
+		[]string{"dn"},
+		nil,
+	)
+	sr, err := l.SearchWithPaging(searchRequest, pageSize(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search LDAP: %w", err)
+	}
+	dns := make([]string, len(sr.Entries))
+	for i, entry := range sr.Entries {
+		dns[i] = entry.DN
+	}
+	return dns, nil
+}
+
+// DeleteEmptyOU deletes ouDN and the OUs under it. It refuses, with
+// ErrNotEmpty naming them, if any groups, users, or other objects remain
+// anywhere inside, so only an empty tree such as an orphan's Groups OU goes.
+func DeleteEmptyOU(ctx context.Context, ouDN string) error {
+	objects, err := objectsInOU(ctx, ouDN)
+	if err != nil {
+		return fmt.Errorf("failed to list objects in %s: %w", ouDN, err)
+	}
+	if len(objects) > 0 {
+		return fmt.Errorf("OU %s still holds %s: %w", ouDN, strings.Join(objects, "; "), ErrNotEmpty)
+	}
+	return DeleteOURecursively(ctx, ouDN)
+}
+
+// AdoptOrphanOU checks ouDN before a managed group is created in it. A
+// missing OU, or one holding nothing but OUs, is fine to create the groups
+// in; the latter is logged as adopted. An OU that still holds groups or
+// other objects is refused with ErrAlreadyExists, pointing at cleanCommand,
+// since creating next to them would leave a half-built group again.
+func AdoptOrphanOU(ctx context.Context, ouDN string, cleanCommand string) error {
+	exists, err := DNExists(ctx, ouDN)
+	if err != nil {
+		return fmt.Errorf("failed to check if OU exists: %w", err)
+	}
+	if !exists {
+		return nil
+	}
+	objects, err := objectsInOU(ctx, ouDN)
+	if err != nil {
+		return fmt.Errorf("failed to list objects in %s: %w", ouDN, err)
+	}
+	if len(objects) > 0 {
+		return fmt.Errorf("OU %s is left from an earlier create and still holds %s; remove them, then run %s: %w", ouDN, strings.Join(objects, "; "), cleanCommand, ErrAlreadyExists)
+	}
+	slog.Info("Adopting empty orphan OU", "dn", ouDN)
+	return nil
+}
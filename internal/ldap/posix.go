@@ -0,0 +1,155 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+)
+
+// samNormalUserAccount is the sAMAccountType of user accounts, as opposed
+// to groups, computers, and trusts.
+const samNormalUserAccount = "805306368"
+
+// PosixGroup is a group with the attributes SSSD needs from it.
+type PosixGroup struct {
+	DN string
+	CN string
+	// GidNumber is the raw gidNumber, "" if unset.
+	GidNumber string
+	Members   []string
+}
+
+// GetPosixGroupsInOU returns every group under ouDN with its gidNumber and
+// members, from one paged subtree search. Groups too large for AD to
+// return their members in one response are read with ranged requests.
+func GetPosixGroupsInOU(ctx context.Context, ouDN string) ([]PosixGroup, error) {
+	l, err := Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	searchRequest := ldap.NewSearchRequest(
+		ouDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0, 0, false,
+		"(objectClass=group)",
+		[]string{"cn", "gidNumber", "member"},
+		nil,
+	)
+	sr, err := l.SearchWithPaging(searchRequest, pageSize(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search LDAP: %w", err)
+	}
+
+	groups := make([]PosixGroup, 0, len(sr.Entries))
+	for _, entry := range sr.Entries {
+		g := PosixGroup{DN: entry.DN, CN: entry.GetAttributeValue("cn"), GidNumber: entry.GetAttributeValue("gidNumber")}
+		g.Members, err = entryMemberValues(ctx, l, entry)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	slog.Debug("Read POSIX attributes of groups", "ouDN", ouDN, "count", len(groups))
+	return groups, nil
+}
+
+// GetUsersWithoutUID returns the user accounts among userDNs that have no
+// uidNumber, keyed by lowercased DN, with the SID GetUidOfExistingUser
+// falls back to for them. Members that aren't user accounts are ignored.
+func GetUsersWithoutUID(ctx context.Context, userDNs []string) (map[string]string, error) {
+	attrs, err := GetUserAttributes(ctx, userDNs, []string{"sAMAccountType", "uidNumber", "objectSid"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user attributes: %w", err)
+	}
+	missing := make(map[string]string)
+	for dn, values := range attrs {
+		if values["samaccounttype"] != samNormalUserAccount || values["uidnumber"] != "" {
+			continue
+		}
+		missing[dn] = uidOrSID("", []byte(values["objectsid"]))
+	}
+	return missing, nil
+}
+
+// SetGidNumber sets the gidNumber of the group at groupDN. Like
+// CreateGroup, it refuses a gidNumber another group already has unless
+// enforce_gid_uniqueness is off.
+func SetGidNumber(ctx context.Context, groupDN string, gidNumber int) error {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return fmt.Errorf("config not found in context")
+	}
+	l, err := Conn(ctx)
+	if err != nil {
+		return err
+	}
+	if err := checkGidUnique(ctx, cfg, gidNumber); err != nil {
+		return err
+	}
+	modifyRequest := ldap.NewModifyRequest(groupDN, nil)
+	modifyRequest.Replace("gidNumber", []string{strconv.Itoa(gidNumber)})
+	if err := retryTransient(ctx, groupDN, func() error { return l.Modify(modifyRequest) }); err != nil {
+		return fmt.Errorf("failed to set gidNumber on %s: %w", groupDN, accessError(ctx, groupDN, err))
+	}
+	slog.Debug("Set gidNumber", "groupDN", groupDN, "gidNumber", gidNumber)
+	return nil
+}
+
+// GetGidNumbersOfGroups looks up the gidNumber of many groups at once,
+// keyed by lowercased DN. DNs are OR-ed together in batches of
+// memberOfBatchSize. Groups without a gidNumber are missing from the
+// result.
+func GetGidNumbersOfGroups(ctx context.Context, groupDNs []string) (map[string]int, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	l, err := Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	gids := make(map[string]int, len(groupDNs))
+	for start := 0; start < len(groupDNs); start += memberOfBatchSize {
+		end := min(start+memberOfBatchSize, len(groupDNs))
+		var filter strings.Builder
+		filter.WriteString("(&(objectClass=group)(gidNumber=*)(|")
+		for _, dn := range groupDNs[start:end] {
+			filter.WriteString(fmt.Sprintf("(distinguishedName=%s)", ldap.EscapeFilter(dn)))
+		}
+		filter.WriteString("))")
+
+		searchRequest := ldap.NewSearchRequest(
+			cfg.LDAPGroupsBaseDN,
+			ldap.ScopeWholeSubtree,
+			ldap.NeverDerefAliases,
+			0, 0, false,
+			filter.String(),
+			[]string{"gidNumber"},
+			nil,
+		)
+		slog.Debug("Searching LDAP for gidNumbers", "count", end-start)
+
+		sr, err := l.SearchWithPaging(searchRequest, pageSize(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("failed to search LDAP: %w", err)
+		}
+		for _, entry := range sr.Entries {
+			gid, err := strconv.Atoi(entry.GetAttributeValue("gidNumber"))
+			if err != nil {
+				slog.Warn("Ignoring invalid gidNumber", "groupDN", entry.DN, "gidNumber", entry.GetAttributeValue("gidNumber"))
+				continue
+			}
+			gids[strings.ToLower(entry.DN)] = gid
+		}
+	}
+	return gids, nil
+}
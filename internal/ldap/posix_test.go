@@ -0,0 +1,59 @@
+package ldap
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+	"github.com/uoracs/directory-manager/internal/ldap/ldaptest"
+)
+
+const noGidDN = "CN=is.racs.pirg.nogid,OU=PIRGS,DC=test"
+
+func addGroupWithoutGid(t *testing.T, dir *ldaptest.Directory) {
+	t.Helper()
+	req := ldap.NewAddRequest(noGidDN, nil)
+	req.Attribute("objectClass", []string{"group"})
+	req.Attribute("cn", []string{"is.racs.pirg.nogid"})
+	if err := dir.Add(req); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetGidNumber(t *testing.T) {
+	ctx, dir := gidTestContext(t, nil)
+	addGroupWithoutGid(t, dir)
+	if err := SetGidNumber(ctx, noGidDN, 100005); err != nil {
+		t.Fatal(err)
+	}
+	if got := dir.Values(noGidDN, "gidNumber"); len(got) != 1 || got[0] != "100005" {
+		t.Errorf("gidNumber = %v, want [100005]", got)
+	}
+}
+
+func TestSetGidNumberRefusesGidInUse(t *testing.T) {
+	ctx, dir := gidTestContext(t, nil)
+	addGroupWithoutGid(t, dir)
+	addOtherGroup(t, dir, "other1", 100005)
+	for _, gid := range []int{100000, 100005} {
+		err := SetGidNumber(ctx, noGidDN, gid)
+		if !errors.Is(err, ErrAlreadyExists) {
+			t.Errorf("SetGidNumber(%d) = %v, want ErrAlreadyExists", gid, err)
+		}
+	}
+	if got := dir.Values(noGidDN, "gidNumber"); len(got) != 0 {
+		t.Errorf("gidNumber = %v, want it left unset", got)
+	}
+}
+
+func TestSetGidNumberWithoutUniquenessCheck(t *testing.T) {
+	ctx, dir := gidTestContext(t, nil)
+	enforce := false
+	ctx.Value(keys.ConfigKey).(*config.Config).EnforceGidUniqueness = &enforce
+	addGroupWithoutGid(t, dir)
+	if err := SetGidNumber(ctx, noGidDN, 100000); err != nil {
+		t.Errorf("SetGidNumber = %v, want nil with enforce_gid_uniqueness off", err)
+	}
+}
@@ -0,0 +1,61 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// NamedGroup is a group with the attributes that carry its name.
+type NamedGroup struct {
+	DN             string `json:"dn"`
+	CN             string `json:"cn"`
+	SAMAccountName string `json:"sam_account_name"`
+	Description    string `json:"description,omitempty"`
+}
+
+// GetGroupsWithNamePrefix returns every group under baseDN whose cn or
+// sAMAccountName starts with prefix, in one paged search.
+func GetGroupsWithNamePrefix(ctx context.Context, baseDN string, prefix string) ([]NamedGroup, error) {
+	escaped := ldap.EscapeFilter(prefix)
+	return searchNamedGroups(ctx, baseDN, fmt.Sprintf("(&(objectClass=group)(|(cn=%s*)(sAMAccountName=%s*)))", escaped, escaped))
+}
+
+// GetGroupsWithDescriptionContaining returns every group under baseDN whose
+// description contains text, in one paged search.
+func GetGroupsWithDescriptionContaining(ctx context.Context, baseDN string, text string) ([]NamedGroup, error) {
+	return searchNamedGroups(ctx, baseDN, fmt.Sprintf("(&(objectClass=group)(description=*%s*))", ldap.EscapeFilter(text)))
+}
+
+func searchNamedGroups(ctx context.Context, baseDN string, filter string) ([]NamedGroup, error) {
+	l, err := Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	searchRequest := ldap.NewSearchRequest(
+		baseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0, 0, false,
+		filter,
+		[]string{"cn", "sAMAccountName", "description"},
+		nil,
+	)
+	sr, err := l.SearchWithPaging(searchRequest, pageSize(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search LDAP: %w", err)
+	}
+
+	groups := make([]NamedGroup, 0, len(sr.Entries))
+	for _, entry := range sr.Entries {
+		groups = append(groups, NamedGroup{
+			DN:             entry.DN,
+			CN:             entry.GetAttributeValue("cn"),
+			SAMAccountName: entry.GetAttributeValue("sAMAccountName"),
+			Description:    entry.GetAttributeValue("description"),
+		})
+	}
+	return groups, nil
+}
@@ -0,0 +1,80 @@
+package ldap
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// rawScopes maps the scope names raw search accepts to LDAP scopes.
+var rawScopes = map[string]int{
+	"base": ldap.ScopeBaseObject,
+	"one":  ldap.ScopeSingleLevel,
+	"sub":  ldap.ScopeWholeSubtree,
+}
+
+// RawEntry is one entry found by RawSearch. Values that aren't valid UTF-8,
+// such as objectSid, are kept base64-encoded under Binary instead.
+type RawEntry struct {
+	DN         string              `json:"dn"`
+	Attributes map[string][]string `json:"attributes"`
+	Binary     map[string][]string `json:"binary,omitempty"`
+}
+
+// RawSearch runs a read-only search for filter under baseDN with the given
+// scope (base, one, or sub), returning the requested attributes, or every
+// attribute if there are none. The filter is checked before it is sent, and
+// results are paged like every other large search. A baseDN that doesn't
+// exist is ErrNotFound.
+func RawSearch(ctx context.Context, baseDN string, scope string, filter string, attributes []string) ([]RawEntry, error) {
+	ldapScope, ok := rawScopes[scope]
+	if !ok {
+		return nil, fmt.Errorf("invalid scope %q, expected base, one, or sub", scope)
+	}
+	if _, err := ldap.CompileFilter(filter); err != nil {
+		return nil, fmt.Errorf("invalid filter %q: %w", filter, err)
+	}
+	l, err := Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	searchRequest := ldap.NewSearchRequest(
+		baseDN,
+		ldapScope,
+		ldap.NeverDerefAliases,
+		0, 0, false,
+		filter,
+		attributes,
+		nil,
+	)
+	sr, err := l.SearchWithPaging(searchRequest, pageSize(ctx))
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, fmt.Errorf("base %q %w", baseDN, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to search LDAP: %w", err)
+	}
+
+	entries := make([]RawEntry, 0, len(sr.Entries))
+	for _, entry := range sr.Entries {
+		e := RawEntry{DN: entry.DN, Attributes: make(map[string][]string, len(entry.Attributes))}
+		for _, attr := range entry.Attributes {
+			for _, value := range attr.ByteValues {
+				if utf8.Valid(value) {
+					e.Attributes[attr.Name] = append(e.Attributes[attr.Name], string(value))
+					continue
+				}
+				if e.Binary == nil {
+					e.Binary = make(map[string][]string)
+				}
+				e.Binary[attr.Name] = append(e.Binary[attr.Name], base64.StdEncoding.EncodeToString(value))
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
@@ -0,0 +1,74 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+)
+
+// retryBackoff is the wait before the first retry of a change the server
+// was too busy for. It doubles with each retry after that.
+const retryBackoff = 250 * time.Millisecond
+
+// changeOutcome is what a failed change means for the caller.
+type changeOutcome int
+
+const (
+	// changeFatal is a refusal that would happen again, such as a
+	// permission or constraint error.
+	changeFatal changeOutcome = iota
+	// changeSatisfied is a failure because what the change asks for is
+	// already the case, e.g. someone else added the member first.
+	changeSatisfied
+	// changeRetryable is the server declining the change for now.
+	changeRetryable
+)
+
+// classifyResult sorts err from a change by its result code. Only busy and
+// unavailable are retryable. Which codes mean the change is already
+// satisfied depends on the change, so the caller lists them in satisfied:
+// attributeOrValueExists when adding a value, noSuchAttribute when removing
+// one.
+func classifyResult(err error, satisfied ...uint16) changeOutcome {
+	var ldapErr *ldap.Error
+	if !errors.As(err, &ldapErr) {
+		return changeFatal
+	}
+	switch {
+	case slices.Contains(satisfied, ldapErr.ResultCode):
+		return changeSatisfied
+	case ldapErr.ResultCode == ldap.LDAPResultBusy || ldapErr.ResultCode == ldap.LDAPResultUnavailable:
+		return changeRetryable
+	}
+	return changeFatal
+}
+
+// retryTransient runs change, and runs it again up to ldap_modify_retries
+// times, with backoff, while it fails with a transient result code. dn is
+// only used for logging.
+func retryTransient(ctx context.Context, dn string, change func() error) error {
+	retries := config.DefaultLDAPModifyRetries
+	if cfg, ok := ctx.Value(keys.ConfigKey).(*config.Config); ok && cfg != nil {
+		retries = cfg.ModifyRetries()
+	}
+	wait := retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := change()
+		if err == nil || attempt == retries || classifyResult(err) != changeRetryable {
+			return err
+		}
+		slog.Debug("Server busy, retrying change", "dn", dn, "attempt", attempt+1, "wait", wait, "error", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
@@ -0,0 +1,234 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+	"github.com/uoracs/directory-manager/internal/ldap/ldaptest"
+)
+
+// busyClient answers the first busy changes it is sent with busy, as a
+// loaded domain controller does, and passes the rest to the directory.
+type busyClient struct {
+	*ldaptest.Directory
+	busy int
+	sent int
+}
+
+func (c *busyClient) refuse() error {
+	c.sent++
+	if c.sent <= c.busy {
+		return ldap.NewError(ldap.LDAPResultBusy, errors.New("server busy"))
+	}
+	return nil
+}
+
+func (c *busyClient) Add(req *ldap.AddRequest) error {
+	if err := c.refuse(); err != nil {
+		return err
+	}
+	return c.Directory.Add(req)
+}
+
+func (c *busyClient) Modify(req *ldap.ModifyRequest) error {
+	if err := c.refuse(); err != nil {
+		return err
+	}
+	return c.Directory.Modify(req)
+}
+
+func (c *busyClient) ModifyDN(req *ldap.ModifyDNRequest) error {
+	if err := c.refuse(); err != nil {
+		return err
+	}
+	return c.Directory.ModifyDN(req)
+}
+
+func (c *busyClient) Del(req *ldap.DelRequest) error {
+	if err := c.refuse(); err != nil {
+		return err
+	}
+	return c.Directory.Del(req)
+}
+
+const retryTestLDIF = `dn: DC=test
+objectClass: domain
+
+dn: OU=lab,DC=test
+objectClass: organizationalUnit
+
+dn: CN=g,OU=lab,DC=test
+objectClass: group
+cn: g
+sAMAccountName: g
+
+dn: CN=u,OU=lab,DC=test
+objectClass: top
+objectClass: person
+objectClass: user
+cn: u
+`
+
+// retryTestContext returns a context using a directory seeded with
+// retryTestLDIF that answers the first busy changes with busy, and allows
+// retries retries of each change.
+func retryTestContext(t *testing.T, busy int, retries int) (context.Context, *ldaptest.Directory) {
+	t.Helper()
+	dir := ldaptest.New()
+	if err := dir.ReadLDIF(strings.NewReader(retryTestLDIF)); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{LDAPModifyRetries: &retries}
+	ctx := context.WithValue(context.Background(), keys.ConfigKey, cfg)
+	return WithClient(ctx, &busyClient{Directory: dir, busy: busy}), dir
+}
+
+// TestMutationsRetryBusy checks that every helper that changes the
+// directory retries a change the server was too busy for.
+func TestMutationsRetryBusy(t *testing.T) {
+	cases := []struct {
+		name   string
+		change func(ctx context.Context) error
+		check  func(dir *ldaptest.Directory) bool
+	}{
+		{
+			name:   "CreateOU",
+			change: func(ctx context.Context) error { return CreateOU(ctx, "OU=lab,DC=test", "new") },
+			check:  func(dir *ldaptest.Directory) bool { return dir.Exists("OU=new,OU=lab,DC=test") },
+		},
+		{
+			name:   "DeleteGroup",
+			change: func(ctx context.Context) error { return DeleteGroup(ctx, "CN=g,OU=lab,DC=test") },
+			check:  func(dir *ldaptest.Directory) bool { return !dir.Exists("CN=g,OU=lab,DC=test") },
+		},
+		{
+			name:   "DeleteOURecursively",
+			change: func(ctx context.Context) error { return DeleteOURecursively(ctx, "OU=lab,DC=test") },
+			check:  func(dir *ldaptest.Directory) bool { return !dir.Exists("OU=lab,DC=test") },
+		},
+		{
+			name:   "RenameOU",
+			change: func(ctx context.Context) error { return RenameOU(ctx, "OU=lab,DC=test", "bench") },
+			check:  func(dir *ldaptest.Directory) bool { return dir.Exists("CN=g,OU=bench,DC=test") },
+		},
+		{
+			name:   "RenameGroup",
+			change: func(ctx context.Context) error { return RenameGroup(ctx, "CN=g,OU=lab,DC=test", "h") },
+			check: func(dir *ldaptest.Directory) bool {
+				name := dir.Values("CN=h,OU=lab,DC=test", "sAMAccountName")
+				return len(name) == 1 && name[0] == "h"
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, dir := retryTestContext(t, 1, 1)
+			if err := tc.change(ctx); err != nil {
+				t.Fatalf("failed after one busy answer: %v", err)
+			}
+			if !tc.check(dir) {
+				t.Error("change not made")
+			}
+
+			ctx, _ = retryTestContext(t, 1, 0)
+			if err := tc.change(ctx); err == nil || !strings.Contains(err.Error(), "server busy") {
+				t.Errorf("error = %v with retries off, want busy", err)
+			}
+		})
+	}
+}
+
+func TestClassifyResult(t *testing.T) {
+	cases := []struct {
+		name      string
+		err       error
+		satisfied []uint16
+		want      changeOutcome
+	}{
+		{name: "busy", err: ldap.NewError(ldap.LDAPResultBusy, errors.New("busy")), want: changeRetryable},
+		{name: "unavailable", err: ldap.NewError(ldap.LDAPResultUnavailable, errors.New("unavailable")), want: changeRetryable},
+		{name: "listed", err: ldap.NewError(ldap.LDAPResultAttributeOrValueExists, errors.New("exists")), satisfied: []uint16{ldap.LDAPResultAttributeOrValueExists}, want: changeSatisfied},
+		{name: "not listed", err: ldap.NewError(ldap.LDAPResultAttributeOrValueExists, errors.New("exists")), want: changeFatal},
+		{name: "insufficient access", err: ldap.NewError(ldap.LDAPResultInsufficientAccessRights, errors.New("denied")), satisfied: []uint16{ldap.LDAPResultNoSuchAttribute}, want: changeFatal},
+		{name: "wrapped", err: fmt.Errorf("modify: %w", ldap.NewError(ldap.LDAPResultBusy, errors.New("busy"))), want: changeRetryable},
+		{name: "not an LDAP error", err: errors.New("connection reset"), want: changeFatal},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := classifyResult(tc.err, tc.satisfied...); got != tc.want {
+				t.Errorf("classifyResult() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// answeringClient answers modifies with the given result codes in turn,
+// then passes them to the directory.
+type answeringClient struct {
+	*ldaptest.Directory
+	codes []uint16
+	sent  int
+}
+
+func (c *answeringClient) Modify(req *ldap.ModifyRequest) error {
+	c.sent++
+	if len(c.codes) > 0 {
+		code := c.codes[0]
+		c.codes = c.codes[1:]
+		return ldap.NewError(code, fmt.Errorf("result code %d", code))
+	}
+	return c.Directory.Modify(req)
+}
+
+// TestMemberChangeResultCodes checks which result codes adding or removing
+// a member treats as done, retries, or reports.
+func TestMemberChangeResultCodes(t *testing.T) {
+	add := func(ctx context.Context) error {
+		return AddUserToGroup(ctx, "CN=g,OU=lab,DC=test", "CN=u,OU=lab,DC=test")
+	}
+	remove := func(ctx context.Context) error {
+		return RemoveUserFromGroup(ctx, "CN=g,OU=lab,DC=test", "CN=u,OU=lab,DC=test")
+	}
+	cases := []struct {
+		name     string
+		change   func(ctx context.Context) error
+		codes    []uint16
+		retries  int
+		wantErr  bool
+		wantSent int
+	}{
+		{name: "add, value exists", change: add, codes: []uint16{ldap.LDAPResultAttributeOrValueExists}, wantSent: 1},
+		{name: "add, entry exists", change: add, codes: []uint16{ldap.LDAPResultEntryAlreadyExists}, wantSent: 1},
+		{name: "add, no such attribute", change: add, codes: []uint16{ldap.LDAPResultNoSuchAttribute}, wantErr: true, wantSent: 1},
+		{name: "remove, no such attribute", change: remove, codes: []uint16{ldap.LDAPResultNoSuchAttribute}, wantSent: 1},
+		{name: "remove, value exists", change: remove, codes: []uint16{ldap.LDAPResultAttributeOrValueExists}, wantErr: true, wantSent: 1},
+		{name: "add, insufficient access", change: add, codes: []uint16{ldap.LDAPResultInsufficientAccessRights}, retries: 2, wantErr: true, wantSent: 1},
+		{name: "add, busy then done", change: add, codes: []uint16{ldap.LDAPResultBusy}, retries: 1, wantSent: 2},
+		{name: "remove, busy then gone", change: remove, codes: []uint16{ldap.LDAPResultBusy, ldap.LDAPResultNoSuchAttribute}, retries: 1, wantSent: 2},
+		{name: "add, busy past the retries", change: add, codes: []uint16{ldap.LDAPResultBusy, ldap.LDAPResultBusy}, retries: 1, wantErr: true, wantSent: 2},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := ldaptest.New()
+			if err := dir.ReadLDIF(strings.NewReader(retryTestLDIF)); err != nil {
+				t.Fatal(err)
+			}
+			cfg := &config.Config{LDAPModifyRetries: &tc.retries}
+			client := &answeringClient{Directory: dir, codes: tc.codes}
+			ctx := WithClient(context.WithValue(context.Background(), keys.ConfigKey, cfg), client)
+			err := tc.change(ctx)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("error = %v, want error %v", err, tc.wantErr)
+			}
+			if client.sent != tc.wantSent {
+				t.Errorf("sent %d modifies, want %d", client.sent, tc.wantSent)
+			}
+		})
+	}
+}
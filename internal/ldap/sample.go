@@ -0,0 +1,58 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// Sample is one entry found by SampleEntry, with its attributes keyed by
+// lowercased name.
+type Sample struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+// SampleEntry returns one entry under baseDN that filter matches, or false if
+// none does. The server is asked for a single entry, so it is cheap even when
+// many match. A baseDN that doesn't exist is ErrNotFound.
+func SampleEntry(ctx context.Context, baseDN string, filter string, attributes []string) (Sample, bool, error) {
+	l, err := Conn(ctx)
+	if err != nil {
+		return Sample{}, false, err
+	}
+
+	searchRequest := ldap.NewSearchRequest(
+		baseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		1, 0, false,
+		filter,
+		attributes,
+		nil,
+	)
+	sr, err := l.Search(searchRequest)
+	// AD ends a search that hit the size limit with sizeLimitExceeded, along
+	// with the entry that was asked for
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) && sr != nil {
+		err = nil
+	}
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return Sample{}, false, fmt.Errorf("%s %w", baseDN, ErrNotFound)
+		}
+		return Sample{}, false, fmt.Errorf("failed to search LDAP: %w", err)
+	}
+	if len(sr.Entries) == 0 {
+		return Sample{}, false, nil
+	}
+
+	entry := sr.Entries[0]
+	s := Sample{DN: entry.DN, Attributes: make(map[string][]string, len(entry.Attributes))}
+	for _, attr := range entry.Attributes {
+		s.Attributes[strings.ToLower(attr.Name)] = attr.Values
+	}
+	return s, true, nil
+}
@@ -0,0 +1,132 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// Client is the part of an LDAP connection commands use. *ldap.Conn is
+// one; the in-memory directory in ldaptest is another.
+type Client interface {
+	Search(req *ldap.SearchRequest) (*ldap.SearchResult, error)
+	SearchWithPaging(req *ldap.SearchRequest, pagingSize uint32) (*ldap.SearchResult, error)
+	Add(req *ldap.AddRequest) error
+	Modify(req *ldap.ModifyRequest) error
+	ModifyDN(req *ldap.ModifyDNRequest) error
+	Del(req *ldap.DelRequest) error
+	Close() error
+	IsClosing() bool
+}
+
+// TimedConn is the connection commands use. It logs any search or change
+// slower than ldap_slow_op_ms as a warning, to find the operations that get
+// slow as the directory grows, and records every change for Changes. Once
+// ctx, the command's context, is cancelled or past its deadline, requests
+// fail saying so.
+type TimedConn struct {
+	Client
+	ctx     context.Context
+	slow    time.Duration
+	changes *changeLog
+}
+
+// interrupted returns an error wrapping the context's error once the
+// command has been cancelled or run out of time, and nil before then.
+func (c *TimedConn) interrupted() error {
+	ctxErr := c.ctx.Err()
+	if ctxErr == nil {
+		return nil
+	}
+	if errors.Is(ctxErr, context.DeadlineExceeded) {
+		return fmt.Errorf("command timed out: %w", ctxErr)
+	}
+	return fmt.Errorf("command cancelled: %w", ctxErr)
+}
+
+// explain returns the error of a failed request, or the interrupted error
+// in its place, since once the command is interrupted the connection is
+// closed and the request's own error is only a symptom of that.
+func (c *TimedConn) explain(err error) error {
+	if err == nil {
+		return nil
+	}
+	if ierr := c.interrupted(); ierr != nil {
+		return ierr
+	}
+	return err
+}
+
+// logIfSlow warns if the operation started at start took longer than the
+// threshold. args describe the operation.
+func (c *TimedConn) logIfSlow(start time.Time, op string, args ...any) {
+	if c.slow <= 0 {
+		return
+	}
+	if elapsed := time.Since(start); elapsed >= c.slow {
+		slog.Warn("slow LDAP op", append([]any{"op", op, "duration", elapsed}, args...)...)
+	}
+}
+
+func (c *TimedConn) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	if err := c.interrupted(); err != nil {
+		return nil, err
+	}
+	defer c.logIfSlow(time.Now(), "search", "baseDN", req.BaseDN, "filter", req.Filter)
+	sr, err := c.Client.Search(req)
+	return sr, c.explain(err)
+}
+
+// SearchWithPaging is timed as a whole, across every page.
+func (c *TimedConn) SearchWithPaging(req *ldap.SearchRequest, pagingSize uint32) (*ldap.SearchResult, error) {
+	if err := c.interrupted(); err != nil {
+		return nil, err
+	}
+	defer c.logIfSlow(time.Now(), "paged search", "baseDN", req.BaseDN, "filter", req.Filter)
+	sr, err := c.Client.SearchWithPaging(req, pagingSize)
+	return sr, c.explain(err)
+}
+
+func (c *TimedConn) Add(req *ldap.AddRequest) error {
+	if err := c.interrupted(); err != nil {
+		return err
+	}
+	defer c.logIfSlow(time.Now(), "add", "dn", req.DN)
+	err := c.explain(c.Client.Add(req))
+	c.changes.record(Change{Op: "add", DN: req.DN, Err: err})
+	return err
+}
+
+func (c *TimedConn) Modify(req *ldap.ModifyRequest) error {
+	if err := c.interrupted(); err != nil {
+		return err
+	}
+	defer c.logIfSlow(time.Now(), "modify", "dn", req.DN)
+	err := c.explain(c.Client.Modify(req))
+	c.changes.record(Change{Op: "modify", DN: req.DN, Members: memberValues(req), Err: err})
+	return err
+}
+
+func (c *TimedConn) ModifyDN(req *ldap.ModifyDNRequest) error {
+	if err := c.interrupted(); err != nil {
+		return err
+	}
+	defer c.logIfSlow(time.Now(), "rename", "dn", req.DN)
+	err := c.explain(c.Client.ModifyDN(req))
+	c.changes.record(Change{Op: "rename", DN: req.DN, Err: err})
+	return err
+}
+
+func (c *TimedConn) Del(req *ldap.DelRequest) error {
+	if err := c.interrupted(); err != nil {
+		return err
+	}
+	defer c.logIfSlow(time.Now(), "delete", "dn", req.DN)
+	err := c.explain(c.Client.Del(req))
+	c.changes.record(Change{Op: "delete", DN: req.DN, Err: err})
+	return err
+}
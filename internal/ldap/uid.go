@@ -2,7 +2,9 @@ package ldap
 
 import (
 	"context"
+	"encoding/binary"
 	"fmt"
+	"log/slog"
 	"strings"
 
 	"github.com/go-ldap/ldap/v3"
@@ -11,9 +13,8 @@ import (
 )
 
 var (
-	err                   error
-	found                 bool
-	topLevelUsersGroupDN  = "CN=IS.RACS.Talapas.Users,OU=RACS,OU=Groups,OU=IS,OU=Units,DC=ad,DC=uoregon,DC=edu"
+	err   error
+	found bool
 )
 
 // GetUidOfExistingUser looks up the uidNumber (UNIX ID) of a user in AD.
@@ -24,9 +25,9 @@ func GetUidOfExistingUser(ctx context.Context, username string) (string, error)
 		return "", fmt.Errorf("config not found in context")
 	}
 
-	l := ctx.Value(keys.LDAPConnKey).(*ldap.Conn)
-	if l == nil {
-		return "", fmt.Errorf("LDAP connection not found in context")
+	l, err := Conn(ctx)
+	if err != nil {
+		return "", err
 	}
 
 	// Build search request against the Users base DN
@@ -47,17 +48,66 @@ func GetUidOfExistingUser(ctx context.Context, username string) (string, error)
 	}
 
 	if len(sr.Entries) == 0 {
-		return "", fmt.Errorf("user %s not found", username)
+		return "", fmt.Errorf("user %s %w", username, ErrNotFound)
 	}
 
-	// Try uidNumber first
-	uid := sr.Entries[0].GetAttributeValue("uidNumber")
-	if uid == "" {
-		// Fallback: SID
-		uid = sr.Entries[0].GetAttributeValue("objectSid")
+	return uidOrSID(sr.Entries[0].GetAttributeValue("uidNumber"), sr.Entries[0].GetRawAttributeValue("objectSid")), nil
+}
+
+// UserUID is a user's username and UNIX ID.
+type UserUID struct {
+	Username string `json:"username"`
+	UID      string `json:"uid"`
+}
+
+// ResolveUsers looks up the username and UNIX ID of many users at once, in
+// the order given. Users without a uidNumber get their SID instead, as in
+// GetUidOfExistingUser. DNs that aren't found are skipped with a warning.
+func ResolveUsers(ctx context.Context, userDNs []string) ([]UserUID, error) {
+	attrs, err := GetUserAttributes(ctx, userDNs, []string{"sAMAccountName", "uidNumber", "objectSid"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user attributes: %w", err)
+	}
+	users := make([]UserUID, 0, len(userDNs))
+	for _, dn := range userDNs {
+		values, ok := attrs[strings.ToLower(dn)]
+		if !ok {
+			slog.Warn("Member not found, skipping", "dn", dn)
+			continue
+		}
+		users = append(users, UserUID{
+			Username: values["samaccountname"],
+			UID:      uidOrSID(values["uidnumber"], []byte(values["objectsid"])),
+		})
 	}
+	return users, nil
+}
 
-	return uid, nil
+// uidOrSID returns uidNumber, or the string form of the binary objectSid
+// if uidNumber isn't populated.
+func uidOrSID(uidNumber string, objectSid []byte) string {
+	if uidNumber != "" {
+		return uidNumber
+	}
+	return formatSID(objectSid)
+}
+
+// formatSID converts a binary SID to its S-1-5-21-... string form.
+func formatSID(sid []byte) string {
+	if len(sid) < 8 || len(sid) < 8+4*int(sid[1]) {
+		return ""
+	}
+	// The identifier authority is 48-bit big-endian; sub-authorities are 32-bit little-endian.
+	var authority uint64
+	for _, b := range sid[2:8] {
+		authority = authority<<8 | uint64(b)
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "S-%d-%d", sid[0], authority)
+	for i := 0; i < int(sid[1]); i++ {
+		fmt.Fprintf(&sb, "-%d", binary.LittleEndian.Uint32(sid[8+4*i:]))
+	}
+	return sb.String()
 }
 func RemoveUserFromTalapasMaster(ctx context.Context, username string) (string, error) {
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
@@ -65,12 +115,12 @@ func RemoveUserFromTalapasMaster(ctx context.Context, username string) (string,
 		return "", fmt.Errorf("config not found in context")
 	}
 
-	l := ctx.Value(keys.LDAPConnKey).(*ldap.Conn)
-	if l == nil {
-		return "", fmt.Errorf("LDAP connection not found in context")
+	l, err := Conn(ctx)
+	if err != nil {
+		return "", err
 	}
 	// Define the DN for the is.racs.talapas.users group
-	groupDN := topLevelUsersGroupDN
+	groupDN := cfg.UsersGroupDN
 	// grabing the talapasCN for stdout so I can confirm the group that the user was removed from
 	talapasCN := strings.TrimPrefix(strings.SplitN(groupDN, ",", 2)[0], "CN=")
 	// Search for the user DN
@@ -125,12 +175,12 @@ func AddUserToTalapasMaster(ctx context.Context, username string) (string, error
 		return "", fmt.Errorf("config not found in context")
 	}
 
-	l := ctx.Value(keys.LDAPConnKey).(*ldap.Conn)
-	if l == nil {
-		return "", fmt.Errorf("LDAP connection not found in context")
+	l, err := Conn(ctx)
+	if err != nil {
+		return "", err
 	}
 	// Define the DN for the is.racs.talapas.users group
-	groupDN := topLevelUsersGroupDN
+	groupDN := cfg.UsersGroupDN
 
 	// grabing the talapasCN for stdout so I can confirm the group that the user was added to 
 	talapasCN := strings.TrimPrefix(strings.SplitN(groupDN, ",", 2)[0], "CN=")
@@ -160,3 +210,74 @@ func AddUserToTalapasMaster(ctx context.Context, username string) (string, error
 
 	return fmt.Sprintf("Successfully added %s to %s", username, talapasCN), nil
 }
+
+// ReconcileTalapasMaster puts the user's membership of the main Talapas group
+// in line with their managed groups: a user in any PIRG, cephfs, cephs3, or
+// software group is added, and a user in none of them is removed, unless
+// keep_top_level_users_on_last_removal is set. It returns a description of
+// the action taken, or of the action it would take if dryRun is set.
+func ReconcileTalapasMaster(ctx context.Context, username string, dryRun bool) (string, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return "", fmt.Errorf("config not found in context")
+	}
+	userDN, err := GetUserDN(ctx, username)
+	if err != nil {
+		return "", fmt.Errorf("failed to get user DN: %w", err)
+	}
+	groupDNs, err := GetGroupsForUser(ctx, userDN)
+	if err != nil {
+		return "", fmt.Errorf("failed to get groups for user %s: %w", username, err)
+	}
+
+	groupDN := cfg.UsersGroupDN
+	talapasCN := strings.TrimPrefix(strings.SplitN(groupDN, ",", 2)[0], "CN=")
+	inTalapas := false
+	var managed []string
+	for _, dn := range groupDNs {
+		if strings.EqualFold(dn, groupDN) {
+			inTalapas = true
+			continue
+		}
+		name, err := ConvertDNToObjectName(dn)
+		if err != nil {
+			return "", fmt.Errorf("failed to convert DN to group name: %w", err)
+		}
+		if _, ok := ClassifyGroupName(name); ok {
+			managed = append(managed, name)
+		}
+	}
+	slog.Debug("Reconciling main Talapas group membership", "username", username, "inTalapas", inTalapas, "managedGroups", managed)
+
+	verb := ""
+	switch {
+	case len(managed) > 0 && !inTalapas:
+		verb = "add"
+	case len(managed) == 0 && inTalapas && cfg.KeepsTopLevelUsers():
+		// PirgRemoveMember leaves them in too, so don't undo it here
+		return fmt.Sprintf("%s is in no managed groups, keeping them in %s (keep_top_level_users_on_last_removal)", username, talapasCN), nil
+	case len(managed) == 0 && inTalapas:
+		verb = "remove"
+	case inTalapas:
+		return fmt.Sprintf("%s is already in %s (member of %s)", username, talapasCN, strings.Join(managed, ", ")), nil
+	default:
+		return fmt.Sprintf("%s is in no managed groups and not in %s", username, talapasCN), nil
+	}
+
+	if dryRun {
+		if verb == "add" {
+			return fmt.Sprintf("Would add %s to %s (member of %s)", username, talapasCN, strings.Join(managed, ", ")), nil
+		}
+		return fmt.Sprintf("Would remove %s from %s (in no managed groups)", username, talapasCN), nil
+	}
+	if verb == "add" {
+		if err := AddUserToGroup(ctx, groupDN, userDN); err != nil {
+			return "", fmt.Errorf("failed to add user %s to group %s: %w", username, groupDN, err)
+		}
+		return fmt.Sprintf("Added %s to %s (member of %s)", username, talapasCN, strings.Join(managed, ", ")), nil
+	}
+	if err := RemoveUserFromGroup(ctx, groupDN, userDN); err != nil {
+		return "", fmt.Errorf("failed to remove user %s from group %s: %w", username, groupDN, err)
+	}
+	return fmt.Sprintf("Removed %s from %s (in no managed groups)", username, talapasCN), nil
+}
@@ -0,0 +1,58 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+)
+
+// ResolveUsernames looks up the DNs of many users at once, keyed by
+// lowercased username. Usernames are OR-ed together in batches of
+// memberOfBatchSize to keep filters a reasonable size. Usernames that
+// aren't found are missing from the result.
+func ResolveUsernames(ctx context.Context, usernames []string) (map[string]string, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	l, err := Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dns := make(map[string]string, len(usernames))
+	for start := 0; start < len(usernames); start += memberOfBatchSize {
+		end := min(start+memberOfBatchSize, len(usernames))
+		var filter strings.Builder
+		filter.WriteString("(&(objectCategory=person)(|")
+		for _, username := range usernames[start:end] {
+			filter.WriteString(fmt.Sprintf("(sAMAccountName=%s)", ldap.EscapeFilter(username)))
+		}
+		filter.WriteString("))")
+
+		searchRequest := ldap.NewSearchRequest(
+			cfg.LDAPUsersBaseDN,
+			ldap.ScopeWholeSubtree,
+			ldap.NeverDerefAliases,
+			0, 0, false,
+			filter.String(),
+			[]string{"sAMAccountName"},
+			nil,
+		)
+		slog.Debug("Searching LDAP for usernames", "count", end-start)
+
+		sr, err := l.SearchWithPaging(searchRequest, pageSize(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("failed to search LDAP: %w", err)
+		}
+		for _, entry := range sr.Entries {
+			dns[strings.ToLower(entry.GetAttributeValue("sAMAccountName"))] = entry.DN
+		}
+	}
+	return dns, nil
+}
@@ -0,0 +1,155 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Options describes how the default logger should be set up.
+type Options struct {
+	// Format is either "text" or "json".
+	Format string
+	// Level is one of "debug", "info", "warn", or "error".
+	Level string
+	// Debug forces the debug level, regardless of Level.
+	Debug bool
+	// File is an optional path that logs are also written to.
+	File string
+	// MaxFileSize is the size in bytes at which File is rotated.
+	MaxFileSize int64
+	// Output is where logs are written, os.Stderr if nil.
+	Output io.Writer
+}
+
+// ParseLevel converts a level name into a slog.Level.
+// An empty name is treated as "info".
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "debug":
+		return slog.LevelDebug, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q", name)
+}
+
+// NewHandler returns a text or JSON handler writing to w.
+// An empty format is treated as "text".
+func NewHandler(w io.Writer, format string, level slog.Leveler) (slog.Handler, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	switch strings.ToLower(format) {
+	case "", "text":
+		return slog.NewTextHandler(w, opts), nil
+	case "json":
+		return slog.NewJSONHandler(w, opts), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q", format)
+}
+
+// Setup builds a logger from the options and makes it the slog default.
+// The returned closer releases the log file, if any, and is always safe to call.
+func Setup(opts Options) (io.Closer, error) {
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return nopCloser{}, err
+	}
+	if opts.Debug {
+		level = slog.LevelDebug
+	}
+
+	var w io.Writer = os.Stderr
+	if opts.Output != nil {
+		w = opts.Output
+	}
+	var closer io.Closer = nopCloser{}
+	if opts.File != "" {
+		f, err := openRotatingFile(opts.File, opts.MaxFileSize)
+		if err != nil {
+			return nopCloser{}, fmt.Errorf("failed to open log file: %w", err)
+		}
+		w = io.MultiWriter(w, f)
+		closer = f
+	}
+
+	h, err := NewHandler(w, opts.Format, level)
+	if err != nil {
+		closer.Close()
+		return nopCloser{}, err
+	}
+	slog.SetDefault(slog.New(h))
+	return closer, nil
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// rotatingFile is an append-only log file that is moved aside to
+// path.1 once it grows past maxSize bytes. Only one old file is kept.
+type rotatingFile struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	size    int64
+	f       *os.File
+}
+
+func openRotatingFile(path string, maxSize int64) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f = f
+	r.size = info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, fmt.Errorf("failed to rotate log file: %w", err)
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(r.path, r.path+".1"); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
@@ -0,0 +1,173 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	cases := []struct {
+		name string
+		want slog.Level
+	}{
+		{"", slog.LevelInfo},
+		{"info", slog.LevelInfo},
+		{"DEBUG", slog.LevelDebug},
+		{"warn", slog.LevelWarn},
+		{"Warning", slog.LevelWarn},
+		{"error", slog.LevelError},
+	}
+	for _, tc := range cases {
+		got, err := ParseLevel(tc.name)
+		if err != nil {
+			t.Errorf("ParseLevel(%q): %v", tc.name, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("ParseLevel(%q) = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("no error for an unknown level")
+	}
+}
+
+func TestNewHandler(t *testing.T) {
+	for _, format := range []string{"", "text", "TEXT"} {
+		var b bytes.Buffer
+		h, err := NewHandler(&b, format, slog.LevelInfo)
+		if err != nil {
+			t.Fatalf("NewHandler(%q): %v", format, err)
+		}
+		slog.New(h).Info("hello", "user", "alice")
+		if got := b.String(); !strings.Contains(got, "level=INFO msg=hello user=alice") {
+			t.Errorf("format %q logged %q", format, got)
+		}
+	}
+
+	var b bytes.Buffer
+	h, err := NewHandler(&b, "json", slog.LevelWarn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(h)
+	logger.Info("dropped")
+	logger.Warn("kept", "user", "alice")
+	var line map[string]any
+	if err := json.Unmarshal(b.Bytes(), &line); err != nil {
+		t.Fatalf("not a single JSON line: %v\n%s", err, b.String())
+	}
+	if line["level"] != "WARN" || line["msg"] != "kept" || line["user"] != "alice" {
+		t.Errorf("logged %v", line)
+	}
+
+	if _, err := NewHandler(&b, "xml", slog.LevelInfo); err == nil {
+		t.Error("no error for an unknown format")
+	}
+}
+
+// setup calls Setup and restores the default logger after the test.
+func setup(t *testing.T, opts Options) error {
+	t.Helper()
+	previous := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	closer, err := Setup(opts)
+	t.Cleanup(func() { closer.Close() })
+	return err
+}
+
+func TestSetup(t *testing.T) {
+	var b bytes.Buffer
+	if err := setup(t, Options{Level: "error", Debug: true, Output: &b}); err != nil {
+		t.Fatal(err)
+	}
+	slog.Debug("debug message")
+	if !strings.Contains(b.String(), "debug message") {
+		t.Errorf("Debug didn't override the level: %q", b.String())
+	}
+
+	for _, opts := range []Options{{Level: "loud"}, {Format: "xml"}} {
+		if err := setup(t, opts); err == nil {
+			t.Errorf("Setup(%+v) gave no error", opts)
+		}
+	}
+}
+
+func TestSetupFile(t *testing.T) {
+	var b bytes.Buffer
+	path := filepath.Join(t.TempDir(), "dm.log")
+	if err := setup(t, Options{Format: "json", File: path, Output: &b}); err != nil {
+		t.Fatal(err)
+	}
+	slog.Info("to both")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != b.String() || !strings.Contains(string(data), `"msg":"to both"`) {
+		t.Errorf("file has %q, output has %q", data, b.String())
+	}
+
+	if err := setup(t, Options{File: filepath.Join(t.TempDir(), "missing", "dm.log")}); err == nil {
+		t.Error("no error for a log file in a missing directory")
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dm.log")
+	if err := os.WriteFile(path, []byte("old\n"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	r, err := openRotatingFile(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	// "two" would take the file past the limit, so it starts a new one
+	// that "three" exactly fills
+	for _, line := range []string{"one\n", "two\n", "three\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A line bigger than the limit still goes to an empty file
+	if _, err := r.Write([]byte("a long line\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		path + ".1": "two\nthree\n",
+		path:        "a long line\n",
+	}
+	for file, content := range want {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Errorf("%s has %q, want %q", filepath.Base(file), data, content)
+		}
+	}
+}
+
+func TestRotatingFileUnlimited(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dm.log")
+	r, err := openRotatingFile(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for i := 0; i < 100; i++ {
+		if _, err := r.Write([]byte("line\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("rotated with no size limit: %v", err)
+	}
+}
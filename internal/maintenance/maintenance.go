@@ -0,0 +1,108 @@
+// Package maintenance is the tool-wide lock that blocks changes to the
+// directory while it is being worked on, for example during a schema
+// upgrade. The lock is a file under data_path, and can also be mirrored to
+// an attribute in AD so every admin host sees it.
+package maintenance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ClockSkew is how long after its end time a lock still holds, so a host
+// whose clock runs ahead doesn't let changes through early.
+const ClockSkew = 2 * time.Minute
+
+// Lock is a maintenance window.
+type Lock struct {
+	Until   time.Time `json:"until"`
+	Reason  string    `json:"reason"`
+	By      string    `json:"by"`
+	Started time.Time `json:"started"`
+}
+
+// Active reports whether the lock still blocks changes at now.
+func (l *Lock) Active(now time.Time) bool {
+	return l != nil && now.Before(l.Until.Add(ClockSkew))
+}
+
+// Encode returns the lock as the JSON stored in the file and in AD.
+func (l *Lock) Encode() (string, error) {
+	data, err := json.Marshal(l)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode maintenance lock: %w", err)
+	}
+	return string(data), nil
+}
+
+// Decode parses a lock written by Encode. An empty value is no lock.
+func Decode(value string) (*Lock, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var l Lock
+	if err := json.Unmarshal([]byte(value), &l); err != nil {
+		return nil, fmt.Errorf("failed to parse maintenance lock: %w", err)
+	}
+	return &l, nil
+}
+
+// Path returns where the lock file for dataPath lives.
+func Path(dataPath string) string {
+	return filepath.Join(dataPath, "maintenance.json")
+}
+
+// Read returns the lock in the file at path, or nil if there is none.
+func Read(path string) (*Lock, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	l, err := Decode(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return l, nil
+}
+
+// Write saves l to the file at path, replacing it atomically.
+func Write(path string, l *Lock) error {
+	value, err := l.Encode()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create data path: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".maintenance-*")
+	if err != nil {
+		return fmt.Errorf("failed to write maintenance lock: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(value); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write maintenance lock: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write maintenance lock: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace maintenance lock: %w", err)
+	}
+	return nil
+}
+
+// Remove deletes the lock file at path. A missing file is not an error.
+func Remove(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove maintenance lock: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package maintenance
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockActive(t *testing.T) {
+	until := time.Date(2025, 7, 1, 6, 0, 0, 0, time.UTC)
+	l := &Lock{Until: until, Reason: "schema upgrade"}
+	cases := []struct {
+		name string
+		now  time.Time
+		want bool
+	}{
+		{name: "before", now: until.Add(-time.Hour), want: true},
+		{name: "at the end", now: until, want: true},
+		{name: "within the skew", now: until.Add(ClockSkew - time.Second), want: true},
+		{name: "past the skew", now: until.Add(ClockSkew), want: false},
+		{name: "long expired", now: until.Add(24 * time.Hour), want: false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := l.Active(tc.now); got != tc.want {
+				t.Errorf("Active(%s) = %v, want %v", tc.now, got, tc.want)
+			}
+		})
+	}
+	var none *Lock
+	if none.Active(until) {
+		t.Error("nil lock is active")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	if l, err := Decode(""); l != nil || err != nil {
+		t.Errorf("Decode(\"\") = %v, %v, want no lock", l, err)
+	}
+	if _, err := Decode("schema upgrade"); err == nil {
+		t.Error("Decode() of a value that isn't JSON succeeded")
+	}
+}
+
+func TestReadWriteRemove(t *testing.T) {
+	path := Path(filepath.Join(t.TempDir(), "data"))
+	if l, err := Read(path); l != nil || err != nil {
+		t.Fatalf("Read() before Write() = %v, %v, want no lock", l, err)
+	}
+
+	want := &Lock{
+		Until:   time.Date(2025, 7, 1, 6, 0, 0, 0, time.UTC),
+		Reason:  "schema upgrade",
+		By:      "operator",
+		Started: time.Date(2025, 7, 1, 2, 0, 0, 0, time.UTC),
+	}
+	if err := Write(path, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *want {
+		t.Errorf("Read() = %+v, want %+v", got, want)
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("data path holds %d files after Write(), want only the lock", len(entries))
+	}
+
+	if err := Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if l, err := Read(path); l != nil || err != nil {
+		t.Errorf("Read() after Remove() = %v, %v, want no lock", l, err)
+	}
+	if err := Remove(path); err != nil {
+		t.Errorf("Remove() of a missing lock = %v", err)
+	}
+}
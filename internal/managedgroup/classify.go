@@ -0,0 +1,115 @@
+package managedgroup
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// shortNameRegex matches the short names the tool creates groups with.
+var shortNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_\-]+$`)
+
+// Kind is the role a group found under a family's base DN plays.
+type Kind string
+
+const (
+	KindGroup         Kind = "group"
+	KindAdmins        Kind = "admins"
+	KindRole          Kind = "role"
+	KindSubgroup      Kind = "subgroup"
+	KindNonconforming Kind = "nonconforming"
+)
+
+// Classification is how one group fits its family's naming conventions.
+type Classification struct {
+	CN   string `json:"cn"`
+	DN   string `json:"dn"`
+	Kind Kind   `json:"kind"`
+	// Name is the short name of the group the entry belongs to, if known.
+	Name   string `json:"name,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Classify reports whether the group at groupDN, somewhere under baseDN, is a
+// main group, one of its companion groups, a subgroup, or a group that doesn't
+// follow the family's conventions, such as a hand-made group with a dotted
+// name. Main groups live in OU=<name> under baseDN; families without
+// subgroups may also keep them directly under baseDN.
+func (f Family) Classify(baseDN string, groupDN string) Classification {
+	c := Classification{DN: groupDN, Kind: KindNonconforming}
+	nonconforming := func(format string, args ...any) Classification {
+		c.Reason = fmt.Sprintf(format, args...)
+		return c
+	}
+
+	dn, err := ldap.ParseDN(groupDN)
+	if err != nil || len(dn.RDNs) == 0 {
+		return nonconforming("unparseable DN")
+	}
+	c.CN = rdnValue(dn.RDNs[0])
+	base, err := ldap.ParseDN(baseDN)
+	if err != nil || !base.AncestorOfFold(dn) {
+		return nonconforming("not under %s", baseDN)
+	}
+	// The RDNs between the group and the base: CN, then any OUs
+	rdns := dn.RDNs[:len(dn.RDNs)-len(base.RDNs)]
+
+	rest, ok := strings.CutPrefix(strings.ToLower(c.CN), f.Prefix)
+	if !ok {
+		return nonconforming("name does not start with %s", f.Prefix)
+	}
+
+	switch len(rdns) {
+	case 1:
+		if f.SubgroupOU != "" {
+			return nonconforming("not in an OU of its own")
+		}
+		if f.AdminsSuffix != "" {
+			if name, ok := strings.CutSuffix(rest, f.AdminsSuffix); ok && shortNameRegex.MatchString(name) {
+				c.Kind, c.Name = KindAdmins, name
+				return c
+			}
+		}
+		if !shortNameRegex.MatchString(rest) {
+			return nonconforming("short name %q has characters other than letters, digits, _ and -", rest)
+		}
+		c.Kind, c.Name = KindGroup, rest
+		return c
+	case 2:
+		ou := strings.ToLower(rdnValue(rdns[1]))
+		c.Name = ou
+		switch {
+		case rest == ou && shortNameRegex.MatchString(ou):
+			c.Kind = KindGroup
+		case f.AdminsSuffix != "" && rest == ou+f.AdminsSuffix:
+			c.Kind = KindAdmins
+		case f.RoleSuffix != "" && rest == ou+f.RoleSuffix:
+			c.Kind = KindRole
+		default:
+			return nonconforming("name does not match its OU %s", rdnValue(rdns[1]))
+		}
+		return c
+	case 3:
+		ou := strings.ToLower(rdnValue(rdns[2]))
+		c.Name = ou
+		if f.SubgroupOU == "" || !strings.EqualFold(rdnValue(rdns[1]), f.SubgroupOU) {
+			return nonconforming("in an unexpected OU %s", rdnValue(rdns[1]))
+		}
+		sub, ok := strings.CutPrefix(rest, ou+".")
+		if !ok || !shortNameRegex.MatchString(sub) {
+			return nonconforming("subgroup name does not match %s%s.<subgroup>", f.Prefix, ou)
+		}
+		c.Kind = KindSubgroup
+		return c
+	}
+	return nonconforming("nested %d levels below %s", len(rdns)-1, baseDN)
+}
+
+func rdnValue(rdn *ldap.RelativeDN) string {
+	if len(rdn.Attributes) == 0 {
+		return ""
+	}
+	return rdn.Attributes[0].Value
+}
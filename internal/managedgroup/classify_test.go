@@ -0,0 +1,132 @@
+package managedgroup
+
+import "testing"
+
+func TestClassify(t *testing.T) {
+	const pirgs = "OU=PIRGS,DC=test"
+	const software = "OU=SOFTWARE,DC=test"
+	cases := []struct {
+		name   string
+		family Family
+		baseDN string
+		dn     string
+		want   Classification
+	}{
+		{
+			name:   "main group",
+			family: Pirg, baseDN: pirgs,
+			dn:   "CN=is.racs.pirg.alpha,OU=alpha,OU=PIRGS,DC=test",
+			want: Classification{CN: "is.racs.pirg.alpha", Kind: KindGroup, Name: "alpha"},
+		},
+		{
+			name:   "main group in another case and spacing",
+			family: Pirg, baseDN: pirgs,
+			dn:   "cn=IS.RACS.PIRG.Alpha, ou=Alpha, ou=pirgs, dc=test",
+			want: Classification{CN: "IS.RACS.PIRG.Alpha", Kind: KindGroup, Name: "alpha"},
+		},
+		{
+			name:   "admins",
+			family: Pirg, baseDN: pirgs,
+			dn:   "CN=is.racs.pirg.alpha.admins,OU=alpha,OU=PIRGS,DC=test",
+			want: Classification{CN: "is.racs.pirg.alpha.admins", Kind: KindAdmins, Name: "alpha"},
+		},
+		{
+			name:   "role",
+			family: Pirg, baseDN: pirgs,
+			dn:   "CN=is.racs.pirg.alpha.pi,OU=alpha,OU=PIRGS,DC=test",
+			want: Classification{CN: "is.racs.pirg.alpha.pi", Kind: KindRole, Name: "alpha"},
+		},
+		{
+			name:   "cephfs owner",
+			family: Cephfs, baseDN: "OU=CEPHFS,DC=test",
+			dn:   "CN=is.racs.cephfs.lab.owner,OU=lab,OU=CEPHFS,DC=test",
+			want: Classification{CN: "is.racs.cephfs.lab.owner", Kind: KindRole, Name: "lab"},
+		},
+		{
+			name:   "subgroup",
+			family: Pirg, baseDN: pirgs,
+			dn:   "CN=is.racs.pirg.alpha.lab,OU=Groups,OU=alpha,OU=PIRGS,DC=test",
+			want: Classification{CN: "is.racs.pirg.alpha.lab", Kind: KindSubgroup, Name: "alpha"},
+		},
+		{
+			name:   "flat software group",
+			family: Software, baseDN: software,
+			dn:   "CN=is.racs.software.matlab,OU=SOFTWARE,DC=test",
+			want: Classification{CN: "is.racs.software.matlab", Kind: KindGroup, Name: "matlab"},
+		},
+		{
+			name:   "flat software admins",
+			family: Software, baseDN: software,
+			dn:   "CN=is.racs.software.matlab.admins,OU=SOFTWARE,DC=test",
+			want: Classification{CN: "is.racs.software.matlab.admins", Kind: KindAdmins, Name: "matlab"},
+		},
+		{
+			name:   "software group in its own OU",
+			family: Software, baseDN: software,
+			dn:   "CN=is.racs.software.matlab,OU=matlab,OU=SOFTWARE,DC=test",
+			want: Classification{CN: "is.racs.software.matlab", Kind: KindGroup, Name: "matlab"},
+		},
+		{
+			name:   "pirg directly under the base DN",
+			family: Pirg, baseDN: pirgs,
+			dn:   "CN=is.racs.pirg.alpha,OU=PIRGS,DC=test",
+			want: Classification{CN: "is.racs.pirg.alpha", Kind: KindNonconforming, Reason: "not in an OU of its own"},
+		},
+		{
+			name:   "dotted flat software name",
+			family: Software, baseDN: software,
+			dn:   "CN=is.racs.software.a.b,OU=SOFTWARE,DC=test",
+			want: Classification{CN: "is.racs.software.a.b", Kind: KindNonconforming, Reason: `short name "a.b" has characters other than letters, digits, _ and -`},
+		},
+		{
+			name:   "wrong prefix",
+			family: Pirg, baseDN: pirgs,
+			dn:   "CN=handmade,OU=alpha,OU=PIRGS,DC=test",
+			want: Classification{CN: "handmade", Kind: KindNonconforming, Reason: "name does not start with is.racs.pirg."},
+		},
+		{
+			name:   "name not matching its OU",
+			family: Pirg, baseDN: pirgs,
+			dn:   "CN=is.racs.pirg.beta,OU=alpha,OU=PIRGS,DC=test",
+			want: Classification{CN: "is.racs.pirg.beta", Kind: KindNonconforming, Name: "alpha", Reason: "name does not match its OU alpha"},
+		},
+		{
+			name:   "subgroup in an unexpected OU",
+			family: Pirg, baseDN: pirgs,
+			dn:   "CN=is.racs.pirg.alpha.lab,OU=Other,OU=alpha,OU=PIRGS,DC=test",
+			want: Classification{CN: "is.racs.pirg.alpha.lab", Kind: KindNonconforming, Name: "alpha", Reason: "in an unexpected OU Other"},
+		},
+		{
+			name:   "subgroup of another group",
+			family: Pirg, baseDN: pirgs,
+			dn:   "CN=is.racs.pirg.beta.lab,OU=Groups,OU=alpha,OU=PIRGS,DC=test",
+			want: Classification{CN: "is.racs.pirg.beta.lab", Kind: KindNonconforming, Name: "alpha", Reason: "subgroup name does not match is.racs.pirg.alpha.<subgroup>"},
+		},
+		{
+			name:   "nested too deep",
+			family: Pirg, baseDN: pirgs,
+			dn:   "CN=is.racs.pirg.alpha.x,OU=a,OU=Groups,OU=alpha,OU=PIRGS,DC=test",
+			want: Classification{CN: "is.racs.pirg.alpha.x", Kind: KindNonconforming, Reason: "nested 3 levels below OU=PIRGS,DC=test"},
+		},
+		{
+			name:   "outside the base DN",
+			family: Pirg, baseDN: pirgs,
+			dn:   "CN=is.racs.pirg.alpha,OU=alpha,OU=Other,DC=test",
+			want: Classification{CN: "is.racs.pirg.alpha", Kind: KindNonconforming, Reason: "not under OU=PIRGS,DC=test"},
+		},
+		{
+			name:   "unparseable",
+			family: Pirg, baseDN: pirgs,
+			dn:   "not a DN",
+			want: Classification{Kind: KindNonconforming, Reason: "unparseable DN"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.want.DN = tc.dn
+			if got := tc.family.Classify(tc.baseDN, tc.dn); got != tc.want {
+				t.Errorf("Classify() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+// Package managedgroup describes the naming conventions of each family of
+// groups the tool manages. The family packages build names and DNs from
+// these descriptors, and the schema command publishes them, so the two
+// can't drift apart.
+package managedgroup
+
+import "strings"
+
+// Family describes how the groups of one family are named and laid out.
+// A group "foo" in a family is the main group Prefix+"foo", with role
+// groups Prefix+"foo"+AdminsSuffix and Prefix+"foo"+RoleSuffix, and
+// subgroups in OU=SubgroupOU under the group's own OU.
+type Family struct {
+	Name   string
+	Prefix string
+	// AdminsSuffix and RoleSuffix are empty for families without those groups.
+	AdminsSuffix string
+	RoleSuffix   string
+	// RoleName is what the single member of the role group is called.
+	RoleName   string
+	SubgroupOU string
+}
+
+var (
+	Pirg = Family{
+		Name:         "pirg",
+		Prefix:       "is.racs.pirg.",
+		AdminsSuffix: ".admins",
+		RoleSuffix:   ".pi",
+		RoleName:     "PI",
+		SubgroupOU:   "Groups",
+	}
+	Cephfs = Family{
+		Name:         "cephfs",
+		Prefix:       "is.racs.cephfs.",
+		AdminsSuffix: ".admins",
+		RoleSuffix:   ".owner",
+		RoleName:     "owner",
+		SubgroupOU:   "Groups",
+	}
+	Cephs3 = Family{
+		Name:         "cephs3",
+		Prefix:       "is.racs.cephs3.",
+		AdminsSuffix: ".admins",
+		RoleSuffix:   ".owner",
+		RoleName:     "owner",
+		SubgroupOU:   "Groups",
+	}
+	Software = Family{
+		Name:   "software",
+		Prefix: "is.racs.software.",
+		// Optional: legacy software groups have no admins group
+		AdminsSuffix: ".admins",
+	}
+)
+
+// Families lists every managed group family.
+var Families = []Family{Pirg, Cephfs, Cephs3, Software}
+
+// ByName returns the family with the given name.
+func ByName(name string) (Family, bool) {
+	for _, f := range Families {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Family{}, false
+}
+
+// Component is one of the AD groups making up a managed group.
+type Component struct {
+	// Label is "main", "admins", the role ("pi" or "owner"), or "subgroup <name>".
+	Label string `json:"label"`
+	CN    string `json:"cn"`
+	// DN is empty when the group is missing.
+	DN string `json:"dn"`
+}
+
+// RoleLabel is the label of the family's role group, e.g. "pi" or "owner".
+func (f Family) RoleLabel() string {
+	return strings.ToLower(f.RoleName)
+}
+
+// AdminRole is an admin of a group and the role they hold in it.
+type AdminRole struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+// AdminRoles pairs each admin with their role: the family's RoleLabel for
+// roleHolder, who is also an admin, and "admin" for everyone else.
+func (f Family) AdminRoles(admins []string, roleHolder string) []AdminRole {
+	roles := make([]AdminRole, 0, len(admins))
+	for _, admin := range admins {
+		role := "admin"
+		if roleHolder != "" && strings.EqualFold(admin, roleHolder) {
+			role = f.RoleLabel()
+		}
+		roles = append(roles, AdminRole{Username: admin, Role: role})
+	}
+	return roles
+}
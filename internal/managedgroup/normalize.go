@@ -0,0 +1,70 @@
+package managedgroup
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// familyOf returns the family whose prefix name starts with, ignoring case.
+func familyOf(name string) (Family, bool) {
+	lower := strings.ToLower(name)
+	for _, f := range Families {
+		if strings.HasPrefix(lower, f.Prefix) {
+			return f, true
+		}
+	}
+	return Family{}, false
+}
+
+// NormalizeName returns the short name for name, which may also be given as
+// the full group name copied out of AD, e.g. "is.racs.pirg.smithlab". A name
+// with another family's prefix is refused, since it names a group this
+// family's commands can't find.
+func (f Family) NormalizeName(name string) (string, error) {
+	other, ok := familyOf(name)
+	if !ok {
+		return name, nil
+	}
+	if other.Name != f.Name {
+		return "", fmt.Errorf("%s looks like a %s group, use the %s command", name, other.Name, other.Name)
+	}
+	short := name[len(f.Prefix):]
+	slog.Debug("Normalized full group name", "family", f.Name, "name", name, "shortName", short)
+	return short, nil
+}
+
+// NormalizeSubgroupName returns the short name of a subgroup of the group
+// with short name groupName, which may also be given as the subgroup's full
+// name, e.g. "is.racs.pirg.smithlab.students". A full name belonging to
+// another group or family is refused.
+func (f Family) NormalizeSubgroupName(groupName string, subgroup string) (string, error) {
+	short, err := f.NormalizeName(subgroup)
+	if err != nil {
+		return "", err
+	}
+	if short == subgroup {
+		return subgroup, nil
+	}
+	owner, sub, ok := strings.Cut(short, ".")
+	if !ok {
+		return "", fmt.Errorf("%s is a %s group, not a subgroup", subgroup, f.Name)
+	}
+	if !strings.EqualFold(owner, groupName) {
+		return "", fmt.Errorf("%s is a subgroup of %s, not %s", subgroup, owner, groupName)
+	}
+	slog.Debug("Normalized full subgroup name", "family", f.Name, "name", subgroup, "shortName", sub)
+	return sub, nil
+}
+
+// StripAnyPrefix returns name without the prefix of whichever family it
+// starts with, for options that take a short name shared across families.
+func StripAnyPrefix(name string) string {
+	f, ok := familyOf(name)
+	if !ok {
+		return name
+	}
+	short := name[len(f.Prefix):]
+	slog.Debug("Normalized full group name", "family", f.Name, "name", name, "shortName", short)
+	return short
+}
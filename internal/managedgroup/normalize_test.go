@@ -0,0 +1,85 @@
+package managedgroup
+
+import "testing"
+
+func TestNormalizeName(t *testing.T) {
+	cases := []struct {
+		family  Family
+		name    string
+		want    string
+		wantErr string
+	}{
+		{family: Pirg, name: "smithlab", want: "smithlab"},
+		{family: Pirg, name: "is.racs.pirg.smithlab", want: "smithlab"},
+		{family: Pirg, name: "IS.RACS.PIRG.SmithLab", want: "SmithLab"},
+		{family: Pirg, name: "is.racs.pirg.smithlab.students", want: "smithlab.students"},
+		{family: Cephfs, name: "is.racs.cephfs.lab", want: "lab"},
+		{family: Software, name: "is.racs.software.matlab", want: "matlab"},
+		{family: Pirg, name: "", want: ""},
+		{family: Pirg, name: "is.racs.pirgsmith", want: "is.racs.pirgsmith"},
+		{family: Pirg, name: "is.racs.cephfs.lab", wantErr: "is.racs.cephfs.lab looks like a cephfs group, use the cephfs command"},
+		{family: Cephs3, name: "IS.RACS.SOFTWARE.matlab", wantErr: "IS.RACS.SOFTWARE.matlab looks like a software group, use the software command"},
+	}
+	for _, tc := range cases {
+		got, err := tc.family.NormalizeName(tc.name)
+		if tc.wantErr != "" {
+			if err == nil || err.Error() != tc.wantErr {
+				t.Errorf("%s NormalizeName(%q) = %q, %v, want error %q", tc.family.Name, tc.name, got, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s NormalizeName(%q): %v", tc.family.Name, tc.name, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%s NormalizeName(%q) = %q, want %q", tc.family.Name, tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestNormalizeSubgroupName(t *testing.T) {
+	cases := []struct {
+		group    string
+		subgroup string
+		want     string
+		wantErr  string
+	}{
+		{group: "smithlab", subgroup: "students", want: "students"},
+		{group: "smithlab", subgroup: "is.racs.pirg.smithlab.students", want: "students"},
+		{group: "SmithLab", subgroup: "is.racs.pirg.smithlab.students", want: "students"},
+		{group: "smithlab", subgroup: "is.racs.pirg.smithlab", wantErr: "is.racs.pirg.smithlab is a pirg group, not a subgroup"},
+		{group: "smithlab", subgroup: "is.racs.pirg.jones.students", wantErr: "is.racs.pirg.jones.students is a subgroup of jones, not smithlab"},
+		{group: "smithlab", subgroup: "is.racs.cephfs.smithlab.students", wantErr: "is.racs.cephfs.smithlab.students looks like a cephfs group, use the cephfs command"},
+	}
+	for _, tc := range cases {
+		got, err := Pirg.NormalizeSubgroupName(tc.group, tc.subgroup)
+		if tc.wantErr != "" {
+			if err == nil || err.Error() != tc.wantErr {
+				t.Errorf("NormalizeSubgroupName(%q, %q) = %q, %v, want error %q", tc.group, tc.subgroup, got, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("NormalizeSubgroupName(%q, %q): %v", tc.group, tc.subgroup, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("NormalizeSubgroupName(%q, %q) = %q, want %q", tc.group, tc.subgroup, got, tc.want)
+		}
+	}
+}
+
+func TestStripAnyPrefix(t *testing.T) {
+	cases := map[string]string{
+		"smithlab":                "smithlab",
+		"is.racs.pirg.smithlab":   "smithlab",
+		"IS.RACS.CEPHS3.smithlab": "smithlab",
+		"is.racs.software.matlab": "matlab",
+	}
+	for name, want := range cases {
+		if got := StripAnyPrefix(name); got != want {
+			t.Errorf("StripAnyPrefix(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
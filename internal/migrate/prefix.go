@@ -0,0 +1,227 @@
+// Package migrate renames the groups of a managed group family in bulk.
+package migrate
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/managedgroup"
+)
+
+// prefixRegex matches the prefixes groups can be given: dot-separated
+// words ending in a dot, like "is.racs.pirg.".
+var prefixRegex = regexp.MustCompile(`^([a-zA-Z0-9_\-]+\.)+$`)
+
+// Rename is one group whose CN and sAMAccountName change prefix.
+type Rename struct {
+	Kind    managedgroup.Kind `json:"kind"`
+	OldName string            `json:"old_name"`
+	NewName string            `json:"new_name"`
+	// DN is where the group is now, under its old name unless Done.
+	DN string `json:"dn"`
+	// Done is set for groups that already have the new name, such as ones
+	// renamed by an earlier run that was interrupted.
+	Done bool `json:"done"`
+}
+
+// DescriptionUpdate is a group description that mentions the old prefix.
+type DescriptionUpdate struct {
+	DN  string `json:"dn"`
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// PrefixPlan is everything a prefix migration of one family changes.
+type PrefixPlan struct {
+	Family       string              `json:"family"`
+	From         string              `json:"from"`
+	To           string              `json:"to"`
+	Renames      []Rename            `json:"renames"`
+	Descriptions []DescriptionUpdate `json:"descriptions"`
+	// Skipped are groups with the old prefix that don't follow the family's
+	// conventions. They are left alone.
+	Skipped []managedgroup.Classification `json:"skipped"`
+	// Conflicts are renames that would take a name another group already
+	// has. The migration refuses to run while there are any.
+	Conflicts []string `json:"conflicts"`
+}
+
+// Pending returns the renames that haven't been made yet.
+func (p *PrefixPlan) Pending() []Rename {
+	var pending []Rename
+	for _, r := range p.Renames {
+		if !r.Done {
+			pending = append(pending, r)
+		}
+	}
+	return pending
+}
+
+// domainDN returns the DC= components at the end of dn, which name the
+// domain it is in.
+func domainDN(dn string) (string, error) {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse DN %s: %w", dn, err)
+	}
+	n := 0
+	for i := len(parsed.RDNs) - 1; i >= 0; i-- {
+		rdn := parsed.RDNs[i]
+		if len(rdn.Attributes) != 1 || !strings.EqualFold(rdn.Attributes[0].Type, "DC") {
+			break
+		}
+		n++
+	}
+	if n == 0 {
+		return "", fmt.Errorf("no domain components in %s", dn)
+	}
+	parts := strings.Split(dn, ",")
+	return strings.Join(parts[len(parts)-n:], ","), nil
+}
+
+// PlanPrefix finds every group of family whose name starts with from and
+// follows the family's conventions, which includes the admins and role
+// groups and the subgroups, and plans renaming it to start with to instead.
+// Groups already named with to are included as done, so an interrupted
+// migration can be planned again and finished. New names are checked
+// against every group in the domain, since sAMAccountName must be unique
+// across it.
+func PlanPrefix(ctx context.Context, family managedgroup.Family, from string, to string) (*PrefixPlan, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	from, to = strings.ToLower(from), strings.ToLower(to)
+	for _, prefix := range []string{from, to} {
+		if !prefixRegex.MatchString(prefix) {
+			return nil, fmt.Errorf("invalid prefix %q, it must be dot-separated words ending in a dot", prefix)
+		}
+	}
+	if strings.HasPrefix(from, to) || strings.HasPrefix(to, from) {
+		return nil, fmt.Errorf("prefixes %s and %s overlap, so renamed groups would still match the old one", from, to)
+	}
+
+	baseDN := cfg.BaseDN(family.Name)
+	domain, err := domainDN(baseDN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find domain of %s: %w", baseDN, err)
+	}
+	oldGroups, err := ld.GetGroupsWithNamePrefix(ctx, baseDN, from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find groups named %s*: %w", from, err)
+	}
+	newGroups, err := ld.GetGroupsWithNamePrefix(ctx, domain, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find groups named %s*: %w", to, err)
+	}
+	taken := make(map[string]string)
+	for _, g := range newGroups {
+		taken[strings.ToLower(g.CN)] = g.DN
+		taken[strings.ToLower(g.SAMAccountName)] = g.DN
+	}
+
+	plan := &PrefixPlan{Family: family.Name, From: from, To: to}
+	oldFamily, newFamily := family, family
+	oldFamily.Prefix, newFamily.Prefix = from, to
+	oldNames := make(map[string]bool)
+	for _, g := range oldGroups {
+		c := oldFamily.Classify(baseDN, g.DN)
+		if c.Kind == managedgroup.KindNonconforming {
+			plan.Skipped = append(plan.Skipped, c)
+			continue
+		}
+		oldNames[strings.ToLower(g.CN)] = true
+		newName := to + g.CN[len(from):]
+		if dn, ok := taken[strings.ToLower(newName)]; ok {
+			plan.Conflicts = append(plan.Conflicts, fmt.Sprintf("%s would be renamed to %s, which %s already has", g.CN, newName, dn))
+			continue
+		}
+		plan.Renames = append(plan.Renames, Rename{Kind: c.Kind, OldName: g.CN, NewName: newName, DN: g.DN})
+	}
+	for _, g := range newGroups {
+		c := newFamily.Classify(baseDN, g.DN)
+		if c.Kind == managedgroup.KindNonconforming {
+			continue
+		}
+		oldName := from + g.CN[len(to):]
+		if oldNames[strings.ToLower(oldName)] {
+			// Already reported as a conflict
+			continue
+		}
+		plan.Renames = append(plan.Renames, Rename{Kind: c.Kind, OldName: oldName, NewName: g.CN, DN: g.DN, Done: true})
+	}
+	slices.SortFunc(plan.Renames, func(a, b Rename) int {
+		return strings.Compare(strings.ToLower(a.OldName), strings.ToLower(b.OldName))
+	})
+
+	// managedBy holds DNs, which AD keeps pointing at renamed groups by
+	// itself. Descriptions are free text, so those are rewritten.
+	described, err := ld.GetGroupsWithDescriptionContaining(ctx, baseDN, from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find descriptions mentioning %s: %w", from, err)
+	}
+	fromRegex := regexp.MustCompile("(?i)" + regexp.QuoteMeta(from))
+	for _, g := range described {
+		plan.Descriptions = append(plan.Descriptions, DescriptionUpdate{
+			DN:  g.DN,
+			Old: g.Description,
+			New: fromRegex.ReplaceAllLiteralString(g.Description, to),
+		})
+	}
+	slog.Debug("Planned prefix migration", "family", family.Name, "renames", len(plan.Renames), "pending", len(plan.Pending()),
+		"descriptions", len(plan.Descriptions), "skipped", len(plan.Skipped), "conflicts", len(plan.Conflicts))
+	return plan, nil
+}
+
+// ApplyPrefix makes the changes in plan, marking each rename done as it
+// goes. Descriptions are updated first, while their groups still have the
+// DNs the plan found. Renaming keeps each group's gidNumber and members,
+// since it only changes the CN and sAMAccountName.
+func ApplyPrefix(ctx context.Context, plan *PrefixPlan) error {
+	if len(plan.Conflicts) > 0 {
+		return fmt.Errorf("%d groups would take names other groups have: %w", len(plan.Conflicts), ld.ErrAlreadyExists)
+	}
+	for _, d := range plan.Descriptions {
+		err := ld.SetGroupDescription(ctx, d.DN, d.New)
+		if err != nil {
+			return fmt.Errorf("failed to update description: %w", err)
+		}
+	}
+	for i, r := range plan.Renames {
+		if r.Done {
+			continue
+		}
+		err := ld.RenameGroup(ctx, r.DN, r.NewName)
+		if err != nil {
+			return fmt.Errorf("failed to rename %s: %w", r.OldName, err)
+		}
+		plan.Renames[i].Done = true
+		slog.Info("Renamed group", "oldName", r.OldName, "newName", r.NewName)
+	}
+	return nil
+}
+
+// WriteMapping writes the old and new name of every renamed group in plan
+// as CSV with a header row, for systems that refer to groups by name.
+func WriteMapping(w io.Writer, plan *PrefixPlan) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"family", "kind", "old_name", "new_name"})
+	for _, r := range plan.Renames {
+		if !r.Done {
+			continue
+		}
+		cw.Write([]string{plan.Family, string(r.Kind), r.OldName, r.NewName})
+	}
+	cw.Flush()
+	return cw.Error()
+}
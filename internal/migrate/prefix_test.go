@@ -0,0 +1,224 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/ldap/ldaptest"
+	"github.com/uoracs/directory-manager/internal/managedgroup"
+)
+
+const (
+	bioDN       = "CN=old.pirg.bio,OU=bio,OU=PIRGS,DC=test"
+	bioAdminsDN = "CN=old.pirg.bio.admins,OU=bio,OU=PIRGS,DC=test"
+	bioPIDN     = "CN=old.pirg.bio.pi,OU=bio,OU=PIRGS,DC=test"
+	bioWetDN    = "CN=old.pirg.bio.wet,OU=Groups,OU=bio,OU=PIRGS,DC=test"
+	chemDN      = "CN=new.pirg.chem,OU=chem,OU=PIRGS,DC=test"
+	handMadeDN  = "CN=old.pirg.handmade,OU=PIRGS,DC=test"
+)
+
+// group returns the LDIF of a group named cn at dn.
+func group(dn string, cn string, description string) string {
+	s := fmt.Sprintf("dn: %s\nobjectClass: group\ncn: %s\nsAMAccountName: %s\n", dn, cn, cn)
+	if description != "" {
+		s += "description: " + description + "\n"
+	}
+	return s + "\n"
+}
+
+func ou(dn string) string {
+	return "dn: " + dn + "\nobjectClass: organizationalUnit\n\n"
+}
+
+// prefixTestContext returns a context using a directory with PIRG bio
+// under the prefix old.pirg., with its admins and PI groups and subgroup
+// wet, PIRG chem already renamed to new.pirg., and a hand-made group that
+// doesn't follow the conventions. extra is appended to the LDIF.
+func prefixTestContext(t *testing.T, extra string) (context.Context, *ldaptest.Directory) {
+	t.Helper()
+	ldif := "dn: DC=test\nobjectClass: domain\n\n" +
+		ou("OU=PIRGS,DC=test") + ou("OU=bio,OU=PIRGS,DC=test") + ou("OU=Groups,OU=bio,OU=PIRGS,DC=test") + ou("OU=chem,OU=PIRGS,DC=test") +
+		group(bioDN, "old.pirg.bio", "Bio lab; admins are in OLD.PIRG.bio.admins") +
+		group(bioAdminsDN, "old.pirg.bio.admins", "") +
+		group(bioPIDN, "old.pirg.bio.pi", "") +
+		group(bioWetDN, "old.pirg.bio.wet", "") +
+		group(chemDN, "new.pirg.chem", "") +
+		group(handMadeDN, "old.pirg.handmade", "") +
+		extra
+	dir := ldaptest.New()
+	if err := dir.ReadLDIF(strings.NewReader(ldif)); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{LDAPPirgDN: "OU=PIRGS,DC=test"}
+	ctx := context.WithValue(context.Background(), keys.ConfigKey, cfg)
+	return ld.WithClient(ctx, dir), dir
+}
+
+func TestPlanPrefix(t *testing.T) {
+	ctx, _ := prefixTestContext(t, "")
+	plan, err := PlanPrefix(ctx, managedgroup.Pirg, "old.pirg.", "New.Pirg.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.From != "old.pirg." || plan.To != "new.pirg." {
+		t.Errorf("prefixes %q and %q, want them lowercased", plan.From, plan.To)
+	}
+	want := []Rename{
+		{Kind: managedgroup.KindGroup, OldName: "old.pirg.bio", NewName: "new.pirg.bio", DN: bioDN},
+		{Kind: managedgroup.KindAdmins, OldName: "old.pirg.bio.admins", NewName: "new.pirg.bio.admins", DN: bioAdminsDN},
+		{Kind: managedgroup.KindRole, OldName: "old.pirg.bio.pi", NewName: "new.pirg.bio.pi", DN: bioPIDN},
+		{Kind: managedgroup.KindSubgroup, OldName: "old.pirg.bio.wet", NewName: "new.pirg.bio.wet", DN: bioWetDN},
+		{Kind: managedgroup.KindGroup, OldName: "old.pirg.chem", NewName: "new.pirg.chem", DN: chemDN, Done: true},
+	}
+	if len(plan.Renames) != len(want) {
+		t.Fatalf("renames = %+v, want %+v", plan.Renames, want)
+	}
+	for i := range want {
+		if plan.Renames[i] != want[i] {
+			t.Errorf("rename %d = %+v, want %+v", i, plan.Renames[i], want[i])
+		}
+	}
+	if got := len(plan.Pending()); got != 4 {
+		t.Errorf("%d pending renames, want 4", got)
+	}
+	if len(plan.Skipped) != 1 || plan.Skipped[0].DN != handMadeDN {
+		t.Errorf("skipped = %+v, want only the hand-made group", plan.Skipped)
+	}
+	if len(plan.Conflicts) != 0 {
+		t.Errorf("conflicts = %v", plan.Conflicts)
+	}
+	wantDescription := DescriptionUpdate{DN: bioDN, Old: "Bio lab; admins are in OLD.PIRG.bio.admins", New: "Bio lab; admins are in new.pirg.bio.admins"}
+	if len(plan.Descriptions) != 1 || plan.Descriptions[0] != wantDescription {
+		t.Errorf("descriptions = %+v, want %+v", plan.Descriptions, wantDescription)
+	}
+}
+
+func TestPlanPrefixInvalid(t *testing.T) {
+	ctx, _ := prefixTestContext(t, "")
+	for _, tc := range []struct{ from, to string }{
+		{"old.pirg", "new.pirg."},
+		{"old.pirg.", "new pirg."},
+		{"old.pirg.", "old.pirg.v2."},
+	} {
+		if _, err := PlanPrefix(ctx, managedgroup.Pirg, tc.from, tc.to); err == nil {
+			t.Errorf("PlanPrefix(%q, %q) succeeded", tc.from, tc.to)
+		}
+	}
+}
+
+func TestPrefixConflict(t *testing.T) {
+	// Anywhere in the domain, since sAMAccountName is unique across it
+	ctx, dir := prefixTestContext(t, ou("OU=Other,DC=test")+group("CN=new.pirg.bio.admins,OU=Other,DC=test", "new.pirg.bio.admins", ""))
+	plan, err := PlanPrefix(ctx, managedgroup.Pirg, "old.pirg.", "new.pirg.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Conflicts) != 1 || !strings.Contains(plan.Conflicts[0], "OU=Other,DC=test") {
+		t.Fatalf("conflicts = %v, want the admins group's", plan.Conflicts)
+	}
+	for _, r := range plan.Renames {
+		if r.OldName == "old.pirg.bio.admins" {
+			t.Errorf("conflicting group planned as a rename: %+v", r)
+		}
+	}
+	if err := ApplyPrefix(ctx, plan); !errors.Is(err, ld.ErrAlreadyExists) {
+		t.Errorf("ApplyPrefix error = %v, want %v", err, ld.ErrAlreadyExists)
+	}
+	if !dir.Exists(bioDN) || dir.Values(bioDN, "description")[0] != "Bio lab; admins are in OLD.PIRG.bio.admins" {
+		t.Error("ApplyPrefix changed the directory despite a conflict")
+	}
+}
+
+func TestApplyPrefix(t *testing.T) {
+	ctx, dir := prefixTestContext(t, "")
+	plan, err := PlanPrefix(ctx, managedgroup.Pirg, "old.pirg.", "new.pirg.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyPrefix(ctx, plan); err != nil {
+		t.Fatal(err)
+	}
+	assertRenamed(t, dir)
+	for _, r := range plan.Renames {
+		if !r.Done {
+			t.Errorf("rename not marked done: %+v", r)
+		}
+	}
+	var mapping strings.Builder
+	if err := WriteMapping(&mapping, plan); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(mapping.String(), "pirg,subgroup,old.pirg.bio.wet,new.pirg.bio.wet\n") {
+		t.Errorf("mapping missing the subgroup:\n%s", mapping.String())
+	}
+}
+
+// TestApplyPrefixResumes checks that a migration interrupted after some
+// renames is planned again with those done, and finished.
+func TestApplyPrefixResumes(t *testing.T) {
+	ctx, dir := prefixTestContext(t, "")
+	if err := ld.RenameGroup(ctx, bioAdminsDN, "new.pirg.bio.admins"); err != nil {
+		t.Fatal(err)
+	}
+	plan, err := PlanPrefix(ctx, managedgroup.Pirg, "old.pirg.", "new.pirg.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Conflicts) != 0 {
+		t.Fatalf("conflicts = %v", plan.Conflicts)
+	}
+	pending := plan.Pending()
+	if len(pending) != 3 {
+		t.Fatalf("pending = %+v, want bio, its PI group, and wet", pending)
+	}
+	for _, r := range pending {
+		if r.OldName == "old.pirg.bio.admins" {
+			t.Errorf("renamed group planned again: %+v", r)
+		}
+	}
+	if err := ApplyPrefix(ctx, plan); err != nil {
+		t.Fatal(err)
+	}
+	assertRenamed(t, dir)
+}
+
+// assertRenamed checks that every conforming group in the test directory
+// has the new prefix, in its CN and sAMAccountName, and that bio's
+// description was rewritten.
+func assertRenamed(t *testing.T, dir *ldaptest.Directory) {
+	t.Helper()
+	for _, dn := range []string{
+		"CN=new.pirg.bio,OU=bio,OU=PIRGS,DC=test",
+		"CN=new.pirg.bio.admins,OU=bio,OU=PIRGS,DC=test",
+		"CN=new.pirg.bio.pi,OU=bio,OU=PIRGS,DC=test",
+		"CN=new.pirg.bio.wet,OU=Groups,OU=bio,OU=PIRGS,DC=test",
+		chemDN,
+	} {
+		if !dir.Exists(dn) {
+			t.Errorf("%s missing", dn)
+			continue
+		}
+		cn, _ := ld.ConvertDNToObjectName(dn)
+		if got := dir.Values(dn, "sAMAccountName"); len(got) != 1 || got[0] != cn {
+			t.Errorf("sAMAccountName of %s = %v, want %s", dn, got, cn)
+		}
+	}
+	for _, dn := range []string{bioDN, bioAdminsDN, bioPIDN, bioWetDN} {
+		if dir.Exists(dn) {
+			t.Errorf("%s still exists", dn)
+		}
+	}
+	if !dir.Exists(handMadeDN) {
+		t.Error("hand-made group renamed")
+	}
+	got := dir.Values("CN=new.pirg.bio,OU=bio,OU=PIRGS,DC=test", "description")
+	if len(got) != 1 || got[0] != "Bio lab; admins are in new.pirg.bio.admins" {
+		t.Errorf("description = %v", got)
+	}
+}
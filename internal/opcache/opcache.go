@@ -0,0 +1,239 @@
+// Package opcache remembers membership changes that recently succeeded, so
+// configuration management re-running the same command every few minutes
+// can confirm it with one membership check instead of the full operation.
+package opcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Direction is whether an operation put the member in the group or took
+// them out.
+type Direction string
+
+const (
+	Add    Direction = "add"
+	Remove Direction = "remove"
+)
+
+// Op identifies one membership change. Group is qualified by family, e.g.
+// "pirg/smithlab", and Role is the membership changed, e.g. "member".
+type Op struct {
+	Role      string
+	Group     string
+	Member    string
+	Direction Direction
+}
+
+// key hashes the operation, so the store doesn't grow with long names.
+func (o Op) key() string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		o.Role, strings.ToLower(o.Group), strings.ToLower(o.Member), string(o.Direction),
+	}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Opposite returns the operation that undoes o.
+func (o Op) Opposite() Op {
+	if o.Direction == Add {
+		o.Direction = Remove
+	} else {
+		o.Direction = Add
+	}
+	return o
+}
+
+// Entry is a recorded operation, with the DNs needed to verify it.
+type Entry struct {
+	Group     string    `json:"group"`
+	Member    string    `json:"member"`
+	Direction Direction `json:"direction"`
+	GroupDN   string    `json:"group_dn"`
+	UserDN    string    `json:"user_dn"`
+	At        time.Time `json:"at"`
+}
+
+// Store is the set of recorded operations, kept as JSON in one file.
+type Store struct {
+	path    string
+	entries map[string]Entry
+	// changed holds each entry recorded since Open, or nil for one
+	// dropped, and invalidated the groups dropped, for Save to apply to
+	// whatever other runs saved in the meantime.
+	changed     map[string]*Entry
+	invalidated map[string]bool
+}
+
+// Path returns where the store for dataPath lives.
+func Path(dataPath string) string {
+	return filepath.Join(dataPath, "opcache.json")
+}
+
+// Open reads the store at path. A missing file is an empty store.
+func Open(path string) (*Store, error) {
+	entries, err := read(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{path: path, entries: entries, changed: make(map[string]*Entry), invalidated: make(map[string]bool)}, nil
+}
+
+// read returns the entries saved at path, or none if there is no file.
+func read(path string) (map[string]Entry, error) {
+	entries := make(map[string]Entry)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// Lookup returns the entry for op if it was recorded less than ttl before now.
+func (s *Store) Lookup(op Op, ttl time.Duration, now time.Time) (Entry, bool) {
+	e, ok := s.entries[op.key()]
+	if !ok || now.Sub(e.At) >= ttl {
+		return Entry{}, false
+	}
+	return e, true
+}
+
+// Record notes that op succeeded at now. It first drops the opposite
+// operation on the same member, which op has just undone.
+func (s *Store) Record(op Op, groupDN string, userDN string, now time.Time) {
+	s.Forget(op.Opposite())
+	e := Entry{
+		Group:     strings.ToLower(op.Group),
+		Member:    strings.ToLower(op.Member),
+		Direction: op.Direction,
+		GroupDN:   groupDN,
+		UserDN:    userDN,
+		At:        now,
+	}
+	s.entries[op.key()] = e
+	s.changed[op.key()] = &e
+}
+
+// Forget drops the entry for op, if any.
+func (s *Store) Forget(op Op) {
+	delete(s.entries, op.key())
+	s.changed[op.key()] = nil
+}
+
+// InvalidateGroup drops every entry for group, after a change to it that
+// could have undone any of them.
+func (s *Store) InvalidateGroup(group string) {
+	group = strings.ToLower(group)
+	s.invalidated[group] = true
+	for key, e := range s.entries {
+		if e.Group == group {
+			delete(s.entries, key)
+			s.changed[key] = nil
+			slog.Debug("Invalidated cached operation", "group", group, "member", e.Member, "direction", e.Direction)
+		}
+	}
+}
+
+// Save writes the store back if it changed, dropping entries older than
+// ttl. Other runs may have saved since Open, so the file is read again
+// under an exclusive lock and only this run's changes are applied to it.
+// The file is replaced atomically so a run reading without the lock never
+// sees half of it.
+func (s *Store) Save(ttl time.Duration, now time.Time) error {
+	expired := false
+	for _, e := range s.entries {
+		if now.Sub(e.At) >= ttl {
+			expired = true
+			break
+		}
+	}
+	if len(s.changed) == 0 && len(s.invalidated) == 0 && !expired {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create data path: %w", err)
+	}
+	unlock, err := lock(s.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries, err := read(s.path)
+	if err != nil {
+		return err
+	}
+	for key, e := range entries {
+		if s.invalidated[e.Group] {
+			delete(entries, key)
+		}
+	}
+	for key, e := range s.changed {
+		if e == nil {
+			delete(entries, key)
+		} else {
+			entries[key] = *e
+		}
+	}
+	for key, e := range entries {
+		if now.Sub(e.At) >= ttl {
+			delete(entries, key)
+		}
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("failed to encode operation cache: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".opcache-*")
+	if err != nil {
+		return fmt.Errorf("failed to write operation cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write operation cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write operation cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace operation cache: %w", err)
+	}
+	s.entries = entries
+	clear(s.changed)
+	clear(s.invalidated)
+	return nil
+}
+
+// lock takes an exclusive lock on the file at path, creating it, and
+// returns the function that releases it. It waits for another run holding
+// the lock, which only ever does so for one read and write.
+func lock(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open operation cache lock: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock operation cache: %w", err)
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
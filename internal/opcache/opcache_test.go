@@ -0,0 +1,134 @@
+package opcache
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const ttl = 10 * time.Minute
+
+var (
+	now    = time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	addBob = Op{Role: "member", Group: "pirg/alpha", Member: "bob", Direction: Add}
+)
+
+func openTemp(t *testing.T) (*Store, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "opcache.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, path
+}
+
+func reopen(t *testing.T, path string) *Store {
+	t.Helper()
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestLookup(t *testing.T) {
+	s, path := openTemp(t)
+	if _, ok := s.Lookup(addBob, ttl, now); ok {
+		t.Error("hit in an empty store")
+	}
+	s.Record(addBob, "CN=alpha", "CN=bob", now)
+	if err := s.Save(ttl, now); err != nil {
+		t.Fatal(err)
+	}
+
+	s = reopen(t, path)
+	e, ok := s.Lookup(Op{Role: "member", Group: "PIRG/Alpha", Member: "BOB", Direction: Add}, ttl, now.Add(time.Minute))
+	if !ok {
+		t.Fatal("miss for an operation recorded a minute ago, in another case")
+	}
+	if e.GroupDN != "CN=alpha" || e.UserDN != "CN=bob" {
+		t.Errorf("entry = %+v, want the recorded DNs", e)
+	}
+	if _, ok := s.Lookup(addBob.Opposite(), ttl, now); ok {
+		t.Error("hit for the opposite operation")
+	}
+	if _, ok := s.Lookup(addBob, ttl, now.Add(ttl)); ok {
+		t.Error("hit for an operation as old as the TTL")
+	}
+}
+
+func TestRecordDropsOpposite(t *testing.T) {
+	s, _ := openTemp(t)
+	s.Record(addBob, "CN=alpha", "CN=bob", now)
+	s.Record(addBob.Opposite(), "CN=alpha", "CN=bob", now)
+	if _, ok := s.Lookup(addBob, ttl, now); ok {
+		t.Error("add still cached after the member was removed")
+	}
+	if _, ok := s.Lookup(addBob.Opposite(), ttl, now); !ok {
+		t.Error("remove not cached")
+	}
+}
+
+func TestInvalidateGroup(t *testing.T) {
+	s, path := openTemp(t)
+	addCarol := Op{Role: "member", Group: "pirg/gamma", Member: "carol", Direction: Add}
+	s.Record(addBob, "CN=alpha", "CN=bob", now)
+	s.Record(addCarol, "CN=gamma", "CN=carol", now)
+	if err := s.Save(ttl, now); err != nil {
+		t.Fatal(err)
+	}
+
+	s = reopen(t, path)
+	s.InvalidateGroup("PIRG/alpha")
+	if err := s.Save(ttl, now); err != nil {
+		t.Fatal(err)
+	}
+	s = reopen(t, path)
+	if _, ok := s.Lookup(addBob, ttl, now); ok {
+		t.Error("invalidated group still cached")
+	}
+	if _, ok := s.Lookup(addCarol, ttl, now); !ok {
+		t.Error("other group's entry dropped")
+	}
+}
+
+func TestSaveMergesConcurrentRuns(t *testing.T) {
+	first, path := openTemp(t)
+	second := reopen(t, path)
+	removeBob := Op{Role: "member", Group: "pirg/gamma", Member: "bob", Direction: Remove}
+	first.Record(addBob, "CN=alpha", "CN=bob", now)
+	second.Record(removeBob, "CN=gamma", "CN=bob", now)
+	if err := first.Save(ttl, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Save(ttl, now); err != nil {
+		t.Fatal(err)
+	}
+
+	s := reopen(t, path)
+	if _, ok := s.Lookup(addBob, ttl, now); !ok {
+		t.Error("the first run's entry was lost when the second saved")
+	}
+	if _, ok := s.Lookup(removeBob, ttl, now); !ok {
+		t.Error("the second run's entry was lost")
+	}
+}
+
+func TestSaveInvalidatesEntriesSavedMeanwhile(t *testing.T) {
+	first, path := openTemp(t)
+	second := reopen(t, path)
+	first.Record(addBob, "CN=alpha", "CN=bob", now)
+	if err := first.Save(ttl, now); err != nil {
+		t.Fatal(err)
+	}
+	// The second run never saw the first's entry, but its change to the
+	// group could have undone it
+	second.InvalidateGroup("pirg/alpha")
+	if err := second.Save(ttl, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopen(t, path).Lookup(addBob, ttl, now); ok {
+		t.Error("entry saved by another run survived invalidating its group")
+	}
+}
@@ -0,0 +1,126 @@
+package pirg
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/managedgroup"
+)
+
+// Contact is the PI or an admin of a PIRG, with what's needed to reach them.
+type Contact struct {
+	Pirg        string `json:"pirg"`
+	Role        string `json:"role"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	Mail        string `json:"mail"`
+}
+
+// contactRoles maps the kind of a PIRG's companion group to the role its
+// members are listed with.
+var contactRoles = map[managedgroup.Kind]string{
+	managedgroup.KindRole:   "pi",
+	managedgroup.KindAdmins: "admin",
+}
+
+// PirgContacts returns the PI and admins of the PIRGs with the given names,
+// or of every PIRG if names is empty, sorted by PIRG with the PI first. The
+// PI and admins groups are read in one subtree search, and each distinct
+// user is then looked up once, however many PIRGs they are in. Contacts
+// without a mail attribute are returned with Mail empty.
+func PirgContacts(ctx context.Context, names []string) ([]Contact, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	f := managedgroup.Pirg
+
+	var filter strings.Builder
+	filter.WriteString("(&(objectClass=group)(|")
+	if len(names) == 0 {
+		prefix := ldap.EscapeFilter(groupPrefix)
+		fmt.Fprintf(&filter, "(cn=%s*%s)(cn=%s*%s)", prefix, ldap.EscapeFilter(f.RoleSuffix), prefix, ldap.EscapeFilter(f.AdminsSuffix))
+	}
+	for _, name := range names {
+		for _, suffix := range []string{f.RoleSuffix, f.AdminsSuffix} {
+			fmt.Fprintf(&filter, "(cn=%s)", ldap.EscapeFilter(groupPrefix+name+suffix))
+		}
+	}
+	filter.WriteString("))")
+
+	groups, err := ld.GetMembersOfGroupsInOU(ctx, cfg.LDAPPirgDN, filter.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PI and admins groups: %w", err)
+	}
+
+	type membership struct {
+		pirg, role, userDN string
+	}
+	var memberships []membership
+	found := make(map[string]bool)
+	seen := make(map[string]bool)
+	var userDNs []string
+	for groupDN, members := range groups {
+		c := f.Classify(cfg.LDAPPirgDN, groupDN)
+		role, ok := contactRoles[c.Kind]
+		if !ok {
+			slog.Debug("Skipping group that isn't a PIRG's PI or admins group", "groupDN", groupDN, "kind", c.Kind)
+			continue
+		}
+		found[c.Name] = true
+		for _, memberDN := range members {
+			memberships = append(memberships, membership{pirg: c.Name, role: role, userDN: memberDN})
+			if key := strings.ToLower(memberDN); !seen[key] {
+				seen[key] = true
+				userDNs = append(userDNs, memberDN)
+			}
+		}
+	}
+	for _, name := range names {
+		if !found[strings.ToLower(name)] {
+			return nil, fmt.Errorf("PIRG %s %w", name, ld.ErrNotFound)
+		}
+	}
+	slog.Debug("Resolving PIRG contacts", "memberships", len(memberships), "users", len(userDNs))
+
+	users, err := ld.GetUserAttributes(ctx, userDNs, []string{"sAMAccountName", "displayName", "mail"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get contact attributes: %w", err)
+	}
+	contacts := make([]Contact, 0, len(memberships))
+	for _, m := range memberships {
+		values, ok := users[strings.ToLower(m.userDN)]
+		if !ok {
+			slog.Warn("Skipping PIRG contact that isn't a user", "pirg", m.pirg, "role", m.role, "memberDN", m.userDN)
+			continue
+		}
+		contacts = append(contacts, Contact{
+			Pirg:        m.pirg,
+			Role:        m.role,
+			Username:    values["samaccountname"],
+			DisplayName: values["displayname"],
+			Mail:        values["mail"],
+		})
+	}
+	slices.SortFunc(contacts, func(a, b Contact) int {
+		if c := strings.Compare(a.Pirg, b.Pirg); c != 0 {
+			return c
+		}
+		// "pi" sorts after "admin", but the PI comes first
+		if a.Role != b.Role {
+			if a.Role == "pi" {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Username, b.Username)
+	})
+	return contacts, nil
+}
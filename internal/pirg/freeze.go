@@ -0,0 +1,79 @@
+package pirg
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+)
+
+// checkNotFrozen refuses changes to a frozen PIRG, unless overridden.
+func checkNotFrozen(ctx context.Context, name string) error {
+	groupDN, err := getPIRGDN(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get PIRG DN: %w", err)
+	}
+	return ld.CheckNotFrozen(ctx, groupDN)
+}
+
+// PirgFreeze freezes the PIRG with the given name, so changes to it are
+// refused until PirgUnfreeze.
+func PirgFreeze(ctx context.Context, name string, f ld.Freeze) error {
+	groupDN, err := getPIRGDN(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get PIRG DN: %w", err)
+	}
+	return ld.FreezeGroup(ctx, groupDN, f)
+}
+
+// PirgUnfreeze lets changes to the PIRG with the given name through again.
+func PirgUnfreeze(ctx context.Context, name string) error {
+	groupDN, err := getPIRGDN(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get PIRG DN: %w", err)
+	}
+	return ld.UnfreezeGroup(ctx, groupDN)
+}
+
+// PirgGetFreeze returns the freeze on the PIRG with the given name, if it
+// is frozen.
+func PirgGetFreeze(ctx context.Context, name string) (ld.Freeze, bool, error) {
+	groupDN, err := getPIRGDN(ctx, name)
+	if err != nil {
+		return ld.Freeze{}, false, fmt.Errorf("failed to get PIRG DN: %w", err)
+	}
+	return ld.GetFreeze(ctx, groupDN)
+}
+
+// PirgListFrozen returns the freeze of every frozen PIRG, keyed by short
+// name.
+func PirgListFrozen(ctx context.Context) (map[string]ld.Freeze, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	groups, err := ld.GetFrozenGroupsInOU(ctx, cfg.LDAPPirgDN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get frozen PIRG groups: %w", err)
+	}
+	nameRegex, err := pirgGroupNameRegex(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PIRG group name regex: %w", err)
+	}
+	re := regexp.MustCompile(nameRegex)
+	frozen := make(map[string]ld.Freeze, len(groups))
+	for groupName, f := range groups {
+		if !re.MatchString(groupName) {
+			continue
+		}
+		shortName, err := ConvertPIRGGroupNametoShortName(groupName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert PIRG group name to short name: %w", err)
+		}
+		frozen[shortName] = f
+	}
+	return frozen, nil
+}
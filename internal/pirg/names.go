@@ -0,0 +1,66 @@
+package pirg
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/uoracs/directory-manager/internal/cephfs"
+	"github.com/uoracs/directory-manager/internal/cephs3"
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/software"
+)
+
+// otherNamespaces are the families a new PIRG's short name is checked
+// against under enforce_name_uniqueness, with the functions listing them.
+var otherNamespaces = []struct {
+	name string
+	list func(context.Context) ([]string, error)
+}{
+	{"cephfs", cephfs.CephfsList},
+	{"cephs3", cephs3.Cephs3List},
+	{"software", software.SoftwareList},
+}
+
+// nameTakenIn returns the first enabled namespace other than PIRGs that
+// already has a group with the given short name, ignoring case, or "" if
+// none does.
+func nameTakenIn(ctx context.Context, name string) (string, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return "", fmt.Errorf("config not found in context")
+	}
+	for _, ns := range otherNamespaces {
+		if cfg.DisabledSubsystem(ns.name) != "" {
+			continue
+		}
+		names, err := ns.list(ctx)
+		if err != nil {
+			return "", fmt.Errorf("failed to list %s groups: %w", ns.name, err)
+		}
+		if slices.ContainsFunc(names, func(n string) bool { return strings.EqualFold(n, name) }) {
+			return ns.name, nil
+		}
+	}
+	return "", nil
+}
+
+// checkNameUnique refuses a new PIRG whose short name another namespace
+// already uses, when enforce_name_uniqueness is set, so that names like
+// "bio" mean one thing across PIRGs, cephfs, cephs3, and software groups.
+func checkNameUnique(ctx context.Context, cfg *config.Config, name string) error {
+	if !cfg.NameUniquenessEnforced() {
+		return nil
+	}
+	namespace, err := nameTakenIn(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to check name uniqueness: %w", err)
+	}
+	if namespace != "" {
+		return fmt.Errorf("%s is already the name of a %s group and enforce_name_uniqueness is set: %w", name, namespace, ld.ErrPolicy)
+	}
+	return nil
+}
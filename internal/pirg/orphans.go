@@ -0,0 +1,43 @@
+package pirg
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/managedgroup"
+)
+
+// PirgListOrphans returns the OUs under the PIRG base DN with no PIRG
+// group in them, with any groups left inside.
+func PirgListOrphans(ctx context.Context) ([]ld.OrphanOU, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	orphans, err := ld.FindOrphanOUs(ctx, managedgroup.Pirg, cfg.LDAPPirgDN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find orphan PIRG OUs: %w", err)
+	}
+	return orphans, nil
+}
+
+// PirgDeleteOrphan deletes the OU of the PIRG with the given name, which
+// must have no PIRG group, refusing with ld.ErrNotEmpty if anything but
+// OUs is left inside.
+func PirgDeleteOrphan(ctx context.Context, name string) error {
+	dn, found, err := findPIRGDN(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to find PIRG DN: %w", err)
+	}
+	if found {
+		return fmt.Errorf("PIRG %s exists at %s, its OU is not an orphan", name, dn)
+	}
+	ouDN, err := getPIRGOUDN(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get PIRG OU DN: %w", err)
+	}
+	return ld.DeleteEmptyOU(ctx, ouDN)
+}
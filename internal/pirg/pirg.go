@@ -2,23 +2,26 @@ package pirg
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"regexp"
 	"slices"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/consistency"
 	"github.com/uoracs/directory-manager/internal/keys"
 	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/managedgroup"
 )
 
 var (
-	err                   error
-	found                 bool
-	groupPrefix           = "is.racs.pirg."
-	topLevelUsersGroupDN  = "CN=IS.RACS.Talapas.Users,OU=RACS,OU=Groups,OU=IS,OU=Units,DC=ad,DC=uoregon,DC=edu"
-	topLevelAdminsGroupDN = "CN=IS.RACS.Talapas.PirgAdmins,OU=RACS,OU=Groups,OU=IS,OU=Units,DC=ad,DC=uoregon,DC=edu"
+	err         error
+	found       bool
+	groupPrefix = managedgroup.Pirg.Prefix
 )
 
 func ConvertPIRGGroupNametoShortName(pirgName string) (string, error) {
@@ -63,7 +66,7 @@ func getPIRGAdminsGroupFullName(ctx context.Context, pirgName string) (string, e
 	if cfg == nil {
 		return "", fmt.Errorf("config not found in context")
 	}
-	n := fmt.Sprintf("%s%s.admins", groupPrefix, pirgName)
+	n := groupPrefix + pirgName + managedgroup.Pirg.AdminsSuffix
 	slog.Debug("PIRG admins group full name", "name", n)
 	return n, nil
 }
@@ -74,7 +77,7 @@ func getPIRGPIGroupFullName(ctx context.Context, pirgName string) (string, error
 	if cfg == nil {
 		return "", fmt.Errorf("config not found in context")
 	}
-	n := fmt.Sprintf("%s%s.pi", groupPrefix, pirgName)
+	n := groupPrefix + pirgName + managedgroup.Pirg.RoleSuffix
 	slog.Debug("PIRG PI group full name", "name", n)
 	return n, nil
 }
@@ -86,7 +89,7 @@ func getUserDN(ctx context.Context, name string) (string, error) {
 		return "", fmt.Errorf("failed to get user DN: %w", err)
 	}
 	if dn == "" {
-		return "", fmt.Errorf("user %s not found", name)
+		return "", fmt.Errorf("user %s %w", name, ld.ErrNotFound)
 	}
 	slog.Debug("User DN", "dn", dn)
 	return dn, nil
@@ -103,7 +106,7 @@ func getPIRGSubgroupOUDN(ctx context.Context, pirgName string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("failed to get PIRG DN: %w", err)
 	}
-	n := fmt.Sprintf("OU=Groups,%s", pirgDN)
+	n := fmt.Sprintf("OU=%s,%s", managedgroup.Pirg.SubgroupOU, pirgDN)
 	slog.Debug("PIRG subgroup OU DN", "dn", n)
 	return n, nil
 }
@@ -143,16 +146,26 @@ func getPIRGDN(ctx context.Context, name string) (string, error) {
 	return n, nil
 }
 
+// PirgGroupDN returns the DistinguishedName of the main group of the PIRG
+// with the given name, without checking that it exists.
+func PirgGroupDN(ctx context.Context, name string) (string, error) {
+	return getPIRGDN(ctx, name)
+}
+
 // findPIRGDN returns the DistinguishedName of the PIRG with the given name.
 // includes a check if the group exists.
 // if not found, it returns an empty string, false, and nil
 func findPIRGDN(ctx context.Context, name string) (string, bool, error) {
 	slog.Debug("Finding PIRG DN", "name", name)
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return "", false, fmt.Errorf("config not found in context")
+	}
 	groupName, err := getPIRGFullName(ctx, name)
 	if err != nil {
 		return "", false, fmt.Errorf("failed to get PIRG full name: %w", err)
 	}
-	dn, found, err := ld.GetGroupDN(ctx, groupName)
+	dn, found, err := ld.GetGroupDN(ctx, cfg.LDAPPirgDN, groupName)
 	if !found && err == nil {
 		slog.Debug("PIRG not found", "name", name)
 		return "", false, nil
@@ -265,7 +278,7 @@ func getAllPIRGDNs(ctx context.Context) ([]string, error) {
 	for _, groupName := range allGroupNamesInPIRGsOU {
 		slog.Debug("Checking group name", "groupName", groupName)
 		if matched, _ := regexp.MatchString(pirgGroupNameRegex, groupName); matched {
-			pirgDN, found, err := ld.GetGroupDN(ctx, groupName)
+			pirgDN, found, err := ld.GetGroupDN(ctx, cfg.LDAPPirgDN, groupName)
 			if err != nil {
 				return nil, fmt.Errorf("failed to get group DN: %w", err)
 			}
@@ -281,23 +294,29 @@ func getAllPIRGDNs(ctx context.Context) ([]string, error) {
 // addUserToTopLevelUsersGroup adds a user to the top level users group.
 func addUserToTopLevelUsersGroup(ctx context.Context, member string) error {
 	slog.Debug("Adding user to top level users group", "member", member)
-	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
-	if cfg == nil {
-		return fmt.Errorf("config not found in context")
-	}
 	userDN, err := getUserDN(ctx, member)
 	if err != nil {
 		return fmt.Errorf("failed to get user DN: %w", err)
 	}
-	inGroup, err := ld.UserInGroup(ctx, topLevelUsersGroupDN, userDN)
+	return addUserDNToTopLevelUsersGroup(ctx, member, userDN)
+}
+
+// addUserDNToTopLevelUsersGroup is addUserToTopLevelUsersGroup for a user
+// whose DN is already known.
+func addUserDNToTopLevelUsersGroup(ctx context.Context, member string, userDN string) error {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return fmt.Errorf("config not found in context")
+	}
+	inGroup, err := ld.UserInGroup(ctx, cfg.UsersGroupDN, userDN)
 	if err != nil {
 		return fmt.Errorf("failed to check if user is in group: %w", err)
 	}
 	if inGroup {
-		slog.Debug("User already in top level users group", "userDN", userDN, "topLevelUsersGroupDN", topLevelUsersGroupDN)
+		slog.Debug("User already in top level users group", "userDN", userDN, "topLevelUsersGroupDN", cfg.UsersGroupDN)
 		return nil
 	}
-	err = ld.AddUserToGroup(ctx, topLevelUsersGroupDN, userDN)
+	err = ld.AddUserToGroup(ctx, cfg.UsersGroupDN, userDN)
 	if err != nil {
 		return fmt.Errorf("failed to add user %s to users group: %w", member, err)
 	}
@@ -316,15 +335,15 @@ func addUsertoTopLevelAdminsGroup(ctx context.Context, member string) error {
 	if err != nil {
 		return fmt.Errorf("failed to get user DN: %w", err)
 	}
-	inGroup, err := ld.UserInGroup(ctx, topLevelAdminsGroupDN, userDN)
+	inGroup, err := ld.UserInGroup(ctx, cfg.PirgAdminsGroupDN, userDN)
 	if err != nil {
 		return fmt.Errorf("failed to check if user is in group: %w", err)
 	}
 	if inGroup {
-		slog.Debug("User already in top level admins group", "userDN", userDN, "topLevelAdminsGroupDN", topLevelAdminsGroupDN)
+		slog.Debug("User already in top level admins group", "userDN", userDN, "topLevelAdminsGroupDN", cfg.PirgAdminsGroupDN)
 		return nil
 	}
-	err = ld.AddUserToGroup(ctx, topLevelAdminsGroupDN, userDN)
+	err = ld.AddUserToGroup(ctx, cfg.PirgAdminsGroupDN, userDN)
 	if err != nil {
 		return fmt.Errorf("failed to add user %s to admins group: %w", member, err)
 	}
@@ -343,15 +362,15 @@ func removeUserFromTopLevelUsersGroup(ctx context.Context, member string) error
 	if err != nil {
 		return fmt.Errorf("failed to get user DN: %w", err)
 	}
-	inGroup, err := ld.UserInGroup(ctx, topLevelUsersGroupDN, userDN)
+	inGroup, err := ld.UserInGroup(ctx, cfg.UsersGroupDN, userDN)
 	if err != nil {
 		return fmt.Errorf("failed to check if user is in group: %w", err)
 	}
 	if !inGroup {
-		slog.Debug("User not in top level users group", "userDN", userDN, "topLevelUsersGroupDN", topLevelUsersGroupDN)
+		slog.Debug("User not in top level users group", "userDN", userDN, "topLevelUsersGroupDN", cfg.UsersGroupDN)
 		return nil
 	}
-	err = ld.RemoveUserFromGroup(ctx, topLevelUsersGroupDN, userDN)
+	err = ld.RemoveUserFromGroup(ctx, cfg.UsersGroupDN, userDN)
 	if err != nil {
 		return fmt.Errorf("failed to remove user %s from users group: %w", member, err)
 	}
@@ -370,15 +389,15 @@ func removeUserFromTopLevelAdminsGroup(ctx context.Context, member string) error
 	if err != nil {
 		return fmt.Errorf("failed to get user DN: %w", err)
 	}
-	inGroup, err := ld.UserInGroup(ctx, topLevelAdminsGroupDN, userDN)
+	inGroup, err := ld.UserInGroup(ctx, cfg.PirgAdminsGroupDN, userDN)
 	if err != nil {
 		return fmt.Errorf("failed to check if user is in group: %w", err)
 	}
 	if !inGroup {
-		slog.Debug("User not in top level admins group", "userDN", userDN, "topLevelAdminsGroupDN", topLevelAdminsGroupDN)
+		slog.Debug("User not in top level admins group", "userDN", userDN, "topLevelAdminsGroupDN", cfg.PirgAdminsGroupDN)
 		return nil
 	}
-	err = ld.RemoveUserFromGroup(ctx, topLevelAdminsGroupDN, userDN)
+	err = ld.RemoveUserFromGroup(ctx, cfg.PirgAdminsGroupDN, userDN)
 	if err != nil {
 		return fmt.Errorf("failed to remove user %s from admins group: %w", member, err)
 	}
@@ -386,33 +405,62 @@ func removeUserFromTopLevelAdminsGroup(ctx context.Context, member string) error
 	return nil
 }
 
-// userInAnyPIRG checks if the user is in any PIRG.
-func userInAnyPIRG(ctx context.Context, username string) (bool, error) {
-	slog.Debug("Checking if user is in any PIRG", "username", username)
+// ListUserPirgs returns the short names of the PIRGs the user is a member of,
+// derived from their memberOf entries. Admins, PI, and subgroups are not counted.
+func ListUserPirgs(ctx context.Context, username string) ([]string, error) {
+	slog.Debug("Listing PIRGs for user", "username", username)
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
 	if cfg == nil {
-		return false, fmt.Errorf("config not found in context")
+		return nil, fmt.Errorf("config not found in context")
 	}
 	userDN, err := getUserDN(ctx, username)
 	if err != nil {
-		return false, fmt.Errorf("failed to get user DN: %w", err)
+		return nil, fmt.Errorf("failed to get user DN: %w", err)
 	}
 	userGroups, err := ld.GetGroupsForUser(ctx, userDN)
 	if err != nil {
-		return false, fmt.Errorf("failed to get user groups: %w", err)
+		return nil, fmt.Errorf("failed to get user groups: %w", err)
 	}
+	var pirgNames []string
 	for _, groupDN := range userGroups {
 		groupName, err := ld.ConvertDNToObjectName(groupDN)
 		if err != nil {
-			return false, fmt.Errorf("failed to convert DN to object name: %w", err)
+			return nil, fmt.Errorf("failed to convert DN to object name: %w", err)
 		}
-		if strings.HasPrefix(groupName, groupPrefix) {
-			slog.Debug("User found in some PIRG", "userDN", userDN, "groupDN", groupDN)
-			return true, nil
+		namespace, ok := ld.ClassifyGroupName(groupName)
+		if !ok || namespace != "pirg" {
+			continue
 		}
+		pirgName := strings.TrimPrefix(strings.ToLower(groupName), groupPrefix)
+		if strings.Contains(pirgName, ".") {
+			// this is admins, pi, or a subgroup
+			continue
+		}
+		pirgNames = append(pirgNames, pirgName)
 	}
-	slog.Debug("User not found in any PIRG")
-	return false, nil
+	slices.Sort(pirgNames)
+	slog.Debug("User PIRGs", "username", username, "pirgs", pirgNames)
+	return pirgNames, nil
+}
+
+// userInAnyPIRG checks if the user is in any PIRG.
+func userInAnyPIRG(ctx context.Context, username string) (bool, error) {
+	pirgNames, err := ListUserPirgs(ctx, username)
+	if err != nil {
+		return false, err
+	}
+	return len(pirgNames) > 0, nil
+}
+
+// PirgUserOtherPIRGs returns the PIRGs, other than the named one, that the user is in.
+func PirgUserOtherPIRGs(ctx context.Context, pirgName string, username string) ([]string, error) {
+	pirgNames, err := ListUserPirgs(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PIRGs for user %s: %w", username, err)
+	}
+	return slices.DeleteFunc(pirgNames, func(n string) bool {
+		return strings.EqualFold(n, pirgName)
+	}), nil
 }
 
 // userIsAdminInAnyPIRG checks if the user is an admin in any PIRG.
@@ -487,8 +535,10 @@ func PirgExists(ctx context.Context, name string) (bool, error) {
 	return true, nil
 }
 
-func PirgCreate(ctx context.Context, pirgName string, piUsername string) error {
-	slog.Debug("Creating PIRG", "name", pirgName, "pi", piUsername)
+// PirgCreate creates the PIRG OU structure and groups and sets the PI.
+// If mail is not empty, the main PIRG group is mail-enabled with that address.
+func PirgCreate(ctx context.Context, pirgName string, piUsername string, mail string, noMember bool) error {
+	slog.Debug("Creating PIRG", "name", pirgName, "pi", piUsername, "mail", mail, "noMember", noMember)
 
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
 	if cfg == nil {
@@ -505,10 +555,54 @@ func PirgCreate(ctx context.Context, pirgName string, piUsername string) error {
 		return fmt.Errorf("failed to find PIRG DN: %w", err)
 	}
 
-	// Get the starting gidNumber, we'll increment locally
-	// for each group we create
-	// TODO: use the prod version: ld.GetNextGidNumber
-	gidNumber, err := ld.GetNextGidNumber(ctx)
+	// Check the name, PI, and mail address before creating anything
+	err = checkNameUnique(ctx, cfg, pirgName)
+	if err != nil {
+		return err
+	}
+	err = checkPIEligibility(ctx, piUsername)
+	if err != nil {
+		return err
+	}
+	if noMember {
+		// PirgAddMember checks the account otherwise
+		piDN, err := getUserDN(ctx, piUsername)
+		if err != nil {
+			return fmt.Errorf("failed to get pi DN: %w", err)
+		}
+		err = ld.CheckAccountActive(ctx, piUsername, piDN)
+		if err != nil {
+			return err
+		}
+	}
+	var groupOpts []ld.GroupOption
+	if mail != "" {
+		err = ld.ValidateMailAddress(mail)
+		if err != nil {
+			return err
+		}
+		inUse, err := ld.MailAddressInUse(ctx, mail, "")
+		if err != nil {
+			return fmt.Errorf("failed to check mail address: %w", err)
+		}
+		if inUse {
+			return fmt.Errorf("mail address %s %w", mail, ld.ErrAlreadyExists)
+		}
+		groupOpts = append(groupOpts, ld.WithMail(mail))
+	}
+
+	// Reuse an empty OU left by an earlier failed create
+	ouDN, err := getPIRGOUDN(ctx, pirgName)
+	if err != nil {
+		return fmt.Errorf("failed to get PIRG OU DN: %w", err)
+	}
+	err = ld.AdoptOrphanOU(ctx, ouDN, "pirg orphans clean")
+	if err != nil {
+		return err
+	}
+
+	// Reserve a gidNumber for each of the three groups we create
+	gidNumber, err := ld.AllocateGids(ctx, "pirg", 3)
 	if err != nil {
 		return fmt.Errorf("failed to get next GID number: %w", err)
 	}
@@ -542,7 +636,7 @@ func PirgCreate(ctx context.Context, pirgName string, piUsername string) error {
 		return fmt.Errorf("failed to get PIRG full name: %w", err)
 	}
 	slog.Debug("PIRG group name", "pirgName", pirgFullName)
-	err = ld.CreateGroup(ctx, pirgOUDN, pirgFullName, gidNumber)
+	err = ld.CreateGroup(ctx, pirgOUDN, pirgFullName, gidNumber, groupOpts...)
 	if err != nil {
 		return fmt.Errorf("failed to create PIRG group object: %w", err)
 	}
@@ -572,44 +666,165 @@ func PirgCreate(ctx context.Context, pirgName string, piUsername string) error {
 	}
 	slog.Debug("Created PIRG PI group object", "pirgPIGroupName", pirgPIGroupFullName)
 
-	// Add the PI to the PIRG group
-	err = PirgAddMember(ctx, pirgName, piUsername)
+	// Add the PI to the PIRG PI and admins groups, and unless noMember is
+	// set, to the PIRG group itself
+	err = setPI(ctx, pirgName, piUsername, !noMember)
+	if err != nil {
+		return fmt.Errorf("failed to add PI user %s to PIRG PI group %s: %w", piUsername, pirgName, err)
+	}
+	slog.Debug("Added PI to PIRG PI and admins groups", "piUsername", piUsername, "pirgName", pirgName, "member", !noMember)
+
+
+	return nil
+}
+
+// PirgMailAddress renders the configured pirg_mail_template for the PIRG
+// with the given name.
+func PirgMailAddress(ctx context.Context, pirgName string) (string, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return "", fmt.Errorf("config not found in context")
+	}
+	if cfg.PirgMailTemplate == "" {
+		return "", fmt.Errorf("pirg_mail_template is not configured")
+	}
+	tmpl, err := template.New("pirg_mail_template").Option("missingkey=error").Parse(cfg.PirgMailTemplate)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse pirg_mail_template: %w", err)
+	}
+	var b strings.Builder
+	err = tmpl.Execute(&b, struct{ Name string }{Name: pirgName})
 	if err != nil {
-		return fmt.Errorf("failed to add PI user %s to PIRG %s: %w", piUsername, pirgName, err)
+		return "", fmt.Errorf("failed to render pirg_mail_template: %w", err)
 	}
-	slog.Debug("Added PI to PIRG group", "piUsername", piUsername, "pirgName", pirgName)
+	slog.Debug("PIRG mail address", "pirgName", pirgName, "mail", b.String())
+	return b.String(), nil
+}
 
-	// Add the PI to the PIRG PI group
-	err = PirgSetPI(ctx, pirgName, piUsername)
+// PirgGetMail returns the mail address of the PIRG group, or an empty
+// string if it is not mail-enabled.
+func PirgGetMail(ctx context.Context, pirgName string) (string, error) {
+	pirgDN, err := getPIRGDN(ctx, pirgName)
 	if err != nil {
-		return fmt.Errorf("failed to add PI user %s to PIRG PI group %s: %w", piUsername, pirgName, err)
+		return "", fmt.Errorf("failed to get PIRG DN: %w", err)
+	}
+	mail, err := ld.GetGroupMail(ctx, pirgDN)
+	if err != nil {
+		return "", fmt.Errorf("failed to get PIRG mail: %w", err)
 	}
-	slog.Debug("Added PI to PIRG PI group", "piUsername", piUsername, "pirgName", pirgName)
+	return mail, nil
+}
 
-	// Add the PI to the PIRG admins group
-	err = PirgAddAdmin(ctx, pirgName, piUsername)
+// PirgSetMail sets the primary mail address of the PIRG group.
+// An empty address clears it.
+func PirgSetMail(ctx context.Context, pirgName string, mail string) error {
+	slog.Debug("Setting PIRG mail", "pirgName", pirgName, "mail", mail)
+	if err := checkNotFrozen(ctx, pirgName); err != nil {
+		return err
+	}
+	pirgDN, err := getPIRGDN(ctx, pirgName)
+	if err != nil {
+		return fmt.Errorf("failed to get PIRG DN: %w", err)
+	}
+	err = ld.SetGroupMail(ctx, pirgDN, mail)
 	if err != nil {
-		return fmt.Errorf("failed to add PI user %s to PIRG admins group %s: %w", piUsername, pirgName, err)
+		return fmt.Errorf("failed to set PIRG mail: %w", err)
 	}
-	slog.Debug("Added PI to PIRG admins group", "piUsername", piUsername, "pirgName", pirgName)
+	return nil
+}
+
+// SubgroupMembers is a subgroup with the usernames of its members.
+type SubgroupMembers struct {
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
+}
 
+// DeleteBlockers is what stands in the way of deleting a PIRG: its members
+// other than the PI, and its subgroups that still have members. The PI is
+// reported for reference, since deleting takes them out too.
+type DeleteBlockers struct {
+	PI        string            `json:"pi,omitempty"`
+	Members   []string          `json:"members"`
+	Subgroups []SubgroupMembers `json:"subgroups"`
+}
 
-	return nil
+// Blocked reports whether anything stops the PIRG from being deleted.
+func (b DeleteBlockers) Blocked() bool {
+	return len(b.Members) > 0 || len(b.Subgroups) > 0
+}
+
+// Summary counts the blockers, as the reason a delete is refused.
+func (b DeleteBlockers) Summary() string {
+	return fmt.Sprintf("%d non-PI member(s) and %d populated subgroup(s)", len(b.Members), len(b.Subgroups))
+}
+
+// PirgDeleteBlockers returns what stands in the way of deleting the PIRG with
+// the given name, without changing anything.
+func PirgDeleteBlockers(ctx context.Context, pirgName string) (DeleteBlockers, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return DeleteBlockers{}, fmt.Errorf("config not found in context")
+	}
+	pirgDN, err := getPIRGDN(ctx, pirgName)
+	if err != nil {
+		return DeleteBlockers{}, fmt.Errorf("failed to get PIRG DN: %w", err)
+	}
+	pi, err := PirgGetPIUsername(ctx, pirgName)
+	if err != nil {
+		// Without a single PI, every member stands in the way
+		slog.Debug("No PI to exclude from delete blockers", "name", pirgName, "error", err)
+		pi = ""
+	}
+	b := DeleteBlockers{PI: pi, Members: []string{}, Subgroups: []SubgroupMembers{}}
+	members, err := ld.GetGroupMemberUsernames(ctx, pirgDN)
+	if err != nil {
+		return DeleteBlockers{}, fmt.Errorf("failed to get group members: %w", err)
+	}
+	for _, member := range members {
+		if !strings.EqualFold(member, pi) {
+			b.Members = append(b.Members, member)
+		}
+	}
+	slices.Sort(b.Members)
+	subgroupDNs, err := PirgSubgroupListDNs(ctx, pirgName)
+	if err != nil {
+		return DeleteBlockers{}, err
+	}
+	for _, subgroupDN := range subgroupDNs {
+		members, err := ld.GetGroupMemberUsernames(ctx, subgroupDN)
+		if err != nil {
+			return DeleteBlockers{}, fmt.Errorf("failed to get subgroup members: %w", err)
+		}
+		if len(members) == 0 {
+			continue
+		}
+		cn, err := ld.ConvertDNToObjectName(subgroupDN)
+		if err != nil {
+			return DeleteBlockers{}, fmt.Errorf("failed to convert DN to object name: %w", err)
+		}
+		slices.Sort(members)
+		b.Subgroups = append(b.Subgroups, SubgroupMembers{Name: getPIRGSubgroupShortName(pirgName, cn), Members: members})
+	}
+	return b, nil
 }
 
 // PirgDelete deletes the PIRG with the given name.
-// It will error if there are any members in the group.
+// It will error if the group has members other than the PI, or any
+// subgroup has members.
 func PirgDelete(ctx context.Context, pirgName string) error {
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
 	if cfg == nil {
 		return fmt.Errorf("config not found in context")
 	}
+	if err := checkNotFrozen(ctx, pirgName); err != nil {
+		return err
+	}
 	pirgOUDN, err := getPIRGOUDN(ctx, pirgName)
 	if err != nil {
 		return fmt.Errorf("failed to get PIRG DN: %w", err)
 	}
 	// Check if the PIRG exists
-	pirgDN, found, err := findPIRGDN(ctx, pirgName)
+	_, found, err := findPIRGDN(ctx, pirgName)
 	if err != nil {
 		return fmt.Errorf("failed to find PIRG DN: %w", err)
 	}
@@ -617,12 +832,12 @@ func PirgDelete(ctx context.Context, pirgName string) error {
 		slog.Debug("PIRG not found", "name", pirgName)
 		return nil
 	}
-	members, err := ld.GetGroupMemberUsernames(ctx, pirgDN)
+	blockers, err := PirgDeleteBlockers(ctx, pirgName)
 	if err != nil {
-		return fmt.Errorf("failed to get group members: %w", err)
+		return err
 	}
-	if len(members) > 1 {
-		return fmt.Errorf("PIRG %s has non-PI members, cannot delete", pirgName)
+	if blockers.Blocked() {
+		return fmt.Errorf("PIRG %s has %s, cannot delete (see delete --dry-run): %w", pirgName, blockers.Summary(), ld.ErrNotEmpty)
 	}
 	err = ld.DeleteOURecursively(ctx, pirgOUDN)
 	if err != nil {
@@ -631,7 +846,9 @@ func PirgDelete(ctx context.Context, pirgName string) error {
 	return nil
 }
 
-// PirgGetPI returns the PI username for the PIRG with the given name.
+// PirgGetPI returns the PI username for the PIRG with the given name. If
+// the PI group is empty, the PI recorded in the PIRG group's managedBy is
+// returned instead.
 func PirgGetPIUsername(ctx context.Context, pirgName string) (string, error) {
 	// Get the PI username for the PIRG with the given name
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
@@ -647,7 +864,7 @@ func PirgGetPIUsername(ctx context.Context, pirgName string) (string, error) {
 		return "", fmt.Errorf("failed to get group members: %w", err)
 	}
 	if len(members) == 0 {
-		return "", fmt.Errorf("no PI found for PIRG %s", pirgName)
+		return pirgManagedByUsername(ctx, pirgName)
 	}
 	if len(members) > 1 {
 		return "", fmt.Errorf("multiple PIs found for PIRG %s", pirgName)
@@ -655,7 +872,130 @@ func PirgGetPIUsername(ctx context.Context, pirgName string) (string, error) {
 	return members[0], nil
 }
 
+// pirgManagedByUsername returns the PI recorded in the PIRG group's
+// managedBy, which setPI keeps in step with the PI group.
+func pirgManagedByUsername(ctx context.Context, pirgName string) (string, error) {
+	pirgDN, err := getPIRGDN(ctx, pirgName)
+	if err != nil {
+		return "", fmt.Errorf("failed to get PIRG DN: %w", err)
+	}
+	managerDN, err := ld.GetManagedBy(ctx, pirgDN)
+	if err != nil {
+		return "", fmt.Errorf("failed to get managedBy of PIRG %s: %w", pirgName, err)
+	}
+	if managerDN == "" {
+		return "", fmt.Errorf("no PI found for PIRG %s", pirgName)
+	}
+	username, err := ld.ConvertDNToObjectName(managerDN)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert DN to username: %w", err)
+	}
+	slog.Debug("PI group empty, using managedBy of PIRG group", "pirgName", pirgName, "managerDN", managerDN)
+	return username, nil
+}
+
+// PirgFixPI makes sure the current PI is in the PIRG's main and admins groups,
+// re-adding them where missing. It does not change who the PI is, and returns
+// the names of the groups the PI was added to.
+func PirgFixPI(ctx context.Context, pirgName string) ([]string, error) {
+	slog.Debug("Fixing PI group memberships", "pirgName", pirgName)
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	if err := checkNotFrozen(ctx, pirgName); err != nil {
+		return nil, err
+	}
+	piUsername, err := PirgGetPIUsername(ctx, pirgName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PI: %w", err)
+	}
+	piDN, err := getUserDN(ctx, piUsername)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pi DN: %w", err)
+	}
+
+	var added []string
+	pirgDN, err := getPIRGDN(ctx, pirgName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PIRG DN: %w", err)
+	}
+	inGroup, err := ld.UserInGroup(ctx, pirgDN, piDN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if user is in group: %w", err)
+	}
+	// A PI created with --no-member stays out of the PIRG group
+	notMember, err := ld.RoleHolderNotMember(ctx, pirgDN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check PI membership mark: %w", err)
+	}
+	if !inGroup && !notMember {
+		// PirgAddMember also restores the top level users group membership.
+		// The PI already holds the role, so an inactive account isn't refused.
+		err = PirgAddMember(context.WithValue(ctx, keys.AllowDisabledKey, true), pirgName, piUsername)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add PI user %s to PIRG %s: %w", piUsername, pirgName, err)
+		}
+		added = append(added, "main")
+	}
+
+	pirgAdminsGroupDN, err := getPIRGAdminsGroupDN(ctx, pirgName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PIRG admins group DN: %w", err)
+	}
+	inGroup, err = ld.UserInGroup(ctx, pirgAdminsGroupDN, piDN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if user is in group: %w", err)
+	}
+	if !inGroup {
+		err = ld.AddUserToGroup(ctx, pirgAdminsGroupDN, piDN)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add pi user %s to PIRG admins group %s: %w", piUsername, pirgName, err)
+		}
+		added = append(added, "admins")
+	}
+	slog.Debug("Fixed PI group memberships", "pirgName", pirgName, "pi", piUsername, "added", added)
+	return added, nil
+}
+
+// checkPIEligibility checks the user against the pi_eligibility policy.
+func checkPIEligibility(ctx context.Context, piUsername string) error {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return fmt.Errorf("config not found in context")
+	}
+	piDN, err := getUserDN(ctx, piUsername)
+	if err != nil {
+		return fmt.Errorf("failed to get pi DN: %w", err)
+	}
+	return ld.CheckEligibility(ctx, piDN, cfg.PIEligibility, "pi_eligibility")
+}
+
+// PirgSetPI makes the user the PI of the PIRG, if pi_eligibility allows it
+// and the account is active.
 func PirgSetPI(ctx context.Context, pirgName string, piUsername string) error {
+	if err := checkNotFrozen(ctx, pirgName); err != nil {
+		return err
+	}
+	err := checkPIEligibility(ctx, piUsername)
+	if err != nil {
+		return err
+	}
+	piDN, err := getUserDN(ctx, piUsername)
+	if err != nil {
+		return fmt.Errorf("failed to get pi DN: %w", err)
+	}
+	err = ld.CheckAccountActive(ctx, piUsername, piDN)
+	if err != nil {
+		return err
+	}
+	return setPI(ctx, pirgName, piUsername, true)
+}
+
+// setPI makes the user the sole member of the PIRG PI group and adds them to
+// the admins group. If addMember is set they are also added to the PIRG
+// itself, and with it the top level users group.
+func setPI(ctx context.Context, pirgName string, piUsername string, addMember bool) error {
 	slog.Debug("Setting PI for PIRG", "pirgName", pirgName, "piUsername", piUsername)
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
 	if cfg == nil {
@@ -693,18 +1033,37 @@ func PirgSetPI(ctx context.Context, pirgName string, piUsername string) error {
 	}
 	// Add the user to the PIRG
 	// This is the correct function to add user to group as the previous did not account for adding new PI to is.racs.talapas.users
-
-	err = PirgAddMember(ctx, pirgName, piUsername)
-	if err != nil {
-		return fmt.Errorf("failed to add PI user %s to PIRG %s: %w", piUsername, pirgName, err)
+	if addMember {
+		err = PirgAddMember(ctx, pirgName, piUsername)
+		if err != nil {
+			return fmt.Errorf("failed to add PI user %s to PIRG %s: %w", piUsername, pirgName, err)
+		}
+		slog.Debug("Added PI to PIRG group", "piUsername", piUsername, "pirgName", pirgName)
 	}
-	slog.Debug("Added PI to PIRG group", "piUsername", piUsername, "pirgName", pirgName)
 	// Add the user to the PIRG PI group
 	err = ld.AddUserToGroup(ctx, pirgPIGroupDN, piDN)
 	if err != nil {
 		return fmt.Errorf("failed to add pi user %s to PIRG PI group %s: %w", piUsername, pirgName, err)
 	}
 
+	// Record the PI on the PIRG group too, so it can be read without a
+	// second lookup. Replacing managedBy drops the previous PI.
+	pirgDN, err := getPIRGDN(ctx, pirgName)
+	if err != nil {
+		return fmt.Errorf("failed to get PIRG DN: %w", err)
+	}
+	err = ld.SetManagedBy(ctx, pirgDN, piDN)
+	if err != nil {
+		return fmt.Errorf("failed to set managedBy of PIRG %s: %w", pirgName, err)
+	}
+
+	// Mark a PI left out of the PIRG group, so check and fix-pi don't add
+	// them back; a PI made a member clears the mark
+	err = ld.SetRoleHolderNotMember(ctx, pirgDN, !addMember)
+	if err != nil {
+		return fmt.Errorf("failed to record PI membership of PIRG %s: %w", pirgName, err)
+	}
+
 	// Add the user to the admins group
 	pirgAdminsGroupDN, err := getPIRGAdminsGroupDN(ctx, pirgName)
 	if err != nil {
@@ -754,12 +1113,56 @@ func PirgList(ctx context.Context) ([]string, error) {
 	return pirgShortNames, nil
 }
 
+// PirgChange is a PIRG and the time its group object was last changed.
+type PirgChange struct {
+	Name        string    `json:"name"`
+	WhenChanged time.Time `json:"when_changed"`
+}
+
+// PirgListChangedSince lists the PIRGs whose group object changed at or
+// after the given time, according to AD's whenChanged attribute.
+func PirgListChangedSince(ctx context.Context, since time.Time) ([]PirgChange, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	changed, err := ld.GetGroupsWhenChangedInOU(ctx, cfg.LDAPPirgDN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PIRGs: %w", err)
+	}
+	pirgGroupNameRegex, err := pirgGroupNameRegex(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PIRG group name regex: %w", err)
+	}
+	var changes []PirgChange
+	for groupName, whenChanged := range changed {
+		if matched, err := regexp.MatchString(pirgGroupNameRegex, groupName); err != nil {
+			return nil, fmt.Errorf("failed to match PIRG group name regex: %w", err)
+		} else if !matched || whenChanged.Before(since) {
+			continue
+		}
+		shortName, err := ConvertPIRGGroupNametoShortName(groupName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert PIRG group name to short name: %w", err)
+		}
+		changes = append(changes, PirgChange{Name: shortName, WhenChanged: whenChanged})
+	}
+	slices.SortFunc(changes, func(a, b PirgChange) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	slog.Debug("Changed PIRGs", "since", since, "count", len(changes))
+	return changes, nil
+}
+
 // PirgAddMember adds a member to the PIRG with the given name.
 func PirgAddMember(ctx context.Context, pirgName string, member string) error {
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
 	if cfg == nil {
 		return fmt.Errorf("config not found in context")
 	}
+	if err := checkNotFrozen(ctx, pirgName); err != nil {
+		return err
+	}
 	pirgDN, err := getPIRGDN(ctx, pirgName)
 	if err != nil {
 		return fmt.Errorf("failed to get PIRG DN: %w", err)
@@ -775,8 +1178,14 @@ func PirgAddMember(ctx context.Context, pirgName string, member string) error {
 		return fmt.Errorf("failed to check if user is in group: %w", err)
 	}
 	if inGroup {
+		// Still make sure they are in the top level users group, in case
+		// someone took them out by hand
 		slog.Debug("User already in PIRG", "userDN", userDN, "pirgDN", pirgDN)
-		return nil
+		return addUserDNToTopLevelUsersGroup(ctx, member, userDN)
+	}
+	err = ld.CheckAccountActive(ctx, member, userDN)
+	if err != nil {
+		return err
 	}
 
 	// Add the user to the PIRG group
@@ -799,17 +1208,22 @@ func PirgAddMember(ctx context.Context, pirgName string, member string) error {
 // PirgRemoveMember removes a member from the PIRG with the given name.
 //
 // It will remove them from the PIRG group, all subgroups, the admin group, and the PI group.
-// If the user is not a member of any other PIRGs, they will also be removed from the top level users and admins groups.
+// If the user is not a member of any other PIRGs, they will also be removed from the top level users and admins groups,
+// unless keep_top_level_users_on_last_removal keeps them in the users group.
+// If the user was not a member, it changes nothing and returns ld.ErrNotMember, wrapped.
 func PirgRemoveMember(ctx context.Context, name string, member string) error {
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
 	if cfg == nil {
 		return fmt.Errorf("config not found in context")
 	}
+	if err := checkNotFrozen(ctx, name); err != nil {
+		return err
+	}
 	pirgDN, err := getPIRGDN(ctx, name)
 	if err != nil {
 		return fmt.Errorf("failed to get PIRG DN: %w", err)
 	}
-	userDN, err := getUserDN(ctx, member)
+	userDN, fallback, err := ld.ResolveMemberDN(ctx, pirgDN, member)
 	if err != nil {
 		return fmt.Errorf("failed to get user DN: %w", err)
 	}
@@ -821,7 +1235,7 @@ func PirgRemoveMember(ctx context.Context, name string, member string) error {
 	}
 	if !inGroup {
 		slog.Debug("User not in PIRG", "userDN", userDN, "pirgDN", pirgDN)
-		return nil
+		return fmt.Errorf("%s %w of PIRG %s", member, ld.ErrNotMember, name)
 	}
 
 	// Check if the user is the PI of the PIRG
@@ -856,6 +1270,18 @@ func PirgRemoveMember(ctx context.Context, name string, member string) error {
 		return fmt.Errorf("failed to get PIRG subgroups: %w", err)
 	}
 	for _, subgroupDN := range subgroups {
+		// Don't leave the subgroup delegated to someone outside the PIRG
+		managerDN, err := ld.GetManagedBy(ctx, subgroupDN)
+		if err != nil {
+			return fmt.Errorf("failed to get subgroup manager: %w", err)
+		}
+		if strings.EqualFold(managerDN, userDN) {
+			slog.Warn("Clearing subgroup manager removed from PIRG", "subgroupDN", subgroupDN, "userDN", userDN)
+			err = ld.SetManagedBy(ctx, subgroupDN, "")
+			if err != nil {
+				return fmt.Errorf("failed to clear subgroup manager: %w", err)
+			}
+		}
 		slog.Debug("Checking if user is in subgroup", "subgroupDN", subgroupDN, "userDN", userDN)
 		inGroup, err := ld.UserInGroup(ctx, subgroupDN, userDN)
 		if err != nil {
@@ -909,6 +1335,11 @@ func PirgRemoveMember(ctx context.Context, name string, member string) error {
 		slog.Debug("Removed user from PIRG PI group", "userDN", userDN, "pirgPIGroupDN", pirgPIGroupDN)
 	}
 
+	// A user missing from AD can't be looked up to clean up the top level groups
+	if fallback {
+		return nil
+	}
+
 	// Remove the user from the top level admins group if they are not an admin in any other PIRG
 	adminInAnyPIRG, err := userIsAdminInAnyPIRG(ctx, member)
 	if err != nil {
@@ -928,7 +1359,9 @@ func PirgRemoveMember(ctx context.Context, name string, member string) error {
 	if err != nil {
 		return fmt.Errorf("failed to check if user is in any PIRG: %w", err)
 	}
-	if !inAnyPIRG {
+	if !inAnyPIRG && cfg.KeepsTopLevelUsers() {
+		slog.Debug("User in no other PIRG, keeping in top level user group as configured", "userDN", userDN)
+	} else if !inAnyPIRG {
 		err = removeUserFromTopLevelUsersGroup(ctx, member)
 		if err != nil {
 			return fmt.Errorf("failed to remove user %s from top level users group: %w", member, err)
@@ -939,24 +1372,129 @@ func PirgRemoveMember(ctx context.Context, name string, member string) error {
 	return nil
 }
 
-func PirgListMemberUsernames(ctx context.Context, name string) ([]string, error) {
-	// List all members of the PIRG with the given name
+// PirgAddMemberDN adds the entry at memberDN to the PIRG with the given name,
+// without looking it up by username. Only user accounts are checked for being active and added to
+// the top level users group. Other objects, such as service accounts, are
+// only added to the PIRG group.
+func PirgAddMemberDN(ctx context.Context, pirgName string, memberDN string) error {
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
 	if cfg == nil {
-		return nil, fmt.Errorf("config not found in context")
+		return fmt.Errorf("config not found in context")
 	}
-	pirgDN, err := getPIRGDN(ctx, name)
+	if err := checkNotFrozen(ctx, pirgName); err != nil {
+		return err
+	}
+	pirgDN, err := getPIRGDN(ctx, pirgName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get PIRG DN: %w", err)
+		return fmt.Errorf("failed to get PIRG DN: %w", err)
 	}
-	members, err := ld.GetGroupMemberUsernames(ctx, pirgDN)
-
+	entry, err := ld.GetMemberEntry(ctx, memberDN)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get group members: %w", err)
+		return fmt.Errorf("failed to look up member: %w", err)
 	}
-	slices.Sort(members)
-	return members, nil
-}
+
+	inGroup, err := ld.UserInGroup(ctx, pirgDN, entry.DN)
+	if err != nil {
+		return fmt.Errorf("failed to check if user is in group: %w", err)
+	}
+	if inGroup {
+		slog.Debug("Member already in PIRG", "memberDN", entry.DN, "pirgDN", pirgDN)
+		return nil
+	}
+	if entry.IsUser {
+		err = ld.CheckAccountActive(ctx, entry.Username, entry.DN)
+		if err != nil {
+			return err
+		}
+	}
+
+	slog.Debug("Adding member to PIRG", "memberDN", entry.DN, "pirgDN", pirgDN)
+	err = ld.AddUserToGroup(ctx, pirgDN, entry.DN)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to PIRG %s: %w", entry.DN, pirgName, err)
+	}
+	if !entry.IsUser {
+		slog.Debug("Not a user account, skipping top level users group", "memberDN", entry.DN)
+		return nil
+	}
+	err = addUserDNToTopLevelUsersGroup(ctx, entry.Username, entry.DN)
+	if err != nil {
+		return fmt.Errorf("failed to add user %s to top level users group: %w", entry.Username, err)
+	}
+	return nil
+}
+
+// PirgRemoveMemberDN removes the entry at memberDN from the PIRG with the
+// given name. User accounts are removed by username through
+// PirgRemoveMember, which also handles the subgroups, admins, PI check, and top level groups. Other objects are
+// only removed from the PIRG group, unless they are in the PI group. A DN that no longer resolves is removed as given.
+func PirgRemoveMemberDN(ctx context.Context, name string, memberDN string) error {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return fmt.Errorf("config not found in context")
+	}
+	if err := checkNotFrozen(ctx, name); err != nil {
+		return err
+	}
+	entry, err := ld.GetRemovableMemberEntry(ctx, memberDN)
+	if err != nil {
+		return fmt.Errorf("failed to look up member: %w", err)
+	}
+	if entry.IsUser {
+		return PirgRemoveMember(ctx, name, entry.Username)
+	}
+	pirgDN, err := getPIRGDN(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get PIRG DN: %w", err)
+	}
+
+	inGroup, err := ld.UserInGroup(ctx, pirgDN, entry.DN)
+	if err != nil {
+		return fmt.Errorf("failed to check if user is in group: %w", err)
+	}
+	if !inGroup {
+		slog.Debug("Member not in PIRG", "memberDN", entry.DN, "pirgDN", pirgDN)
+		return fmt.Errorf("%s %w of PIRG %s", entry.DN, ld.ErrNotMember, name)
+	}
+
+	// Like the username path, refuse to remove the PI
+	roleGroupDN, err := getPIRGPIGroupDN(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get PIRG PI group DN: %w", err)
+	}
+	isRole, err := ld.UserInGroup(ctx, roleGroupDN, entry.DN)
+	if err != nil {
+		return fmt.Errorf("failed to check if user is in group: %w", err)
+	}
+	if isRole {
+		return fmt.Errorf("%s is the PI of PIRG %s, cannot remove without setting a new PI: %w", entry.DN, name, ld.ErrPolicy)
+	}
+	err = ld.RemoveUserFromGroup(ctx, pirgDN, entry.DN)
+	if err != nil {
+		return fmt.Errorf("failed to remove %s from PIRG %s: %w", entry.DN, name, err)
+	}
+	slog.Debug("Removed member from PIRG", "memberDN", entry.DN, "pirgDN", pirgDN)
+	return nil
+}
+
+func PirgListMemberUsernames(ctx context.Context, name string) ([]string, error) {
+	// List all members of the PIRG with the given name
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	pirgDN, err := getPIRGDN(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PIRG DN: %w", err)
+	}
+	members, err := ld.GetGroupMemberUsernames(ctx, pirgDN)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group members: %w", err)
+	}
+	slices.Sort(members)
+	return members, nil
+}
 
 // PirgListMemberDNs lists all member DNs of the PIRG with the given name.
 func PirgListMemberDNs(ctx context.Context, name string) ([]string, error) {
@@ -976,6 +1514,270 @@ func PirgListMemberDNs(ctx context.Context, name string) ([]string, error) {
 	return members, nil
 }
 
+// PirgCountMembers returns how many members the PIRG has, without
+// looking up their usernames.
+func PirgCountMembers(ctx context.Context, name string) (int, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return 0, fmt.Errorf("config not found in context")
+	}
+	pirgDN, err := getPIRGDN(ctx, name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get PIRG DN: %w", err)
+	}
+	count, err := ld.CountGroupMembers(ctx, pirgDN)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count group members: %w", err)
+	}
+	return count, nil
+}
+
+// PirgListSharedMemberUsernames lists the members of the PIRG with the given name
+// who are also members of the other PIRG.
+func PirgListSharedMemberUsernames(ctx context.Context, name string, otherName string) ([]string, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	members, err := PirgListMemberUsernames(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PIRG members: %w", err)
+	}
+	otherMembers, err := PirgListMemberUsernames(ctx, otherName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PIRG %s members: %w", otherName, err)
+	}
+	var shared []string
+	for _, member := range members {
+		if slices.Contains(otherMembers, member) {
+			shared = append(shared, member)
+		}
+	}
+	return shared, nil
+}
+
+// PirgListNonAdminMemberUsernames lists the members of the PIRG with the given
+// name who are not in its admins group. The PI is always an admin, so they are
+// excluded too.
+func PirgListNonAdminMemberUsernames(ctx context.Context, name string) ([]string, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	members, err := PirgListMemberUsernames(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PIRG members: %w", err)
+	}
+	admins, err := PirgListAdminUsernames(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PIRG admins: %w", err)
+	}
+	plain := make([]string, 0, len(members))
+	for _, member := range members {
+		if !slices.Contains(admins, member) {
+			plain = append(plain, member)
+		}
+	}
+	return plain, nil
+}
+
+// MembershipDiff is what it takes to bring a PIRG's members and admins to a
+// desired state.
+type MembershipDiff struct {
+	AddMembers    []string `json:"add_members"`
+	RemoveMembers []string `json:"remove_members"`
+	AddAdmins     []string `json:"add_admins"`
+	RemoveAdmins  []string `json:"remove_admins"`
+}
+
+// PirgMembershipDiff compares the members and admins of the PIRG with the
+// given name to the desired ones, ignoring case. Desired admins are also
+// desired members, since admins must be members, and the PI is never
+// listed for removal.
+func PirgMembershipDiff(ctx context.Context, name string, members []string, admins []string) (MembershipDiff, error) {
+	var diff MembershipDiff
+	currentMembers, err := PirgListMemberUsernames(ctx, name)
+	if err != nil {
+		return diff, fmt.Errorf("failed to get PIRG members: %w", err)
+	}
+	currentAdmins, err := PirgListAdminUsernames(ctx, name)
+	if err != nil {
+		return diff, fmt.Errorf("failed to get PIRG admins: %w", err)
+	}
+	pi, err := PirgGetPIUsername(ctx, name)
+	if err != nil {
+		return diff, fmt.Errorf("failed to get PIRG PI: %w", err)
+	}
+
+	wantMembers := slices.Concat(members, admins)
+	if pi != "" {
+		wantMembers = append(wantMembers, pi)
+		admins = append(slices.Clone(admins), pi)
+	}
+	diff.AddMembers = missingFrom(currentMembers, wantMembers)
+	diff.RemoveMembers = missingFrom(wantMembers, currentMembers)
+	diff.AddAdmins = missingFrom(currentAdmins, admins)
+	diff.RemoveAdmins = missingFrom(admins, currentAdmins)
+	return diff, nil
+}
+
+// missingFrom returns the names in want that aren't in have, ignoring case,
+// sorted and without duplicates.
+func missingFrom(have []string, want []string) []string {
+	present := make(map[string]bool, len(have))
+	for _, name := range have {
+		present[strings.ToLower(name)] = true
+	}
+	missing := []string{}
+	for _, name := range want {
+		if !present[strings.ToLower(name)] {
+			present[strings.ToLower(name)] = true
+			missing = append(missing, name)
+		}
+	}
+	slices.Sort(missing)
+	return missing
+}
+
+// PirgAddMissing applies only the additions of diff to the PIRG with the
+// given name: members first, then admins, who must already be members. No
+// one is ever removed. Inactive accounts are skipped and returned, so the
+// rest are still added.
+func PirgAddMissing(ctx context.Context, name string, diff MembershipDiff) ([]error, error) {
+	if err := checkNotFrozen(ctx, name); err != nil {
+		return nil, err
+	}
+	var inactive []error
+	skipped := make(map[string]bool)
+	for _, username := range diff.AddMembers {
+		err := PirgAddMember(ctx, name, username)
+		if errors.Is(err, ld.ErrAccountInactive) {
+			inactive = append(inactive, err)
+			skipped[strings.ToLower(username)] = true
+			continue
+		}
+		if err != nil {
+			return inactive, fmt.Errorf("failed to add member %s: %w", username, err)
+		}
+	}
+	for _, username := range diff.AddAdmins {
+		// Already reported, and not a member to make an admin of
+		if skipped[strings.ToLower(username)] {
+			continue
+		}
+		err := PirgAddAdmin(ctx, name, username)
+		if errors.Is(err, ld.ErrAccountInactive) {
+			inactive = append(inactive, err)
+			continue
+		}
+		if err != nil {
+			return inactive, fmt.Errorf("failed to add admin %s: %w", username, err)
+		}
+	}
+	return inactive, nil
+}
+
+// PirgUndoCreate removes the given members, added after the PIRG with the
+// given name was created, along with their admin rights, and then deletes
+// the PIRG. It undoes a create whose population failed partway. The PI,
+// who is left in place by PirgRemoveMember, goes with the delete.
+func PirgUndoCreate(ctx context.Context, name string, members []string) error {
+	pi, err := PirgGetPIUsername(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get PIRG PI: %w", err)
+	}
+	for _, username := range members {
+		if strings.EqualFold(username, pi) {
+			continue
+		}
+		err := PirgRemoveMember(ctx, name, username)
+		if err != nil && !errors.Is(err, ld.ErrNotMember) {
+			return fmt.Errorf("failed to remove member %s: %w", username, err)
+		}
+	}
+	if err := PirgDelete(ctx, name); err != nil {
+		return fmt.Errorf("failed to delete PIRG %s: %w", name, err)
+	}
+	slog.Info("Rolled back PIRG create", "pirg", name, "members", len(members))
+	return nil
+}
+
+// PirgMemberManagedGroups returns, for every member of the PIRG with the given name,
+// the names of all managed groups they belong to.
+func PirgMemberManagedGroups(ctx context.Context, name string) (map[string][]string, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	memberDNs, err := PirgListMemberDNs(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PIRG members: %w", err)
+	}
+	userGroups, err := ld.GetGroupsForUsers(ctx, memberDNs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user groups: %w", err)
+	}
+	memberGroups := make(map[string][]string, len(userGroups))
+	for username, groupDNs := range userGroups {
+		var groupNames []string
+		for _, groupDN := range groupDNs {
+			groupName, err := ld.ConvertDNToObjectName(groupDN)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert DN to object name: %w", err)
+			}
+			if _, managed := ld.ClassifyGroupName(groupName); managed {
+				groupNames = append(groupNames, groupName)
+			}
+		}
+		slices.Sort(groupNames)
+		memberGroups[username] = groupNames
+	}
+	return memberGroups, nil
+}
+
+// PirgExposureEntry is another managed group and the PIRG members that are in it.
+type PirgExposureEntry struct {
+	Group   string
+	Members []string
+}
+
+// PirgExposure lists every other managed group the members of the PIRG with the given name belong to,
+// sorted by how many of the PIRG's members are in each.
+// The PIRG's own groups (main, admins, pi, and subgroups) are left out.
+func PirgExposure(ctx context.Context, name string) ([]PirgExposureEntry, error) {
+	pirgFullName, err := getPIRGFullName(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PIRG full name: %w", err)
+	}
+	pirgFullName = strings.ToLower(pirgFullName)
+	memberGroups, err := PirgMemberManagedGroups(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	groupMembers := make(map[string][]string)
+	for username, groupNames := range memberGroups {
+		for _, groupName := range groupNames {
+			lower := strings.ToLower(groupName)
+			if lower == pirgFullName || strings.HasPrefix(lower, pirgFullName+".") {
+				continue
+			}
+			groupMembers[groupName] = append(groupMembers[groupName], username)
+		}
+	}
+	entries := make([]PirgExposureEntry, 0, len(groupMembers))
+	for groupName, members := range groupMembers {
+		slices.Sort(members)
+		entries = append(entries, PirgExposureEntry{Group: groupName, Members: members})
+	}
+	slices.SortFunc(entries, func(a, b PirgExposureEntry) int {
+		if len(a.Members) != len(b.Members) {
+			return len(b.Members) - len(a.Members)
+		}
+		return strings.Compare(a.Group, b.Group)
+	})
+	return entries, nil
+}
+
 // PirgListAdminUsernames lists all admin usernames of the PIRG with the given name.
 func PirgListAdminUsernames(ctx context.Context, name string) ([]string, error) {
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
@@ -994,12 +1796,47 @@ func PirgListAdminUsernames(ctx context.Context, name string) ([]string, error)
 	return admins, nil
 }
 
+// PirgListAdminDNs lists all admin DNs of the PIRG with the given name.
+func PirgListAdminDNs(ctx context.Context, name string) ([]string, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	pirgDN, err := getPIRGAdminsGroupDN(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PIRG DN: %w", err)
+	}
+	admins, err := ld.GetGroupMemberDNs(ctx, pirgDN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group members: %w", err)
+	}
+	slices.Sort(admins)
+	return admins, nil
+}
+
+// PirgListTopLevelAdminUsernames lists all members of the top level PIRG admins group.
+func PirgListTopLevelAdminUsernames(ctx context.Context) ([]string, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	admins, err := ld.GetGroupMemberUsernames(ctx, cfg.PirgAdminsGroupDN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group members: %w", err)
+	}
+	slices.Sort(admins)
+	return admins, nil
+}
+
 // PirgAddAdmin adds an admin to the PIRG with the given name.
 func PirgAddAdmin(ctx context.Context, pirgName string, adminUsername string) error {
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
 	if cfg == nil {
 		return fmt.Errorf("config not found in context")
 	}
+	if err := checkNotFrozen(ctx, pirgName); err != nil {
+		return err
+	}
 	adminGroupDN, err := getPIRGAdminsGroupDN(ctx, pirgName)
 	if err != nil {
 		return fmt.Errorf("failed to get PIRG admin group DN: %w", err)
@@ -1016,7 +1853,7 @@ func PirgAddAdmin(ctx context.Context, pirgName string, adminUsername string) er
 	}
 	if !found {
 		slog.Debug("PIRG not found", "name", pirgName)
-		return fmt.Errorf("PIRG %s not found", pirgName)
+		return fmt.Errorf("PIRG %s %w", pirgName, ld.ErrNotFound)
 	}
 
 	// Check if the user is a member of the PIRG
@@ -1038,6 +1875,10 @@ func PirgAddAdmin(ctx context.Context, pirgName string, adminUsername string) er
 		slog.Debug("User already in PIRG admins group", "userDN", userDN, "pirgDN", adminGroupDN)
 		return nil
 	}
+	err = ld.CheckAccountActive(ctx, adminUsername, userDN)
+	if err != nil {
+		return err
+	}
 
 	// Add the user to the PIRG admins group
 	err = ld.AddUserToGroup(ctx, adminGroupDN, userDN)
@@ -1062,11 +1903,14 @@ func PirgRemoveAdmin(ctx context.Context, pirgName string, adminUsername string)
 	if cfg == nil {
 		return fmt.Errorf("config not found in context")
 	}
+	if err := checkNotFrozen(ctx, pirgName); err != nil {
+		return err
+	}
 	adminGroupDN, err := getPIRGAdminsGroupDN(ctx, pirgName)
 	if err != nil {
 		return fmt.Errorf("failed to get PIRG admin group DN: %w", err)
 	}
-	userDN, err := getUserDN(ctx, adminUsername)
+	userDN, fallback, err := ld.ResolveMemberDN(ctx, adminGroupDN, adminUsername)
 	if err != nil {
 		return fmt.Errorf("failed to get user DN: %w", err)
 	}
@@ -1088,6 +1932,11 @@ func PirgRemoveAdmin(ctx context.Context, pirgName string, adminUsername string)
 	}
 	slog.Debug("Removed admin from PIRG", "userDN", userDN, "pirgDN", adminGroupDN)
 
+	// A user missing from AD can't be looked up to clean up the top level groups
+	if fallback {
+		return nil
+	}
+
 	// Remove the user from the top level admins if they are not an admin of any other PIRG
 	isAdminInAnotherPIRG, err := userIsAdminInAnyPIRG(ctx, adminUsername)
 	if err != nil {
@@ -1105,6 +1954,46 @@ func PirgRemoveAdmin(ctx context.Context, pirgName string, adminUsername string)
 	return nil
 }
 
+// PirgAndSubgroupExist reports whether the PIRG with the given name and its
+// subgroup exist, resolving both in one search under the PIRG's OU instead
+// of one round trip each.
+func PirgAndSubgroupExist(ctx context.Context, pirgName string, subgroupName string) (bool, bool, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return false, false, fmt.Errorf("config not found in context")
+	}
+	pirgOUDN, err := getPIRGOUDN(ctx, pirgName)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to get PIRG DN: %w", err)
+	}
+	pirgDN, err := getPIRGDN(ctx, pirgName)
+	if err != nil {
+		return false, false, err
+	}
+	subgroupDN, err := getPIRGSubgroupDN(ctx, pirgName, subgroupName)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to get PIRG subgroup DN: %w", err)
+	}
+	pirgCN, err := ld.ConvertDNToObjectName(pirgDN)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to convert DN to object name: %w", err)
+	}
+	subgroupCN, err := ld.ConvertDNToObjectName(subgroupDN)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to convert DN to object name: %w", err)
+	}
+	found, err := ld.GetGroupDNsByCN(ctx, pirgOUDN, []string{pirgCN, subgroupCN})
+	if err != nil {
+		return false, false, fmt.Errorf("failed to find PIRG and subgroup: %w", err)
+	}
+	at := func(cn string, dn string) bool {
+		return slices.ContainsFunc(found[strings.ToLower(cn)], func(d string) bool { return strings.EqualFold(d, dn) })
+	}
+	pirgFound, subgroupFound := at(pirgCN, pirgDN), at(subgroupCN, subgroupDN)
+	slog.Debug("Resolved PIRG and subgroup", "pirgDN", pirgDN, "pirgFound", pirgFound, "subgroupDN", subgroupDN, "subgroupFound", subgroupFound)
+	return pirgFound, subgroupFound, nil
+}
+
 // PirgSubgroupExists checks if the subgroup with the given name exists under the PIRG.
 func PirgSubgroupExists(ctx context.Context, pirgName string, subgroupName string) (bool, error) {
 	// Check if the subgroup with the given name exists under the PIRG
@@ -1145,12 +2034,62 @@ func PirgSubgroupList(ctx context.Context, pirgName string) ([]string, error) {
 	return shortNames, nil
 }
 
+// PirgSubgroupListDetails lists the subgroups of the PIRG with the given
+// name by short name, with their gidNumber and description.
+func PirgSubgroupListDetails(ctx context.Context, pirgName string) ([]ld.GroupDetails, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	pirgSubgroupsOUDN, err := getPIRGSubgroupOUDN(ctx, pirgName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PIRG subgroup OU DN: %w", err)
+	}
+	subgroups, err := ld.GetGroupDetailsInOU(ctx, pirgSubgroupsOUDN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PIRG subgroups: %w", err)
+	}
+	for i := range subgroups {
+		subgroups[i].Name = getPIRGSubgroupShortName(pirgName, subgroups[i].Name)
+	}
+	slices.SortFunc(subgroups, func(a, b ld.GroupDetails) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return subgroups, nil
+}
+
+// PirgSubgroupSetDescription replaces the description of a PIRG subgroup.
+func PirgSubgroupSetDescription(ctx context.Context, pirgName string, subgroupName string, description string) error {
+	if err := checkNotFrozen(ctx, pirgName); err != nil {
+		return err
+	}
+	if err := ld.ValidateSubgroupDescription(ctx, description); err != nil {
+		return err
+	}
+	subgroupDN, err := getPIRGSubgroupDN(ctx, pirgName, subgroupName)
+	if err != nil {
+		return fmt.Errorf("failed to get PIRG subgroup DN: %w", err)
+	}
+	err = ld.SetGroupDescription(ctx, subgroupDN, description)
+	if err != nil {
+		return fmt.Errorf("failed to set subgroup description: %w", err)
+	}
+	return nil
+}
+
 // PirgSubgroupCreate creates a new subgroup under the PIRG with the given name.
-func PirgSubgroupCreate(ctx context.Context, pirgName string, subgroupName string) error {
+// description is stored on the group and may be empty unless require_subgroup_description is set.
+func PirgSubgroupCreate(ctx context.Context, pirgName string, subgroupName string, description string) error {
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
 	if cfg == nil {
 		return fmt.Errorf("config not found in context")
 	}
+	if err := checkNotFrozen(ctx, pirgName); err != nil {
+		return err
+	}
+	if err := ld.ValidateSubgroupDescription(ctx, description); err != nil {
+		return err
+	}
 	subgroupDN, err := getPIRGSubgroupDN(ctx, pirgName, subgroupName)
 	if err != nil {
 		return fmt.Errorf("failed to get PIRG subgroup DN: %w", err)
@@ -1166,11 +2105,11 @@ func PirgSubgroupCreate(ctx context.Context, pirgName string, subgroupName strin
 	}
 
 	// Create the subgroup object inside the PIRG OU/Groups
-	gidNumber, err := ld.GetNextGidNumber(ctx)
+	gidNumber, err := ld.AllocateGids(ctx, "pirg", 1)
 	if err != nil {
 		return fmt.Errorf("failed to get next GID number: %w", err)
 	}
-	err = ld.CreateGroup(ctx, subgroupOUDN, subgroupFullName, gidNumber)
+	err = ld.CreateGroup(ctx, subgroupOUDN, subgroupFullName, gidNumber, ld.WithDescription(description))
 	if err != nil {
 		return fmt.Errorf("failed to create PIRG subgroup object: %w", err)
 	}
@@ -1180,35 +2119,56 @@ func PirgSubgroupCreate(ctx context.Context, pirgName string, subgroupName strin
 }
 
 // PirgSubgroupDelete deletes the subgroup with the given name under the PIRG groups OU.
-// If the subgroup is found, it returns true and nil.
-func PirgSubgroupDelete(ctx context.Context, pirgName string, subgroupName string) error {
+// It will error if the subgroup still has members, unless force is set,
+// in which case the members are removed first and their usernames returned.
+func PirgSubgroupDelete(ctx context.Context, pirgName string, subgroupName string, force bool) ([]string, error) {
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
 	if cfg == nil {
-		return fmt.Errorf("config not found in context")
+		return nil, fmt.Errorf("config not found in context")
+	}
+	if err := checkNotFrozen(ctx, pirgName); err != nil {
+		return nil, err
 	}
 	subgroupDN, err := getPIRGSubgroupDN(ctx, pirgName, subgroupName)
 	if err != nil {
-		return fmt.Errorf("failed to get PIRG subgroup DN: %w", err)
+		return nil, fmt.Errorf("failed to get PIRG subgroup DN: %w", err)
 	}
 
 	// Check if the subgroup exists
 	exists, err := ld.DNExists(ctx, subgroupDN)
 	if err != nil {
-		return fmt.Errorf("failed to check if group exists: %w", err)
+		return nil, fmt.Errorf("failed to check if group exists: %w", err)
 	}
 	if !exists {
 		slog.Debug("PIRG subgroup does not exist", "subgroupDN", subgroupDN)
-		return nil
+		return nil, nil
+	}
+
+	// Check if the subgroup has members
+	members, err := PirgSubgroupListMemberUsernames(ctx, pirgName, subgroupName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group members: %w", err)
+	}
+	if len(members) > 0 && !force {
+		return nil, fmt.Errorf("PIRG subgroup %s has %d members, cannot delete without force: %w", subgroupName, len(members), ld.ErrNotEmpty)
+	}
+	var removed []string
+	for _, member := range members {
+		err = PirgSubgroupRemoveMember(ctx, pirgName, subgroupName, member)
+		if err != nil {
+			return removed, fmt.Errorf("failed to remove member %s from PIRG subgroup %s: %w", member, subgroupName, err)
+		}
+		removed = append(removed, member)
 	}
 
 	// Delete the subgroup object
 	err = ld.DeleteGroup(ctx, subgroupDN)
 	if err != nil {
-		return fmt.Errorf("failed to delete PIRG subgroup object: %w", err)
+		return removed, fmt.Errorf("failed to delete PIRG subgroup object: %w", err)
 	}
 	slog.Debug("Deleted PIRG subgroup object", "subgroupDN", subgroupDN)
 
-	return nil
+	return removed, nil
 }
 
 // PirgSubgroupListMemberUsernames lists all members of the subgroup with the given name under the PIRG.
@@ -1257,6 +2217,24 @@ func PirgSubgroupListMemberDNs(ctx context.Context, pirgName string, subgroupNam
 	return members, nil
 }
 
+// PirgSubgroupCountMembers returns how many members the subgroup with the given name under the PIRG has, without
+// looking up their usernames.
+func PirgSubgroupCountMembers(ctx context.Context, pirgName string, subgroupName string) (int, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return 0, fmt.Errorf("config not found in context")
+	}
+	subgroupDN, err := getPIRGSubgroupDN(ctx, pirgName, subgroupName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get PIRG subgroup DN: %w", err)
+	}
+	count, err := ld.CountGroupMembers(ctx, subgroupDN)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count group members: %w", err)
+	}
+	return count, nil
+}
+
 // PirgSubgroupAddMember adds a member to the subgroup with the given name under the PIRG.
 func PirgSubgroupAddMember(ctx context.Context, pirgName string, subgroupName string, memberUsername string) error {
 	// Check if memberUsername is in the PIRG
@@ -1281,14 +2259,13 @@ func PirgSubgroupAddMember(ctx context.Context, pirgName string, subgroupName st
 	if cfg == nil {
 		return fmt.Errorf("config not found in context")
 	}
+	if err := checkNotFrozen(ctx, pirgName); err != nil {
+		return err
+	}
 	subgroupDN, err := getPIRGSubgroupDN(ctx, pirgName, subgroupName)
 	if err != nil {
 		return fmt.Errorf("failed to get PIRG subgroup DN: %w", err)
 	}
-	userDN, err = getUserDN(ctx, memberUsername)
-	if err != nil {
-		return fmt.Errorf("failed to get user DN: %w", err)
-	}
 
 	// Check if the user is already a member of the subgroup
 	inGroup, err = ld.UserInGroup(ctx, subgroupDN, userDN)
@@ -1316,11 +2293,14 @@ func PirgSubgroupRemoveMember(ctx context.Context, pirgName string, subgroupName
 	if cfg == nil {
 		return fmt.Errorf("config not found in context")
 	}
+	if err := checkNotFrozen(ctx, pirgName); err != nil {
+		return err
+	}
 	subgroupDN, err := getPIRGSubgroupDN(ctx, pirgName, subgroupName)
 	if err != nil {
 		return fmt.Errorf("failed to get PIRG subgroup DN: %w", err)
 	}
-	userDN, err := getUserDN(ctx, memberUsername)
+	userDN, _, err := ld.ResolveMemberDN(ctx, subgroupDN, memberUsername)
 	if err != nil {
 		return fmt.Errorf("failed to get user DN: %w", err)
 	}
@@ -1383,3 +2363,145 @@ func PirgSubgroupListDNs(ctx context.Context, pirgName string) ([]string, error)
 	slices.Sort(subgroups)
 	return subgroups, nil
 }
+
+// PirgSubgroupGetManager returns the username of the subgroup's delegated
+// manager, or an empty string if none is set.
+func PirgSubgroupGetManager(ctx context.Context, pirgName string, subgroupName string) (string, error) {
+	subgroupDN, err := getPIRGSubgroupDN(ctx, pirgName, subgroupName)
+	if err != nil {
+		return "", fmt.Errorf("failed to get PIRG subgroup DN: %w", err)
+	}
+	managerDN, err := ld.GetManagedBy(ctx, subgroupDN)
+	if err != nil {
+		return "", fmt.Errorf("failed to get subgroup manager: %w", err)
+	}
+	if managerDN == "" {
+		return "", nil
+	}
+	manager, err := ld.ConvertDNToObjectName(managerDN)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert DN to username: %w", err)
+	}
+	return manager, nil
+}
+
+// PirgSubgroupSetManager delegates management of a subgroup's members to
+// the given user, who must be a member of the parent PIRG.
+func PirgSubgroupSetManager(ctx context.Context, pirgName string, subgroupName string, managerUsername string) error {
+	slog.Debug("Setting PIRG subgroup manager", "pirgName", pirgName, "subgroupName", subgroupName, "manager", managerUsername)
+	if err := checkNotFrozen(ctx, pirgName); err != nil {
+		return err
+	}
+	pirgDN, err := getPIRGDN(ctx, pirgName)
+	if err != nil {
+		return fmt.Errorf("failed to get PIRG DN: %w", err)
+	}
+	subgroupDN, err := getPIRGSubgroupDN(ctx, pirgName, subgroupName)
+	if err != nil {
+		return fmt.Errorf("failed to get PIRG subgroup DN: %w", err)
+	}
+	userDN, err := getUserDN(ctx, managerUsername)
+	if err != nil {
+		return fmt.Errorf("failed to get user DN: %w", err)
+	}
+	inGroup, err := ld.UserInGroup(ctx, pirgDN, userDN)
+	if err != nil {
+		return fmt.Errorf("failed to check if user is in group: %w", err)
+	}
+	if !inGroup {
+		return fmt.Errorf("user %s is not a member of PIRG %s", managerUsername, pirgName)
+	}
+	err = ld.SetManagedBy(ctx, subgroupDN, userDN)
+	if err != nil {
+		return fmt.Errorf("failed to set subgroup manager: %w", err)
+	}
+	return nil
+}
+
+// PirgSubgroupCanManage reports whether the user may manage the subgroup's
+// members, either as a PIRG admin or as the subgroup's delegated manager.
+func PirgSubgroupCanManage(ctx context.Context, pirgName string, subgroupName string, username string) (bool, error) {
+	userDN, err := getUserDN(ctx, username)
+	if err != nil {
+		return false, fmt.Errorf("failed to get user DN: %w", err)
+	}
+	pirgAdminsGroupDN, err := getPIRGAdminsGroupDN(ctx, pirgName)
+	if err != nil {
+		return false, fmt.Errorf("failed to get PIRG admins group DN: %w", err)
+	}
+	isAdmin, err := ld.UserInGroup(ctx, pirgAdminsGroupDN, userDN)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if user is in group: %w", err)
+	}
+	if isAdmin {
+		return true, nil
+	}
+	subgroupDN, err := getPIRGSubgroupDN(ctx, pirgName, subgroupName)
+	if err != nil {
+		return false, fmt.Errorf("failed to get PIRG subgroup DN: %w", err)
+	}
+	managerDN, err := ld.GetManagedBy(ctx, subgroupDN)
+	if err != nil {
+		return false, fmt.Errorf("failed to get subgroup manager: %w", err)
+	}
+	return strings.EqualFold(managerDN, userDN), nil
+}
+
+// PirgCheck reports inconsistencies in the PIRG with the given name. Admins
+// who aren't members are only fixable once strayAdminFix says whether to add
+// them to the PIRG or drop them from its admins.
+func PirgCheck(ctx context.Context, name string, strayAdminFix consistency.StrayAdminFix) ([]consistency.Problem, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	mainDN, err := getPIRGDN(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PIRG DN: %w", err)
+	}
+	adminsDN, err := getPIRGAdminsGroupDN(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PIRG admins group DN: %w", err)
+	}
+	piDN, err := getPIRGPIGroupDN(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PIRG PI group DN: %w", err)
+	}
+	subgroupDNs, err := PirgSubgroupListDNs(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PIRG subgroups: %w", err)
+	}
+	fullName, err := getPIRGFullName(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PIRG full name: %w", err)
+	}
+	notMember, err := ld.RoleHolderNotMember(ctx, mainDN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check PI membership mark: %w", err)
+	}
+	return consistency.Check(ctx, consistency.Group{
+		Name:                fullName,
+		MainDN:              mainDN,
+		AdminsDN:            adminsDN,
+		RoleDN:              piDN,
+		RoleName:            "PI",
+		SubgroupDNs:         subgroupDNs,
+		StrayAdminFix:       strayAdminFix,
+		RoleHolderNotMember: notMember,
+	})
+}
+
+// PirgDNs returns the DNs of the main, admins and PI groups and every
+// subgroup of the PIRG with the given name, as found in the directory.
+// Missing groups have an empty DN.
+func PirgDNs(ctx context.Context, name string) ([]managedgroup.Component, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	components, err := ld.ResolveGroupComponents(ctx, managedgroup.Pirg, cfg.LDAPPirgDN, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve PIRG group DNs: %w", err)
+	}
+	return components, nil
+}
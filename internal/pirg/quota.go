@@ -0,0 +1,150 @@
+package pirg
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/quota"
+)
+
+// quotaAttributes returns the attributes of the quotas in
+// quota_attribute_map, in quota.Names order.
+func quotaAttributes(cfg *config.Config) []string {
+	var attributes []string
+	for _, name := range quota.Names {
+		if attribute, ok := cfg.QuotaAttributeMap[name]; ok {
+			attributes = append(attributes, attribute)
+		}
+	}
+	return attributes
+}
+
+// parseQuotas reads the quotas out of a group's attributes, keyed by
+// lowercased attribute name. A value that isn't a whole number of bytes
+// is an error, so a hand-edited attribute isn't silently ignored.
+func parseQuotas(cfg *config.Config, values map[string]string) (map[string]int64, error) {
+	quotas := make(map[string]int64)
+	for _, name := range quota.Names {
+		attribute, ok := cfg.QuotaAttributeMap[name]
+		if !ok {
+			continue
+		}
+		value, ok := values[strings.ToLower(attribute)]
+		if !ok {
+			continue
+		}
+		bytes, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || bytes <= 0 {
+			return nil, fmt.Errorf("%s quota in %s is %q, not a number of bytes: %w", name, attribute, value, quota.ErrInvalid)
+		}
+		quotas[name] = bytes
+	}
+	return quotas, nil
+}
+
+// PirgGetQuotas returns the storage quotas set on the PIRG with the given
+// name, in bytes and keyed by quota name. Quotas that aren't set are left
+// out.
+func PirgGetQuotas(ctx context.Context, name string) (map[string]int64, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	groupDN, err := getPIRGDN(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PIRG DN: %w", err)
+	}
+	attributes := quotaAttributes(cfg)
+	values, err := ld.GetGroupAttributes(ctx, groupDN, attributes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quotas of PIRG %s: %w", name, err)
+	}
+	return parseQuotas(cfg, values)
+}
+
+// PirgSetQuotas sets the given storage quotas, in bytes, on the PIRG with
+// the given name, leaving its other quotas alone. A quota of 0 clears it.
+// Every quota must be in quota_attribute_map. The change is logged at info
+// level.
+func PirgSetQuotas(ctx context.Context, name string, quotas map[string]int64) error {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return fmt.Errorf("config not found in context")
+	}
+	values := make(map[string]string, len(quotas))
+	for quotaName, bytes := range quotas {
+		attribute, ok := cfg.QuotaAttributeMap[quotaName]
+		if !ok {
+			return fmt.Errorf("quota_attribute_map has no attribute for the %s quota", quotaName)
+		}
+		if bytes < 0 {
+			return fmt.Errorf("%s quota %d: %w", quotaName, bytes, quota.ErrInvalid)
+		}
+		values[attribute] = ""
+		if bytes > 0 {
+			values[attribute] = strconv.FormatInt(bytes, 10)
+		}
+	}
+	if err := checkNotFrozen(ctx, name); err != nil {
+		return err
+	}
+	groupDN, err := getPIRGDN(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get PIRG DN: %w", err)
+	}
+	if err := ld.SetGroupAttributes(ctx, groupDN, values); err != nil {
+		return fmt.Errorf("failed to set quotas of PIRG %s: %w", name, err)
+	}
+	for quotaName, bytes := range quotas {
+		slog.Info("Set PIRG quota", "pirg", name, "quota", quotaName, "bytes", bytes, "attribute", cfg.QuotaAttributeMap[quotaName])
+	}
+	return nil
+}
+
+// PirgListQuotas returns the storage quotas of every PIRG that has any,
+// keyed by short name and then quota name, from one paged search. A PIRG
+// whose quota attribute holds something other than a number of bytes is
+// skipped with a warning.
+func PirgListQuotas(ctx context.Context) (map[string]map[string]int64, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	attributes := quotaAttributes(cfg)
+	if len(attributes) == 0 {
+		return map[string]map[string]int64{}, nil
+	}
+	groups, err := ld.GetGroupAttributesInOU(ctx, cfg.LDAPPirgDN, attributes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PIRG quotas: %w", err)
+	}
+	nameRegex, err := pirgGroupNameRegex(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PIRG group name regex: %w", err)
+	}
+	re := regexp.MustCompile(nameRegex)
+	quotas := make(map[string]map[string]int64, len(groups))
+	for groupName, values := range groups {
+		if !re.MatchString(groupName) {
+			continue
+		}
+		shortName, err := ConvertPIRGGroupNametoShortName(groupName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert PIRG group name to short name: %w", err)
+		}
+		q, err := parseQuotas(cfg, values)
+		if err != nil {
+			slog.Warn("Skipping PIRG with an invalid quota", "pirg", shortName, "error", err)
+			continue
+		}
+		quotas[shortName] = q
+	}
+	return quotas, nil
+}
@@ -0,0 +1,207 @@
+package pirg
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/managedgroup"
+)
+
+// RegularMember is a member of a PIRG who is neither its PI nor an admin.
+type RegularMember struct {
+	// Username is the sAMAccountName, or the cn of an entry without one.
+	Username string
+	DN       string
+	// IsUser is whether the entry has a sAMAccountName. Only users are
+	// taken out of the top level users group.
+	IsUser bool
+}
+
+// PirgListRegularMembers returns the members of the PIRG with the given name
+// who are in neither its PI group nor its admins group, sorted by username.
+// Members whose username is in except are kept out of the list.
+func PirgListRegularMembers(ctx context.Context, name string, except []string) ([]RegularMember, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	memberDNs, err := PirgListMemberDNs(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	adminDNs, err := PirgListAdminDNs(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	piGroupDN, err := getPIRGPIGroupDN(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PIRG PI group DN: %w", err)
+	}
+	piDNs, err := ld.GetGroupMemberDNs(ctx, piGroupDN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PI group members: %w", err)
+	}
+	roles := make(map[string]bool, len(adminDNs)+len(piDNs))
+	for _, dn := range slices.Concat(adminDNs, piDNs) {
+		roles[strings.ToLower(dn)] = true
+	}
+	var regularDNs []string
+	for _, dn := range memberDNs {
+		if !roles[strings.ToLower(dn)] {
+			regularDNs = append(regularDNs, dn)
+		}
+	}
+
+	users, err := ld.GetUserAttributes(ctx, regularDNs, []string{"sAMAccountName"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get member usernames: %w", err)
+	}
+	kept := make(map[string]bool, len(except))
+	members := make([]RegularMember, 0, len(regularDNs))
+	for _, dn := range regularDNs {
+		m := RegularMember{DN: dn}
+		if username := users[strings.ToLower(dn)]["samaccountname"]; username != "" {
+			m.Username, m.IsUser = username, true
+		} else if m.Username, err = ld.ConvertDNToObjectName(dn); err != nil {
+			return nil, fmt.Errorf("failed to convert DN to object name: %w", err)
+		}
+		if i := slices.IndexFunc(except, func(u string) bool { return strings.EqualFold(u, m.Username) }); i >= 0 {
+			kept[strings.ToLower(except[i])] = true
+			continue
+		}
+		members = append(members, m)
+	}
+	for _, username := range except {
+		if !kept[strings.ToLower(username)] {
+			slog.Warn("Not a regular member of the PIRG, nothing to keep", "pirg", name, "username", username)
+		}
+	}
+	slices.SortFunc(members, func(a, b RegularMember) int { return strings.Compare(a.Username, b.Username) })
+	return members, nil
+}
+
+// PirgRemoveRegularMembers removes the given members, from
+// PirgListRegularMembers, from the PIRG with the given name and its
+// subgroups, in batched modifies. Subgroups they managed are left without
+// a manager. Users who are then in no PIRG are taken out of the top level
+// users group, unless keep_top_level_users_on_last_removal is set; this is
+// decided from one batched memberOf lookup rather than a search per user.
+func PirgRemoveRegularMembers(ctx context.Context, name string, members []RegularMember) error {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return fmt.Errorf("config not found in context")
+	}
+	if err := checkNotFrozen(ctx, name); err != nil {
+		return err
+	}
+	pirgDN, err := getPIRGDN(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get PIRG DN: %w", err)
+	}
+	removed := make(map[string]bool, len(members))
+	dns := make([]string, 0, len(members))
+	for _, m := range members {
+		removed[strings.ToLower(m.DN)] = true
+		dns = append(dns, m.DN)
+	}
+
+	if err := ld.RemoveUsersFromGroup(ctx, pirgDN, dns); err != nil {
+		return fmt.Errorf("failed to remove members from PIRG %s: %w", name, err)
+	}
+	slog.Info("Removed regular members from PIRG", "pirg", name, "count", len(dns))
+
+	subgroupOUDN, err := getPIRGSubgroupOUDN(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get PIRG subgroup OU DN: %w", err)
+	}
+	subgroups, err := ld.GetGroupDNsInOU(ctx, subgroupOUDN)
+	if err != nil {
+		return fmt.Errorf("failed to get PIRG subgroups: %w", err)
+	}
+	for _, subgroupDN := range subgroups {
+		managerDN, err := ld.GetManagedBy(ctx, subgroupDN)
+		if err != nil {
+			return fmt.Errorf("failed to get subgroup manager: %w", err)
+		}
+		if removed[strings.ToLower(managerDN)] {
+			slog.Warn("Clearing subgroup manager removed from PIRG", "subgroupDN", subgroupDN, "userDN", managerDN)
+			if err := ld.SetManagedBy(ctx, subgroupDN, ""); err != nil {
+				return fmt.Errorf("failed to clear subgroup manager: %w", err)
+			}
+		}
+		subgroupMembers, err := ld.GetGroupMemberDNs(ctx, subgroupDN)
+		if err != nil {
+			return fmt.Errorf("failed to get subgroup members: %w", err)
+		}
+		subgroupMembers = slices.DeleteFunc(subgroupMembers, func(dn string) bool { return !removed[strings.ToLower(dn)] })
+		if len(subgroupMembers) == 0 {
+			continue
+		}
+		if err := ld.RemoveUsersFromGroup(ctx, subgroupDN, subgroupMembers); err != nil {
+			return fmt.Errorf("failed to remove members from PIRG subgroup %s: %w", subgroupDN, err)
+		}
+		slog.Debug("Removed regular members from subgroup", "subgroupDN", subgroupDN, "count", len(subgroupMembers))
+	}
+
+	if cfg.KeepsTopLevelUsers() {
+		slog.Debug("Keeping removed members in top level user group as configured", "pirg", name)
+		return nil
+	}
+	return removeFromTopLevelUsersIfNoPIRG(ctx, pirgDN, members)
+}
+
+// removeFromTopLevelUsersIfNoPIRG takes the users among members who are no
+// longer in any PIRG out of the top level users group. pirgDN, the group
+// they were just removed from, is ignored in case memberOf still lists it.
+func removeFromTopLevelUsersIfNoPIRG(ctx context.Context, pirgDN string, members []RegularMember) error {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return fmt.Errorf("config not found in context")
+	}
+	var userDNs []string
+	for _, m := range members {
+		if m.IsUser {
+			userDNs = append(userDNs, m.DN)
+		}
+	}
+	groups, err := ld.GetGroupsForUsers(ctx, userDNs)
+	if err != nil {
+		return fmt.Errorf("failed to get groups of removed members: %w", err)
+	}
+	var leaving []string
+	for _, m := range members {
+		if !m.IsUser {
+			continue
+		}
+		inTopLevel, inPIRG := false, false
+		for _, groupDN := range groups[m.Username] {
+			if strings.EqualFold(groupDN, cfg.UsersGroupDN) {
+				inTopLevel = true
+				continue
+			}
+			if strings.EqualFold(groupDN, pirgDN) {
+				continue
+			}
+			if managedgroup.Pirg.Classify(cfg.LDAPPirgDN, groupDN).Kind == managedgroup.KindGroup {
+				inPIRG = true
+			}
+		}
+		if inTopLevel && !inPIRG {
+			leaving = append(leaving, m.DN)
+		}
+	}
+	if len(leaving) == 0 {
+		return nil
+	}
+	if err := ld.RemoveUsersFromGroup(ctx, cfg.UsersGroupDN, leaving); err != nil {
+		return fmt.Errorf("failed to remove users from top level users group: %w", err)
+	}
+	slog.Info("Removed users in no other PIRG from top level users group", "count", len(leaving))
+	return nil
+}
@@ -0,0 +1,63 @@
+package pirg
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// SubgroupMembershipDiff is what it takes to bring a PIRG subgroup's
+// members to a desired list.
+type SubgroupMembershipDiff struct {
+	Add    []string `json:"add"`
+	Remove []string `json:"remove"`
+}
+
+// PirgSubgroupMembershipDiff compares the members of the given subgroup of
+// the PIRG with the given name to the desired ones, ignoring case. Every
+// desired member must already be a member of the PIRG; if any aren't, an
+// error naming all of them is returned.
+func PirgSubgroupMembershipDiff(ctx context.Context, pirgName string, subgroupName string, members []string) (SubgroupMembershipDiff, error) {
+	var diff SubgroupMembershipDiff
+	pirgMembers, err := PirgListMemberUsernames(ctx, pirgName)
+	if err != nil {
+		return diff, fmt.Errorf("failed to get PIRG members: %w", err)
+	}
+	if outside := missingFrom(pirgMembers, members); len(outside) > 0 {
+		return diff, fmt.Errorf("not members of the PIRG %s: %s", pirgName, strings.Join(outside, ", "))
+	}
+	current, err := PirgSubgroupListMemberUsernames(ctx, pirgName, subgroupName)
+	if err != nil {
+		return diff, fmt.Errorf("failed to get PIRG subgroup members: %w", err)
+	}
+	diff.Add = missingFrom(current, members)
+	diff.Remove = missingFrom(members, current)
+	return diff, nil
+}
+
+// PirgSubgroupSetMembers makes the given subgroup of the PIRG with the given
+// name have exactly the given members, adding the missing ones before
+// removing the extra ones. Nothing changes if any of them aren't members
+// of the PIRG.
+func PirgSubgroupSetMembers(ctx context.Context, pirgName string, subgroupName string, members []string) error {
+	if err := checkNotFrozen(ctx, pirgName); err != nil {
+		return err
+	}
+	diff, err := PirgSubgroupMembershipDiff(ctx, pirgName, subgroupName, members)
+	if err != nil {
+		return err
+	}
+	for _, username := range diff.Add {
+		if err := PirgSubgroupAddMember(ctx, pirgName, subgroupName, username); err != nil {
+			return fmt.Errorf("failed to add member %s: %w", username, err)
+		}
+	}
+	for _, username := range diff.Remove {
+		if err := PirgSubgroupRemoveMember(ctx, pirgName, subgroupName, username); err != nil {
+			return fmt.Errorf("failed to remove member %s: %w", username, err)
+		}
+	}
+	slog.Info("Set PIRG subgroup members", "pirg", pirgName, "subgroup", subgroupName, "added", len(diff.Add), "removed", len(diff.Remove))
+	return nil
+}
@@ -0,0 +1,123 @@
+package pirg
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/ldap/ldaptest"
+)
+
+// pirgTestContext returns a context using the directory and config the
+// root package's golden tests use.
+func pirgTestContext(t *testing.T) context.Context {
+	ctx, _ := countingTestContext(t)
+	return ctx
+}
+
+// countingTestContext is pirgTestContext, also returning the client so
+// tests can count the searches made through it.
+func countingTestContext(t *testing.T) (context.Context, *countingClient) {
+	t.Helper()
+	dir, err := ldaptest.Load(filepath.Join("..", "..", "testdata", "directory.ldif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.GetConfig(filepath.Join("..", "..", "testdata", "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &countingClient{Directory: dir}
+	ctx := context.WithValue(context.Background(), keys.ConfigKey, cfg)
+	return ld.WithClient(ctx, client), client
+}
+
+// countingClient counts the searches made through it.
+type countingClient struct {
+	*ldaptest.Directory
+	searches int
+}
+
+func (c *countingClient) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	c.searches++
+	return c.Directory.Search(req)
+}
+
+func (c *countingClient) SearchWithPaging(req *ldap.SearchRequest, pagingSize uint32) (*ldap.SearchResult, error) {
+	c.searches++
+	return c.Directory.SearchWithPaging(req, pagingSize)
+}
+
+func TestPirgSubgroupCanManage(t *testing.T) {
+	ctx := pirgTestContext(t)
+	check := func(username string, want bool) {
+		t.Helper()
+		got, err := PirgSubgroupCanManage(ctx, "alpha", "lab", username)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("PirgSubgroupCanManage(%s) = %v, want %v", username, got, want)
+		}
+	}
+
+	// alice is the PI, and so a PIRG admin
+	check("alice", true)
+	check("bob", false)
+	check("carol", false)
+
+	if err := PirgSubgroupSetManager(ctx, "alpha", "lab", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	check("bob", true)
+	check("carol", false)
+	// Managing lab doesn't extend to other subgroups
+	if err := PirgSubgroupCreate(ctx, "alpha", "bench", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := PirgSubgroupCanManage(ctx, "alpha", "bench", "bob"); err != nil || got {
+		t.Errorf("PirgSubgroupCanManage(bench, bob) = %v, %v, want false", got, err)
+	}
+
+	if err := PirgRemoveMember(ctx, "alpha", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	check("bob", false)
+}
+
+// TestPirgAndSubgroupExist checks that a PIRG and its subgroup are resolved
+// in one search, and that only groups at their expected DNs count.
+func TestPirgAndSubgroupExist(t *testing.T) {
+	cases := []struct {
+		name         string
+		pirg         string
+		subgroup     string
+		wantPirg     bool
+		wantSubgroup bool
+	}{
+		{name: "both", pirg: "alpha", subgroup: "lab", wantPirg: true, wantSubgroup: true},
+		{name: "no subgroup", pirg: "alpha", subgroup: "bench", wantPirg: true},
+		{name: "subgroup of another PIRG", pirg: "gamma", subgroup: "lab", wantPirg: true},
+		{name: "neither", pirg: "nosuch", subgroup: "lab"},
+		{name: "PI group isn't a subgroup", pirg: "alpha", subgroup: "pi", wantPirg: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, client := countingTestContext(t)
+			gotPirg, gotSubgroup, err := PirgAndSubgroupExist(ctx, tc.pirg, tc.subgroup)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotPirg != tc.wantPirg || gotSubgroup != tc.wantSubgroup {
+				t.Errorf("PirgAndSubgroupExist() = %v, %v, want %v, %v", gotPirg, gotSubgroup, tc.wantPirg, tc.wantSubgroup)
+			}
+			if client.searches != 1 {
+				t.Errorf("PirgAndSubgroupExist() made %d searches, want 1", client.searches)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+// Package quota parses and formats the storage quotas recorded on PIRG
+// groups. Quotas are stored in AD as a whole number of bytes and written
+// with binary K, M, G, and T suffixes, so 10T is 10 TiB.
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Names are the quotas a PIRG can have, in the order they are listed.
+var Names = []string{"projects", "scratch"}
+
+// units are the suffixes Parse accepts, largest first so Format picks the
+// largest that fits.
+var units = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"T", 1 << 40},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+}
+
+// ErrInvalid is returned, wrapped, for a value that isn't a positive size.
+var ErrInvalid = errors.New("invalid quota")
+
+// Parse reads a size such as 10T, 512G, or 1073741824 (bytes) and returns
+// it in bytes. Suffixes are case-insensitive. Zero, negative, fractional,
+// and overflowing sizes are rejected.
+func Parse(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for _, u := range units {
+		if rest, ok := strings.CutSuffix(s, u.suffix); ok {
+			s, multiplier = rest, u.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%w %q: want a whole number of bytes, optionally with a K, M, G, or T suffix", ErrInvalid, value)
+	}
+	if n > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("%w %q: too large", ErrInvalid, value)
+	}
+	return n * multiplier, nil
+}
+
+// Format writes bytes with the largest suffix that divides it evenly, so
+// Parse(Format(n)) is n.
+func Format(bytes int64) string {
+	for _, u := range units {
+		if bytes != 0 && bytes%u.bytes == 0 {
+			return fmt.Sprintf("%d%s", bytes/u.bytes, u.suffix)
+		}
+	}
+	return strconv.FormatInt(bytes, 10)
+}
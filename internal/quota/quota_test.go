@@ -0,0 +1,89 @@
+package quota
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		value string
+		want  int64
+	}{
+		{"1", 1},
+		{"1073741824", 1 << 30},
+		{"1K", 1 << 10},
+		{"512G", 512 << 30},
+		{"10T", 10 << 40},
+		{"10t", 10 << 40},
+		{" 3m ", 3 << 20},
+		{"8388607T", 8388607 << 40},
+	}
+	for _, tc := range cases {
+		got, err := Parse(tc.value)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tc.value, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("Parse(%q) = %d, want %d", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"0",
+		"0T",
+		"-1",
+		"-5G",
+		"1.5T",
+		"T",
+		"10TB",
+		"10P",
+		"ten",
+		"8388608T",
+		"9223372036854775808",
+	} {
+		if got, err := Parse(value); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Parse(%q) = %d, %v, want ErrInvalid", value, got, err)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	cases := []struct {
+		bytes int64
+		want  string
+	}{
+		{0, "0"},
+		{1, "1"},
+		{1023, "1023"},
+		{1 << 10, "1K"},
+		{1536 << 10, "1536K"},
+		{1 << 30, "1G"},
+		{1024 << 30, "1T"},
+		{1025 << 30, "1025G"},
+		{10 << 40, "10T"},
+	}
+	for _, tc := range cases {
+		if got := Format(tc.bytes); got != tc.want {
+			t.Errorf("Format(%d) = %q, want %q", tc.bytes, got, tc.want)
+		}
+	}
+}
+
+func TestFormatParseRoundTrip(t *testing.T) {
+	for _, bytes := range []int64{1, 999, 1 << 10, 3 << 20, 1536 << 10, 512 << 30, 10 << 40, math.MaxInt64} {
+		got, err := Parse(Format(bytes))
+		if err != nil {
+			t.Errorf("Parse(Format(%d)): %v", bytes, err)
+			continue
+		}
+		if got != bytes {
+			t.Errorf("Parse(Format(%d)) = %d", bytes, got)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/managedgroup"
+)
+
+// PosixFinding is one object in a managed group that SSSD ignores for want
+// of a POSIX attribute.
+type PosixFinding struct {
+	Family string `json:"family"`
+	// Group is the cn of the group, or of the group the user is a member of.
+	Group string `json:"group"`
+	// DN is the group's DN for a missing gidNumber, and the user's for a
+	// missing uidNumber.
+	DN        string `json:"dn"`
+	Attribute string `json:"attribute"`
+	// Value is the malformed gidNumber, or the SID a user without a
+	// uidNumber falls back to.
+	Value string `json:"value,omitempty"`
+	// AssignedGid is the gidNumber --fix-gid gave the group.
+	AssignedGid int `json:"assigned_gid,omitempty"`
+}
+
+// MissingPosix returns the groups of every enabled family without a
+// numeric gidNumber, and the user members of those families' groups
+// without a uidNumber. Groups are read with one subtree search per family,
+// and members in batches. With fixGid, each group missing a gidNumber is
+// given the next one from its family's range.
+func MissingPosix(ctx context.Context, fixGid bool) ([]PosixFinding, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	var findings []PosixFinding
+	for _, f := range managedgroup.Families {
+		if cfg.DisabledSubsystem(f.Name) != "" {
+			slog.Debug("Skipping disabled family", "family", f.Name)
+			continue
+		}
+		groups, err := ld.GetPosixGroupsInOU(ctx, cfg.BaseDN(f.Name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s groups: %w", f.Name, err)
+		}
+
+		var memberDNs []string
+		seen := make(map[string]bool)
+		for _, g := range groups {
+			for _, dn := range g.Members {
+				if !seen[strings.ToLower(dn)] {
+					seen[strings.ToLower(dn)] = true
+					memberDNs = append(memberDNs, dn)
+				}
+			}
+			if _, err := strconv.Atoi(g.GidNumber); err == nil {
+				continue
+			}
+			finding := PosixFinding{Family: f.Name, Group: g.CN, DN: g.DN, Attribute: "gidNumber", Value: g.GidNumber}
+			if fixGid {
+				finding.AssignedGid, err = assignGid(ctx, cfg, f, g)
+				if err != nil {
+					return nil, err
+				}
+			}
+			findings = append(findings, finding)
+		}
+
+		missing, err := ld.GetUsersWithoutUID(ctx, memberDNs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check uidNumber of %s members: %w", f.Name, err)
+		}
+		for _, g := range groups {
+			for _, dn := range g.Members {
+				if sid, ok := missing[strings.ToLower(dn)]; ok {
+					findings = append(findings, PosixFinding{Family: f.Name, Group: g.CN, DN: dn, Attribute: "uidNumber", Value: sid})
+				}
+			}
+		}
+	}
+	return findings, nil
+}
+
+// assignGid gives g the next gidNumber in f's range, logging the assignment
+// and noting it in the group's info attribute.
+func assignGid(ctx context.Context, cfg *config.Config, f managedgroup.Family, g ld.PosixGroup) (int, error) {
+	gidNumber, err := ld.AllocateGids(ctx, f.Name, 1)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get next GID number for %s: %w", g.DN, err)
+	}
+	if err := ld.SetGidNumber(ctx, g.DN, gidNumber); err != nil {
+		return 0, err
+	}
+	slog.Info("Assigned missing gidNumber", "family", f.Name, "groupDN", g.DN, "old", g.GidNumber, "gidNumber", gidNumber)
+	note := fmt.Sprintf("%s fix-gid: assigned gidNumber %d", time.Now().Format(time.DateOnly), gidNumber)
+	if err := ld.AppendChangeNote(ctx, g.DN, note, cfg.ChangeNoteLimit); err != nil {
+		slog.Warn("Failed to record change note", "groupDN", g.DN, "error", err)
+	}
+	return gidNumber, nil
+}
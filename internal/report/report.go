@@ -0,0 +1,119 @@
+// Package report finds managed groups that are candidates for cleanup.
+package report
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/managedgroup"
+)
+
+// Finding is one group a report flags.
+type Finding struct {
+	Family string `json:"family"`
+	// Name is the short name of the group the entry belongs to, if known.
+	Name        string            `json:"name,omitempty"`
+	CN          string            `json:"cn"`
+	DN          string            `json:"dn"`
+	Kind        managedgroup.Kind `json:"kind"`
+	Members     int               `json:"members"`
+	WhenChanged time.Time         `json:"when_changed"`
+}
+
+// classified is a group summary with its place in its family.
+type classified struct {
+	ld.GroupSummary
+	managedgroup.Classification
+}
+
+// scan summarizes and classifies every group of every enabled family, one
+// subtree search per family.
+func scan(ctx context.Context, visit func(f managedgroup.Family, groups []classified)) error {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return fmt.Errorf("config not found in context")
+	}
+	for _, f := range managedgroup.Families {
+		if cfg.DisabledSubsystem(f.Name) != "" {
+			slog.Debug("Skipping disabled family", "family", f.Name)
+			continue
+		}
+		baseDN := cfg.BaseDN(f.Name)
+		summaries, err := ld.SummarizeGroupsInOU(ctx, baseDN)
+		if err != nil {
+			return fmt.Errorf("failed to summarize %s groups: %w", f.Name, err)
+		}
+		groups := make([]classified, 0, len(summaries))
+		for _, s := range summaries {
+			groups = append(groups, classified{GroupSummary: s, Classification: f.Classify(baseDN, s.DN)})
+		}
+		visit(f, groups)
+	}
+	return nil
+}
+
+func finding(f managedgroup.Family, g classified) Finding {
+	return Finding{
+		Family:      f.Name,
+		Name:        g.Name,
+		CN:          g.GroupSummary.CN,
+		DN:          g.GroupSummary.DN,
+		Kind:        g.Kind,
+		Members:     g.Members,
+		WhenChanged: g.WhenChanged,
+	}
+}
+
+// Empty returns the groups of every enabled family with no members. PI and
+// owner groups are left out, since they hold one person by design and are
+// reported by the consistency checks when empty, and so are admins groups
+// whose group has a PI or owner.
+func Empty(ctx context.Context) ([]Finding, error) {
+	var findings []Finding
+	err := scan(ctx, func(f managedgroup.Family, groups []classified) {
+		// Groups whose PI or owner group has someone in it
+		hasRole := make(map[string]bool)
+		for _, g := range groups {
+			if g.Kind == managedgroup.KindRole && g.Members > 0 {
+				hasRole[strings.ToLower(g.Name)] = true
+			}
+		}
+		for _, g := range groups {
+			if g.Members > 0 || g.Kind == managedgroup.KindRole {
+				continue
+			}
+			if g.Kind == managedgroup.KindAdmins && hasRole[strings.ToLower(g.Name)] {
+				continue
+			}
+			findings = append(findings, finding(f, g))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return findings, nil
+}
+
+// StaleSubgroups returns the subgroups of every enabled family that have
+// not changed since before and have fewer than minMembers members.
+func StaleSubgroups(ctx context.Context, before time.Time, minMembers int) ([]Finding, error) {
+	var findings []Finding
+	err := scan(ctx, func(f managedgroup.Family, groups []classified) {
+		for _, g := range groups {
+			if g.Kind != managedgroup.KindSubgroup || g.Members >= minMembers || !g.WhenChanged.Before(before) {
+				continue
+			}
+			findings = append(findings, finding(f, g))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return findings, nil
+}
@@ -11,13 +11,13 @@ import (
 	"github.com/uoracs/directory-manager/internal/config"
 	"github.com/uoracs/directory-manager/internal/keys"
 	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/managedgroup"
 )
 
 var (
-	err                   error
-	found                 bool
-	groupPrefix           = "is.racs.software."
-	topLevelUsersGroupDN  = "CN=IS.RACS.Talapas.Users,OU=RACS,OU=Groups,OU=IS,OU=Units,DC=ad,DC=uoregon,DC=edu"
+	err         error
+	found       bool
+	groupPrefix = managedgroup.Software.Prefix
 )
 
 func ConvertSoftwareGroupNametoShortName(swName string) (string, error) {
@@ -45,11 +45,15 @@ func SoftwareGroupNameRegex(ctx context.Context) (string, error) {
 
 func findSWDN(ctx context.Context, name string) (string, bool, error) {
 	slog.Debug("Finding SW DN", "name", name)
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return "", false, fmt.Errorf("config not found in context")
+	}
 	groupName, err := getSOFTWAREFullName(ctx, name)
 	if err != nil {
 		return "", false, fmt.Errorf("failed to get SOFTWARE full name: %w", err)
 	}
-	dn, found, err := ld.GetGroupDN(ctx, groupName)
+	dn, found, err := ld.GetGroupDN(ctx, cfg.LDAPSoftwareDN, groupName)
 	if !found && err == nil {
 		slog.Debug("SOFTWARE group not found", "name", name)
 		return "", false, nil
@@ -114,21 +118,22 @@ func SoftwareList(ctx context.Context) ([]string, error) {
 	return softwareShortNames, nil
 }
 
+// getSWDN returns the DistinguishedName of the SOFTWARE group with the given name.
+// The group is looked up in the directory, so it is found under either layout.
+// if not found, it returns an error.
 func getSWDN(ctx context.Context, name string) (string, error) {
 	slog.Debug("Getting SOFTWARE DN", "name", name)
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
 	if cfg == nil {
 		return "", fmt.Errorf("config not found in context")
 	}
-	swDN, err := getSWOUDN(ctx, name)
+	n, found, err := findSWDN(ctx, name)
 	if err != nil {
-		return "", fmt.Errorf("failed to get SOFTWARE DN: %w", err)
+		return "", fmt.Errorf("failed to find SOFTWARE DN: %w", err)
 	}
-	groupName, err := getSOFTWAREFullName(ctx, name)
-	if err != nil {
-		return "", fmt.Errorf("failed to get SOFTWARE full name: %w", err)
+	if !found {
+		return "", fmt.Errorf("SOFTWARE group %s %w", name, ld.ErrNotFound)
 	}
-	n := fmt.Sprintf("CN=%s,%s", groupName, swDN)
 
 	slog.Debug("SOFTWARE DN", "dn", n)
 	return n, nil
@@ -145,6 +150,9 @@ func getSOFTWAREFullName(ctx context.Context, swName string) (string, error) {
 	return n, nil
 }
 
+// getSWOUDN returns the DistinguishedName of the OU a new SOFTWARE group is created in.
+// With the flat layout this is the software base DN, with the ou layout
+// it is a per-group OU below it, for example: OU=sw_name,OU=Software,DC=example,DC=com
 func getSWOUDN(ctx context.Context, name string) (string, error) {
 	slog.Debug("Getting SOFTWARE OU DN", "name", name)
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
@@ -152,31 +160,84 @@ func getSWOUDN(ctx context.Context, name string) (string, error) {
 		return "", fmt.Errorf("config not found in context")
 	}
 	baseDN := cfg.LDAPSoftwareDN
+	if cfg.SoftwareLayout == "ou" {
+		baseDN = fmt.Sprintf("OU=%s,%s", name, cfg.LDAPSoftwareDN)
+	}
 	slog.Debug("SOFTWARE OU DN", "dn", baseDN)
 	
 	return baseDN, nil
 }
 
+// isPerGroupOU reports whether the group DN lives in its own per-group OU
+// (the ou layout) rather than directly under the software base DN.
+func isPerGroupOU(ctx context.Context, name string, groupDN string) (bool, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return false, fmt.Errorf("config not found in context")
+	}
+	parentDN, err := ld.ParentDN(groupDN)
+	if err != nil {
+		return false, err
+	}
+	perGroupOUDN := fmt.Sprintf("OU=%s,%s", name, cfg.LDAPSoftwareDN)
+	return ld.SameDN(parentDN, perGroupOUDN)
+}
+
 func SoftwareListMemberUsernames(ctx context.Context, name string) ([]string, error) {
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
 	if cfg == nil {
 		return nil, fmt.Errorf("config not found in context")
 	}
 
-	fullName, err := getSOFTWAREFullName(ctx, name)
+	softwareDN, err := getSWDN(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get SOFTWARE DN: %w", err)
+	}
+	members, err := ld.GetGroupMemberUsernames(ctx, softwareDN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group members: %w", err)
+	}
+	slices.Sort(members)
+	return members, nil
+}
 
-	baseDN := cfg.LDAPSoftwareDN
+// SoftwareListMemberDNs lists all member DNs of the SOFTWARE group with the given name.
+func SoftwareListMemberDNs(ctx context.Context, name string) ([]string, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+
+	softwareDN, err := getSWDN(ctx, name)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get SOFTWARE DN: %w", err)
 	}
-	fullNameCN := fmt.Sprintf("cn=%s,%s",fullName, baseDN)
-	members, err := ld.GetGroupMemberUsernames(ctx, fullNameCN)
+	members, err := ld.GetGroupMemberDNs(ctx, softwareDN)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get group members: %w", err)
 	}
 	slices.Sort(members)
 	return members, nil
 }
+
+// SoftwareCountMembers returns how many members the SOFTWARE group has, without
+// looking up their usernames.
+func SoftwareCountMembers(ctx context.Context, name string) (int, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return 0, fmt.Errorf("config not found in context")
+	}
+	softwareDN, err := getSWDN(ctx, name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get SOFTWARE DN: %w", err)
+	}
+	count, err := ld.CountGroupMembers(ctx, softwareDN)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count group members: %w", err)
+	}
+	return count, nil
+}
+
 func SoftwareAddMember(ctx context.Context, softwareName string, member string) error {
 	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
 	if cfg == nil {
@@ -222,7 +283,7 @@ func getUserDN(ctx context.Context, name string) (string, error) {
 		return "", fmt.Errorf("failed to get user DN: %w", err)
 	}
 	if dn == "" {
-		return "", fmt.Errorf("user %s not found", name)
+		return "", fmt.Errorf("user %s %w", name, ld.ErrNotFound)
 	}
 	slog.Debug("User DN", "dn", dn)
 	return dn, nil
@@ -230,23 +291,29 @@ func getUserDN(ctx context.Context, name string) (string, error) {
 
 func addUserToTopLevelUsersGroup(ctx context.Context, member string) error {
 	slog.Debug("Adding user to top level users group", "member", member)
-	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
-	if cfg == nil {
-		return fmt.Errorf("config not found in context")
-	}
 	userDN, err := getUserDN(ctx, member)
 	if err != nil {
 		return fmt.Errorf("failed to get user DN: %w", err)
 	}
-	inGroup, err := ld.UserInGroup(ctx, topLevelUsersGroupDN, userDN)
+	return addUserDNToTopLevelUsersGroup(ctx, member, userDN)
+}
+
+// addUserDNToTopLevelUsersGroup is addUserToTopLevelUsersGroup for a user
+// whose DN is already known.
+func addUserDNToTopLevelUsersGroup(ctx context.Context, member string, userDN string) error {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return fmt.Errorf("config not found in context")
+	}
+	inGroup, err := ld.UserInGroup(ctx, cfg.UsersGroupDN, userDN)
 	if err != nil {
 		return fmt.Errorf("failed to check if user is in group: %w", err)
 	}
 	if inGroup {
-		slog.Debug("User already in top level users group", "userDN", userDN, "topLevelUsersGroupDN", topLevelUsersGroupDN)
+		slog.Debug("User already in top level users group", "userDN", userDN, "topLevelUsersGroupDN", cfg.UsersGroupDN)
 		return nil
 	}
-	err = ld.AddUserToGroup(ctx, topLevelUsersGroupDN, userDN)
+	err = ld.AddUserToGroup(ctx, cfg.UsersGroupDN, userDN)
 	if err != nil {
 		return fmt.Errorf("failed to add user %s to users group: %w", member, err)
 	}
@@ -263,7 +330,7 @@ func SoftwareRemoveMember(ctx context.Context, name string, member string) error
 	if err != nil {
 		return fmt.Errorf("failed to get SOFTWARE DN: %w", err)
 	}
-	userDN, err := getUserDN(ctx, member)
+	userDN, _, err := ld.ResolveMemberDN(ctx, softwareDN, member)
 	if err != nil {
 		return fmt.Errorf("failed to get user DN: %w", err)
 	}
@@ -274,7 +341,7 @@ func SoftwareRemoveMember(ctx context.Context, name string, member string) error
 	}
 	if !inGroup {
 		slog.Debug("User not in SOFTWARE group", "userDN", userDN, "softwareDN", softwareDN)
-		return nil
+		return fmt.Errorf("%s %w of SOFTWARE %s", member, ld.ErrNotMember, name)
 	}
 	err = ld.RemoveUserFromGroup(ctx, softwareDN, userDN)
 	if err != nil {
@@ -282,6 +349,92 @@ func SoftwareRemoveMember(ctx context.Context, name string, member string) error
 	}
 	slog.Debug("Removed user from SOFTWARE", "userDN", userDN, "softwareDN", softwareDN)
 
+	// Admins must be members, so remove the user from the admins group too
+	err = SoftwareRemoveAdmin(ctx, name, member)
+	if err != nil {
+		return fmt.Errorf("failed to remove user %s from SOFTWARE admins group %s: %w", member, name, err)
+	}
+
+	return nil
+}
+
+// SoftwareAddMemberDN adds the entry at memberDN to the SOFTWARE with the given name,
+// without looking it up by username. Only user accounts are added to
+// the top level users group. Other objects, such as service accounts, are
+// only added to the SOFTWARE group.
+func SoftwareAddMemberDN(ctx context.Context, softwareName string, memberDN string) error {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return fmt.Errorf("config not found in context")
+	}
+	softwareDN, err := getSWDN(ctx, softwareName)
+	if err != nil {
+		return fmt.Errorf("failed to get SOFTWARE DN: %w", err)
+	}
+	entry, err := ld.GetMemberEntry(ctx, memberDN)
+	if err != nil {
+		return fmt.Errorf("failed to look up member: %w", err)
+	}
+
+	inGroup, err := ld.UserInGroup(ctx, softwareDN, entry.DN)
+	if err != nil {
+		return fmt.Errorf("failed to check if user is in group: %w", err)
+	}
+	if inGroup {
+		slog.Debug("Member already in SOFTWARE", "memberDN", entry.DN, "softwareDN", softwareDN)
+		return nil
+	}
+
+	slog.Debug("Adding member to SOFTWARE", "memberDN", entry.DN, "softwareDN", softwareDN)
+	err = ld.AddUserToGroup(ctx, softwareDN, entry.DN)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to SOFTWARE %s: %w", entry.DN, softwareName, err)
+	}
+	if !entry.IsUser {
+		slog.Debug("Not a user account, skipping top level users group", "memberDN", entry.DN)
+		return nil
+	}
+	err = addUserDNToTopLevelUsersGroup(ctx, entry.Username, entry.DN)
+	if err != nil {
+		return fmt.Errorf("failed to add user %s to top level users group: %w", entry.Username, err)
+	}
+	return nil
+}
+
+// SoftwareRemoveMemberDN removes the entry at memberDN from the SOFTWARE with the
+// given name. User accounts are removed by username through
+// SoftwareRemoveMember, which also handles the admins group. Other objects are
+// only removed from the SOFTWARE group. A DN that no longer resolves is removed as given.
+func SoftwareRemoveMemberDN(ctx context.Context, name string, memberDN string) error {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return fmt.Errorf("config not found in context")
+	}
+	entry, err := ld.GetRemovableMemberEntry(ctx, memberDN)
+	if err != nil {
+		return fmt.Errorf("failed to look up member: %w", err)
+	}
+	if entry.IsUser {
+		return SoftwareRemoveMember(ctx, name, entry.Username)
+	}
+	softwareDN, err := getSWDN(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get SOFTWARE DN: %w", err)
+	}
+
+	inGroup, err := ld.UserInGroup(ctx, softwareDN, entry.DN)
+	if err != nil {
+		return fmt.Errorf("failed to check if user is in group: %w", err)
+	}
+	if !inGroup {
+		slog.Debug("Member not in SOFTWARE", "memberDN", entry.DN, "softwareDN", softwareDN)
+		return fmt.Errorf("%s %w of SOFTWARE %s", entry.DN, ld.ErrNotMember, name)
+	}
+	err = ld.RemoveUserFromGroup(ctx, softwareDN, entry.DN)
+	if err != nil {
+		return fmt.Errorf("failed to remove %s from SOFTWARE %s: %w", entry.DN, name, err)
+	}
+	slog.Debug("Removed member from SOFTWARE", "memberDN", entry.DN, "softwareDN", softwareDN)
 	return nil
 }
 func SoftwareCreate(ctx context.Context, softwareName string) error {
@@ -306,7 +459,7 @@ func SoftwareCreate(ctx context.Context, softwareName string) error {
 		return fmt.Errorf("failed to find software DN: %w", err)
 	}
 
-	gidNumber, err := ld.GetNextGidNumber(ctx)
+	gidNumber, err := ld.AllocateGids(ctx, "software", 1)
 	if err != nil {
 		return fmt.Errorf("failed to get next GID number: %w", err)
 	}
@@ -315,7 +468,14 @@ func SoftwareCreate(ctx context.Context, softwareName string) error {
 	allsoftwaresDN := cfg.LDAPSoftwareDN
 	slog.Debug("All softwares DN", "allsoftwaresDN", allsoftwaresDN)
 
-	slog.Debug("Created software OU", "name", softwareName)
+	// Create the per-group OU inside the software base DN
+	if cfg.SoftwareLayout == "ou" {
+		err = ld.CreateOU(ctx, allsoftwaresDN, softwareName)
+		if err != nil {
+			return fmt.Errorf("failed to create software OU: %w", err)
+		}
+		slog.Debug("Created software OU", "name", softwareName)
+	}
 	softwareFullName, err := getSOFTWAREFullName(ctx, softwareName)
 	if err != nil {
 		return fmt.Errorf("failed to get software full name: %w", err)
@@ -343,20 +503,43 @@ func SoftwareDelete(ctx context.Context, softwareName string) error {
 		slog.Debug("software group not found", "name", softwareName)
 		return nil
 	}
-	slog.Debug("software DN", softwareDN, err)
+	slog.Debug("software DN", "softwareDN", softwareDN)
 
-	baseDN := cfg.LDAPSoftwareDN
-	fullName, err := getSOFTWAREFullName(ctx, softwareName)
-	if err != nil {
-		return fmt.Errorf("failed to obtain software group fullname: %w", err)
-	}
-	fullNameCN := fmt.Sprintf("cn=%s,%s", fullName, baseDN)
-	members, err := ld.GetGroupMemberUsernames(ctx, fullNameCN)
+	members, err := ld.GetGroupMemberUsernames(ctx, softwareDN)
 	if err != nil {
 		return fmt.Errorf("failed to get group members: %w", err)
 	}
 	if len(members) > 0 {
-		return fmt.Errorf("software group is not empty. There are %d members. Please remove all members and try again", len(members))
+		return fmt.Errorf("software group has %d members, remove them and try again: %w", len(members), ld.ErrNotEmpty)
+	}
+
+	// Groups created with the ou layout take their OU with them,
+	// whatever the current layout setting is.
+	perGroupOU, err := isPerGroupOU(ctx, softwareName, softwareDN)
+	if err != nil {
+		return fmt.Errorf("failed to check software group layout: %w", err)
+	}
+	if perGroupOU {
+		ouDN, err := ld.ParentDN(softwareDN)
+		if err != nil {
+			return err
+		}
+		err = ld.DeleteOURecursively(ctx, ouDN)
+		if err != nil {
+			return fmt.Errorf("failed to delete software OU: %w", err)
+		}
+		return nil
+	}
+	// With the flat layout the admins group, if any, sits next to the main group
+	adminsDN, found, err := findSWAdminsDN(ctx, softwareName)
+	if err != nil {
+		return fmt.Errorf("failed to find software admins group DN: %w", err)
+	}
+	if found {
+		err = ld.DeleteGroup(ctx, adminsDN)
+		if err != nil {
+			return fmt.Errorf("failed to delete software admins group object: %w", err)
+		}
 	}
 	err = ld.DeleteGroup(ctx, softwareDN)
 	if err != nil {
@@ -365,3 +548,237 @@ func SoftwareDelete(ctx context.Context, softwareName string) error {
 	return nil
 }
 
+
+func getSWAdminsGroupFullName(swName string) string {
+	return groupPrefix + swName + managedgroup.Software.AdminsSuffix
+}
+
+// findSWAdminsDN returns the DN of the admins group of the SOFTWARE group
+// with the given name. Admins groups are optional, so legacy groups
+// without one are reported as not found rather than as an error.
+func findSWAdminsDN(ctx context.Context, name string) (string, bool, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return "", false, fmt.Errorf("config not found in context")
+	}
+	dn, found, err := ld.GetGroupDN(ctx, cfg.LDAPSoftwareDN, getSWAdminsGroupFullName(name))
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get group DN: %w", err)
+	}
+	slog.Debug("SOFTWARE admins group", "name", name, "found", found, "dn", dn)
+	return dn, found, nil
+}
+
+// SoftwareListAdminUsernames lists all admin usernames of the SOFTWARE group
+// with the given name, which is empty if it has no admins group.
+func SoftwareListAdminUsernames(ctx context.Context, name string) ([]string, error) {
+	adminsDN, found, err := findSWAdminsDN(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find SOFTWARE admins group DN: %w", err)
+	}
+	if !found {
+		return nil, nil
+	}
+	admins, err := ld.GetGroupMemberUsernames(ctx, adminsDN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group members: %w", err)
+	}
+	slices.Sort(admins)
+	return admins, nil
+}
+
+// SoftwareListAdminDNs lists all admin DNs of the SOFTWARE group with the
+// given name, which is empty if it has no admins group.
+func SoftwareListAdminDNs(ctx context.Context, name string) ([]string, error) {
+	adminsDN, found, err := findSWAdminsDN(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find SOFTWARE admins group DN: %w", err)
+	}
+	if !found {
+		return nil, nil
+	}
+	admins, err := ld.GetGroupMemberDNs(ctx, adminsDN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group members: %w", err)
+	}
+	slices.Sort(admins)
+	return admins, nil
+}
+
+// createSWAdminsGroup creates the admins group of the SOFTWARE group at
+// softwareDN next to it, in the per-group OU or the software base DN.
+func createSWAdminsGroup(ctx context.Context, name string, softwareDN string) (string, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return "", fmt.Errorf("config not found in context")
+	}
+	parentDN, err := ld.ParentDN(softwareDN)
+	if err != nil {
+		return "", err
+	}
+	gidNumber, err := ld.AllocateGids(ctx, "software", 1)
+	if err != nil {
+		return "", fmt.Errorf("failed to get next GID number: %w", err)
+	}
+	adminsName := getSWAdminsGroupFullName(name)
+	err = ld.CreateGroup(ctx, parentDN, adminsName, gidNumber)
+	if err != nil {
+		return "", fmt.Errorf("failed to create SOFTWARE admins group object: %w", err)
+	}
+	slog.Debug("Created SOFTWARE admins group object", "name", adminsName, "gidNumber", gidNumber)
+	return fmt.Sprintf("CN=%s,%s", adminsName, parentDN), nil
+}
+
+// SoftwareAddAdmin adds an admin to the SOFTWARE group with the given name,
+// creating its admins group first if it has none.
+func SoftwareAddAdmin(ctx context.Context, softwareName string, adminUsername string) error {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return fmt.Errorf("config not found in context")
+	}
+	softwareDN, err := getSWDN(ctx, softwareName)
+	if err != nil {
+		return fmt.Errorf("failed to get SOFTWARE DN: %w", err)
+	}
+	userDN, err := getUserDN(ctx, adminUsername)
+	if err != nil {
+		return fmt.Errorf("failed to get user DN: %w", err)
+	}
+
+	// Check if the user is a member of the SOFTWARE group
+	inGroup, err := ld.UserInGroup(ctx, softwareDN, userDN)
+	if err != nil {
+		return fmt.Errorf("failed to check if user is in group: %w", err)
+	}
+	if !inGroup {
+		slog.Debug("User not in SOFTWARE", "userDN", userDN, "softwareDN", softwareDN)
+		return fmt.Errorf("user %s is not a member of SOFTWARE %s", adminUsername, softwareName)
+	}
+
+	adminsDN, found, err := findSWAdminsDN(ctx, softwareName)
+	if err != nil {
+		return fmt.Errorf("failed to find SOFTWARE admins group DN: %w", err)
+	}
+	if !found {
+		adminsDN, err = createSWAdminsGroup(ctx, softwareName, softwareDN)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Check if the user is already an admin of the SOFTWARE group
+	inGroup, err = ld.UserInGroup(ctx, adminsDN, userDN)
+	if err != nil {
+		return fmt.Errorf("failed to check if user is in group: %w", err)
+	}
+	if inGroup {
+		slog.Debug("User already in SOFTWARE admins group", "userDN", userDN, "adminsDN", adminsDN)
+		return nil
+	}
+	err = ld.AddUserToGroup(ctx, adminsDN, userDN)
+	if err != nil {
+		return fmt.Errorf("failed to add admin %s to SOFTWARE %s: %w", adminUsername, softwareName, err)
+	}
+	slog.Debug("Added admin to SOFTWARE", "userDN", userDN, "adminsDN", adminsDN)
+
+	// Add the user to the top level admins group, if one is configured
+	if cfg.SoftwareAdminsGroupDN == "" {
+		return nil
+	}
+	inGroup, err = ld.UserInGroup(ctx, cfg.SoftwareAdminsGroupDN, userDN)
+	if err != nil {
+		return fmt.Errorf("failed to check if user is in group: %w", err)
+	}
+	if inGroup {
+		return nil
+	}
+	err = ld.AddUserToGroup(ctx, cfg.SoftwareAdminsGroupDN, userDN)
+	if err != nil {
+		return fmt.Errorf("failed to add admin %s to top level admins group: %w", adminUsername, err)
+	}
+	slog.Debug("Added user to top level software admins group", "userDN", userDN)
+	return nil
+}
+
+// SoftwareRemoveAdmin removes an admin from the SOFTWARE group with the
+// given name. It does nothing if the group has no admins group.
+func SoftwareRemoveAdmin(ctx context.Context, softwareName string, adminUsername string) error {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return fmt.Errorf("config not found in context")
+	}
+	adminsDN, found, err := findSWAdminsDN(ctx, softwareName)
+	if err != nil {
+		return fmt.Errorf("failed to find SOFTWARE admins group DN: %w", err)
+	}
+	if !found {
+		slog.Debug("SOFTWARE has no admins group", "name", softwareName)
+		return nil
+	}
+	userDN, fallback, err := ld.ResolveMemberDN(ctx, adminsDN, adminUsername)
+	if err != nil {
+		return fmt.Errorf("failed to get user DN: %w", err)
+	}
+
+	inGroup, err := ld.UserInGroup(ctx, adminsDN, userDN)
+	if err != nil {
+		return fmt.Errorf("failed to check if user is in group: %w", err)
+	}
+	if !inGroup {
+		slog.Debug("User not in SOFTWARE admins group", "userDN", userDN, "adminsDN", adminsDN)
+		return nil
+	}
+	err = ld.RemoveUserFromGroup(ctx, adminsDN, userDN)
+	if err != nil {
+		return fmt.Errorf("failed to remove admin %s from SOFTWARE %s: %w", adminUsername, softwareName, err)
+	}
+	slog.Debug("Removed admin from SOFTWARE", "userDN", userDN, "adminsDN", adminsDN)
+
+	// A user missing from AD can't be looked up to clean up the top level group
+	if fallback || cfg.SoftwareAdminsGroupDN == "" {
+		return nil
+	}
+
+	// Remove the user from the top level admins if they are not an admin of any other SOFTWARE
+	isAdmin, err := userIsAdminInAnySoftware(ctx, userDN)
+	if err != nil {
+		return fmt.Errorf("failed to check if user is admin in any SOFTWARE: %w", err)
+	}
+	if isAdmin {
+		slog.Debug("User still an admin in another SOFTWARE, not removing from top level admins group", "userDN", userDN)
+		return nil
+	}
+	inGroup, err = ld.UserInGroup(ctx, cfg.SoftwareAdminsGroupDN, userDN)
+	if err != nil {
+		return fmt.Errorf("failed to check if user is in group: %w", err)
+	}
+	if !inGroup {
+		return nil
+	}
+	err = ld.RemoveUserFromGroup(ctx, cfg.SoftwareAdminsGroupDN, userDN)
+	if err != nil {
+		return fmt.Errorf("failed to remove admin %s from top level admins group: %w", adminUsername, err)
+	}
+	slog.Debug("Removed user from top level software admins group", "userDN", userDN)
+	return nil
+}
+
+// userIsAdminInAnySoftware checks if the user is in the admins group of any SOFTWARE.
+func userIsAdminInAnySoftware(ctx context.Context, userDN string) (bool, error) {
+	userGroups, err := ld.GetGroupsForUser(ctx, userDN)
+	if err != nil {
+		return false, fmt.Errorf("failed to get user groups: %w", err)
+	}
+	for _, groupDN := range userGroups {
+		groupName, err := ld.ConvertDNToObjectName(groupDN)
+		if err != nil {
+			return false, fmt.Errorf("failed to convert DN to object name: %w", err)
+		}
+		groupName = strings.ToLower(groupName)
+		if strings.HasPrefix(groupName, groupPrefix) && strings.HasSuffix(groupName, managedgroup.Software.AdminsSuffix) {
+			slog.Debug("User found as admin in SOFTWARE", "userDN", userDN, "groupDN", groupDN)
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,171 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/uoracs/directory-manager/internal/cephfs"
+	"github.com/uoracs/directory-manager/internal/cephs3"
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/keys"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/managedgroup"
+	"github.com/uoracs/directory-manager/internal/pirg"
+)
+
+// Role is one managed group where a user holds the PI, owner, or sponsor role.
+type Role struct {
+	Family string `json:"family"`
+	Name   string `json:"name"`
+	Role   string `json:"role"`
+	// GroupDN is only set for software groups, whose sponsor is stored in managedBy.
+	GroupDN string `json:"-"`
+}
+
+func (r Role) String() string {
+	return fmt.Sprintf("%s %s %s", r.Family, r.Name, r.Role)
+}
+
+// Result is the outcome of transferring one role.
+type Result struct {
+	Role
+	Transferred bool   `json:"transferred"`
+	Error       string `json:"error,omitempty"`
+}
+
+// Plan finds every managed group where fromUsername holds a role, skipping
+// families disabled in configuration. If shortName is set, only groups with
+// that short name are included. It fails before looking at any group if
+// toUsername doesn't exist or is the same user as fromUsername.
+func Plan(ctx context.Context, fromUsername string, toUsername string, shortName string) ([]Role, error) {
+	cfg := ctx.Value(keys.ConfigKey).(*config.Config)
+	if cfg == nil {
+		return nil, fmt.Errorf("config not found in context")
+	}
+	fromDN, err := ld.GetUserDN(ctx, fromUsername)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user DN: %w", err)
+	}
+	toDN, err := ld.GetUserDN(ctx, toUsername)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user DN: %w", err)
+	}
+	if strings.EqualFold(fromDN, toDN) {
+		return nil, fmt.Errorf("%s and %s are the same user", fromUsername, toUsername)
+	}
+	groupDNs, err := ld.GetGroupsForUser(ctx, fromDN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get groups for user %s: %w", fromUsername, err)
+	}
+
+	var roles []Role
+	for _, groupDN := range groupDNs {
+		groupName, err := ld.ConvertDNToObjectName(groupDN)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert DN to object name: %w", err)
+		}
+		family, ok := ld.ClassifyGroupName(groupName)
+		if !ok {
+			continue
+		}
+		// Software groups have no role group; their sponsor is the managedBy attribute
+		f, _ := managedgroup.ByName(family)
+		if f.RoleSuffix == "" || cfg.DisabledSubsystem(family) != "" {
+			continue
+		}
+		name := strings.TrimPrefix(strings.ToLower(groupName), f.Prefix)
+		name, ok = strings.CutSuffix(name, f.RoleSuffix)
+		if !ok || strings.Contains(name, ".") {
+			continue
+		}
+		if shortName != "" && !strings.EqualFold(name, shortName) {
+			continue
+		}
+		roles = append(roles, Role{Family: family, Name: name, Role: f.RoleName})
+	}
+
+	var sponsored []Role
+	if cfg.DisabledSubsystem("software") == "" {
+		sponsored, err = sponsoredSoftware(ctx, cfg.LDAPSoftwareDN, fromDN)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, r := range sponsored {
+		if shortName != "" && !strings.EqualFold(r.Name, shortName) {
+			continue
+		}
+		roles = append(roles, r)
+	}
+
+	slog.Debug("Planned ownership transfer", "from", fromUsername, "roles", len(roles))
+	return roles, nil
+}
+
+// sponsoredSoftware returns the software groups whose managedBy is userDN.
+func sponsoredSoftware(ctx context.Context, softwareDN string, userDN string) ([]Role, error) {
+	l, err := ld.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	searchRequest := ldap.NewSearchRequest(
+		softwareDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0, 0, false,
+		fmt.Sprintf("(&(objectClass=group)(managedBy=%s))", ldap.EscapeFilter(userDN)),
+		[]string{"cn"},
+		nil,
+	)
+	sr, err := l.Search(searchRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search LDAP: %w", err)
+	}
+	var roles []Role
+	for _, entry := range sr.Entries {
+		name := strings.TrimPrefix(strings.ToLower(entry.GetAttributeValue("cn")), managedgroup.Software.Prefix)
+		roles = append(roles, Role{Family: "software", Name: name, Role: "sponsor", GroupDN: entry.DN})
+	}
+	return roles, nil
+}
+
+// Execute hands every role in plan to toUsername, using the same functions
+// as set-pi and set-owner. It carries on past failures so the results show
+// exactly which roles moved and which still need to be finished by hand.
+func Execute(ctx context.Context, plan []Role, fromUsername string, toUsername string) []Result {
+	results := make([]Result, 0, len(plan))
+	toDN := ""
+	for _, r := range plan {
+		var err error
+		switch r.Family {
+		case "pirg":
+			err = pirg.PirgSetPI(ctx, r.Name, toUsername)
+		case "cephfs":
+			err = cephfs.CEPHFSSetOWNER(ctx, r.Name, toUsername)
+		case "cephs3":
+			err = cephs3.Cephs3SetOWNER(ctx, r.Name, toUsername)
+		case "software":
+			if toDN == "" {
+				toDN, err = ld.GetUserDN(ctx, toUsername)
+			}
+			if err == nil {
+				err = ld.SetManagedBy(ctx, r.GroupDN, toDN)
+			}
+		default:
+			err = fmt.Errorf("unknown family %s", r.Family)
+		}
+
+		result := Result{Role: r, Transferred: err == nil}
+		if err != nil {
+			result.Error = err.Error()
+			slog.Error("Failed to transfer role", "role", r.String(), "from", fromUsername, "to", toUsername, "error", err)
+		} else {
+			slog.Info("Transferred role", "role", r.String(), "from", fromUsername, "to", toUsername)
+		}
+		results = append(results, result)
+	}
+	return results
+}
@@ -0,0 +1,59 @@
+// Package webhook posts events to a configured URL for the changes the
+// tool makes, so a provisioning pipeline can react to group changes.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// timeout bounds the post, so a slow receiver delays a command by at most
+// this long.
+const timeout = 5 * time.Second
+
+// Event is one change to the directory, as posted.
+type Event struct {
+	Operation string `json:"operation"`
+	Namespace string `json:"namespace"`
+	Group     string `json:"group,omitempty"`
+	User      string `json:"user,omitempty"`
+	// Change is the LDAP operation: add, modify, rename, or delete.
+	Change string `json:"change"`
+	// DN is the object changed, and Member the member added or removed.
+	DN        string    `json:"dn"`
+	Member    string    `json:"member,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	// Result is success or failure, with Error saying why it failed.
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Send posts the events to url in one request, as a JSON array in the order
+// they happened. It has its own timeout and ignores ctx's cancellation, so
+// the changes a command made before a timeout or Ctrl-C are still posted.
+func Send(ctx context.Context, url string, events []Event) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+	body, err := json.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("failed to encode events: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post %d event(s): %w", len(events), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to post %d event(s): %s", len(events), resp.Status)
+	}
+	return nil
+}
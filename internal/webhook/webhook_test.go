@@ -0,0 +1,83 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// receiver returns the URL of a server that decodes each post into posts.
+func receiver(t *testing.T, status int, posts *[][]Event) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if ct := req.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		var events []Event
+		if err := json.NewDecoder(req.Body).Decode(&events); err != nil {
+			t.Errorf("decoding post: %v", err)
+		}
+		*posts = append(*posts, events)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestSendBatches(t *testing.T) {
+	var posts [][]Event
+	url := receiver(t, http.StatusNoContent, &posts)
+	var events []Event
+	for i := range 500 {
+		events = append(events, Event{Operation: "add-member", Change: "modify", Member: fmt.Sprintf("CN=u%d", i), Result: "success"})
+	}
+	if err := Send(context.Background(), url, events); err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 {
+		t.Fatalf("%d posts, want 1", len(posts))
+	}
+	if len(posts[0]) != len(events) {
+		t.Fatalf("%d events posted, want %d", len(posts[0]), len(events))
+	}
+	for i, event := range posts[0] {
+		if event.Member != events[i].Member {
+			t.Fatalf("event %d is for %s, want %s", i, event.Member, events[i].Member)
+		}
+	}
+}
+
+func TestSendAfterCancel(t *testing.T) {
+	var posts [][]Event
+	url := receiver(t, http.StatusOK, &posts)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Send(ctx, url, []Event{{Operation: "delete", Change: "delete", Result: "success"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 {
+		t.Errorf("%d posts after the command was cancelled, want 1", len(posts))
+	}
+}
+
+func TestSendErrorStatus(t *testing.T) {
+	var posts [][]Event
+	url := receiver(t, http.StatusInternalServerError, &posts)
+	err := Send(context.Background(), url, []Event{{Operation: "add-member"}, {Operation: "add-member"}})
+	if err == nil || !strings.Contains(err.Error(), "2 event(s)") || !strings.Contains(err.Error(), "500") {
+		t.Errorf("Send error = %v, want the events and status", err)
+	}
+}
+
+func TestSendUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+	if err := Send(context.Background(), url, []Event{{Operation: "add-member"}}); err == nil {
+		t.Error("no error posting to a closed server")
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+const archiveDN = "OU=Archive,OU=RACS,DC=ad,DC=uoregon,DC=edu"
+
+// addGroupCopy adds a group named cn under parentDN, creating parentDN as
+// an OU first if it doesn't exist, to stand in for an old copy of a group.
+func (e *testEnv) addGroupCopy(t *testing.T, parentDN string, cn string, members ...string) string {
+	t.Helper()
+	if !e.dir.Exists(parentDN) {
+		ou := ldap.NewAddRequest(parentDN, nil)
+		ou.Attribute("objectClass", []string{"organizationalUnit"})
+		if err := e.dir.Add(ou); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dn := "CN=" + cn + "," + parentDN
+	group := ldap.NewAddRequest(dn, nil)
+	group.Attribute("objectClass", []string{"group"})
+	group.Attribute("cn", []string{cn})
+	if len(members) > 0 {
+		group.Attribute("member", members)
+	}
+	if err := e.dir.Add(group); err != nil {
+		t.Fatal(err)
+	}
+	return dn
+}
+
+// TestArchivedCopyIgnored checks that a copy of a PIRG group outside the
+// PIRG OU isn't read or changed by list-members, add-member, or delete.
+func TestArchivedCopyIgnored(t *testing.T) {
+	env := newTestEnv(t, "")
+	env.mustRun(t, "pirg", "beta", "create", "--pi", "bob")
+	archivedDN := env.addGroupCopy(t, archiveDN, "is.racs.pirg.beta", aliceDN)
+
+	if got := env.mustRun(t, "pirg", "beta", "list-members"); got != "bob\n" {
+		t.Errorf("list-members = %q, want the live group's bob", got)
+	}
+	env.mustRun(t, "pirg", "beta", "add-member", "carol")
+	if !env.hasMember(betaDN, carolDN) || env.hasMember(archivedDN, carolDN) {
+		t.Error("add-member didn't go to the live group only")
+	}
+	env.mustRun(t, "pirg", "beta", "remove-member", "carol")
+	env.mustRun(t, "pirg", "beta", "delete")
+	if env.dir.Exists(betaDN) {
+		t.Error("delete left the live group")
+	}
+	if !env.dir.Exists(archivedDN) || !env.hasMember(archivedDN, aliceDN) {
+		t.Error("delete touched the archived copy")
+	}
+}
+
+// TestDuplicateCNRefused checks that list-members, add-member, and delete
+// fail, listing both DNs, when two groups in the PIRG OU share a CN.
+func TestDuplicateCNRefused(t *testing.T) {
+	env := newTestEnv(t, "")
+	copyDN := env.addGroupCopy(t, "OU=old,OU=PIRGS,OU=RACS,DC=ad,DC=uoregon,DC=edu", "is.racs.pirg.alpha")
+	for _, args := range [][]string{
+		{"pirg", "alpha", "list-members"},
+		{"pirg", "alpha", "add-member", "carol"},
+		{"pirg", "alpha", "delete"},
+	} {
+		code, stdout, _ := env.run(args...)
+		if code == 0 {
+			t.Errorf("%s exited 0 with a duplicate CN:\n%s", strings.Join(args, " "), stdout)
+		}
+		for _, want := range []string{"multiple groups named is.racs.pirg.alpha found", alphaDN, copyDN} {
+			if !strings.Contains(stdout, want) {
+				t.Errorf("%s output doesn't contain %q:\n%s", strings.Join(args, " "), want, stdout)
+			}
+		}
+	}
+	if env.hasMember(alphaDN, carolDN) || env.hasMember(copyDN, carolDN) {
+		t.Error("add-member changed a group despite the duplicate")
+	}
+	if !env.dir.Exists(alphaDN) || !env.dir.Exists(copyDN) {
+		t.Error("delete removed a group despite the duplicate")
+	}
+}
@@ -1,28 +1,76 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/alecthomas/kong"
-	"github.com/go-ldap/ldap/v3"
 	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/consistency"
 	"github.com/uoracs/directory-manager/internal/keys"
 	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/logging"
+	"github.com/uoracs/directory-manager/internal/managedgroup"
+	"github.com/uoracs/directory-manager/internal/opcache"
 	"github.com/uoracs/directory-manager/internal/pirg"
+	"github.com/uoracs/directory-manager/internal/agent"
 	"github.com/uoracs/directory-manager/internal/cephfs"
 	"github.com/uoracs/directory-manager/internal/cephs3"
 	"github.com/uoracs/directory-manager/internal/software"
+	"github.com/uoracs/directory-manager/internal/report"
+	"github.com/uoracs/directory-manager/internal/transfer"
 )
 
 var version = "v1.1.6"
 
-var CLI struct {
+// cliArgs is the command line kong parses into CLI.
+type cliArgs struct {
 	Config  string      `help:"Path to the configuration file." short:"c" type:"path"`
 	Debug   bool        `help:"Enable debug mode." short:"d" type:"bool"`
 	Version VersionFlag `help:"Show version." short:"v" type:"bool"`
+	LogFormat string    `help:"Log format (text or json)." enum:",text,json" default:""`
+	LogLevel  string    `help:"Log level (debug, info, warn, error)."`
+	LogFile   string    `help:"Also write logs to this file." type:"path"`
+	Output    string    `help:"Output format (text, json, or table; report commands and pirg contacts also take csv)." short:"o" enum:"text,json,table,csv" default:"text"`
+	JSONCompact *bool   `help:"Print JSON on one line. By default JSON is indented on a terminal and on one line otherwise." name:"json-compact" negatable:""`
+	Timeout   time.Duration `help:"Give up on the command after this long (e.g. 30s, 5m). Unlimited by default."`
+	PageSize  int           `help:"Entries per page for large searches (1-1000). Overrides ldap_page_size."`
+	NoCache   bool          `help:"Don't skip add-member and remove-member calls repeated within op_cache_ttl_minutes."`
+	Reason    string        `help:"Why the change is made, e.g. a ticket number. Logged, and noted in the group's info attribute."`
+	Parallel  int           `help:"Add or remove up to N members at once, each over its own LDAP connection (at most 8). Serial by default." placeholder:"N"`
+	OverrideFreeze bool     `help:"Change a frozen PIRG or cephfs group anyway. Requires --reason; the override is logged."`
+	OverrideMaintenance bool `help:"Make a change during a maintenance window anyway. Requires --reason; the override is logged."`
+	SkipUnknown    bool     `help:"When adding several users, skip usernames not found in AD instead of changing nothing."`
+	PreferDC       string   `help:"Connect to this server from ldap_servers first, falling back to the others only if it is down." name:"prefer-dc" placeholder:"HOST"`
+	Verbose        bool     `help:"Print the group's member count before and after add-member or remove-member with a single member. Several members, serial or --parallel, are always counted; nothing else is affected."`
+
+	VersionCmd struct {
+		JSON  bool `help:"Print version and build metadata as JSON." name:"json"`
+		Check bool `help:"Check GitHub for a newer release. Exits 5 if one is available."`
+	} `cmd:"" name:"version" help:"Show version and build metadata."`
+	Schema struct{} `cmd:"" help:"Print the naming conventions of every managed group family as JSON."`
+	Maintenance struct {
+		Start struct {
+			Until string `help:"When the window ends: YYYY-MM-DDTHH:MM local time, or RFC 3339." required:""`
+		} `cmd:"" help:"Block every change to the directory until the given time. Requires --reason."`
+		Status struct{} `cmd:"" help:"Show the maintenance window in force, if any."`
+		End    struct{} `cmd:"" help:"End the maintenance window now."`
+	} `cmd:"" help:"Block changes to the directory during maintenance."`
+	ConfigCmd struct {
+		Validate struct{} `cmd:"" help:"Check that the base DN of every enabled family is an OU in AD."`
+	} `cmd:"" name:"config" help:"Check configuration against the directory."`
+	Doctor struct{} `cmd:"" help:"Check that the directory has the OUs, groups, and attributes the tool expects, without changing anything."`
 
 	Aduser struct {
 		Name struct {
@@ -30,103 +78,337 @@ var CLI struct {
 				GetUid  struct{} `cmd:"" help:"Get the UID of a User in AD."`
 				RemoveTalapasGroupUser  struct{} `cmd:"" help:"Remove a user from the main Talapas group"`
 				AddTalapasGroupUser  struct{} `cmd:"" help:"Add a user to the main Talapas group"`
+				Reconcile struct {
+					DryRun bool `help:"Print the action without making it."`
+				} `cmd:"" help:"Add the user to the main Talapas group if they are in any managed group, or remove them if in none"`
+				PosixGroups struct{} `cmd:"" name:"posix-groups" help:"List the managed groups the user is in, with their GIDs, to compare with id on a node."`
+				Owned       struct{} `cmd:"" help:"List the PIRGs the user is the PI of, and the cephfs and cephs3 groups they own."`
 		} `arg:""`
 	} `cmd:"" help:"Manage PIRGs."`
 	Pirg struct {
 		List struct {
-		} `cmd:"" help:"List all PIRGs."`
+			ChangedSince string `help:"Only list PIRGs changed at or after this time (YYYY-MM-DD or RFC 3339; dates are UTC)." xor:"long"`
+			Long         bool   `help:"Also show which PIRGs are frozen, and why, and their storage quotas." short:"l" xor:"long"`
+			PI           string `help:"Only list the PIRGs this user is the PI of." name:"pi" placeholder:"USERNAME" xor:"long"`
+			BaseDN       string `help:"List PIRGs under this OU instead of ldap_pirg_dn, e.g. an archive OU." name:"base-dn" placeholder:"DN"`
+		} `cmd:"" aliases:"ls" help:"List all PIRGs."`
+		Contacts struct {
+			Pirgs []string `help:"Only list the contacts of these PIRGs." name:"pirg" placeholder:"NAME"`
+		} `cmd:"" help:"List the PI and admins of every PIRG with their display name and mail, e.g. -o csv for an outage notice."`
+		Orphans struct {
+			List  struct{} `cmd:"" default:"1" help:"List OUs under the PIRG OU with no PIRG group in them, and any groups left inside."`
+			Clean struct {
+				Yes bool `help:"Skip the confirmation prompt." short:"y"`
+			} `cmd:"" help:"Delete the orphan OUs that hold no groups. Orphans with groups left in them are listed and kept."`
+		} `cmd:"" help:"Find OUs left behind by failed creates and deleted PIRGs."`
 		Name struct {
 			Name string `arg:""`
 
+			Info     struct{} `cmd:"" help:"Show a PIRG's DN, PI, member count, and whether it is frozen."`
+			Freeze   struct{} `cmd:"" help:"Refuse changes to a PIRG until it is unfrozen. Requires --reason."`
+			Unfreeze struct{} `cmd:"" help:"Allow changes to a frozen PIRG again."`
+
 			Create struct {
-				PI string `required:"" help:"Name of the PI." type:"name"`
-			} `cmd:"" help:"Create a new PIRG."`
-			Delete struct{} `cmd:"" help:"Delete a PIRG."`
+				PI               string `required:"" help:"Name of the PI." type:"name"`
+				Mail             bool   `help:"Mail-enable the PIRG group using pirg_mail_template." xor:"mail"`
+				NoMail           bool   `help:"Do not mail-enable the PIRG group." xor:"mail"`
+				OverridePiPolicy bool   `help:"Allow a PI that pi_eligibility would reject. The exception is logged."`
+				AllowDisabled    bool   `help:"Allow a disabled or expired account."`
+				NoMember         bool   `help:"Record the PI in the PI and admins groups without making them a member of the PIRG."`
+				Members          []string `help:"Also add these members, repeatable or comma-separated." name:"member" sep:","`
+				Admins           []string `help:"Also add these admins, who are made members too, repeatable or comma-separated." name:"admin" sep:","`
+				RollbackOnError  bool   `help:"Delete the new PIRG again if adding a member or admin fails."`
+			} `cmd:"" aliases:"mk" help:"Create a new PIRG."`
+			Delete struct {
+				DryRun bool `help:"List what stands in the way of deleting the PIRG instead of deleting it."`
+			} `cmd:"" aliases:"del" help:"Delete a PIRG."`
 			GetPI  struct{} `cmd:"" help:"Get the PI of a PIRG."`
+			Check  struct {
+				Fix      bool   `help:"Repair the problems that can be fixed automatically."`
+				AdminFix string `help:"How --fix repairs admins who aren't members: add-to-main or remove-from-admins. Without it they are only reported." enum:",add-to-main,remove-from-admins" default:""`
+			} `cmd:"" help:"Check a PIRG for inconsistencies."`
+			DNs struct{} `cmd:"" name:"dns" help:"Print the DNs of the PIRG's main, admins, PI, and subgroups."`
 			SetPI  struct {
-				PI string `required:"" name:"pi" help:"Name of the PI." type:"name"`
+				PI               string `required:"" name:"pi" help:"Name of the PI." type:"name"`
+				OverridePiPolicy bool   `help:"Allow a PI that pi_eligibility would reject. The exception is logged."`
+				AllowDisabled    bool   `help:"Allow a disabled or expired account."`
 			} `cmd:"" help:"Set the PI of a PIRG."`
-			ListMembers struct{} `cmd:"" help:"List all members of a PIRG."`
+			FixPI   struct{} `cmd:"" help:"Re-add the PI to the PIRG's main and admins groups if missing."`
+			GetMail struct{} `cmd:"" help:"Get the mail address of a PIRG."`
+			SetMail struct {
+				Address string `arg:"" help:"Primary mail address."`
+			} `cmd:"" help:"Set the mail address of a PIRG."`
+			ClearMail struct{} `cmd:"" help:"Clear the mail address of a PIRG."`
+			GetQuota  struct{} `cmd:"" help:"Get the storage quotas of a PIRG."`
+			SetQuota  struct {
+				Projects string `help:"Quota for /projects/<name>, e.g. 10T (K, M, G, T are powers of 1024), or none to clear it." placeholder:"SIZE"`
+				Scratch  string `help:"Quota for scratch, e.g. 20T, or none to clear it." placeholder:"SIZE"`
+			} `cmd:"" help:"Set the storage quotas of a PIRG."`
+			ListMembers struct {
+				Fields        []string `help:"Comma-separated fields to print (username, dn, mail, displayName, or any user attribute)." sep:"," xor:"exclude,count"`
+				ExcludeAdmins bool     `help:"Only list members who are neither the PI nor an admin." xor:"exclude,count-only"`
+				Count         bool     `help:"Print only the number of members." xor:"count,count-only"`
+				DNOnly        bool     `help:"Print the full DN of each member instead of the username." name:"dn-only" xor:"exclude,count"`
+				Summary       bool     `help:"Print the number of members before the list." xor:"count"`
+				Limit         int      `help:"Print at most N members, after sorting." placeholder:"N"`
+				Offset        int      `help:"Skip the first N members, after sorting." placeholder:"N"`
+				Sort          string   `help:"Sort members by name (username) or dn, ignoring case, before --limit and --offset." enum:",name,dn" default:"" placeholder:"name|dn"`
+			} `cmd:"" aliases:"ls" help:"List all members of a PIRG."`
 			AddMember   struct {
-				Usernames []string `arg:"" name:"username" help:"Names of the members." type:"name"`
-			} `cmd:"" help:"Add members to a PIRG."`
+				Usernames     []string `arg:"" optional:"" name:"username" help:"Names of the members." type:"name"`
+				DNs           []string `name:"dn" sep:"none" help:"Full DN of a member, repeatable. Skips the username lookup and also accepts objects that aren't user accounts."`
+				WarnMultiPirg bool     `help:"Warn when a user is already in another PIRG." xor:"multi-pirg"`
+				DenyMultiPirg bool     `help:"Refuse to add users who are already in another PIRG." xor:"multi-pirg"`
+				AllowDisabled bool     `help:"Allow disabled or expired accounts."`
+			} `cmd:"" aliases:"add" help:"Add members to a PIRG."`
 			RemoveMember struct {
-				Usernames []string `arg:"" name:"username" help:"Names of the members." type:"name"`
-			} `cmd:"" help:"Remove members from a PIRG."`
-			ListAdmins struct{} `cmd:"" help:"List all admins of a PIRG."`
+				Usernames    []string `arg:"" optional:"" name:"username" help:"Names of the members." type:"name"`
+				DNs          []string `name:"dn" sep:"none" help:"Full DN of a member, repeatable. Skips the username lookup and also accepts objects that aren't user accounts." xor:"source"`
+				FromSubgroup string   `help:"Remove every member of the named subgroup from that subgroup." xor:"source"`
+				AlsoParent   bool     `help:"With --from-subgroup, also remove those members from the PIRG."`
+				MembersOf    string   `help:"Remove every member who is also a member of the named PIRG." xor:"source"`
+				AllRegular   bool     `help:"Remove every member who is neither the PI nor an admin, e.g. students at the end of a term." xor:"source"`
+				Except       []string `help:"With --all-regular, comma-separated usernames to keep." sep:","`
+				Yes          bool     `help:"Skip the confirmation prompt." short:"y"`
+			} `cmd:"" aliases:"rm" help:"Remove members from a PIRG."`
+			Exposure   struct{} `cmd:"" help:"List other managed groups the members of a PIRG are in."`
+			AddMissing struct {
+				Members []string `help:"Comma-separated usernames that should be members." sep:","`
+				Admins  []string `help:"Comma-separated usernames that should be admins. They are made members too." sep:","`
+				DryRun  bool     `help:"Show what would be added without making changes."`
+			} `cmd:"" help:"Add the given members and admins a PIRG is missing, never removing anyone."`
+			ListAdmins struct {
+				Fields   []string `help:"Comma-separated fields to print (username, dn, mail, displayName, or any user attribute)." sep:"," xor:"fields"`
+				WithRole bool     `help:"Mark which admin is the PI." xor:"fields"`
+			} `cmd:"" help:"List all admins of a PIRG."`
 			AddAdmin   struct {
-				Usernames []string `arg:"" name:"username" help:"Names of the admins." type:"name"`
+				Usernames     []string `arg:"" name:"username" help:"Names of the admins." type:"name"`
+				AllowDisabled bool     `help:"Allow disabled or expired accounts."`
 			} `cmd:"" help:"Add admins to a PIRG."`
 			RemoveAdmin struct {
 				Usernames []string `arg:"" name:"username" help:"Names of the admins." type:"name"`
 			} `cmd:"" help:"Remove admins from a PIRG."`
 			Subgroup struct {
-				List struct{} `cmd:"" help:"List all subgroups."`
+				List struct {
+					Long bool `help:"Also show each subgroup's GID and description." short:"l"`
+				} `cmd:"" aliases:"ls" help:"List all subgroups."`
 				Name struct {
-					Name        string   `arg`
-					Create      struct{} `cmd:"" help:"Create a new subgroup."`
-					Delete      struct{} `cmd:"" help:"Delete a subgroup."`
-					ListMembers struct{} `cmd:"" help:"List all members of a subgroup."`
+					Name        string   `arg:""`
+					Create      struct {
+						Description string `help:"What the subgroup is for."`
+					} `cmd:"" aliases:"mk" help:"Create a new subgroup."`
+					SetDescription struct {
+						Description string `arg:"" help:"What the subgroup is for. Empty clears it."`
+					} `cmd:"" help:"Set the description of a subgroup."`
+					Delete      struct {
+						Force bool `help:"Remove all members before deleting the subgroup."`
+					} `cmd:"" aliases:"del" help:"Delete a subgroup."`
+					ListMembers struct {
+						Fields  []string `help:"Comma-separated fields to print (username, dn, mail, displayName, or any user attribute)." sep:"," xor:"columns,count"`
+						WithUID bool     `help:"Print each member as username:uid." name:"with-uid" xor:"columns,count"`
+						Count   bool     `help:"Print only the number of members." xor:"count"`
+						DNOnly  bool     `help:"Print the full DN of each member instead of the username." name:"dn-only" xor:"columns,count"`
+						Summary bool     `help:"Print the number of members before the list." xor:"count"`
+					} `cmd:"" aliases:"ls" help:"List all members of a subgroup."`
 					AddMember   struct {
 						Usernames []string `arg:"" name:"username" help:"Names of the members." type:"name"`
-					} `cmd:"" help:"Add members to a subgroup."`
+					} `cmd:"" aliases:"add" help:"Add members to a subgroup."`
 					RemoveMember struct {
 						Usernames []string `arg:"" name:"username" help:"Names of the members." type:"name"`
-					} `cmd:"" help:"Remove members from a subgroup."`
-				} `arg`
+					} `cmd:"" aliases:"rm" help:"Remove members from a subgroup."`
+					SetMembers struct {
+						Members  []string `help:"A member the subgroup should have, repeatable or comma-separated." name:"member" sep:","`
+						FromFile string   `help:"Read members from this file, one username per line, or - for stdin. Blank lines and lines starting with # are skipped." placeholder:"PATH"`
+						DryRun   bool     `help:"Show what would be added and removed without making changes."`
+					} `cmd:"" help:"Make a subgroup's members exactly the given PIRG members, adding and removing as needed."`
+					GetManager struct{} `cmd:"" help:"Get the delegated manager of a subgroup."`
+					SetManager struct {
+						Username string `arg:"" help:"Name of the manager, who must be a PIRG member." type:"name"`
+					} `cmd:"" help:"Delegate subgroup member management to a PIRG member."`
+				} `arg:""`
 			} `cmd:"" help:"Manage subgroups."`
 		} `arg:""`
 	} `cmd:"" help:"Manage PIRGs."`
 
+	Admins struct {
+		List struct {
+			Namespace string `required:"" enum:"pirg,cephfs,cephs3" help:"Which top-level admins group to list (pirg, cephfs, cephs3)."`
+		} `cmd:"" aliases:"ls" help:"List all members of a top-level admins group."`
+	} `cmd:"" help:"Inspect top-level admins groups."`
+
 	Nextgidnumber struct {
 	} `cmd:"" help:"Get the next available GID number in the specified range."`
 
+	Gid struct {
+		Usage struct{} `cmd:"" help:"Show how much of each family's GID range is allocated."`
+	} `cmd:"" help:"Inspect GID allocation."`
+
+	TransferOwnership struct {
+		From   string `required:"" help:"User giving up their roles." type:"name"`
+		To     string `required:"" help:"User taking over the roles." type:"name"`
+		Pirg   string `help:"Only transfer roles in groups with this short name." type:"name"`
+		DryRun bool   `help:"Show the plan without making changes."`
+		Yes    bool   `help:"Skip the confirmation prompt." short:"y"`
+	} `cmd:"" help:"Hand every PIRG PI, cephfs/cephs3 owner, and software sponsor role a user holds to another user."`
+
+	Migrate struct {
+		Prefix struct {
+			Family      string `required:"" enum:"pirg,cephfs,cephs3,software" help:"Family whose groups are renamed."`
+			From        string `required:"" help:"Current prefix, e.g. is.racs.pirg."`
+			To          string `required:"" help:"New prefix, e.g. is.hpc.pirg."`
+			MappingFile string `help:"Write the old and new name of every renamed group to this CSV file. Required unless --dry-run is given." type:"path"`
+			DryRun      bool   `help:"Show the plan without making changes."`
+			Yes         bool   `help:"Skip the confirmation prompt." short:"y"`
+		} `cmd:"" help:"Rename every group of a family, with its admins, role, and subgroups, from one name prefix to another."`
+	} `cmd:"" help:"Migrate groups to new naming conventions."`
+
+	Check struct {
+		NameCollisions struct{} `cmd:"" help:"List group short names used in more than one namespace, such as a PIRG and a cephfs group both named foo."`
+	} `cmd:"" help:"Audit the directory across namespaces."`
+
+	Memberof struct {
+		DN string `required:"" name:"dn" help:"Full DN of the user, computer, service account, or other object."`
+	} `cmd:"" name:"memberof" help:"List the groups an object is a direct member of, by family and short name."`
+
+	Raw struct {
+		Search struct {
+			Base   string   `required:"" help:"DN to search under." placeholder:"DN"`
+			Filter string   `help:"LDAP filter, sent as given after a syntax check." default:"(objectClass=*)"`
+			Attrs  []string `help:"Comma-separated attributes to return. All of them by default." sep:","`
+			Scope  string   `help:"Search the base entry only, one level below it, or the whole subtree." enum:"base,one,sub" default:"sub"`
+		} `cmd:"" help:"Run a read-only LDAP search over the tool's connection, printing LDIF, or JSON with -o json."`
+	} `cmd:"" help:"Query the directory directly. Nothing here can change it."`
+
+	Report struct {
+		Empty          struct{} `cmd:"" help:"List groups with no members, other than PI and owner groups and the admins groups of groups that have one."`
+		StaleSubgroups struct {
+			Days       int `required:"" help:"Only list subgroups unchanged for at least this many days."`
+			MinMembers int `help:"Only list subgroups with fewer members than this." default:"2"`
+		} `cmd:"" help:"List small subgroups that haven't changed in a while."`
+		MissingPosix struct {
+			FixGid bool `help:"Give each group without a valid gidNumber the next one from its family's GID range."`
+		} `cmd:"" help:"List groups without a numeric gidNumber and user members without a uidNumber, which SSSD ignores."`
+		Quotas struct{} `cmd:"" help:"List the storage quotas of every PIRG that has one."`
+	} `cmd:"" help:"Report on managed groups, such as candidates for cleanup."`
+
+	Agent struct {
+		Start struct{} `cmd:"" help:"Hold a bound connection and answer read-only lookups from other invocations until idle."`
+		Stop  struct{} `cmd:"" help:"Stop the running agent."`
+	} `cmd:"" help:"Run a background agent that speeds up repeated read-only lookups."`
+
 	Cephs3 struct {
 		List struct {
-		} `cmd:"" help:"Get list of all cephs3 groups."`
+			All           bool `help:"List every group under the cephs3 OU, marking companion groups, subgroups, and nonconforming names." xor:"all"`
+			Nonconforming bool `help:"List only groups whose names don't follow the naming conventions." xor:"all"`
+			BaseDN        string `help:"List groups under this OU instead of ldap_cephs3_dn, e.g. an archive OU." name:"base-dn" placeholder:"DN"`
+		} `cmd:"" aliases:"ls" help:"Get list of all cephs3 groups."`
+		Orphans struct {
+			List  struct{} `cmd:"" default:"1" help:"List OUs under the cephs3 OU with no cephs3 group in them, and any groups left inside."`
+			Clean struct {
+				Yes bool `help:"Skip the confirmation prompt." short:"y"`
+			} `cmd:"" help:"Delete the orphan OUs that hold no groups. Orphans with groups left in them are listed and kept."`
+		} `cmd:"" help:"Find OUs left behind by failed creates and deleted cephs3 groups."`
 		Name struct {
 			Name string `arg:""`
 			GetGID struct {} `cmd:"" help:"Get the GID of a cephs3 group."`
+			Policy struct {
+				Format string `help:"Policy format." enum:"rgw" default:"rgw"`
+			} `cmd:"" help:"Print a bucket policy granting the admins group read/write and the main group read-only access, from cephs3_policy_template."`
 			GetOwner  struct{} `cmd:"" help:"Get the Owner of a cephs3 group."`
 			SetOwner  struct {
-				Owner string `required:"" help:"Name of the Owner." type:"name"`
+				Owner               string `required:"" help:"Name of the Owner." type:"name"`
+				OverrideOwnerPolicy bool   `help:"Allow an owner that owner_eligibility would reject. The exception is logged."`
 			} `cmd:"" help:"Set the Owner of a cephs3 group."`
 			Create struct {
-				Owner string `required:"" help:"Name of the Owner." type:"name"`
-			} `cmd:"" help:"Create a new cephs3 group."`
-			Delete struct{} `cmd:"" help:"Delete a cephs3 group."`
-			ListAdmins struct{} `cmd:"" help:"List all admins of a Cephs3 group."`
+				Owner               string `required:"" help:"Name of the Owner." type:"name"`
+				OverrideOwnerPolicy bool   `help:"Allow an owner that owner_eligibility would reject. The exception is logged."`
+			} `cmd:"" aliases:"mk" help:"Create a new cephs3 group."`
+			Delete struct{} `cmd:"" aliases:"del" help:"Delete a cephs3 group."`
+			Rename struct {
+				NewName string `arg:"" name:"new-name" help:"New name of the cephs3 group." type:"name"`
+			} `cmd:"" help:"Rename a cephs3 group."`
+			ListAdmins struct {
+				Fields []string `help:"Comma-separated fields to print (username, dn, mail, displayName, or any user attribute)." sep:","`
+			} `cmd:"" help:"List all admins of a Cephs3 group."`
 			AddAdmin   struct {
 				Usernames []string `arg:"" name:"username" help:"Names of the admins." type:"name"`
 			} `cmd:"" help:"Add admins to a Cephs3 group."`
 			RemoveAdmin struct {
 				Usernames []string `arg:"" name:"username" help:"Names of the admins." type:"name"`
 			} `cmd:"" help:"Remove admins from a Cephs3 group."`
-			ListMembers struct{} `cmd:"" help:"List all members of a cephs3 group."`
+			ListMembers struct {
+				Fields  []string `help:"Comma-separated fields to print (username, dn, mail, displayName, or any user attribute)." sep:"," xor:"count"`
+				Count   bool     `help:"Print only the number of members." xor:"count"`
+				DNOnly  bool     `help:"Print the full DN of each member instead of the username." name:"dn-only" xor:"count"`
+				Summary bool     `help:"Print the number of members before the list." xor:"count"`
+				Limit   int    `help:"Print at most N members, after sorting." placeholder:"N"`
+				Offset  int    `help:"Skip the first N members, after sorting." placeholder:"N"`
+				Sort    string `help:"Sort members by name (username) or dn, ignoring case, before --limit and --offset." enum:",name,dn" default:"" placeholder:"name|dn"`
+			} `cmd:"" aliases:"ls" help:"List all members of a cephs3 group."`
 			AddMember   struct {
-				Usernames []string `arg:"" name:"username" help:"Names of the members." type:"name"`
-			} `cmd:"" help:"Add members to a cephs3 group."`
+				Usernames []string `arg:"" optional:"" name:"username" help:"Names of the members." type:"name"`
+				DNs       []string `name:"dn" sep:"none" help:"Full DN of a member, repeatable. Skips the username lookup and also accepts objects that aren't user accounts."`
+			} `cmd:"" aliases:"add" help:"Add members to a cephs3 group."`
 			RemoveMember struct {
-				Usernames []string `arg:"" name:"username" help:"Names of the members." type:"name"`
-			} `cmd:"" help:"Remove members from a cephs3 group."`
+				Usernames []string `arg:"" optional:"" name:"username" help:"Names of the members." type:"name"`
+				DNs       []string `name:"dn" sep:"none" help:"Full DN of a member, repeatable. Skips the username lookup and also accepts objects that aren't user accounts."`
+			} `cmd:"" aliases:"rm" help:"Remove members from a cephs3 group."`
+			Check struct {
+				Fix bool `help:"Repair the problems that can be fixed automatically."`
+			} `cmd:"" help:"Check a cephs3 group for inconsistencies."`
+			DNs struct{} `cmd:"" name:"dns" help:"Print the DNs of the cephs3 group's main, admins, owner, and subgroups."`
 		} `arg:""`
 	} `cmd:"" name:"cephs3" help:"Manage Ceph s3 buckets groups."`
 	Cephfs struct {
 		List struct {
-		} `cmd:"" help:"Get list of all cephfs groups."`
+			All           bool `help:"List every group under the cephfs OU, marking companion groups, subgroups, and nonconforming names." xor:"all"`
+			Nonconforming bool `help:"List only groups whose names don't follow the naming conventions." xor:"all"`
+			Long          bool `help:"Also show which groups are frozen, and why." short:"l" xor:"all"`
+			BaseDN        string `help:"List groups under this OU instead of ldap_cephfs_dn, e.g. an archive OU." name:"base-dn" placeholder:"DN"`
+		} `cmd:"" aliases:"ls" help:"Get list of all cephfs groups."`
+		Orphans struct {
+			List  struct{} `cmd:"" default:"1" help:"List OUs under the cephfs OU with no cephfs group in them, and any groups left inside."`
+			Clean struct {
+				Yes bool `help:"Skip the confirmation prompt." short:"y"`
+			} `cmd:"" help:"Delete the orphan OUs that hold no groups. Orphans with groups left in them are listed and kept."`
+		} `cmd:"" help:"Find OUs left behind by failed creates and deleted cephfs groups."`
 		Name struct {
 			Name string `arg:""`
+			Info     struct{} `cmd:"" help:"Show a cephfs group's DN, Owner, member count, and whether it is frozen."`
+			Freeze   struct{} `cmd:"" help:"Refuse changes to a cephfs group until it is unfrozen. Requires --reason."`
+			Unfreeze struct{} `cmd:"" help:"Allow changes to a frozen cephfs group again."`
 			GetGID struct {} `cmd:"" help:"Get the GID of a cephfs group."`
 			GetOwner  struct{} `cmd:"" help:"Get the Owner of a cephfs group."`
 			SetOwner  struct {
-				Owner string `required:"" help:"Name of the Owner." type:"name"`
+				Owner               string `required:"" help:"Name of the Owner." type:"name"`
+				OverrideOwnerPolicy bool   `help:"Allow an owner that owner_eligibility would reject. The exception is logged."`
 			} `cmd:"" help:"Set the Owner of a cephfs group."`
+			TransferOwner struct {
+				Username            string `arg:"" help:"User taking over as Owner." type:"name"`
+				DemoteOld           bool   `help:"Also remove the previous Owner from the admins group. They stay a member."`
+				OverrideOwnerPolicy bool   `help:"Allow an owner that owner_eligibility would reject. The exception is logged."`
+			} `cmd:"" help:"Hand a cephfs group to a new Owner, printing the previous one."`
 			Create struct {
-				Owner string `required:"" help:"Name of the Owner." type:"name"`
-			} `cmd:"" help:"Create a new cephfs group."`
-			Delete struct{} `cmd:"" help:"Delete a cephfs group."`
-			ListMembers struct{} `cmd:"" help:"List all members of a cephfs group."`
-			ListAdmins struct{} `cmd:"" help:"List all admins of a Cephfs group."`
+				Owner               string `required:"" help:"Name of the Owner." type:"name"`
+				OverrideOwnerPolicy bool   `help:"Allow an owner that owner_eligibility would reject. The exception is logged."`
+			} `cmd:"" aliases:"mk" help:"Create a new cephfs group."`
+			Delete struct{} `cmd:"" aliases:"del" help:"Delete a cephfs group."`
+			Rename struct {
+				NewName string `arg:"" name:"new-name" help:"New name of the cephfs group." type:"name"`
+			} `cmd:"" help:"Rename a cephfs group."`
+			ListMembers struct {
+				Fields  []string `help:"Comma-separated fields to print (username, dn, mail, displayName, or any user attribute)." sep:"," xor:"count"`
+				Count   bool     `help:"Print only the number of members." xor:"count"`
+				DNOnly  bool     `help:"Print the full DN of each member instead of the username." name:"dn-only" xor:"count"`
+				Summary bool     `help:"Print the number of members before the list." xor:"count"`
+				Limit   int    `help:"Print at most N members, after sorting." placeholder:"N"`
+				Offset  int    `help:"Skip the first N members, after sorting." placeholder:"N"`
+				Sort    string `help:"Sort members by name (username) or dn, ignoring case, before --limit and --offset." enum:",name,dn" default:"" placeholder:"name|dn"`
+			} `cmd:"" aliases:"ls" help:"List all members of a cephfs group."`
+			ListAdmins struct {
+				Fields   []string `help:"Comma-separated fields to print (username, dn, mail, displayName, or any user attribute)." sep:"," xor:"fields"`
+				WithRole bool     `help:"Mark which admin is the Owner." xor:"fields"`
+			} `cmd:"" help:"List all admins of a Cephfs group."`
 			AddAdmin   struct {
 				Usernames []string `arg:"" name:"username" help:"Names of the admins." type:"name"`
 			} `cmd:"" help:"Add admins to a Cephfs group."`
@@ -134,31 +416,68 @@ var CLI struct {
 				Usernames []string `arg:"" name:"username" help:"Names of the admins." type:"name"`
 			} `cmd:"" help:"Remove admins from a Cephfs group."`
 			AddMember   struct {
-				Usernames []string `arg:"" name:"username" help:"Names of the members." type:"name"`
-			} `cmd:"" help:"Add members to a cephfs group."`
+				Usernames []string `arg:"" optional:"" name:"username" help:"Names of the members." type:"name"`
+				DNs       []string `name:"dn" sep:"none" help:"Full DN of a member, repeatable. Skips the username lookup and also accepts objects that aren't user accounts."`
+			} `cmd:"" aliases:"add" help:"Add members to a cephfs group."`
 			RemoveMember struct {
-				Usernames []string `arg:"" name:"username" help:"Names of the members." type:"name"`
-			} `cmd:"" help:"Remove members from a cephfs group."`
+				Usernames []string `arg:"" optional:"" name:"username" help:"Names of the members." type:"name"`
+				DNs       []string `name:"dn" sep:"none" help:"Full DN of a member, repeatable. Skips the username lookup and also accepts objects that aren't user accounts."`
+			} `cmd:"" aliases:"rm" help:"Remove members from a cephfs group."`
+			Check struct {
+				Fix bool `help:"Repair the problems that can be fixed automatically."`
+			} `cmd:"" help:"Check a cephfs group for inconsistencies."`
+			DNs struct{} `cmd:"" name:"dns" help:"Print the DNs of the cephfs group's main, admins, owner, and subgroups."`
 		} `arg:""`
 	} `cmd:"" help:"Manage Cephfs POSIX groups."`
 	Software struct {
 		List struct {
-		} `cmd:"" help:"Get list of all software groups."`
+			BaseDN string `help:"List groups under this OU instead of ldap_software_dn, e.g. an archive OU." name:"base-dn" placeholder:"DN"`
+		} `cmd:"" aliases:"ls" help:"Get list of all software groups."`
 		Name struct {
-			Create struct {} `cmd:"" help:"Create a new SOFTWARE."`
-			Delete struct{} `cmd:"" help:"Delete a SOFTWARE."`
+			Create struct {} `cmd:"" aliases:"mk" help:"Create a new SOFTWARE."`
+			Delete struct{} `cmd:"" aliases:"del" help:"Delete a SOFTWARE."`
 			Name string `arg:""`
-			ListMembers struct{} `cmd:"" help:"List all members of a software group."`
+			ListMembers struct {
+				Fields  []string `help:"Comma-separated fields to print (username, dn, mail, displayName, or any user attribute)." sep:"," xor:"count"`
+				Count   bool     `help:"Print only the number of members." xor:"count"`
+				DNOnly  bool     `help:"Print the full DN of each member instead of the username." name:"dn-only" xor:"count"`
+				Summary bool     `help:"Print the number of members before the list." xor:"count"`
+				Limit   int    `help:"Print at most N members, after sorting." placeholder:"N"`
+				Offset  int    `help:"Skip the first N members, after sorting." placeholder:"N"`
+				Sort    string `help:"Sort members by name (username) or dn, ignoring case, before --limit and --offset." enum:",name,dn" default:"" placeholder:"name|dn"`
+			} `cmd:"" aliases:"ls" help:"List all members of a software group."`
 			AddMember   struct {
-				Usernames []string `arg:"" name:"username" help:"Names of the members." type:"name"`
-			} `cmd:"" help:"Add members to a SOFTWARE group."`
+				Usernames []string `arg:"" optional:"" name:"username" help:"Names of the members." type:"name"`
+				DNs       []string `name:"dn" sep:"none" help:"Full DN of a member, repeatable. Skips the username lookup and also accepts objects that aren't user accounts."`
+			} `cmd:"" aliases:"add" help:"Add members to a SOFTWARE group."`
 			RemoveMember struct {
-				Usernames []string `arg:"" name:"username" help:"Names of the members." type:"name"`
-			} `cmd:"" help:"Remove members from a SOFTWARE Group."`
+				Usernames []string `arg:"" optional:"" name:"username" help:"Names of the members." type:"name"`
+				DNs       []string `name:"dn" sep:"none" help:"Full DN of a member, repeatable. Skips the username lookup and also accepts objects that aren't user accounts."`
+			} `cmd:"" aliases:"rm" help:"Remove members from a SOFTWARE Group."`
+			ListAdmins struct {
+				Fields []string `help:"Comma-separated fields to print (username, dn, mail, displayName, or any user attribute)." sep:","`
+			} `cmd:"" help:"List all admins of a SOFTWARE group."`
+			AddAdmin struct {
+				Usernames []string `arg:"" name:"username" help:"Names of the admins, who must be members." type:"name"`
+			} `cmd:"" help:"Add admins to a SOFTWARE group, creating its admins group if needed."`
+			RemoveAdmin struct {
+				Usernames []string `arg:"" name:"username" help:"Names of the admins." type:"name"`
+			} `cmd:"" help:"Remove admins from a SOFTWARE group."`
 		} `arg:""`
 	} `cmd:"" help:"Manage SOFTWARE groups."`
 }
 
+// CLI holds the parsed command line of the running command.
+var CLI cliArgs
+
+// connectDirectory adds the directory connection to a command's context.
+// Tests replace it to run commands against an in-memory directory.
+var connectDirectory = ld.WithConnector
+
+// confirmThreshold is the number of users a set-based operation may touch
+// before it asks for confirmation.
+const confirmThreshold = 10
+
 type VersionFlag bool
 
 func (v VersionFlag) BeforeReset(app *kong.Kong, vars kong.Vars) error {
@@ -167,540 +486,1626 @@ func (v VersionFlag) BeforeReset(app *kong.Kong, vars kong.Vars) error {
 	return nil
 }
 
+// configPathFromArgs finds the --config flag in args before kong parses
+// them, so the config can shape the command tree.
+func configPathFromArgs(args []string) string {
+	for i, arg := range args {
+		switch {
+		case arg == "--":
+			return ""
+		case arg == "-c" || arg == "--config":
+			if i+1 < len(args) {
+				return args[i+1]
+			}
+		case strings.HasPrefix(arg, "--config="):
+			return strings.TrimPrefix(arg, "--config=")
+		}
+	}
+	return ""
+}
+
+// checkMemberArgs exits unless members were given either as usernames or
+// with --dn, but not both.
+func checkMemberArgs(usernames []string, dns []string) {
+	if len(usernames) > 0 && len(dns) > 0 {
+		failUsage("Usernames cannot be combined with --dn.")
+	}
+	if len(usernames) == 0 && len(dns) == 0 {
+		failUsage("No usernames or --dn given.")
+	}
+}
+
+// confirm asks the user to confirm an action on stdin.
+// Anything other than an explicit yes, including Ctrl-C, is treated as a no.
+func confirm(ctx context.Context, prompt string) bool {
+	fmt.Fprintf(stdout, "%s [y/N]: ", prompt)
+	line := make(chan string, 1)
+	go func() {
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		line <- answer
+	}()
+	select {
+	case answer := <-line:
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		return answer == "y" || answer == "yes"
+	case <-ctx.Done():
+		fmt.Fprintln(stdout)
+		return false
+	}
+}
+
 func main() {
-	cli := kong.Parse(&CLI,
+	os.Exit(Run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// Run runs the command line args, the arguments after the program name,
+// writing to out and errOut, and returns the exit code. Commands end early
+// with exit, which unwinds back to here.
+func Run(args []string, out io.Writer, errOut io.Writer) (code int) {
+	stdout, stderr = out, errOut
+	CLI = cliArgs{}
+	counted = nil
+	defer func() {
+		if r := recover(); r != nil {
+			c, ok := r.(exitCode)
+			if !ok {
+				panic(r)
+			}
+			code = int(c)
+		}
+	}()
+	run(args)
+	return 0
+}
+
+func run(args []string) {
+	parser := kong.Must(&CLI,
 		kong.Name("directory-manager"),
 		kong.Description("Command-line tool for managing HPC ActiveDirectory groups."),
-		kong.Vars{"version": version},
+		kong.Vars{"version": versionString()},
+		kong.Writers(stdout, stderr),
+		kong.Exit(exit),
 		kong.UsageOnError(),
 		kong.ConfigureHelp(kong.HelpOptions{
 			Compact: true,
 			Summary: true,
 		}))
+	// Hide command families turned off in configuration from help, and
+	// expand site aliases. A config that fails to load is reported after
+	// parsing, like any other command.
+	if cfg, err := config.GetConfig(configPathFromArgs(args)); err == nil {
+		for _, node := range parser.Model.Children {
+			if cfg.DisabledSubsystem(node.Name) != "" {
+				node.Hidden = true
+			}
+		}
+		args, err = expandAliases(parser.Model, args, cfg.Aliases)
+		if err != nil {
+			failUsage(err.Error())
+		}
+	}
+	cli, err := parser.Parse(args)
+	parser.FatalIfErrorf(err)
 
 	if CLI.Version {
-		fmt.Printf("Version: %s\n", version)
-		os.Exit(0)
+		fmt.Fprintf(stdout, "Version: %s\n", version)
+		exit(0)
+	}
+	// version doesn't need the directory, so handle it before connecting
+	if cli.Command() == "version" {
+		exit(runVersion(CLI.VersionCmd.JSON, CLI.VersionCmd.Check))
 	}
 
-	// Set up logging
-	slogOpts := slog.HandlerOptions{
-		Level: slog.LevelInfo,
+	// Set up logging from the flags first so config loading can be debugged,
+	// then again below once the config file and environment are known.
+	logOpts := logging.Options{
+		Format: CLI.LogFormat,
+		Level:  CLI.LogLevel,
+		Debug:  CLI.Debug,
+		Output: stderr,
 	}
-	if CLI.Debug {
-		slogOpts = slog.HandlerOptions{
-			Level: slog.LevelDebug,
-		}
+	logCloser, err := logging.Setup(logOpts)
+	if err != nil {
+		fail("Error setting up logging", err)
 	}
-	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slogOpts)))
 	slog.Debug("Debug mode enabled")
 
 	cfg, err := config.GetConfig(CLI.Config)
 	slog.Debug("Loading config", "path", CLI.Config)
 	if err != nil {
-		fmt.Printf("Error loading config: %v\n", err)
-		os.Exit(1)
+		fail("Error loading config", err)
 	}
-	slog.Debug("Loaded config", "config", cfg)
-	ctx := context.Background()
-	ctx = context.WithValue(ctx, keys.ConfigKey, cfg)
 
-	// Initialize the LDAP connection
-	ctx, err = ld.LoadLDAPConnection(ctx)
+	// Flags take precedence over the config file and environment
+	if logOpts.Format == "" {
+		logOpts.Format = cfg.LogFormat
+	}
+	if logOpts.Level == "" {
+		logOpts.Level = cfg.LogLevel
+	}
+	logOpts.File = CLI.LogFile
+	if logOpts.File == "" {
+		logOpts.File = cfg.LogFile
+	}
+	logOpts.MaxFileSize = int64(cfg.LogFileMaxSizeMB) * 1024 * 1024
+	logCloser.Close()
+	logCloser, err = logging.Setup(logOpts)
 	if err != nil {
-		fmt.Printf("Error loading LDAP connection: %v\n", err)
-		os.Exit(1)
+		fail("Error setting up logging", err)
+	}
+	defer logCloser.Close()
+	if CLI.PageSize != 0 {
+		cfg.LDAPPageSize = config.ClampPageSize(CLI.PageSize)
+	}
+	if CLI.PreferDC != "" {
+		if err := cfg.PreferServer(CLI.PreferDC); err != nil {
+			failUsage(err.Error())
+		}
+	}
+	if CLI.Parallel < 0 {
+		failUsage("--parallel must not be negative.")
+	}
+	if CLI.OverrideFreeze && CLI.Reason == "" {
+		failUsage("--override-freeze requires --reason, which is logged with the override.")
+	}
+	if CLI.OverrideMaintenance && CLI.Reason == "" {
+		failUsage("--override-maintenance requires --reason, which is logged with the override.")
+	}
+	slog.Debug("Loaded config", "config", cfg)
+	normalizeNames()
+
+	// Refuse commands for disabled subsystems before touching the directory
+	family := strings.Fields(cli.Command())[0]
+	if family == "admins" {
+		family = CLI.Admins.List.Namespace
 	}
+	if family == "migrate" {
+		family = CLI.Migrate.Prefix.Family
+	}
+	if subsystem := cfg.DisabledSubsystem(family); subsystem != "" {
+		failDisabled(subsystem)
+	}
+	checkReason(cfg, cli.Command())
+	// schema only describes configuration, so it doesn't need the directory either
+	if cli.Command() == "schema" {
+		printJSON(buildSchema(cfg))
+		return
+	}
+	if cli.Command() == "agent stop" {
+		stopAgent(cfg)
+		return
+	}
+	// Read-only lookups go through a running agent when there is one
+	if runThroughAgent(cfg, cli.Command()) {
+		return
+	}
+	// Ctrl-C and SIGTERM cancel the command, and --timeout bounds all of
+	// it rather than each request. Either closes the directory connection,
+	// failing whatever request is still waiting on it.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if CLI.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, CLI.Timeout)
+		defer cancel()
+	}
+	ctx = context.WithValue(ctx, keys.ConfigKey, cfg)
+
+	// Connect to LDAP when something first needs the directory, so a
+	// command that doesn't works without the server or credentials
+	ctx = connectDirectory(ctx)
 	defer func() {
-		l := ctx.Value(keys.LDAPConnKey).(*ldap.Conn)
-		if l != nil {
-			err := l.Close()
-			if err != nil {
-				fmt.Printf("Error closing LDAP connection: %v\n", err)
-			}
+		if err := ld.Close(ctx); err != nil {
+			fmt.Fprintf(stdout, "Error closing LDAP connection: %v\n", err)
 		}
 	}()
-	slog.Debug("Loaded LDAP connection")
+	// Record the reason and post the changes however the command ends,
+	// since one that fails or skips accounts partway through may already
+	// have changed the group. notifyWebhook runs before noteReason, so the
+	// note's own modify is not sent as an event.
+	defer noteReason(ctx, cfg, cli.Command())
+	defer notifyWebhook(ctx, cfg, cli.Command())
+
+	// Let PI and owner eligibility checks pass for an exception the admin vouched for
+	if CLI.Pirg.Name.Create.OverridePiPolicy || CLI.Pirg.Name.SetPI.OverridePiPolicy ||
+		CLI.Cephfs.Name.Create.OverrideOwnerPolicy || CLI.Cephfs.Name.SetOwner.OverrideOwnerPolicy ||
+		CLI.Cephfs.Name.TransferOwner.OverrideOwnerPolicy ||
+		CLI.Cephs3.Name.Create.OverrideOwnerPolicy || CLI.Cephs3.Name.SetOwner.OverrideOwnerPolicy {
+		ctx = context.WithValue(ctx, keys.OverridePolicyKey, true)
+	}
+	if CLI.Pirg.Name.Create.AllowDisabled || CLI.Pirg.Name.SetPI.AllowDisabled ||
+		CLI.Pirg.Name.AddMember.AllowDisabled || CLI.Pirg.Name.AddAdmin.AllowDisabled {
+		ctx = context.WithValue(ctx, keys.AllowDisabledKey, true)
+	}
+	if CLI.OverrideFreeze {
+		ctx = context.WithValue(ctx, keys.OverrideFreezeKey, true)
+	}
+	checkMaintenance(ctx, cfg, cli.Command())
+
+	// Resolve every username to add before the first change, so a typo changes nothing
+	if usernames := usernameArgs(cli.Command()); usernames != nil {
+		*usernames = knownUsernames(ctx, *usernames)
+	}
+
+	// Count before anything changes, to confirm the change against afterwards
+	counted = startMemberCount(ctx, cli.Command())
+
+	overrideBaseDN(ctx, cfg, cli.Command())
+
+	cache := openOpCache(cfg)
+	if name := invalidatedPirg(cli.Command()); name != "" {
+		cache.invalidate("pirg/" + name)
+	}
 
 	switch cli.Command() {
+	case "pirg contacts":
+		printContacts(ctx, CLI.Pirg.Contacts.Pirgs)
+	case "pirg orphans list", "pirg orphans clean":
+		orphans, err := pirg.PirgListOrphans(ctx)
+		if err != nil {
+			fail("Error listing orphan PIRG OUs", err)
+		}
+		if cli.Command() == "pirg orphans list" {
+			printOrphans(orphans, "No orphan PIRG OUs found.")
+			return
+		}
+		cleanOrphans(ctx, orphans, pirg.PirgDeleteOrphan, CLI.Pirg.Orphans.Clean.Yes)
 	case "pirg list":
+		if CLI.Pirg.List.PI != "" {
+			printOwnedPirgs(ctx, cfg, CLI.Pirg.List.PI)
+			return
+		}
+		if CLI.Pirg.List.ChangedSince != "" {
+			since, err := parseSince(CLI.Pirg.List.ChangedSince)
+			if err != nil {
+				failUsage(err.Error())
+			}
+			changes, err := pirg.PirgListChangedSince(ctx, since)
+			if err != nil {
+				fail("Error listing PIRGs", err)
+			}
+			if jsonOutput() {
+				printJSON(changes)
+				return
+			}
+			if len(changes) == 0 {
+				fmt.Fprintln(stdout, "No PIRGs found.")
+				return
+			}
+			if tableOutput() {
+				rows := make([][]string, 0, len(changes))
+				for _, change := range changes {
+					rows = append(rows, []string{change.Name, change.WhenChanged.Format(time.RFC3339)})
+				}
+				printTable([]string{"name", "when changed"}, rows)
+				return
+			}
+			for _, change := range changes {
+				fmt.Fprintln(stdout, change.Name)
+			}
+			return
+		}
 		pirgs, err := pirg.PirgList(ctx)
 		if err != nil {
-			fmt.Printf("Error listing PIRGs: %v\n", err)
-			os.Exit(1)
+			fail("Error listing PIRGs", err)
+		}
+		if CLI.Pirg.List.Long {
+			frozen, err := pirg.PirgListFrozen(ctx)
+			if err != nil {
+				fail("Error listing frozen PIRGs", err)
+			}
+			printLongList(pirgs, frozen, pirgListQuotas(ctx, cfg), "No PIRGs found.")
+			return
 		}
 		if len(pirgs) == 0 {
-			fmt.Println("No PIRGs found.")
+			fmt.Fprintln(stdout, "No PIRGs found.")
 			return
 		}
 		for _, pirg := range pirgs {
-			fmt.Println(pirg)
+			fmt.Fprintln(stdout, pirg)
+		}
+	case "pirg <name> info":
+		found, err := pirg.PirgExists(ctx, CLI.Pirg.Name.Name)
+		if err != nil {
+			fail("Error checking PIRG existence", err)
+		}
+		if !found {
+			notFound("PIRG %s not found.", CLI.Pirg.Name.Name)
+			return
+		}
+		info := groupInfo{Name: CLI.Pirg.Name.Name}
+		info.DN, err = pirg.PirgGroupDN(ctx, CLI.Pirg.Name.Name)
+		if err != nil {
+			fail("Error getting PIRG DN", err)
+		}
+		info.PI, err = pirg.PirgGetPIUsername(ctx, CLI.Pirg.Name.Name)
+		if err != nil {
+			fail("Error getting PI", err)
+		}
+		info.Members, err = pirg.PirgCountMembers(ctx, CLI.Pirg.Name.Name)
+		if err != nil {
+			fail("Error counting members", err)
+		}
+		f, frozen, err := pirg.PirgGetFreeze(ctx, CLI.Pirg.Name.Name)
+		if err != nil {
+			fail("Error checking freeze", err)
+		}
+		if frozen {
+			info.Frozen, info.Freeze = true, &f
+		}
+		printGroupInfo(info)
+	case "pirg <name> freeze":
+		found, err := pirg.PirgExists(ctx, CLI.Pirg.Name.Name)
+		if err != nil {
+			fail("Error checking PIRG existence", err)
+		}
+		if !found {
+			notFound("PIRG %s not found.", CLI.Pirg.Name.Name)
+			return
+		}
+		err = pirg.PirgFreeze(ctx, CLI.Pirg.Name.Name, newFreeze())
+		if err != nil {
+			fail("Error freezing PIRG", err)
+		}
+	case "pirg <name> unfreeze":
+		found, err := pirg.PirgExists(ctx, CLI.Pirg.Name.Name)
+		if err != nil {
+			fail("Error checking PIRG existence", err)
+		}
+		if !found {
+			notFound("PIRG %s not found.", CLI.Pirg.Name.Name)
+			return
+		}
+		err = pirg.PirgUnfreeze(ctx, CLI.Pirg.Name.Name)
+		if err != nil {
+			fail("Error unfreezing PIRG", err)
 		}
 	case "pirg <name> create":
 		found, err := pirg.PirgExists(ctx, CLI.Pirg.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking PIRG existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking PIRG existence", err)
 		}
 		if found {
-			fmt.Printf("PIRG %s already exists.\n", CLI.Pirg.Name.Name)
+			alreadyExists("PIRG %s already exists.", CLI.Pirg.Name.Name)
 			return
 		}
-		err = pirg.PirgCreate(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Create.PI)
+		// Mail-enable by default when a template is configured
+		var mail string
+		if CLI.Pirg.Name.Create.Mail || (cfg.PirgMailTemplate != "" && !CLI.Pirg.Name.Create.NoMail) {
+			mail, err = pirg.PirgMailAddress(ctx, CLI.Pirg.Name.Name)
+			if err != nil {
+				fail("Error building PIRG mail address", err)
+			}
+		}
+		err = pirg.PirgCreate(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Create.PI, mail, CLI.Pirg.Name.Create.NoMember)
 		if err != nil {
-			fmt.Printf("Error creating PIRG: %v\n", err)
-			os.Exit(1)
+			fail("Error creating PIRG", err)
 		}
+		populateNewPirg(ctx, CLI.Pirg.Name.Name)
 	case "pirg <name> delete":
 		found, err := pirg.PirgExists(ctx, CLI.Pirg.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking PIRG existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking PIRG existence", err)
 		}
 		if !found {
-			fmt.Printf("PIRG %s not found.\n", CLI.Pirg.Name.Name)
+			notFound("PIRG %s not found.", CLI.Pirg.Name.Name)
+			return
+		}
+		if CLI.Pirg.Name.Delete.DryRun {
+			printDeleteBlockers(ctx, CLI.Pirg.Name.Name)
 			return
 		}
 		err = pirg.PirgDelete(ctx, CLI.Pirg.Name.Name)
 		if err != nil {
-			fmt.Printf("Error deleting PIRG: %v\n", err)
-			os.Exit(1)
+			fail("Error deleting PIRG", err)
 		}
 	case "pirg <name> get-pi":
 		found, err := pirg.PirgExists(ctx, CLI.Pirg.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking PIRG existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking PIRG existence", err)
 		}
 		if !found {
-			fmt.Printf("PIRG %s not found.\n", CLI.Pirg.Name.Name)
+			notFound("PIRG %s not found.", CLI.Pirg.Name.Name)
 			return
 		}
 		pi, err := pirg.PirgGetPIUsername(ctx, CLI.Pirg.Name.Name)
 		if err != nil {
-			fmt.Printf("Error getting PI: %v\n", err)
-			os.Exit(1)
+			fail("Error getting PI", err)
+		}
+		fmt.Fprintln(stdout, pi)
+	case "pirg <name> dns":
+		found, err := pirg.PirgExists(ctx, CLI.Pirg.Name.Name)
+		if err != nil {
+			fail("Error checking PIRG existence", err)
+		}
+		if !found {
+			notFound("PIRG %s not found.", CLI.Pirg.Name.Name)
+			return
 		}
-		fmt.Println(pi)
+		components, err := pirg.PirgDNs(ctx, CLI.Pirg.Name.Name)
+		if err != nil {
+			fail("Error resolving PIRG DNs", err)
+		}
+		printComponents(components)
+	case "pirg <name> check":
+		found, err := pirg.PirgExists(ctx, CLI.Pirg.Name.Name)
+		if err != nil {
+			fail("Error checking PIRG existence", err)
+		}
+		if !found {
+			notFound("PIRG %s not found.", CLI.Pirg.Name.Name)
+			return
+		}
+		problems, err := pirg.PirgCheck(ctx, CLI.Pirg.Name.Name, consistency.StrayAdminFix(CLI.Pirg.Name.Check.AdminFix))
+		if err != nil {
+			fail("Error checking PIRG", err)
+		}
+		reportProblems(ctx, problems, CLI.Pirg.Name.Check.Fix)
 	case "pirg <name> set-pi":
 		found, err := pirg.PirgExists(ctx, CLI.Pirg.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking PIRG existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking PIRG existence", err)
 		}
 		if !found {
-			fmt.Printf("PIRG %s not found.\n", CLI.Pirg.Name.Name)
+			notFound("PIRG %s not found.", CLI.Pirg.Name.Name)
 			return
 		}
 		err = pirg.PirgSetPI(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.SetPI.PI)
 		if err != nil {
-			fmt.Printf("Error setting PI: %v\n", err)
-			os.Exit(1)
+			fail("Error setting PI", err)
+		}
+	case "pirg <name> fix-pi":
+		found, err := pirg.PirgExists(ctx, CLI.Pirg.Name.Name)
+		if err != nil {
+			fail("Error checking PIRG existence", err)
+		}
+		if !found {
+			notFound("PIRG %s not found.", CLI.Pirg.Name.Name)
+			return
 		}
+		added, err := pirg.PirgFixPI(ctx, CLI.Pirg.Name.Name)
+		if err != nil {
+			fail("Error fixing PI", err)
+		}
+		if len(added) == 0 {
+			fmt.Fprintln(stdout, "PI group memberships are already correct.")
+			return
+		}
+		for _, group := range added {
+			fmt.Fprintf(stdout, "Added PI to %s group\n", group)
+		}
+	case "pirg <name> get-mail":
+		found, err := pirg.PirgExists(ctx, CLI.Pirg.Name.Name)
+		if err != nil {
+			fail("Error checking PIRG existence", err)
+		}
+		if !found {
+			notFound("PIRG %s not found.", CLI.Pirg.Name.Name)
+			return
+		}
+		mail, err := pirg.PirgGetMail(ctx, CLI.Pirg.Name.Name)
+		if err != nil {
+			fail("Error getting PIRG mail", err)
+		}
+		if mail == "" {
+			fmt.Fprintln(stdout, "No mail address set.")
+			return
+		}
+		fmt.Fprintln(stdout, mail)
+	case "pirg <name> set-mail <address>":
+		found, err := pirg.PirgExists(ctx, CLI.Pirg.Name.Name)
+		if err != nil {
+			fail("Error checking PIRG existence", err)
+		}
+		if !found {
+			notFound("PIRG %s not found.", CLI.Pirg.Name.Name)
+			return
+		}
+		err = pirg.PirgSetMail(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.SetMail.Address)
+		if err != nil {
+			fail("Error setting PIRG mail", err)
+		}
+	case "pirg <name> clear-mail":
+		found, err := pirg.PirgExists(ctx, CLI.Pirg.Name.Name)
+		if err != nil {
+			fail("Error checking PIRG existence", err)
+		}
+		if !found {
+			notFound("PIRG %s not found.", CLI.Pirg.Name.Name)
+			return
+		}
+		err = pirg.PirgSetMail(ctx, CLI.Pirg.Name.Name, "")
+		if err != nil {
+			fail("Error clearing PIRG mail", err)
+		}
+	case "pirg <name> get-quota":
+		found, err := pirg.PirgExists(ctx, CLI.Pirg.Name.Name)
+		if err != nil {
+			fail("Error checking PIRG existence", err)
+		}
+		if !found {
+			notFound("PIRG %s not found.", CLI.Pirg.Name.Name)
+			return
+		}
+		printQuotas(ctx, cfg, CLI.Pirg.Name.Name)
+	case "pirg <name> set-quota":
+		found, err := pirg.PirgExists(ctx, CLI.Pirg.Name.Name)
+		if err != nil {
+			fail("Error checking PIRG existence", err)
+		}
+		if !found {
+			notFound("PIRG %s not found.", CLI.Pirg.Name.Name)
+			return
+		}
+		setQuotas(ctx, cfg, CLI.Pirg.Name.Name)
 	case "pirg <name> list-members":
 		found, err := pirg.PirgExists(ctx, CLI.Pirg.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking PIRG existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking PIRG existence", err)
 		}
 		if !found {
-			fmt.Printf("PIRG %s not found.\n", CLI.Pirg.Name.Name)
+			notFound("PIRG %s not found.", CLI.Pirg.Name.Name)
+			return
+		}
+		if p := (memberPaging{CLI.Pirg.Name.ListMembers.Limit, CLI.Pirg.Name.ListMembers.Offset, CLI.Pirg.Name.ListMembers.Sort}); p.active() {
+			if len(CLI.Pirg.Name.ListMembers.Fields) > 0 || CLI.Pirg.Name.ListMembers.Count || CLI.Pirg.Name.ListMembers.ExcludeAdmins {
+				failUsage("--limit, --offset, and --sort can't be combined with --fields or --count or --exclude-admins.")
+			}
+			dns, err := pirg.PirgListMemberDNs(ctx, CLI.Pirg.Name.Name)
+			if err != nil {
+				fail("Error listing members", err)
+			}
+			printMemberPage(dns, p, CLI.Pirg.Name.ListMembers.DNOnly, CLI.Pirg.Name.ListMembers.Summary)
+			return
+		}
+		if len(CLI.Pirg.Name.ListMembers.Fields) > 0 {
+			dns, err := pirg.PirgListMemberDNs(ctx, CLI.Pirg.Name.Name)
+			if err != nil {
+				fail("Error listing members", err)
+			}
+			printMemberFields(ctx, dns, CLI.Pirg.Name.ListMembers.Fields)
+			return
+		}
+		if CLI.Pirg.Name.ListMembers.DNOnly {
+			dns, err := pirg.PirgListMemberDNs(ctx, CLI.Pirg.Name.Name)
+			if err != nil {
+				fail("Error listing members", err)
+			}
+			printMembers(dns, false, "")
+			return
+		}
+		if CLI.Pirg.Name.ListMembers.Count {
+			count, err := pirg.PirgCountMembers(ctx, CLI.Pirg.Name.Name)
+			if err != nil {
+				fail("Error counting members", err)
+			}
+			printMemberCount(count)
+			return
+		}
+		if CLI.Pirg.Name.ListMembers.ExcludeAdmins {
+			members, err := pirg.PirgListNonAdminMemberUsernames(ctx, CLI.Pirg.Name.Name)
+			if err != nil {
+				fail("Error listing members", err)
+			}
+			printMembers(members, CLI.Pirg.Name.ListMembers.Summary, "")
 			return
 		}
 		members, err := pirg.PirgListMemberUsernames(ctx, CLI.Pirg.Name.Name)
 		if err != nil {
-			fmt.Printf("Error listing members: %v\n", err)
-			os.Exit(1)
+			fail("Error listing members", err)
 		}
-		for _, member := range members {
-			fmt.Println(member)
+		printMembers(members, CLI.Pirg.Name.ListMembers.Summary, "")
+	case "pirg <name> add-member", "pirg <name> add-member <username>":
+		opts := CLI.Pirg.Name.AddMember
+		checkMemberArgs(opts.Usernames, opts.DNs)
+		if len(opts.DNs) == 0 {
+			opts.Usernames = cache.uncached(ctx, "pirg/"+CLI.Pirg.Name.Name, opcache.Add, opts.Usernames)
+			if len(opts.Usernames) == 0 {
+				return
+			}
 		}
-	case "pirg <name> add-member <username>":
 		found, err := pirg.PirgExists(ctx, CLI.Pirg.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking PIRG existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking PIRG existence", err)
 		}
 		if !found {
-			fmt.Printf("PIRG %s not found.\n", CLI.Pirg.Name.Name)
+			notFound("PIRG %s not found.", CLI.Pirg.Name.Name)
 			return
 		}
-		for _, username := range CLI.Pirg.Name.AddMember.Usernames {
+		groupDN, err := pirg.PirgGroupDN(ctx, CLI.Pirg.Name.Name)
+		if err != nil {
+			fail("Error getting PIRG DN", err)
+		}
+		if opts.WarnMultiPirg || opts.DenyMultiPirg {
+			// Check everyone up front so --deny-multi-pirg adds nobody on refusal
+			for _, username := range opts.Usernames {
+				others, err := pirg.PirgUserOtherPIRGs(ctx, CLI.Pirg.Name.Name, username)
+				if err != nil {
+					fail(fmt.Sprintf("Error checking PIRGs for %s", username), err)
+				}
+				if len(others) == 0 {
+					continue
+				}
+				if opts.DenyMultiPirg {
+					fail(fmt.Sprintf("Error adding member %s", username), fmt.Errorf("already in PIRG(s) %s", strings.Join(others, ", ")))
+				}
+				fmt.Fprintf(stderr, "Warning: %s is already in PIRG(s) %s\n", username, strings.Join(others, ", "))
+			}
+		}
+		if parallel() {
+			name := CLI.Pirg.Name.Name
+			results := runParallel(ctx, opts.Usernames, opts.DNs,
+				func(ctx context.Context, username string) error { return pirg.PirgAddMember(ctx, name, username) },
+				func(ctx context.Context, dn string) error { return pirg.PirgAddMemberDN(ctx, name, dn) })
+			for _, r := range results {
+				if r.Err == nil && len(opts.DNs) == 0 {
+					cache.record(ctx, "pirg/"+name, groupDN, opcache.Add, r.Member)
+				}
+			}
+			reportParallel("add", results)
+			break
+		}
+		var inactive []error
+		for _, dn := range opts.DNs {
+			err = pirg.PirgAddMemberDN(ctx, CLI.Pirg.Name.Name, dn)
+			if errors.Is(err, ld.ErrAccountInactive) {
+				inactive = append(inactive, err)
+				continue
+			}
+			if err != nil {
+				fail(fmt.Sprintf("Error adding member %s", dn), err)
+			}
+		}
+		for _, username := range opts.Usernames {
 			err = pirg.PirgAddMember(ctx, CLI.Pirg.Name.Name, username)
+			if errors.Is(err, ld.ErrAccountInactive) {
+				inactive = append(inactive, err)
+				continue
+			}
 			if err != nil {
-				fmt.Printf("Error adding member %s: %v\n", username, err)
-				os.Exit(1)
+				fail(fmt.Sprintf("Error adding member %s", username), err)
+			}
+			cache.record(ctx, "pirg/"+CLI.Pirg.Name.Name, groupDN, opcache.Add, username)
+		}
+		reportInactive("member", inactive)
+	case "pirg <name> add-missing":
+		found, err := pirg.PirgExists(ctx, CLI.Pirg.Name.Name)
+		if err != nil {
+			fail("Error checking PIRG existence", err)
+		}
+		if !found {
+			notFound("PIRG %s not found.", CLI.Pirg.Name.Name)
+			return
+		}
+		opts := CLI.Pirg.Name.AddMissing
+		diff, err := pirg.PirgMembershipDiff(ctx, CLI.Pirg.Name.Name, opts.Members, opts.Admins)
+		if err != nil {
+			fail("Error comparing PIRG membership", err)
+		}
+		if jsonOutput() {
+			printJSON(diff)
+		} else {
+			verb := "Adding"
+			if opts.DryRun {
+				verb = "Would add"
+			}
+			for _, username := range diff.AddMembers {
+				fmt.Fprintf(stdout, "%s member %s\n", verb, username)
+			}
+			for _, username := range diff.AddAdmins {
+				fmt.Fprintf(stdout, "%s admin %s\n", verb, username)
+			}
+			for _, username := range diff.RemoveMembers {
+				fmt.Fprintf(stdout, "Keeping member %s, who is not in the desired list\n", username)
+			}
+			for _, username := range diff.RemoveAdmins {
+				fmt.Fprintf(stdout, "Keeping admin %s, who is not in the desired list\n", username)
+			}
+		}
+		if opts.DryRun {
+			return
+		}
+		inactive, err := pirg.PirgAddMissing(ctx, CLI.Pirg.Name.Name, diff)
+		if err != nil {
+			fail("Error adding missing members", err)
+		}
+		reportInactive("member or admin", inactive)
+	case "pirg <name> remove-member", "pirg <name> remove-member <username>":
+		opts := CLI.Pirg.Name.RemoveMember
+		if len(opts.Except) > 0 && !opts.AllRegular {
+			failUsage("--except requires --all-regular.")
+		}
+		if opts.AllRegular {
+			if len(opts.Usernames) > 0 {
+				failUsage("Usernames cannot be combined with --all-regular.")
+			}
+			removeAllRegular(ctx, CLI.Pirg.Name.Name, opts.Except, opts.Yes)
+			break
+		}
+		if opts.FromSubgroup == "" && opts.MembersOf == "" {
+			checkMemberArgs(opts.Usernames, opts.DNs)
+			if len(opts.DNs) == 0 {
+				opts.Usernames = cache.uncached(ctx, "pirg/"+CLI.Pirg.Name.Name, opcache.Remove, opts.Usernames)
+				if len(opts.Usernames) == 0 {
+					return
+				}
 			}
 		}
-	case "pirg <name> remove-member <username>":
 		found, err := pirg.PirgExists(ctx, CLI.Pirg.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking PIRG existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking PIRG existence", err)
 		}
 		if !found {
-			fmt.Printf("PIRG %s not found.\n", CLI.Pirg.Name.Name)
+			notFound("PIRG %s not found.", CLI.Pirg.Name.Name)
 			return
 		}
-		for _, username := range CLI.Pirg.Name.RemoveMember.Usernames {
-			err = pirg.PirgRemoveMember(ctx, CLI.Pirg.Name.Name, username)
+		if parallel() && opts.FromSubgroup == "" && opts.MembersOf == "" {
+			name := CLI.Pirg.Name.Name
+			groupDN, err := pirg.PirgGroupDN(ctx, name)
 			if err != nil {
-				fmt.Printf("Error removing member %s: %v\n", username, err)
-				os.Exit(1)
+				fail("Error getting PIRG DN", err)
+			}
+			results := runParallel(ctx, opts.Usernames, opts.DNs,
+				func(ctx context.Context, username string) error { return pirg.PirgRemoveMember(ctx, name, username) },
+				func(ctx context.Context, dn string) error { return pirg.PirgRemoveMemberDN(ctx, name, dn) })
+			for _, r := range results {
+				if r.Err == nil && len(opts.DNs) == 0 {
+					cache.record(ctx, "pirg/"+name, groupDN, opcache.Remove, r.Member)
+				}
+			}
+			reportParallel("remove", results)
+			break
+		}
+		if len(opts.DNs) > 0 {
+			for _, dn := range opts.DNs {
+				err = pirg.PirgRemoveMemberDN(ctx, CLI.Pirg.Name.Name, dn)
+				if err != nil && !notMember(dn, CLI.Pirg.Name.Name, err) {
+					fail(fmt.Sprintf("Error removing member %s", dn), err)
+				}
+			}
+			break
+		}
+		if opts.FromSubgroup == "" && opts.MembersOf == "" {
+			groupDN, err := pirg.PirgGroupDN(ctx, CLI.Pirg.Name.Name)
+			if err != nil {
+				fail("Error getting PIRG DN", err)
+			}
+			for _, username := range opts.Usernames {
+				err = pirg.PirgRemoveMember(ctx, CLI.Pirg.Name.Name, username)
+				if err != nil && !notMember(username, CLI.Pirg.Name.Name, err) {
+					fail(fmt.Sprintf("Error removing member %s", username), err)
+				}
+				cache.record(ctx, "pirg/"+CLI.Pirg.Name.Name, groupDN, opcache.Remove, username)
 			}
+			break
+		}
+		if len(opts.Usernames) > 0 {
+			failUsage("Usernames cannot be combined with --from-subgroup or --members-of.")
+		}
+		if opts.AlsoParent && opts.FromSubgroup == "" {
+			failUsage("--also-parent requires --from-subgroup.")
+		}
+		var usernames []string
+		if opts.FromSubgroup != "" {
+			found, err = pirg.PirgSubgroupExists(ctx, CLI.Pirg.Name.Name, opts.FromSubgroup)
+			if err != nil {
+				fail("Error checking subgroup existence", err)
+			}
+			if !found {
+				notFound("Subgroup %s not found.", opts.FromSubgroup)
+				return
+			}
+			usernames, err = pirg.PirgSubgroupListMemberUsernames(ctx, CLI.Pirg.Name.Name, opts.FromSubgroup)
+			if err != nil {
+				fail("Error listing subgroup members", err)
+			}
+		} else {
+			found, err = pirg.PirgExists(ctx, opts.MembersOf)
+			if err != nil {
+				fail("Error checking PIRG existence", err)
+			}
+			if !found {
+				notFound("PIRG %s not found.", opts.MembersOf)
+				return
+			}
+			usernames, err = pirg.PirgListSharedMemberUsernames(ctx, CLI.Pirg.Name.Name, opts.MembersOf)
+			if err != nil {
+				fail("Error listing shared members", err)
+			}
+		}
+		// The PI can only leave a PIRG through set-pi, so keep them in the
+		// PIRG rather than failing halfway through. They still leave the
+		// subgroup.
+		removeFromPirg := opts.MembersOf != "" || opts.AlsoParent
+		var pi string
+		if removeFromPirg {
+			pi, err = pirg.PirgGetPIUsername(ctx, CLI.Pirg.Name.Name)
+			if err != nil {
+				fail("Error getting PI", err)
+			}
+			isPI := func(u string) bool { return strings.EqualFold(u, pi) }
+			if slices.ContainsFunc(usernames, isPI) {
+				if opts.FromSubgroup == "" {
+					fmt.Fprintf(stdout, "Skipping %s, the PI of PIRG %s.\n", pi, CLI.Pirg.Name.Name)
+					usernames = slices.DeleteFunc(usernames, isPI)
+				} else {
+					fmt.Fprintf(stdout, "Keeping %s, the PI of PIRG %s, in the PIRG.\n", pi, CLI.Pirg.Name.Name)
+				}
+			}
+		}
+		// removeFromParent reports whether username leaves the PIRG too
+		removeFromParent := func(username string) bool {
+			return removeFromPirg && !strings.EqualFold(username, pi)
+		}
+		if len(usernames) == 0 {
+			fmt.Fprintln(stdout, "No members to remove.")
+			return
+		}
+		fmt.Fprintf(stdout, "Members to remove (%d):\n", len(usernames))
+		for _, username := range usernames {
+			fmt.Fprintln(stdout, username)
+		}
+		if len(usernames) > confirmThreshold && !opts.Yes && !confirm(ctx, "Remove these members?") {
+			fmt.Fprintln(stdout, "Aborted.")
+			return
+		}
+		if parallel() {
+			name := CLI.Pirg.Name.Name
+			removeOne := func(ctx context.Context, username string) error {
+				if opts.FromSubgroup != "" {
+					err := pirg.PirgSubgroupRemoveMember(ctx, name, opts.FromSubgroup, username)
+					if err != nil {
+						return fmt.Errorf("failed to remove from subgroup: %w", err)
+					}
+				}
+				if removeFromParent(username) {
+					return pirg.PirgRemoveMember(ctx, name, username)
+				}
+				return nil
+			}
+			reportParallel("remove", runParallel(ctx, usernames, nil, removeOne, nil))
+			break
+		}
+		for _, username := range usernames {
+			if opts.FromSubgroup != "" {
+				err = pirg.PirgSubgroupRemoveMember(ctx, CLI.Pirg.Name.Name, opts.FromSubgroup, username)
+				if err != nil {
+					fail(fmt.Sprintf("Error removing member %s from subgroup", username), err)
+				}
+			}
+			if removeFromParent(username) {
+				err = pirg.PirgRemoveMember(ctx, CLI.Pirg.Name.Name, username)
+				if err != nil && !notMember(username, CLI.Pirg.Name.Name, err) {
+					fail(fmt.Sprintf("Error removing member %s", username), err)
+				}
+			}
+		}
+	case "pirg <name> exposure":
+		found, err := pirg.PirgExists(ctx, CLI.Pirg.Name.Name)
+		if err != nil {
+			fail("Error checking PIRG existence", err)
+		}
+		if !found {
+			notFound("PIRG %s not found.", CLI.Pirg.Name.Name)
+			return
+		}
+		entries, err := pirg.PirgExposure(ctx, CLI.Pirg.Name.Name)
+		if err != nil {
+			fail("Error building exposure report", err)
+		}
+		if len(entries) == 0 {
+			fmt.Fprintln(stdout, "No other managed groups found.")
+			return
+		}
+		for _, entry := range entries {
+			fmt.Fprintf(stdout, "%s (%d): %s\n", entry.Group, len(entry.Members), strings.Join(entry.Members, ", "))
 		}
 	case "pirg <name> list-admins":
 		found, err := pirg.PirgExists(ctx, CLI.Pirg.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking PIRG existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking PIRG existence", err)
 		}
 		if !found {
-			fmt.Printf("PIRG %s not found.\n", CLI.Pirg.Name.Name)
+			notFound("PIRG %s not found.", CLI.Pirg.Name.Name)
+			return
+		}
+		if len(CLI.Pirg.Name.ListAdmins.Fields) > 0 {
+			dns, err := pirg.PirgListAdminDNs(ctx, CLI.Pirg.Name.Name)
+			if err != nil {
+				fail("Error listing admins", err)
+			}
+			printMemberFields(ctx, dns, CLI.Pirg.Name.ListAdmins.Fields)
 			return
 		}
 		admins, err := pirg.PirgListAdminUsernames(ctx, CLI.Pirg.Name.Name)
 		if err != nil {
-			fmt.Printf("Error listing admins: %v\n", err)
-			os.Exit(1)
+			fail("Error listing admins", err)
+		}
+		if CLI.Pirg.Name.ListAdmins.WithRole {
+			pi, err := pirg.PirgGetPIUsername(ctx, CLI.Pirg.Name.Name)
+			if err != nil {
+				slog.Warn("Failed to get PI, listing admins without one", "pirg", CLI.Pirg.Name.Name, "error", err)
+			}
+			printAdminRoles(managedgroup.Pirg.AdminRoles(admins, pi))
+			return
 		}
 		for _, admin := range admins {
-			fmt.Println(admin)
+			fmt.Fprintln(stdout, admin)
 		}
 	case "pirg <name> add-admin <username>":
 		found, err := pirg.PirgExists(ctx, CLI.Pirg.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking PIRG existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking PIRG existence", err)
 		}
 		if !found {
-			fmt.Printf("PIRG %s not found.\n", CLI.Pirg.Name.Name)
+			notFound("PIRG %s not found.", CLI.Pirg.Name.Name)
 			return
 		}
+		var inactive []error
 		for _, username := range CLI.Pirg.Name.AddAdmin.Usernames {
 			err = pirg.PirgAddAdmin(ctx, CLI.Pirg.Name.Name, username)
+			if errors.Is(err, ld.ErrAccountInactive) {
+				inactive = append(inactive, err)
+				continue
+			}
 			if err != nil {
-				fmt.Printf("Error adding admin %s: %v\n", username, err)
-				os.Exit(1)
+				fail(fmt.Sprintf("Error adding admin %s", username), err)
 			}
 		}
+		reportInactive("admin", inactive)
 	case "pirg <name> remove-admin <username>":
 		found, err := pirg.PirgExists(ctx, CLI.Pirg.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking PIRG existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking PIRG existence", err)
 		}
 		if !found {
-			fmt.Printf("PIRG %s not found.\n", CLI.Pirg.Name.Name)
+			notFound("PIRG %s not found.", CLI.Pirg.Name.Name)
 			return
 		}
 		for _, username := range CLI.Pirg.Name.RemoveAdmin.Usernames {
 			err = pirg.PirgRemoveAdmin(ctx, CLI.Pirg.Name.Name, username)
 			if err != nil {
-				fmt.Printf("Error removing admin %s: %v\n", username, err)
-				os.Exit(1)
+				fail(fmt.Sprintf("Error removing admin %s", username), err)
 			}
 		}
 	case "pirg <name> subgroup list":
 		found, err := pirg.PirgExists(ctx, CLI.Pirg.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking PIRG existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking PIRG existence", err)
 		}
 		if !found {
-			fmt.Printf("PIRG %s not found.\n", CLI.Pirg.Name.Name)
+			notFound("PIRG %s not found.", CLI.Pirg.Name.Name)
+			return
+		}
+		if CLI.Pirg.Name.Subgroup.List.Long {
+			details, err := pirg.PirgSubgroupListDetails(ctx, CLI.Pirg.Name.Name)
+			if err != nil {
+				fail("Error listing subgroups", err)
+			}
+			if jsonOutput() {
+				printJSON(details)
+				return
+			}
+			if len(details) == 0 {
+				fmt.Fprintln(stdout, "No subgroups found.")
+				return
+			}
+			rows := make([][]string, 0, len(details))
+			for _, d := range details {
+				rows = append(rows, []string{d.Name, strconv.Itoa(d.GidNumber), d.Description})
+			}
+			printTable([]string{"name", "gid", "description"}, rows)
 			return
 		}
 		subgroups, err := pirg.PirgSubgroupList(ctx, CLI.Pirg.Name.Name)
 		if err != nil {
-			fmt.Printf("Error listing subgroups: %v\n", err)
-			os.Exit(1)
+			fail("Error listing subgroups", err)
 		}
 		if len(subgroups) == 0 {
-			fmt.Println("No subgroups found.")
+			fmt.Fprintln(stdout, "No subgroups found.")
 			return
 		}
 		for _, subgroup := range subgroups {
-			fmt.Println(subgroup)
+			fmt.Fprintln(stdout, subgroup)
 		}
 
 	case "pirg <name> subgroup <name> create":
 		found, err := pirg.PirgExists(ctx, CLI.Pirg.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking PIRG existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking PIRG existence", err)
 		}
 		if !found {
-			fmt.Printf("PIRG %s not found.\n", CLI.Pirg.Name.Name)
+			notFound("PIRG %s not found.", CLI.Pirg.Name.Name)
 			return
 		}
 		found, err = pirg.PirgSubgroupExists(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Subgroup.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking subgroup existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking subgroup existence", err)
 		}
 		if found {
-			fmt.Printf("Subgroup %s already exists.\n", CLI.Pirg.Name.Subgroup.Name.Name)
+			alreadyExists("Subgroup %s already exists.", CLI.Pirg.Name.Subgroup.Name.Name)
 			return
 		}
-		err = pirg.PirgSubgroupCreate(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Subgroup.Name.Name)
+		err = pirg.PirgSubgroupCreate(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Subgroup.Name.Name, CLI.Pirg.Name.Subgroup.Name.Create.Description)
 		if err != nil {
-			slog.Error("Error creating subgroup", "error", err)
-			os.Exit(1)
+			fail("Error creating subgroup", err)
 		}
-	case "pirg <name> subgroup <name> delete":
-		found, err := pirg.PirgExists(ctx, CLI.Pirg.Name.Name)
+	case "pirg <name> subgroup <name> set-description <description>":
+		found, err := pirg.PirgSubgroupExists(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Subgroup.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking PIRG existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking subgroup existence", err)
 		}
 		if !found {
-			fmt.Printf("PIRG %s not found.\n", CLI.Pirg.Name.Name)
+			notFound("Subgroup %s not found.", CLI.Pirg.Name.Subgroup.Name.Name)
 			return
 		}
-		found, err = pirg.PirgSubgroupExists(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Subgroup.Name.Name)
+		err = pirg.PirgSubgroupSetDescription(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Subgroup.Name.Name, CLI.Pirg.Name.Subgroup.Name.SetDescription.Description)
 		if err != nil {
-			fmt.Printf("Error checking subgroup existence: %v\n", err)
-			os.Exit(1)
+			fail("Error setting subgroup description", err)
 		}
-		if !found {
-			fmt.Printf("Subgroup %s not found.\n", CLI.Pirg.Name.Subgroup.Name.Name)
+	case "pirg <name> subgroup <name> delete":
+		if !pirgSubgroupFound(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Subgroup.Name.Name) {
 			return
 		}
-		err = pirg.PirgSubgroupDelete(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Subgroup.Name.Name)
+		removed, err := pirg.PirgSubgroupDelete(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Subgroup.Name.Name, CLI.Pirg.Name.Subgroup.Name.Delete.Force)
+		if !jsonOutput() {
+			for _, member := range removed {
+				fmt.Fprintf(stdout, "Removed member %s\n", member)
+			}
+		}
 		if err != nil {
-			fmt.Printf("Error deleting subgroup: %v\n", err)
-			os.Exit(1)
+			fail("Error deleting subgroup", err)
 		}
-		if !found {
-			fmt.Printf("Subgroup %s not found.\n", CLI.Pirg.Name.Subgroup.Name.Name)
-			return
+		if jsonOutput() {
+			if removed == nil {
+				removed = []string{}
+			}
+			printJSON(map[string][]string{"removed_members": removed})
 		}
 	case "pirg <name> subgroup <name> list-members":
-		found, err := pirg.PirgExists(ctx, CLI.Pirg.Name.Name)
-		if err != nil {
-			fmt.Printf("Error checking PIRG existence: %v\n", err)
-			os.Exit(1)
+		if !pirgSubgroupFound(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Subgroup.Name.Name) {
+			return
 		}
-		if !found {
-			fmt.Printf("PIRG %s not found.\n", CLI.Pirg.Name.Name)
+		if len(CLI.Pirg.Name.Subgroup.Name.ListMembers.Fields) > 0 {
+			dns, err := pirg.PirgSubgroupListMemberDNs(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Subgroup.Name.Name)
+			if err != nil {
+				fail("Error listing subgroup members", err)
+			}
+			printMemberFields(ctx, dns, CLI.Pirg.Name.Subgroup.Name.ListMembers.Fields)
 			return
 		}
-		found, err = pirg.PirgSubgroupExists(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Subgroup.Name.Name)
-		if err != nil {
-			fmt.Printf("Error checking subgroup existence: %v\n", err)
-			os.Exit(1)
+		if CLI.Pirg.Name.Subgroup.Name.ListMembers.WithUID {
+			dns, err := pirg.PirgSubgroupListMemberDNs(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Subgroup.Name.Name)
+			if err != nil {
+				fail("Error listing subgroup members", err)
+			}
+			printMemberUIDs(ctx, dns)
+			return
 		}
-		if !found {
-			fmt.Printf("Subgroup %s not found.\n", CLI.Pirg.Name.Subgroup.Name.Name)
+		if CLI.Pirg.Name.Subgroup.Name.ListMembers.DNOnly {
+			dns, err := pirg.PirgSubgroupListMemberDNs(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Subgroup.Name.Name)
+			if err != nil {
+				fail("Error listing subgroup members", err)
+			}
+			printMembers(dns, false, "No members found in subgroup.")
+			return
+		}
+		if CLI.Pirg.Name.Subgroup.Name.ListMembers.Count {
+			count, err := pirg.PirgSubgroupCountMembers(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Subgroup.Name.Name)
+			if err != nil {
+				fail("Error counting subgroup members", err)
+			}
+			printMemberCount(count)
 			return
 		}
 		members, err := pirg.PirgSubgroupListMemberUsernames(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Subgroup.Name.Name)
 		if err != nil {
-			fmt.Printf("Error listing subgroup members: %v\n", err)
-			os.Exit(1)
+			fail("Error listing subgroup members", err)
+		}
+		printMembers(members, CLI.Pirg.Name.Subgroup.Name.ListMembers.Summary, "No members found in subgroup.")
+	case "pirg <name> subgroup <name> get-manager":
+		if !pirgSubgroupFound(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Subgroup.Name.Name) {
+			return
+		}
+		manager, err := pirg.PirgSubgroupGetManager(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Subgroup.Name.Name)
+		if err != nil {
+			fail("Error getting subgroup manager", err)
 		}
-		if len(members) == 0 {
-			fmt.Println("No members found in subgroup.")
+		if manager == "" {
+			fmt.Fprintln(stdout, "No manager set.")
 			return
 		}
-		for _, member := range members {
-			fmt.Println(member)
+		fmt.Fprintln(stdout, manager)
+	case "pirg <name> subgroup <name> set-manager <username>":
+		if !pirgSubgroupFound(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Subgroup.Name.Name) {
+			return
+		}
+		err = pirg.PirgSubgroupSetManager(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Subgroup.Name.Name, CLI.Pirg.Name.Subgroup.Name.SetManager.Username)
+		if err != nil {
+			fail("Error setting subgroup manager", err)
 		}
 	case "pirg <name> subgroup <name> add-member <username>":
-		found, err := pirg.PirgExists(ctx, CLI.Pirg.Name.Name)
+		if !pirgSubgroupFound(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Subgroup.Name.Name) {
+			return
+		}
+		for _, username := range CLI.Pirg.Name.Subgroup.Name.AddMember.Usernames {
+			err = pirg.PirgSubgroupAddMember(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Subgroup.Name.Name, username)
+			if err != nil {
+				fail(fmt.Sprintf("Error adding member %s to subgroup", username), err)
+			}
+		}
+	case "pirg <name> subgroup <name> set-members":
+		opts := CLI.Pirg.Name.Subgroup.Name.SetMembers
+		setSubgroupMembers(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Subgroup.Name.Name, opts.Members, opts.FromFile, opts.DryRun)
+	case "pirg <name> subgroup <name> remove-member <username>":
+		if !pirgSubgroupFound(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Subgroup.Name.Name) {
+			return
+		}
+		for _, username := range CLI.Pirg.Name.Subgroup.Name.RemoveMember.Usernames {
+			err = pirg.PirgSubgroupRemoveMember(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Subgroup.Name.Name, username)
+			if err != nil {
+				fail(fmt.Sprintf("Error removing member %s from subgroup", username), err)
+			}
+		}
+	case "admins list":
+		var admins []string
+		switch CLI.Admins.List.Namespace {
+		case "pirg":
+			admins, err = pirg.PirgListTopLevelAdminUsernames(ctx)
+		case "cephfs":
+			admins, err = cephfs.CephfsListTopLevelAdminUsernames(ctx)
+		case "cephs3":
+			admins, err = cephs3.Cephs3ListTopLevelAdminUsernames(ctx)
+		}
 		if err != nil {
-			fmt.Printf("Error checking PIRG existence: %v\n", err)
-			os.Exit(1)
+			fail("Error listing top-level admins", err)
 		}
-		if !found {
-			fmt.Printf("PIRG %s not found.\n", CLI.Pirg.Name.Name)
+		if len(admins) == 0 {
+			fmt.Fprintln(stdout, "No admins found.")
 			return
 		}
-		found, err = pirg.PirgSubgroupExists(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Subgroup.Name.Name)
+		for _, admin := range admins {
+			fmt.Fprintln(stdout, admin)
+		}
+	case "agent start":
+		// Fail now on bad credentials rather than on the first request
+		if _, err := ld.Conn(ctx); err != nil {
+			fail("Error loading LDAP connection", err)
+		}
+		err := agent.Serve(ctx, time.Duration(cfg.AgentIdleMinutes)*time.Minute)
+		if err != nil {
+			fail("Error running agent", err)
+		}
+	case "transfer-ownership":
+		opts := CLI.TransferOwnership
+		plan, err := transfer.Plan(ctx, opts.From, opts.To, opts.Pirg)
+		if err != nil {
+			fail("Error finding roles to transfer", err)
+		}
+		if len(plan) == 0 {
+			notFound("%s holds no PI, owner, or sponsor roles.", opts.From)
+			return
+		}
+		if opts.DryRun {
+			if jsonOutput() {
+				printJSON(plan)
+				return
+			}
+			fmt.Fprintf(stdout, "Would transfer from %s to %s:\n", opts.From, opts.To)
+			for _, r := range plan {
+				fmt.Fprintf(stdout, "  %s\n", r)
+			}
+			return
+		}
+		if !jsonOutput() {
+			fmt.Fprintf(stdout, "Transferring from %s to %s:\n", opts.From, opts.To)
+			for _, r := range plan {
+				fmt.Fprintf(stdout, "  %s\n", r)
+			}
+		}
+		if !opts.Yes && !confirm(ctx, "Transfer these roles?") {
+			fmt.Fprintln(stdout, "Aborted.")
+			return
+		}
+		results := transfer.Execute(ctx, plan, opts.From, opts.To)
+		failed := 0
+		for _, r := range results {
+			if !r.Transferred {
+				failed++
+			}
+		}
+		if jsonOutput() {
+			printJSON(results)
+		} else {
+			for _, r := range results {
+				if r.Transferred {
+					fmt.Fprintf(stdout, "Transferred: %s\n", r.Role)
+				} else {
+					fmt.Fprintf(stdout, "NOT transferred: %s: %s\n", r.Role, r.Error)
+				}
+			}
+			if failed > 0 {
+				fmt.Fprintf(stdout, "%d of %d roles were not transferred; finish them with set-pi or set-owner.\n", failed, len(results))
+			}
+		}
+		if failed > 0 {
+			exit(1)
+		}
+	case "nextgidnumber":
+		gid, err := ld.GetNextGidNumber(ctx)
+		if err != nil {
+			fail("Error obtaining next gid number", err)
+		}
+		fmt.Fprintln(stdout, gid)
+
+	case "report quotas":
+		printQuotaReport(ctx, cfg)
+	case "report empty":
+		findings, err := report.Empty(ctx)
+		if err != nil {
+			fail("Error finding empty groups", err)
+		}
+		printFindings(findings)
+	case "report stale-subgroups":
+		opts := CLI.Report.StaleSubgroups
+		if opts.Days < 0 {
+			failUsage("--days must not be negative")
+		}
+		before := time.Now().AddDate(0, 0, -opts.Days)
+		findings, err := report.StaleSubgroups(ctx, before, opts.MinMembers)
+		if err != nil {
+			fail("Error finding stale subgroups", err)
+		}
+		printFindings(findings)
+	case "report missing-posix":
+		findings, err := report.MissingPosix(ctx, CLI.Report.MissingPosix.FixGid)
+		if err != nil {
+			fail("Error finding missing POSIX attributes", err)
+		}
+		printPosixFindings(findings)
+	case "gid usage":
+		printGidUsage(ctx, cfg)
+	case "config validate":
+		validateConfig(ctx, cfg)
+	case "doctor":
+		runDoctor(ctx, cfg)
+	case "check name-collisions":
+		printNameCollisions(ctx, cfg)
+	case "migrate prefix":
+		runMigratePrefix(ctx)
+	case "maintenance start":
+		startMaintenance(ctx, cfg)
+	case "maintenance status":
+		printMaintenance(ctx, cfg)
+	case "maintenance end":
+		endMaintenance(ctx, cfg)
+	case "raw search":
+		opts := CLI.Raw.Search
+		printRawSearch(ctx, opts.Base, opts.Scope, opts.Filter, opts.Attrs)
+	case "memberof":
+		groups, err := ld.GetGroupsForDN(ctx, CLI.Memberof.DN)
+		if errors.Is(err, ld.ErrNotFound) {
+			notFound("%s not found.", CLI.Memberof.DN)
+			return
+		}
+		if err != nil {
+			fail("Error listing groups", err)
+		}
+		if jsonOutput() {
+			printJSON(groups)
+			return
+		}
+		if tableOutput() {
+			rows := make([][]string, 0, len(groups))
+			for _, g := range groups {
+				rows = append(rows, []string{g.Family, g.Name, string(g.Kind), g.DN})
+			}
+			printTable([]string{"family", "name", "kind", "dn"}, rows)
+			return
+		}
+		if len(groups) == 0 {
+			fmt.Fprintln(stdout, "No groups found.")
+			return
+		}
+		for _, g := range groups {
+			family := g.Family
+			if family == "" {
+				family = "unmanaged"
+			}
+			fmt.Fprintf(stdout, "%s %s\n", family, g.Name)
+		}
+
+	case "aduser <name> owned":
+		printOwned(ctx, cfg, CLI.Aduser.Name.Name)
+	case "aduser <name> posix-groups":
+		printPosixGroups(ctx, CLI.Aduser.Name.Name)
+	case "aduser <name> get-uid":
+		uid, err := ld.GetUidOfExistingUser(ctx, CLI.Aduser.Name.Name)
+		if err != nil {
+			fail("Error obtaining uid for user", err)
+		}
+		fmt.Fprintln(stdout, uid)
+
+	case "aduser <name> remove-talapas-group-user":
+		removed_user, err := ld.RemoveUserFromTalapasMaster(ctx, CLI.Aduser.Name.Name)
+		if err != nil {
+			fail("Error removing user from Talapas group (is.racs.talapas.users)", err)
+		}
+		fmt.Fprintf(stdout, "%s", removed_user)
+
+	case "aduser <name> add-talapas-group-user":
+		added_user, err := ld.AddUserToTalapasMaster(ctx, CLI.Aduser.Name.Name)
+		if err != nil {
+			fail("Error adding user to Talapas group (is.racs.talapas.users)", err)
+		}
+		fmt.Fprintf(stdout, "%s", added_user)
+
+	case "aduser <name> reconcile":
+		action, err := ld.ReconcileTalapasMaster(ctx, CLI.Aduser.Name.Name, CLI.Aduser.Name.Reconcile.DryRun)
+		if err != nil {
+			fail("Error reconciling Talapas group (is.racs.talapas.users) membership", err)
+		}
+		fmt.Fprintln(stdout, action)
+
+	case "cephfs orphans list", "cephfs orphans clean":
+		orphans, err := cephfs.CephfsListOrphans(ctx)
 		if err != nil {
-			fmt.Printf("Error checking subgroup existence: %v\n", err)
-			os.Exit(1)
+			fail("Error listing orphan cephfs OUs", err)
 		}
-		if !found {
-			fmt.Printf("Subgroup %s not found.\n", CLI.Pirg.Name.Subgroup.Name.Name)
+		if cli.Command() == "cephfs orphans list" {
+			printOrphans(orphans, "No orphan cephfs OUs found.")
 			return
 		}
-		for _, username := range CLI.Pirg.Name.Subgroup.Name.AddMember.Usernames {
-			err = pirg.PirgSubgroupAddMember(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Subgroup.Name.Name, username)
+		cleanOrphans(ctx, orphans, cephfs.CephfsDeleteOrphan, CLI.Cephfs.Orphans.Clean.Yes)
+
+	case "cephfs list":
+		if CLI.Cephfs.List.All || CLI.Cephfs.List.Nonconforming {
+			groups, err := cephfs.CephfsListAll(ctx)
 			if err != nil {
-				fmt.Printf("Error adding member %s to subgroup: %v\n", username, err)
-				os.Exit(1)
+				fail("Error obtaining list of all cephfs groups", err)
 			}
+			printClassifications(groups, CLI.Cephfs.List.Nonconforming)
+			return
 		}
-	case "pirg <name> subgroup <name> remove-member <username>":
-		found, err := pirg.PirgExists(ctx, CLI.Pirg.Name.Name)
+		cephfs_groups, err := cephfs.CephfsList(ctx)
 		if err != nil {
-			fmt.Printf("Error checking PIRG existence: %v\n", err)
-			os.Exit(1)
+			fail("Error obtaining list of all cephfs groups", err)
 		}
-		if !found {
-			fmt.Printf("PIRG %s not found.\n", CLI.Pirg.Name.Name)
+		if CLI.Cephfs.List.Long {
+			frozen, err := cephfs.CephfsListFrozen(ctx)
+			if err != nil {
+				fail("Error listing frozen cephfs groups", err)
+			}
+			printLongList(cephfs_groups, frozen, listQuotas{}, "No cephfs groups found.")
 			return
 		}
-		found, err = pirg.PirgSubgroupExists(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Subgroup.Name.Name)
+		if len(cephfs_groups) == 0 {
+			fmt.Fprintln(stdout, "No cephfs groups found.")
+			return
+		}
+		for _, groups := range cephfs_groups{
+			fmt.Fprintln(stdout, groups)
+		}
+
+	case "cephfs <name> info":
+		found, err := cephfs.CephfsExists(ctx, CLI.Cephfs.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking subgroup existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking cephfs group existence", err)
 		}
 		if !found {
-			fmt.Printf("Subgroup %s not found.\n", CLI.Pirg.Name.Subgroup.Name.Name)
+			notFound("cephfs group %s not found.", CLI.Cephfs.Name.Name)
 			return
 		}
-		for _, username := range CLI.Pirg.Name.Subgroup.Name.RemoveMember.Usernames {
-			err = pirg.PirgSubgroupRemoveMember(ctx, CLI.Pirg.Name.Name, CLI.Pirg.Name.Subgroup.Name.Name, username)
-			if err != nil {
-				fmt.Printf("Error removing member %s from subgroup: %v\n", username, err)
-				os.Exit(1)
-			}
+		info := groupInfo{Name: CLI.Cephfs.Name.Name}
+		components, err := cephfs.CephfsDNs(ctx, CLI.Cephfs.Name.Name)
+		if err != nil {
+			fail("Error resolving cephfs group DNs", err)
 		}
-	case "nextgidnumber":
-		gid, err := ld.GetNextGidNumber(ctx)
+		info.DN = components[0].DN
+		info.Owner, err = cephfs.CephfsGetOwnerUsername(ctx, CLI.Cephfs.Name.Name)
 		if err != nil {
-			fmt.Printf("Error obtaining next gid number: %v\n", err)
-			os.Exit(1)
+			fail("Error getting Owner", err)
 		}
-		fmt.Println(gid)
-
-	case "aduser <name> get-uid":
-		uid, err := ld.GetUidOfExistingUser(ctx, CLI.Aduser.Name.Name)
+		info.Members, err = cephfs.CephfsCountMembers(ctx, CLI.Cephfs.Name.Name)
 		if err != nil {
-			fmt.Printf("Error obtaining uid for user: %v\n", err)
-			os.Exit(1)
+			fail("Error counting members", err)
 		}
-		fmt.Println(uid)
-
-	case "aduser <name> remove-talapas-group-user":
-		removed_user, err := ld.RemoveUserFromTalapasMaster(ctx, CLI.Aduser.Name.Name)
+		f, frozen, err := cephfs.CephfsGetFreeze(ctx, CLI.Cephfs.Name.Name)
 		if err != nil {
-			fmt.Printf("Error removing user from Talapas group (is.racs.talapas.users): %v\n", err)
-			os.Exit(1)
+			fail("Error checking freeze", err)
 		}
-		fmt.Printf("%s", removed_user)
-
-	case "aduser <name> add-talapas-group-user":
-		added_user, err := ld.AddUserToTalapasMaster(ctx, CLI.Aduser.Name.Name)
+		if frozen {
+			info.Frozen, info.Freeze = true, &f
+		}
+		printGroupInfo(info)
+	case "cephfs <name> freeze":
+		found, err := cephfs.CephfsExists(ctx, CLI.Cephfs.Name.Name)
 		if err != nil {
-			fmt.Printf("Error adding user to Talapas group (is.racs.talapas.users): %v\n", err)
-			os.Exit(1)
+			fail("Error checking cephfs group existence", err)
 		}
-		fmt.Printf("%s", added_user)
-
-	case "cephfs list":
-		cephfs_groups, err := cephfs.CephfsList(ctx)
+		if !found {
+			notFound("cephfs group %s not found.", CLI.Cephfs.Name.Name)
+			return
+		}
+		err = cephfs.CephfsFreeze(ctx, CLI.Cephfs.Name.Name, newFreeze())
 		if err != nil {
-			fmt.Printf("Error obtaining list of all cephfs groups: %v\n", err)
-			os.Exit(1)
+			fail("Error freezing cephfs group", err)
 		}
-		if len(cephfs_groups) == 0 {
-			fmt.Println("No cephfs groups found.")
+	case "cephfs <name> unfreeze":
+		found, err := cephfs.CephfsExists(ctx, CLI.Cephfs.Name.Name)
+		if err != nil {
+			fail("Error checking cephfs group existence", err)
+		}
+		if !found {
+			notFound("cephfs group %s not found.", CLI.Cephfs.Name.Name)
 			return
 		}
-		for _, groups := range cephfs_groups{
-			fmt.Println(groups)
+		err = cephfs.CephfsUnfreeze(ctx, CLI.Cephfs.Name.Name)
+		if err != nil {
+			fail("Error unfreezing cephfs group", err)
 		}
-
 	case "cephfs <name> list-members":
 		found, err := cephfs.CephfsExists(ctx, CLI.Cephfs.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking cephfs group existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking cephfs group existence", err)
 		}
 		if !found {
-			fmt.Printf("cephfs %s not found.\n", CLI.Cephfs.Name.Name)
+			notFound("cephfs %s not found.", CLI.Cephfs.Name.Name)
+			return
+		}
+		if p := (memberPaging{CLI.Cephfs.Name.ListMembers.Limit, CLI.Cephfs.Name.ListMembers.Offset, CLI.Cephfs.Name.ListMembers.Sort}); p.active() {
+			if len(CLI.Cephfs.Name.ListMembers.Fields) > 0 || CLI.Cephfs.Name.ListMembers.Count {
+				failUsage("--limit, --offset, and --sort can't be combined with --fields or --count.")
+			}
+			dns, err := cephfs.CephfsListMemberDNs(ctx, CLI.Cephfs.Name.Name)
+			if err != nil {
+				fail("Error listing members", err)
+			}
+			printMemberPage(dns, p, CLI.Cephfs.Name.ListMembers.DNOnly, CLI.Cephfs.Name.ListMembers.Summary)
+			return
+		}
+		if len(CLI.Cephfs.Name.ListMembers.Fields) > 0 {
+			dns, err := cephfs.CephfsListMemberDNs(ctx, CLI.Cephfs.Name.Name)
+			if err != nil {
+				fail("Error listing members", err)
+			}
+			printMemberFields(ctx, dns, CLI.Cephfs.Name.ListMembers.Fields)
+			return
+		}
+		if CLI.Cephfs.Name.ListMembers.DNOnly {
+			dns, err := cephfs.CephfsListMemberDNs(ctx, CLI.Cephfs.Name.Name)
+			if err != nil {
+				fail("Error listing members", err)
+			}
+			printMembers(dns, false, "")
+			return
+		}
+		if CLI.Cephfs.Name.ListMembers.Count {
+			count, err := cephfs.CephfsCountMembers(ctx, CLI.Cephfs.Name.Name)
+			if err != nil {
+				fail("Error counting members", err)
+			}
+			printMemberCount(count)
 			return
 		}
 		members, err := cephfs.CephfsListMemberUsernames(ctx, CLI.Cephfs.Name.Name)
 		if err != nil {
-			fmt.Printf("Error listing members: %v\n", err)
-			os.Exit(1)
-		}
-		for _, member := range members {
-			fmt.Println(member)
+			fail("Error listing members", err)
 		}
+		printMembers(members, CLI.Cephfs.Name.ListMembers.Summary, "")
 
     case "cephfs <name> list-admins":
 		found, err := cephfs.CephfsExists(ctx, CLI.Cephfs.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking cephfs group existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking cephfs group existence", err)
 		}
 		if !found {
-			fmt.Printf("cephfs group %s not found.\n", CLI.Cephfs.Name.Name)
+			notFound("cephfs group %s not found.", CLI.Cephfs.Name.Name)
+			return
+		}
+		if len(CLI.Cephfs.Name.ListAdmins.Fields) > 0 {
+			dns, err := cephfs.CephfsListAdminDNs(ctx, CLI.Cephfs.Name.Name)
+			if err != nil {
+				fail("Error listing admins", err)
+			}
+			printMemberFields(ctx, dns, CLI.Cephfs.Name.ListAdmins.Fields)
 			return
 		}
 		admins, err := cephfs.CephfsListAdminUsernames(ctx, CLI.Cephfs.Name.Name)
 		if err != nil {
-			fmt.Printf("Error listing admins: %v\n", err)
-			os.Exit(1)
+			fail("Error listing admins", err)
+		}
+		if CLI.Cephfs.Name.ListAdmins.WithRole {
+			owner, err := cephfs.CephfsGetOwnerUsername(ctx, CLI.Cephfs.Name.Name)
+			if err != nil {
+				slog.Warn("Failed to get owner, listing admins without one", "cephfs", CLI.Cephfs.Name.Name, "error", err)
+			}
+			printAdminRoles(managedgroup.Cephfs.AdminRoles(admins, owner))
+			return
 		}
 		for _, admin := range admins {
-			fmt.Println(admin)
+			fmt.Fprintln(stdout, admin)
 		}
 	case "cephfs <name> add-admin <username>":
 		found, err := cephfs.CephfsExists(ctx, CLI.Cephfs.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking Cephfs existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking Cephfs existence", err)
 		}
 		if !found {
-			fmt.Printf("Cephfs %s not found.\n", CLI.Cephfs.Name.Name)
+			notFound("Cephfs %s not found.", CLI.Cephfs.Name.Name)
 			return
 		}
 		for _, username := range CLI.Cephfs.Name.AddAdmin.Usernames {
 			err = cephfs.CephfsAddAdmin(ctx, CLI.Cephfs.Name.Name, username)
 			if err != nil {
-				fmt.Printf("Error adding admin %s: %v\n", username, err)
-				os.Exit(1)
+				fail(fmt.Sprintf("Error adding admin %s", username), err)
 			}
 		}
 	case "cephfs <name> remove-admin <username>":
 		found, err := cephfs.CephfsExists(ctx, CLI.Cephfs.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking PIRG existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking PIRG existence", err)
 		}
 		if !found {
-			fmt.Printf("Cephfs %s not found.\n", CLI.Cephfs.Name.Name)
+			notFound("Cephfs %s not found.", CLI.Cephfs.Name.Name)
 			return
 		}
 		for _, username := range CLI.Cephfs.Name.RemoveAdmin.Usernames {
 			err = cephfs.CephfsRemoveAdmin(ctx, CLI.Cephfs.Name.Name, username)
 			if err != nil {
-				fmt.Printf("Error removing admin %s: %v\n", username, err)
-				os.Exit(1)
+				fail(fmt.Sprintf("Error removing admin %s", username), err)
 			}
 		}
+	case "cephfs <name> dns":
+		found, err := cephfs.CephfsExists(ctx, CLI.Cephfs.Name.Name)
+		if err != nil {
+			fail("Error checking cephfs group existence", err)
+		}
+		if !found {
+			notFound("cephfs group %s not found.", CLI.Cephfs.Name.Name)
+			return
+		}
+		components, err := cephfs.CephfsDNs(ctx, CLI.Cephfs.Name.Name)
+		if err != nil {
+			fail("Error resolving cephfs group DNs", err)
+		}
+		printComponents(components)
+	case "cephfs <name> check":
+		found, err := cephfs.CephfsExists(ctx, CLI.Cephfs.Name.Name)
+		if err != nil {
+			fail("Error checking cephfs group existence", err)
+		}
+		if !found {
+			notFound("cephfs group %s not found.", CLI.Cephfs.Name.Name)
+			return
+		}
+		problems, err := cephfs.CephfsCheck(ctx, CLI.Cephfs.Name.Name)
+		if err != nil {
+			fail("Error checking cephfs group", err)
+		}
+		reportProblems(ctx, problems, CLI.Cephfs.Name.Check.Fix)
 	case "cephfs <name> get-gid":
 		gid, err := cephfs.GetCephfsGroupGID(ctx, CLI.Cephfs.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking cephfs group existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking cephfs group existence", err)
 		}
-		fmt.Println(gid)
+		fmt.Fprintln(stdout, gid)
 
 	case "cephfs <name> get-owner":
 		ownerName, err := cephfs.CephfsGetOwnerUsername(ctx, CLI.Cephfs.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking cephfs group existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking cephfs group existence", err)
 		}
 		if len(ownerName) == 0 {
-	   	    fmt.Println("No PI assigned to this cephfs group")
+	   	    fmt.Fprintln(stdout, "No PI assigned to this cephfs group")
 	   	} else {
-			fmt.Println(ownerName)
+			fmt.Fprintln(stdout, ownerName)
 	   	}
 
 	case "cephfs <name> set-owner":
 		found, err := cephfs.CephfsExists(ctx, CLI.Cephfs.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking cephfs group existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking cephfs group existence", err)
 		}
 		if found {
 			slog.Debug("cephfs group already exists")
@@ -709,130 +2114,295 @@ func main() {
 		if res == nil {
 			return 
 		}
-		fmt.Printf("Error setting pi of cephs3 group: %s\n", res)
+		fmt.Fprintf(stdout, "Error setting pi of cephs3 group: %s\n", res)
 		return
 
+	case "cephfs <name> transfer-owner <username>":
+		opts := CLI.Cephfs.Name.TransferOwner
+		found, err := cephfs.CephfsExists(ctx, CLI.Cephfs.Name.Name)
+		if err != nil {
+			fail("Error checking cephfs group existence", err)
+		}
+		if !found {
+			notFound("cephfs group %s not found.", CLI.Cephfs.Name.Name)
+			return
+		}
+		previous, err := cephfs.CephfsTransferOwner(ctx, CLI.Cephfs.Name.Name, opts.Username, opts.DemoteOld)
+		if err != nil {
+			fail("Error transferring ownership of cephfs group", err)
+		}
+		if jsonOutput() {
+			printJSON(map[string]any{"previous_owner": previous, "owner": opts.Username, "demoted": opts.DemoteOld && previous != "" && !strings.EqualFold(previous, opts.Username)})
+			break
+		}
+		if previous == "" {
+			fmt.Fprintf(stdout, "cephfs group %s had no Owner; %s is now its Owner.\n", CLI.Cephfs.Name.Name, opts.Username)
+			break
+		}
+		if strings.EqualFold(previous, opts.Username) {
+			fmt.Fprintf(stdout, "%s is already the Owner of cephfs group %s.\n", opts.Username, CLI.Cephfs.Name.Name)
+			break
+		}
+		fmt.Fprintf(stdout, "Transferred cephfs group %s from %s to %s.\n", CLI.Cephfs.Name.Name, previous, opts.Username)
+		if opts.DemoteOld {
+			fmt.Fprintf(stdout, "Removed %s from the admins group; they remain a member.\n", previous)
+		}
+
 	case "cephfs <name> create":
 		found, err := cephfs.CephfsExists(ctx, CLI.Cephfs.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking cephfs group existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking cephfs group existence", err)
 		}
 		if found {
-			fmt.Printf("cephfs group %s already exists.\n", CLI.Cephfs.Name.Name)
+			alreadyExists("cephfs group %s already exists.", CLI.Cephfs.Name.Name)
 			return
 		}
 		err = cephfs.CephfsCreate(ctx, CLI.Cephfs.Name.Name, CLI.Cephfs.Name.Create.Owner)
 		if err != nil {
-			fmt.Printf("Error creating cephfs group: %v\n", err)
-			os.Exit(1)
+			fail("Error creating cephfs group", err)
 		}
 	case "cephfs <name> delete":
 		found, err := cephfs.CephfsExists(ctx, CLI.Cephfs.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking cephfs existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking cephfs existence", err)
 		}
 		if !found {
-			fmt.Printf("cephfs group %s not found.\n", CLI.Cephfs.Name.Name)
+			notFound("cephfs group %s not found.", CLI.Cephfs.Name.Name)
 			return
 		}
 		err = cephfs.CephfsDelete(ctx, CLI.Cephfs.Name.Name)
 		if err != nil {
-			fmt.Printf("Error deleting cephfs group: %v\n", err)
-			os.Exit(1)
+			fail("Error deleting cephfs group", err)
+		}
+	case "cephfs <name> rename <new-name>":
+		found, err := cephfs.CephfsExists(ctx, CLI.Cephfs.Name.Name)
+		if err != nil {
+			fail("Error checking cephfs group existence", err)
 		}
-	case "cephfs <name> add-member <username>":
+		if !found {
+			notFound("cephfs group %s not found.", CLI.Cephfs.Name.Name)
+			return
+		}
+		found, err = cephfs.CephfsExists(ctx, CLI.Cephfs.Name.Rename.NewName)
+		if err != nil {
+			fail("Error checking cephfs group existence", err)
+		}
+		if found {
+			alreadyExists("cephfs group %s already exists.", CLI.Cephfs.Name.Rename.NewName)
+			exit(1)
+		}
+		err = cephfs.CephfsRename(ctx, CLI.Cephfs.Name.Name, CLI.Cephfs.Name.Rename.NewName)
+		if err != nil {
+			fail("Error renaming cephfs group", err)
+		}
+	case "cephfs <name> add-member", "cephfs <name> add-member <username>":
+		checkMemberArgs(CLI.Cephfs.Name.AddMember.Usernames, CLI.Cephfs.Name.AddMember.DNs)
 		found, err := cephfs.CephfsExists(ctx, CLI.Cephfs.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking PIRG existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking PIRG existence", err)
 		}
 		if !found {
-			fmt.Printf("cephfs group %s not found.\n", CLI.Cephfs.Name.Name)
+			notFound("cephfs group %s not found.", CLI.Cephfs.Name.Name)
 			return
 		}
+		if parallel() {
+			name := CLI.Cephfs.Name.Name
+			results := runParallel(ctx, CLI.Cephfs.Name.AddMember.Usernames, CLI.Cephfs.Name.AddMember.DNs,
+				func(ctx context.Context, username string) error { return cephfs.CephfsAddMember(ctx, name, username) },
+				func(ctx context.Context, dn string) error { return cephfs.CephfsAddMemberDN(ctx, name, dn) })
+			reportParallel("add", results)
+			break
+		}
+		for _, dn := range CLI.Cephfs.Name.AddMember.DNs {
+			err = cephfs.CephfsAddMemberDN(ctx, CLI.Cephfs.Name.Name, dn)
+			if err != nil {
+				fail(fmt.Sprintf("Error adding member %s", dn), err)
+			}
+		}
 		for _, username := range CLI.Cephfs.Name.AddMember.Usernames {
 			err = cephfs.CephfsAddMember(ctx, CLI.Cephfs.Name.Name, username)
 			if err != nil {
-				fmt.Printf("Error adding member %s: %v\n", username, err)
-				os.Exit(1)
+				fail(fmt.Sprintf("Error adding member %s", username), err)
 			}
 		}
-	case "cephfs <name> remove-member <username>":
+	case "cephfs <name> remove-member", "cephfs <name> remove-member <username>":
+		checkMemberArgs(CLI.Cephfs.Name.RemoveMember.Usernames, CLI.Cephfs.Name.RemoveMember.DNs)
 		found, err := cephfs.CephfsExists(ctx, CLI.Cephfs.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking cephfs group existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking cephfs group existence", err)
 		}
 		if !found {
-			fmt.Printf("cephfs group %s not found.\n", CLI.Cephfs.Name.Name)
+			notFound("cephfs group %s not found.", CLI.Cephfs.Name.Name)
 			return
 		}
+		if parallel() {
+			name := CLI.Cephfs.Name.Name
+			results := runParallel(ctx, CLI.Cephfs.Name.RemoveMember.Usernames, CLI.Cephfs.Name.RemoveMember.DNs,
+				func(ctx context.Context, username string) error { return cephfs.CephfsRemoveMember(ctx, name, username) },
+				func(ctx context.Context, dn string) error { return cephfs.CephfsRemoveMemberDN(ctx, name, dn) })
+			reportParallel("remove", results)
+			break
+		}
+		for _, dn := range CLI.Cephfs.Name.RemoveMember.DNs {
+			err = cephfs.CephfsRemoveMemberDN(ctx, CLI.Cephfs.Name.Name, dn)
+			if err != nil && !notMember(dn, CLI.Cephfs.Name.Name, err) {
+				fail(fmt.Sprintf("Error removing member %s", dn), err)
+			}
+		}
 		for _, username := range CLI.Cephfs.Name.RemoveMember.Usernames {
 			err = cephfs.CephfsRemoveMember(ctx, CLI.Cephfs.Name.Name, username)
-			if err != nil {
-				fmt.Printf("Error removing member %s: %v\n", username, err)
-				os.Exit(1)
+			if err != nil && !notMember(username, CLI.Cephfs.Name.Name, err) {
+				fail(fmt.Sprintf("Error removing member %s", username), err)
 			}
 		}
+	case "cephs3 orphans list", "cephs3 orphans clean":
+		orphans, err := cephs3.Cephs3ListOrphans(ctx)
+		if err != nil {
+			fail("Error listing orphan cephs3 OUs", err)
+		}
+		if cli.Command() == "cephs3 orphans list" {
+			printOrphans(orphans, "No orphan cephs3 OUs found.")
+			return
+		}
+		cleanOrphans(ctx, orphans, cephs3.Cephs3DeleteOrphan, CLI.Cephs3.Orphans.Clean.Yes)
 	case "cephs3 list":
+		if CLI.Cephs3.List.All || CLI.Cephs3.List.Nonconforming {
+			groups, err := cephs3.Cephs3ListAll(ctx)
+			if err != nil {
+				fail("Error obtaining list of all cephs3 groups", err)
+			}
+			printClassifications(groups, CLI.Cephs3.List.Nonconforming)
+			return
+		}
 		cephs3_groups, err := cephs3.Cephs3List(ctx)
 		if err != nil {
-			fmt.Printf("Error obtaining list of all cephs3 groups: %v\n", err)
-			os.Exit(1)
+			fail("Error obtaining list of all cephs3 groups", err)
 		}
 		if len(cephs3_groups) == 0 {
-			fmt.Println("No cephs3 groups found.")
+			fmt.Fprintln(stdout, "No cephs3 groups found.")
 			return
 		}
 		for _, groups := range cephs3_groups{
-			fmt.Println(groups)
+			fmt.Fprintln(stdout, groups)
 		}
 
 	case "cephs3 <name> list-members":
 		found, err := cephs3.Cephs3Exists(ctx, CLI.Cephs3.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking cephs3 group existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking cephs3 group existence", err)
 		}
 		if !found {
-			fmt.Printf("cephs3 %s not found.\n", CLI.Cephs3.Name.Name)
+			notFound("cephs3 %s not found.", CLI.Cephs3.Name.Name)
+			return
+		}
+		if p := (memberPaging{CLI.Cephs3.Name.ListMembers.Limit, CLI.Cephs3.Name.ListMembers.Offset, CLI.Cephs3.Name.ListMembers.Sort}); p.active() {
+			if len(CLI.Cephs3.Name.ListMembers.Fields) > 0 || CLI.Cephs3.Name.ListMembers.Count {
+				failUsage("--limit, --offset, and --sort can't be combined with --fields or --count.")
+			}
+			dns, err := cephs3.Cephs3ListMemberDNs(ctx, CLI.Cephs3.Name.Name)
+			if err != nil {
+				fail("Error listing members", err)
+			}
+			printMemberPage(dns, p, CLI.Cephs3.Name.ListMembers.DNOnly, CLI.Cephs3.Name.ListMembers.Summary)
+			return
+		}
+		if len(CLI.Cephs3.Name.ListMembers.Fields) > 0 {
+			dns, err := cephs3.Cephs3ListMemberDNs(ctx, CLI.Cephs3.Name.Name)
+			if err != nil {
+				fail("Error listing members", err)
+			}
+			printMemberFields(ctx, dns, CLI.Cephs3.Name.ListMembers.Fields)
+			return
+		}
+		if CLI.Cephs3.Name.ListMembers.DNOnly {
+			dns, err := cephs3.Cephs3ListMemberDNs(ctx, CLI.Cephs3.Name.Name)
+			if err != nil {
+				fail("Error listing members", err)
+			}
+			printMembers(dns, false, "")
+			return
+		}
+		if CLI.Cephs3.Name.ListMembers.Count {
+			count, err := cephs3.Cephs3CountMembers(ctx, CLI.Cephs3.Name.Name)
+			if err != nil {
+				fail("Error counting members", err)
+			}
+			printMemberCount(count)
 			return
 		}
 		members, err := cephs3.Cephs3ListMemberUsernames(ctx, CLI.Cephs3.Name.Name)
 		if err != nil {
-			fmt.Printf("Error listing members: %v\n", err)
-			os.Exit(1)
+			fail("Error listing members", err)
+		}
+		printMembers(members, CLI.Cephs3.Name.ListMembers.Summary, "")
+	case "cephs3 <name> dns":
+		found, err := cephs3.Cephs3Exists(ctx, CLI.Cephs3.Name.Name)
+		if err != nil {
+			fail("Error checking cephs3 group existence", err)
+		}
+		if !found {
+			notFound("cephs3 group %s not found.", CLI.Cephs3.Name.Name)
+			return
+		}
+		components, err := cephs3.Cephs3DNs(ctx, CLI.Cephs3.Name.Name)
+		if err != nil {
+			fail("Error resolving cephs3 group DNs", err)
+		}
+		printComponents(components)
+	case "cephs3 <name> policy":
+		found, err := cephs3.Cephs3Exists(ctx, CLI.Cephs3.Name.Name)
+		if err != nil {
+			fail("Error checking cephs3 group existence", err)
+		}
+		if !found {
+			notFound("cephs3 group %s not found.", CLI.Cephs3.Name.Name)
+			return
+		}
+		p, err := cephs3.Cephs3Policy(ctx, CLI.Cephs3.Name.Name)
+		if err != nil {
+			fail("Error looking up cephs3 groups", err)
+		}
+		policy, err := cephs3.RenderPolicy(ctx, p)
+		if err != nil {
+			fail("Error rendering policy", err)
+		}
+		fmt.Fprint(stdout, policy)
+	case "cephs3 <name> check":
+		found, err := cephs3.Cephs3Exists(ctx, CLI.Cephs3.Name.Name)
+		if err != nil {
+			fail("Error checking cephs3 group existence", err)
+		}
+		if !found {
+			notFound("cephs3 group %s not found.", CLI.Cephs3.Name.Name)
+			return
 		}
-		for _, member := range members {
-			fmt.Println(member)
+		problems, err := cephs3.Cephs3Check(ctx, CLI.Cephs3.Name.Name)
+		if err != nil {
+			fail("Error checking cephs3 group", err)
 		}
+		reportProblems(ctx, problems, CLI.Cephs3.Name.Check.Fix)
 	case "cephs3 <name> get-gid":
 		gid, err := cephs3.GetCephs3GroupGID(ctx, CLI.Cephs3.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking cephs3 group existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking cephs3 group existence", err)
 		}
-		fmt.Println(gid)
+		fmt.Fprintln(stdout, gid)
 
 	case "cephs3 <name> get-owner":
 		ownerName, err := cephs3.Cephs3GetOwnerUsername(ctx, CLI.Cephs3.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking cephs3 group existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking cephs3 group existence", err)
 		}
 		if len(ownerName) == 0 {
-	   	    fmt.Println("No PI assigned to this cephs3 group")
+	   	    fmt.Fprintln(stdout, "No PI assigned to this cephs3 group")
 	   	} else {
-			fmt.Println(ownerName)
+			fmt.Fprintln(stdout, ownerName)
 	   	}
 
 	case "cephs3 <name> set-owner":
 		found, err := cephs3.Cephs3Exists(ctx, CLI.Cephs3.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking cephs3 group existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking cephs3 group existence", err)
 		}
 		if found {
 			slog.Debug("cephs3 group already exists")
@@ -841,222 +2411,373 @@ func main() {
 		if res == nil {
 			return 
 		}
-		fmt.Printf("Error setting pi of cephs3 group: %s\n", res)
+		fmt.Fprintf(stdout, "Error setting pi of cephs3 group: %s\n", res)
 		return
     case "cephs3 <name> list-admins":
 		found, err := cephs3.Cephs3Exists(ctx, CLI.Cephs3.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking cephs3 group existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking cephs3 group existence", err)
 		}
 		if !found {
-			fmt.Printf("cephs3 group %s not found.\n", CLI.Cephs3.Name.Name)
+			notFound("cephs3 group %s not found.", CLI.Cephs3.Name.Name)
+			return
+		}
+		if len(CLI.Cephs3.Name.ListAdmins.Fields) > 0 {
+			dns, err := cephs3.Cephs3ListAdminDNs(ctx, CLI.Cephs3.Name.Name)
+			if err != nil {
+				fail("Error listing admins", err)
+			}
+			printMemberFields(ctx, dns, CLI.Cephs3.Name.ListAdmins.Fields)
 			return
 		}
 		admins, err := cephs3.Cephs3ListAdminUsernames(ctx, CLI.Cephs3.Name.Name)
 		if err != nil {
-			fmt.Printf("Error listing admins: %v\n", err)
-			os.Exit(1)
+			fail("Error listing admins", err)
 		}
 		for _, admin := range admins {
-			fmt.Println(admin)
+			fmt.Fprintln(stdout, admin)
 		}
 	case "cephs3 <name> add-admin <username>":
 		found, err := cephs3.Cephs3Exists(ctx, CLI.Cephs3.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking cephs3 existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking cephs3 existence", err)
 		}
 		if !found {
-			fmt.Printf("cephs3 %s not found.\n", CLI.Cephs3.Name.Name)
+			notFound("cephs3 %s not found.", CLI.Cephs3.Name.Name)
 			return
 		}
 		for _, username := range CLI.Cephs3.Name.AddAdmin.Usernames {
 			err = cephs3.Cephs3AddAdmin(ctx, CLI.Cephs3.Name.Name, username)
 			if err != nil {
-				fmt.Printf("Error adding admin %s: %v\n", username, err)
-				os.Exit(1)
+				fail(fmt.Sprintf("Error adding admin %s", username), err)
 			}
 		}
 	case "cephs3 <name> remove-admin <username>":
 		found, err := cephs3.Cephs3Exists(ctx, CLI.Cephs3.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking PIRG existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking PIRG existence", err)
 		}
 		if !found {
-			fmt.Printf("cephs3 %s not found.\n", CLI.Cephs3.Name.Name)
+			notFound("cephs3 %s not found.", CLI.Cephs3.Name.Name)
 			return
 		}
 		for _, username := range CLI.Cephs3.Name.RemoveAdmin.Usernames {
 			err = cephs3.Cephs3RemoveAdmin(ctx, CLI.Cephs3.Name.Name, username)
 			if err != nil {
-				fmt.Printf("Error removing admin %s: %v\n", username, err)
-				os.Exit(1)
+				fail(fmt.Sprintf("Error removing admin %s", username), err)
 			}
 		}
 
 	case "cephs3 <name> create":
 		found, err := cephs3.Cephs3Exists(ctx, CLI.Cephs3.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking cephs3 group existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking cephs3 group existence", err)
 		}
 		if found {
-			fmt.Printf("cephs3 group %s already exists.\n", CLI.Cephs3.Name.Name)
+			alreadyExists("cephs3 group %s already exists.", CLI.Cephs3.Name.Name)
 			return
 		}
 		err = cephs3.Cephs3Create(ctx, CLI.Cephs3.Name.Name, CLI.Cephs3.Name.Create.Owner)
 		if err != nil {
-			fmt.Printf("Error creating cephs3 group: %v\n", err)
-			os.Exit(1)
+			fail("Error creating cephs3 group", err)
 		}
 	case "cephs3 <name> delete":
 		found, err := cephs3.Cephs3Exists(ctx, CLI.Cephs3.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking cephs3 existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking cephs3 existence", err)
 		}
 		if !found {
-			fmt.Printf("cephs3 group %s not found.\n", CLI.Cephs3.Name.Name)
+			notFound("cephs3 group %s not found.", CLI.Cephs3.Name.Name)
 			return
 		}
 		err = cephs3.Cephs3Delete(ctx, CLI.Cephs3.Name.Name)
 		if err != nil {
-			fmt.Printf("Error deleting cephs3 group: %v\n", err)
-			os.Exit(1)
+			fail("Error deleting cephs3 group", err)
+		}
+	case "cephs3 <name> rename <new-name>":
+		found, err := cephs3.Cephs3Exists(ctx, CLI.Cephs3.Name.Name)
+		if err != nil {
+			fail("Error checking cephs3 group existence", err)
+		}
+		if !found {
+			notFound("cephs3 group %s not found.", CLI.Cephs3.Name.Name)
+			return
+		}
+		found, err = cephs3.Cephs3Exists(ctx, CLI.Cephs3.Name.Rename.NewName)
+		if err != nil {
+			fail("Error checking cephs3 group existence", err)
+		}
+		if found {
+			alreadyExists("cephs3 group %s already exists.", CLI.Cephs3.Name.Rename.NewName)
+			exit(1)
+		}
+		err = cephs3.Cephs3Rename(ctx, CLI.Cephs3.Name.Name, CLI.Cephs3.Name.Rename.NewName)
+		if err != nil {
+			fail("Error renaming cephs3 group", err)
 		}
-	case "cephs3 <name> add-member <username>":
+	case "cephs3 <name> add-member", "cephs3 <name> add-member <username>":
+		checkMemberArgs(CLI.Cephs3.Name.AddMember.Usernames, CLI.Cephs3.Name.AddMember.DNs)
 		found, err := cephs3.Cephs3Exists(ctx, CLI.Cephs3.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking PIRG existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking PIRG existence", err)
 		}
 		if !found {
-			fmt.Printf("cephs3 group %s not found.\n", CLI.Cephs3.Name.Name)
+			notFound("cephs3 group %s not found.", CLI.Cephs3.Name.Name)
 			return
 		}
+		if parallel() {
+			name := CLI.Cephs3.Name.Name
+			results := runParallel(ctx, CLI.Cephs3.Name.AddMember.Usernames, CLI.Cephs3.Name.AddMember.DNs,
+				func(ctx context.Context, username string) error { return cephs3.Cephs3AddMember(ctx, name, username) },
+				func(ctx context.Context, dn string) error { return cephs3.Cephs3AddMemberDN(ctx, name, dn) })
+			reportParallel("add", results)
+			break
+		}
+		for _, dn := range CLI.Cephs3.Name.AddMember.DNs {
+			err = cephs3.Cephs3AddMemberDN(ctx, CLI.Cephs3.Name.Name, dn)
+			if err != nil {
+				fail(fmt.Sprintf("Error adding member %s", dn), err)
+			}
+		}
 		for _, username := range CLI.Cephs3.Name.AddMember.Usernames {
 			err = cephs3.Cephs3AddMember(ctx, CLI.Cephs3.Name.Name, username)
 			if err != nil {
-				fmt.Printf("Error adding member %s: %v\n", username, err)
-				os.Exit(1)
+				fail(fmt.Sprintf("Error adding member %s", username), err)
 			}
 		}
-	case "cephs3 <name> remove-member <username>":
+	case "cephs3 <name> remove-member", "cephs3 <name> remove-member <username>":
+		checkMemberArgs(CLI.Cephs3.Name.RemoveMember.Usernames, CLI.Cephs3.Name.RemoveMember.DNs)
 		found, err := cephs3.Cephs3Exists(ctx, CLI.Cephs3.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking cephs3 group existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking cephs3 group existence", err)
 		}
 		if !found {
-			fmt.Printf("cephs3 group %s not found.\n", CLI.Cephs3.Name.Name)
+			notFound("cephs3 group %s not found.", CLI.Cephs3.Name.Name)
 			return
 		}
+		if parallel() {
+			name := CLI.Cephs3.Name.Name
+			results := runParallel(ctx, CLI.Cephs3.Name.RemoveMember.Usernames, CLI.Cephs3.Name.RemoveMember.DNs,
+				func(ctx context.Context, username string) error { return cephs3.Cephs3RemoveMember(ctx, name, username) },
+				func(ctx context.Context, dn string) error { return cephs3.Cephs3RemoveMemberDN(ctx, name, dn) })
+			reportParallel("remove", results)
+			break
+		}
+		for _, dn := range CLI.Cephs3.Name.RemoveMember.DNs {
+			err = cephs3.Cephs3RemoveMemberDN(ctx, CLI.Cephs3.Name.Name, dn)
+			if err != nil && !notMember(dn, CLI.Cephs3.Name.Name, err) {
+				fail(fmt.Sprintf("Error removing member %s", dn), err)
+			}
+		}
 		for _, username := range CLI.Cephs3.Name.RemoveMember.Usernames {
 			err = cephs3.Cephs3RemoveMember(ctx, CLI.Cephs3.Name.Name, username)
-			if err != nil {
-				fmt.Printf("Error removing member %s: %v\n", username, err)
-				os.Exit(1)
+			if err != nil && !notMember(username, CLI.Cephs3.Name.Name, err) {
+				fail(fmt.Sprintf("Error removing member %s", username), err)
 			}
 		}
 	case "software list":
 		software_groups, err := software.SoftwareList(ctx)
 		if err != nil {
-			fmt.Printf("Error obtaining list of all Software groups: %v\n", err)
-			os.Exit(1)
+			fail("Error obtaining list of all Software groups", err)
 		}
 		if len(software_groups) == 0 {
-			fmt.Println("No Software groups found.")
+			fmt.Fprintln(stdout, "No Software groups found.")
 			return
 		}
 		for _, groups := range software_groups{
-			fmt.Println(groups)
+			fmt.Fprintln(stdout, groups)
 		}
 	case "software <name> list-members":
 		found, err := software.SoftwareExists(ctx, CLI.Software.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking Software group existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking Software group existence", err)
 		}
 		if !found {
-			fmt.Printf("Software %s not found.\n", CLI.Software.Name.Name)
+			notFound("Software %s not found.", CLI.Software.Name.Name)
+			return
+		}
+		if p := (memberPaging{CLI.Software.Name.ListMembers.Limit, CLI.Software.Name.ListMembers.Offset, CLI.Software.Name.ListMembers.Sort}); p.active() {
+			if len(CLI.Software.Name.ListMembers.Fields) > 0 || CLI.Software.Name.ListMembers.Count {
+				failUsage("--limit, --offset, and --sort can't be combined with --fields or --count.")
+			}
+			dns, err := software.SoftwareListMemberDNs(ctx, CLI.Software.Name.Name)
+			if err != nil {
+				fail("Error listing members", err)
+			}
+			printMemberPage(dns, p, CLI.Software.Name.ListMembers.DNOnly, CLI.Software.Name.ListMembers.Summary)
+			return
+		}
+		if len(CLI.Software.Name.ListMembers.Fields) > 0 {
+			dns, err := software.SoftwareListMemberDNs(ctx, CLI.Software.Name.Name)
+			if err != nil {
+				fail("Error listing members", err)
+			}
+			printMemberFields(ctx, dns, CLI.Software.Name.ListMembers.Fields)
+			return
+		}
+		if CLI.Software.Name.ListMembers.DNOnly {
+			dns, err := software.SoftwareListMemberDNs(ctx, CLI.Software.Name.Name)
+			if err != nil {
+				fail("Error listing members", err)
+			}
+			printMembers(dns, false, "")
+			return
+		}
+		if CLI.Software.Name.ListMembers.Count {
+			count, err := software.SoftwareCountMembers(ctx, CLI.Software.Name.Name)
+			if err != nil {
+				fail("Error counting members", err)
+			}
+			printMemberCount(count)
 			return
 		}
 		members, err := software.SoftwareListMemberUsernames(ctx, CLI.Software.Name.Name)
 		if err != nil {
-			fmt.Printf("Error listing members: %v\n", err)
-			os.Exit(1)
+			fail("Error listing members", err)
 		}
-		for _, member := range members {
-			fmt.Println(member)
-		}
-	case "software <name> add-member <username>":
+		printMembers(members, CLI.Software.Name.ListMembers.Summary, "")
+	case "software <name> add-member", "software <name> add-member <username>":
+		checkMemberArgs(CLI.Software.Name.AddMember.Usernames, CLI.Software.Name.AddMember.DNs)
 		found, err := software.SoftwareExists(ctx, CLI.Software.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking SOFTWARE existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking SOFTWARE existence", err)
 		}
 		if !found {
-			fmt.Printf("SOFTWARE group %s not found.\n", CLI.Software.Name.Name)
+			notFound("SOFTWARE group %s not found.", CLI.Software.Name.Name)
 			return
 		}
+		if parallel() {
+			name := CLI.Software.Name.Name
+			results := runParallel(ctx, CLI.Software.Name.AddMember.Usernames, CLI.Software.Name.AddMember.DNs,
+				func(ctx context.Context, username string) error { return software.SoftwareAddMember(ctx, name, username) },
+				func(ctx context.Context, dn string) error { return software.SoftwareAddMemberDN(ctx, name, dn) })
+			reportParallel("add", results)
+			break
+		}
+		for _, dn := range CLI.Software.Name.AddMember.DNs {
+			err = software.SoftwareAddMemberDN(ctx, CLI.Software.Name.Name, dn)
+			if err != nil {
+				fail(fmt.Sprintf("Error adding member %s", dn), err)
+			}
+		}
 		for _, username := range CLI.Software.Name.AddMember.Usernames {
 			err = software.SoftwareAddMember(ctx, CLI.Software.Name.Name, username)
 			if err != nil {
-				fmt.Printf("Error adding member %s: %v\n", username, err)
-				os.Exit(1)
+				fail(fmt.Sprintf("Error adding member %s", username), err)
 			}
 		}
-	case "software <name> remove-member <username>":
+	case "software <name> remove-member", "software <name> remove-member <username>":
+		checkMemberArgs(CLI.Software.Name.RemoveMember.Usernames, CLI.Software.Name.RemoveMember.DNs)
 		found, err := software.SoftwareExists(ctx, CLI.Software.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking SOFTWARE group existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking SOFTWARE group existence", err)
 		}
 		if !found {
-			fmt.Printf("SOFTWARE group %s not found.\n", CLI.Software.Name.Name)
+			notFound("SOFTWARE group %s not found.", CLI.Software.Name.Name)
 			return
 		}
+		if parallel() {
+			name := CLI.Software.Name.Name
+			results := runParallel(ctx, CLI.Software.Name.RemoveMember.Usernames, CLI.Software.Name.RemoveMember.DNs,
+				func(ctx context.Context, username string) error { return software.SoftwareRemoveMember(ctx, name, username) },
+				func(ctx context.Context, dn string) error { return software.SoftwareRemoveMemberDN(ctx, name, dn) })
+			reportParallel("remove", results)
+			break
+		}
+		for _, dn := range CLI.Software.Name.RemoveMember.DNs {
+			err = software.SoftwareRemoveMemberDN(ctx, CLI.Software.Name.Name, dn)
+			if err != nil && !notMember(dn, CLI.Software.Name.Name, err) {
+				fail(fmt.Sprintf("Error removing member %s", dn), err)
+			}
+		}
 		for _, username := range CLI.Software.Name.RemoveMember.Usernames {
 			err = software.SoftwareRemoveMember(ctx, CLI.Software.Name.Name, username)
+			if err != nil && !notMember(username, CLI.Software.Name.Name, err) {
+				fail(fmt.Sprintf("Error removing member %s", username), err)
+			}
+		}
+	case "software <name> list-admins":
+		found, err := software.SoftwareExists(ctx, CLI.Software.Name.Name)
+		if err != nil {
+			fail("Error checking SOFTWARE group existence", err)
+		}
+		if !found {
+			notFound("SOFTWARE group %s not found.", CLI.Software.Name.Name)
+			return
+		}
+		if len(CLI.Software.Name.ListAdmins.Fields) > 0 {
+			dns, err := software.SoftwareListAdminDNs(ctx, CLI.Software.Name.Name)
+			if err != nil {
+				fail("Error listing admins", err)
+			}
+			printMemberFields(ctx, dns, CLI.Software.Name.ListAdmins.Fields)
+			return
+		}
+		admins, err := software.SoftwareListAdminUsernames(ctx, CLI.Software.Name.Name)
+		if err != nil {
+			fail("Error listing admins", err)
+		}
+		for _, admin := range admins {
+			fmt.Fprintln(stdout, admin)
+		}
+	case "software <name> add-admin <username>":
+		found, err := software.SoftwareExists(ctx, CLI.Software.Name.Name)
+		if err != nil {
+			fail("Error checking SOFTWARE group existence", err)
+		}
+		if !found {
+			notFound("SOFTWARE group %s not found.", CLI.Software.Name.Name)
+			return
+		}
+		for _, username := range CLI.Software.Name.AddAdmin.Usernames {
+			err = software.SoftwareAddAdmin(ctx, CLI.Software.Name.Name, username)
+			if err != nil {
+				fail(fmt.Sprintf("Error adding admin %s", username), err)
+			}
+		}
+	case "software <name> remove-admin <username>":
+		found, err := software.SoftwareExists(ctx, CLI.Software.Name.Name)
+		if err != nil {
+			fail("Error checking SOFTWARE group existence", err)
+		}
+		if !found {
+			notFound("SOFTWARE group %s not found.", CLI.Software.Name.Name)
+			return
+		}
+		for _, username := range CLI.Software.Name.RemoveAdmin.Usernames {
+			err = software.SoftwareRemoveAdmin(ctx, CLI.Software.Name.Name, username)
 			if err != nil {
-				fmt.Printf("Error removing member %s: %v\n", username, err)
-				os.Exit(1)
+				fail(fmt.Sprintf("Error removing admin %s", username), err)
 			}
 		}
 	case "software <name> create":
 		found, err := software.SoftwareExists(ctx, CLI.Software.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking software group existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking software group existence", err)
 		}
 		if found {
-			fmt.Printf("software group %s already exists.\n", CLI.Software.Name.Name)
+			alreadyExists("software group %s already exists.", CLI.Software.Name.Name)
 			return
 		}
 		err = software.SoftwareCreate(ctx, CLI.Software.Name.Name)
 		if err != nil {
-			fmt.Printf("Error creating software group: %v\n", err)
-			os.Exit(1)
+			fail("Error creating software group", err)
 		}
 	case "software <name> delete":
 		found, err := software.SoftwareExists(ctx, CLI.Software.Name.Name)
 		if err != nil {
-			fmt.Printf("Error checking software existence: %v\n", err)
-			os.Exit(1)
+			fail("Error checking software existence", err)
 		}
 		if !found {
-			fmt.Printf("software group %s not found.\n", CLI.Software.Name.Name)
+			notFound("software group %s not found.", CLI.Software.Name.Name)
 			return
 		}
 		err = software.SoftwareDelete(ctx, CLI.Software.Name.Name)
 		if err != nil {
-			fmt.Printf("Error deleting software group: %v\n", err)
-			os.Exit(1)
+			fail("Error deleting software group", err)
 		}
 	default:
-		fmt.Printf("Unknown command: %s\n", cli.Command())
-		os.Exit(1)
+		failUsage(fmt.Sprintf("Unknown command: %s", cli.Command()))
 	}
+	confirmMemberCount(0)
 }
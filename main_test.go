@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/ldap/ldaptest"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden transcripts in testdata")
+
+// logTime matches the timestamp of a text log line, which changes every run.
+var logTime = regexp.MustCompile(`time=\S+ `)
+
+// goldenCases are the command sequences run, in order, against a fresh
+// copy of testdata/directory.ldif. Each one's transcript is compared with
+// testdata/<name>.golden.
+var goldenCases = []struct {
+	name     string
+	commands [][]string
+}{
+	{"list", [][]string{
+		{"pirg", "list"},
+		{"pirg", "alpha", "list-members"},
+		{"pirg", "alpha", "get-pi"},
+		{"--output", "json", "pirg", "alpha", "list-members"},
+	}},
+	{"create", [][]string{
+		{"pirg", "beta", "create", "--pi", "carol"},
+		{"pirg", "list"},
+		{"pirg", "beta", "get-pi"},
+		{"pirg", "beta", "list-members"},
+	}},
+	{"add-member", [][]string{
+		{"pirg", "alpha", "add-member", "carol"},
+		{"pirg", "alpha", "list-members"},
+		{"pirg", "alpha", "add-member", "dave"},
+		{"pirg", "alpha", "add-member", "nobody"},
+	}},
+	{"remove-member", [][]string{
+		{"pirg", "alpha", "remove-member", "bob"},
+		{"pirg", "alpha", "list-members"},
+	}},
+	{"missing-group", [][]string{
+		{"pirg", "nosuch", "list-members"},
+		{"--output", "json", "pirg", "nosuch", "list-members"},
+		{"pirg", "nosuch", "add-member", "bob"},
+	}},
+	{"duplicate-create", [][]string{
+		{"pirg", "alpha", "create", "--pi", "carol"},
+		{"--output", "json", "pirg", "alpha", "create", "--pi", "carol"},
+	}},
+	{"remove-pi", [][]string{
+		{"pirg", "alpha", "remove-member", "alice"},
+		{"pirg", "alpha", "list-members"},
+	}},
+	{"remove-subgroup-also-parent", [][]string{
+		{"pirg", "alpha", "remove-member", "--from-subgroup", "lab", "--also-parent"},
+		{"pirg", "alpha", "subgroup", "lab", "list-members"},
+		{"pirg", "alpha", "list-members"},
+	}},
+	{"create-no-member", [][]string{
+		{"pirg", "beta", "create", "--pi", "carol", "--no-member"},
+		{"pirg", "beta", "list-members"},
+		{"pirg", "beta", "check"},
+		{"pirg", "beta", "fix-pi"},
+		{"pirg", "beta", "list-members"},
+		{"pirg", "beta", "set-pi", "--pi", "carol"},
+		{"pirg", "beta", "list-members"},
+		{"pirg", "beta", "check"},
+	}},
+	{"remove-members-of", [][]string{
+		{"pirg", "alpha", "remove-member", "--members-of", "gamma"},
+		{"pirg", "alpha", "list-members"},
+		{"pirg", "gamma", "list-members"},
+	}},
+	{"contacts", [][]string{
+		{"pirg", "alpha", "add-admin", "bob"},
+		{"--output", "csv", "pirg", "contacts"},
+		{"--output", "csv", "pirg", "contacts", "--pirg", "gamma"},
+		{"--output", "csv", "pirg", "contacts", "--pirg", "nosuch"},
+		{"pirg", "contacts"},
+	}},
+	{"subgroup-descriptions", [][]string{
+		{"pirg", "alpha", "subgroup", "bench", "create", "--description", "Bench instrument users"},
+		{"pirg", "alpha", "subgroup", "tmp", "create"},
+		{"pirg", "alpha", "subgroup", "list"},
+		{"pirg", "alpha", "subgroup", "list", "--long"},
+		{"pirg", "alpha", "subgroup", "tmp", "set-description", "Scratch space for the spring course"},
+		{"pirg", "alpha", "subgroup", "bench", "set-description", ""},
+		{"pirg", "alpha", "subgroup", "list", "--long"},
+		{"--output", "json", "pirg", "alpha", "subgroup", "list", "--long"},
+	}},
+	{"dns", [][]string{
+		{"pirg", "alpha", "dns"},
+		{"--output", "json", "pirg", "alpha", "dns"},
+		{"--output", "table", "pirg", "alpha", "dns"},
+		{"pirg", "nosuch", "dns"},
+		{"cephfs", "lab", "create", "--owner", "carol"},
+		{"cephfs", "lab", "dns"},
+		{"cephs3", "bucket", "create", "--owner", "carol"},
+		{"cephs3", "bucket", "dns"},
+	}},
+	{"subgroup-delete", [][]string{
+		{"pirg", "alpha", "subgroup", "tmp", "create"},
+		{"pirg", "alpha", "subgroup", "tmp", "delete"},
+		{"pirg", "alpha", "subgroup", "lab", "delete"},
+		{"--output", "json", "pirg", "alpha", "subgroup", "lab", "delete"},
+		{"pirg", "alpha", "subgroup", "lab", "list-members"},
+		{"pirg", "alpha", "subgroup", "lab", "delete", "--force"},
+		{"pirg", "alpha", "subgroup", "nosuch", "delete"},
+		{"pirg", "alpha", "subgroup", "list"},
+	}},
+	{"cephs3-policy", [][]string{
+		{"cephs3", "bucket", "create", "--owner", "carol"},
+		{"cephs3", "bucket", "policy"},
+		{"cephs3", "bucket", "add-member", "alice", "bob"},
+		{"cephs3", "bucket", "add-admin", "bob"},
+		{"cephs3", "bucket", "policy", "--format", "rgw"},
+		{"cephs3", "nosuch", "policy"},
+	}},
+	{"owned", [][]string{
+		{"pirg", "delta", "create", "--pi", "carol"},
+		{"cephfs", "lab", "create", "--owner", "bob"},
+		{"pirg", "list", "--pi", "carol"},
+		{"--output", "json", "pirg", "list", "--pi", "carol"},
+		{"pirg", "list", "--pi", "bob"},
+		{"aduser", "carol", "owned"},
+		{"aduser", "bob", "owned"},
+		{"--output", "json", "aduser", "bob", "owned"},
+		{"aduser", "dave", "owned"},
+		{"pirg", "list", "--pi", "nosuch"},
+		{"--output", "json", "aduser", "nosuch", "owned"},
+	}},
+	{"subgroup-set-members", [][]string{
+		{"pirg", "alpha", "subgroup", "lab", "set-members", "--member", "alice", "--dry-run"},
+		{"pirg", "alpha", "subgroup", "lab", "list-members"},
+		{"pirg", "alpha", "subgroup", "lab", "set-members", "--member", "alice"},
+		{"pirg", "alpha", "subgroup", "lab", "list-members"},
+		{"pirg", "alpha", "subgroup", "lab", "set-members", "--member", "alice", "--member", "carol"},
+		{"pirg", "alpha", "subgroup", "lab", "list-members"},
+		{"pirg", "alpha", "add-member", "carol"},
+		{"--output", "json", "pirg", "alpha", "subgroup", "lab", "set-members", "--member", "bob", "--member", "carol"},
+		{"pirg", "alpha", "subgroup", "lab", "list-members"},
+		{"pirg", "alpha", "subgroup", "lab", "set-members", "--member", "carol", "--member", "bob"},
+		{"pirg", "alpha", "subgroup", "lab", "set-members"},
+		{"pirg", "alpha", "subgroup", "nosuch", "set-members", "--member", "bob"},
+	}},
+	{"raw-search", [][]string{
+		{"raw", "search", "--base", "OU=alpha,OU=PIRGS,OU=RACS,DC=ad,DC=uoregon,DC=edu", "--scope", "base", "--attrs", "ou,objectClass"},
+		{"raw", "search", "--base", "OU=alpha,OU=PIRGS,OU=RACS,DC=ad,DC=uoregon,DC=edu", "--scope", "one", "--filter", "(objectClass=group)", "--attrs", "cn,gidNumber"},
+		{"raw", "search", "--base", "OU=alpha,OU=PIRGS,OU=RACS,DC=ad,DC=uoregon,DC=edu", "--filter", "(cn=is.racs.pirg.alpha*)", "--attrs", "cn,managedBy"},
+		{"--output", "json", "raw", "search", "--base", "OU=People,DC=ad,DC=uoregon,DC=edu", "--filter", "(|(sAMAccountName=alice)(sAMAccountName=bob))", "--attrs", "sAMAccountName,mail"},
+		{"raw", "search", "--base", "OU=People,DC=ad,DC=uoregon,DC=edu", "--filter", "(sAMAccountName=nosuch)"},
+		{"raw", "search", "--base", "OU=nosuch,DC=ad,DC=uoregon,DC=edu"},
+		{"raw", "search", "--base", "OU=People,DC=ad,DC=uoregon,DC=edu", "--filter", "(sAMAccountName=alice"},
+		{"raw", "search", "--base", "OU=People,DC=ad,DC=uoregon,DC=edu", "--scope", "children"},
+	}},
+}
+
+// testEnv is a config and in-memory directory commands run against.
+type testEnv struct {
+	dir    *ldaptest.Directory
+	config string
+}
+
+// newTestEnv loads testdata/directory.ldif and writes the test config,
+// with extra appended to it, to a temporary directory that also holds
+// data_path. Commands run with Run use the directory until the test ends.
+func newTestEnv(t *testing.T, extra string) *testEnv {
+	t.Helper()
+	dir, err := ldaptest.Load(filepath.Join("testdata", "directory.ldif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := os.ReadFile(filepath.Join("testdata", "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp := t.TempDir()
+	config := filepath.Join(tmp, "config.yaml")
+	text := fmt.Sprintf("%sdata_path: %s\n%s", base, tmp, extra)
+	if err := os.WriteFile(config, []byte(text), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	previous := connectDirectory
+	connectDirectory = func(ctx context.Context) context.Context {
+		return ld.WithClient(ctx, dir)
+	}
+	t.Cleanup(func() { connectDirectory = previous })
+	return &testEnv{dir: dir, config: config}
+}
+
+// run runs one command line and returns its exit code, stdout, and stderr,
+// with log timestamps removed.
+func (e *testEnv) run(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := Run(append([]string{"--config", e.config}, args...), &stdout, &stderr)
+	return code, stdout.String(), logTime.ReplaceAllString(stderr.String(), "")
+}
+
+// mustRun runs a command line that is expected to succeed.
+func (e *testEnv) mustRun(t *testing.T, args ...string) string {
+	t.Helper()
+	code, stdout, stderr := e.run(args...)
+	if code != 0 {
+		t.Fatalf("%s exited %d, stdout:\n%s\nstderr:\n%s", strings.Join(args, " "), code, stdout, stderr)
+	}
+	return stdout
+}
+
+// transcript runs each command line in turn and records what it printed
+// and how it exited.
+func (e *testEnv) transcript(commands [][]string) string {
+	var b strings.Builder
+	for i, args := range commands {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		code, stdout, stderr := e.run(args...)
+		fmt.Fprintf(&b, "$ directory-manager %s\n", strings.Join(args, " "))
+		b.WriteString(stdout)
+		if stderr != "" {
+			b.WriteString("--- stderr\n")
+			b.WriteString(stderr)
+		}
+		fmt.Fprintf(&b, "--- exit %d\n", code)
+	}
+	return b.String()
+}
+
+// checkGolden compares got with testdata/<name>.golden, or rewrites the
+// file with -update.
+func checkGolden(t *testing.T, name string, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v; run go test -update to create it", err)
+	}
+	if got != string(want) {
+		t.Errorf("transcript differs from %s; run go test -update and review the diff\n--- got\n%s--- want\n%s", path, got, want)
+	}
+}
+
+func TestGolden(t *testing.T) {
+	for _, tc := range goldenCases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := newTestEnv(t, "")
+			checkGolden(t, tc.name, env.transcript(tc.commands))
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os/user"
+	"time"
+
+	"github.com/uoracs/directory-manager/internal/config"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/maintenance"
+)
+
+// maintenanceStatus is what maintenance status prints in json mode.
+type maintenanceStatus struct {
+	Active bool              `json:"active"`
+	Source string            `json:"source,omitempty"`
+	Lock   *maintenance.Lock `json:"lock,omitempty"`
+}
+
+// parseUntil parses a --until value: RFC 3339, or a local date and time
+// such as 2025-07-01T06:00.
+func parseUntil(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: use YYYY-MM-DDTHH:MM or RFC 3339", value)
+}
+
+// currentMaintenance returns the maintenance lock in force, and where it was
+// found: "local" for the file under data_path, "directory" for the
+// maintenance_dn marker. When both hold a lock, the one ending later wins.
+// It returns nil if there is none, including one that has expired.
+func currentMaintenance(ctx context.Context, cfg *config.Config) (*maintenance.Lock, string, error) {
+	local, err := maintenance.Read(maintenance.Path(cfg.DataPath))
+	if err != nil {
+		return nil, "", err
+	}
+	value, err := ld.GetMaintenanceMarker(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read maintenance marker: %w", err)
+	}
+	directory, err := maintenance.Decode(value)
+	if err != nil {
+		return nil, "", fmt.Errorf("maintenance marker on %s: %w", cfg.MaintenanceDN, err)
+	}
+
+	now := time.Now()
+	var lock *maintenance.Lock
+	var source string
+	if local.Active(now) {
+		lock, source = local, "local"
+	}
+	if directory.Active(now) && (lock == nil || directory.Until.After(lock.Until)) {
+		lock, source = directory, "directory"
+	}
+	return lock, source, nil
+}
+
+// checkMaintenance exits before any change when a maintenance window is in
+// force, unless --override-maintenance is given, which is logged. Read-only
+// commands and the maintenance commands themselves always run.
+func checkMaintenance(ctx context.Context, cfg *config.Config, command string) {
+	if !changesDirectory(command) || commandWords(command)[0] == "maintenance" {
+		return
+	}
+	lock, source, err := currentMaintenance(ctx, cfg)
+	if err != nil {
+		fail("Error checking maintenance lock", err)
+	}
+	if lock == nil {
+		return
+	}
+	if CLI.OverrideMaintenance {
+		slog.Warn("Overriding maintenance lock", "command", command, "until", lock.Until, "lockReason", lock.Reason, "source", source)
+		return
+	}
+	msg := fmt.Sprintf("The directory is in maintenance until %s: %s. Changes are blocked; pass --override-maintenance with --reason for an emergency change.",
+		lock.Until.Local().Format("2006-01-02 15:04 MST"), lock.Reason)
+	if jsonOutput() {
+		writeErrorEnvelope(msg, "maintenance")
+		exit(1)
+	}
+	fmt.Fprintln(stdout, msg)
+	exit(1)
+}
+
+// startMaintenance writes a maintenance lock until the given time, to the
+// file under data_path and, if maintenance_dn is set, to the directory.
+func startMaintenance(ctx context.Context, cfg *config.Config) {
+	if CLI.Reason == "" {
+		failUsage("Starting maintenance needs a reason: pass --reason, e.g. --reason \"schema upgrade\".")
+	}
+	until, err := parseUntil(CLI.Maintenance.Start.Until)
+	if err != nil {
+		failUsage(err.Error())
+	}
+	now := time.Now()
+	if !until.After(now) {
+		failUsage(fmt.Sprintf("--until %s is in the past.", CLI.Maintenance.Start.Until))
+	}
+	lock := &maintenance.Lock{Until: until.UTC(), Reason: CLI.Reason, Started: now.UTC()}
+	if u, err := user.Current(); err == nil {
+		lock.By = u.Username
+	}
+	if err := maintenance.Write(maintenance.Path(cfg.DataPath), lock); err != nil {
+		fail("Error writing maintenance lock", err)
+	}
+	value, err := lock.Encode()
+	if err != nil {
+		fail("Error encoding maintenance lock", err)
+	}
+	if err := ld.SetMaintenanceMarker(ctx, value); err != nil {
+		fail("Error writing maintenance marker", err)
+	}
+	slog.Info("Started maintenance", "until", lock.Until, "reason", lock.Reason)
+	if !jsonOutput() {
+		fmt.Fprintf(stdout, "Maintenance started until %s.\n", until.Local().Format("2006-01-02 15:04 MST"))
+	}
+}
+
+// endMaintenance removes the maintenance lock from the file and the
+// directory.
+func endMaintenance(ctx context.Context, cfg *config.Config) {
+	if err := maintenance.Remove(maintenance.Path(cfg.DataPath)); err != nil {
+		fail("Error removing maintenance lock", err)
+	}
+	if err := ld.SetMaintenanceMarker(ctx, ""); err != nil {
+		fail("Error clearing maintenance marker", err)
+	}
+	slog.Info("Ended maintenance")
+	if !jsonOutput() {
+		fmt.Fprintln(stdout, "Maintenance ended.")
+	}
+}
+
+// printMaintenance prints the maintenance window in force, if any.
+func printMaintenance(ctx context.Context, cfg *config.Config) {
+	lock, source, err := currentMaintenance(ctx, cfg)
+	if err != nil {
+		fail("Error checking maintenance lock", err)
+	}
+	if jsonOutput() {
+		printJSON(maintenanceStatus{Active: lock != nil, Source: source, Lock: lock})
+		return
+	}
+	if lock == nil {
+		fmt.Fprintln(stdout, "No maintenance in progress.")
+		return
+	}
+	fmt.Fprintf(stdout, "Maintenance until %s (%s lock), started by %s at %s: %s\n",
+		lock.Until.Local().Format("2006-01-02 15:04 MST"), source, lock.By,
+		lock.Started.Local().Format("2006-01-02 15:04 MST"), lock.Reason)
+}
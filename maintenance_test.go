@@ -0,0 +1,128 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/uoracs/directory-manager/internal/maintenance"
+)
+
+const maintenanceMarkerDN = "OU=RACS,DC=ad,DC=uoregon,DC=edu"
+
+// dataPath returns the env's data_path.
+func (e *testEnv) dataPath() string {
+	return filepath.Dir(e.config)
+}
+
+// checkBlocked checks that add-member is refused for maintenance and that
+// read-only commands still run.
+func (e *testEnv) checkBlocked(t *testing.T, reason string) {
+	t.Helper()
+	code, stdout, _ := e.run("pirg", "alpha", "add-member", "carol")
+	if code != 1 || !strings.Contains(stdout, "The directory is in maintenance until") || !strings.Contains(stdout, reason) {
+		t.Errorf("add-member during maintenance exited %d:\n%s", code, stdout)
+	}
+	if e.hasMember(alphaDN, carolDN) {
+		t.Error("add-member during maintenance changed the directory")
+	}
+	e.mustRun(t, "pirg", "alpha", "list-members")
+}
+
+func TestMaintenanceLocal(t *testing.T) {
+	env := newTestEnv(t, "")
+	until := time.Now().Add(time.Hour).Format("2006-01-02T15:04")
+	env.mustRun(t, "maintenance", "start", "--until", until, "--reason", "schema upgrade")
+	if l, err := maintenance.Read(maintenance.Path(env.dataPath())); err != nil || l == nil {
+		t.Fatalf("lock file = %v, %v after maintenance start", l, err)
+	}
+	env.checkBlocked(t, "schema upgrade")
+
+	code, stdout, _ := env.run("--output", "json", "pirg", "alpha", "add-member", "carol")
+	if code != 1 || !strings.Contains(stdout, `"code":"maintenance"`) {
+		t.Errorf("add-member -o json during maintenance exited %d:\n%s", code, stdout)
+	}
+	if got := env.mustRun(t, "maintenance", "status"); !strings.Contains(got, "(local lock)") || !strings.Contains(got, "schema upgrade") {
+		t.Errorf("maintenance status = %q", got)
+	}
+
+	code, stdout, _ = env.run("--override-maintenance", "pirg", "alpha", "add-member", "carol")
+	if code == 0 || env.hasMember(alphaDN, carolDN) {
+		t.Errorf("--override-maintenance without --reason exited %d:\n%s", code, stdout)
+	}
+	_, _, stderr := env.run("--override-maintenance", "--reason", "INC-1234", "pirg", "alpha", "add-member", "carol")
+	if !env.hasMember(alphaDN, carolDN) {
+		t.Error("--override-maintenance --reason didn't make the change")
+	}
+	if !strings.Contains(stderr, "Overriding maintenance lock") {
+		t.Errorf("override wasn't logged:\n%s", stderr)
+	}
+
+	env.mustRun(t, "maintenance", "end")
+	if got := env.mustRun(t, "maintenance", "status"); got != "No maintenance in progress.\n" {
+		t.Errorf("maintenance status after end = %q", got)
+	}
+	env.mustRun(t, "pirg", "alpha", "remove-member", "carol")
+}
+
+// TestMaintenanceDirectory checks that with maintenance_dn set the lock is
+// seen through the directory even without the local file, as it is on
+// another admin host.
+func TestMaintenanceDirectory(t *testing.T) {
+	env := newTestEnv(t, "maintenance_dn: "+maintenanceMarkerDN+"\n")
+	until := time.Now().Add(time.Hour).Format("2006-01-02T15:04")
+	env.mustRun(t, "maintenance", "start", "--until", until, "--reason", "schema upgrade")
+	marker := env.dir.Values(maintenanceMarkerDN, "info")
+	if len(marker) != 1 || !strings.Contains(marker[0], "schema upgrade") {
+		t.Fatalf("marker = %q after maintenance start", marker)
+	}
+
+	if err := maintenance.Remove(maintenance.Path(env.dataPath())); err != nil {
+		t.Fatal(err)
+	}
+	env.checkBlocked(t, "schema upgrade")
+	if got := env.mustRun(t, "maintenance", "status"); !strings.Contains(got, "(directory lock)") {
+		t.Errorf("maintenance status = %q, want the directory lock", got)
+	}
+
+	env.mustRun(t, "maintenance", "end")
+	if got := env.dir.Values(maintenanceMarkerDN, "info"); len(got) != 0 {
+		t.Errorf("marker = %q after maintenance end", got)
+	}
+	env.mustRun(t, "pirg", "alpha", "add-member", "carol")
+}
+
+// TestMaintenanceExpired checks that locks past their end time and the
+// clock skew allowance no longer block changes, wherever they are stored.
+func TestMaintenanceExpired(t *testing.T) {
+	expired := &maintenance.Lock{
+		Until:   time.Now().Add(-maintenance.ClockSkew - time.Minute).UTC(),
+		Reason:  "schema upgrade",
+		Started: time.Now().Add(-time.Hour).UTC(),
+	}
+	value, err := expired.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := newTestEnv(t, "maintenance_dn: "+maintenanceMarkerDN+"\n")
+	if err := maintenance.Write(maintenance.Path(env.dataPath()), expired); err != nil {
+		t.Fatal(err)
+	}
+	req := ldap.NewModifyRequest(maintenanceMarkerDN, nil)
+	req.Replace("info", []string{value})
+	if err := env.dir.Modify(req); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := env.mustRun(t, "maintenance", "status"); got != "No maintenance in progress.\n" {
+		t.Errorf("maintenance status = %q with expired locks", got)
+	}
+	env.mustRun(t, "pirg", "alpha", "add-member", "carol")
+
+	code, stdout, _ := env.run("maintenance", "start", "--until", "2020-01-01T00:00", "--reason", "schema upgrade")
+	if code == 0 || !strings.Contains(stdout, "is in the past") {
+		t.Errorf("maintenance start in the past exited %d:\n%s", code, stdout)
+	}
+}
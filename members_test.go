@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+func TestListMembersCount(t *testing.T) {
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"pirg", "alpha", "list-members", "--count"}, "2\n"},
+		{[]string{"--output", "json", "pirg", "alpha", "list-members", "--count"}, `{"count":2}` + "\n"},
+		{[]string{"pirg", "alpha", "list-members", "--summary"}, "2 members\nalice\nbob\n"},
+		{[]string{"--output", "json", "pirg", "alpha", "list-members"}, `{"count":2,"members":["alice","bob"]}` + "\n"},
+		{[]string{"pirg", "alpha", "subgroup", "lab", "list-members", "--count"}, "2\n"},
+		{[]string{"pirg", "gamma", "list-members", "--count"}, "3\n"},
+	}
+	env := newTestEnv(t, "")
+	for _, tc := range cases {
+		code, stdout, stderr := env.run(tc.args...)
+		if code != 0 {
+			t.Errorf("%s: exit %d\n%s%s", strings.Join(tc.args, " "), code, stdout, stderr)
+			continue
+		}
+		if stdout != tc.want {
+			t.Errorf("%s printed %q, want %q", strings.Join(tc.args, " "), stdout, tc.want)
+		}
+	}
+}
+
+// TestListMembersCountRanged checks that --count adds up every range of a
+// group with more members than AD returns at once.
+func TestListMembersCountRanged(t *testing.T) {
+	env := newTestEnv(t, "")
+	var members []string
+	for i := range 1600 {
+		members = append(members, fmt.Sprintf("CN=user%04d,OU=People,DC=ad,DC=uoregon,DC=edu", i))
+	}
+	big := ldap.NewAddRequest("CN=is.racs.pirg.alpha.big,OU=Groups,OU=alpha,OU=PIRGS,OU=RACS,DC=ad,DC=uoregon,DC=edu", nil)
+	big.Attribute("objectClass", []string{"group"})
+	big.Attribute("cn", []string{"is.racs.pirg.alpha.big"})
+	big.Attribute("member", members)
+	if err := env.dir.Add(big); err != nil {
+		t.Fatal(err)
+	}
+	env.dir.MaxValRange = 1500
+
+	code, stdout, stderr := env.run("pirg", "alpha", "subgroup", "big", "list-members", "--count")
+	if code != 0 || stdout != "1600\n" {
+		t.Errorf("exit %d, printed %q, want 1600\n%s", code, stdout, stderr)
+	}
+}
+
+// addMemberObject adds an entry named cn to OU=People with the given object
+// classes, for tests that add members by DN.
+func (e *testEnv) addMemberObject(t *testing.T, cn string, classes ...string) string {
+	t.Helper()
+	dn := "CN=" + cn + ",OU=People,DC=ad,DC=uoregon,DC=edu"
+	req := ldap.NewAddRequest(dn, nil)
+	category := "person"
+	if slices.Contains(classes, "computer") {
+		category = "computer"
+	}
+	req.Attribute("objectClass", classes)
+	req.Attribute("objectCategory", []string{category})
+	req.Attribute("cn", []string{cn})
+	req.Attribute("sAMAccountName", []string{cn})
+	req.Attribute("userAccountControl", []string{"512"})
+	if err := e.dir.Add(req); err != nil {
+		t.Fatal(err)
+	}
+	return dn
+}
+
+// TestMemberByDN checks add-member and remove-member --dn with a user, a
+// computer account, and DNs that don't resolve.
+func TestMemberByDN(t *testing.T) {
+	env := newTestEnv(t, "")
+	frankDN := env.addMemberObject(t, "frank", "top", "person", "organizationalPerson", "user")
+	svcDN := env.addMemberObject(t, "svc-backup", "top", "person", "organizationalPerson", "user", "computer")
+
+	env.mustRun(t, "pirg", "alpha", "add-member", "--dn", frankDN, "--dn", svcDN)
+	for _, dn := range []string{frankDN, svcDN} {
+		if !env.hasMember(alphaDN, dn) {
+			t.Errorf("%s not added to alpha", dn)
+		}
+	}
+	if !env.hasMember(talapasUsersDN, frankDN) {
+		t.Error("user added by DN not added to the top-level group")
+	}
+	if env.hasMember(talapasUsersDN, svcDN) {
+		t.Error("computer added by DN was added to the top-level group")
+	}
+
+	env.mustRun(t, "pirg", "alpha", "remove-member", "--dn", frankDN, "--dn", svcDN)
+	for _, dn := range []string{frankDN, svcDN} {
+		if env.hasMember(alphaDN, dn) {
+			t.Errorf("%s not removed from alpha", dn)
+		}
+	}
+	if env.hasMember(talapasUsersDN, frankDN) {
+		t.Error("user removed by DN left in the top-level group")
+	}
+
+	code, stdout, _ := env.run("pirg", "alpha", "add-member", "--dn", daveDN)
+	if code != 1 || !strings.Contains(stdout, "account dave is disabled") {
+		t.Errorf("add-member --dn of a disabled user exited %d:\n%s", code, stdout)
+	}
+	if env.hasMember(alphaDN, daveDN) {
+		t.Error("disabled user added by DN")
+	}
+
+	env.mustRun(t, "cephfs", "lab", "create", "--owner", "carol")
+	env.mustRun(t, "cephfs", "lab", "add-member", "--dn", svcDN)
+	if got := env.mustRun(t, "cephfs", "lab", "list-members"); got != "carol\nsvc-backup\n" {
+		t.Errorf("cephfs members = %q, want carol and svc-backup", got)
+	}
+	env.mustRun(t, "cephfs", "lab", "remove-member", "--dn", svcDN)
+	if env.hasMember(labDN, svcDN) {
+		t.Error("computer not removed from the cephfs group")
+	}
+}
+
+func TestMemberByDNRefused(t *testing.T) {
+	cases := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "nonexistent",
+			args: []string{"add-member", "--dn", "CN=nosuch,OU=People,DC=ad,DC=uoregon,DC=edu"},
+			want: "CN=nosuch,OU=People,DC=ad,DC=uoregon,DC=edu not found",
+		},
+		{
+			name: "malformed",
+			args: []string{"add-member", "--dn", "not a dn"},
+			want: "invalid DN not a dn",
+		},
+		{
+			name: "mixed with usernames",
+			args: []string{"add-member", "carol", "--dn", carolDN},
+			want: "Usernames cannot be combined with --dn.",
+		},
+		{
+			name: "remove mixed with usernames",
+			args: []string{"remove-member", "bob", "--dn", carolDN},
+			want: "Usernames cannot be combined with --dn.",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := newTestEnv(t, "")
+			code, stdout, stderr := env.run(append([]string{"pirg", "alpha"}, tc.args...)...)
+			if code != 1 {
+				t.Fatalf("exit %d, want 1, stdout:\n%s\nstderr:\n%s", code, stdout, stderr)
+			}
+			if !strings.Contains(stdout, tc.want) {
+				t.Errorf("stdout doesn't contain %q:\n%s", tc.want, stdout)
+			}
+			if got := env.mustRun(t, "pirg", "alpha", "list-members"); got != "alice\nbob\n" {
+				t.Errorf("members after a refused %s = %q", tc.args[0], got)
+			}
+		})
+	}
+}
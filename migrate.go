@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/managedgroup"
+	"github.com/uoracs/directory-manager/internal/migrate"
+)
+
+// printPrefixPlan prints what a prefix migration changes, and what it
+// skips or refuses to do.
+func printPrefixPlan(plan *migrate.PrefixPlan) {
+	pending := plan.Pending()
+	fmt.Fprintf(stdout, "Renaming %s groups from %s* to %s*: %d to rename, %d already renamed\n",
+		plan.Family, plan.From, plan.To, len(pending), len(plan.Renames)-len(pending))
+	for _, r := range pending {
+		fmt.Fprintf(stdout, "  %s -> %s (%s)\n", r.OldName, r.NewName, r.Kind)
+	}
+	if len(plan.Descriptions) > 0 {
+		fmt.Fprintf(stdout, "Updating %d descriptions:\n", len(plan.Descriptions))
+		for _, d := range plan.Descriptions {
+			fmt.Fprintf(stdout, "  %s: %q -> %q\n", d.DN, d.Old, d.New)
+		}
+	}
+	if len(plan.Skipped) > 0 {
+		fmt.Fprintf(stdout, "Skipping %d groups that don't follow the %s conventions:\n", len(plan.Skipped), plan.Family)
+		for _, c := range plan.Skipped {
+			fmt.Fprintf(stdout, "  %s: %s\n", c.DN, c.Reason)
+		}
+	}
+	if len(plan.Conflicts) > 0 {
+		fmt.Fprintf(stdout, "Conflicts, resolve these before migrating:\n")
+		for _, c := range plan.Conflicts {
+			fmt.Fprintf(stdout, "  %s\n", c)
+		}
+	}
+}
+
+// runMigratePrefix plans a prefix migration and, unless it is a dry run,
+// applies it and writes the name mapping.
+func runMigratePrefix(ctx context.Context) {
+	opts := CLI.Migrate.Prefix
+	if !opts.DryRun && opts.MappingFile == "" {
+		failUsage("--mapping-file is required unless --dry-run is given.")
+	}
+	f, _ := managedgroup.ByName(opts.Family)
+	plan, err := migrate.PlanPrefix(ctx, f, opts.From, opts.To)
+	if err != nil {
+		fail("Error planning prefix migration", err)
+	}
+	if jsonOutput() && opts.DryRun {
+		printJSON(plan)
+		if len(plan.Conflicts) > 0 {
+			exit(1)
+		}
+		return
+	}
+	if !jsonOutput() {
+		printPrefixPlan(plan)
+	}
+	if len(plan.Conflicts) > 0 {
+		fail("Error planning prefix migration", fmt.Errorf("%d groups would take names other groups have: %w", len(plan.Conflicts), ld.ErrAlreadyExists))
+	}
+	if opts.DryRun {
+		return
+	}
+	if len(plan.Pending()) > 0 || len(plan.Descriptions) > 0 {
+		if !opts.Yes && !confirm(ctx, "Migrate these groups?") {
+			fmt.Fprintln(stdout, "Aborted.")
+			return
+		}
+	}
+	// Write the mapping even if the migration stops partway, so the groups
+	// already renamed are recorded
+	applyErr := migrate.ApplyPrefix(ctx, plan)
+	out, err := os.Create(opts.MappingFile)
+	if err != nil {
+		fail("Error writing mapping file", err)
+	}
+	err = migrate.WriteMapping(out, plan)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fail("Error writing mapping file", err)
+	}
+	if applyErr != nil {
+		fail("Error migrating prefix, run it again to finish", applyErr)
+	}
+	if jsonOutput() {
+		printJSON(plan)
+		return
+	}
+	fmt.Fprintf(stdout, "Migrated %d groups, mapping written to %s\n", len(plan.Renames), opts.MappingFile)
+}
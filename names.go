@@ -0,0 +1,37 @@
+package main
+
+import (
+	"github.com/uoracs/directory-manager/internal/managedgroup"
+)
+
+// normalizeNames rewrites group names given as full AD names, such as
+// "is.racs.pirg.smithlab", into the short names every command expects. It
+// exits when a name belongs to another family or group.
+func normalizeNames() {
+	normalize := func(f managedgroup.Family, name *string) {
+		short, err := f.NormalizeName(*name)
+		if err != nil {
+			failUsage(err.Error())
+		}
+		*name = short
+	}
+	normalizeSubgroup := func(f managedgroup.Family, groupName string, name *string) {
+		short, err := f.NormalizeSubgroupName(groupName, *name)
+		if err != nil {
+			failUsage(err.Error())
+		}
+		*name = short
+	}
+
+	normalize(managedgroup.Pirg, &CLI.Pirg.Name.Name)
+	normalize(managedgroup.Pirg, &CLI.Pirg.Name.RemoveMember.MembersOf)
+	normalizeSubgroup(managedgroup.Pirg, CLI.Pirg.Name.Name, &CLI.Pirg.Name.RemoveMember.FromSubgroup)
+	normalizeSubgroup(managedgroup.Pirg, CLI.Pirg.Name.Name, &CLI.Pirg.Name.Subgroup.Name.Name)
+	normalize(managedgroup.Cephfs, &CLI.Cephfs.Name.Name)
+	normalize(managedgroup.Cephfs, &CLI.Cephfs.Name.Rename.NewName)
+	normalize(managedgroup.Cephs3, &CLI.Cephs3.Name.Name)
+	normalize(managedgroup.Cephs3, &CLI.Cephs3.Name.Rename.NewName)
+	normalize(managedgroup.Software, &CLI.Software.Name.Name)
+	// --pirg matches the short name in every family
+	CLI.TransferOwnership.Pirg = managedgroup.StripAnyPrefix(CLI.TransferOwnership.Pirg)
+}
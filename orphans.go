@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+)
+
+// printOrphans prints orphan OUs by name, each followed by the groups still
+// in it, or empty if there are none.
+func printOrphans(orphans []ld.OrphanOU, empty string) {
+	if jsonOutput() {
+		for i := range orphans {
+			if orphans[i].Groups == nil {
+				orphans[i].Groups = []string{}
+			}
+		}
+		if orphans == nil {
+			orphans = []ld.OrphanOU{}
+		}
+		printJSON(orphans)
+		return
+	}
+	if len(orphans) == 0 {
+		fmt.Fprintln(stdout, empty)
+		return
+	}
+	if tableOutput() {
+		rows := make([][]string, 0, len(orphans))
+		for _, o := range orphans {
+			rows = append(rows, []string{o.Name, o.DN, strconv.Itoa(len(o.Groups))})
+		}
+		printTable([]string{"name", "dn", "groups"}, rows)
+		return
+	}
+	for _, o := range orphans {
+		fmt.Fprintln(stdout, o.Name)
+		for _, group := range o.Groups {
+			fmt.Fprintf(stdout, "  %s\n", group)
+		}
+	}
+}
+
+// cleanOrphans deletes the orphan OUs that hold no groups, after listing
+// them and asking for confirmation unless yes is set. Orphans that still
+// hold groups are listed with them and left alone, and the command then
+// exits 1, since they need someone to look at the groups first.
+func cleanOrphans(ctx context.Context, orphans []ld.OrphanOU, deleteOrphan func(context.Context, string) error, yes bool) {
+	var empty, kept []ld.OrphanOU
+	for _, o := range orphans {
+		if len(o.Groups) == 0 {
+			empty = append(empty, o)
+		} else {
+			kept = append(kept, o)
+		}
+	}
+	for _, o := range kept {
+		fmt.Fprintf(stderr, "Not deleting %s, it still holds groups:\n", o.DN)
+		for _, group := range o.Groups {
+			fmt.Fprintf(stderr, "  %s\n", group)
+		}
+	}
+	if len(empty) == 0 {
+		fmt.Fprintln(stdout, "No empty orphan OUs to delete.")
+	} else {
+		fmt.Fprintf(stdout, "Orphan OUs to delete (%d):\n", len(empty))
+		for _, o := range empty {
+			fmt.Fprintln(stdout, o.DN)
+		}
+		if !yes && !confirm(ctx, "Delete these OUs?") {
+			fmt.Fprintln(stdout, "Aborted.")
+			return
+		}
+		for _, o := range empty {
+			if err := deleteOrphan(ctx, o.Name); err != nil {
+				fail(fmt.Sprintf("Error deleting orphan OU %s", o.DN), err)
+			}
+		}
+	}
+	if len(kept) > 0 {
+		exit(1)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// addOrphans seeds OUs left behind by failed creates: ghost holds only its
+// Groups OU, and half still holds a subgroup of the missing PIRG. A cephfs
+// OU named ghost is left with nothing in it at all.
+func (e *testEnv) addOrphans(t *testing.T) {
+	t.Helper()
+	add := func(dn string, class string, cn string) {
+		req := ldap.NewAddRequest(dn, nil)
+		req.Attribute("objectClass", []string{"top", class})
+		if cn != "" {
+			req.Attribute("cn", []string{cn})
+			req.Attribute("sAMAccountName", []string{cn})
+		}
+		if err := e.dir.Add(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add("OU=ghost,OU=PIRGS,OU=RACS,DC=ad,DC=uoregon,DC=edu", "organizationalUnit", "")
+	add("OU=Groups,OU=ghost,OU=PIRGS,OU=RACS,DC=ad,DC=uoregon,DC=edu", "organizationalUnit", "")
+	add("OU=half,OU=PIRGS,OU=RACS,DC=ad,DC=uoregon,DC=edu", "organizationalUnit", "")
+	add("OU=Groups,OU=half,OU=PIRGS,OU=RACS,DC=ad,DC=uoregon,DC=edu", "organizationalUnit", "")
+	add("CN=is.racs.pirg.half.lab,OU=Groups,OU=half,OU=PIRGS,OU=RACS,DC=ad,DC=uoregon,DC=edu", "group", "is.racs.pirg.half.lab")
+	add("OU=ghost,OU=CEPHFS,OU=RACS,DC=ad,DC=uoregon,DC=edu", "organizationalUnit", "")
+}
+
+func TestOrphans(t *testing.T) {
+	env := newTestEnv(t, "")
+	env.addOrphans(t)
+	checkGolden(t, "orphans", env.transcript([][]string{
+		{"pirg", "orphans"},
+		{"--output", "json", "pirg", "orphans"},
+		{"pirg", "list"},
+		{"pirg", "half", "create", "--pi", "carol"},
+		{"pirg", "orphans", "clean"},
+		{"pirg", "orphans", "clean", "--yes"},
+		{"pirg", "orphans"},
+		{"cephfs", "orphans"},
+		{"cephfs", "orphans", "clean", "--yes"},
+		{"cephfs", "orphans"},
+		{"cephs3", "orphans"},
+	}))
+}
+
+// TestCreateAdoptsOrphan checks that create reuses an empty orphan OU.
+func TestCreateAdoptsOrphan(t *testing.T) {
+	env := newTestEnv(t, "")
+	env.addOrphans(t)
+	env.mustRun(t, "pirg", "ghost", "create", "--pi", "carol")
+	env.mustRun(t, "cephfs", "ghost", "create", "--owner", "carol")
+	checkGolden(t, "orphans-adopted", env.transcript([][]string{
+		{"pirg", "ghost", "dns"},
+		{"pirg", "ghost", "list-members"},
+		{"cephfs", "ghost", "dns"},
+		{"pirg", "orphans"},
+		{"cephfs", "orphans"},
+	}))
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/managedgroup"
+)
+
+// stdout and stderr are where commands write, os.Stdout and os.Stderr
+// unless Run was given others.
+var (
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
+)
+
+// exitCode is what exit panics with, for Run to recover as its result.
+type exitCode int
+
+// exit ends the command with the given exit code, unwinding to Run so
+// deferred cleanup such as closing the connection still happens.
+func exit(code int) {
+	panic(exitCode(code))
+}
+
+// errorEnvelope is written to stdout in place of the usual error text
+// when --output json is set, so callers parsing stdout always get JSON.
+type errorEnvelope struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+func jsonOutput() bool {
+	return CLI.Output == "json"
+}
+
+func tableOutput() bool {
+	return CLI.Output == "table"
+}
+
+// printTable writes rows as aligned columns under an upper-cased header.
+func printTable(headers []string, rows [][]string) {
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.ToUpper(strings.Join(headers, "\t")))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+}
+
+// errorCode maps an error to the code reported in the JSON envelope.
+func errorCode(err error) string {
+	switch {
+	case errors.Is(err, ld.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, ld.ErrAlreadyExists):
+		return "already_exists"
+	case errors.Is(err, ld.ErrPolicy):
+		return "policy_violation"
+	case errors.Is(err, ld.ErrPermissionDenied):
+		return "permission_denied"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "cancelled"
+	case errors.Is(err, ld.ErrAccountInactive):
+		return "account_inactive"
+	case errors.Is(err, ld.ErrNotOU):
+		return "not_an_ou"
+	case errors.Is(err, ld.ErrNotGroup):
+		return "not_a_group"
+	case errors.Is(err, ld.ErrNotEmpty):
+		return "not_empty"
+	case errors.Is(err, ld.ErrFrozen):
+		return "frozen"
+	default:
+		return "error"
+	}
+}
+
+func writeErrorEnvelope(msg, code string) {
+	out, err := marshalJSON(errorEnvelope{Error: msg, Code: code})
+	if err != nil {
+		// Marshalling two strings cannot fail, but never fall back to
+		// writing non-JSON to stdout.
+		out = []byte(`{"error":"internal error","code":"error"}`)
+	}
+	fmt.Fprintln(stdout, string(out))
+}
+
+// fail reports err with the given context and exits non-zero.
+func fail(msg string, err error) {
+	if jsonOutput() {
+		writeErrorEnvelope(fmt.Sprintf("%s: %v", msg, err), errorCode(err))
+		exit(1)
+	}
+	fmt.Fprintf(stdout, "%s: %v\n", msg, err)
+	exit(1)
+}
+
+// failUsage reports a problem with the given arguments and exits non-zero.
+func failUsage(msg string) {
+	if jsonOutput() {
+		writeErrorEnvelope(msg, "invalid_argument")
+		exit(1)
+	}
+	fmt.Fprintln(stdout, msg)
+	exit(1)
+}
+
+// failDisabled reports a command whose subsystem is turned off in
+// configuration and exits non-zero.
+func failDisabled(subsystem string) {
+	msg := fmt.Sprintf("the %s subsystem is disabled in configuration", subsystem)
+	if jsonOutput() {
+		writeErrorEnvelope(msg, "disabled")
+		exit(1)
+	}
+	fmt.Fprintln(stdout, msg)
+	exit(1)
+}
+
+// notFound reports a missing object. In text mode this is informational
+// and the caller returns normally; in json mode it is an error.
+func notFound(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if jsonOutput() {
+		writeErrorEnvelope(msg, "not_found")
+		exit(1)
+	}
+	fmt.Fprintln(stdout, msg)
+}
+
+// alreadyExists reports an object that is already present. Like notFound,
+// it only exits in json mode.
+func alreadyExists(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if jsonOutput() {
+		writeErrorEnvelope(msg, "already_exists")
+		exit(1)
+	}
+	fmt.Fprintln(stdout, msg)
+}
+
+// compactJSON reports whether JSON is printed on one line: as
+// --json-compact or --no-json-compact says, or else when stdout isn't a
+// terminal, so scripts get one line and people get indented output.
+func compactJSON() bool {
+	if CLI.JSONCompact != nil {
+		return *CLI.JSONCompact
+	}
+	f, ok := stdout.(*os.File)
+	if !ok {
+		return true
+	}
+	info, err := f.Stat()
+	return err != nil || info.Mode()&os.ModeCharDevice == 0
+}
+
+// marshalJSON encodes v, indented unless compactJSON.
+func marshalJSON(v any) ([]byte, error) {
+	if compactJSON() {
+		return json.Marshal(v)
+	}
+	return json.MarshalIndent(v, "", "  ")
+}
+
+// printJSON writes v to stdout as JSON.
+func printJSON(v any) {
+	out, err := marshalJSON(v)
+	if err != nil {
+		fail("Error encoding output", err)
+	}
+	fmt.Fprintln(stdout, string(out))
+}
+
+// parseSince parses a --changed-since value. A bare date is taken as
+// midnight UTC, matching how AD stores whenChanged.
+func parseSince(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: use YYYY-MM-DD or RFC 3339", value)
+	}
+	return t.UTC(), nil
+}
+
+// memberList is the output of list-members in json mode.
+type memberList struct {
+	Count   int      `json:"count"`
+	Members []string `json:"members"`
+}
+
+// printMembers prints the usernames of a group's members one per line,
+// after a "42 members" line with --summary, or in json mode as a
+// memberList. empty, if not "", is printed instead when there are none.
+func printMembers(usernames []string, summary bool, empty string) {
+	if jsonOutput() {
+		if usernames == nil {
+			usernames = []string{}
+		}
+		printJSON(memberList{Count: len(usernames), Members: usernames})
+		return
+	}
+	if summary {
+		fmt.Fprintf(stdout, "%d members\n", len(usernames))
+	}
+	if len(usernames) == 0 && empty != "" {
+		fmt.Fprintln(stdout, empty)
+		return
+	}
+	for _, username := range usernames {
+		fmt.Fprintln(stdout, username)
+	}
+}
+
+// printMemberCount prints the number of members of a group, or in json mode
+// an object with just the count.
+func printMemberCount(count int) {
+	if jsonOutput() {
+		printJSON(map[string]int{"count": count})
+		return
+	}
+	fmt.Fprintln(stdout, count)
+}
+
+// printMemberFields prints the selected fields of each member, as aligned
+// columns (with a header under --output table) or, with --output json, as a
+// JSON array of objects keyed by field.
+// "dn" and "username" are built in; any other field is read as a user
+// attribute and printed empty if the user doesn't have it.
+func printMemberFields(ctx context.Context, memberDNs []string, fields []string) {
+	var attributes []string
+	for _, field := range fields {
+		switch strings.ToLower(field) {
+		case "dn":
+		case "username":
+			attributes = append(attributes, "sAMAccountName")
+		default:
+			attributes = append(attributes, field)
+		}
+	}
+	users, err := ld.GetUserAttributes(ctx, memberDNs, attributes)
+	if err != nil {
+		fail("Error getting member attributes", err)
+	}
+
+	rows := make([]map[string]string, 0, len(memberDNs))
+	for _, dn := range memberDNs {
+		values := users[strings.ToLower(dn)]
+		row := make(map[string]string, len(fields))
+		for _, field := range fields {
+			switch strings.ToLower(field) {
+			case "dn":
+				row[field] = dn
+			case "username":
+				row[field] = values["samaccountname"]
+			default:
+				row[field] = values[strings.ToLower(field)]
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	if jsonOutput() {
+		printJSON(rows)
+		return
+	}
+	table := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		cols := make([]string, len(fields))
+		for i, field := range fields {
+			cols[i] = row[field]
+		}
+		table = append(table, cols)
+	}
+	if tableOutput() {
+		printTable(fields, table)
+		return
+	}
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	for _, cols := range table {
+		fmt.Fprintln(w, strings.Join(cols, "\t"))
+	}
+	w.Flush()
+}
+
+// printAdminRoles prints each admin with their role, marking the PI or
+// owner, as a table with --output table, or as a JSON array with --output
+// json.
+func printAdminRoles(roles []managedgroup.AdminRole) {
+	if jsonOutput() {
+		printJSON(roles)
+		return
+	}
+	if tableOutput() {
+		rows := make([][]string, 0, len(roles))
+		for _, r := range roles {
+			rows = append(rows, []string{r.Username, r.Role})
+		}
+		printTable([]string{"username", "role"}, rows)
+		return
+	}
+	for _, r := range roles {
+		if r.Role == "admin" {
+			fmt.Fprintln(stdout, r.Username)
+			continue
+		}
+		fmt.Fprintf(stdout, "%s (%s)\n", r.Username, r.Role)
+	}
+}
+
+// printMemberUIDs prints each member as username:uid, as a table with
+// --output table, or as a JSON array with --output json.
+func printMemberUIDs(ctx context.Context, memberDNs []string) {
+	users, err := ld.ResolveUsers(ctx, memberDNs)
+	if err != nil {
+		fail("Error resolving member UIDs", err)
+	}
+	if jsonOutput() {
+		printJSON(users)
+		return
+	}
+	if tableOutput() {
+		rows := make([][]string, 0, len(users))
+		for _, u := range users {
+			rows = append(rows, []string{u.Username, u.UID})
+		}
+		printTable([]string{"username", "uid"}, rows)
+		return
+	}
+	for _, u := range users {
+		fmt.Fprintf(stdout, "%s:%s\n", u.Username, u.UID)
+	}
+}
+
+// printClassifications prints the groups found by a list --all, or only the
+// nonconforming ones when onlyNonconforming is set. Nonconforming groups are
+// printed by DN, since their names alone may not say where they are.
+func printClassifications(groups []managedgroup.Classification, onlyNonconforming bool) {
+	if onlyNonconforming {
+		var filtered []managedgroup.Classification
+		for _, g := range groups {
+			if g.Kind == managedgroup.KindNonconforming {
+				filtered = append(filtered, g)
+			}
+		}
+		groups = filtered
+	}
+	if jsonOutput() {
+		if groups == nil {
+			groups = []managedgroup.Classification{}
+		}
+		printJSON(groups)
+		return
+	}
+	if tableOutput() {
+		rows := make([][]string, 0, len(groups))
+		for _, g := range groups {
+			rows = append(rows, []string{g.CN, string(g.Kind), g.Reason})
+		}
+		printTable([]string{"cn", "kind", "reason"}, rows)
+		return
+	}
+	if len(groups) == 0 {
+		fmt.Fprintln(stdout, "No groups found.")
+		return
+	}
+	for _, g := range groups {
+		switch g.Kind {
+		case managedgroup.KindGroup:
+			fmt.Fprintln(stdout, g.CN)
+		case managedgroup.KindNonconforming:
+			fmt.Fprintf(stdout, "%s (nonconforming: %s)\n", g.DN, g.Reason)
+		default:
+			fmt.Fprintf(stdout, "%s (%s)\n", g.CN, g.Kind)
+		}
+	}
+}
+
+// reportInactive fails listing the accounts an add command skipped because
+// they are disabled or expired, once the rest of the batch has been added.
+func reportInactive(role string, skipped []error) {
+	if len(skipped) == 0 {
+		return
+	}
+	if !jsonOutput() {
+		// In json mode the error envelope is the only output
+		confirmMemberCount(len(skipped))
+	}
+	fail(fmt.Sprintf("Skipped %d inactive %s account(s)", len(skipped), role), errors.Join(skipped...))
+}
+
+// printComponents prints the groups making up a managed group as
+// "label: DN" lines, a table, or a JSON array. It exits 1 if any of them
+// is missing, after printing the rest.
+func printComponents(components []managedgroup.Component) {
+	var missing []string
+	for _, c := range components {
+		if c.DN == "" {
+			missing = append(missing, c.CN)
+		}
+	}
+	if jsonOutput() {
+		printJSON(components)
+	} else if tableOutput() {
+		rows := make([][]string, 0, len(components))
+		for _, c := range components {
+			rows = append(rows, []string{c.Label, c.DN})
+		}
+		printTable([]string{"component", "dn"}, rows)
+	} else {
+		for _, c := range components {
+			dn := c.DN
+			if dn == "" {
+				dn = "(missing)"
+			}
+			fmt.Fprintf(stdout, "%s: %s\n", c.Label, dn)
+		}
+	}
+	if len(missing) > 0 {
+		fmt.Fprintf(stderr, "Missing groups: %s\n", strings.Join(missing, ", "))
+		exit(1)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/uoracs/directory-manager/internal/config"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/managedgroup"
+)
+
+// ownedGroups returns, for each enabled family with a role group, the short
+// names of the groups whose PI or owner group username is in. It reads the
+// user's memberOf once rather than checking every group's role group.
+func ownedGroups(ctx context.Context, cfg *config.Config, username string) (map[string][]string, error) {
+	userDN, err := ld.GetUserDN(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	groupDNs, err := ld.GetGroupsForUser(ctx, userDN)
+	if err != nil {
+		return nil, err
+	}
+	owned := make(map[string][]string)
+	for _, f := range managedgroup.Families {
+		if f.RoleSuffix == "" || cfg.DisabledSubsystem(f.Name) != "" {
+			continue
+		}
+		names := []string{}
+		for _, groupDN := range groupDNs {
+			c := f.Classify(cfg.BaseDN(f.Name), groupDN)
+			if c.Kind == managedgroup.KindRole {
+				names = append(names, c.Name)
+			}
+		}
+		slices.Sort(names)
+		owned[f.Name] = names
+	}
+	return owned, nil
+}
+
+// printOwnedPirgs prints the PIRGs username is the PI of.
+func printOwnedPirgs(ctx context.Context, cfg *config.Config, username string) {
+	owned, err := ownedGroups(ctx, cfg, username)
+	if err != nil {
+		// An unknown username is an error here, not an empty list
+		fail(fmt.Sprintf("Error listing PIRGs of %s", username), err)
+	}
+	pirgs := owned["pirg"]
+	if jsonOutput() {
+		printJSON(pirgs)
+		return
+	}
+	if len(pirgs) == 0 {
+		fmt.Fprintf(stdout, "%s is the PI of no PIRGs.\n", username)
+		return
+	}
+	for _, name := range pirgs {
+		fmt.Fprintln(stdout, name)
+	}
+}
+
+// printOwned prints the groups username is the PI or owner of, by family.
+func printOwned(ctx context.Context, cfg *config.Config, username string) {
+	owned, err := ownedGroups(ctx, cfg, username)
+	if err != nil {
+		fail(fmt.Sprintf("Error listing groups of %s", username), err)
+	}
+	if jsonOutput() {
+		printJSON(owned)
+		return
+	}
+	var families []string
+	for _, f := range managedgroup.Families {
+		if len(owned[f.Name]) > 0 {
+			families = append(families, f.Name)
+		}
+	}
+	if tableOutput() {
+		var rows [][]string
+		for _, family := range families {
+			for _, name := range owned[family] {
+				rows = append(rows, []string{family, name})
+			}
+		}
+		printTable([]string{"family", "name"}, rows)
+		return
+	}
+	if len(families) == 0 {
+		fmt.Fprintf(stdout, "%s owns no groups.\n", username)
+		return
+	}
+	for _, family := range families {
+		fmt.Fprintf(stdout, "%s: %s\n", family, strings.Join(owned[family], ", "))
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+)
+
+// memberPaging is the --limit, --offset, and --sort of list-members.
+type memberPaging struct {
+	Limit  int
+	Offset int
+	Sort   string
+}
+
+// active reports whether any of the paging flags were given.
+func (p memberPaging) active() bool {
+	return p.Limit != 0 || p.Offset != 0 || p.Sort != ""
+}
+
+// memberPage is a memberList cut down by --limit and --offset, with what a
+// caller needs to build pagination controls. Limit is 0 when there is none.
+type memberPage struct {
+	memberList
+	TotalCount int `json:"total_count"`
+	Limit      int `json:"limit"`
+	Offset     int `json:"offset"`
+}
+
+// pagedMember is a member as list-members prints it: the username is the cn
+// of the member's DN.
+type pagedMember struct {
+	dn       string
+	username string
+}
+
+// pageMembers sorts every member of a group, given their DNs, and returns
+// the page p asks for along with the total. Sorting is by lowercased
+// username, or DN with --sort dn, then by the DN as given, compared byte by
+// byte so pages don't depend on the locale, the order the directory
+// returned the members in, or shuffle between calls. A negative limit or
+// offset, or an offset past the last member, is a usage error rather than
+// an empty page.
+func pageMembers(dns []string, p memberPaging) ([]pagedMember, int) {
+	if p.Limit < 0 || p.Offset < 0 {
+		failUsage("--limit and --offset must not be negative.")
+	}
+	members := make([]pagedMember, 0, len(dns))
+	for _, dn := range dns {
+		username, err := ld.ConvertDNToObjectName(dn)
+		if err != nil {
+			fail("Error converting DN to username", err)
+		}
+		members = append(members, pagedMember{dn: dn, username: username})
+	}
+	key := func(m pagedMember) string { return strings.ToLower(m.username) }
+	if p.Sort == "dn" {
+		key = func(m pagedMember) string { return strings.ToLower(m.dn) }
+	}
+	slices.SortFunc(members, func(a, b pagedMember) int {
+		return cmp.Or(strings.Compare(key(a), key(b)), strings.Compare(a.dn, b.dn))
+	})
+
+	total := len(members)
+	if p.Offset > 0 && p.Offset >= total {
+		failUsage(fmt.Sprintf("--offset %d is past the last of the %d members.", p.Offset, total))
+	}
+	members = members[p.Offset:]
+	if p.Limit > 0 && p.Limit < len(members) {
+		members = members[:p.Limit]
+	}
+	return members, total
+}
+
+// printMemberPage prints one page of a group's members as usernames, or
+// DNs with dnOnly, after a "42 members" line for the whole group with
+// --summary. In json mode it prints a memberPage.
+func printMemberPage(dns []string, p memberPaging, dnOnly bool, summary bool) {
+	page, total := pageMembers(dns, p)
+	values := make([]string, 0, len(page))
+	for _, m := range page {
+		if dnOnly {
+			values = append(values, m.dn)
+		} else {
+			values = append(values, m.username)
+		}
+	}
+	if jsonOutput() {
+		printJSON(memberPage{
+			memberList: memberList{Count: len(values), Members: values},
+			TotalCount: total,
+			Limit:      p.Limit,
+			Offset:     p.Offset,
+		})
+		return
+	}
+	if summary {
+		fmt.Fprintf(stdout, "%d members\n", total)
+	}
+	for _, value := range values {
+		fmt.Fprintln(stdout, value)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+func pagedUsernames(members []pagedMember) []string {
+	var names []string
+	for _, m := range members {
+		names = append(names, m.username)
+	}
+	return names
+}
+
+func pagedDNs(members []pagedMember) []string {
+	var dns []string
+	for _, m := range members {
+		dns = append(dns, m.dn)
+	}
+	return dns
+}
+
+func TestPageMembers(t *testing.T) {
+	dns := []string{
+		"CN=carol,OU=People,DC=test",
+		"CN=Alice,OU=Staff,DC=test",
+		"CN=bob,OU=Guests,DC=test",
+		"CN=dave,OU=People,DC=test",
+	}
+	cases := []struct {
+		name string
+		p    memberPaging
+		want []string
+	}{
+		{"all", memberPaging{}, []string{"Alice", "bob", "carol", "dave"}},
+		{"limit", memberPaging{Limit: 2}, []string{"Alice", "bob"}},
+		{"offset", memberPaging{Offset: 3}, []string{"dave"}},
+		{"limit and offset", memberPaging{Limit: 2, Offset: 1}, []string{"bob", "carol"}},
+		{"limit past the end", memberPaging{Limit: 10, Offset: 2}, []string{"carol", "dave"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			page, total := pageMembers(dns, tc.p)
+			if total != len(dns) {
+				t.Errorf("total = %d, want %d", total, len(dns))
+			}
+			if got := pagedUsernames(page); !slices.Equal(got, tc.want) {
+				t.Errorf("page = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestPageMembersTies checks that members sharing a sort key are ordered
+// by DN, so every order the directory returns them in gives the same pages.
+func TestPageMembersTies(t *testing.T) {
+	dns := []string{
+		"CN=alice,OU=Staff,DC=test",
+		"CN=ALICE,OU=People,DC=test",
+		"CN=alice,OU=Guests,DC=test",
+		"CN=bob,OU=People,DC=test",
+	}
+	want := []string{
+		"CN=ALICE,OU=People,DC=test",
+		"CN=alice,OU=Guests,DC=test",
+		"CN=alice,OU=Staff,DC=test",
+		"CN=bob,OU=People,DC=test",
+	}
+	reversed := slices.Clone(dns)
+	slices.Reverse(reversed)
+	for _, order := range [][]string{dns, reversed} {
+		var got []string
+		for offset := range len(order) {
+			page, _ := pageMembers(order, memberPaging{Limit: 1, Offset: offset})
+			got = append(got, pagedDNs(page)...)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("pages of %v = %v, want %v", order, got, want)
+		}
+	}
+
+	// Under --sort dn, DNs differing only in case tie on the key too
+	caseOnly := []string{"CN=bob,OU=People,DC=test", "CN=Bob,OU=People,DC=test"}
+	for _, order := range [][]string{caseOnly, {caseOnly[1], caseOnly[0]}} {
+		page, _ := pageMembers(order, memberPaging{Sort: "dn"})
+		if got := pagedDNs(page); !slices.Equal(got, []string{caseOnly[1], caseOnly[0]}) {
+			t.Errorf("--sort dn of %v = %v", order, got)
+		}
+	}
+}
+
+func TestListMembersPaging(t *testing.T) {
+	env := newTestEnv(t, "")
+	// A second carol, elsewhere, ties with the first on username
+	other := ldap.NewAddRequest("CN=carol,OU=RACS,DC=ad,DC=uoregon,DC=edu", nil)
+	other.Attribute("objectClass", []string{"user"})
+	other.Attribute("cn", []string{"carol"})
+	if err := env.dir.Add(other); err != nil {
+		t.Fatal(err)
+	}
+	if code, stdout, _ := env.run("pirg", "alpha", "add-member", "--dn", carolDN, "--dn", other.DN); code != 0 {
+		t.Fatalf("add-member failed:\n%s", stdout)
+	}
+
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--limit", "2", "--offset", "1"}, "bob\ncarol\n"},
+		{[]string{"--dn-only", "--offset", "2"}, carolDN + "\n" + other.DN + "\n"},
+		{[]string{"--sort", "dn", "--dn-only", "--offset", "2"}, carolDN + "\n" + other.DN + "\n"},
+		{[]string{"--summary", "--limit", "1"}, "4 members\nalice\n"},
+		{[]string{"--limit", "1", "--offset", "3", "--output", "json"}, `{"count":1,"members":["carol"],"total_count":4,"limit":1,"offset":3}` + "\n"},
+	}
+	for _, tc := range cases {
+		args := append([]string{"pirg", "alpha", "list-members"}, tc.args...)
+		code, stdout, stderr := env.run(args...)
+		if code != 0 || stdout != tc.want {
+			t.Errorf("%s: exit %d, printed %q, want %q\n%s", strings.Join(args, " "), code, stdout, tc.want, stderr)
+		}
+	}
+}
+
+func TestListMembersPagingUsage(t *testing.T) {
+	env := newTestEnv(t, "")
+	for _, args := range [][]string{
+		{"--offset", "2"},
+		{"--limit=-1"},
+		{"--offset=-1"},
+	} {
+		code, stdout, stderr := env.run(append([]string{"pirg", "alpha", "list-members"}, args...)...)
+		if code == 0 {
+			t.Errorf("%v exited 0:\n%s", args, stdout)
+		}
+		if out := stdout + stderr; !strings.Contains(out, "--offset") && !strings.Contains(out, "negative") {
+			t.Errorf("%v: unexpected message:\n%s", args, out)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+// TestCephs3PolicyTemplateFromConfig checks that cephs3_policy_template
+// replaces the default policy.
+func TestCephs3PolicyTemplateFromConfig(t *testing.T) {
+	env := newTestEnv(t, `cephs3_policy_template: |
+  {"bucket": "{{.Name}}", "owner_gid": {{.Owner.Gid}}, "admins": {{json .Admins.Members}}, "readers": {{json .Main.Members}}}
+`)
+	env.mustRun(t, "cephs3", "bucket", "create", "--owner", "carol")
+	env.mustRun(t, "cephs3", "bucket", "remove-admin", "carol")
+	env.mustRun(t, "cephs3", "bucket", "add-member", "bob")
+	got := env.mustRun(t, "cephs3", "bucket", "policy")
+	want := `{"bucket": "bucket", "owner_gid": 100015, "admins": [], "readers": ["bob","carol"]}` + "\n"
+	if got != want {
+		t.Errorf("policy = %q, want %q", got, want)
+	}
+}
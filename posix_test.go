@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// TestFixGidSkipsGidsInUse checks that report missing-posix --fix-gid
+// doesn't hand out a GID another group under the groups base DN holds,
+// even one outside the family OUs.
+func TestFixGidSkipsGidsInUse(t *testing.T) {
+	env := newTestEnv(t, "")
+	noGid := ldap.NewAddRequest("CN=is.racs.pirg.alpha.nogid,OU=Groups,OU=alpha,OU=PIRGS,OU=RACS,DC=ad,DC=uoregon,DC=edu", nil)
+	noGid.Attribute("objectClass", []string{"group"})
+	noGid.Attribute("cn", []string{"is.racs.pirg.alpha.nogid"})
+	// The highest GID in the family OUs is 100012, so 100013 is next
+	stray := ldap.NewAddRequest("CN=stray,OU=RACS,DC=ad,DC=uoregon,DC=edu", nil)
+	stray.Attribute("objectClass", []string{"group"})
+	stray.Attribute("cn", []string{"stray"})
+	stray.Attribute("gidNumber", []string{"100013"})
+	for _, req := range []*ldap.AddRequest{noGid, stray} {
+		if err := env.dir.Add(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	code, stdout, stderr := env.run("report", "missing-posix", "--fix-gid")
+	if code != 0 {
+		t.Fatalf("exit %d, stdout:\n%s\nstderr:\n%s", code, stdout, stderr)
+	}
+	if got := env.dir.Values(noGid.DN, "gidNumber"); len(got) != 1 || got[0] != "100014" {
+		t.Errorf("assigned gidNumber = %v, want [100014]", got)
+	}
+}
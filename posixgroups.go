@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/managedgroup"
+)
+
+// posixGroup is a managed group a user resolves to on a node, as printed
+// by aduser posix-groups. Gid is 0 for a group without a gidNumber.
+type posixGroup struct {
+	Family string            `json:"family"`
+	Kind   managedgroup.Kind `json:"kind,omitempty"`
+	CN     string            `json:"cn"`
+	Gid    int               `json:"gid,omitempty"`
+	DN     string            `json:"dn"`
+}
+
+// printPosixGroups prints every managed group username is a direct member
+// of with its gidNumber, as "cn:gid" lines to compare with id on a node.
+// The gidNumbers are read in batched searches.
+func printPosixGroups(ctx context.Context, username string) {
+	userDN, err := ld.GetUserDN(ctx, username)
+	if errors.Is(err, ld.ErrNotFound) {
+		notFound("User %s not found.", username)
+		return
+	}
+	if err != nil {
+		fail("Error getting user DN", err)
+	}
+	groups, err := ld.GetGroupsForDN(ctx, userDN)
+	if err != nil {
+		fail("Error listing groups", err)
+	}
+	var managed []posixGroup
+	var dns []string
+	for _, g := range groups {
+		if g.Family == "" {
+			continue
+		}
+		managed = append(managed, posixGroup{Family: g.Family, Kind: g.Kind, CN: g.CN, DN: g.DN})
+		dns = append(dns, g.DN)
+	}
+	gids, err := ld.GetGidNumbersOfGroups(ctx, dns)
+	if err != nil {
+		fail("Error getting gidNumbers", err)
+	}
+	for i := range managed {
+		managed[i].Gid = gids[strings.ToLower(managed[i].DN)]
+	}
+
+	if jsonOutput() {
+		if managed == nil {
+			managed = []posixGroup{}
+		}
+		printJSON(managed)
+		return
+	}
+	if tableOutput() {
+		rows := make([][]string, 0, len(managed))
+		for _, g := range managed {
+			rows = append(rows, []string{g.Family, g.CN, gidText(g.Gid), g.DN})
+		}
+		printTable([]string{"family", "cn", "gid", "dn"}, rows)
+		return
+	}
+	if len(managed) == 0 {
+		fmt.Fprintf(stdout, "%s is in no managed groups.\n", username)
+		return
+	}
+	for _, g := range managed {
+		fmt.Fprintf(stdout, "%s:%s\n", g.CN, gidText(g.Gid))
+	}
+}
+
+// gidText formats a gidNumber, or "(none)" for a group without one, which
+// won't show up in id at all.
+func gidText(gid int) string {
+	if gid == 0 {
+		return "(none)"
+	}
+	return strconv.Itoa(gid)
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+
+	"github.com/uoracs/directory-manager/internal/pirg"
+)
+
+// pirgSubgroupFound reports whether a PIRG and its subgroup both exist,
+// checking both in one search, and reports whichever is missing as not
+// found.
+func pirgSubgroupFound(ctx context.Context, pirgName string, subgroupName string) bool {
+	pirgFound, subgroupFound, err := pirg.PirgAndSubgroupExist(ctx, pirgName, subgroupName)
+	if err != nil {
+		fail("Error checking PIRG and subgroup existence", err)
+	}
+	if !pirgFound {
+		notFound("PIRG %s not found.", pirgName)
+		return false
+	}
+	if !subgroupFound {
+		notFound("Subgroup %s not found.", subgroupName)
+		return false
+	}
+	return true
+}
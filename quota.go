@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/pirg"
+	"github.com/uoracs/directory-manager/internal/quota"
+)
+
+// listQuotas is what list --long shows of storage quotas: the configured
+// quota names, in order, and each group's quotas by short name.
+type listQuotas struct {
+	names   []string
+	byGroup map[string]map[string]int64
+}
+
+// configuredQuotas returns the names of the quotas in quota_attribute_map,
+// in quota.Names order.
+func configuredQuotas(cfg *config.Config) []string {
+	var names []string
+	for _, name := range quota.Names {
+		if _, ok := cfg.QuotaAttributeMap[name]; ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// quotaText describes quotas for text output, e.g. "projects 10T, scratch
+// 20T", leaving out the ones that aren't set.
+func quotaText(names []string, quotas map[string]int64) string {
+	var parts []string
+	for _, name := range names {
+		if bytes, ok := quotas[name]; ok {
+			parts = append(parts, fmt.Sprintf("%s %s", name, quota.Format(bytes)))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// pirgListQuotas reads the quotas of every PIRG for pirg list --long, or
+// returns none if quota_attribute_map is unset.
+func pirgListQuotas(ctx context.Context, cfg *config.Config) listQuotas {
+	names := configuredQuotas(cfg)
+	if len(names) == 0 {
+		return listQuotas{}
+	}
+	byGroup, err := pirg.PirgListQuotas(ctx)
+	if err != nil {
+		fail("Error listing PIRG quotas", err)
+	}
+	return listQuotas{names: names, byGroup: byGroup}
+}
+
+// requireQuotas fails unless quota_attribute_map names at least one quota.
+func requireQuotas(cfg *config.Config) []string {
+	names := configuredQuotas(cfg)
+	if len(names) == 0 {
+		failUsage("No quotas are configured: set quota_attribute_map, e.g. projects: extensionAttribute10.")
+	}
+	return names
+}
+
+// setQuotas parses the sizes given to pirg set-quota and stores them.
+// "none" clears a quota.
+func setQuotas(ctx context.Context, cfg *config.Config, name string) {
+	requireQuotas(cfg)
+	given := map[string]string{
+		"projects": CLI.Pirg.Name.SetQuota.Projects,
+		"scratch":  CLI.Pirg.Name.SetQuota.Scratch,
+	}
+	quotas := make(map[string]int64)
+	for _, quotaName := range quota.Names {
+		value := given[quotaName]
+		if value == "" {
+			continue
+		}
+		if _, ok := cfg.QuotaAttributeMap[quotaName]; !ok {
+			failUsage(fmt.Sprintf("quota_attribute_map has no attribute for the %s quota.", quotaName))
+		}
+		if strings.EqualFold(value, "none") {
+			quotas[quotaName] = 0
+			continue
+		}
+		bytes, err := quota.Parse(value)
+		if err != nil {
+			failUsage(fmt.Sprintf("--%s: %s", quotaName, err))
+		}
+		quotas[quotaName] = bytes
+	}
+	if len(quotas) == 0 {
+		failUsage("Give at least one quota to set, e.g. --projects 10T.")
+	}
+	if err := pirg.PirgSetQuotas(ctx, name, quotas); err != nil {
+		fail("Error setting PIRG quota", err)
+	}
+}
+
+// printQuotas prints the quotas of one PIRG, one per line, or as a JSON
+// object of bytes keyed by quota name.
+func printQuotas(ctx context.Context, cfg *config.Config, name string) {
+	names := requireQuotas(cfg)
+	quotas, err := pirg.PirgGetQuotas(ctx, name)
+	if err != nil {
+		fail("Error getting PIRG quota", err)
+	}
+	if jsonOutput() {
+		printJSON(quotas)
+		return
+	}
+	if tableOutput() {
+		rows := make([][]string, 0, len(names))
+		for _, quotaName := range names {
+			value := "(not set)"
+			if bytes, ok := quotas[quotaName]; ok {
+				value = quota.Format(bytes)
+			}
+			rows = append(rows, []string{quotaName, value})
+		}
+		printTable([]string{"quota", "size"}, rows)
+		return
+	}
+	for _, quotaName := range names {
+		if bytes, ok := quotas[quotaName]; ok {
+			fmt.Fprintf(stdout, "%s: %s\n", quotaName, quota.Format(bytes))
+			continue
+		}
+		fmt.Fprintf(stdout, "%s: not set\n", quotaName)
+	}
+}
+
+// quotaRow is one PIRG in report quotas.
+type quotaRow struct {
+	Pirg   string           `json:"pirg"`
+	Quotas map[string]int64 `json:"quotas"`
+}
+
+// printQuotaReport prints every PIRG with a quota. CSV and JSON give bytes
+// for the provisioner; text and table use K/M/G/T sizes.
+func printQuotaReport(ctx context.Context, cfg *config.Config) {
+	names := requireQuotas(cfg)
+	byGroup, err := pirg.PirgListQuotas(ctx)
+	if err != nil {
+		fail("Error listing PIRG quotas", err)
+	}
+	var pirgs []string
+	for name, quotas := range byGroup {
+		if len(quotas) > 0 {
+			pirgs = append(pirgs, name)
+		}
+	}
+	slices.Sort(pirgs)
+
+	if jsonOutput() {
+		rows := make([]quotaRow, 0, len(pirgs))
+		for _, name := range pirgs {
+			rows = append(rows, quotaRow{Pirg: name, Quotas: byGroup[name]})
+		}
+		printJSON(rows)
+		return
+	}
+	headers := append([]string{"pirg"}, names...)
+	rows := make([][]string, 0, len(pirgs))
+	for _, name := range pirgs {
+		row := []string{name}
+		for _, quotaName := range names {
+			bytes, ok := byGroup[name][quotaName]
+			switch {
+			case !ok:
+				row = append(row, "")
+			case csvOutput():
+				row = append(row, strconv.FormatInt(bytes, 10))
+			default:
+				row = append(row, quota.Format(bytes))
+			}
+		}
+		rows = append(rows, row)
+	}
+	if csvOutput() {
+		w := csv.NewWriter(stdout)
+		w.Write(headers)
+		w.WriteAll(rows)
+		if err := w.Error(); err != nil {
+			fail("Error writing CSV", err)
+		}
+		return
+	}
+	if tableOutput() {
+		printTable(headers, rows)
+		return
+	}
+	if len(pirgs) == 0 {
+		fmt.Fprintln(stdout, "No PIRGs have quotas.")
+		return
+	}
+	for _, name := range pirgs {
+		fmt.Fprintf(stdout, "%s: %s\n", name, quotaText(names, byGroup[name]))
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+)
+
+// ldifSafe reports whether value can follow "name: " in LDIF as it is, or
+// must be base64-encoded after "name:: ".
+func ldifSafe(value string) bool {
+	if value == "" {
+		return true
+	}
+	if strings.ContainsAny(value, "\r\n\x00") {
+		return false
+	}
+	return value[0] != ' ' && value[0] != ':' && value[0] != '<' && !strings.HasSuffix(value, " ")
+}
+
+// printRawSearch runs raw search and prints the entries as LDIF, with
+// attributes sorted by name and a blank line after each entry, or as JSON.
+func printRawSearch(ctx context.Context, base string, scope string, filter string, attrs []string) {
+	entries, err := ld.RawSearch(ctx, base, scope, filter, attrs)
+	if errors.Is(err, ld.ErrNotFound) {
+		notFound("%s not found.", base)
+		return
+	}
+	if err != nil {
+		fail("Error searching", err)
+	}
+	if jsonOutput() {
+		printJSON(entries)
+		return
+	}
+	for _, e := range entries {
+		fmt.Fprintf(stdout, "dn: %s\n", e.DN)
+		names := make([]string, 0, len(e.Attributes)+len(e.Binary))
+		for name := range e.Attributes {
+			names = append(names, name)
+		}
+		for name := range e.Binary {
+			if _, ok := e.Attributes[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		slices.SortFunc(names, func(a, b string) int { return strings.Compare(strings.ToLower(a), strings.ToLower(b)) })
+		for _, name := range names {
+			for _, value := range e.Attributes[name] {
+				if ldifSafe(value) {
+					fmt.Fprintf(stdout, "%s: %s\n", name, value)
+				} else {
+					fmt.Fprintf(stdout, "%s:: %s\n", name, base64.StdEncoding.EncodeToString([]byte(value)))
+				}
+			}
+			for _, value := range e.Binary[name] {
+				fmt.Fprintf(stdout, "%s:: %s\n", name, value)
+			}
+		}
+		fmt.Fprintln(stdout)
+	}
+	fmt.Fprintf(stdout, "# %d entries\n", len(entries))
+}
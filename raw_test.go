@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestLDIFSafe(t *testing.T) {
+	cases := []struct {
+		value string
+		want  bool
+	}{
+		{"", true},
+		{"is.racs.pirg.alpha", true},
+		{"two words", true},
+		{" leading space", false},
+		{"trailing space ", false},
+		{":colon", false},
+		{"<angle", false},
+		{"line\nbreak", false},
+		{"carriage\rreturn", false},
+		{"nul\x00", false},
+	}
+	for _, tc := range cases {
+		if got := ldifSafe(tc.value); got != tc.want {
+			t.Errorf("ldifSafe(%q) = %v, want %v", tc.value, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/uoracs/directory-manager/internal/config"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/managedgroup"
+)
+
+// readOnlyVerbs are the last words of commands that never change the
+// directory, whatever their flags.
+var readOnlyVerbs = map[string]bool{
+	"list":            true,
+	"list-members":    true,
+	"list-admins":     true,
+	"get-pi":          true,
+	"info":            true,
+	"get-owner":       true,
+	"policy":          true,
+	"get-gid":         true,
+	"get-mail":        true,
+	"get-quota":       true,
+	"quotas":          true,
+	"get-manager":     true,
+	"get-uid":         true,
+	"dns":             true,
+	"exposure":        true,
+	"usage":           true,
+	"empty":           true,
+	"stale-subgroups": true,
+	"name-collisions": true,
+	"memberof":        true,
+	"search":          true,
+	"posix-groups":    true,
+	"owned":           true,
+	"contacts":        true,
+	"nextgidnumber":   true,
+	"schema":          true,
+	"validate":        true,
+	"doctor":          true,
+	"version":         true,
+	"start":           true,
+	"stop":            true,
+	"status":          true,
+}
+
+// commandWords returns the words of command without argument placeholders,
+// e.g. "pirg subgroup add-member" for "pirg <name> subgroup <name>
+// add-member <username>".
+func commandWords(command string) []string {
+	var words []string
+	for _, word := range strings.Fields(command) {
+		if !strings.HasPrefix(word, "<") {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// changesDirectory reports whether command, with the flags it was given,
+// can change the directory.
+func changesDirectory(command string) bool {
+	words := commandWords(command)
+	verb := words[len(words)-1]
+	if verb == "start" && words[0] == "maintenance" {
+		// Unlike agent start, this writes the lock to the directory
+		return true
+	}
+	if readOnlyVerbs[verb] {
+		return false
+	}
+	switch verb {
+	case "check":
+		return CLI.Pirg.Name.Check.Fix || CLI.Cephfs.Name.Check.Fix || CLI.Cephs3.Name.Check.Fix
+	case "add-missing":
+		return !CLI.Pirg.Name.AddMissing.DryRun
+	case "set-members":
+		return !CLI.Pirg.Name.Subgroup.Name.SetMembers.DryRun
+	case "reconcile":
+		return !CLI.Aduser.Name.Reconcile.DryRun
+	case "transfer-ownership":
+		return !CLI.TransferOwnership.DryRun
+	case "delete":
+		return !CLI.Pirg.Name.Delete.DryRun
+	case "prefix":
+		return !CLI.Migrate.Prefix.DryRun
+	case "missing-posix":
+		return CLI.Report.MissingPosix.FixGid
+	}
+	return true
+}
+
+// checkReason exits before any directory work when command changes the
+// directory without the --reason that require_reason asks for. When a
+// reason is given, it is attached to every log record of the run.
+func checkReason(cfg *config.Config, command string) {
+	if !changesDirectory(command) {
+		return
+	}
+	if CLI.Reason == "" {
+		if cfg.ReasonRequired() {
+			failUsage("This command changes the directory, and require_reason is set: pass --reason, e.g. --reason \"RT#12345 onboarding\".")
+		}
+		return
+	}
+	slog.SetDefault(slog.Default().With("reason", CLI.Reason))
+	slog.Info("Running command", "command", command)
+}
+
+// noteTarget returns the family and short name of the managed group a
+// command changes, if it changes one that still exists afterwards.
+func noteTarget(command string) (managedgroup.Family, string, bool) {
+	words := commandWords(command)
+	fields := strings.Fields(command)
+	if len(fields) < 3 || fields[1] != "<name>" || (len(words) == 2 && words[1] == "delete") {
+		return managedgroup.Family{}, "", false
+	}
+	f, ok := managedgroup.ByName(fields[0])
+	if !ok {
+		return managedgroup.Family{}, "", false
+	}
+	switch f.Name {
+	case "pirg":
+		return f, CLI.Pirg.Name.Name, true
+	case "cephfs":
+		if words[1] == "rename" {
+			return f, CLI.Cephfs.Name.Rename.NewName, true
+		}
+		return f, CLI.Cephfs.Name.Name, true
+	case "cephs3":
+		if words[1] == "rename" {
+			return f, CLI.Cephs3.Name.Rename.NewName, true
+		}
+		return f, CLI.Cephs3.Name.Name, true
+	case "software":
+		return f, CLI.Software.Name.Name, true
+	}
+	return managedgroup.Family{}, "", false
+}
+
+// noteReason records the --reason of a change to a managed group in the
+// group's info attribute, if the command changed anything, even if it then
+// failed. The change has already been made, so failing to record it only
+// warns.
+func noteReason(ctx context.Context, cfg *config.Config, command string) {
+	if CLI.Reason == "" || !changesDirectory(command) || !ld.Changed(ctx) {
+		return
+	}
+	f, name, ok := noteTarget(command)
+	if !ok {
+		return
+	}
+	groupDN, found, err := ld.GetGroupDN(ctx, cfg.BaseDN(f.Name), f.Prefix+name)
+	if err != nil || !found {
+		slog.Warn("Failed to find group for change note", "family", f.Name, "name", name, "error", err)
+		return
+	}
+	words := commandWords(command)
+	note := fmt.Sprintf("%s %s: %s", time.Now().Format(time.DateOnly), strings.Join(words[1:], " "), CLI.Reason)
+	if err := ld.AppendChangeNote(ctx, groupDN, note, cfg.ChangeNoteLimit); err != nil {
+		slog.Warn("Failed to record change note", "groupDN", groupDN, "error", err)
+	}
+}
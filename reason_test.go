@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const alphaDN = "CN=is.racs.pirg.alpha,OU=alpha,OU=PIRGS,OU=RACS,DC=ad,DC=uoregon,DC=edu"
+
+func TestNoteReason(t *testing.T) {
+	cases := []struct {
+		name     string
+		args     []string
+		wantCode bool // whether the command exits 0
+		wantNote string
+	}{
+		{
+			name:     "success",
+			args:     []string{"--reason", "RT#1 onboarding", "pirg", "alpha", "add-member", "carol"},
+			wantCode: true,
+			wantNote: " add-member: RT#1 onboarding",
+		},
+		{
+			// dave is disabled, so the command fails after adding carol
+			name:     "partial",
+			args:     []string{"--reason", "RT#2 lab move", "pirg", "alpha", "add-member", "carol", "dave"},
+			wantNote: " add-member: RT#2 lab move",
+		},
+		{
+			// The workers' changes count as the command's
+			name:     "parallel",
+			args:     []string{"--parallel", "2", "--reason", "RT#4 term end", "pirg", "alpha", "remove-member", "bob", "carol"},
+			wantCode: true,
+			wantNote: " remove-member: RT#4 term end",
+		},
+		{
+			// Nothing changed, so there is nothing to note
+			name: "refused",
+			args: []string{"--reason", "RT#3 typo", "pirg", "alpha", "add-member", "dave"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := newTestEnv(t, "")
+			code, stdout, stderr := env.run(tc.args...)
+			if (code == 0) != tc.wantCode {
+				t.Fatalf("exit %d, stdout:\n%s\nstderr:\n%s", code, stdout, stderr)
+			}
+			info := strings.Join(env.dir.Values(alphaDN, "info"), "\n")
+			if tc.wantNote == "" {
+				if strings.Contains(info, "[directory-manager] ") {
+					t.Errorf("change note recorded although nothing changed: %q", info)
+				}
+				return
+			}
+			if !strings.Contains(info, tc.wantNote) {
+				t.Errorf("info = %q, want a change note ending %q", info, tc.wantNote)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uoracs/directory-manager/internal/pirg"
+)
+
+// removeAllRegular removes every member of the PIRG who is neither its PI
+// nor an admin, apart from the usernames in except, after listing them and
+// asking for confirmation unless yes is set.
+func removeAllRegular(ctx context.Context, name string, except []string, yes bool) {
+	found, err := pirg.PirgExists(ctx, name)
+	if err != nil {
+		fail("Error checking PIRG existence", err)
+	}
+	if !found {
+		notFound("PIRG %s not found.", name)
+		return
+	}
+	members, err := pirg.PirgListRegularMembers(ctx, name, except)
+	if err != nil {
+		fail("Error listing regular members", err)
+	}
+	if len(members) == 0 {
+		fmt.Fprintln(stdout, "No members to remove.")
+		return
+	}
+	fmt.Fprintf(stdout, "Members to remove (%d):\n", len(members))
+	for _, m := range members {
+		fmt.Fprintln(stdout, m.Username)
+	}
+	if !yes && !confirm(ctx, "Remove these members?") {
+		fmt.Fprintln(stdout, "Aborted.")
+		return
+	}
+	if err := pirg.PirgRemoveRegularMembers(ctx, name, members); err != nil {
+		fail("Error removing regular members", err)
+	}
+}
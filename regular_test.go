@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestRemoveAllRegular checks that remove-member --all-regular keeps the PI,
+// admins, and --except users, sweeps the rest from the subgroups, and only
+// drops users from the top-level group once they are in no PIRG.
+func TestRemoveAllRegular(t *testing.T) {
+	env := newTestEnv(t, "")
+	frankDN := env.addMemberObject(t, "frank", "top", "person", "organizationalPerson", "user")
+	env.mustRun(t, "pirg", "alpha", "add-member", "carol", "frank")
+	env.mustRun(t, "pirg", "alpha", "subgroup", "lab", "add-member", "carol", "frank")
+	env.mustRun(t, "pirg", "alpha", "add-admin", "bob")
+
+	got := env.mustRun(t, "pirg", "alpha", "remove-member", "--all-regular", "--yes", "--except", "carol")
+	if !strings.HasPrefix(got, "Members to remove (1):\nfrank\n") {
+		t.Errorf("remove-member --all-regular printed:\n%s", got)
+	}
+	if got := env.mustRun(t, "pirg", "alpha", "list-members"); got != "alice\nbob\ncarol\n" {
+		t.Errorf("members = %q, want the PI, the admin, and the excepted user", got)
+	}
+	if got := env.mustRun(t, "pirg", "alpha", "subgroup", "lab", "list-members"); got != "alice\nbob\ncarol\n" {
+		t.Errorf("subgroup members = %q, want frank swept", got)
+	}
+	if env.hasMember(talapasUsersDN, frankDN) {
+		t.Error("frank, in no PIRG now, left in the top-level users group")
+	}
+
+	env.mustRun(t, "pirg", "alpha", "remove-member", "--all-regular", "--yes")
+	if got := env.mustRun(t, "pirg", "alpha", "list-members"); got != "alice\nbob\n" {
+		t.Errorf("members = %q, want only the PI and the admin", got)
+	}
+	if !env.hasMember(talapasUsersDN, carolDN) {
+		t.Error("carol, still in gamma, removed from the top-level users group")
+	}
+
+	if got := env.mustRun(t, "pirg", "alpha", "remove-member", "--all-regular", "--yes"); !strings.HasPrefix(got, "No members to remove.\n") {
+		t.Errorf("remove-member --all-regular with no regular members printed %q", got)
+	}
+}
+
+func TestRemoveAllRegularRefused(t *testing.T) {
+	env := newTestEnv(t, "")
+	code, stdout, _ := env.run("pirg", "alpha", "remove-member", "--all-regular", "--yes", "bob")
+	if code != 1 || !strings.Contains(stdout, "Usernames cannot be combined with --all-regular.") {
+		t.Errorf("--all-regular with a username exited %d:\n%s", code, stdout)
+	}
+	if !env.hasMember(alphaDN, bobDN) {
+		t.Error("bob removed by a refused command")
+	}
+
+	stdout = env.mustRun(t, "pirg", "alpha", "remove-member", "--all-regular")
+	if !strings.Contains(stdout, "Aborted.") {
+		t.Errorf("unconfirmed --all-regular printed:\n%s", stdout)
+	}
+	if !env.hasMember(alphaDN, bobDN) {
+		t.Error("bob removed without confirmation")
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/uoracs/directory-manager/internal/report"
+)
+
+func csvOutput() bool {
+	return CLI.Output == "csv"
+}
+
+// printFindings prints the groups a report flagged, by DN in text mode,
+// or as a table, CSV with a header row, or a JSON array.
+func printFindings(findings []report.Finding) {
+	if jsonOutput() {
+		if findings == nil {
+			findings = []report.Finding{}
+		}
+		printJSON(findings)
+		return
+	}
+	headers := []string{"family", "name", "kind", "cn", "dn", "members", "when_changed"}
+	rows := make([][]string, 0, len(findings))
+	for _, f := range findings {
+		rows = append(rows, []string{
+			f.Family,
+			f.Name,
+			string(f.Kind),
+			f.CN,
+			f.DN,
+			strconv.Itoa(f.Members),
+			f.WhenChanged.Format(time.RFC3339),
+		})
+	}
+	if csvOutput() {
+		w := csv.NewWriter(stdout)
+		w.Write(headers)
+		w.WriteAll(rows)
+		if err := w.Error(); err != nil {
+			fail("Error writing CSV", err)
+		}
+		return
+	}
+	if tableOutput() {
+		printTable(headers, rows)
+		return
+	}
+	if len(findings) == 0 {
+		fmt.Fprintln(stdout, "No groups found.")
+		return
+	}
+	for _, f := range findings {
+		fmt.Fprintf(stdout, "%s (%s %s, %d members, changed %s)\n", f.DN, f.Family, f.Kind, f.Members, f.WhenChanged.Format(time.DateOnly))
+	}
+}
+
+// printPosixFindings prints the objects missing a POSIX attribute, with the
+// gidNumber each group was given by --fix-gid.
+func printPosixFindings(findings []report.PosixFinding) {
+	if jsonOutput() {
+		if findings == nil {
+			findings = []report.PosixFinding{}
+		}
+		printJSON(findings)
+		return
+	}
+	headers := []string{"family", "group", "dn", "attribute", "value", "assigned_gid"}
+	rows := make([][]string, 0, len(findings))
+	for _, f := range findings {
+		assigned := ""
+		if f.AssignedGid != 0 {
+			assigned = strconv.Itoa(f.AssignedGid)
+		}
+		rows = append(rows, []string{f.Family, f.Group, f.DN, f.Attribute, f.Value, assigned})
+	}
+	if csvOutput() {
+		w := csv.NewWriter(stdout)
+		w.Write(headers)
+		w.WriteAll(rows)
+		if err := w.Error(); err != nil {
+			fail("Error writing CSV", err)
+		}
+		return
+	}
+	if tableOutput() {
+		printTable(headers, rows)
+		return
+	}
+	if len(findings) == 0 {
+		fmt.Fprintln(stdout, "No missing POSIX attributes found.")
+		return
+	}
+	for _, f := range findings {
+		switch {
+		case f.AssignedGid != 0:
+			fmt.Fprintf(stdout, "%s (%s %s): assigned gidNumber %d\n", f.DN, f.Family, f.Group, f.AssignedGid)
+		case f.Attribute == "gidNumber" && f.Value != "":
+			fmt.Fprintf(stdout, "%s (%s %s): gidNumber %q is not a number\n", f.DN, f.Family, f.Group, f.Value)
+		case f.Attribute == "uidNumber":
+			fmt.Fprintf(stdout, "%s (%s %s): no uidNumber, falls back to SID %s\n", f.DN, f.Family, f.Group, f.Value)
+		default:
+			fmt.Fprintf(stdout, "%s (%s %s): no %s\n", f.DN, f.Family, f.Group, f.Attribute)
+		}
+	}
+}
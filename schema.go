@@ -0,0 +1,74 @@
+package main
+
+import (
+	"github.com/uoracs/directory-manager/internal/config"
+	"github.com/uoracs/directory-manager/internal/managedgroup"
+)
+
+// schemaVersion is bumped whenever the shape of the schema output changes,
+// so consumers can tell which fields to expect.
+const schemaVersion = 1
+
+type schema struct {
+	SchemaVersion        int            `json:"schema_version"`
+	Version              string         `json:"version"`
+	TopLevelUsersGroupDN string         `json:"top_level_users_group_dn"`
+	Families             []familySchema `json:"families"`
+}
+
+type familySchema struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Prefix  string `json:"prefix"`
+	BaseDN  string `json:"base_dn"`
+	// Name patterns use {name} for a group's short name and {subgroup} for a subgroup's.
+	MainGroup             string `json:"main_group"`
+	AdminsGroup           string `json:"admins_group,omitempty"`
+	RoleGroup             string `json:"role_group,omitempty"`
+	RoleName              string `json:"role_name,omitempty"`
+	Subgroup              string `json:"subgroup,omitempty"`
+	SubgroupContainer     string `json:"subgroup_container,omitempty"`
+	TopLevelAdminsGroupDN string `json:"top_level_admins_group_dn,omitempty"`
+	MinGid                int    `json:"min_gid"`
+	MaxGid                int    `json:"max_gid"`
+	Layout                string `json:"layout,omitempty"`
+}
+
+// buildSchema describes the naming conventions of every managed group
+// family, filled in with the base DNs and GID ranges from cfg.
+func buildSchema(cfg *config.Config) schema {
+	s := schema{
+		SchemaVersion:        schemaVersion,
+		Version:              version,
+		TopLevelUsersGroupDN: cfg.UsersGroupDN,
+	}
+	for _, f := range managedgroup.Families {
+		minGid, maxGid := cfg.GidRange(f.Name)
+		fs := familySchema{
+			Name:                  f.Name,
+			Enabled:               cfg.DisabledSubsystem(f.Name) == "",
+			Prefix:                f.Prefix,
+			BaseDN:                cfg.BaseDN(f.Name),
+			MainGroup:             f.Prefix + "{name}",
+			RoleName:              f.RoleName,
+			TopLevelAdminsGroupDN: cfg.AdminsGroupDN(f.Name),
+			MinGid:                minGid,
+			MaxGid:                maxGid,
+		}
+		if f.AdminsSuffix != "" {
+			fs.AdminsGroup = fs.MainGroup + f.AdminsSuffix
+		}
+		if f.RoleSuffix != "" {
+			fs.RoleGroup = fs.MainGroup + f.RoleSuffix
+		}
+		if f.SubgroupOU != "" {
+			fs.Subgroup = fs.MainGroup + ".{subgroup}"
+			fs.SubgroupContainer = "OU=" + f.SubgroupOU + ",OU={name}," + fs.BaseDN
+		}
+		if f.Name == managedgroup.Software.Name {
+			fs.Layout = cfg.SoftwareLayout
+		}
+		s.Families = append(s.Families, fs)
+	}
+	return s
+}
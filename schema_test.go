@@ -0,0 +1,103 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/uoracs/directory-manager/internal/config"
+)
+
+func TestBuildSchema(t *testing.T) {
+	disabled := false
+	cfg := &config.Config{
+		LDAPPirgDN:            "OU=PIRGS,DC=test",
+		LDAPCephfsDN:          "OU=CEPHFS,DC=test",
+		LDAPCephs3DN:          "OU=CEPHS3,DC=test",
+		LDAPSoftwareDN:        "OU=SOFTWARE,DC=test",
+		UsersGroupDN:          "CN=Users,DC=test",
+		PirgAdminsGroupDN:     "CN=PirgAdmins,DC=test",
+		CephfsAdminsGroupDN:   "CN=CephfsAdmins,DC=test",
+		Cephs3AdminsGroupDN:   "CN=Cephs3Admins,DC=test",
+		SoftwareLayout:        "ou",
+		SoftwareAdminsGroupDN: "CN=SoftwareAdmins,DC=test",
+		EnableCephs3:          &disabled,
+		LDAPMinGid:            1000,
+		LDAPMaxGid:            1999,
+		CephfsMinGid:          2000,
+		CephfsMaxGid:          2999,
+	}
+	s := buildSchema(cfg)
+	if s.SchemaVersion != schemaVersion || s.Version != version {
+		t.Errorf("schema_version %d, version %q", s.SchemaVersion, s.Version)
+	}
+	if s.TopLevelUsersGroupDN != "CN=Users,DC=test" {
+		t.Errorf("top_level_users_group_dn = %q", s.TopLevelUsersGroupDN)
+	}
+
+	want := map[string]familySchema{
+		"pirg": {
+			Name:                  "pirg",
+			Enabled:               true,
+			Prefix:                "is.racs.pirg.",
+			BaseDN:                "OU=PIRGS,DC=test",
+			MainGroup:             "is.racs.pirg.{name}",
+			AdminsGroup:           "is.racs.pirg.{name}.admins",
+			RoleGroup:             "is.racs.pirg.{name}.pi",
+			RoleName:              "PI",
+			Subgroup:              "is.racs.pirg.{name}.{subgroup}",
+			SubgroupContainer:     "OU=Groups,OU={name},OU=PIRGS,DC=test",
+			TopLevelAdminsGroupDN: "CN=PirgAdmins,DC=test",
+			MinGid:                1000,
+			MaxGid:                1999,
+		},
+		"cephfs": {
+			Name:                  "cephfs",
+			Enabled:               true,
+			Prefix:                "is.racs.cephfs.",
+			BaseDN:                "OU=CEPHFS,DC=test",
+			MainGroup:             "is.racs.cephfs.{name}",
+			AdminsGroup:           "is.racs.cephfs.{name}.admins",
+			RoleGroup:             "is.racs.cephfs.{name}.owner",
+			RoleName:              "owner",
+			Subgroup:              "is.racs.cephfs.{name}.{subgroup}",
+			SubgroupContainer:     "OU=Groups,OU={name},OU=CEPHFS,DC=test",
+			TopLevelAdminsGroupDN: "CN=CephfsAdmins,DC=test",
+			MinGid:                2000,
+			MaxGid:                2999,
+		},
+		"cephs3": {
+			Name:                  "cephs3",
+			Enabled:               false,
+			Prefix:                "is.racs.cephs3.",
+			BaseDN:                "OU=CEPHS3,DC=test",
+			MainGroup:             "is.racs.cephs3.{name}",
+			AdminsGroup:           "is.racs.cephs3.{name}.admins",
+			RoleGroup:             "is.racs.cephs3.{name}.owner",
+			RoleName:              "owner",
+			Subgroup:              "is.racs.cephs3.{name}.{subgroup}",
+			SubgroupContainer:     "OU=Groups,OU={name},OU=CEPHS3,DC=test",
+			TopLevelAdminsGroupDN: "CN=Cephs3Admins,DC=test",
+			MinGid:                1000,
+			MaxGid:                1999,
+		},
+		"software": {
+			Name:                  "software",
+			Enabled:               true,
+			Prefix:                "is.racs.software.",
+			BaseDN:                "OU=SOFTWARE,DC=test",
+			MainGroup:             "is.racs.software.{name}",
+			AdminsGroup:           "is.racs.software.{name}.admins",
+			TopLevelAdminsGroupDN: "CN=SoftwareAdmins,DC=test",
+			MinGid:                1000,
+			MaxGid:                1999,
+			Layout:                "ou",
+		},
+	}
+	if len(s.Families) != len(want) {
+		t.Fatalf("%d families, want %d: %+v", len(s.Families), len(want), s.Families)
+	}
+	for _, got := range s.Families {
+		if got != want[got.Name] {
+			t.Errorf("family %s:\n got %+v\nwant %+v", got.Name, got, want[got.Name])
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/uoracs/directory-manager/internal/pirg"
+)
+
+// readUsernames reads usernames from path, or stdin for "-", one per line,
+// skipping blank lines and lines starting with #.
+func readUsernames(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	var usernames []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		usernames = append(usernames, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return usernames, nil
+}
+
+// setSubgroupMembers makes the subgroup's members exactly members plus
+// those read from fromFile, printing what is added and removed. An empty
+// list is refused, since it would empty the subgroup; remove-member does
+// that explicitly.
+func setSubgroupMembers(ctx context.Context, pirgName string, subgroupName string, members []string, fromFile string, dryRun bool) {
+	if fromFile != "" {
+		fileMembers, err := readUsernames(fromFile)
+		if err != nil {
+			fail("Error reading members", err)
+		}
+		members = append(members, fileMembers...)
+	}
+	if len(members) == 0 {
+		failUsage("No members given; pass --member or --from-file. Use remove-member to empty a subgroup.")
+	}
+	if !pirgSubgroupFound(ctx, pirgName, subgroupName) {
+		return
+	}
+	diff, err := pirg.PirgSubgroupMembershipDiff(ctx, pirgName, subgroupName, members)
+	if err != nil {
+		fail("Error comparing subgroup membership", err)
+	}
+	if jsonOutput() {
+		printJSON(diff)
+	} else {
+		add, remove := "Adding", "Removing"
+		if dryRun {
+			add, remove = "Would add", "Would remove"
+		}
+		for _, username := range diff.Add {
+			fmt.Fprintf(stdout, "%s member %s\n", add, username)
+		}
+		for _, username := range diff.Remove {
+			fmt.Fprintf(stdout, "%s member %s\n", remove, username)
+		}
+		if len(diff.Add) == 0 && len(diff.Remove) == 0 {
+			fmt.Fprintln(stdout, "Subgroup members already match.")
+		}
+	}
+	if dryRun {
+		return
+	}
+	if err := pirg.PirgSubgroupSetMembers(ctx, pirgName, subgroupName, members); err != nil {
+		fail("Error setting subgroup members", err)
+	}
+}
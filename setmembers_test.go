@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestReadUsernames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "members.txt")
+	if err := os.WriteFile(path, []byte("alice\n\n# spring term\n  carol  \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readUsernames(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"alice", "carol"}; !slices.Equal(got, want) {
+		t.Errorf("readUsernames() = %q, want %q", got, want)
+	}
+	if _, err := readUsernames(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("readUsernames() of a missing file succeeded")
+	}
+}
+
+// TestSubgroupSetMembersFromFile checks that --from-file and --member are
+// combined into the desired membership.
+func TestSubgroupSetMembersFromFile(t *testing.T) {
+	env := newTestEnv(t, "")
+	env.mustRun(t, "pirg", "alpha", "add-member", "carol")
+	path := filepath.Join(t.TempDir(), "members.txt")
+	if err := os.WriteFile(path, []byte("# spring term\ncarol\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	env.mustRun(t, "pirg", "alpha", "subgroup", "lab", "set-members", "--from-file", path, "--member", "alice")
+	if got := env.mustRun(t, "pirg", "alpha", "subgroup", "lab", "list-members"); got != "alice\ncarol\n" {
+		t.Errorf("subgroup members = %q, want alice and carol", got)
+	}
+	if !env.hasMember(alphaDN, bobDN) {
+		t.Error("bob removed from the PIRG along with the subgroup")
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	softwareDN             = "OU=SOFTWARE,OU=RACS,DC=ad,DC=uoregon,DC=edu"
+	softwareAdminsGroupDN  = "CN=IS.RACS.Talapas.SoftwareAdmins,OU=RACS,OU=Groups,OU=IS,OU=Units,DC=ad,DC=uoregon,DC=edu"
+	softwareAdminsGroupCfg = "software_admins_group_dn: " + softwareAdminsGroupDN + "\n"
+)
+
+// addSoftwareGroup adds a software group without an admins group behind
+// the tool's back, as older versions created them, in a per-group OU if
+// perGroupOU is set and directly under the software base DN otherwise.
+// It returns the group's DN.
+func (e *testEnv) addSoftwareGroup(t *testing.T, name string, perGroupOU bool) string {
+	t.Helper()
+	parentDN := softwareDN
+	var reqs []*ldap.AddRequest
+	if perGroupOU {
+		parentDN = "OU=" + name + "," + softwareDN
+		ou := ldap.NewAddRequest(parentDN, nil)
+		ou.Attribute("objectClass", []string{"organizationalUnit"})
+		reqs = append(reqs, ou)
+	}
+	cn := "is.racs.software." + name
+	group := ldap.NewAddRequest("CN="+cn+","+parentDN, nil)
+	group.Attribute("objectClass", []string{"group"})
+	group.Attribute("cn", []string{cn})
+	group.Attribute("sAMAccountName", []string{cn})
+	group.Attribute("gidNumber", []string{"100020"})
+	reqs = append(reqs, group)
+	for _, req := range reqs {
+		if err := e.dir.Add(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return "CN=" + cn + "," + parentDN
+}
+
+// addSoftwareAdminsGroup adds the top-level software admins group.
+func (e *testEnv) addSoftwareAdminsGroup(t *testing.T) {
+	t.Helper()
+	req := ldap.NewAddRequest(softwareAdminsGroupDN, nil)
+	req.Attribute("objectClass", []string{"group"})
+	req.Attribute("cn", []string{"IS.RACS.Talapas.SoftwareAdmins"})
+	if err := e.dir.Add(req); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSoftwareDeleteOULayoutSpacedBaseDN checks that deleting a software
+// group in its own OU takes the OU with it when ldap_software_dn is
+// written with spaces after its commas, unlike the DNs the directory
+// returns, so comparing DN strings would miss the per-group OU.
+func TestSoftwareDeleteOULayoutSpacedBaseDN(t *testing.T) {
+	t.Setenv("DIRECTORY_MANAGER_LDAP_SOFTWARE_DN", "OU=SOFTWARE, OU=RACS, DC=ad, DC=uoregon, DC=edu")
+	t.Setenv("DIRECTORY_MANAGER_SOFTWARE_LAYOUT", "ou")
+	env := newTestEnv(t, "")
+	const ouDN = "OU=matlab,OU=SOFTWARE,OU=RACS,DC=ad,DC=uoregon,DC=edu"
+	env.addSoftwareGroup(t, "matlab", true)
+
+	if code, stdout, stderr := env.run("software", "matlab", "delete"); code != 0 {
+		t.Fatalf("exit %d, stdout:\n%s\nstderr:\n%s", code, stdout, stderr)
+	}
+	if got := env.dir.Values(ouDN, "objectClass"); len(got) != 0 {
+		t.Errorf("per-group OU %s left behind", ouDN)
+	}
+}
+
+// TestSoftwareAddAdminRequiresMember checks that add-admin refuses users
+// who aren't members without creating an admins group, and that admins
+// are added to and removed from the top-level software admins group.
+func TestSoftwareAddAdminRequiresMember(t *testing.T) {
+	env := newTestEnv(t, softwareAdminsGroupCfg)
+	env.addSoftwareAdminsGroup(t)
+	const adminsDN = "CN=is.racs.software.matlab.admins," + softwareDN
+	env.mustRun(t, "software", "matlab", "create")
+
+	code, stdout, _ := env.run("software", "matlab", "add-admin", "alice")
+	if code == 0 || !strings.Contains(stdout, "alice is not a member of SOFTWARE matlab") {
+		t.Errorf("add-admin of a non-member exited %d:\n%s", code, stdout)
+	}
+	if env.dir.Exists(adminsDN) {
+		t.Error("refused add-admin created an admins group")
+	}
+	if env.hasMember(softwareAdminsGroupDN, aliceDN) {
+		t.Error("refused add-admin added alice to the top-level admins group")
+	}
+
+	env.mustRun(t, "software", "matlab", "add-member", "alice")
+	env.mustRun(t, "software", "matlab", "add-admin", "alice")
+	if !env.hasMember(adminsDN, aliceDN) {
+		t.Fatal("alice not added to the admins group")
+	}
+	if !env.hasMember(softwareAdminsGroupDN, aliceDN) {
+		t.Error("alice not added to the top-level admins group")
+	}
+	if got := env.mustRun(t, "software", "matlab", "list-admins"); got != "alice\n" {
+		t.Errorf("list-admins = %q, want alice", got)
+	}
+
+	// An admin of another software group stays in the top-level group.
+	env.mustRun(t, "software", "stata", "create")
+	env.mustRun(t, "software", "stata", "add-member", "alice")
+	env.mustRun(t, "software", "stata", "add-admin", "alice")
+	env.mustRun(t, "software", "matlab", "remove-admin", "alice")
+	if env.hasMember(adminsDN, aliceDN) {
+		t.Error("alice still in the admins group after remove-admin")
+	}
+	if !env.hasMember(softwareAdminsGroupDN, aliceDN) {
+		t.Error("alice removed from the top-level admins group while still a stata admin")
+	}
+
+	// Removing a member also removes them as an admin.
+	env.mustRun(t, "software", "stata", "remove-member", "alice")
+	if env.hasMember("CN=is.racs.software.stata.admins,"+softwareDN, aliceDN) {
+		t.Error("alice still a stata admin after remove-member")
+	}
+	if env.hasMember(softwareAdminsGroupDN, aliceDN) {
+		t.Error("alice still in the top-level admins group with no software left to administer")
+	}
+}
+
+// TestSoftwareLegacyGroupWithoutAdmins checks that a software group
+// without an admins group can still be listed, have admins removed, and
+// be deleted, without an admins group being created for it.
+func TestSoftwareLegacyGroupWithoutAdmins(t *testing.T) {
+	env := newTestEnv(t, "")
+	env.addSoftwareGroup(t, "legacy", false)
+	const adminsDN = "CN=is.racs.software.legacy.admins," + softwareDN
+
+	if got := env.mustRun(t, "software", "legacy", "list-admins"); got != "" {
+		t.Errorf("list-admins of a legacy group = %q, want nothing", got)
+	}
+	env.mustRun(t, "software", "legacy", "remove-admin", "alice")
+	if env.dir.Exists(adminsDN) {
+		t.Error("remove-admin created an admins group")
+	}
+	env.mustRun(t, "software", "legacy", "delete")
+	if env.dir.Exists("CN=is.racs.software.legacy," + softwareDN) {
+		t.Error("legacy group not deleted")
+	}
+}
+
+// TestSoftwareAdminsLayoutMismatch checks that the admins group is created
+// next to the main group when software_layout no longer matches the layout
+// the group was created with.
+func TestSoftwareAdminsLayoutMismatch(t *testing.T) {
+	cases := []struct {
+		name       string
+		layout     string
+		perGroupOU bool
+	}{
+		{name: "ou group with flat layout", layout: "flat", perGroupOU: true},
+		{name: "flat group with ou layout", layout: "ou", perGroupOU: false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("DIRECTORY_MANAGER_SOFTWARE_LAYOUT", tc.layout)
+			env := newTestEnv(t, "")
+			groupDN := env.addSoftwareGroup(t, "matlab", tc.perGroupOU)
+			parentDN := strings.SplitN(groupDN, ",", 2)[1]
+			env.mustRun(t, "software", "matlab", "add-member", "alice")
+			env.mustRun(t, "software", "matlab", "add-admin", "alice")
+
+			adminsDN := "CN=is.racs.software.matlab.admins," + parentDN
+			if !env.hasMember(adminsDN, aliceDN) {
+				t.Errorf("alice not in an admins group at %s", adminsDN)
+			}
+			if got := env.mustRun(t, "software", "matlab", "list-admins"); got != "alice\n" {
+				t.Errorf("list-admins = %q, want alice", got)
+			}
+
+			env.mustRun(t, "software", "matlab", "remove-member", "alice")
+			env.mustRun(t, "software", "matlab", "delete")
+			for _, dn := range []string{groupDN, adminsDN} {
+				if env.dir.Exists(dn) {
+					t.Errorf("%s left behind by delete", dn)
+				}
+			}
+		})
+	}
+}
+
+// TestSoftwareLayouts checks creating, listing, and deleting software
+// groups with each software_layout, in a directory that already holds
+// groups of both layouts.
+func TestSoftwareLayouts(t *testing.T) {
+	cases := []struct {
+		layout string
+		newDN  string
+	}{
+		{layout: "flat", newDN: "CN=is.racs.software.stata," + softwareDN},
+		{layout: "ou", newDN: "CN=is.racs.software.stata,OU=stata," + softwareDN},
+	}
+	for _, tc := range cases {
+		t.Run(tc.layout, func(t *testing.T) {
+			t.Setenv("DIRECTORY_MANAGER_SOFTWARE_LAYOUT", tc.layout)
+			env := newTestEnv(t, "")
+			flatDN := env.addSoftwareGroup(t, "gaussian", false)
+			ouDN := env.addSoftwareGroup(t, "matlab", true)
+
+			env.mustRun(t, "software", "stata", "create")
+			if !env.dir.Exists(tc.newDN) {
+				t.Fatalf("create didn't put the group at %s", tc.newDN)
+			}
+			if got := env.mustRun(t, "software", "list"); got != "gaussian\nmatlab\nstata\n" {
+				t.Errorf("list = %q, want all three groups", got)
+			}
+			for _, name := range []string{"gaussian", "matlab", "stata"} {
+				env.mustRun(t, "software", name, "add-member", "alice")
+				if got := env.mustRun(t, "software", name, "list-members"); got != "alice\n" {
+					t.Errorf("%s list-members = %q, want alice", name, got)
+				}
+				env.mustRun(t, "software", name, "remove-member", "alice")
+			}
+
+			for _, name := range []string{"gaussian", "matlab", "stata"} {
+				env.mustRun(t, "software", name, "delete")
+			}
+			for _, dn := range []string{flatDN, ouDN, "OU=matlab," + softwareDN, tc.newDN, "OU=stata," + softwareDN} {
+				if env.dir.Exists(dn) {
+					t.Errorf("%s left behind", dn)
+				}
+			}
+			if !env.dir.Exists(softwareDN) {
+				t.Error("delete removed the software base OU")
+			}
+			if got := env.mustRun(t, "software", "list"); got != "No Software groups found.\n" {
+				t.Errorf("list after deleting = %q, want none", got)
+			}
+		})
+	}
+}
+
+// TestSoftwareDeleteNotEmpty checks that a software group in its own OU
+// isn't deleted, OU and all, while it still has members.
+func TestSoftwareDeleteNotEmpty(t *testing.T) {
+	env := newTestEnv(t, "")
+	groupDN := env.addSoftwareGroup(t, "matlab", true)
+	env.mustRun(t, "software", "matlab", "add-member", "alice")
+	code, stdout, _ := env.run("software", "matlab", "delete")
+	if code == 0 || !strings.Contains(stdout, "has 1 members") {
+		t.Errorf("delete of a group with members exited %d:\n%s", code, stdout)
+	}
+	if !env.dir.Exists(groupDN) {
+		t.Error("delete removed a group with members")
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestNoLDAPConfig checks that commands which don't touch the directory
+// run with no LDAP settings at all, and that those which do fail with the
+// missing setting rather than at startup.
+func TestNoLDAPConfig(t *testing.T) {
+	for _, name := range []string{"DIRECTORY_MANAGER_LDAP_SERVER", "DIRECTORY_MANAGER_LDAP_SERVERS", "DIRECTORY_MANAGER_LDAP_USERNAME", "DIRECTORY_MANAGER_LDAP_PASSWORD"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+	tmp := t.TempDir()
+	config := filepath.Join(tmp, "config.yaml")
+	text := "data_path: " + tmp + "\ndisable_update_check: true\n"
+	if err := os.WriteFile(config, []byte(text), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		args     []string
+		wantCode int
+		want     string
+	}{
+		{args: []string{"--help"}, want: "Usage: directory-manager <command>"},
+		{args: []string{"pirg", "--help"}, want: "Usage: directory-manager pirg <command>"},
+		{args: []string{"version"}, want: "Version: " + version},
+		{args: []string{"schema"}, want: `"schema_version":`},
+		{args: []string{"config", "validate"}, wantCode: 1, want: "ldap_server or ldap_servers is required"},
+		{args: []string{"pirg", "list"}, wantCode: 1, want: "failed to connect to LDAP: ldap_server or ldap_servers is required"},
+	}
+	for _, tc := range cases {
+		t.Run(strings.Join(tc.args, " "), func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := Run(append([]string{"--config", config}, tc.args...), &stdout, &stderr)
+			if code != tc.wantCode {
+				t.Fatalf("exit %d, want %d, stdout:\n%s\nstderr:\n%s", code, tc.wantCode, stdout.String(), stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tc.want) {
+				t.Errorf("stdout doesn't contain %q:\n%s", tc.want, stdout.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/ldap/ldaptest"
+)
+
+const alphaLabDN = "CN=is.racs.pirg.alpha.lab,OU=Groups,OU=alpha,OU=PIRGS,OU=RACS,DC=ad,DC=uoregon,DC=edu"
+
+// TestSubgroupManager checks that a subgroup can be delegated to a member
+// of its PIRG, and that removing the manager from the PIRG clears it.
+func TestSubgroupManager(t *testing.T) {
+	env := newTestEnv(t, "")
+	if got := env.mustRun(t, "pirg", "alpha", "subgroup", "lab", "get-manager"); got != "No manager set.\n" {
+		t.Errorf("get-manager before set-manager = %q", got)
+	}
+
+	code, stdout, _ := env.run("pirg", "alpha", "subgroup", "lab", "set-manager", "carol")
+	if code == 0 || !strings.Contains(stdout, "carol is not a member of PIRG alpha") {
+		t.Errorf("set-manager of a non-member exited %d:\n%s", code, stdout)
+	}
+	if got := env.dir.Values(alphaLabDN, "managedBy"); len(got) != 0 {
+		t.Errorf("refused set-manager set managedBy to %q", got)
+	}
+
+	env.mustRun(t, "pirg", "alpha", "subgroup", "lab", "set-manager", "bob")
+	if got := env.dir.Values(alphaLabDN, "managedBy"); len(got) != 1 || !strings.EqualFold(got[0], bobDN) {
+		t.Errorf("managedBy = %q, want bob", got)
+	}
+	if got := env.mustRun(t, "pirg", "alpha", "subgroup", "lab", "get-manager"); got != "bob\n" {
+		t.Errorf("get-manager = %q, want bob", got)
+	}
+
+	_, _, stderr := env.run("pirg", "alpha", "remove-member", "bob")
+	if !strings.Contains(stderr, "Clearing subgroup manager removed from PIRG") {
+		t.Errorf("clearing the manager wasn't logged:\n%s", stderr)
+	}
+	if got := env.dir.Values(alphaLabDN, "managedBy"); len(got) != 0 {
+		t.Errorf("managedBy = %q after the manager left the PIRG", got)
+	}
+	if got := env.mustRun(t, "pirg", "alpha", "subgroup", "lab", "get-manager"); got != "No manager set.\n" {
+		t.Errorf("get-manager after the manager left = %q", got)
+	}
+}
+
+// TestSubgroupDescriptionRefused checks that a subgroup isn't created when
+// its description is missing under require_subgroup_description or longer
+// than the 1024-character cap.
+func TestSubgroupDescriptionRefused(t *testing.T) {
+	cases := []struct {
+		name  string
+		extra string
+		args  []string
+		want  string
+	}{
+		{
+			name:  "required",
+			extra: "require_subgroup_description: true\n",
+			want:  "a description is required by require_subgroup_description",
+		},
+		{
+			name: "too long",
+			args: []string{"--description", strings.Repeat("x", 1025)},
+			want: "description is 1025 characters, the limit is 1024",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := newTestEnv(t, tc.extra)
+			args := append([]string{"pirg", "alpha", "subgroup", "bench", "create"}, tc.args...)
+			code, stdout, stderr := env.run(args...)
+			if code != 1 {
+				t.Fatalf("exit %d, want 1, stdout:\n%s\nstderr:\n%s", code, stdout, stderr)
+			}
+			if !strings.Contains(stdout, tc.want) {
+				t.Errorf("stdout doesn't contain %q:\n%s", tc.want, stdout)
+			}
+			if got := env.mustRun(t, "pirg", "alpha", "subgroup", "list"); got != "lab\n" {
+				t.Errorf("subgroup list after a refused create = %q", got)
+			}
+		})
+	}
+
+	env := newTestEnv(t, "require_subgroup_description: true\n")
+	env.mustRun(t, "pirg", "alpha", "subgroup", "bench", "create", "--description", "Bench instrument users")
+	if got := env.mustRun(t, "pirg", "alpha", "subgroup", "list"); got != "bench\nlab\n" {
+		t.Errorf("subgroup list = %q, want bench and lab", got)
+	}
+}
+
+// searchRecorder records the filters of the searches made through it until
+// the first modify.
+type searchRecorder struct {
+	*ldaptest.Directory
+	filters  []string
+	modified bool
+}
+
+func (r *searchRecorder) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	if !r.modified {
+		r.filters = append(r.filters, req.Filter)
+	}
+	return r.Directory.Search(req)
+}
+
+func (r *searchRecorder) SearchWithPaging(req *ldap.SearchRequest, pagingSize uint32) (*ldap.SearchResult, error) {
+	if !r.modified {
+		r.filters = append(r.filters, req.Filter)
+	}
+	return r.Directory.SearchWithPaging(req, pagingSize)
+}
+
+func (r *searchRecorder) Modify(req *ldap.ModifyRequest) error {
+	r.modified = true
+	return r.Directory.Modify(req)
+}
+
+// TestSubgroupAddMemberSearches checks that subgroup add-member finds the
+// PIRG and the subgroup with one search and looks the user up once.
+func TestSubgroupAddMemberSearches(t *testing.T) {
+	env := newTestEnv(t, "")
+	env.mustRun(t, "pirg", "alpha", "add-member", "carol")
+	recorder := &searchRecorder{Directory: env.dir}
+	connectDirectory = func(ctx context.Context) context.Context {
+		return ld.WithClient(ctx, recorder)
+	}
+
+	env.mustRun(t, "pirg", "alpha", "subgroup", "lab", "add-member", "carol")
+	if !env.hasMember(alphaLabDN, carolDN) {
+		t.Fatal("carol not added to the subgroup")
+	}
+	if !recorder.modified {
+		t.Fatal("no modify was made")
+	}
+	want := "(&(objectClass=group)(|(cn=is.racs.pirg.alpha)(cn=is.racs.pirg.alpha.lab)))"
+	if recorder.filters[0] != want {
+		t.Errorf("first search = %s, want %s", recorder.filters[0], want)
+	}
+	var lookups int
+	for _, f := range recorder.filters {
+		if strings.Contains(f, "(cn=is.racs.pirg.alpha)") || strings.Contains(f, "(cn=is.racs.pirg.alpha.lab)") {
+			if f != want {
+				t.Errorf("PIRG or subgroup searched for again: %s", f)
+			}
+		}
+		if strings.Contains(f, "sAMAccountName=carol") {
+			lookups++
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("carol looked up %d times before the modify, want 1", lookups)
+	}
+	if len(recorder.filters) > 5 {
+		t.Errorf("%d searches before the modify, want at most 5:\n%s", len(recorder.filters), strings.Join(recorder.filters, "\n"))
+	}
+}
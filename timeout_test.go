@@ -0,0 +1,22 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestTimeoutBoundsCommand checks that once --timeout has passed, the
+// command stops sending requests and reports the timeout.
+func TestTimeoutBoundsCommand(t *testing.T) {
+	env := newTestEnv(t, "")
+	code, stdout, _ := env.run("--timeout", "1ns", "--output", "json", "pirg", "alpha", "add-member", "carol")
+	if code == 0 {
+		t.Fatalf("command exited 0 after its timeout:\n%s", stdout)
+	}
+	if !strings.Contains(stdout, `"code":"timeout"`) || !strings.Contains(stdout, "command timed out") {
+		t.Errorf("output doesn't report the timeout:\n%s", stdout)
+	}
+	if env.hasMember(alphaDN, carolDN) {
+		t.Error("carol was added after the timeout")
+	}
+}
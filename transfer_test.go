@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const alphaPIDN = "CN=is.racs.pirg.alpha.pi,OU=alpha,OU=PIRGS,OU=RACS,DC=ad,DC=uoregon,DC=edu"
+
+func TestTransferOwnership(t *testing.T) {
+	env := newTestEnv(t, "")
+	code, stdout, stderr := env.run("transfer-ownership", "--from", "alice", "--to", "bob", "-y")
+	if code != 0 {
+		t.Fatalf("exit %d, stdout:\n%s\nstderr:\n%s", code, stdout, stderr)
+	}
+	if !strings.Contains(stdout, "Transferred: pirg alpha PI") {
+		t.Errorf("alpha not reported as transferred:\n%s", stdout)
+	}
+	if env.hasMember(alphaPIDN, aliceDN) || !env.hasMember(alphaPIDN, "CN=bob,OU=People,DC=ad,DC=uoregon,DC=edu") {
+		t.Errorf("PI group members = %v, want only bob", env.dir.Values(alphaPIDN, "member"))
+	}
+}
+
+// TestTransferOwnershipBadTarget checks that a target that can't take the
+// roles is refused before the plan is shown or anything changes.
+func TestTransferOwnershipBadTarget(t *testing.T) {
+	cases := []struct {
+		name    string
+		to      string
+		wantErr string
+	}{
+		{name: "missing", to: "nobody", wantErr: `user "nobody" not found`},
+		{name: "same user", to: "alice", wantErr: "alice and alice are the same user"},
+		{name: "same user in another case", to: "ALICE", wantErr: "are the same user"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := newTestEnv(t, "")
+			code, stdout, stderr := env.run("transfer-ownership", "--from", "alice", "--to", tc.to, "-y")
+			if code == 0 {
+				t.Fatalf("exit 0, stdout:\n%s", stdout)
+			}
+			if strings.Contains(stdout, "Transferring") {
+				t.Errorf("plan shown for a bad target:\n%s", stdout)
+			}
+			if !strings.Contains(stdout+stderr, tc.wantErr) {
+				t.Errorf("output = %q, want it to contain %q", stdout+stderr, tc.wantErr)
+			}
+			if !env.hasMember(alphaPIDN, aliceDN) {
+				t.Error("alice is no longer PI of alpha")
+			}
+		})
+	}
+}
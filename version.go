@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uoracs/directory-manager/internal/config"
+)
+
+// Build metadata, set with -ldflags "-X main.commit=... -X main.date=...".
+var (
+	commit = "unknown"
+	date   = "unknown"
+)
+
+// latestReleaseURL is where version --check looks for the newest release.
+// It's a variable so tests can point it at a local server.
+var latestReleaseURL = "https://api.github.com/repos/uoracs/directory-manager/releases/latest"
+
+const (
+	updateCheckTimeout = 5 * time.Second
+
+	// exitUpdateAvailable is the exit code of version --check when a newer release exists.
+	exitUpdateAvailable = 5
+)
+
+// versionString is the one-line form printed by -v.
+func versionString() string {
+	return fmt.Sprintf("%s (commit %s, built %s)", version, commit, date)
+}
+
+type versionInfo struct {
+	Version         string `json:"version"`
+	Commit          string `json:"commit"`
+	Date            string `json:"date"`
+	Latest          string `json:"latest,omitempty"`
+	UpdateAvailable *bool  `json:"update_available,omitempty"`
+	ChangelogURL    string `json:"changelog_url,omitempty"`
+}
+
+type release struct {
+	TagName string `json:"tag_name"`
+	HTMLURL string `json:"html_url"`
+}
+
+// latestRelease fetches the newest published release. The default transport
+// honours HTTP_PROXY/HTTPS_PROXY.
+func latestRelease(ctx context.Context, url string) (release, error) {
+	ctx, cancel := context.WithTimeout(ctx, updateCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return release{}, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return release{}, fmt.Errorf("failed to fetch latest release: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return release{}, fmt.Errorf("failed to fetch latest release: %s", resp.Status)
+	}
+	var r release
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return release{}, fmt.Errorf("failed to decode latest release: %w", err)
+	}
+	if r.TagName == "" {
+		return release{}, fmt.Errorf("latest release has no tag")
+	}
+	return r, nil
+}
+
+// compareVersions compares two vX.Y.Z versions numerically, ignoring any
+// pre-release or build suffix. It returns -1, 0, or 1.
+func compareVersions(a, b string) (int, error) {
+	pa, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	pb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range pa {
+		if pa[i] != pb[i] {
+			if pa[i] < pb[i] {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func parseVersion(v string) ([3]int, error) {
+	var parts [3]int
+	core, _, _ := strings.Cut(strings.TrimPrefix(v, "v"), "-")
+	core, _, _ = strings.Cut(core, "+")
+	fields := strings.Split(core, ".")
+	if len(fields) > 3 {
+		return parts, fmt.Errorf("invalid version %q", v)
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return parts, fmt.Errorf("invalid version %q", v)
+		}
+		parts[i] = n
+	}
+	return parts, nil
+}
+
+// runVersion handles the version command and returns the exit code.
+// It runs before the LDAP connection is made, and only reads the config
+// to see whether update checks are disabled.
+func runVersion(asJSON bool, check bool) int {
+	info := versionInfo{Version: version, Commit: commit, Date: date}
+	code := 0
+	if check {
+		if cfg, err := config.GetConfig(CLI.Config); err == nil && cfg.UpdateCheckDisabled() {
+			fmt.Fprintln(stdout, "Update check is disabled by configuration.")
+			return 0
+		}
+		r, err := latestRelease(context.Background(), latestReleaseURL)
+		if err != nil {
+			fmt.Fprintf(stdout, "Error checking for updates: %v\n", err)
+			return 1
+		}
+		cmp, err := compareVersions(version, r.TagName)
+		if err != nil {
+			fmt.Fprintf(stdout, "Error checking for updates: %v\n", err)
+			return 1
+		}
+		available := cmp < 0
+		info.Latest = r.TagName
+		info.UpdateAvailable = &available
+		if available {
+			info.ChangelogURL = r.HTMLURL
+			code = exitUpdateAvailable
+		}
+	}
+
+	if asJSON || jsonOutput() {
+		printJSON(info)
+		return code
+	}
+	fmt.Fprintf(stdout, "Version: %s\nCommit: %s\nBuilt: %s\n", info.Version, info.Commit, info.Date)
+	if info.UpdateAvailable != nil {
+		if *info.UpdateAvailable {
+			fmt.Fprintf(stdout, "Update available: %s\nChangelog: %s\n", info.Latest, info.ChangelogURL)
+		} else {
+			fmt.Fprintln(stdout, "Up to date.")
+		}
+	}
+	return code
+}
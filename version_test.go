@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"v1.2.3", "v1.2.3", 0},
+		{"1.2.3", "v1.2.3", 0},
+		{"v1.2.3", "v1.2.4", -1},
+		{"v1.10.0", "v1.9.9", 1},
+		{"v2.0.0", "v1.99.99", 1},
+		{"v1.2", "v1.2.0", 0},
+		{"v1", "v1.0.1", -1},
+		{"v1.2.3-rc.1", "v1.2.3", 0},
+		{"v1.2.3+build.5", "v1.2.4", -1},
+	}
+	for _, tc := range cases {
+		got, err := compareVersions(tc.a, tc.b)
+		if err != nil {
+			t.Errorf("compareVersions(%q, %q): %v", tc.a, tc.b, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestCompareVersionsInvalid(t *testing.T) {
+	for _, v := range []string{"", "vX.1.0", "v1.2.3.4", "v1..2", "latest"} {
+		if _, err := compareVersions(v, "v1.0.0"); err == nil {
+			t.Errorf("compareVersions(%q, ...) gave no error", v)
+		}
+		if _, err := compareVersions("v1.0.0", v); err == nil {
+			t.Errorf("compareVersions(..., %q) gave no error", v)
+		}
+	}
+}
+
+// serveRelease points latestReleaseURL at a server that answers with
+// status and body for the duration of the test, and turns the update
+// check back on, which testdata/config.yaml disables.
+func serveRelease(t *testing.T, status int, body string) {
+	t.Helper()
+	t.Setenv("DIRECTORY_MANAGER_DISABLE_UPDATE_CHECK", "false")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if accept := r.Header.Get("Accept"); accept != "application/vnd.github+json" {
+			t.Errorf("Accept header = %q", accept)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	previous := latestReleaseURL
+	latestReleaseURL = server.URL
+	t.Cleanup(func() { latestReleaseURL = previous })
+}
+
+func TestVersionCheck(t *testing.T) {
+	cases := []struct {
+		name     string
+		status   int
+		body     string
+		wantCode int
+		want     string
+	}{
+		{
+			name:     "update available",
+			status:   http.StatusOK,
+			body:     `{"tag_name": "v99.0.0", "html_url": "https://example.com/v99.0.0"}`,
+			wantCode: exitUpdateAvailable,
+			want:     "Update available: v99.0.0\nChangelog: https://example.com/v99.0.0\n",
+		},
+		{
+			name:   "up to date",
+			status: http.StatusOK,
+			body:   `{"tag_name": "` + version + `", "html_url": "https://example.com/"}`,
+			want:   "Up to date.\n",
+		},
+		{
+			name:     "server error",
+			status:   http.StatusInternalServerError,
+			wantCode: 1,
+			want:     "Error checking for updates: failed to fetch latest release: 500 Internal Server Error\n",
+		},
+		{
+			name:     "no tag",
+			status:   http.StatusOK,
+			body:     `{}`,
+			wantCode: 1,
+			want:     "Error checking for updates: latest release has no tag\n",
+		},
+		{
+			name:     "invalid tag",
+			status:   http.StatusOK,
+			body:     `{"tag_name": "nightly"}`,
+			wantCode: 1,
+			want:     "Error checking for updates: invalid version \"nightly\"\n",
+		},
+		{
+			name:     "not JSON",
+			status:   http.StatusOK,
+			body:     `<html>`,
+			wantCode: 1,
+			want:     "Error checking for updates: failed to decode latest release",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			serveRelease(t, tc.status, tc.body)
+			env := newTestEnv(t, "")
+			code, stdout, _ := env.run("version", "--check")
+			if code != tc.wantCode {
+				t.Errorf("exit %d, want %d; output:\n%s", code, tc.wantCode, stdout)
+			}
+			if !strings.Contains(stdout, tc.want) {
+				t.Errorf("output missing %q:\n%s", tc.want, stdout)
+			}
+		})
+	}
+}
+
+func TestVersionCheckJSON(t *testing.T) {
+	serveRelease(t, http.StatusOK, `{"tag_name": "v99.0.0", "html_url": "https://example.com/v99.0.0"}`)
+	env := newTestEnv(t, "")
+	code, stdout, _ := env.run("version", "--check", "--json")
+	if code != exitUpdateAvailable {
+		t.Errorf("exit %d, want %d", code, exitUpdateAvailable)
+	}
+	var info versionInfo
+	if err := json.Unmarshal([]byte(stdout), &info); err != nil {
+		t.Fatalf("output isn't JSON: %v\n%s", err, stdout)
+	}
+	if info.Latest != "v99.0.0" || info.UpdateAvailable == nil || !*info.UpdateAvailable || info.ChangelogURL != "https://example.com/v99.0.0" {
+		t.Errorf("version info = %+v", info)
+	}
+}
+
+func TestVersionCheckDisabled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("update check contacted the server although it is disabled")
+	}))
+	t.Cleanup(server.Close)
+	previous := latestReleaseURL
+	latestReleaseURL = server.URL
+	t.Cleanup(func() { latestReleaseURL = previous })
+
+	// testdata/config.yaml sets disable_update_check
+	env := newTestEnv(t, "")
+	code, stdout, _ := env.run("version", "--check")
+	if code != 0 || !strings.Contains(stdout, "Update check is disabled by configuration.") {
+		t.Errorf("exit %d, output:\n%s", code, stdout)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/uoracs/directory-manager/internal/config"
+	ld "github.com/uoracs/directory-manager/internal/ldap"
+	"github.com/uoracs/directory-manager/internal/webhook"
+)
+
+// notifyWebhook posts to webhook_url an event for each change the command
+// sent to the directory, however the command ended, with whether that
+// change succeeded. A membership change posts one event per member.
+// Writes to gid_highwater_dn are bookkeeping and aren't posted. The changes
+// have already been made, so failing to post only warns.
+func notifyWebhook(ctx context.Context, cfg *config.Config, command string) {
+	if cfg.WebhookURL == "" || !changesDirectory(command) {
+		return
+	}
+	var changes []ld.Change
+	var memberDNs []string
+	for _, change := range ld.Changes(ctx) {
+		if cfg.GidHighWaterDN != "" && strings.EqualFold(change.DN, cfg.GidHighWaterDN) {
+			continue
+		}
+		changes = append(changes, change)
+		memberDNs = append(memberDNs, change.Members...)
+	}
+	if len(changes) == 0 {
+		return
+	}
+	usernames, err := ld.GetUserAttributes(ctx, memberDNs, []string{"sAMAccountName"})
+	if err != nil {
+		slog.Warn("Failed to look up usernames for webhook events", "error", err)
+	}
+
+	words := commandWords(command)
+	base := webhook.Event{
+		Operation: strings.Join(words, " "),
+		Namespace: words[0],
+		Timestamp: time.Now().UTC(),
+	}
+	if f, name, ok := noteTarget(command); ok {
+		base.Operation = strings.Join(words[1:], " ")
+		base.Namespace, base.Group = f.Name, name
+	}
+	var events []webhook.Event
+	for _, change := range changes {
+		event := base
+		event.Change, event.DN, event.Result = change.Op, change.DN, "success"
+		if change.Err != nil {
+			event.Result, event.Error = "failure", change.Err.Error()
+		}
+		if len(change.Members) == 0 {
+			events = append(events, event)
+			continue
+		}
+		for _, member := range change.Members {
+			event.Member = member
+			event.User = usernames[strings.ToLower(member)]["samaccountname"]
+			events = append(events, event)
+		}
+	}
+	if err := webhook.Send(ctx, cfg.WebhookURL, events); err != nil {
+		slog.Warn("Failed to notify webhook", "error", err)
+	}
+}
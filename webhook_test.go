@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/uoracs/directory-manager/internal/webhook"
+)
+
+const gammaDN = "CN=is.racs.pirg.gamma,OU=gamma,OU=PIRGS,OU=RACS,DC=ad,DC=uoregon,DC=edu"
+
+// webhookReceiver collects the events posted to it.
+type webhookReceiver struct {
+	mu     sync.Mutex
+	events []webhook.Event
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var events []webhook.Event
+	if err := json.NewDecoder(req.Body).Decode(&events); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.mu.Lock()
+	r.events = append(r.events, events...)
+	r.mu.Unlock()
+}
+
+func newWebhookEnv(t *testing.T) (*testEnv, *webhookReceiver) {
+	t.Helper()
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+	return newTestEnv(t, "webhook_url: "+server.URL+"\n"), receiver
+}
+
+func TestWebhookPostsEachChange(t *testing.T) {
+	env, receiver := newWebhookEnv(t)
+	// dave is disabled, so the command fails after adding carol
+	if code, _, _ := env.run("pirg", "alpha", "add-member", "carol", "dave"); code == 0 {
+		t.Fatal("adding a disabled account exited 0")
+	}
+	var carol []webhook.Event
+	for _, event := range receiver.events {
+		if event.Member == carolDN {
+			carol = append(carol, event)
+		}
+		if event.User == "dave" {
+			t.Errorf("event posted for dave, who wasn't added: %+v", event)
+		}
+		if event.Result != "success" {
+			t.Errorf("event with result %q: %+v", event.Result, event)
+		}
+	}
+	var sawMain bool
+	for _, event := range carol {
+		if event.Operation != "add-member" || event.Namespace != "pirg" || event.Group != "alpha" || event.User != "carol" || event.Change != "modify" {
+			t.Errorf("unexpected event for carol: %+v", event)
+		}
+		if event.DN == alphaDN {
+			sawMain = true
+		}
+	}
+	if !sawMain {
+		t.Errorf("no event for adding carol to %s; got %+v", alphaDN, receiver.events)
+	}
+}
+
+func TestWebhookNothingChanged(t *testing.T) {
+	env, receiver := newWebhookEnv(t)
+	if code, _, _ := env.run("pirg", "alpha", "add-member", "dave"); code == 0 {
+		t.Fatal("adding a disabled account exited 0")
+	}
+	if code, _, _ := env.run("pirg", "alpha", "list-members"); code != 0 {
+		t.Fatal("list-members failed")
+	}
+	if len(receiver.events) != 0 {
+		t.Errorf("events posted although nothing changed: %+v", receiver.events)
+	}
+}
+
+// TestWebhookParallel checks that changes made over --parallel's worker
+// connections are posted too.
+func TestWebhookParallel(t *testing.T) {
+	env, receiver := newWebhookEnv(t)
+	if code, stdout, stderr := env.run("--parallel", "2", "pirg", "gamma", "remove-member", "alice", "bob"); code != 0 {
+		t.Fatalf("exit %d, stdout:\n%s\nstderr:\n%s", code, stdout, stderr)
+	}
+	removed := map[string]bool{}
+	for _, event := range receiver.events {
+		if event.Operation == "remove-member" && event.Group == "gamma" && event.DN == gammaDN && event.Result == "success" {
+			removed[event.User] = true
+		}
+	}
+	if !removed["alice"] || !removed["bob"] {
+		t.Errorf("events for the members each worker removed missing; got %+v", receiver.events)
+	}
+}